@@ -17,7 +17,15 @@ limitations under the License.
 package v1
 
 import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -25,24 +33,1773 @@ import (
 
 // GhostSpec defines the desired state of Ghost
 type GhostSpec struct {
+	// Paused stops the controller from changing the children of the Ghost, e.g. during manual
+	// maintenance on the content volume. The status still reports ReconciliationPaused.
+	// +optional
+	Paused        bool `json:"paused,omitempty"`
 	EnableIngress bool `json:"enableIngress"`
-	// +kubebuilder:validation:Minimum=1
+	// ResyncPeriod reconciles the Ghost in full at this interval even when nothing changed, to
+	// correct drift outside the watched resources such as Admin API settings or DNS records. It
+	// overrides the --resync-period of the operator, 0s turns the resync off for this Ghost.
+	// +optional
+	ResyncPeriod *metav1.Duration `json:"resyncPeriod,omitempty"`
+	// ReconcileMode DryRun computes the children the Ghost would get and reports the changes
+	// applying them would make in status.pendingChanges and events, without changing anything
+	// +optional
+	ReconcileMode ReconcileMode `json:"reconcileMode,omitempty"`
+	// Replicas of zero stops the Ghost, its hosts then serve what stoppedBehavior asks for
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:validation:Maximum=3
 	Replicas int32 `json:"replicas"`
+	// Autoscaling hands the replica count to a HorizontalPodAutoscaler, replicas then only
+	// seeds the Deployment when it is created
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+	// ContainerPort is the port Ghost listens on, the Service, probes and routes follow it.
+	// Defaults to 2368, Ghost's own default.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ContainerPort int32 `json:"containerPort,omitempty"`
+	// TerminationGracePeriodSeconds is how long a stopping pod may finish its requests before it
+	// is killed, 30 seconds when unset. It includes preStopSleepSeconds.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+	// PreStopSleepSeconds keeps a stopping pod serving while the ingress controller and the
+	// kube-proxies drop its endpoint, so the requests still routed to it do not fail
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=300
+	// +optional
+	PreStopSleepSeconds *int32 `json:"preStopSleepSeconds,omitempty"`
+	// ImageTag is the Ghost image tag, superseded by image.tag
 	// +kubebuilder:validation:Pattern=`^[-a-z0-9]*$`
-	ImageTag string `json:"imageTag"`
+	// +optional
+	ImageTag string `json:"imageTag,omitempty"`
+	// +optional
+	Image *ImageSpec `json:"image,omitempty"`
+	// CommonLabels are added to every child resource and the pod template
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// CommonAnnotations are added to every child resource and the pod template
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// Tags group Ghosts into logical fleets, such as region: eu. Each tag is set as the label
+	// tags.marketing.kb.dev/<key> on the Ghost and its children, for selectors and
+	// kubectl ghost list --tag to pick them up.
+	// +kubebuilder:validation:MaxProperties=20
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// StoppedBehavior is what the hosts of a Ghost with zero replicas serve. MaintenancePage
+	// answers every request with a 503 maintenance page, RemoveIngress removes the Ingress,
+	// HTTPRoute or Route so the hosts are no longer routed.
+	// +kubebuilder:default=MaintenancePage
+	// +optional
+	StoppedBehavior StoppedBehavior `json:"stoppedBehavior,omitempty"`
+	// ImageTagPolicy moves the Ghost to the newest release in the registry matching its tag,
+	// e.g. 5.96.0-alpine tracks 5.96.x-alpine with TrackPatch and 5.x.y-alpine with TrackMinor.
+	// The tag is the oldest release to run, the release running is status.resolvedImage.
+	// +kubebuilder:default=Pinned
+	// +optional
+	ImageTagPolicy ImageTagPolicy `json:"imageTagPolicy,omitempty"`
+	// +optional
+	Mail *MailSpec `json:"mail,omitempty"`
+	// Database selects the database Ghost stores its content in, the SQLite file of the content
+	// volume when unset
+	// +optional
+	Database *DatabaseSpec `json:"database,omitempty"`
+	// +optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+	// +optional
+	Routing *RoutingSpec `json:"routing,omitempty"`
+	// +optional
+	Exposure *ExposureSpec `json:"exposure,omitempty"`
+	// +optional
+	Service *ServiceSpec `json:"service,omitempty"`
+	// +optional
+	Scheduling *SchedulingSpec `json:"scheduling,omitempty"`
+	// PriorityClassName schedules the Ghost pods ahead of, and preempting, lower priority workloads
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// RuntimeClassName runs the Ghost pods in another container runtime, e.g. gVisor for a sandbox
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+	// Profile bundles environment specific defaults, explicit fields still take precedence
+	// +optional
+	Profile Profile `json:"profile,omitempty"`
+	// Environment is the NODE_ENV Ghost runs in, the one of the profile when unset
+	// +optional
+	Environment NodeEnvironment `json:"environment,omitempty"`
+	// URL is the address Ghost builds absolute links, emails and newsletters with. It is derived
+	// from the first host and its TLS when unset, set it when Ghost is reached through a CDN or
+	// a proxy of its own.
+	// +kubebuilder:validation:Pattern=`^https?://[^/]+(/.*)?$`
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Timezone is the IANA time zone Ghost runs in, e.g. Europe/Berlin, UTC when unset. It sets
+	// TZ, the publication's timezone shown to readers stays a site setting in Ghost Admin.
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+	// Locale is the language of the publication, e.g. de or pt-BR, which picks the default
+	// theme bundle of the operator. It replaces the marketing.kb.dev/locale annotation.
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8})*$`
+	// +optional
+	Locale string `json:"locale,omitempty"`
+	// Resources overrides the profile's resource preset for the Ghost container
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// EphemeralStorage bounds the node local disk used by the Ghost container and the scratch
+	// volumes of its backup and restore jobs
+	// +optional
+	EphemeralStorage *EphemeralStorageSpec `json:"ephemeralStorage,omitempty"`
+	// +optional
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+	// AccessLogs turns the per-request logs of the ingress controller and of Ghost on or off,
+	// unset leaves both at their defaults
+	// +optional
+	AccessLogs *AccessLogsSpec `json:"accessLogs,omitempty"`
+	// Logging configures the log output of Ghost and ships it to a central logging stack
+	// +optional
+	Logging *LoggingSpec `json:"logging,omitempty"`
+	// Indexable controls whether search engines may index the site, defaults to true
+	// for production and unprofiled instances and false otherwise
+	// +optional
+	Indexable *bool `json:"indexable,omitempty"`
+	// +optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+	// EvictionProtection keeps the cluster autoscaler and drains from evicting the only
+	// pod of a single replica Ghost
+	// +optional
+	EvictionProtection bool `json:"evictionProtection,omitempty"`
+	// PodDisruptionBudget bounds voluntary disruptions of a Ghost running more than one
+	// replica, at most one pod is unavailable when unset
+	// +optional
+	PodDisruptionBudget *PodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+	// NetworkPolicy isolates the Ghost pods from everything but the ingress controller
+	// +optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+	// OwnerGroup is the group of the team owning the Ghost, it is granted read access to the
+	// Ghost, its children and its pods and logs in the namespace. In PortForwardOnly exposure
+	// mode it may also request the port-forward tokens used by kubectl ghost open.
+	// +optional
+	OwnerGroup string `json:"ownerGroup,omitempty"`
+	// +optional
+	SchedulerCheck *SchedulerCheckSpec `json:"schedulerCheck,omitempty"`
+	// +optional
+	Persistence *PersistenceSpec `json:"persistence,omitempty"`
+	// ContentInit seeds the content volume from a git repository or an archive before Ghost starts
+	// +optional
+	ContentInit *ContentInitSpec `json:"contentInit,omitempty"`
+	// InitContainers run after the content seeding, the content volume is named ghost-data
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+	// Sidecars run next to Ghost in the same pod, e.g. an auth proxy or a log shipper
+	// +optional
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+	// ExtraVolumes are added to the pod for sidecars and extraVolumeMounts
+	// +optional
+	ExtraVolumes []corev1.Volume `json:"extraVolumes,omitempty"`
+	// ExtraVolumeMounts are added to the Ghost container
+	// +optional
+	ExtraVolumeMounts []corev1.VolumeMount `json:"extraVolumeMounts,omitempty"`
+	// PodTemplateOverlay is a strategic merge patch of a pod template, applied to the generated
+	// one as the last step, for the requirements no field covers such as hostAliases or
+	// dnsConfig. The Ghost container is named ghost.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +optional
+	PodTemplateOverlay *runtime.RawExtension `json:"podTemplateOverlay,omitempty"`
+	// Proxy routes outbound traffic of Ghost and its backup jobs through an HTTP proxy,
+	// it replaces the operator wide proxy
+	// +optional
+	Proxy *ProxySpec `json:"proxy,omitempty"`
+	// TrustedCABundle is a ConfigMap key holding PEM certificates Ghost trusts in addition to
+	// the public roots, e.g. for SMTP, S3 or webhook endpoints behind a private CA.
+	// Ghost reads it at startup, pods pick up a changed bundle on their next restart.
+	// +optional
+	TrustedCABundle *corev1.ConfigMapKeySelector `json:"trustedCABundle,omitempty"`
+	// SecurityProfiles confines the Ghost pod with seccomp and AppArmor, both default to RuntimeDefault
+	// +optional
+	SecurityProfiles *SecurityProfilesSpec `json:"securityProfiles,omitempty"`
+	// PodSecurityContext replaces the default pod security context, which runs as the node
+	// user of the Ghost image (1000) and hands it the content volume through fsGroup.
+	// securityProfiles still applies when no seccomp profile is set here.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext replaces the default security context of the Ghost container, which
+	// satisfies the Pod Security Standards restricted profile
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	// UpgradePolicy controls what happens before a new Ghost image rolls out
+	// +optional
+	UpgradePolicy *UpgradePolicySpec `json:"upgradePolicy,omitempty"`
+	// Verification checks Ghost after every change to its children before GhostReady turns
+	// True, and the new slot before a BlueGreen upgrade switches to it
+	// +optional
+	Verification *VerificationSpec `json:"verification,omitempty"`
+	// Staging maintains a linked staging instance of this Ghost
+	// +optional
+	Staging *StagingSpec `json:"staging,omitempty"`
+	// Backup takes GhostBackups on a schedule and prunes old ones, and prepares the Ghost for
+	// the cluster backups of Velero
+	// +optional
+	Backup *BackupScheduleSpec `json:"backup,omitempty"`
+	// Export saves Ghost's JSON export on a schedule, independent of the volume backups of
+	// spec.backup, for content-only recovery and content audits
+	// +optional
+	Export *ContentExportSpec `json:"export,omitempty"`
+	// Owner records who is accountable for the blog and when it is reviewed for expiry
+	// +optional
+	Owner *OwnerSpec `json:"owner,omitempty"`
+	// AdminBootstrap completes Ghost's initial setup with the owner account of a Secret once
+	// the instance answers, instead of leaving it at the interactive setup screen
+	// +optional
+	AdminBootstrap *AdminBootstrapSpec `json:"adminBootstrap,omitempty"`
+	// CacheWarmup requests key pages on a schedule so Ghost has resized their images before
+	// the first visitors after a rollout ask for them
+	// +optional
+	CacheWarmup *CacheWarmupSpec `json:"cacheWarmup,omitempty"`
+	// Events registers Ghost webhooks posting to the event receiver of the operator, which
+	// records them as Kubernetes Events, tracks status.lastPublishedAt and starts the
+	// GhostStaticBuilds of the Ghost that build on publish
+	// +optional
+	Events *EventsSpec `json:"events,omitempty"`
+	// Notifications posts a message to a Slack or generic webhook when the Ghost becomes Ready
+	// or Degraded, is upgraded or fails a backup
+	// +optional
+	Notifications *NotificationsSpec `json:"notifications,omitempty"`
+	// Headless serves Ghost as a CMS only, its public site is not routed and content changes
+	// trigger a build of the front-end instead
+	// +optional
+	Headless *HeadlessSpec `json:"headless,omitempty"`
+	// Workload selects the kind of workload running the Ghost pods
+	// +optional
+	Workload *WorkloadSpec `json:"workload,omitempty"`
+	// MediaStorage keeps uploaded images and files in object storage instead of the content volume
+	// +optional
+	MediaStorage *MediaStorageSpec `json:"mediaStorage,omitempty"`
+	// Cache moves the caches Ghost keeps in memory into a shared store
+	// +optional
+	Cache *CacheSpec `json:"cache,omitempty"`
+	// Routes is the routes.yaml of the site, its collections, channels and taxonomies
+	// +optional
+	Routes *SiteConfigSource `json:"routes,omitempty"`
+	// Redirects is the redirects.yaml, or redirects.json, of the site. Unlike
+	// spec.ingress.redirects these are answered by Ghost itself and redirect paths.
+	// +optional
+	Redirects *SiteConfigSource `json:"redirects,omitempty"`
+	// AdminAPIKeySecretRef holds the Admin API key of a Ghost custom integration, which spec.routes
+	// and spec.redirects are uploaded with. Without it they are mounted into the content directory
+	// instead, which Ghost reads at startup and every version supports.
+	// +optional
+	AdminAPIKeySecretRef *corev1.SecretKeySelector `json:"adminAPIKeySecretRef,omitempty"`
+}
+
+// SiteConfigSource holds a configuration file of the site inline or in a ConfigMap
+// +kubebuilder:validation:XValidation:rule="has(self.inline) != has(self.configMapRef)",message="exactly one of inline or configMapRef must be set"
+type SiteConfigSource struct {
+	// Inline is the content of the file, it requires spec.adminAPIKeySecretRef
+	// +optional
+	Inline string `json:"inline,omitempty"`
+	// ConfigMapRef reads the file from a ConfigMap key. A key ending in .json holds redirects in
+	// the JSON format.
+	// +optional
+	ConfigMapRef *corev1.ConfigMapKeySelector `json:"configMapRef,omitempty"`
+}
+
+// CacheSpec selects the cache adapter of Ghost
+type CacheSpec struct {
+	// Redis caches in Redis, shared by every pod and kept across restarts
+	// +optional
+	Redis *RedisCacheSpec `json:"redis,omitempty"`
+}
+
+// RedisCacheSpec configures the Redis cache adapter of Ghost, reaching Redis either at Host or
+// through a connection URL kept in a Secret
+// +kubebuilder:validation:XValidation:rule="has(self.host) != has(self.urlSecretRef)",message="exactly one of host and urlSecretRef is required"
+// +kubebuilder:validation:XValidation:rule="!has(self.passwordSecretRef) || has(self.host)",message="passwordSecretRef requires host, a URL carries its own credentials"
+type RedisCacheSpec struct {
+	// +optional
+	Host string `json:"host,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=6379
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// PasswordSecretRef selects the key of a Secret holding the Redis password
+	// +optional
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// URLSecretRef selects the key of a Secret holding a redis://[user:password@]host[:port] URL,
+	// such as the connection string of a managed Redis
+	// +optional
+	URLSecretRef *corev1.SecretKeySelector `json:"urlSecretRef,omitempty"`
+	// KeyPrefix namespaces the keys of this Ghost, <namespace>:<name>: when unset so Ghosts can
+	// share a Redis
+	// +optional
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+	// TTLSeconds a cached entry is kept, the adapter default when unset
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TTLSeconds *int32 `json:"ttlSeconds,omitempty"`
+}
+
+// MediaStorageSpec selects the storage adapter Ghost uploads media through
+type MediaStorageSpec struct {
+	// S3 stores uploads in an S3 bucket through the ghost-storage-adapter-s3 adapter
+	// +optional
+	S3 *S3MediaStorageSpec `json:"s3,omitempty"`
+}
+
+// S3MediaStorageSpec configures the S3 storage adapter. The adapter is installed into the pod by
+// an init container, which needs to reach the npm registry.
+type S3MediaStorageSpec struct {
+	// +kubebuilder:validation:MinLength=3
+	Bucket string `json:"bucket"`
+	Region string `json:"region"`
+	// Endpoint of an S3 compatible store such as MinIO, AWS when unset
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// ForcePathStyle addresses the bucket in the path rather than the host name, which most
+	// S3 compatible stores need
+	// +optional
+	ForcePathStyle bool `json:"forcePathStyle,omitempty"`
+	// AssetHost serves the uploads, such as a CDN in front of the bucket, the bucket itself when unset
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	AssetHost string `json:"assetHost,omitempty"`
+	// PathPrefix the uploads are stored under in the bucket
+	// +optional
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// CredentialsSecretRef names a Secret with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, the
+	// pod relies on the identity of its service account when unset
+	// +optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// WorkloadSpec configures the workload running the Ghost pods
+type WorkloadSpec struct {
+	// Kind is Deployment, whose pods share the content volume, or StatefulSet, which gives its
+	// pod a stable identity and a content volume of its own from a volume claim template and
+	// replaces it in order. Ghost writes its SQLite database from a single pod, so a StatefulSet
+	// runs at most one replica. The kind cannot change once the Ghost is created.
+	// +kubebuilder:default=Deployment
+	// +optional
+	Kind WorkloadKind `json:"kind,omitempty"`
+}
+
+// WorkloadKind is the kind of workload running the Ghost pods
+// +kubebuilder:validation:Enum=Deployment;StatefulSet
+type WorkloadKind string
+
+const (
+	WorkloadKindDeployment  WorkloadKind = "Deployment"
+	WorkloadKindStatefulSet WorkloadKind = "StatefulSet"
+)
+
+// TagLabelPrefix prefixes the label each entry of spec.tags is set as
+const TagLabelPrefix = "tags.marketing.kb.dev/"
+
+// TagLabels returns the labels spec.tags is surfaced as
+func TagLabels(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(tags))
+	for key, value := range tags {
+		labels[TagLabelPrefix+key] = value
+	}
+	return labels
+}
+
+// HeadlessSpec configures a Ghost whose content is rendered by a separate front-end, such as a
+// Gatsby or Next.js site. Only /ghost/, serving the admin and the APIs, and /content/, serving
+// the uploaded images and files, are routed. An OpenShift Route serves a single path, /ghost/.
+// +kubebuilder:validation:XValidation:rule="!has(self.buildHook) || has(self.adminAPIKeySecretRef)",message="buildHook requires adminAPIKeySecretRef"
+type HeadlessSpec struct {
+	// BuildHook references the Secret key holding the URL Ghost posts to on every content
+	// change, e.g. the build hook of the front-end's CI
+	// +optional
+	BuildHook *corev1.SecretKeySelector `json:"buildHook,omitempty"`
+	// AdminAPIKeySecretRef references the Admin API key of a custom integration, the build
+	// hook is registered as a webhook of that integration
+	// +optional
+	AdminAPIKeySecretRef *corev1.SecretKeySelector `json:"adminAPIKeySecretRef,omitempty"`
+}
+
+// GhostEvent is a Ghost webhook event the operator receives
+// +kubebuilder:validation:Enum=post.published;post.published.edited;post.unpublished;page.published;page.published.edited;page.unpublished;site.changed
+type GhostEvent string
+
+const (
+	GhostEventPostPublished GhostEvent = "post.published"
+	GhostEventPagePublished GhostEvent = "page.published"
+	GhostEventSiteChanged   GhostEvent = "site.changed"
+)
+
+// EventsSpec selects the Ghost webhook events posted to the operator
+type EventsSpec struct {
+	// AdminAPIKeySecretRef references the Admin API key of a custom integration, the webhooks
+	// are registered for that integration
+	AdminAPIKeySecretRef corev1.SecretKeySelector `json:"adminAPIKeySecretRef"`
+	// Events are the events received
+	// +kubebuilder:default={"post.published","site.changed"}
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	// +optional
+	Events []GhostEvent `json:"events,omitempty"`
+}
+
+// EventsStatus records the webhooks registered in Ghost for spec.events
+type EventsStatus struct {
+	// Webhooks are the IDs of the Ghost webhooks by event
+	// +optional
+	Webhooks map[GhostEvent]string `json:"webhooks,omitempty"`
+	// TargetDigest identifies the receiver URL and token the webhooks post with
+	TargetDigest string `json:"targetDigest"`
+}
+
+// NotificationEvent is a lifecycle event of a Ghost spec.notifications posts about
+// +kubebuilder:validation:Enum=Ready;Degraded;Upgraded;BackupFailed
+type NotificationEvent string
+
+const (
+	NotificationEventReady        NotificationEvent = "Ready"
+	NotificationEventDegraded     NotificationEvent = "Degraded"
+	NotificationEventUpgraded     NotificationEvent = "Upgraded"
+	NotificationEventBackupFailed NotificationEvent = "BackupFailed"
+)
+
+// NotificationsSpec configures where the lifecycle events of a Ghost are posted
+type NotificationsSpec struct {
+	// WebhookURLSecretRef references the Secret key holding the webhook URL, e.g. a Slack
+	// incoming webhook. The message is posted as JSON with its text in the text field.
+	WebhookURLSecretRef corev1.SecretKeySelector `json:"webhookURLSecretRef"`
+	// Events are the events posted
+	// +kubebuilder:default={"Ready","Degraded","Upgraded","BackupFailed"}
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	// +optional
+	Events []NotificationEvent `json:"events,omitempty"`
+}
+
+// SiteConfigStatus records the digests of the configuration files uploaded to Ghost, a live file
+// that no longer matches the digest it was uploaded with has been changed in Ghost
+type SiteConfigStatus struct {
+	// +optional
+	RoutesDigest string `json:"routesDigest,omitempty"`
+	// +optional
+	RedirectsDigest string `json:"redirectsDigest,omitempty"`
+}
+
+// HeadlessStatus records the build hook registered in Ghost
+type HeadlessStatus struct {
+	// WebhookID is the Ghost webhook posting to the build hook
+	WebhookID string `json:"webhookID"`
+	// TargetDigest identifies the build hook URL the webhook posts to without revealing it
+	TargetDigest string `json:"targetDigest"`
+}
+
+// AdminBootstrapSpec names the Secret holding the owner account created by Ghost's setup
+type AdminBootstrapSpec struct {
+	// SecretRef holds the email, name and password keys of the owner account, the password
+	// needs at least 10 characters
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+	// BlogTitle is the site title set up with the account, the Ghost name when unset
+	// +optional
+	BlogTitle string `json:"blogTitle,omitempty"`
+}
+
+// AdminBootstrapStatus records that Ghost's initial setup is complete
+type AdminBootstrapStatus struct {
+	// Email of the owner account the operator set up, empty when the setup was completed
+	// before the operator got to it
+	// +optional
+	Email       string      `json:"email,omitempty"`
+	CompletedAt metav1.Time `json:"completedAt"`
+}
+
+// CacheWarmupSpec runs a CronJob requesting a list of pages and the images they embed
+type CacheWarmupSpec struct {
+	// +kubebuilder:default="*/15 * * * *"
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+	// URLs are paths requested through the Ghost Service, such as /, or absolute URLs, e.g.
+	// to warm a CDN in front of the site
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=50
+	// +kubebuilder:validation:items:Pattern=`^(/|https?://)`
+	URLs []string `json:"urls"`
+}
+
+// OwnerSpec names the owners of a Ghost. Once ExpiryReview has passed the controller reminds
+// them to confirm the blog is still needed until the date is moved.
+type OwnerSpec struct {
+	Team string `json:"team"`
+	// Contact reaches the owners, e.g. an email address or a chat channel
+	// +optional
+	Contact string `json:"contact,omitempty"`
+	// ExpiryReview is the date the blog is next reviewed, as YYYY-MM-DD
+	// +kubebuilder:validation:Format=date
+	// +optional
+	ExpiryReview string `json:"expiryReview,omitempty"`
+}
+
+// OwnerStatus reports the expiry review of spec.owner
+type OwnerStatus struct {
+	// ReviewOverdue is set once the expiry review date has passed
+	ReviewOverdue bool `json:"reviewOverdue"`
+	// LastReminderTime is when the owners were last reminded of the overdue review
+	// +optional
+	LastReminderTime *metav1.Time `json:"lastReminderTime,omitempty"`
+}
+
+// StorageStatus reports the usage of the content volume as measured by the kubelet
+type StorageStatus struct {
+	// Capacity of the filesystem on the content volume
+	Capacity resource.Quantity `json:"capacity"`
+	// Used space of the filesystem
+	Used resource.Quantity `json:"used"`
+	// Inodes of the filesystem, unset when the filesystem does not report them
+	// +optional
+	Inodes *int64 `json:"inodes,omitempty"`
+	// InodesUsed of the filesystem, unset when the filesystem does not report them
+	// +optional
+	InodesUsed *int64 `json:"inodesUsed,omitempty"`
+}
+
+// TerminationStatus reports the last time the Ghost container stopped
+type TerminationStatus struct {
+	// Pod the container ran in
+	Pod string `json:"pod"`
+	// Reason of the termination, such as Error or OOMKilled
+	// +optional
+	Reason   string `json:"reason,omitempty"`
+	ExitCode int32  `json:"exitCode"`
+	// Message is the termination message, the tail of the container log when it failed without one
+	// +optional
+	Message    string      `json:"message,omitempty"`
+	FinishedAt metav1.Time `json:"finishedAt"`
+	// RestartCount of the container when the termination was captured
+	RestartCount int32 `json:"restartCount"`
+}
+
+// VerificationSpec configures the checks a rollout has to pass
+type VerificationSpec struct {
+	// HTTPCheck requests a path of Ghost and expects a status
+	// +optional
+	HTTPCheck *HTTPCheckSpec `json:"httpCheck,omitempty"`
+}
+
+// HTTPCheckSpec is a request Ghost has to answer with the expected status. Redirects are not
+// followed, and the request is sent as forwarded over https like the Ingress would.
+type HTTPCheckSpec struct {
+	// Path is requested under the sub-path Ghost serves
+	// +kubebuilder:default="/"
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+	// ExpectedStatus is the HTTP status Ghost has to answer with
+	// +kubebuilder:default=200
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	// +optional
+	ExpectedStatus int32 `json:"expectedStatus,omitempty"`
+	// Timeout bounds the request
+	// +kubebuilder:default="10s"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// RolloutStatus reports a rollout of the Ghost Deployment and whether Ghost passed its checks after it
+type RolloutStatus struct {
+	// Revision of the Deployment the rollout brought out
+	Revision string `json:"revision"`
+	// Image the rollout brought out
+	// +optional
+	Image     string      `json:"image,omitempty"`
+	StartedAt metav1.Time `json:"startedAt"`
+	// FinishedAt is set once the rollout completed and was checked, or failed
+	// +optional
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+	// Verified is set once the pods rolled out and Ghost answered on its Admin API and
+	// spec.verification, it stays false while the rollout is in progress and when it failed
+	Verified bool `json:"verified"`
+	// Failures of the rollout or of the checks after it
+	// +optional
+	Failures []string `json:"failures,omitempty"`
+}
+
+// ChildStatus reports one kind of child resource the operator manages for the Ghost
+type ChildStatus struct {
+	// Kind is the child kind as named in its <Kind>Reconciled condition
+	Kind string `json:"kind"`
+	// Name is the child object, empty when it could not be applied yet
+	// +optional
+	Name string `json:"name,omitempty"`
+	// APIVersion is the API version of the child object, recorded so a child left behind
+	// under a previous name can be pruned
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+	// ResourceKind is the kind of the child object
+	// +optional
+	ResourceKind string `json:"resourceKind,omitempty"`
+	// Ready reports whether the child is ready, children without a readiness of their own
+	// are ready once applied
+	Ready bool `json:"ready"`
+	// LastAppliedHash is a hash of the object last applied
+	// +optional
+	LastAppliedHash string `json:"lastAppliedHash,omitempty"`
+	// LastError is the latest failure to reconcile the child or Warning event about it
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+	// Reason is the machine readable reason of LastError
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// LastErrorTime is when LastError was last seen
+	// +optional
+	LastErrorTime *metav1.Time `json:"lastErrorTime,omitempty"`
+}
+
+// NetworkPolicySpec configures the NetworkPolicy generated for the Ghost pods. Pods in the
+// Ghost's own namespace, such as backup jobs, can always reach Ghost.
+type NetworkPolicySpec struct {
+	Enabled bool `json:"enabled"`
+	// IngressControllerNamespace runs the ingress controller allowed to reach Ghost
+	// +kubebuilder:default=ingress-nginx
+	// +optional
+	IngressControllerNamespace string `json:"ingressControllerNamespace,omitempty"`
+	// AllowedPeers may also reach Ghost, e.g. a monitoring namespace
+	// +optional
+	AllowedPeers []networkingv1.NetworkPolicyPeer `json:"allowedPeers,omitempty"`
+	// Egress restricts outbound traffic to these rules and DNS, e.g. an external database
+	// or SMTP relay. Outbound traffic is unrestricted when empty.
+	// +optional
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
+// PodDisruptionBudgetSpec sets one of the PodDisruptionBudget bounds
+// +kubebuilder:validation:XValidation:rule="!(has(self.minAvailable) && has(self.maxUnavailable))",message="set either minAvailable or maxUnavailable"
+type PodDisruptionBudgetSpec struct {
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// AutoscalingSpec configures the HorizontalPodAutoscaler of the Ghost Deployment
+// +kubebuilder:validation:XValidation:rule="self.maxReplicas >= self.minReplicas",message="maxReplicas must not be below minReplicas"
+// +kubebuilder:validation:XValidation:rule="has(self.targetCPUUtilizationPercentage) || has(self.targetMemoryUtilizationPercentage)",message="at least one utilization target is required"
+type AutoscalingSpec struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilizationPercentage is the average cpu usage relative to the request
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// TargetMemoryUtilizationPercentage is the average memory usage relative to the request
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+}
+
+// EphemeralStorageSpec sizes node local scratch space so one Ghost cannot evict its neighbors
+type EphemeralStorageSpec struct {
+	// Request is the ephemeral-storage request of the Ghost container, it overrides spec.resources
+	// +optional
+	Request *resource.Quantity `json:"request,omitempty"`
+	// Limit is the ephemeral-storage limit of the Ghost container, it overrides spec.resources
+	// +optional
+	Limit *resource.Quantity `json:"limit,omitempty"`
+	// ScratchSizeLimit caps the emptyDir volumes the controller injects, such as the
+	// artifact scratch space of backup and restore jobs
+	// +optional
+	ScratchSizeLimit *resource.Quantity `json:"scratchSizeLimit,omitempty"`
+}
+
+// SecurityProfilesSpec selects the seccomp and AppArmor profiles of the Ghost pod
+type SecurityProfilesSpec struct {
+	// Seccomp is set on the pod security context, RuntimeDefault when unset
+	// +optional
+	Seccomp *corev1.SeccompProfile `json:"seccomp,omitempty"`
+	// AppArmor is set through the per container AppArmor annotations so clusters before
+	// Kubernetes 1.30 enforce it as well, RuntimeDefault when unset
+	// +optional
+	AppArmor *corev1.AppArmorProfile `json:"appArmor,omitempty"`
+}
+
+// UpgradePolicySpec guards image upgrades, Ghost migrates its database on startup
+// +kubebuilder:validation:XValidation:rule="!has(self.migrationJob) || !self.migrationJob || !has(self.strategy) || self.strategy == 'RollingUpdate'",message="migrationJob requires the RollingUpdate strategy"
+type UpgradePolicySpec struct {
+	// SnapshotBeforeUpgrade holds the rollout of a new image until a CSI VolumeSnapshot
+	// of the content volume is ready to use, so a failed migration can be rolled back
+	// +optional
+	SnapshotBeforeUpgrade bool `json:"snapshotBeforeUpgrade,omitempty"`
+	// VolumeSnapshotClassName selects the snapshot class, the cluster default when empty
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// Strategy replaces the pods of a new image in place with RollingUpdate. BlueGreen stands
+	// the new image up next to the running one on a clone of the content volume, and switches
+	// the Service to it once it is ready. The clone needs a CSI driver that clones volumes.
+	// +kubebuilder:default=RollingUpdate
+	// +optional
+	Strategy UpgradeStrategy `json:"strategy,omitempty"`
+	// SmokePath is requested from a pod of the new image before BlueGreen switches to it, the
+	// switch waits for a 2xx answer
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	SmokePath string `json:"smokePath,omitempty"`
+	// MigrationJob scales the running Ghost down and migrates the database to a new image in
+	// a Job rather than on startup, the new image rolls out once the Job succeeded
+	// +optional
+	MigrationJob bool `json:"migrationJob,omitempty"`
+	// AllowMajorSkips lets a new image jump more than one Ghost major version. Ghost only
+	// migrates the database of the previous major, skipping one corrupts the content.
+	// +optional
+	AllowMajorSkips bool `json:"allowMajorSkips,omitempty"`
+}
+
+// UpgradeStrategy selects how a new Ghost image replaces the running one
+// +kubebuilder:validation:Enum=RollingUpdate;BlueGreen
+type UpgradeStrategy string
+
+const (
+	UpgradeStrategyRollingUpdate UpgradeStrategy = "RollingUpdate"
+	UpgradeStrategyBlueGreen     UpgradeStrategy = "BlueGreen"
+)
+
+// MajorVersion returns the Ghost major version an image tag such as 5, 5.96.0 or 5.96.0-alpine
+// runs, false for tags naming no version such as latest or alpine
+func MajorVersion(tag string) (int, bool) {
+	number, _, _ := strings.Cut(tag, "-")
+	number, _, _ = strings.Cut(number, ".")
+	major, err := strconv.Atoi(number)
+	return major, err == nil
+}
+
+// BlueGreenStatus reports the blue/green upgrades of the Ghost
+type BlueGreenStatus struct {
+	// TargetImage is verified in the idle slot before the Service switches to it, empty when
+	// no upgrade is in progress
+	// +optional
+	TargetImage string `json:"targetImage,omitempty"`
+	// LastSwitchTime is when the Service last switched to a new image
+	// +optional
+	LastSwitchTime *metav1.Time `json:"lastSwitchTime,omitempty"`
+}
+
+// ContentPolicy selects the content a new staging instance starts with
+type ContentPolicy string
+
+const (
+	// ContentPolicyEmpty starts staging with a fresh Ghost
+	ContentPolicyEmpty ContentPolicy = "Empty"
+	// ContentPolicyClone copies the production content into staging once, when it is created
+	ContentPolicyClone ContentPolicy = "Clone"
+)
+
+// MonitorKind is the prometheus-operator resource scraping the Ghost
+// +kubebuilder:validation:Enum=ServiceMonitor;PodMonitor
+type MonitorKind string
+
+const (
+	// MonitorKindServiceMonitor scrapes the pods behind the Ghost Service
+	MonitorKindServiceMonitor MonitorKind = "ServiceMonitor"
+	// MonitorKindPodMonitor scrapes the Ghost pods directly
+	MonitorKindPodMonitor MonitorKind = "PodMonitor"
+)
+
+// MonitoringSpec configures how Prometheus discovers the Ghost metrics endpoint
+type MonitoringSpec struct {
+	// Enabled creates a prometheus-operator monitor for the Ghost, on clusters without the
+	// monitoring.coreos.com API the Ghost reports the MonitoringUnavailable condition instead
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Kind of monitor to create
+	// +kubebuilder:default=ServiceMonitor
+	// +optional
+	Kind MonitorKind `json:"kind,omitempty"`
+	// Interval between two scrapes, the Prometheus default when unset
+	// +kubebuilder:validation:Pattern=`^([0-9]+(ms|s|m|h))+$`
+	// +optional
+	Interval string `json:"interval,omitempty"`
+	// Exporter runs a metrics exporter next to Ghost, which serves no Prometheus metrics itself.
+	// The monitor and the annotations then point at the exporter.
+	// +optional
+	Exporter *MetricsExporterSpec `json:"exporter,omitempty"`
+	// Annotations stamps the prometheus.io scrape annotations on the pods and the Service,
+	// for clusters without prometheus-operator that discover targets through them
+	// +optional
+	Annotations bool `json:"annotations,omitempty"`
+	// Port serving the metrics, the Ghost container port when unset
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// Path of the metrics endpoint, /metrics when unset
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Dashboard publishes a Grafana dashboard of the Ghost in a ConfigMap picked up by the
+	// Grafana dashboard sidecar
+	// +optional
+	Dashboard bool `json:"dashboard,omitempty"`
+}
+
+// MetricsExporterSpec is a sidecar container exporting Ghost metrics
+type MetricsExporterSpec struct {
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+	// Port the exporter serves its metrics on
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=9100
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// AccessLogsSpec controls the request logs of a blog. The ingress-nginx access log is toggled through
+// its enable-access-log annotation, other ingress controllers and routing modes keep their own settings.
+type AccessLogsSpec struct {
+	// Enabled logs every request, disable it on high-traffic blogs that flood the logging stack
+	Enabled bool `json:"enabled"`
+}
+
+// LoggingSpec configures the log output of Ghost through its logging__* settings
+type LoggingSpec struct {
+	// Level is the lowest level Ghost logs, it takes precedence over the level spec.accessLogs picks
+	// +kubebuilder:validation:Enum=trace;debug;info;warn;error;fatal
+	// +optional
+	Level string `json:"level,omitempty"`
+	// Format of the log lines Ghost writes to stdout, Ghost's own default when unset
+	// +optional
+	Format LogFormat `json:"format,omitempty"`
+	// Shipper runs a log shipper sidecar forwarding the Ghost logs to a central logging stack
+	// +optional
+	Shipper *LogShipperSpec `json:"shipper,omitempty"`
+}
+
+// LogFormat is how Ghost formats the lines it logs to stdout
+// +kubebuilder:validation:Enum=Text;JSON
+type LogFormat string
+
+const (
+	// LogFormatText logs human readable lines
+	LogFormatText LogFormat = "Text"
+	// LogFormatJSON logs one JSON object per line
+	LogFormatJSON LogFormat = "JSON"
+)
+
+// LogShipperType is the log shipper run next to Ghost
+// +kubebuilder:validation:Enum=FluentBit;Vector
+type LogShipperType string
+
+const (
+	LogShipperFluentBit LogShipperType = "FluentBit"
+	LogShipperVector    LogShipperType = "Vector"
+)
+
+// LogShipperSpec runs fluent-bit or Vector next to Ghost. Ghost additionally writes its logs as
+// JSON files into a volume shared with the shipper, whose path is in the GHOST_LOG_PATH
+// environment variable of the shipper.
+type LogShipperSpec struct {
+	Type LogShipperType `json:"type"`
+	// Image overrides the image the operator runs for the type
+	// +optional
+	Image string `json:"image,omitempty"`
+	// ConfigSecretRef references the Secret configuring the shipper, its fluent-bit.conf key
+	// for FluentBit and its vector.yaml key for Vector. Other keys, such as parsers, are
+	// mounted next to it.
+	ConfigSecretRef corev1.LocalObjectReference `json:"configSecretRef"`
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// StagingSpec maintains a staging Ghost derived from this one in a sibling namespace,
+// the staging Ghost keeps the production name so the two cannot share one.
+// Annotate the Ghost with marketing.kb.dev/promote-staging=<token> to promote staging,
+// the promotion is recorded in status.promotions of both instances.
+// +kubebuilder:validation:XValidation:rule="!has(self.contentPolicy) || self.contentPolicy != 'Clone' || has(self.transfer)",message="the Clone content policy requires transfer"
+type StagingSpec struct {
+	Enabled bool `json:"enabled"`
+	// Namespace of the staging Ghost, <namespace>-staging when empty. A namespace the
+	// controller creates is deleted with the staging instance.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Host serves the staging instance, staging.<production host> when empty
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Image runs a different Ghost image on staging, e.g. to try an upgrade.
+	// Promoting moves it to spec.image of production.
+	// +optional
+	Image *ImageSpec `json:"image,omitempty"`
+	// +kubebuilder:validation:Enum=Empty;Clone
+	// +kubebuilder:default=Empty
+	// +optional
+	ContentPolicy ContentPolicy `json:"contentPolicy,omitempty"`
+	// Transfer is the bucket content is moved through when cloning and promoting, without it
+	// promotion only moves the image. The credentials Secret must exist in both namespaces.
+	// +optional
+	Transfer *BackupDestination `json:"transfer,omitempty"`
+	// PromotionWindow restricts when a promotion restores into production. The staging
+	// backup is taken right away, the restore and image change wait for the window to open.
+	// +optional
+	PromotionWindow *MaintenanceWindow `json:"promotionWindow,omitempty"`
+}
+
+// MaintenanceWindow opens on every tick of the schedule and stays open for the duration
+type MaintenanceWindow struct {
+	// Schedule in cron syntax, e.g. "0 2 * * SAT"
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+	// +kubebuilder:default="1h"
+	// +optional
+	Duration metav1.Duration `json:"duration,omitempty"`
 }
 
+// PromotionRecord is one finished promotion from a staging instance into production
+type PromotionRecord struct {
+	// Token is the value of the promote annotation that started the promotion
+	Token string `json:"token"`
+	// Source is the staging Ghost as <namespace>/<name>
+	Source string `json:"source"`
+	// Target is the production Ghost as <namespace>/<name>
+	Target string `json:"target"`
+	// Backup is the GhostBackup of the staging content, empty when only the image moved
+	// +optional
+	Backup string `json:"backup,omitempty"`
+	// Image moved to production, empty when the image did not change
+	// +optional
+	Image string `json:"image,omitempty"`
+	// CompletionTime is when production was switched over
+	CompletionTime metav1.Time `json:"completionTime"`
+}
+
+// StagingStatus tracks the linked staging instance
+type StagingStatus struct {
+	// Namespace the staging Ghost runs in
+	Namespace string `json:"namespace,omitempty"`
+	// Cloned is set once the production content was copied into staging
+	// +optional
+	Cloned bool `json:"cloned,omitempty"`
+	// Promotion is the token of the promotion in progress
+	// +optional
+	Promotion string `json:"promotion,omitempty"`
+	// PromotedToken is the token of the last finished promotion
+	// +optional
+	PromotedToken string `json:"promotedToken,omitempty"`
+	// PromotionWindowStart is when the promotion in progress may restore into production
+	// +optional
+	PromotionWindowStart *metav1.Time `json:"promotionWindowStart,omitempty"`
+}
+
+// BackupScheduleSpec creates a GhostBackup on every tick of the schedule
+// +kubebuilder:validation:XValidation:rule="self.method != 'Export' || has(self.adminAPIKeySecretRef)",message="the Export method requires adminAPIKeySecretRef"
+// +kubebuilder:validation:XValidation:rule="has(self.schedule) == has(self.destination)",message="schedule and destination are set together"
+// +kubebuilder:validation:XValidation:rule="has(self.schedule) || has(self.velero)",message="schedule or velero is required"
+type BackupScheduleSpec struct {
+	// Schedule in cron syntax, e.g. "0 3 * * *", no GhostBackups are taken when unset
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+	// Retention is the number of finished scheduled backups kept, older GhostBackups are
+	// deleted while their artifacts are left to the bucket's lifecycle rules
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	// +optional
+	Retention int32 `json:"retention,omitempty"`
+	// +kubebuilder:default=Volume
+	// +optional
+	Method BackupMethod `json:"method,omitempty"`
+	// AdminAPIKeySecretRef holds the Admin API key used by the Export method
+	// +optional
+	AdminAPIKeySecretRef *corev1.SecretKeySelector `json:"adminAPIKeySecretRef,omitempty"`
+	// Destination the scheduled GhostBackups are uploaded to, required with schedule
+	// +optional
+	Destination *BackupDestination `json:"destination,omitempty"`
+	// Velero prepares the Ghost for the cluster backups Velero takes
+	// +optional
+	Velero *VeleroBackupSpec `json:"velero,omitempty"`
+}
+
+// VeleroBackupSpec makes the Ghost consistent in Velero backups. The content PVC and the pods
+// are labelled marketing.kb.dev/velero-backup=true for a Velero Backup to select, and the pod
+// template carries the hooks Velero runs in the Ghost container around the backup of the pod.
+type VeleroBackupSpec struct {
+	Enabled bool `json:"enabled"`
+	// PreBackupCommand runs in the Ghost container before its volumes are backed up. Unset,
+	// sync flushes the content volume, SQLite database included, to disk.
+	// +optional
+	PreBackupCommand []string `json:"preBackupCommand,omitempty"`
+	// PostBackupCommand runs in the Ghost container once its volumes are backed up
+	// +optional
+	PostBackupCommand []string `json:"postBackupCommand,omitempty"`
+	// HookTimeout bounds each hook, Velero waits 30s when unset
+	// +optional
+	HookTimeout *metav1.Duration `json:"hookTimeout,omitempty"`
+	// FileSystemBackup opts the content volume into the file system backup of Velero, for
+	// storage without volume snapshot support
+	// +optional
+	FileSystemBackup bool `json:"fileSystemBackup,omitempty"`
+}
+
+// ContentExportSpec runs a CronJob saving the JSON export of the Admin API, and optionally a
+// tarball of the images, into a directory named after the time of the run
+type ContentExportSpec struct {
+	// Schedule in cron syntax, e.g. "0 4 * * *"
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+	// AdminAPIKeySecretRef holds the Admin API key the export is requested with
+	AdminAPIKeySecretRef corev1.SecretKeySelector `json:"adminAPIKeySecretRef"`
+	// IncludeImages adds images.tar.gz, the content/images directory of the content volume
+	// +optional
+	IncludeImages bool `json:"includeImages,omitempty"`
+	// Retention is the number of exports kept on a PVC destination, exports in an object
+	// store are left to the bucket's lifecycle rules
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	// +optional
+	Retention   int32                    `json:"retention,omitempty"`
+	Destination ContentExportDestination `json:"destination"`
+}
+
+// ContentExportDestination is where the exports are stored, a PVC or an object store
+// +kubebuilder:validation:XValidation:rule="has(self.claimName) != has(self.objectStore)",message="exactly one of claimName and objectStore is required"
+type ContentExportDestination struct {
+	// ClaimName is an existing PVC in the namespace of the Ghost
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+	// ObjectStore uploads the exports under <url>/<namespace>/<ghost>/
+	// +optional
+	ObjectStore *BackupDestination `json:"objectStore,omitempty"`
+}
+
+// ProxySpec configures an egress proxy through the conventional environment variables
+type ProxySpec struct {
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	// NoProxy is a comma separated list of hosts and domains reached directly, cluster
+	// internal names are always added
+	// +optional
+	NoProxy string `json:"noProxy,omitempty"`
+}
+
+// PersistenceSpec configures the content volume
+type PersistenceSpec struct {
+	// Enabled false keeps the content in an emptyDir rather than a PVC, for CI previews and demos.
+	// The content is lost whenever a pod is replaced. It cannot be changed once the Ghost is created.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Size of the content volume, 1Gi when unset. Raising it expands the claim when the
+	// StorageClass allows expansion, a smaller size is ignored as volumes cannot shrink.
+	// It limits the emptyDir when persistence is disabled.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+	// StorageClassName provisions the content volume, the cluster default when unset. It cannot
+	// be changed once the Ghost is created since the claim keeps the class it was bound with.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// AccessModes of the content volume, ReadWriteOnce when unset. More than one pod only shares
+	// the volume across nodes through ReadWriteMany storage, such as NFS or CephFS. They cannot be
+	// changed once the Ghost is created.
+	// +kubebuilder:validation:MaxItems=2
+	// +kubebuilder:validation:XValidation:rule="self.all(mode, mode in ['ReadWriteOnce', 'ReadWriteMany', 'ReadWriteOncePod'])",message="access modes must be ReadWriteOnce, ReadWriteMany or ReadWriteOncePod"
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// FixPermissions chowns the content volume to Ghost's node user before it starts,
+	// e.g. after a restore or an fsGroup change left it with the wrong ownership
+	// +optional
+	FixPermissions bool `json:"fixPermissions,omitempty"`
+	// AutoExpand grows the content volume as it fills up, the StorageClass must allow expansion
+	// +optional
+	AutoExpand *AutoExpandSpec `json:"autoExpand,omitempty"`
+}
+
+// AutoExpandSpec grows the content volume by a step whenever its usage crosses a threshold
+type AutoExpandSpec struct {
+	// ThresholdPercent of the volume in use that triggers an expansion
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	// +kubebuilder:default=80
+	// +optional
+	ThresholdPercent int32 `json:"thresholdPercent,omitempty"`
+	// Step added to the volume on every expansion
+	Step resource.Quantity `json:"step"`
+	// MaxSize the volume is never expanded beyond
+	MaxSize resource.Quantity `json:"maxSize"`
+}
+
+// ContentInitSpec copies themes, routes and other content into the content volume
+// +kubebuilder:validation:XValidation:rule="has(self.git) != has(self.archiveURL)",message="exactly one of git or archiveURL must be set"
+type ContentInitSpec struct {
+	// +optional
+	Git *GitContentSource `json:"git,omitempty"`
+	// ArchiveURL points at a .tar.gz archive of the content
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	ArchiveURL string `json:"archiveURL,omitempty"`
+	// SubPath is the directory inside the source copied into the content directory
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
+	// Overwrite replaces files already in the content volume, by default they are kept
+	// +optional
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+// GitContentSource is a git repository cloned at a branch or tag
+type GitContentSource struct {
+	// +kubebuilder:validation:MinLength=1
+	Repository string `json:"repository"`
+	// Ref is the branch or tag to clone, defaults to the remote HEAD
+	// +optional
+	Ref string `json:"ref,omitempty"`
+	// CredentialsSecretRef names a Secret with the password or token of a private repository
+	// under the password key, and optionally the username key. Credentials cannot be part of
+	// the repository URL.
+	// +optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// SchedulerCheckSpec runs a CronJob that checks Ghost is up and publishing scheduled posts on time
+type SchedulerCheckSpec struct {
+	Enabled bool `json:"enabled"`
+	// +kubebuilder:default="*/5 * * * *"
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+	// GraceSeconds a scheduled post may be late before it counts as overdue
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=300
+	// +optional
+	GraceSeconds int32 `json:"graceSeconds,omitempty"`
+	// AdminAPIKeySecretRef holds a Ghost Admin API key, without it only Ghost's health is checked
+	// +optional
+	AdminAPIKeySecretRef *corev1.SecretKeySelector `json:"adminAPIKeySecretRef,omitempty"`
+}
+
+// ProbesSpec overrides the operator's default HTTP probes against the Ghost container
+type ProbesSpec struct {
+	// +optional
+	Liveness *ProbeSpec `json:"liveness,omitempty"`
+	// +optional
+	Readiness *ProbeSpec `json:"readiness,omitempty"`
+	// Startup covers Ghost's slow first boot and migrations before liveness kicks in
+	// +optional
+	Startup *ProbeSpec `json:"startup,omitempty"`
+}
+
+// ProbeSpec tunes one probe, unset fields keep the operator default
+type ProbeSpec struct {
+	// Disabled removes the probe entirely
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// Profile captures the intent of an environment in a single field
+// +kubebuilder:validation:Enum=Development;Staging;Production
+type Profile string
+
+const (
+	ProfileDevelopment Profile = "Development"
+	ProfileStaging     Profile = "Staging"
+	ProfileProduction  Profile = "Production"
+)
+
+// NodeEnvironment is the NODE_ENV of Ghost, which selects its config.<environment>.json
+// +kubebuilder:validation:Enum=development;production
+type NodeEnvironment string
+
+const (
+	NodeEnvironmentDevelopment NodeEnvironment = "development"
+	NodeEnvironmentProduction  NodeEnvironment = "production"
+)
+
+// SpreadPolicy selects how multiple Ghost replicas are spread across the cluster
+// +kubebuilder:validation:Enum=None;PreferredNodes;RequiredNodes;Zones
+type SpreadPolicy string
+
+const (
+	// SpreadPolicyNone leaves placement entirely to the scheduler
+	SpreadPolicyNone SpreadPolicy = "None"
+	// SpreadPolicyPreferredNodes prefers a different node per replica but still schedules when it cannot
+	SpreadPolicyPreferredNodes SpreadPolicy = "PreferredNodes"
+	// SpreadPolicyRequiredNodes refuses to co-locate two replicas on one node
+	SpreadPolicyRequiredNodes SpreadPolicy = "RequiredNodes"
+	// SpreadPolicyZones balances replicas evenly across zones
+	SpreadPolicyZones SpreadPolicy = "Zones"
+)
+
+// SchedulingSpec influences where the Ghost pods are placed
+type SchedulingSpec struct {
+	// SpreadPolicy only applies with more than one replica
+	// +kubebuilder:default=PreferredNodes
+	// +optional
+	SpreadPolicy SpreadPolicy `json:"spreadPolicy,omitempty"`
+}
+
+// ImageSpec selects the Ghost image, e.g. from a private registry mirror
+// +kubebuilder:validation:XValidation:rule="!has(self.architectureTags) || !self.architectureTags || (has(self.architectures) && size(self.architectures) > 0)",message="architectureTags requires architectures"
+// +kubebuilder:validation:XValidation:rule="!has(self.architectureTags) || !self.architectureTags || !has(self.digest)",message="architectureTags cannot be combined with a digest"
+type ImageSpec struct {
+	// Repository defaults to the Docker Hub ghost image
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:default=ghost
+	// +optional
+	Repository string `json:"repository,omitempty"`
+	// Tag overrides spec.imageTag
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// Digest pins the image by content, the tag is ignored when it is set
+	// +kubebuilder:validation:Pattern=`^(sha256:)?[a-f0-9]{64}$`
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	PullPolicy corev1.PullPolicy `json:"pullPolicy,omitempty"`
+	// PullSecrets are added to the pod spec to authenticate against the registry
+	// +optional
+	PullSecrets []corev1.LocalObjectReference `json:"pullSecrets,omitempty"`
+	// VerifySignature holds a new image back until its cosign signature is verified
+	// +optional
+	VerifySignature *SignatureVerificationSpec `json:"verifySignature,omitempty"`
+	// Architectures are the CPU architectures the image runs on, the pods are only scheduled
+	// onto nodes of one of them. Detected from the image index in the registry when unset.
+	// +listType=set
+	// +optional
+	Architectures []Architecture `json:"architectures,omitempty"`
+	// ArchitectureTags is set when the registry publishes a single architecture image per tag,
+	// suffixed with the architecture such as 5.96.0-arm64. The pods run the image of the first
+	// of architectures and are scheduled onto its nodes only.
+	// +optional
+	ArchitectureTags bool `json:"architectureTags,omitempty"`
+}
+
+// Architecture is a CPU architecture as nodes report it in their kubernetes.io/arch label
+// +kubebuilder:validation:Enum=amd64;arm64;arm;ppc64le;s390x
+type Architecture string
+
+const (
+	ArchitectureAMD64 Architecture = "amd64"
+	ArchitectureARM64 Architecture = "arm64"
+)
+
+// SignatureVerificationSpec verifies the cosign signature of an image against the public key
+// it was signed with, or against the identity that signed it keylessly
+// +kubebuilder:validation:XValidation:rule="has(self.publicKeySecretRef) != has(self.keyless)",message="exactly one of publicKeySecretRef and keyless is required"
+type SignatureVerificationSpec struct {
+	// PublicKeySecretRef references the Secret key holding the PEM encoded public key
+	// +optional
+	PublicKeySecretRef *corev1.SecretKeySelector `json:"publicKeySecretRef,omitempty"`
+	// Keyless verifies a signature made with a Fulcio certificate and recorded in Rekor
+	// +optional
+	Keyless *KeylessIdentity `json:"keyless,omitempty"`
+}
+
+// KeylessIdentity is the signer the certificate of a keyless signature must name
+type KeylessIdentity struct {
+	// Identity is the subject of the certificate, e.g. the workflow that built the image
+	// +kubebuilder:validation:MinLength=1
+	Identity string `json:"identity"`
+	// Issuer is the OIDC issuer that authenticated the identity, e.g.
+	// https://token.actions.githubusercontent.com
+	// +kubebuilder:validation:MinLength=1
+	Issuer string `json:"issuer"`
+}
+
+// ServiceSpec configures the Service in front of the Ghost pods
+type ServiceSpec struct {
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:default=NodePort
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=80
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// NodePort pins the allocated node port for NodePort and LoadBalancer services
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+	// SessionAffinity set to ClientIP keeps each client on one pod, e.g. for admin logins
+	// +kubebuilder:validation:Enum=None;ClientIP
+	// +kubebuilder:default=None
+	// +optional
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+	// SessionAffinityTimeoutSeconds bounds how long a ClientIP session sticks, defaults to 3 hours
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	// +optional
+	SessionAffinityTimeoutSeconds *int32 `json:"sessionAffinityTimeoutSeconds,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// ExtraPorts are exposed by the Service next to the Ghost port, e.g. for a metrics sidecar,
+	// and declared on the container serving them
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=10
+	// +optional
+	ExtraPorts []ServicePortSpec `json:"extraPorts,omitempty"`
+}
+
+// ServicePortSpec is an additional port of the Ghost Service
+type ServicePortSpec struct {
+	// Name identifies the port on the Service and the container, the Ghost port is named http
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+	// TargetPort is the container port, defaults to port
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	TargetPort int32 `json:"targetPort,omitempty"`
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +kubebuilder:default=TCP
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+	// ContainerName is the container serving the port, the Ghost container when unset or one
+	// of spec.sidecars
+	// +optional
+	ContainerName string `json:"containerName,omitempty"`
+}
+
+// RoutingMode selects the API used to expose the Ghost instance
+// +kubebuilder:validation:Enum=Ingress;GatewayAPI;Route
+type RoutingMode string
+
+const (
+	RoutingModeIngress    RoutingMode = "Ingress"
+	RoutingModeGatewayAPI RoutingMode = "GatewayAPI"
+	RoutingModeRoute      RoutingMode = "Route"
+)
+
+// RoutingSpec chooses between an Ingress, a Gateway API HTTPRoute and an OpenShift Route
+type RoutingSpec struct {
+	// +kubebuilder:default=Ingress
+	// +optional
+	Mode RoutingMode `json:"mode,omitempty"`
+	// Gateway the HTTPRoute attaches to, required in GatewayAPI mode
+	// +optional
+	Gateway *GatewayReference `json:"gateway,omitempty"`
+}
+
+// ExposureMode selects how the Ghost instance is reached from outside the cluster
+// +kubebuilder:validation:Enum=Default;PortForwardOnly
+type ExposureMode string
+
+const (
+	// ExposureModeDefault exposes the Ghost through its Service type and, with enableIngress, its routing
+	ExposureModeDefault ExposureMode = "Default"
+	// ExposureModePortForwardOnly keeps the Ghost on a ClusterIP Service without any route, it is
+	// reached through kubectl ghost open with a port-forward token of the operator managed ServiceAccount
+	ExposureModePortForwardOnly ExposureMode = "PortForwardOnly"
+)
+
+// ExposureSpec configures how the Ghost instance is reached, e.g. on dev clusters without ingress
+type ExposureSpec struct {
+	// +kubebuilder:default=Default
+	// +optional
+	Mode ExposureMode `json:"mode,omitempty"`
+}
+
+// GatewayReference points at the Gateway an HTTPRoute attaches to
+type GatewayReference struct {
+	Name string `json:"name"`
+	// Namespace of the Gateway, defaults to the Ghost namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// SectionName selects a listener on the Gateway
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// IngressSpec configures how the Ghost instance is exposed through an Ingress
+type IngressSpec struct {
+	// Host is the primary hostname, defaults to <name>.<base domain>, where the base domain
+	// is kb.dev unless the namespace sets the marketing.kb.dev/base-domain annotation
+	// +kubebuilder:validation:Pattern=`^(\*\.)?[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Host string `json:"host,omitempty"`
+	// ExtraHosts are additional hostnames routed to the same Service
+	// +optional
+	ExtraHosts []string `json:"extraHosts,omitempty"`
+	// Path serves Ghost under a sub-path of its hosts, e.g. /blog, leaving the rest of the hosts
+	// to other Ingresses. Ghost's url includes it, so Ghost serves the sub-path itself and
+	// requests are forwarded without rewriting.
+	// +kubebuilder:validation:Pattern=`^(/[-a-zA-Z0-9._~]+)+$`
+	// +optional
+	Path string `json:"path,omitempty"`
+	// +optional
+	TLS *IngressTLSSpec `json:"tls,omitempty"`
+	// ClassName selects the ingress controller, defaults to nginx
+	// +optional
+	ClassName string `json:"className,omitempty"`
+	// Annotations are added to the Ingress for controller-specific settings
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Redirects permanently redirect other hosts to a canonical host of the Ghost, e.g. the apex
+	// domain to www. Each gets an Ingress of its own, which requires ingress-nginx.
+	// +listType=map
+	// +listMapKey=from
+	// +optional
+	Redirects []IngressRedirect `json:"redirects,omitempty"`
+	// ExternalDNS has external-dns publish the records of the hosts
+	// +optional
+	ExternalDNS *ExternalDNSSpec `json:"externalDNS,omitempty"`
+}
+
+// ExternalDNSSpec stamps the Ingress, or the LoadBalancer Service of a Ghost without one, with the
+// external-dns annotations publishing its hosts
+type ExternalDNSSpec struct {
+	Enabled bool `json:"enabled"`
+	// TTL of the records in seconds, the default of the external-dns provider when unset
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TTL *int32 `json:"ttl,omitempty"`
+	// Annotations are further external-dns annotations, e.g. external-dns.alpha.kubernetes.io/target
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// IngressRedirect permanently redirects a host to a canonical host, keeping the request path
+type IngressRedirect struct {
+	// From is the host redirected away from
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	From string `json:"from"`
+	// To is the canonical host, the primary host or one of extraHosts. Defaults to the primary host.
+	// +optional
+	To string `json:"to,omitempty"`
+}
+
+// IngressTLSSpec configures TLS termination on the Ingress
+type IngressTLSSpec struct {
+	Enabled bool `json:"enabled"`
+	// SecretName holds the certificate, defaults to ghost-tls-<namespace>
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// IssuerRef makes the controller request the certificate from cert-manager,
+	// otherwise SecretName must reference an existing Secret
+	// +optional
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+}
+
+// IssuerReference points at a cert-manager Issuer or ClusterIssuer
+type IssuerReference struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +kubebuilder:default=Issuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// +kubebuilder:default=cert-manager.io
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// MailSpec configures the transport Ghost uses to send invites, magic links and newsletters
+type MailSpec struct {
+	// +kubebuilder:default=SMTP
+	// +optional
+	Transport string `json:"transport,omitempty"`
+	Host      string `json:"host"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=587
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// +optional
+	User string `json:"user,omitempty"`
+	// PasswordSecretRef selects the key of a Secret holding the SMTP password
+	// +optional
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// +optional
+	From string `json:"from,omitempty"`
+}
+
+// DatabaseClient is the database Ghost stores its content in
+// +kubebuilder:validation:Enum=sqlite3;mysql
+type DatabaseClient string
+
+const (
+	// DatabaseClientSQLite keeps the content in a SQLite file on the content volume
+	DatabaseClientSQLite DatabaseClient = "sqlite3"
+	// DatabaseClientMySQL keeps the content on the MySQL server of spec.database.mysql
+	DatabaseClientMySQL DatabaseClient = "mysql"
+)
+
+// DatabaseSpec selects the database of the Ghost
+// +kubebuilder:validation:XValidation:rule="!has(self.client) || self.client != 'mysql' || has(self.mysql)",message="the mysql client needs spec.database.mysql"
+type DatabaseSpec struct {
+	// Client is sqlite3 for the SQLite file of the content volume or mysql for the server of
+	// spec.database.mysql
+	// +kubebuilder:default=sqlite3
+	// +optional
+	Client DatabaseClient `json:"client,omitempty"`
+	// MySQL is the server Ghost connects to with the mysql client
+	// +optional
+	MySQL *MySQLSpec `json:"mysql,omitempty"`
+	// Migration copies the content of the SQLite file into MySQL when the client changes from
+	// sqlite3 to mysql. Without it Ghost starts on an empty MySQL database.
+	// +optional
+	Migration *DatabaseMigrationSpec `json:"migration,omitempty"`
+}
+
+// MySQLSpec is the MySQL server and database Ghost connects to
+type MySQLSpec struct {
+	Host string `json:"host"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=3306
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// Database is the schema Ghost keeps its tables in
+	Database string `json:"database"`
+	User     string `json:"user"`
+	// PasswordSecretRef selects the key of a Secret holding the password of the user
+	PasswordSecretRef corev1.SecretKeySelector `json:"passwordSecretRef"`
+}
+
+// DatabaseMigrationSpec opts into moving the content of the SQLite file to MySQL
+type DatabaseMigrationSpec struct {
+	// Enabled scales Ghost down once spec.database.client changes from sqlite3 to mysql, copies
+	// the content of the SQLite file into MySQL in a Job and starts Ghost on MySQL once the Job
+	// succeeded. The SQLite file is kept.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// RemoveSQLite deletes the SQLite file kept by the migration, set it once the content on
+	// MySQL was checked
+	// +optional
+	RemoveSQLite bool `json:"removeSQLite,omitempty"`
+}
+
+// StoppedBehavior selects what the hosts of a stopped Ghost serve
+// +kubebuilder:validation:Enum=MaintenancePage;RemoveIngress
+type StoppedBehavior string
+
+const (
+	StoppedBehaviorMaintenancePage StoppedBehavior = "MaintenancePage"
+	StoppedBehaviorRemoveIngress   StoppedBehavior = "RemoveIngress"
+)
+
+// ImageTagPolicy selects whether the operator moves the image tag to newer releases
+// +kubebuilder:validation:Enum=Pinned;TrackPatch;TrackMinor
+type ImageTagPolicy string
+
+const (
+	// ImageTagPolicyPinned runs the tag as it is
+	ImageTagPolicyPinned ImageTagPolicy = "Pinned"
+	// ImageTagPolicyTrackPatch runs the newest patch release of the tag's minor version
+	ImageTagPolicyTrackPatch ImageTagPolicy = "TrackPatch"
+	// ImageTagPolicyTrackMinor runs the newest release of the tag's major version
+	ImageTagPolicyTrackMinor ImageTagPolicy = "TrackMinor"
+)
+
+// GhostPhase is a coarse summary of the Ghost conditions
+type GhostPhase string
+
+const (
+	// GhostPhaseProvisioning is set until the children are ready and every replica is serving
+	GhostPhaseProvisioning GhostPhase = "Provisioning"
+	// GhostPhaseReady is set while the children are ready and every replica is serving
+	GhostPhaseReady GhostPhase = "Ready"
+	// GhostPhaseDegraded is set when a Ghost that was ready lost replicas or cannot be served
+	GhostPhaseDegraded GhostPhase = "Degraded"
+	// GhostPhaseStopped is set while spec.replicas is zero
+	GhostPhaseStopped GhostPhase = "Stopped"
+	// GhostPhaseDeleting is set once the Ghost is being deleted
+	GhostPhaseDeleting GhostPhase = "Deleting"
+)
+
 // GhostStatus defines the observed state of Ghost
 type GhostStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the generation last fully reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastFullReconcileTime is when the Ghost was last reconciled in full while a resync period applies to it
+	// +optional
+	LastFullReconcileTime *metav1.Time `json:"lastFullReconcileTime,omitempty"`
+	// Phase summarises the conditions for dashboards and GitOps health checks
+	// +optional
+	Phase GhostPhase `json:"phase,omitempty"`
+	// Replicas is the replica count of the Ghost Deployment, as scaled by the autoscaler if any
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// ReadyReplicas mirrors the ready replicas of the Ghost Deployment
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// Image is the Ghost image the Deployment runs
+	// +optional
+	Image string `json:"image,omitempty"`
+	// ImageArchitectures are the architectures the registry publishes the Ghost image for, the
+	// pods are scheduled onto nodes of one of them unless spec.image.architectures is set
+	// +optional
+	ImageArchitectures []string `json:"imageArchitectures,omitempty"`
+	// ResolvedImage is the newest release spec.imageTagPolicy found in the registry, which the
+	// Deployment rolls out
+	// +optional
+	ResolvedImage string `json:"resolvedImage,omitempty"`
+	// ConfigChecksum hashes the Secrets and ConfigMaps the pods read at startup, the pods roll
+	// when it changes
+	// +optional
+	ConfigChecksum string `json:"configChecksum,omitempty"`
+	// GhostVersion is the version the running Ghost reports through its Admin API
+	// +optional
+	GhostVersion string `json:"ghostVersion,omitempty"`
+	// URL is the public address the Ghost is served at, spec.url when set and empty when it is not exposed
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Addresses are where the load balancer of the Ingress or Service, or the node ports of the
+	// Service, expose the Ghost outside the cluster
+	// +optional
+	Addresses []GhostAddress `json:"addresses,omitempty"`
+	// PendingChanges describes the changes to the children spec.reconcileMode DryRun holds back,
+	// one line per child
+	// +optional
+	PendingChanges []string `json:"pendingChanges,omitempty"`
+	// DesiredHash is a hash of the child resources rendered for ObservedGeneration
+	// +optional
+	DesiredHash string `json:"desiredHash,omitempty"`
+	// ContentVolumeNodeAffinity is the node affinity of the volume bound to the content PVC,
+	// the Ghost pods are pinned to it so they are never scheduled where it cannot attach
+	// +optional
+	ContentVolumeNodeAffinity *corev1.NodeSelector `json:"contentVolumeNodeAffinity,omitempty"`
+	// LastBackupTime is the schedule time of the latest scheduled backup
+	// +optional
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+	// LastBackupName is the latest GhostBackup created by spec.backup
+	// +optional
+	LastBackupName string `json:"lastBackupName,omitempty"`
+	// PreUpgradeSnapshot is the VolumeSnapshot taken of the content volume before the latest image upgrade
+	// +optional
+	PreUpgradeSnapshot string `json:"preUpgradeSnapshot,omitempty"`
+	// Staging reports the linked staging instance
+	// +optional
+	Staging *StagingStatus `json:"staging,omitempty"`
+	// Promotions lists the latest promotions this Ghost took part in, as staging or production
+	// +optional
+	Promotions []PromotionRecord `json:"promotions,omitempty"`
+	// Owner reports the expiry review of spec.owner
+	// +optional
+	Owner *OwnerStatus `json:"owner,omitempty"`
+	// Storage reports the usage of the content volume while a Ghost pod mounts it
+	// +optional
+	Storage *StorageStatus `json:"storage,omitempty"`
+	// AdminBootstrap is set once the initial setup of Ghost is complete
+	// +optional
+	AdminBootstrap *AdminBootstrapStatus `json:"adminBootstrap,omitempty"`
+	// SiteConfig records the spec.routes and spec.redirects last uploaded to Ghost
+	// +optional
+	SiteConfig *SiteConfigStatus `json:"siteConfig,omitempty"`
+	// Headless reports the build hook registered for spec.headless
+	// +optional
+	Headless *HeadlessStatus `json:"headless,omitempty"`
+	// Events reports the webhooks registered for spec.events
+	// +optional
+	Events *EventsStatus `json:"events,omitempty"`
+	// LastPublishedAt is when the event receiver was last told a post or page was published
+	// +optional
+	LastPublishedAt *metav1.Time `json:"lastPublishedAt,omitempty"`
+	// BlueGreen reports the upgrades of spec.upgradePolicy.strategy BlueGreen, the Service
+	// selects the pods of the active slot once it is set
+	// +optional
+	BlueGreen *BlueGreenStatus `json:"blueGreen,omitempty"`
+	// LastRollout is the latest rollout of the Ghost Deployment, release automation waits for
+	// its verified flag
+	// +optional
+	LastRollout *RolloutStatus `json:"lastRollout,omitempty"`
+	// VerifiedHash is the DesiredHash of the children that last passed spec.verification
+	// +optional
+	VerifiedHash string `json:"verifiedHash,omitempty"`
+	// LastTermination is the latest termination of the Ghost container in any of its pods, kept
+	// after the pod is gone
+	// +optional
+	LastTermination *TerminationStatus `json:"lastTermination,omitempty"`
+	// Children is the inventory of the child resources the operator manages for the Ghost
+	// +optional
+	// +listType=map
+	// +listMapKey=kind
+	Children []ChildStatus `json:"children,omitempty"`
+}
+
+// ReconcileMode is whether the controller applies the children of a Ghost
+// +kubebuilder:validation:Enum=Apply;DryRun
+type ReconcileMode string
+
+const (
+	ReconcileModeApply  ReconcileMode = "Apply"
+	ReconcileModeDryRun ReconcileMode = "DryRun"
+)
+
+// GhostAddressType is the kind of address a Ghost is reachable at
+// +kubebuilder:validation:Enum=IPAddress;Hostname;NodePort
+type GhostAddressType string
+
+const (
+	GhostAddressTypeIPAddress GhostAddressType = "IPAddress"
+	GhostAddressTypeHostname  GhostAddressType = "Hostname"
+	GhostAddressTypeNodePort  GhostAddressType = "NodePort"
+)
+
+// GhostAddress is an address the Ghost is reachable at from outside the cluster
+type GhostAddress struct {
+	// Type is IPAddress or Hostname for a load balancer, NodePort for the port every node forwards
+	Type GhostAddressType `json:"type"`
+	// Value is the IP, the hostname or the port number
+	Value string `json:"value"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
-// +kubebuilder:printcolumn:name="EnableIngress",type=boolean,JSONPath=`.spec.enableIngress`
-// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.spec.replicas`
-// +kubebuilder:printcolumn:name="ImageTag",type=string,JSONPath=`.spec.imageTag`
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.replicas`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.image`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.ghostVersion`,priority=1
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:printcolumn:name="EnableIngress",type=boolean,JSONPath=`.spec.enableIngress`,priority=1
+// +kubebuilder:printcolumn:name="Team",type=string,JSONPath=`.spec.owner.team`,priority=1
+// +kubebuilder:printcolumn:name="Review",type=string,JSONPath=`.spec.owner.expiryReview`,priority=1
+// +kubebuilder:printcolumn:name="ReviewOverdue",type=boolean,JSONPath=`.status.owner.reviewOverdue`,priority=1
 
 // Ghost is the Schema for the ghosts API
 type Ghost struct {
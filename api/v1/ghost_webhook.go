@@ -17,8 +17,26 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -27,8 +45,21 @@ import (
 // log is for logging in this package.
 var ghostlog = logf.Log.WithName("ghost-resource")
 
+// BaseDomainAnnotation on a Ghost holds the domain its default host is under, which the controller
+// mirrors from the annotation of the same name on its namespace
+const BaseDomainAnnotation = "marketing.kb.dev/base-domain"
+
+// DefaultBaseDomain is the domain of the default host of a Ghost without BaseDomainAnnotation
+const DefaultBaseDomain = "kb.dev"
+
+// hostReader lists the Ghosts of every namespace, hosts are not checked for uniqueness when nil.
+// It reads the API server rather than the manager's cache, which a restricted install limits to the
+// namespaces it watches while hosts are unique across the cluster.
+var hostReader client.Reader
+
 // SetupWebhookWithManager will setup the manager to manage the webhooks
 func (r *Ghost) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	hostReader = mgr.GetAPIReader()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -40,13 +71,55 @@ func (r *Ghost) SetupWebhookWithManager(mgr ctrl.Manager) error {
 
 var _ webhook.Defaulter = &Ghost{}
 
-// Default implements webhook.Defaulter so a webhook will be registered for the type
+// DefaultImageTag is the Ghost release a Ghost without any image tag runs, pinned to a minor
+// version so new blogs do not pick up the next major release unnoticed
+var DefaultImageTag = "5.96"
+
+// SharedStorageClassName is the ReadWriteMany StorageClass new Ghosts running more than one pod
+// are provisioned with when they set neither a class nor access modes, none when empty
+var SharedStorageClassName = ""
+
+// defaultPersistenceSize matches the size the content volume template requests
+var defaultPersistenceSize = resource.MustParse("1Gi")
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type. It records
+// the defaults in the spec so a minimal manifest ends up fully populated in the API server.
 func (r *Ghost) Default() {
 	ghostlog.Info("default", "name", r.Name)
 
-	// TODO(user): fill in your defaulting logic.
-	if r.Spec.ImageTag == "" {
-		r.Spec.ImageTag = "latest"
+	if r.Spec.ImageTag == "" && (r.Spec.Image == nil || (r.Spec.Image.Tag == "" && r.Spec.Image.Digest == "")) {
+		// The tag grammar of spec.imageTag has no room for a version, spec.image.tag does
+		if r.Spec.Image == nil {
+			r.Spec.Image = &ImageSpec{}
+		}
+		if r.Spec.Image.Repository == "" {
+			r.Spec.Image.Repository = "ghost"
+		}
+		r.Spec.Image.Tag = DefaultImageTag
+	}
+	if r.Spec.Persistence == nil {
+		r.Spec.Persistence = &PersistenceSpec{}
+	}
+	if r.Spec.Persistence.Size == nil {
+		size := defaultPersistenceSize.DeepCopy()
+		r.Spec.Persistence.Size = &size
+	}
+	r.Labels = syncTagLabels(r.Labels, r.Spec.Tags)
+	// Ghosts created before the Service type was defaulted keep the NodePort Service they got.
+	// The API server only sets the creation timestamp after the mutating webhooks of a create.
+	if r.CreationTimestamp.IsZero() {
+		if r.Spec.Service == nil {
+			r.Spec.Service = &ServiceSpec{}
+		}
+		if r.Spec.Service.Type == "" {
+			r.Spec.Service.Type = corev1.ServiceTypeClusterIP
+		}
+		// The pods of a scaled out Ghost attach the content volume on several nodes
+		persistence := r.Spec.Persistence
+		if SharedStorageClassName != "" && r.maxPods() > 1 && !r.ephemeral() && r.workloadKind() == WorkloadKindDeployment && persistence.StorageClassName == nil && len(persistence.AccessModes) == 0 {
+			persistence.StorageClassName = ptr.To(SharedStorageClassName)
+			persistence.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+		}
 	}
 }
 
@@ -61,16 +134,18 @@ var _ webhook.Validator = &Ghost{}
 func (r *Ghost) ValidateCreate() (admission.Warnings, error) {
 	ghostlog.Info("validate create", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object creation.
-	return nil, nil
+	warnings, allErrs := r.validateSpec(nil)
+	allErrs = append(allErrs, r.validateHostsUnique(nil)...)
+	return warnings, r.invalid(append(allErrs, r.validateRename(nil)...))
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *Ghost) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
 	ghostlog.Info("validate update", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object update.
-	return nil, nil
+	warnings, allErrs := r.validateSpec(old.(*Ghost))
+	allErrs = append(allErrs, r.validateHostsUnique(old.(*Ghost))...)
+	return warnings, r.invalid(append(allErrs, r.validateRename(old.(*Ghost))...))
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -80,3 +155,588 @@ func (r *Ghost) ValidateDelete() (admission.Warnings, error) {
 	// TODO(user): fill in your validation logic upon object deletion.
 	return nil, nil
 }
+
+// Annotations of the rename flow, a Ghost created with RenamedFromAnnotation replaces the Ghost of
+// its namespace with that name and takes over its content PVC, pinned in ContentClaimAnnotation
+const (
+	RenamedFromAnnotation  = "marketing.kb.dev/renamed-from"
+	ContentClaimAnnotation = "marketing.kb.dev/content-claim"
+)
+
+// validateRename rejects a Ghost renamed from itself and changes to a rename in progress or
+// to the content PVC taken over, which would point the Ghost at the content of another one
+func (r *Ghost) validateRename(old *Ghost) field.ErrorList {
+	var allErrs field.ErrorList
+	annotations := field.NewPath("metadata", "annotations")
+	if r.Annotations[RenamedFromAnnotation] == r.Name {
+		allErrs = append(allErrs, field.Invalid(annotations.Key(RenamedFromAnnotation), r.Name, "a Ghost cannot be renamed from itself"))
+	}
+	if old != nil {
+		for _, key := range []string{RenamedFromAnnotation, ContentClaimAnnotation} {
+			previous, ok := old.Annotations[key]
+			if ok && previous != "" && r.Annotations[key] != previous {
+				allErrs = append(allErrs, field.Forbidden(annotations.Key(key), "cannot be changed once set"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// imageTagPattern is the tag grammar of the OCI distribution spec
+var imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// validateSpec rejects the specs that would only fail deep inside the reconcile, such as an image
+// that cannot be referenced, hosts no Ingress accepts or changes to fields the children cannot follow
+func (r *Ghost) validateSpec(old *Ghost) (admission.Warnings, field.ErrorList) {
+	// Metadata updates such as releasing a finalizer must go through for Ghosts admitted before
+	if old != nil && equality.Semantic.DeepEqual(r.Spec, old.Spec) {
+		return nil, nil
+	}
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+	spec := field.NewPath("spec")
+
+	pinned := r.Spec.Image != nil && (r.Spec.Image.Tag != "" || r.Spec.Image.Digest != "")
+	switch {
+	case r.Spec.ImageTag == "" && !pinned:
+		allErrs = append(allErrs, field.Required(spec.Child("imageTag"), "an image tag, image.tag or image.digest is required"))
+	case r.Spec.ImageTag != "" && !imageTagPattern.MatchString(r.Spec.ImageTag):
+		allErrs = append(allErrs, field.Invalid(spec.Child("imageTag"), r.Spec.ImageTag, "must start with a letter, digit or underscore"))
+	}
+	if policy := r.Spec.ImageTagPolicy; policy == ImageTagPolicyTrackPatch || policy == ImageTagPolicyTrackMinor {
+		tag := r.imageTag()
+		number, _, _ := strings.Cut(tag, "-")
+		_, version := MajorVersion(tag)
+		switch {
+		case tag == "":
+			allErrs = append(allErrs, field.Forbidden(spec.Child("imageTagPolicy"), "an image pinned by digest cannot track releases"))
+		case !version || (policy == ImageTagPolicyTrackPatch && !strings.Contains(number, ".")):
+			allErrs = append(allErrs, field.Invalid(r.imageTagPath(spec), tag, fmt.Sprintf("must name the release %s starts from, such as 5.96.0", policy)))
+		}
+	}
+	if r.Spec.Timezone != "" {
+		// The Ghost image ships the time zone database Node resolves TZ with
+		if _, err := time.LoadLocation(r.Spec.Timezone); err != nil || r.Spec.Timezone == "Local" {
+			allErrs = append(allErrs, field.Invalid(spec.Child("timezone"), r.Spec.Timezone, "must be an IANA time zone such as Europe/Berlin"))
+		}
+	}
+	if sleep := r.Spec.PreStopSleepSeconds; sleep != nil {
+		grace := int64(corev1.DefaultTerminationGracePeriodSeconds)
+		if r.Spec.TerminationGracePeriodSeconds != nil {
+			grace = *r.Spec.TerminationGracePeriodSeconds
+		}
+		if int64(*sleep) >= grace {
+			allErrs = append(allErrs, field.Invalid(spec.Child("preStopSleepSeconds"), *sleep,
+				fmt.Sprintf("must be below the termination grace period of %d seconds, Ghost needs time to stop after the sleep", grace)))
+		}
+	}
+	if r.Spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(spec.Child("replicas"), r.Spec.Replicas, "must not be negative"))
+	}
+
+	if r.Spec.Exposure != nil && r.Spec.Exposure.Mode == ExposureModePortForwardOnly {
+		if r.Spec.EnableIngress {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("enableIngress"), "a Ghost in PortForwardOnly exposure mode is not routed"))
+		}
+		if r.Spec.Service != nil && r.Spec.Service.NodePort != 0 {
+			warnings = append(warnings, fmt.Sprintf("%s is ignored in PortForwardOnly exposure mode, the Service is a ClusterIP", spec.Child("service", "nodePort")))
+		}
+	}
+
+	if service := r.Spec.Service; service != nil {
+		for i, port := range service.ExtraPorts {
+			portPath := spec.Child("service", "extraPorts").Index(i)
+			if port.Name == "http" {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("name"), port.Name, "names the Ghost port"))
+			}
+			if port.Port == service.Port || (service.Port == 0 && port.Port == 80) {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("port"), port.Port, "is the Ghost port"))
+			}
+			sidecar := slices.ContainsFunc(r.Spec.Sidecars, func(c corev1.Container) bool { return c.Name == port.ContainerName })
+			if port.ContainerName != "" && port.ContainerName != "ghost" && !sidecar {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("containerName"), port.ContainerName, "must be ghost or one of spec.sidecars"))
+			}
+		}
+	}
+
+	// Credentials are only read from Secrets, so they stay out of the spec, its exports and
+	// GitOps repositories. Values admitted before are kept until they change.
+	admitted := map[string]string{}
+	if old != nil {
+		for _, credential := range old.plaintextCredentials(spec) {
+			admitted[credential.path.String()] = credential.value
+		}
+	}
+	for _, credential := range r.plaintextCredentials(spec) {
+		if value, ok := admitted[credential.path.String()]; ok && value == credential.value {
+			continue
+		}
+		allErrs = append(allErrs, field.Forbidden(credential.path, credential.detail))
+	}
+
+	if r.workloadKind() == WorkloadKindStatefulSet {
+		// Every replica of a StatefulSet gets a content volume, and with it a database, of its own
+		if r.Spec.Replicas > 1 {
+			allErrs = append(allErrs, field.Invalid(spec.Child("replicas"), r.Spec.Replicas, "a StatefulSet runs at most one replica"))
+		}
+		if r.Spec.Autoscaling != nil {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("autoscaling"), "a StatefulSet runs at most one replica"))
+		}
+		if policy := r.Spec.UpgradePolicy; policy != nil {
+			if policy.Strategy == UpgradeStrategyBlueGreen {
+				allErrs = append(allErrs, field.Forbidden(spec.Child("upgradePolicy", "strategy"), "BlueGreen needs the Deployment workload"))
+			}
+			if policy.MigrationJob {
+				allErrs = append(allErrs, field.Forbidden(spec.Child("upgradePolicy", "migrationJob"), "the migration Job needs the Deployment workload"))
+			}
+		}
+		if r.Annotations[RenamedFromAnnotation] != "" {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("metadata", "annotations").Key(RenamedFromAnnotation), "a StatefulSet cannot take over the content volume of another Ghost"))
+		}
+		if database := r.Spec.Database; database != nil && database.Migration != nil && database.Migration.Enabled {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("database", "migration", "enabled"), "the SQLite migration needs the Deployment workload"))
+		}
+	}
+
+	if database := r.Spec.Database; database != nil && database.Client == DatabaseClientMySQL && database.MySQL == nil {
+		allErrs = append(allErrs, field.Required(spec.Child("database", "mysql"), "the mysql client needs a MySQL server"))
+	}
+
+	if ingress := r.Spec.Ingress; ingress != nil {
+		path := spec.Child("ingress")
+		if ingress.Host != "" {
+			allErrs = append(allErrs, validateHost(path.Child("host"), ingress.Host)...)
+		}
+		seen := map[string]bool{r.ServedHosts()[0]: true}
+		for i, host := range ingress.ExtraHosts {
+			hostPath := path.Child("extraHosts").Index(i)
+			if seen[host] {
+				allErrs = append(allErrs, field.Duplicate(hostPath, host))
+				continue
+			}
+			seen[host] = true
+			allErrs = append(allErrs, validateHost(hostPath, host)...)
+		}
+		if len(ingress.Redirects) > 0 && r.Spec.Routing != nil && r.Spec.Routing.Mode != "" && r.Spec.Routing.Mode != RoutingModeIngress {
+			allErrs = append(allErrs, field.Forbidden(path.Child("redirects"), "redirects are served by ingress-nginx and need the Ingress routing mode"))
+		}
+		for i, redirect := range ingress.Redirects {
+			redirectPath := path.Child("redirects").Index(i)
+			if seen[redirect.From] {
+				allErrs = append(allErrs, field.Invalid(redirectPath.Child("from"), redirect.From, "is served by the Ghost itself"))
+			}
+			if redirect.To != "" && !slices.Contains(r.ServedHosts(), redirect.To) {
+				allErrs = append(allErrs, field.Invalid(redirectPath.Child("to"), redirect.To, "must be spec.ingress.host, the default host when it is unset or one of spec.ingress.extraHosts"))
+			}
+		}
+		// Ghost serves the path of its url, which then has to be the one routed to it
+		if ingress.Path != "" && r.Spec.URL != "" {
+			if parsed, err := url.Parse(r.Spec.URL); err == nil && strings.TrimSuffix(parsed.Path, "/") != ingress.Path {
+				allErrs = append(allErrs, field.Invalid(spec.Child("url"), r.Spec.URL, "must have the path of spec.ingress.path"))
+			}
+		}
+	}
+
+	for key, value := range r.Spec.Tags {
+		tagPath := spec.Child("tags").Key(key)
+		for _, msg := range validation.IsQualifiedName(TagLabelPrefix + key) {
+			allErrs = append(allErrs, field.Invalid(tagPath, key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			allErrs = append(allErrs, field.Invalid(tagPath, value, msg))
+		}
+	}
+
+	if r.ephemeral() {
+		persistence := spec.Child("persistence")
+		warnings = append(warnings, fmt.Sprintf("%s is false, the content is lost whenever a pod is replaced", persistence.Child("enabled")))
+		if r.workloadKind() == WorkloadKindStatefulSet {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("workload", "kind"), "a StatefulSet keeps its content on a volume, it needs persistence"))
+		}
+		if r.Spec.Persistence.AutoExpand != nil {
+			allErrs = append(allErrs, field.Forbidden(persistence.Child("autoExpand"), "there is no volume to expand without persistence"))
+		}
+		if policy := r.Spec.UpgradePolicy; policy != nil && policy.SnapshotBeforeUpgrade {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("upgradePolicy", "snapshotBeforeUpgrade"), "there is no volume to snapshot without persistence"))
+		}
+		if policy := r.Spec.UpgradePolicy; policy != nil && policy.Strategy == UpgradeStrategyBlueGreen {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("upgradePolicy", "strategy"), "BlueGreen clones the content volume, it needs persistence"))
+		}
+		if backup := r.Spec.Backup; backup != nil && backup.Schedule != "" && backup.Method != BackupMethodExport {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("backup", "method"), "only Export backs up a Ghost without persistence, there is no volume to archive"))
+		}
+		if export := r.Spec.Export; export != nil && export.IncludeImages {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("export", "includeImages"), "there is no volume to archive the images of without persistence"))
+		}
+		if r.maxPods() > 1 {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("replicas"), "each pod would keep its own content without persistence, run a single pod"))
+		}
+	}
+
+	if r.Spec.Persistence != nil && r.Spec.Persistence.AutoExpand != nil {
+		autoExpand := r.Spec.Persistence.AutoExpand
+		autoExpandPath := spec.Child("persistence", "autoExpand")
+		if autoExpand.Step.Sign() <= 0 {
+			allErrs = append(allErrs, field.Invalid(autoExpandPath.Child("step"), autoExpand.Step.String(), "must be positive"))
+		}
+		if size := persistenceSize(r); size != nil && autoExpand.MaxSize.Cmp(*size) < 0 {
+			allErrs = append(allErrs, field.Invalid(autoExpandPath.Child("maxSize"), autoExpand.MaxSize.String(), "must not be below spec.persistence.size"))
+		}
+	}
+
+	// A ReadWriteOnce volume attaches to a single node, the pods scheduled elsewhere never start.
+	// Ghosts already running several pods keep them, scaling up further is denied.
+	if pods := r.maxPods(); pods > 1 && !r.ephemeral() && !r.sharedStorage() && r.workloadKind() == WorkloadKindDeployment && (old == nil || old.maxPods() < pods) {
+		path := spec.Child("replicas")
+		if r.Spec.Autoscaling != nil {
+			path = spec.Child("autoscaling", "maxReplicas")
+		}
+		allErrs = append(allErrs, field.Forbidden(path, "more than one pod needs ReadWriteMany in spec.persistence.accessModes, "+
+			"a ReadWriteOnce content volume only attaches to a single node"))
+	}
+
+	// Every pod writes the SQLite database of the shared volume, MySQL is needed to scale out safely
+	if pods := r.maxPods(); pods > 1 && !r.ephemeral() && r.sharedStorage() && r.workloadKind() == WorkloadKindDeployment && r.sqlite() {
+		warnings = append(warnings, fmt.Sprintf("%d pods write the SQLite database on the shared content volume, concurrent writes can corrupt it, "+
+			"set %s to mysql", pods, spec.Child("database", "client")))
+	}
+
+	// Only a ConfigMap can be mounted, inline files are uploaded through the Admin API
+	if r.Spec.AdminAPIKeySecretRef == nil && (r.Spec.Routes.inline() || r.Spec.Redirects.inline()) {
+		allErrs = append(allErrs, field.Required(spec.Child("adminAPIKeySecretRef"), "inline spec.routes and spec.redirects are uploaded through the Admin API"))
+	}
+
+	// Images uploaded to S3 never reach the content volume
+	if export := r.Spec.Export; export != nil && export.IncludeImages && r.Spec.MediaStorage != nil && r.Spec.MediaStorage.S3 != nil {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("export", "includeImages"), "the images are stored in spec.mediaStorage.s3, not on the content volume"))
+	}
+
+	if old != nil {
+		persistence := spec.Child("persistence")
+		if !equality.Semantic.DeepEqual(accessModes(r), accessModes(old)) {
+			allErrs = append(allErrs, field.Forbidden(persistence.Child("accessModes"), "cannot be changed, the content volume keeps the access modes it was provisioned with"))
+		}
+		if r.ephemeral() != old.ephemeral() {
+			allErrs = append(allErrs, field.Forbidden(persistence.Child("enabled"), "cannot be changed, the content would not move between the volume and an emptyDir"))
+		}
+		var storageClass, oldStorageClass *string
+		if r.Spec.Persistence != nil {
+			storageClass = r.Spec.Persistence.StorageClassName
+		}
+		if old.Spec.Persistence != nil {
+			oldStorageClass = old.Spec.Persistence.StorageClassName
+		}
+		if !equality.Semantic.DeepEqual(storageClass, oldStorageClass) {
+			allErrs = append(allErrs, field.Forbidden(persistence.Child("storageClassName"), "cannot be changed, the content volume keeps the class it was provisioned with"))
+		}
+		if r.workloadKind() != old.workloadKind() {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("workload", "kind"), "cannot be changed, the content stays on the volume of the workload it was created with"))
+		}
+		// Without the migration Ghost starts on whatever the MySQL database holds
+		if old.sqlite() && !r.sqlite() && (r.Spec.Database == nil || r.Spec.Database.Migration == nil || !r.Spec.Database.Migration.Enabled) {
+			warnings = append(warnings, fmt.Sprintf("%s switches from SQLite to MySQL without %s, the content of the SQLite file is not copied",
+				spec.Child("database", "client"), spec.Child("database", "migration", "enabled")))
+		}
+		if size, oldSize := persistenceSize(r), persistenceSize(old); size != nil && oldSize != nil && size.Cmp(*oldSize) < 0 {
+			warnings = append(warnings, fmt.Sprintf("%s is ignored below %s, volumes cannot shrink", persistence.Child("size"), oldSize.String()))
+		}
+		if policy := r.Spec.UpgradePolicy; policy == nil || !policy.AllowMajorSkips {
+			from, fromVersion := MajorVersion(old.imageTag())
+			to, toVersion := MajorVersion(r.imageTag())
+			if fromVersion && toVersion && to > from+1 {
+				allErrs = append(allErrs, field.Forbidden(r.imageTagPath(spec), fmt.Sprintf(
+					"upgrading from Ghost %d to %d skips a major version, upgrade to Ghost %d first or set spec.upgradePolicy.allowMajorSkips", from, to, from+1)))
+			}
+		}
+	}
+	if overlay := r.Spec.PodTemplateOverlay; overlay != nil && len(overlay.Raw) > 0 {
+		// An overlay that does not patch an empty pod template cannot patch the generated one either
+		patched, err := strategicpatch.StrategicMergePatch([]byte("{}"), overlay.Raw, corev1.PodTemplateSpec{})
+		if err == nil {
+			err = json.Unmarshal(patched, &corev1.PodTemplateSpec{})
+		}
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(spec.Child("podTemplateOverlay"), string(overlay.Raw), err.Error()))
+		}
+	}
+	return warnings, allErrs
+}
+
+// syncTagLabels sets the labels of spec.tags and drops those of removed tags, other labels are kept
+func syncTagLabels(labels, tags map[string]string) map[string]string {
+	for key := range labels {
+		if tag, ok := strings.CutPrefix(key, TagLabelPrefix); ok {
+			if _, tagged := tags[tag]; !tagged {
+				delete(labels, key)
+			}
+		}
+	}
+	for key, value := range TagLabels(tags) {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+// inline reports whether the file is held in the spec itself
+func (s *SiteConfigSource) inline() bool {
+	return s != nil && s.Inline != ""
+}
+
+// DefaultHost is the host a Ghost without spec.ingress.host is served at, its name under its base domain
+func (r *Ghost) DefaultHost() string {
+	domain := r.Annotations[BaseDomainAnnotation]
+	if domain == "" {
+		domain = DefaultBaseDomain
+	}
+	return r.Name + "." + domain
+}
+
+// ServedHosts returns the hosts the Ghost serves, spec.ingress.host or its default host first and
+// then spec.ingress.extraHosts
+func (r *Ghost) ServedHosts() []string {
+	if r.Spec.Ingress == nil {
+		return []string{r.DefaultHost()}
+	}
+	host := r.Spec.Ingress.Host
+	if host == "" {
+		host = r.DefaultHost()
+	}
+	return append([]string{host}, r.Spec.Ingress.ExtraHosts...)
+}
+
+// Hosts returns the hosts the Ghost routes or redirects away from, none unless it is routed. The
+// admission webhook and the controller both index the Ghosts by them, so a host admitted as unique
+// is not reported as a conflict once the Ghost is reconciled.
+func (r *Ghost) Hosts() []string {
+	if !r.Spec.EnableIngress || (r.Spec.Exposure != nil && r.Spec.Exposure.Mode == ExposureModePortForwardOnly) {
+		return nil
+	}
+	hosts := r.ServedHosts()
+	// Redirects are only served by ingress-nginx
+	if r.Spec.Ingress != nil && (r.Spec.Routing == nil || r.Spec.Routing.Mode == "" || r.Spec.Routing.Mode == RoutingModeIngress) {
+		for _, redirect := range r.Spec.Ingress.Redirects {
+			hosts = append(hosts, redirect.From)
+		}
+	}
+	return hosts
+}
+
+// hostPath is the field a host of Hosts is set by, the name for the default host
+func (r *Ghost) hostPath(host string) *field.Path {
+	path := field.NewPath("spec", "ingress")
+	if r.Spec.Ingress != nil {
+		if r.Spec.Ingress.Host == host {
+			return path.Child("host")
+		}
+		if i := slices.Index(r.Spec.Ingress.ExtraHosts, host); i >= 0 {
+			return path.Child("extraHosts").Index(i)
+		}
+		for i, redirect := range r.Spec.Ingress.Redirects {
+			if redirect.From == host {
+				return path.Child("redirects").Index(i).Child("from")
+			}
+		}
+	}
+	return field.NewPath("metadata", "name")
+}
+
+// validateHostsUnique rejects a host another Ghost already routes or redirects, the ingress
+// controller would route it to either of them. An update keeping the hosts is let through, and a
+// renamed Ghost shares its hosts with the Ghost it replaces.
+func (r *Ghost) validateHostsUnique(old *Ghost) field.ErrorList {
+	hosts := r.Hosts()
+	if len(hosts) == 0 || hostReader == nil || (old != nil && slices.Equal(hosts, old.Hosts())) {
+		return nil
+	}
+	ghosts := &GhostList{}
+	if err := hostReader.List(context.Background(), ghosts); err != nil {
+		return field.ErrorList{field.InternalError(r.hostPath(hosts[0]), err)}
+	}
+	var allErrs field.ErrorList
+	for _, host := range hosts {
+		hostPath := r.hostPath(host)
+		for _, other := range ghosts.Items {
+			if !slices.Contains(other.Hosts(), host) {
+				continue
+			}
+			if other.Namespace == r.Namespace && (other.Name == r.Name ||
+				other.Name == r.Annotations[RenamedFromAnnotation] || other.Annotations[RenamedFromAnnotation] == r.Name) {
+				continue
+			}
+			allErrs = append(allErrs, field.Invalid(hostPath, host, fmt.Sprintf("already set by Ghost %s/%s", other.Namespace, other.Name)))
+			break
+		}
+	}
+	return allErrs
+}
+
+// validateHost accepts DNS subdomains, with a leading wildcard label
+func validateHost(path *field.Path, host string) field.ErrorList {
+	validate := validation.IsDNS1123Subdomain
+	if strings.HasPrefix(host, "*.") {
+		validate = validation.IsWildcardDNS1123Subdomain
+	}
+	var allErrs field.ErrorList
+	for _, msg := range validate(host) {
+		allErrs = append(allErrs, field.Invalid(path, host, msg))
+	}
+	return allErrs
+}
+
+// maxPods is the most Ghost pods running at once, the autoscaler decides within its bounds
+func (r *Ghost) maxPods() int32 {
+	if r.Spec.Autoscaling != nil {
+		return r.Spec.Autoscaling.MaxReplicas
+	}
+	return r.Spec.Replicas
+}
+
+// sqlite reports whether Ghost keeps its database in SQLite on the content volume, the default
+// unless spec.database or spec.podTemplateOverlay sets the database__client of the ghost container
+func (r *Ghost) sqlite() bool {
+	sqlite := r.Spec.Database == nil || r.Spec.Database.Client != DatabaseClientMySQL
+	overlay := r.Spec.PodTemplateOverlay
+	if overlay == nil || len(overlay.Raw) == 0 {
+		return sqlite
+	}
+	template := corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(overlay.Raw, &template); err != nil {
+		return sqlite
+	}
+	for _, container := range template.Spec.Containers {
+		if container.Name != "ghost" {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == "database__client" {
+				return env.Value == "sqlite3" || env.Value == "sqlite"
+			}
+		}
+	}
+	return sqlite
+}
+
+// sharedStorage reports whether the content volume can attach to the pods of several nodes
+func (r *Ghost) sharedStorage() bool {
+	return slices.Contains(accessModes(r), corev1.ReadWriteMany)
+}
+
+// ephemeral reports whether the content lives in an emptyDir rather than a PVC
+func (r *Ghost) ephemeral() bool {
+	return r.Spec.Persistence != nil && r.Spec.Persistence.Enabled != nil && !*r.Spec.Persistence.Enabled
+}
+
+// accessModes are the access modes of the content volume, nil when left to the default
+func accessModes(ghost *Ghost) []corev1.PersistentVolumeAccessMode {
+	if ghost.Spec.Persistence == nil {
+		return nil
+	}
+	return ghost.Spec.Persistence.AccessModes
+}
+
+// workloadKind is the kind of workload running the Ghost pods, a Deployment unless set
+func (r *Ghost) workloadKind() WorkloadKind {
+	if r.Spec.Workload == nil || r.Spec.Workload.Kind == "" {
+		return WorkloadKindDeployment
+	}
+	return r.Spec.Workload.Kind
+}
+
+// imageTag is the tag the Ghost runs, empty when the image is pinned by digest
+func (r *Ghost) imageTag() string {
+	if image := r.Spec.Image; image != nil {
+		if image.Digest != "" {
+			return ""
+		}
+		if image.Tag != "" {
+			return image.Tag
+		}
+	}
+	return r.Spec.ImageTag
+}
+
+// imageTagPath is the field imageTag reads the tag from
+func (r *Ghost) imageTagPath(spec *field.Path) *field.Path {
+	if r.Spec.Image != nil && r.Spec.Image.Tag != "" {
+		return spec.Child("image", "tag")
+	}
+	return spec.Child("imageTag")
+}
+
+// persistenceSize is the requested size of the content volume, nil when unset
+func persistenceSize(ghost *Ghost) *resource.Quantity {
+	if ghost.Spec.Persistence == nil {
+		return nil
+	}
+	return ghost.Spec.Persistence.Size
+}
+
+// invalid turns the field errors into the Invalid status the API server returns to the client
+func (r *Ghost) invalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Ghost").GroupKind(), r.Name, allErrs)
+}
+
+// credentialEnvName matches the environment variables holding credentials, including Ghost's
+// config keys such as mail__options__auth__pass
+var credentialEnvName = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|access_?key|private_?key|credentials|__pass$)`)
+
+// plaintextCredential is a credential written into the spec instead of referenced from a Secret
+type plaintextCredential struct {
+	path   *field.Path
+	value  string
+	detail string
+}
+
+// plaintextCredentials lists the URLs carrying user info and the literal environment variables
+// named like credentials
+func (r *Ghost) plaintextCredentials(spec *field.Path) []plaintextCredential {
+	var found []plaintextCredential
+	inURL := func(path *field.Path, raw, detail string) {
+		if parsed, err := url.Parse(raw); err == nil && parsed.User != nil {
+			found = append(found, plaintextCredential{path: path, value: raw, detail: "must not carry credentials" + detail})
+		}
+	}
+	inURL(spec.Child("url"), r.Spec.URL, "")
+	if init := r.Spec.ContentInit; init != nil {
+		if init.Git != nil {
+			inURL(spec.Child("contentInit", "git", "repository"), init.Git.Repository, ", reference them through spec.contentInit.git.credentialsSecretRef")
+		}
+		inURL(spec.Child("contentInit", "archiveURL"), init.ArchiveURL, "")
+	}
+	if proxy := r.Spec.Proxy; proxy != nil {
+		inURL(spec.Child("proxy", "httpProxy"), proxy.HTTPProxy, "")
+		inURL(spec.Child("proxy", "httpsProxy"), proxy.HTTPSProxy, "")
+	}
+	if storage := r.Spec.MediaStorage; storage != nil && storage.S3 != nil {
+		inURL(spec.Child("mediaStorage", "s3", "endpoint"), storage.S3.Endpoint, ", reference them through spec.mediaStorage.s3.credentialsSecretRef")
+		inURL(spec.Child("mediaStorage", "s3", "assetHost"), storage.S3.AssetHost, "")
+	}
+	if warmup := r.Spec.CacheWarmup; warmup != nil {
+		for i, page := range warmup.URLs {
+			inURL(spec.Child("cacheWarmup", "urls").Index(i), page, "")
+		}
+	}
+	for _, containers := range []struct {
+		path       *field.Path
+		containers []corev1.Container
+	}{{spec.Child("initContainers"), r.Spec.InitContainers}, {spec.Child("sidecars"), r.Spec.Sidecars}} {
+		for i, container := range containers.containers {
+			for j, env := range container.Env {
+				if env.Value != "" && credentialEnvName.MatchString(env.Name) {
+					found = append(found, plaintextCredential{
+						path:   containers.path.Index(i).Child("env").Index(j).Child("value"),
+						value:  env.Value,
+						detail: "must not be a literal credential, reference it through valueFrom.secretKeyRef",
+					})
+				}
+			}
+		}
+	}
+	return found
+}
@@ -18,29 +18,677 @@ package v1
 
 import (
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
 )
 
 var _ = Describe("Ghost Webhook", func() {
 
 	Context("When creating Ghost under Defaulting Webhook", func() {
 		It("Should fill in the default value if a required field is empty", func() {
+			ghost := &Ghost{ObjectMeta: metav1.ObjectMeta{Name: "blog"}}
+			ghost.Default()
+			// Zero replicas stops the Ghost, the CRD schema defaults an omitted count to one
+			Expect(ghost.Spec.Replicas).To(BeZero())
+			Expect(ghost.Spec.Image).To(Equal(&ImageSpec{Repository: "ghost", Tag: DefaultImageTag}))
+			Expect(ghost.Spec.Persistence.Size.String()).To(Equal("1Gi"))
+			Expect(ghost.Spec.Service.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			_, err := ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
 
-			// TODO(user): Add your logic here
+		It("Should keep the values set and the Service type of existing Ghosts", func() {
+			size := resource.MustParse("5Gi")
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog", CreationTimestamp: metav1.Now()},
+				Spec: GhostSpec{
+					Replicas:    2,
+					ImageTag:    "alpine",
+					Persistence: &PersistenceSpec{Size: &size},
+				},
+			}
+			ghost.Default()
+			Expect(ghost.Spec.Replicas).To(Equal(int32(2)))
+			Expect(ghost.Spec.Image).To(BeNil())
+			Expect(ghost.Spec.Persistence.Size.String()).To(Equal("5Gi"))
+			Expect(ghost.Spec.Service).To(BeNil())
+		})
 
+		It("Should surface the tags as labels and drop the labels of removed tags", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog", Labels: map[string]string{
+					"team":                    "news",
+					TagLabelPrefix + "region": "us",
+					TagLabelPrefix + "tier":   "gold",
+				}},
+				Spec: GhostSpec{ImageTag: "alpine", Tags: map[string]string{"region": "eu"}},
+			}
+			ghost.Default()
+			Expect(ghost.Labels).To(Equal(map[string]string{"team": "news", TagLabelPrefix + "region": "eu"}))
 		})
 	})
 
 	Context("When creating Ghost under Validating Webhook", func() {
 		It("Should deny if a required field is empty", func() {
+			ghost := &Ghost{ObjectMeta: metav1.ObjectMeta{Name: "blog"}, Spec: GhostSpec{Replicas: 1}}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.imageTag: Required value")))
+		})
 
-			// TODO(user): Add your logic here
+		It("Should deny garbage image tags, negative replicas and malformed hosts", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag: "-",
+					Replicas: -1,
+					Ingress: &IngressSpec{
+						Host:       "Blog_Example.com",
+						ExtraHosts: []string{"www.example.com", "www.example.com", "*.example.com"},
+					},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(HaveOccurred())
+			for _, message := range []string{
+				"spec.imageTag: Invalid value",
+				"spec.replicas: Invalid value",
+				"spec.ingress.host: Invalid value",
+				"spec.ingress.extraHosts[1]: Duplicate value",
+			} {
+				Expect(err.Error()).To(ContainSubstring(message))
+			}
+			Expect(err.Error()).NotTo(ContainSubstring("extraHosts[2]"))
+		})
+
+		It("Should deny time zones missing from the time zone database", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 1, Timezone: "Europe/Atlantis"},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.timezone: Invalid value")))
+
+			ghost.Spec.Timezone = "America/Sao_Paulo"
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny a preStop sleep outlasting the termination grace period", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 1, PreStopSleepSeconds: ptr.To[int32](30)},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.preStopSleepSeconds: Invalid value")))
+
+			ghost.Spec.TerminationGracePeriodSeconds = ptr.To[int64](45)
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny a pod template overlay that does not patch a pod template", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag:           "latest",
+					Replicas:           1,
+					PodTemplateOverlay: &runtime.RawExtension{Raw: []byte(`{"spec": {"hostAliases": "not a list"}}`)},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.podTemplateOverlay: Invalid value")))
+
+			ghost.Spec.PodTemplateOverlay = &runtime.RawExtension{Raw: []byte(`{"spec": {"hostAliases": [{"ip": "10.0.0.1", "hostnames": ["db.internal"]}]}}`)}
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should warn that content without persistence is ephemeral", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag:    "latest",
+					Replicas:    1,
+					Persistence: &PersistenceSpec{Enabled: ptr.To(false)},
+				},
+			}
+			warnings, err := ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("spec.persistence.enabled is false")))
+
+			ghost.Spec.Replicas = 2
+			ghost.Spec.Backup = &BackupScheduleSpec{Schedule: "@daily", Method: BackupMethodVolume}
+			ghost.Spec.UpgradePolicy = &UpgradePolicySpec{Strategy: UpgradeStrategyBlueGreen}
+			_, err = ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.replicas: Forbidden: each pod would keep its own content")))
+			Expect(err).To(MatchError(ContainSubstring("spec.backup.method: Forbidden")))
+			Expect(err).To(MatchError(ContainSubstring("spec.upgradePolicy.strategy: Forbidden")))
+
+			persistent := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 1},
+			}
+			updated := persistent.DeepCopy()
+			updated.Spec.Persistence = &PersistenceSpec{Enabled: ptr.To(false)}
+			_, err = updated.ValidateUpdate(persistent)
+			Expect(err).To(MatchError(ContainSubstring("spec.persistence.enabled: Forbidden")))
+		})
+
+		It("Should deny exporting images that are not on the content volume", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag: "latest",
+					Replicas: 1,
+					Export: &ContentExportSpec{
+						Schedule:      "@daily",
+						IncludeImages: true,
+						Destination:   ContentExportDestination{ClaimName: "ghost-exports"},
+					},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+
+			ghost.Spec.MediaStorage = &MediaStorageSpec{S3: &S3MediaStorageSpec{Bucket: "media", Region: "eu-west-1"}}
+			_, err = ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.export.includeImages: Forbidden: the images are stored in spec.mediaStorage.s3")))
+
+			ghost.Spec.MediaStorage = nil
+			ghost.Spec.Persistence = &PersistenceSpec{Enabled: ptr.To(false)}
+			_, err = ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.export.includeImages: Forbidden: there is no volume")))
+		})
+
+		It("Should deny tags that cannot be labels", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "alpine", Tags: map[string]string{"Region!": "eu", "tier": "gold plated"}},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.tags[Region!]")))
+			Expect(err).To(MatchError(ContainSubstring("spec.tags[tier]")))
+		})
+
+		It("Should deny inline routes without an Admin API key to upload them with", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 1, Routes: &SiteConfigSource{Inline: "routes: {}"}},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.adminAPIKeySecretRef: Required value")))
+
+			ghost.Spec.Routes = &SiteConfigSource{ConfigMapRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "site"},
+				Key:                  "routes.yaml",
+			}}
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny redirects away from the Ghost's own hosts or to hosts it does not serve", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag: "latest",
+					Replicas: 1,
+					Ingress: &IngressSpec{
+						Host: "www.example.com",
+						Redirects: []IngressRedirect{
+							{From: "example.com"},
+							{From: "www.example.com"},
+							{From: "blog.example.com", To: "elsewhere.example.com"},
+						},
+					},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.ingress.redirects[1].from: Invalid value"))
+			Expect(err.Error()).To(ContainSubstring("spec.ingress.redirects[2].to: Invalid value"))
+			Expect(err.Error()).NotTo(ContainSubstring("redirects[0]"))
+
+			ghost.Spec.Ingress.Redirects = ghost.Spec.Ingress.Redirects[:1]
+			ghost.Spec.Routing = &RoutingSpec{Mode: RoutingModeGatewayAPI}
+			_, err = ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.ingress.redirects: Forbidden")))
+
+			By("admitting a redirect to the default host of a Ghost without spec.ingress.host")
+			ghost.Spec.Routing = nil
+			ghost.Spec.Ingress = &IngressSpec{Redirects: []IngressRedirect{{From: "example.com", To: "blog.kb.dev"}}}
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny extra ports clashing with the Ghost port or served by unknown containers", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag: "latest",
+					Replicas: 1,
+					Service: &ServiceSpec{ExtraPorts: []ServicePortSpec{
+						{Name: "http", Port: 8080},
+						{Name: "web", Port: 80},
+						{Name: "metrics", Port: 9100, ContainerName: "exporter"},
+					}},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.service.extraPorts[0].name: Invalid value"))
+			Expect(err.Error()).To(ContainSubstring("spec.service.extraPorts[1].port: Invalid value"))
+			Expect(err.Error()).To(ContainSubstring("spec.service.extraPorts[2].containerName: Invalid value"))
 
+			ghost.Spec.Service.ExtraPorts = ghost.Spec.Service.ExtraPorts[2:]
+			ghost.Spec.Sidecars = []corev1.Container{{Name: "exporter", Image: "prom/node-exporter"}}
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny a url outside of the sub-path Ghost is routed under", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag: "latest",
+					Replicas: 1,
+					URL:      "https://www.example.com/",
+					Ingress:  &IngressSpec{Host: "www.example.com", Path: "/blog"},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.url: Invalid value")))
+
+			ghost.Spec.URL = "https://www.example.com/blog/"
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny credentials written into the spec instead of referenced from a Secret", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag: "latest",
+					Replicas: 1,
+					ContentInit: &ContentInitSpec{
+						Git: &GitContentSource{Repository: "https://ghp_token@github.com/example/content.git"},
+					},
+					Proxy: &ProxySpec{HTTPSProxy: "http://proxy:3128"},
+					Sidecars: []corev1.Container{{
+						Name:  "shipper",
+						Image: "fluent-bit",
+						Env: []corev1.EnvVar{
+							{Name: "LOG_LEVEL", Value: "info"},
+							{Name: "API_KEY", Value: "s3cret"},
+						},
+					}},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.contentInit.git.repository: Forbidden"))
+			Expect(err.Error()).To(ContainSubstring("spec.sidecars[0].env[1].value: Forbidden"))
+			Expect(err.Error()).NotTo(ContainSubstring("s3cret"))
+			Expect(err.Error()).NotTo(ContainSubstring("proxy"))
+			Expect(err.Error()).NotTo(ContainSubstring("env[0]"))
+
+			By("keeping the credentials admitted before until they change")
+			updated := ghost.DeepCopy()
+			updated.Spec.Replicas = 0
+			_, err = updated.ValidateUpdate(ghost)
+			Expect(err).NotTo(HaveOccurred())
+			updated.Spec.Sidecars[0].Env[1].Value = "rotated"
+			_, err = updated.ValidateUpdate(ghost)
+			Expect(err).To(MatchError(ContainSubstring("spec.sidecars[0].env[1].value: Forbidden")))
+		})
+
+		It("Should deny changing the storage class and warn about shrinking the volume", func() {
+			fast, standard := "fast", "standard"
+			size := resource.MustParse("10Gi")
+			old := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag:    "latest",
+					Replicas:    1,
+					Persistence: &PersistenceSpec{Size: &size, StorageClassName: &standard},
+				},
+			}
+			updated := old.DeepCopy()
+			updated.Spec.Persistence.StorageClassName = &fast
+			_, err := updated.ValidateUpdate(old)
+			Expect(err).To(MatchError(ContainSubstring("spec.persistence.storageClassName: Forbidden")))
+
+			smaller := resource.MustParse("5Gi")
+			updated = old.DeepCopy()
+			updated.Spec.Persistence.Size = &smaller
+			warnings, err := updated.ValidateUpdate(old)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("volumes cannot shrink")))
+		})
+
+		It("Should deny scaling out on ReadWriteOnce storage and changing the access modes", func() {
+			old := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 1},
+			}
+			updated := old.DeepCopy()
+			updated.Spec.Replicas = 2
+			_, err := updated.ValidateUpdate(old)
+			Expect(err).To(MatchError(ContainSubstring("spec.replicas: Forbidden")))
+			updated.Spec.Replicas = 1
+			updated.Spec.Autoscaling = &AutoscalingSpec{MinReplicas: 1, MaxReplicas: 3}
+			_, err = updated.ValidateUpdate(old)
+			Expect(err).To(MatchError(ContainSubstring("spec.autoscaling.maxReplicas: Forbidden")))
+			updated.Spec.Autoscaling = nil
+			updated.Spec.Persistence = &PersistenceSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}}
+			_, err = updated.ValidateUpdate(old)
+			Expect(err).To(MatchError(ContainSubstring("spec.persistence.accessModes: Forbidden")))
+
+			By("keeping the pods of a Ghost scaled out before")
+			old.Spec.Replicas = 2
+			updated = old.DeepCopy()
+			updated.Spec.ImageTag = "alpine"
+			_, err = updated.ValidateUpdate(old)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("admitting more pods on ReadWriteMany storage")
+			shared := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag:    "latest",
+					Replicas:    3,
+					Persistence: &PersistenceSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+				},
+			}
+			warnings, err := shared.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("SQLite database")))
+
+			By("not warning about a MySQL database")
+			shared.Spec.PodTemplateOverlay = &runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"ghost","env":[{"name":"database__client","value":"mysql"}]}]}}`)}
+			warnings, err = shared.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			By("not warning about the MySQL client of spec.database")
+			shared.Spec.PodTemplateOverlay = nil
+			shared.Spec.Database = &DatabaseSpec{Client: DatabaseClientMySQL, MySQL: &MySQLSpec{Host: "mysql", Database: "ghost", User: "ghost"}}
+			warnings, err = shared.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("Should validate the database and warn about switching to MySQL without the migration", func() {
+			old := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 1},
+			}
+
+			By("requiring the MySQL server of the mysql client")
+			updated := old.DeepCopy()
+			updated.Spec.Database = &DatabaseSpec{Client: DatabaseClientMySQL}
+			_, err := updated.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.database.mysql: Required value")))
+
+			By("warning that the SQLite content is not copied without the migration")
+			updated.Spec.Database.MySQL = &MySQLSpec{Host: "mysql", Database: "ghost", User: "ghost"}
+			warnings, err := updated.ValidateUpdate(old)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("spec.database.migration.enabled")))
+
+			By("not warning once the migration copies it")
+			updated.Spec.Database.Migration = &DatabaseMigrationSpec{Enabled: true}
+			warnings, err = updated.ValidateUpdate(old)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			By("denying the migration of a StatefulSet")
+			updated.Spec.Workload = &WorkloadSpec{Kind: WorkloadKindStatefulSet}
+			_, err = updated.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.database.migration.enabled: Forbidden")))
+		})
+
+		It("Should provision new scaled out Ghosts on the shared storage class", func() {
+			DeferCleanup(func(class string) { SharedStorageClassName = class }, SharedStorageClassName)
+			SharedStorageClassName = "nfs"
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 2},
+			}
+			ghost.Default()
+			Expect(*ghost.Spec.Persistence.StorageClassName).To(Equal("nfs"))
+			Expect(ghost.Spec.Persistence.AccessModes).To(ConsistOf(corev1.ReadWriteMany))
+			_, err := ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+
+			single := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 1},
+			}
+			single.Default()
+			Expect(single.Spec.Persistence.StorageClassName).To(BeNil())
+			Expect(single.Spec.Persistence.AccessModes).To(BeEmpty())
+		})
+
+		It("Should deny upgrades skipping a Ghost major version unless they are allowed", func() {
+			old := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{Image: &ImageSpec{Tag: "3.42.9-alpine"}, Replicas: 1},
+			}
+			updated := old.DeepCopy()
+			updated.Spec.Image.Tag = "4.48.9"
+			_, err := updated.ValidateUpdate(old)
+			Expect(err).NotTo(HaveOccurred())
+
+			updated.Spec.Image.Tag = "5.96.0"
+			_, err = updated.ValidateUpdate(old)
+			Expect(err).To(MatchError(ContainSubstring("spec.image.tag: Forbidden: upgrading from Ghost 3 to 5 skips a major version")))
+
+			updated.Spec.Image = nil
+			updated.Spec.ImageTag = "latest"
+			_, err = updated.ValidateUpdate(old)
+			Expect(err).NotTo(HaveOccurred())
+
+			updated.Spec.ImageTag = "5-alpine"
+			_, err = updated.ValidateUpdate(old)
+			Expect(err).To(MatchError(ContainSubstring("spec.imageTag: Forbidden")))
+
+			updated.Spec.UpgradePolicy = &UpgradePolicySpec{AllowMajorSkips: true}
+			_, err = updated.ValidateUpdate(old)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny tracking releases from a tag naming none", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "5-alpine", ImageTagPolicy: ImageTagPolicyTrackPatch, Replicas: 1},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.imageTag: Invalid value")))
+
+			ghost.Spec.ImageTagPolicy = ImageTagPolicyTrackMinor
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+
+			ghost.Spec.Image = &ImageSpec{Digest: "sha256:0123"}
+			_, err = ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.imageTagPolicy: Forbidden")))
+		})
+
+		It("Should deny a StatefulSet more than one replica and changing the workload kind", func() {
+			old := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec:       GhostSpec{ImageTag: "latest", Replicas: 1},
+			}
+			updated := old.DeepCopy()
+			updated.Spec.Workload = &WorkloadSpec{Kind: WorkloadKindStatefulSet}
+			_, err := updated.ValidateUpdate(old)
+			Expect(err).To(MatchError(ContainSubstring("spec.workload.kind: Forbidden")))
+
+			_, err = updated.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			updated.Spec.Replicas = 2
+			updated.Spec.UpgradePolicy = &UpgradePolicySpec{Strategy: UpgradeStrategyBlueGreen}
+			_, err = updated.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.replicas: Invalid value")))
+			Expect(err).To(MatchError(ContainSubstring("spec.upgradePolicy.strategy: Forbidden")))
+		})
+
+		It("Should deny auto expanding the volume without a step or below its size", func() {
+			size := resource.MustParse("10Gi")
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag: "latest",
+					Replicas: 1,
+					Persistence: &PersistenceSpec{Size: &size, AutoExpand: &AutoExpandSpec{
+						ThresholdPercent: 80,
+						MaxSize:          resource.MustParse("5Gi"),
+					}},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.persistence.autoExpand.step: Invalid value")))
+			Expect(err).To(MatchError(ContainSubstring("spec.persistence.autoExpand.maxSize: Invalid value")))
+
+			ghost.Spec.Persistence.AutoExpand.Step = resource.MustParse("2Gi")
+			ghost.Spec.Persistence.AutoExpand.MaxSize = resource.MustParse("20Gi")
+			_, err = ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny routing a PortForwardOnly Ghost and warn about its node port", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					ImageTag:      "latest",
+					Replicas:      1,
+					EnableIngress: true,
+					Exposure:      &ExposureSpec{Mode: ExposureModePortForwardOnly},
+					Service:       &ServiceSpec{NodePort: 30080},
+				},
+			}
+			_, err := ghost.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.enableIngress: Forbidden")))
+
+			ghost.Spec.EnableIngress = false
+			warnings, err := ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("spec.service.nodePort is ignored")))
+		})
+
+		It("Should admit metadata updates of a Ghost admitted before the validation", func() {
+			old := &Ghost{ObjectMeta: metav1.ObjectMeta{Name: "blog"}, Spec: GhostSpec{ImageTag: "-", Replicas: 1}}
+			updated := old.DeepCopy()
+			updated.Finalizers = []string{"marketing.kb.dev/cleanup"}
+			_, err := updated.ValidateUpdate(old)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny changing the Ghost a rename takes over from", func() {
+			old := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "new-name",
+					Annotations: map[string]string{RenamedFromAnnotation: "old-name", ContentClaimAnnotation: "ghost-data-pvc-old-name"},
+				},
+				Spec: GhostSpec{ImageTag: "latest", Replicas: 1},
+			}
+			_, err := old.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := old.DeepCopy()
+			updated.Annotations[ContentClaimAnnotation] = "ghost-data-pvc-other"
+			_, err = updated.ValidateUpdate(old)
+			Expect(err).To(HaveOccurred())
+
+			self := &Ghost{ObjectMeta: metav1.ObjectMeta{Name: "blog", Annotations: map[string]string{RenamedFromAnnotation: "blog"}}}
+			_, err = self.ValidateCreate()
+			Expect(err).To(HaveOccurred())
 		})
 
 		It("Should admit if all required fields are provided", func() {
+			ghost := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog"},
+				Spec: GhostSpec{
+					Replicas: 1,
+					Image:    &ImageSpec{Repository: "ghost", Tag: "5.96.0-alpine"},
+					Ingress:  &IngressSpec{Host: "blog.example.com", ExtraHosts: []string{"*.blog.example.com"}},
+				},
+			}
+			warnings, err := ghost.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
 
-			// TODO(user): Add your logic here
+		It("Should deny a host another Ghost of the cluster already sets", func() {
+			claimed := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "news", Namespace: "default"},
+				Spec: GhostSpec{
+					Replicas:      1,
+					EnableIngress: true,
+					Ingress:       &IngressSpec{Host: "news.example.com"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, claimed)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, claimed)).To(Succeed())
+			})
+
+			duplicate := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "news", Namespace: "kube-public"},
+				Spec: GhostSpec{
+					Replicas:      1,
+					EnableIngress: true,
+					Ingress:       &IngressSpec{Host: "blog.example.com", ExtraHosts: []string{"news.example.com"}},
+				},
+			}
+			Eventually(func() error {
+				return k8sClient.Create(ctx, duplicate.DeepCopy())
+			}).Should(MatchError(ContainSubstring("spec.ingress.extraHosts[0]: Invalid value: \"news.example.com\": already set by Ghost default/news")))
+
+			By("admitting the host while the Ingress is disabled")
+			duplicate.Spec.EnableIngress = false
+			Expect(k8sClient.Create(ctx, duplicate)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, duplicate)).To(Succeed())
+			})
+			duplicate.Spec.EnableIngress = true
+			Expect(k8sClient.Update(ctx, duplicate)).NotTo(Succeed())
+
+			By("denying a redirect away from a host of the other Ghost")
+			redirect := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "kube-public"},
+				Spec: GhostSpec{
+					Replicas:      1,
+					EnableIngress: true,
+					Ingress:       &IngressSpec{Redirects: []IngressRedirect{{From: "news.example.com"}}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, redirect)).To(MatchError(ContainSubstring("spec.ingress.redirects[0].from: Invalid value: \"news.example.com\": already set by Ghost default/news")))
 
+			By("denying the default host of the other Ghost")
+			mirror := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "default"},
+				Spec:       GhostSpec{Replicas: 1, EnableIngress: true},
+			}
+			Expect(k8sClient.Create(ctx, mirror)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, mirror)).To(Succeed())
+			})
+			alias := &Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "alias", Namespace: "kube-public"},
+				Spec: GhostSpec{
+					Replicas:      1,
+					EnableIngress: true,
+					Ingress:       &IngressSpec{Host: "mirror.kb.dev"},
+				},
+			}
+			Eventually(func() error {
+				return k8sClient.Create(ctx, alias.DeepCopy())
+			}).Should(MatchError(ContainSubstring("spec.ingress.host: Invalid value: \"mirror.kb.dev\": already set by Ghost default/mirror")))
 		})
 	})
 
@@ -0,0 +1,125 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BackupMethod selects what a GhostBackup captures
+// +kubebuilder:validation:Enum=Volume;Export
+type BackupMethod string
+
+const (
+	// BackupMethodVolume archives the whole content PVC, including images and the database
+	BackupMethodVolume BackupMethod = "Volume"
+	// BackupMethodExport saves Ghost's JSON export of posts, pages and settings
+	BackupMethodExport BackupMethod = "Export"
+)
+
+// BackupPhase is the lifecycle stage of a GhostBackup
+type BackupPhase string
+
+const (
+	BackupPhasePending   BackupPhase = "Pending"
+	BackupPhaseRunning   BackupPhase = "Running"
+	BackupPhaseSucceeded BackupPhase = "Succeeded"
+	BackupPhaseFailed    BackupPhase = "Failed"
+)
+
+// GhostBackupSpec defines the desired state of GhostBackup
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable, create a new GhostBackup instead"
+// +kubebuilder:validation:XValidation:rule="self.method != 'Export' || has(self.adminAPIKeySecretRef)",message="the Export method requires adminAPIKeySecretRef"
+type GhostBackupSpec struct {
+	// GhostRef names the Ghost in the same namespace to back up
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	// +kubebuilder:default=Volume
+	// +optional
+	Method BackupMethod `json:"method,omitempty"`
+	// AdminAPIKeySecretRef holds the Admin API key used by the Export method
+	// +optional
+	AdminAPIKeySecretRef *corev1.SecretKeySelector `json:"adminAPIKeySecretRef,omitempty"`
+	Destination          BackupDestination         `json:"destination"`
+}
+
+// BackupDestination is the object storage location backups are uploaded to
+type BackupDestination struct {
+	// URL is the bucket and optional prefix, s3://bucket/prefix or gs://bucket/prefix
+	// +kubebuilder:validation:Pattern=`^(s3|gs)://[^/]+(/.*)?$`
+	URL string `json:"url"`
+	// Endpoint overrides the S3 endpoint for S3 compatible stores such as MinIO
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// Region of the S3 bucket
+	// +optional
+	Region string `json:"region,omitempty"`
+	// CredentialsSecretRef names a Secret with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	// for S3, or a credentials.json service account key for GCS
+	CredentialsSecretRef corev1.LocalObjectReference `json:"credentialsSecretRef"`
+}
+
+// GhostBackupStatus defines the observed state of GhostBackup
+type GhostBackupStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+	// JobName is the Job taking the backup
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+	// Location is the URL of the uploaded artifact
+	// +optional
+	Location string `json:"location,omitempty"`
+	// SizeBytes is the size of the uploaded artifact
+	// +optional
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ghost",type=string,JSONPath=`.spec.ghostRef.name`
+// +kubebuilder:printcolumn:name="Method",type=string,JSONPath=`.spec.method`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.sizeBytes`
+// +kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.status.location`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GhostBackup is the Schema for the ghostbackups API
+type GhostBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostBackupSpec   `json:"spec,omitempty"`
+	Status GhostBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostBackupList contains a list of GhostBackup
+type GhostBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostBackup{}, &GhostBackupList{})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClonePhase is the lifecycle stage of a GhostClone
+type ClonePhase string
+
+const (
+	ClonePhasePending      ClonePhase = "Pending"
+	ClonePhaseSnapshotting ClonePhase = "Snapshotting"
+	ClonePhaseProvisioning ClonePhase = "Provisioning"
+	ClonePhaseSucceeded    ClonePhase = "Succeeded"
+	ClonePhaseFailed       ClonePhase = "Failed"
+)
+
+// GhostCloneSpec defines the desired state of GhostClone
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable, create a new GhostClone instead"
+type GhostCloneSpec struct {
+	// GhostRef names the Ghost in the same namespace whose content seeds the clone. The new
+	// Ghost is named after the GhostClone and copies the spec of this one.
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	// SnapshotRef seeds the clone from an existing VolumeSnapshot of the content of the Ghost,
+	// such as one taken before an upgrade, instead of snapshotting the content now
+	// +optional
+	SnapshotRef *corev1.LocalObjectReference `json:"snapshotRef,omitempty"`
+	// VolumeSnapshotClassName is the class of the snapshot taken of the content, the default
+	// class of the cluster when empty
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// Host serves the new Ghost. The clone gets no Ingress when empty.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Image runs a different Ghost image on the clone
+	// +optional
+	Image *ImageSpec `json:"image,omitempty"`
+}
+
+// GhostCloneStatus defines the observed state of GhostClone
+type GhostCloneStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	Phase ClonePhase `json:"phase,omitempty"`
+	// SnapshotName is the VolumeSnapshot the content of the clone is restored from
+	// +optional
+	SnapshotName string `json:"snapshotName,omitempty"`
+	// GhostName is the Ghost created by the clone. It is not owned by the GhostClone and
+	// outlives its deletion.
+	// +optional
+	GhostName string `json:"ghostName,omitempty"`
+	// URL is the address the clone is served at
+	// +optional
+	URL string `json:"url,omitempty"`
+	// CompletionTime is when the new Ghost became ready
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ghost",type=string,JSONPath=`.spec.ghostRef.name`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GhostClone is the Schema for the ghostclones API
+type GhostClone struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostCloneSpec   `json:"spec,omitempty"`
+	Status GhostCloneStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostCloneList contains a list of GhostClone
+type GhostCloneList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostClone `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostClone{}, &GhostCloneList{})
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ContentSyncPhase is the lifecycle stage of a GhostContentSync
+type ContentSyncPhase string
+
+const (
+	ContentSyncPhasePending   ContentSyncPhase = "Pending"
+	ContentSyncPhaseRunning   ContentSyncPhase = "Running"
+	ContentSyncPhaseSucceeded ContentSyncPhase = "Succeeded"
+	ContentSyncPhaseFailed    ContentSyncPhase = "Failed"
+)
+
+// GhostContentSyncSpec defines the desired state of GhostContentSync
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable, create a new GhostContentSync instead"
+// +kubebuilder:validation:XValidation:rule="self.source.ghostRef.name != self.target.ghostRef.name || has(self.source.__namespace__) != has(self.target.__namespace__) || (has(self.source.__namespace__) && self.source.__namespace__ != self.target.__namespace__)",message="source and target must be different Ghosts"
+type GhostContentSyncSpec struct {
+	// Source is the Ghost the content is exported from
+	Source ContentSyncEndpoint `json:"source"`
+	// Target is the Ghost the content is imported into
+	Target ContentSyncEndpoint `json:"target"`
+	// ExcludeMembers drops the members, their labels and the email recipients from the export
+	// before it is imported
+	// +optional
+	ExcludeMembers bool `json:"excludeMembers,omitempty"`
+	// ReplaceContent deletes the posts and tags of the target before the import, which
+	// otherwise adds to them
+	// +optional
+	ReplaceContent bool `json:"replaceContent,omitempty"`
+}
+
+// ContentSyncEndpoint is a Ghost content is synced from or to, reached through its Admin API
+type ContentSyncEndpoint struct {
+	// GhostRef names the Ghost
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	// Namespace of the Ghost, the namespace of the GhostContentSync when empty
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// AdminAPIKeySecretRef references the Admin API key of a custom integration of the Ghost.
+	// The Secret is read from the namespace of the GhostContentSync.
+	AdminAPIKeySecretRef corev1.SecretKeySelector `json:"adminAPIKeySecretRef"`
+}
+
+// GhostContentSyncStatus defines the observed state of GhostContentSync
+type GhostContentSyncStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	Phase ContentSyncPhase `json:"phase,omitempty"`
+	// JobName is the Job exporting and importing the content
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+	// Source is the namespace and name of the source Ghost
+	// +optional
+	Source string `json:"source,omitempty"`
+	// Target is the namespace and name of the target Ghost
+	// +optional
+	Target string `json:"target,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.status.source`
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.status.target`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GhostContentSync is the Schema for the ghostcontentsyncs API
+type GhostContentSync struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostContentSyncSpec   `json:"spec,omitempty"`
+	Status GhostContentSyncStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostContentSyncList contains a list of GhostContentSync
+type GhostContentSyncList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostContentSync `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostContentSync{}, &GhostContentSyncList{})
+}
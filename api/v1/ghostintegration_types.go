@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GhostIntegrationSpec defines the desired state of GhostIntegration
+type GhostIntegrationSpec struct {
+	// GhostRef names the Ghost in the same namespace the integration is created in
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="ghostRef is immutable, declare another GhostIntegration instead"
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	// DisplayName is the name of the integration in Ghost's admin, the name of the resource by default
+	// +kubebuilder:validation:MaxLength=191
+	// +optional
+	DisplayName string `json:"displayName,omitempty"`
+	// +kubebuilder:validation:MaxLength=2000
+	// +optional
+	Description string `json:"description,omitempty"`
+	// SecretName is the Secret the keys of the integration are published in, with the keys
+	// content-api-key, admin-api-key and url. Defaults to the name of the resource.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// AdminAPIKeySecretRef holds an Admin API key allowed to manage the integrations of the Ghost
+	AdminAPIKeySecretRef corev1.SecretKeySelector `json:"adminAPIKeySecretRef"`
+}
+
+// GhostIntegrationStatus defines the observed state of GhostIntegration
+type GhostIntegrationStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the generation last fully reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// IntegrationID is the id of the integration in Ghost
+	// +optional
+	IntegrationID string `json:"integrationID,omitempty"`
+	// SecretName is the Secret holding the keys
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ghost",type=string,JSONPath=`.spec.ghostRef.name`
+// +kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// GhostIntegration is the Schema for the ghostintegrations API
+type GhostIntegration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostIntegrationSpec   `json:"spec,omitempty"`
+	Status GhostIntegrationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostIntegrationList contains a list of GhostIntegration
+type GhostIntegrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostIntegration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostIntegration{}, &GhostIntegrationList{})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GhostMemberRole is a staff role of Ghost. The Owner is created by Ghost's setup and cannot be declared.
+// +kubebuilder:validation:Enum=Administrator;Editor;Author;Contributor
+type GhostMemberRole string
+
+const (
+	GhostMemberRoleAdministrator GhostMemberRole = "Administrator"
+	GhostMemberRoleEditor        GhostMemberRole = "Editor"
+	GhostMemberRoleAuthor        GhostMemberRole = "Author"
+	GhostMemberRoleContributor   GhostMemberRole = "Contributor"
+)
+
+// GhostMemberSpec defines the desired state of GhostMember
+type GhostMemberSpec struct {
+	// GhostRef names the Ghost in the same namespace the staff user belongs to
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="ghostRef is immutable, declare another GhostMember instead"
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	// Email identifies the staff user, an invitation is sent to it until they accept
+	// +kubebuilder:validation:Pattern=`^[^@\s]+@[^@\s]+$`
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="email is immutable, declare another GhostMember instead"
+	Email string `json:"email"`
+	// +kubebuilder:default=Contributor
+	// +optional
+	Role GhostMemberRole `json:"role,omitempty"`
+	// AdminAPIKeySecretRef holds an Admin API key allowed to manage the staff of the Ghost
+	AdminAPIKeySecretRef corev1.SecretKeySelector `json:"adminAPIKeySecretRef"`
+}
+
+// GhostMemberState is how far a staff user has joined
+type GhostMemberState string
+
+const (
+	// GhostMemberInvited is a staff user who has not accepted the invitation yet
+	GhostMemberInvited GhostMemberState = "Invited"
+	// GhostMemberActive is a staff user who has signed up
+	GhostMemberActive GhostMemberState = "Active"
+)
+
+// GhostMemberStatus defines the observed state of GhostMember
+type GhostMemberStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the generation last fully reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	State GhostMemberState `json:"state,omitempty"`
+	// UserID is the id of the staff user once they signed up
+	// +optional
+	UserID string `json:"userID,omitempty"`
+	// InviteID is the id of the pending invitation
+	// +optional
+	InviteID string `json:"inviteID,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ghost",type=string,JSONPath=`.spec.ghostRef.name`
+// +kubebuilder:printcolumn:name="Email",type=string,JSONPath=`.spec.email`
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.role`
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+
+// GhostMember is the Schema for the ghostmembers API
+type GhostMember struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostMemberSpec   `json:"spec,omitempty"`
+	Status GhostMemberStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostMemberList contains a list of GhostMember
+type GhostMemberList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostMember `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostMember{}, &GhostMemberList{})
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OperatorConfigName is the only GhostOperatorConfig the controller reads
+const OperatorConfigName = "default"
+
+// GhostOperatorConfigSpec holds the defaults of every Ghost in the cluster. A Ghost setting the
+// field itself keeps its own value, a changed default reaches the Ghosts without a restart.
+type GhostOperatorConfigSpec struct {
+	// StorageClassName provisions the content volume of a Ghost without
+	// spec.persistence.storageClassName. Existing volumes keep the class they were provisioned with.
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// IngressClassName selects the ingress controller of a Ghost without spec.ingress.className
+	// +optional
+	IngressClassName string `json:"ingressClassName,omitempty"`
+	// ImageRegistry is prefixed to the Ghost image repositories naming no registry, e.g. a mirror of
+	// Docker Hub such as registry.example.com/dockerhub
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9.-]+(:[0-9]+)?(/[a-z0-9._-]+)*$`
+	// +optional
+	ImageRegistry string `json:"imageRegistry,omitempty"`
+	// AllowedImageTags are the glob patterns, e.g. 5.*, an image tag has to match to be rolled
+	// out. Any tag is allowed when empty, an image pinned by digest is not checked.
+	// +optional
+	AllowedImageTags []string `json:"allowedImageTags,omitempty"`
+	// Resources of the Ghost container when neither spec.resources nor spec.profile is set
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:validation:XValidation:rule="self.metadata.name == 'default'",message="the controller only reads the GhostOperatorConfig named default"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GhostOperatorConfig is the Schema for the ghostoperatorconfigs API
+type GhostOperatorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GhostOperatorConfigSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostOperatorConfigList contains a list of GhostOperatorConfig
+type GhostOperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostOperatorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostOperatorConfig{}, &GhostOperatorConfigList{})
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PreviewPhase is the lifecycle stage of a GhostPreview
+type PreviewPhase string
+
+const (
+	PreviewPhaseProvisioning PreviewPhase = "Provisioning"
+	PreviewPhaseReady        PreviewPhase = "Ready"
+)
+
+// GhostPreviewSpec defines the desired state of GhostPreview
+// +kubebuilder:validation:XValidation:rule="self.ghostRef == oldSelf.ghostRef",message="ghostRef is immutable, create a new GhostPreview instead"
+// +kubebuilder:validation:XValidation:rule="has(self.backupRef) == has(oldSelf.backupRef) && (!has(self.backupRef) || self.backupRef == oldSelf.backupRef)",message="backupRef is immutable, create a new GhostPreview instead"
+type GhostPreviewSpec struct {
+	// GhostRef names the Ghost in the same namespace the preview is cloned from
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	// BackupRef imports a succeeded GhostBackup in the same namespace instead of cloning the
+	// content volume of the Ghost, which needs a CSI driver that clones volumes
+	// +optional
+	BackupRef *corev1.LocalObjectReference `json:"backupRef,omitempty"`
+	// Host serves the preview, <preview name>.preview.<Ghost host> when empty
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Image runs a different Ghost image on the preview, e.g. to try an upgrade. A theme is
+	// previewed by pointing a GhostTheme at the Ghost of the preview.
+	// +optional
+	Image *ImageSpec `json:"image,omitempty"`
+	// TTL is how long after its creation the preview is deleted together with its Ghost
+	// +kubebuilder:default="72h"
+	// +optional
+	TTL metav1.Duration `json:"ttl,omitempty"`
+}
+
+// GhostPreviewStatus defines the observed state of GhostPreview
+type GhostPreviewStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	Phase PreviewPhase `json:"phase,omitempty"`
+	// GhostName is the Ghost running the preview
+	// +optional
+	GhostName string `json:"ghostName,omitempty"`
+	// URL is the address the preview is served at
+	// +optional
+	URL string `json:"url,omitempty"`
+	// ExpiresAt is when the preview is deleted
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ghost",type=string,JSONPath=`.spec.ghostRef.name`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.status.expiresAt`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GhostPreview is the Schema for the ghostpreviews API
+type GhostPreview struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostPreviewSpec   `json:"spec,omitempty"`
+	Status GhostPreviewStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostPreviewList contains a list of GhostPreview
+type GhostPreviewList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostPreview `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostPreview{}, &GhostPreviewList{})
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestorePhase is the lifecycle stage of a GhostRestore
+type RestorePhase string
+
+const (
+	RestorePhasePending     RestorePhase = "Pending"
+	RestorePhaseScalingDown RestorePhase = "ScalingDown"
+	RestorePhaseRestoring   RestorePhase = "Restoring"
+	RestorePhaseSucceeded   RestorePhase = "Succeeded"
+	RestorePhaseFailed      RestorePhase = "Failed"
+)
+
+// GhostRestoreSpec defines the desired state of GhostRestore
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable, create a new GhostRestore instead"
+// +kubebuilder:validation:XValidation:rule="has(self.backupRef) != has(self.source)",message="exactly one of backupRef or source must be set"
+type GhostRestoreSpec struct {
+	// GhostRef names the Ghost in the same namespace to restore into
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	// BackupRef restores a succeeded GhostBackup in the same namespace
+	// +optional
+	BackupRef *corev1.LocalObjectReference `json:"backupRef,omitempty"`
+	// Source restores an artifact straight from object storage
+	// +optional
+	Source *RestoreSource `json:"source,omitempty"`
+	// AdminAPIKeySecretRef holds the Admin API key used to import Export backups
+	// +optional
+	AdminAPIKeySecretRef *corev1.SecretKeySelector `json:"adminAPIKeySecretRef,omitempty"`
+}
+
+// RestoreSource is a backup artifact in object storage
+type RestoreSource struct {
+	// Method is the method the artifact was taken with
+	// +kubebuilder:default=Volume
+	// +optional
+	Method BackupMethod `json:"method,omitempty"`
+	// BackupDestination locates the artifact, its url is the full object URL
+	BackupDestination `json:",inline"`
+}
+
+// GhostRestoreStatus defines the observed state of GhostRestore
+type GhostRestoreStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +optional
+	Phase RestorePhase `json:"phase,omitempty"`
+	// JobName is the Job restoring the artifact
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+	// Location is the URL of the restored artifact
+	// +optional
+	Location string `json:"location,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ghost",type=string,JSONPath=`.spec.ghostRef.name`
+// +kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.spec.backupRef.name`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.status.location`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GhostRestore is the Schema for the ghostrestores API
+type GhostRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostRestoreSpec   `json:"spec,omitempty"`
+	Status GhostRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostRestoreList contains a list of GhostRestore
+type GhostRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostRestore{}, &GhostRestoreList{})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StaticBuildPhase is the stage of the most recent build of a GhostStaticBuild
+type StaticBuildPhase string
+
+const (
+	StaticBuildPhaseRunning   StaticBuildPhase = "Running"
+	StaticBuildPhaseSucceeded StaticBuildPhase = "Succeeded"
+	StaticBuildPhaseFailed    StaticBuildPhase = "Failed"
+)
+
+// GhostStaticBuildSpec defines the desired state of GhostStaticBuild
+type GhostStaticBuildSpec struct {
+	// GhostRef names the Ghost in the same namespace the static site is built from
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="ghostRef is immutable, declare another GhostStaticBuild instead"
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	// OnPublish builds the site again whenever a post or page is published or updated
+	// +optional
+	OnPublish   *StaticBuildOnPublish  `json:"onPublish,omitempty"`
+	Destination StaticBuildDestination `json:"destination"`
+}
+
+// StaticBuildOnPublish watches the published content of the Ghost through the Admin API
+type StaticBuildOnPublish struct {
+	// AdminAPIKeySecretRef holds the Admin API key the content is watched with
+	AdminAPIKeySecretRef corev1.SecretKeySelector `json:"adminAPIKeySecretRef"`
+	// PollInterval is how often the content is checked for changes
+	// +kubebuilder:default="5m"
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// StaticBuildDestination is where the static site is published, a bucket or an nginx
+// Deployment serving it from a ConfigMap
+// +kubebuilder:validation:XValidation:rule="has(self.objectStore) != has(self.nginx)",message="exactly one of objectStore and nginx is required"
+type StaticBuildDestination struct {
+	// ObjectStore synchronises the site to the bucket and prefix of the URL, files of pages
+	// removed from Ghost are deleted
+	// +optional
+	ObjectStore *BackupDestination `json:"objectStore,omitempty"`
+	// Nginx serves the site from a ConfigMap. A ConfigMap holds at most 1MiB, the images
+	// are left out and keep being served by Ghost.
+	// +optional
+	Nginx *StaticBuildNginx `json:"nginx,omitempty"`
+}
+
+// StaticBuildNginx configures the nginx Deployment serving the site
+type StaticBuildNginx struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// Image of nginx
+	// +kubebuilder:default="nginx:1.27-alpine"
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// GhostStaticBuildStatus defines the observed state of GhostStaticBuild
+type GhostStaticBuildStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Builds is the number of builds started
+	// +optional
+	Builds int64 `json:"builds,omitempty"`
+	// Phase of the most recent build
+	// +optional
+	Phase StaticBuildPhase `json:"phase,omitempty"`
+	// JobName is the Job of the most recent build
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+	// LastBuildTime is when the most recent successful build finished
+	// +optional
+	LastBuildTime *metav1.Time `json:"lastBuildTime,omitempty"`
+	// BuildToken is the value of the marketing.kb.dev/build annotation last built for
+	// +optional
+	BuildToken string `json:"buildToken,omitempty"`
+	// ContentUpdatedAt is the time of the latest content change built
+	// +optional
+	ContentUpdatedAt *metav1.Time `json:"contentUpdatedAt,omitempty"`
+	// ServiceName is the Service of the nginx Deployment
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ghost",type=string,JSONPath=`.spec.ghostRef.name`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Last Build",type=date,JSONPath=`.status.lastBuildTime`
+
+// GhostStaticBuild is the Schema for the ghoststaticbuilds API. It crawls a Ghost into static
+// HTML, on creation, on request through the marketing.kb.dev/build annotation and optionally
+// whenever content is published.
+type GhostStaticBuild struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostStaticBuildSpec   `json:"spec,omitempty"`
+	Status GhostStaticBuildStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostStaticBuildList contains a list of GhostStaticBuild
+type GhostStaticBuildList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostStaticBuild `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostStaticBuild{}, &GhostStaticBuildList{})
+}
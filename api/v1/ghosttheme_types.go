@@ -0,0 +1,88 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GhostThemeSpec defines the desired state of GhostTheme
+type GhostThemeSpec struct {
+	// GhostRef names the Ghost in the same namespace the theme is installed on
+	GhostRef corev1.LocalObjectReference `json:"ghostRef"`
+	Source   ThemeSource                 `json:"source"`
+	// Activate makes the theme the active one once it is uploaded
+	// +optional
+	Activate bool `json:"activate,omitempty"`
+	// AdminAPIKeySecretRef holds the Admin API key of a Ghost custom integration
+	AdminAPIKeySecretRef corev1.SecretKeySelector `json:"adminAPIKeySecretRef"`
+}
+
+// ThemeSource locates the theme zip
+// +kubebuilder:validation:XValidation:rule="has(self.url) != has(self.configMapRef)",message="exactly one of url or configMapRef must be set"
+type ThemeSource struct {
+	// URL downloads the theme zip over HTTP(S)
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	URL string `json:"url,omitempty"`
+	// ConfigMapRef reads the theme zip from a binaryData key
+	// +optional
+	ConfigMapRef *corev1.ConfigMapKeySelector `json:"configMapRef,omitempty"`
+}
+
+// GhostThemeStatus defines the observed state of GhostTheme
+type GhostThemeStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the generation last fully reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ThemeName is the name Ghost registered the theme under
+	// +optional
+	ThemeName string `json:"themeName,omitempty"`
+	// Checksum is the sha256 of the last uploaded zip
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ghost",type=string,JSONPath=`.spec.ghostRef.name`
+// +kubebuilder:printcolumn:name="Theme",type=string,JSONPath=`.status.themeName`
+// +kubebuilder:printcolumn:name="Activated",type=string,JSONPath=`.status.conditions[?(@.type=="Activated")].status`
+
+// GhostTheme is the Schema for the ghostthemes API
+type GhostTheme struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostThemeSpec   `json:"spec,omitempty"`
+	Status GhostThemeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostThemeList contains a list of GhostTheme
+type GhostThemeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GhostTheme `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GhostTheme{}, &GhostThemeList{})
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// ConvertTo converts this Ghost to the v1 hub version. The tag is written to spec.image.tag,
+// which v1 prefers over the legacy spec.imageTag.
+func (src *Ghost) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*marketingv1.Ghost)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+
+	spec := src.Spec
+	dst.Spec = marketingv1.GhostSpec{
+		Paused:               spec.Paused,
+		ReconcileMode:        spec.ReconcileMode,
+		ResyncPeriod:         spec.ResyncPeriod,
+		Replicas:             spec.Replicas,
+		Autoscaling:          spec.Autoscaling,
+		Image:                spec.Image,
+		Exposure:             spec.Exposure,
+		Service:              spec.Service,
+		Mail:                 spec.Mail,
+		Database:             spec.Database,
+		CommonLabels:         spec.CommonLabels,
+		CommonAnnotations:    spec.CommonAnnotations,
+		Profile:              spec.Profile,
+		Environment:          spec.Environment,
+		URL:                  spec.URL,
+		Timezone:             spec.Timezone,
+		Locale:               spec.Locale,
+		Monitoring:           spec.Monitoring,
+		AccessLogs:           spec.AccessLogs,
+		Logging:              spec.Logging,
+		Indexable:            spec.Indexable,
+		EvictionProtection:   spec.EvictionProtection,
+		PodDisruptionBudget:  spec.PodDisruptionBudget,
+		NetworkPolicy:        spec.NetworkPolicy,
+		Proxy:                spec.Proxy,
+		SchedulerCheck:       spec.SchedulerCheck,
+		UpgradePolicy:        spec.UpgradePolicy,
+		Verification:         spec.Verification,
+		Staging:              spec.Staging,
+		Backup:               spec.Backup,
+		Export:               spec.Export,
+		OwnerGroup:           spec.OwnerGroup,
+		Owner:                spec.Owner,
+		AdminBootstrap:       spec.AdminBootstrap,
+		CacheWarmup:          spec.CacheWarmup,
+		Events:               spec.Events,
+		Notifications:        spec.Notifications,
+		Headless:             spec.Headless,
+		Tags:                 spec.Tags,
+		StoppedBehavior:      spec.StoppedBehavior,
+		ImageTagPolicy:       spec.ImageTagPolicy,
+		Workload:             spec.Workload,
+		MediaStorage:         spec.MediaStorage,
+		Cache:                spec.Cache,
+		Routes:               spec.Routes,
+		Redirects:            spec.Redirects,
+		AdminAPIKeySecretRef: spec.AdminAPIKeySecretRef,
+	}
+	if pod := spec.Pod; pod != nil {
+		dst.Spec.ContainerPort = pod.ContainerPort
+		dst.Spec.TerminationGracePeriodSeconds = pod.TerminationGracePeriodSeconds
+		dst.Spec.PreStopSleepSeconds = pod.PreStopSleepSeconds
+		dst.Spec.Resources = pod.Resources
+		dst.Spec.EphemeralStorage = pod.EphemeralStorage
+		dst.Spec.Probes = pod.Probes
+		dst.Spec.Scheduling = pod.Scheduling
+		dst.Spec.PriorityClassName = pod.PriorityClassName
+		dst.Spec.RuntimeClassName = pod.RuntimeClassName
+		dst.Spec.InitContainers = pod.InitContainers
+		dst.Spec.Sidecars = pod.Sidecars
+		dst.Spec.ExtraVolumes = pod.ExtraVolumes
+		dst.Spec.ExtraVolumeMounts = pod.ExtraVolumeMounts
+		dst.Spec.PodTemplateOverlay = pod.TemplateOverlay
+		dst.Spec.TrustedCABundle = pod.TrustedCABundle
+		dst.Spec.SecurityProfiles = pod.SecurityProfiles
+		dst.Spec.PodSecurityContext = pod.PodSecurityContext
+		dst.Spec.SecurityContext = pod.SecurityContext
+	}
+	if ingress := spec.Ingress; ingress != nil {
+		dst.Spec.EnableIngress = ingress.Enabled
+		if ingress.RoutingSpec != (marketingv1.RoutingSpec{}) {
+			routing := ingress.RoutingSpec
+			dst.Spec.Routing = &routing
+		}
+		if !equality.Semantic.DeepEqual(ingress.IngressSpec, marketingv1.IngressSpec{}) {
+			routes := ingress.IngressSpec
+			dst.Spec.Ingress = &routes
+		}
+	}
+	if persistence := spec.Persistence; persistence != nil {
+		volume := persistence.PersistenceSpec
+		dst.Spec.Persistence = &volume
+		dst.Spec.ContentInit = persistence.ContentInit
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1 hub version to this Ghost, the legacy spec.imageTag moves to
+// spec.image.tag unless spec.image already sets a tag
+func (dst *Ghost) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*marketingv1.Ghost)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+
+	spec := src.Spec
+	dst.Spec = GhostSpec{
+		Paused:               spec.Paused,
+		ReconcileMode:        spec.ReconcileMode,
+		ResyncPeriod:         spec.ResyncPeriod,
+		Replicas:             spec.Replicas,
+		Autoscaling:          spec.Autoscaling,
+		Image:                spec.Image,
+		Exposure:             spec.Exposure,
+		Service:              spec.Service,
+		Mail:                 spec.Mail,
+		Database:             spec.Database,
+		CommonLabels:         spec.CommonLabels,
+		CommonAnnotations:    spec.CommonAnnotations,
+		Profile:              spec.Profile,
+		Environment:          spec.Environment,
+		URL:                  spec.URL,
+		Timezone:             spec.Timezone,
+		Locale:               spec.Locale,
+		Monitoring:           spec.Monitoring,
+		AccessLogs:           spec.AccessLogs,
+		Logging:              spec.Logging,
+		Indexable:            spec.Indexable,
+		EvictionProtection:   spec.EvictionProtection,
+		PodDisruptionBudget:  spec.PodDisruptionBudget,
+		NetworkPolicy:        spec.NetworkPolicy,
+		Proxy:                spec.Proxy,
+		SchedulerCheck:       spec.SchedulerCheck,
+		UpgradePolicy:        spec.UpgradePolicy,
+		Verification:         spec.Verification,
+		Staging:              spec.Staging,
+		Backup:               spec.Backup,
+		Export:               spec.Export,
+		OwnerGroup:           spec.OwnerGroup,
+		Owner:                spec.Owner,
+		AdminBootstrap:       spec.AdminBootstrap,
+		CacheWarmup:          spec.CacheWarmup,
+		Events:               spec.Events,
+		Notifications:        spec.Notifications,
+		Headless:             spec.Headless,
+		Tags:                 spec.Tags,
+		StoppedBehavior:      spec.StoppedBehavior,
+		ImageTagPolicy:       spec.ImageTagPolicy,
+		Workload:             spec.Workload,
+		MediaStorage:         spec.MediaStorage,
+		Cache:                spec.Cache,
+		Routes:               spec.Routes,
+		Redirects:            spec.Redirects,
+		AdminAPIKeySecretRef: spec.AdminAPIKeySecretRef,
+	}
+	if spec.ImageTag != "" && (spec.Image == nil || spec.Image.Tag == "") {
+		image := marketingv1.ImageSpec{}
+		if spec.Image != nil {
+			image = *spec.Image.DeepCopy()
+		}
+		image.Tag = spec.ImageTag
+		dst.Spec.Image = &image
+	}
+	pod := PodSpec{
+		ContainerPort:                 spec.ContainerPort,
+		TerminationGracePeriodSeconds: spec.TerminationGracePeriodSeconds,
+		PreStopSleepSeconds:           spec.PreStopSleepSeconds,
+		Resources:                     spec.Resources,
+		EphemeralStorage:              spec.EphemeralStorage,
+		Probes:                        spec.Probes,
+		Scheduling:                    spec.Scheduling,
+		PriorityClassName:             spec.PriorityClassName,
+		RuntimeClassName:              spec.RuntimeClassName,
+		InitContainers:                spec.InitContainers,
+		Sidecars:                      spec.Sidecars,
+		ExtraVolumes:                  spec.ExtraVolumes,
+		ExtraVolumeMounts:             spec.ExtraVolumeMounts,
+		TemplateOverlay:               spec.PodTemplateOverlay,
+		TrustedCABundle:               spec.TrustedCABundle,
+		SecurityProfiles:              spec.SecurityProfiles,
+		PodSecurityContext:            spec.PodSecurityContext,
+		SecurityContext:               spec.SecurityContext,
+	}
+	if !equality.Semantic.DeepEqual(pod, PodSpec{}) {
+		dst.Spec.Pod = &pod
+	}
+	if spec.EnableIngress || spec.Ingress != nil || spec.Routing != nil {
+		ingress := &IngressSpec{Enabled: spec.EnableIngress}
+		if spec.Routing != nil {
+			ingress.RoutingSpec = *spec.Routing
+		}
+		if spec.Ingress != nil {
+			ingress.IngressSpec = *spec.Ingress
+		}
+		dst.Spec.Ingress = ingress
+	}
+	if spec.Persistence != nil || spec.ContentInit != nil {
+		persistence := &PersistenceSpec{ContentInit: spec.ContentInit}
+		if spec.Persistence != nil {
+			persistence.PersistenceSpec = *spec.Persistence
+		}
+		dst.Spec.Persistence = persistence
+	}
+	return nil
+}
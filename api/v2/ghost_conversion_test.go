@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Ghost conversion", func() {
+	hub := func() *marketingv1.Ghost {
+		size := resource.MustParse("5Gi")
+		return &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: "news"},
+			Spec: marketingv1.GhostSpec{
+				Paused:                        true,
+				EnableIngress:                 true,
+				Replicas:                      2,
+				ContainerPort:                 3000,
+				TerminationGracePeriodSeconds: ptr.To[int64](60),
+				PreStopSleepSeconds:           ptr.To[int32](10),
+				PriorityClassName:             "marketing-critical",
+				RuntimeClassName:              "gvisor",
+				Image:                         &marketingv1.ImageSpec{Repository: "mirror/ghost", Tag: "5.96"},
+				Ingress: &marketingv1.IngressSpec{
+					Host:       "news.example.com",
+					ExtraHosts: []string{"www.news.example.com"},
+					TLS:        &marketingv1.IngressTLSSpec{Enabled: true},
+				},
+				Routing:     &marketingv1.RoutingSpec{Mode: marketingv1.RoutingModeGatewayAPI, Gateway: &marketingv1.GatewayReference{Name: "public"}},
+				Service:     &marketingv1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, Port: 80},
+				Persistence: &marketingv1.PersistenceSpec{Size: &size, StorageClassName: ptr.To("fast"), AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+				ContentInit: &marketingv1.ContentInitSpec{Git: &marketingv1.GitContentSource{Repository: "https://example.com/theme.git"}},
+				Sidecars:    []corev1.Container{{Name: "shipper", Image: "fluent-bit"}},
+				Resources:   &corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")}},
+				Profile:     marketingv1.ProfileProduction,
+				Environment: marketingv1.NodeEnvironmentDevelopment,
+				URL:         "https://news.example.com/blog/",
+				Timezone:    "Europe/Berlin",
+				Locale:      "de",
+				OwnerGroup:  "team-news",
+				Owner:       &marketingv1.OwnerSpec{Team: "news", ExpiryReview: "2027-01-01"},
+				CacheWarmup: &marketingv1.CacheWarmupSpec{URLs: []string{"/", "/pricing/"}},
+				AdminBootstrap: &marketingv1.AdminBootstrapSpec{
+					SecretRef: corev1.LocalObjectReference{Name: "news-owner"},
+				},
+				Tags:            map[string]string{"region": "eu"},
+				StoppedBehavior: marketingv1.StoppedBehaviorRemoveIngress,
+				ImageTagPolicy:  marketingv1.ImageTagPolicyTrackPatch,
+				Workload:        &marketingv1.WorkloadSpec{Kind: marketingv1.WorkloadKindStatefulSet},
+				MediaStorage:    &marketingv1.MediaStorageSpec{S3: &marketingv1.S3MediaStorageSpec{Bucket: "media", Region: "eu-west-1"}},
+				Cache:           &marketingv1.CacheSpec{Redis: &marketingv1.RedisCacheSpec{Host: "redis.cache.svc", Port: 6379}},
+				Headless: &marketingv1.HeadlessSpec{
+					BuildHook: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "news-build"}, Key: "url"},
+				},
+			},
+			Status: marketingv1.GhostStatus{Phase: marketingv1.GhostPhaseReady, URL: "https://news.example.com"},
+		}
+	}
+
+	It("should group the flat v1 fields into blocks and back without loss", func() {
+		original := hub()
+		ghost := &Ghost{}
+		Expect(ghost.ConvertFrom(original)).To(Succeed())
+		Expect(ghost.Spec.Ingress.Enabled).To(BeTrue())
+		Expect(ghost.Spec.Ingress.Mode).To(Equal(marketingv1.RoutingModeGatewayAPI))
+		Expect(ghost.Spec.Ingress.Host).To(Equal("news.example.com"))
+		Expect(ghost.Spec.Persistence.ContentInit).To(Equal(original.Spec.ContentInit))
+		Expect(ghost.Spec.Pod.ContainerPort).To(BeEquivalentTo(3000))
+		Expect(ghost.Status).To(Equal(original.Status))
+
+		converted := &marketingv1.Ghost{}
+		Expect(ghost.ConvertTo(converted)).To(Succeed())
+		Expect(converted).To(Equal(original))
+	})
+
+	It("should move the legacy imageTag to image.tag", func() {
+		original := hub()
+		original.Spec.Image = nil
+		original.Spec.ImageTag = "alpine"
+		ghost := &Ghost{}
+		Expect(ghost.ConvertFrom(original)).To(Succeed())
+		Expect(ghost.Spec.Image).To(Equal(&marketingv1.ImageSpec{Tag: "alpine"}))
+
+		converted := &marketingv1.Ghost{}
+		Expect(ghost.ConvertTo(converted)).To(Succeed())
+		Expect(converted.Spec.ImageTag).To(BeEmpty())
+		Expect(converted.Spec.Image.Tag).To(Equal("alpine"))
+	})
+
+	It("should leave the blocks of a minimal Ghost unset", func() {
+		ghost := &Ghost{}
+		Expect(ghost.ConvertFrom(&marketingv1.Ghost{Spec: marketingv1.GhostSpec{Replicas: 1}})).To(Succeed())
+		Expect(ghost.Spec).To(Equal(GhostSpec{Replicas: 1}))
+
+		ghost.Spec.Ingress = &IngressSpec{Enabled: true}
+		converted := &marketingv1.Ghost{}
+		Expect(ghost.ConvertTo(converted)).To(Succeed())
+		Expect(converted.Spec).To(Equal(marketingv1.GhostSpec{Replicas: 1, EnableIngress: true}))
+	})
+})
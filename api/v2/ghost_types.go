@@ -0,0 +1,284 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// GhostSpec defines the desired state of Ghost. Blocks that did not change shape since v1
+// reuse the v1 types, see there for their fields.
+type GhostSpec struct {
+	// Paused stops the controller from changing the children of the Ghost, e.g. during manual
+	// maintenance on the content volume. The status still reports ReconciliationPaused.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+	// ResyncPeriod reconciles the Ghost in full at this interval even when nothing changed, to
+	// correct drift outside the watched resources such as Admin API settings or DNS records. It
+	// overrides the --resync-period of the operator, 0s turns the resync off for this Ghost.
+	// +optional
+	ResyncPeriod *metav1.Duration `json:"resyncPeriod,omitempty"`
+	// +optional
+	ReconcileMode marketingv1.ReconcileMode `json:"reconcileMode,omitempty"`
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3
+	Replicas int32 `json:"replicas"`
+	// Autoscaling hands the replica count to a HorizontalPodAutoscaler, replicas then only
+	// seeds the Deployment when it is created
+	// +optional
+	Autoscaling *marketingv1.AutoscalingSpec `json:"autoscaling,omitempty"`
+	// Image selects the Ghost image, a tag or a digest is required
+	// +optional
+	Image *marketingv1.ImageSpec `json:"image,omitempty"`
+	// Pod configures the Ghost pod and its containers
+	// +optional
+	Pod *PodSpec `json:"pod,omitempty"`
+	// Ingress routes external traffic to the Ghost through the API selected by mode
+	// +optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+	// +optional
+	Exposure *marketingv1.ExposureSpec `json:"exposure,omitempty"`
+	// +optional
+	Service *marketingv1.ServiceSpec `json:"service,omitempty"`
+	// Persistence configures the content volume and how it is seeded
+	// +optional
+	Persistence *PersistenceSpec `json:"persistence,omitempty"`
+	// +optional
+	Mail *marketingv1.MailSpec `json:"mail,omitempty"`
+	// Database selects the database Ghost stores its content in, the SQLite file of the content
+	// volume when unset
+	// +optional
+	Database *marketingv1.DatabaseSpec `json:"database,omitempty"`
+	// CommonLabels are added to every child resource and the pod template
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// CommonAnnotations are added to every child resource and the pod template
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// Profile bundles environment specific defaults, explicit fields still take precedence
+	// +optional
+	Profile marketingv1.Profile `json:"profile,omitempty"`
+	// Environment is the NODE_ENV Ghost runs in, the one of the profile when unset
+	// +optional
+	Environment marketingv1.NodeEnvironment `json:"environment,omitempty"`
+	// URL is the address Ghost builds absolute links, emails and newsletters with, derived from
+	// the first host and its TLS when unset
+	// +kubebuilder:validation:Pattern=`^https?://[^/]+(/.*)?$`
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Timezone is the IANA time zone Ghost runs in, UTC when unset
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+	// Locale is the language of the publication, which picks the default theme bundle
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8})*$`
+	// +optional
+	Locale string `json:"locale,omitempty"`
+	// +optional
+	Monitoring *marketingv1.MonitoringSpec `json:"monitoring,omitempty"`
+	// +optional
+	AccessLogs *marketingv1.AccessLogsSpec `json:"accessLogs,omitempty"`
+	// +optional
+	Logging *marketingv1.LoggingSpec `json:"logging,omitempty"`
+	// Indexable controls whether search engines may index the site
+	// +optional
+	Indexable *bool `json:"indexable,omitempty"`
+	// EvictionProtection keeps the cluster autoscaler and drains from evicting the only
+	// pod of a single replica Ghost
+	// +optional
+	EvictionProtection bool `json:"evictionProtection,omitempty"`
+	// +optional
+	PodDisruptionBudget *marketingv1.PodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+	// +optional
+	NetworkPolicy *marketingv1.NetworkPolicySpec `json:"networkPolicy,omitempty"`
+	// +optional
+	Proxy *marketingv1.ProxySpec `json:"proxy,omitempty"`
+	// +optional
+	SchedulerCheck *marketingv1.SchedulerCheckSpec `json:"schedulerCheck,omitempty"`
+	// +optional
+	UpgradePolicy *marketingv1.UpgradePolicySpec `json:"upgradePolicy,omitempty"`
+	// +optional
+	Verification *marketingv1.VerificationSpec `json:"verification,omitempty"`
+	// +optional
+	Staging *marketingv1.StagingSpec `json:"staging,omitempty"`
+	// +optional
+	Backup *marketingv1.BackupScheduleSpec `json:"backup,omitempty"`
+	// +optional
+	Export *marketingv1.ContentExportSpec `json:"export,omitempty"`
+	// OwnerGroup is the group of the team owning the Ghost, it is granted read access to the
+	// Ghost, its children and its pods and logs in the namespace
+	// +optional
+	OwnerGroup string `json:"ownerGroup,omitempty"`
+	// +optional
+	Owner *marketingv1.OwnerSpec `json:"owner,omitempty"`
+	// +optional
+	AdminBootstrap *marketingv1.AdminBootstrapSpec `json:"adminBootstrap,omitempty"`
+	// +optional
+	CacheWarmup *marketingv1.CacheWarmupSpec `json:"cacheWarmup,omitempty"`
+	// +optional
+	Events *marketingv1.EventsSpec `json:"events,omitempty"`
+	// +optional
+	Notifications *marketingv1.NotificationsSpec `json:"notifications,omitempty"`
+	// +optional
+	Headless *marketingv1.HeadlessSpec `json:"headless,omitempty"`
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// +kubebuilder:default=MaintenancePage
+	// +optional
+	StoppedBehavior marketingv1.StoppedBehavior `json:"stoppedBehavior,omitempty"`
+	// +kubebuilder:default=Pinned
+	// +optional
+	ImageTagPolicy marketingv1.ImageTagPolicy `json:"imageTagPolicy,omitempty"`
+	// +optional
+	Workload *marketingv1.WorkloadSpec `json:"workload,omitempty"`
+	// +optional
+	MediaStorage *marketingv1.MediaStorageSpec `json:"mediaStorage,omitempty"`
+	// +optional
+	Cache *marketingv1.CacheSpec `json:"cache,omitempty"`
+	// +optional
+	Routes *marketingv1.SiteConfigSource `json:"routes,omitempty"`
+	// +optional
+	Redirects *marketingv1.SiteConfigSource `json:"redirects,omitempty"`
+	// +optional
+	AdminAPIKeySecretRef *corev1.SecretKeySelector `json:"adminAPIKeySecretRef,omitempty"`
+}
+
+// PodSpec configures the Ghost pod and its containers
+type PodSpec struct {
+	// ContainerPort is the port Ghost listens on, the Service, probes and routes follow it.
+	// Defaults to 2368, Ghost's own default.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ContainerPort int32 `json:"containerPort,omitempty"`
+	// TerminationGracePeriodSeconds is how long a stopping pod may finish its requests
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+	// PreStopSleepSeconds keeps a stopping pod serving while its endpoint is removed
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=300
+	// +optional
+	PreStopSleepSeconds *int32 `json:"preStopSleepSeconds,omitempty"`
+	// Resources overrides the profile's resource preset for the Ghost container
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// +optional
+	EphemeralStorage *marketingv1.EphemeralStorageSpec `json:"ephemeralStorage,omitempty"`
+	// +optional
+	Probes *marketingv1.ProbesSpec `json:"probes,omitempty"`
+	// +optional
+	Scheduling *marketingv1.SchedulingSpec `json:"scheduling,omitempty"`
+	// PriorityClassName schedules the Ghost pods ahead of lower priority workloads
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// RuntimeClassName runs the Ghost pods in another container runtime
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+	// InitContainers run after the content seeding, the content volume is named ghost-data
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+	// Sidecars run next to Ghost in the same pod
+	// +optional
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+	// ExtraVolumes are added to the pod for sidecars and extraVolumeMounts
+	// +optional
+	ExtraVolumes []corev1.Volume `json:"extraVolumes,omitempty"`
+	// ExtraVolumeMounts are added to the Ghost container
+	// +optional
+	ExtraVolumeMounts []corev1.VolumeMount `json:"extraVolumeMounts,omitempty"`
+	// TemplateOverlay is a strategic merge patch applied to the generated pod template last
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +optional
+	TemplateOverlay *runtime.RawExtension `json:"templateOverlay,omitempty"`
+	// TrustedCABundle is a ConfigMap key holding PEM certificates Ghost trusts in addition to
+	// the public roots
+	// +optional
+	TrustedCABundle *corev1.ConfigMapKeySelector `json:"trustedCABundle,omitempty"`
+	// +optional
+	SecurityProfiles *marketingv1.SecurityProfilesSpec `json:"securityProfiles,omitempty"`
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// IngressSpec routes external traffic to the Ghost, it merges the v1 enableIngress, ingress
+// and routing fields
+type IngressSpec struct {
+	// Enabled exposes the Ghost through the routing API of mode
+	Enabled bool `json:"enabled"`
+	// Mode and Gateway select the routing API
+	marketingv1.RoutingSpec `json:",inline"`
+	// Host, ExtraHosts, TLS, ClassName and Annotations configure the route
+	marketingv1.IngressSpec `json:",inline"`
+}
+
+// PersistenceSpec configures the content volume, it merges the v1 persistence and contentInit fields
+type PersistenceSpec struct {
+	marketingv1.PersistenceSpec `json:",inline"`
+	// ContentInit seeds the content volume from a git repository or an archive before Ghost starts
+	// +optional
+	ContentInit *marketingv1.ContentInitSpec `json:"contentInit,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.replicas`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.image`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.ghostVersion`,priority=1
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:printcolumn:name="Ingress",type=boolean,JSONPath=`.spec.ingress.enabled`,priority=1
+// +kubebuilder:printcolumn:name="Team",type=string,JSONPath=`.spec.owner.team`,priority=1
+// +kubebuilder:printcolumn:name="Review",type=string,JSONPath=`.spec.owner.expiryReview`,priority=1
+// +kubebuilder:printcolumn:name="ReviewOverdue",type=boolean,JSONPath=`.status.owner.reviewOverdue`,priority=1
+
+// Ghost is the Schema for the ghosts API
+type Ghost struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GhostSpec               `json:"spec,omitempty"`
+	Status marketingv1.GhostStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GhostList contains a list of Ghost
+type GhostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Ghost `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Ghost{}, &GhostList{})
+}
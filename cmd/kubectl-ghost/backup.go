@@ -0,0 +1,63 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/controller"
+)
+
+func (c *cli) backupCommand() *cobra.Command {
+	var method string
+	command := &cobra.Command{
+		Use:   "backup <ghost>",
+		Short: "Create a GhostBackup of a Ghost to the destination of its spec.backup",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			return c.runBackup(command.Context(), command.OutOrStdout(), args[0], method)
+		},
+	}
+	command.Flags().StringVar(&method, "method", "", "Backup method, Volume or Export, the one of spec.backup when empty")
+	return command
+}
+
+// runBackup creates a GhostBackup of a Ghost the way its backup schedule would, on demand
+func (c *cli) runBackup(ctx context.Context, out io.Writer, name, method string) error {
+	_, kubeClient, _, ghost, err := c.getGhost(ctx, name)
+	if err != nil {
+		return err
+	}
+	backup, err := controller.OnDemandBackup(ghost)
+	if err != nil {
+		return err
+	}
+	if method != "" {
+		backup.Spec.Method = marketingv1.BackupMethod(method)
+	}
+	if err := kubeClient.Create(ctx, backup); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "GhostBackup %s/%s created, follow it with kubectl get ghostbackup -n %s %s -w\n",
+		backup.Namespace, backup.Name, backup.Namespace, backup.Name)
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// tagSelector selects the Ghosts carrying every tag, given as key:value or as a bare key for any value
+func tagSelector(tags []string) (labels.Selector, error) {
+	selector := labels.NewSelector()
+	for _, tag := range tags {
+		key, value, hasValue := strings.Cut(tag, ":")
+		op, values := selection.Exists, []string(nil)
+		if hasValue {
+			op, values = selection.Equals, []string{value}
+		}
+		requirement, err := labels.NewRequirement(marketingv1.TagLabelPrefix+key, op, values)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag %q: %w", tag, err)
+		}
+		selector = selector.Add(*requirement)
+	}
+	return selector, nil
+}
+
+func (c *cli) listCommand() *cobra.Command {
+	var tags []string
+	var allNamespaces bool
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "Print the Ghosts, narrowed to those carrying every --tag key:value",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, _ []string) error {
+			return c.runList(command.Context(), command.OutOrStdout(), tags, allNamespaces)
+		},
+	}
+	command.Flags().StringArrayVar(&tags, "tag", nil, "Tag the Ghosts carry as key:value, or key for any value, may be repeated")
+	command.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List the Ghosts of every namespace")
+	return command
+}
+
+// runList prints the Ghosts of the selected namespace, or of all, carrying all of the given tags
+func (c *cli) runList(ctx context.Context, out io.Writer, tags []string, allNamespaces bool) error {
+	selector, err := tagSelector(tags)
+	if err != nil {
+		return err
+	}
+	namespace := ""
+	if !allNamespaces {
+		if namespace, err = c.namespace(); err != nil {
+			return err
+		}
+	}
+
+	_, kubeClient, _, err := c.connect()
+	if err != nil {
+		return err
+	}
+	ghosts := &marketingv1.GhostList{}
+	if err := kubeClient.List(ctx, ghosts, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	if len(ghosts.Items) == 0 {
+		fmt.Fprintln(out, "No Ghosts found")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tPHASE\tVERSION\tTAGS")
+	for _, ghost := range ghosts.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ghost.Namespace, ghost.Name, ghost.Status.Phase, ghost.Status.GhostVersion, formatTags(ghost.Spec.Tags))
+	}
+	return w.Flush()
+}
+
+// formatTags renders tags sorted by key, in the key:value form --tag takes
+func formatTags(tags map[string]string) string {
+	entries := make([]string, 0, len(tags))
+	for key, value := range tags {
+		entries = append(entries, key+":"+value)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/jiaqi-yin/ghost-controller/internal/controller"
+)
+
+// logsOptions are the flags of kubectl ghost logs
+type logsOptions struct {
+	follow   bool
+	tail     int64
+	previous bool
+}
+
+func (c *cli) logsCommand() *cobra.Command {
+	options := logsOptions{}
+	command := &cobra.Command{
+		Use:   "logs <ghost>",
+		Short: "Print the logs of the Ghost container of a Ghost pod",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			return c.runLogs(command.Context(), command.OutOrStdout(), args[0], options)
+		},
+	}
+	command.Flags().BoolVarP(&options.follow, "follow", "f", false, "Stream the logs until interrupted")
+	command.Flags().Int64Var(&options.tail, "tail", -1, "Lines of recent log to print, all when negative")
+	command.Flags().BoolVarP(&options.previous, "previous", "p", false, "Print the logs of the previous, crashed container")
+	return command
+}
+
+// runLogs prints the logs of the Ghost container of a ready Ghost pod, or of any of its pods
+// when none is ready
+func (c *cli) runLogs(ctx context.Context, out io.Writer, name string, options logsOptions) error {
+	_, _, clientset, ghost, err := c.getGhost(ctx, name)
+	if err != nil {
+		return err
+	}
+	service, err := clientset.CoreV1().Services(ghost.Namespace).Get(ctx, controller.ServiceName(ghost), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	pods, err := clientset.CoreV1().Pods(ghost.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String(),
+	})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pod behind Service %s/%s", service.Namespace, service.Name)
+	}
+	pod := readyPod(pods.Items)
+	if pod == nil {
+		pod = &pods.Items[0]
+	}
+
+	logOptions := &corev1.PodLogOptions{Container: controller.GhostContainerName, Follow: options.follow, Previous: options.previous}
+	if options.tail >= 0 {
+		logOptions.TailLines = &options.tail
+	}
+	logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	_, err = io.Copy(out, logs)
+	return err
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-ghost is a kubectl plugin for operating Ghost instances managed by the controller,
+// installed on the PATH it runs as kubectl ghost.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/controller"
+)
+
+func main() {
+	if err := newRootCommand(newClients).Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// clientsFunc connects to the cluster of a rest config
+type clientsFunc func(cfg *rest.Config) (client.Client, kubernetes.Interface, error)
+
+// cli is what the commands share, the cluster the kubeconfig flags select
+type cli struct {
+	clientConfig clientcmd.ClientConfig
+	// newClients is swapped for fake clients by the tests
+	newClients clientsFunc
+}
+
+// newRootCommand builds kubectl ghost with the kubeconfig flags of kubectl, --namespace/-n
+// defaulting to the namespace of the current context. The flags may follow the Ghost name.
+func newRootCommand(clients clientsFunc) *cobra.Command {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{}
+	c := &cli{
+		clientConfig: clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides),
+		newClients:   clients,
+	}
+	root := &cobra.Command{
+		Use:           "kubectl ghost",
+		Short:         "Operate the Ghost instances managed by the controller",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&loadingRules.ExplicitPath, clientcmd.RecommendedConfigPathFlag, "", "Path to the kubeconfig file")
+	clientcmd.BindOverrideFlags(overrides, flags, clientcmd.RecommendedConfigOverrideFlags(""))
+	root.AddCommand(
+		c.listCommand(),
+		c.statusCommand(),
+		c.exportCommand(),
+		c.childrenCommand(),
+		c.backupCommand(),
+		c.openCommand(),
+		c.logsCommand(),
+	)
+	return root
+}
+
+// namespace is the namespace of --namespace, else the one of the current kubeconfig context
+func (c *cli) namespace() (string, error) {
+	namespace, _, err := c.clientConfig.Namespace()
+	return namespace, err
+}
+
+// connect connects to the cluster of the kubeconfig flags
+func (c *cli) connect() (*rest.Config, client.Client, kubernetes.Interface, error) {
+	cfg, err := c.clientConfig.ClientConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	kubeClient, clientset, err := c.newClients(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cfg, kubeClient, clientset, nil
+}
+
+// getGhost connects to the cluster and reads the named Ghost of the selected namespace
+func (c *cli) getGhost(ctx context.Context, name string) (*rest.Config, client.Client, kubernetes.Interface, *marketingv1.Ghost, error) {
+	namespace, err := c.namespace()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	cfg, kubeClient, clientset, err := c.connect()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, ghost); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return cfg, kubeClient, clientset, ghost, nil
+}
+
+func (c *cli) exportCommand() *cobra.Command {
+	var templateDir string
+	command := &cobra.Command{
+		Use:   "export <ghost>",
+		Short: "Print the child manifests the operator renders for a Ghost",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			return c.runExport(command.Context(), command.OutOrStdout(), args[0], templateDir)
+		},
+	}
+	command.Flags().StringVar(&templateDir, "manifest-template-dir", "", "Site-specific manifest templates used by the operator")
+	return command
+}
+
+func (c *cli) runExport(ctx context.Context, out io.Writer, name, templateDir string) error {
+	if err := controller.LoadManifestTemplates(templateDir); err != nil {
+		return err
+	}
+	cfg, _, _, ghost, err := c.getGhost(ctx, name)
+	if err != nil {
+		return err
+	}
+	routeAPIAvailable, err := controller.RouteAPIAvailable(cfg)
+	if err != nil {
+		return err
+	}
+	monitoringAPIAvailable, err := controller.MonitoringAPIAvailable(cfg)
+	if err != nil {
+		return err
+	}
+	manifests, err := controller.RenderManifests(ghost, routeAPIAvailable, monitoringAPIAvailable)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(manifests)
+	return err
+}
+
+func (c *cli) childrenCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "children <ghost>",
+		Short: "Print the children the operator manages for a Ghost and their latest error",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			return c.runChildren(command.Context(), command.OutOrStdout(), args[0])
+		},
+	}
+}
+
+func (c *cli) runChildren(ctx context.Context, out io.Writer, name string) error {
+	_, _, _, ghost, err := c.getGhost(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(ghost.Status.Children) == 0 {
+		fmt.Fprintln(out, "No children recorded")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tREADY\tHASH\tLAST ERROR\tSEEN")
+	for _, child := range ghost.Status.Children {
+		seen := ""
+		if child.LastErrorTime != nil {
+			seen = child.LastErrorTime.UTC().Format(time.RFC3339)
+		}
+		lastError := child.LastError
+		if child.Reason != "" {
+			lastError = child.Reason + ": " + lastError
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%s\n", child.Kind, child.Name, child.Ready, child.LastAppliedHash, lastError, seen)
+	}
+	return w.Flush()
+}
+
+// newClients connects to the cluster with the Ghost types registered
+func newClients(cfg *rest.Config) (client.Client, kubernetes.Interface, error) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(marketingv1.AddToScheme(scheme))
+	kubeClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kubeClient, clientset, nil
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/controller"
+)
+
+// contextNamespace is the namespace of the current context of the test kubeconfig
+const contextNamespace = "marketing"
+
+// writeKubeconfig writes a kubeconfig whose current context points at server in contextNamespace
+func writeKubeconfig(t *testing.T, server string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+users:
+- name: test
+  user:
+    token: test
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+    namespace: %s
+current-context: test
+`, server, contextNamespace)
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fakeCluster serves the commands from fake clients seeded with objects
+type fakeCluster struct {
+	client    client.Client
+	clientset *kubernetesfake.Clientset
+}
+
+func newFakeCluster(ghosts []client.Object, objects ...runtime.Object) *fakeCluster {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(marketingv1.AddToScheme(scheme))
+	return &fakeCluster{
+		client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(ghosts...).Build(),
+		clientset: kubernetesfake.NewSimpleClientset(objects...),
+	}
+}
+
+func (f *fakeCluster) clients(*rest.Config) (client.Client, kubernetes.Interface, error) {
+	return f.client, f.clientset, nil
+}
+
+// run runs kubectl ghost with args against the cluster, it returns what the command printed
+func (f *fakeCluster) run(t *testing.T, server string, args ...string) (string, error) {
+	t.Helper()
+	root := newRootCommand(f.clients)
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(out)
+	root.SetArgs(append([]string{"--kubeconfig", writeKubeconfig(t, server)}, args...))
+	err := root.Execute()
+	return out.String(), err
+}
+
+func ghostIn(namespace, name string) *marketingv1.Ghost {
+	return &marketingv1.Ghost{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1},
+	}
+}
+
+func TestNamespaceFlags(t *testing.T) {
+	g := NewWithT(t)
+	cluster := newFakeCluster([]client.Object{ghostIn(contextNamespace, "blog"), ghostIn("staging", "blog")})
+
+	out, err := cluster.run(t, "https://127.0.0.1:6443", "status", "blog")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(MatchRegexp(`Name:\s+` + contextNamespace + `/blog`))
+
+	for _, args := range [][]string{
+		{"status", "blog", "-n", "staging"},
+		{"status", "-n", "staging", "blog"},
+		{"--namespace", "staging", "status", "blog"},
+		{"status", "blog", "--namespace=staging"},
+	} {
+		out, err := cluster.run(t, "https://127.0.0.1:6443", args...)
+		g.Expect(err).NotTo(HaveOccurred(), "%v", args)
+		g.Expect(out).To(MatchRegexp(`Name:\s+staging/blog`), "%v", args)
+	}
+
+	_, err = cluster.run(t, "https://127.0.0.1:6443", "status", "blog", "-n", "production")
+	g.Expect(err).To(MatchError(ContainSubstring("not found")))
+}
+
+func TestArguments(t *testing.T) {
+	g := NewWithT(t)
+	cluster := newFakeCluster(nil)
+	for _, command := range []string{"status", "export", "children", "backup", "open", "logs"} {
+		_, err := cluster.run(t, "https://127.0.0.1:6443", command)
+		g.Expect(err).To(MatchError(ContainSubstring("accepts 1 arg(s), received 0")), command)
+		_, err = cluster.run(t, "https://127.0.0.1:6443", command, "blog", "other")
+		g.Expect(err).To(MatchError(ContainSubstring("accepts 1 arg(s), received 2")), command)
+	}
+	_, err := cluster.run(t, "https://127.0.0.1:6443", "list", "blog")
+	g.Expect(err).To(MatchError(ContainSubstring("unknown command")))
+	_, err = cluster.run(t, "https://127.0.0.1:6443", "status", "blog", "--bogus")
+	g.Expect(err).To(MatchError(ContainSubstring("unknown flag: --bogus")))
+	_, err = cluster.run(t, "https://127.0.0.1:6443", "list", "--tag", "team:")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = cluster.run(t, "https://127.0.0.1:6443", "list", "--tag", "bad key")
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid tag "bad key"`)))
+}
+
+func TestList(t *testing.T) {
+	g := NewWithT(t)
+	tagged := ghostIn(contextNamespace, "blog")
+	tagged.Labels = map[string]string{marketingv1.TagLabelPrefix + "team": "growth"}
+	tagged.Spec.Tags = map[string]string{"team": "growth"}
+	cluster := newFakeCluster([]client.Object{tagged, ghostIn(contextNamespace, "docs"), ghostIn("staging", "blog")})
+
+	out, err := cluster.run(t, "https://127.0.0.1:6443", "list")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(MatchRegexp(contextNamespace + `\s+blog`))
+	g.Expect(out).To(MatchRegexp(contextNamespace + `\s+docs`))
+	g.Expect(out).NotTo(ContainSubstring("staging"))
+
+	out, err = cluster.run(t, "https://127.0.0.1:6443", "list", "-A")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(ContainSubstring("staging"))
+
+	out, err = cluster.run(t, "https://127.0.0.1:6443", "list", "--tag", "team:growth")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(ContainSubstring("team:growth"))
+	g.Expect(out).NotTo(ContainSubstring("docs"))
+
+	out, err = cluster.run(t, "https://127.0.0.1:6443", "list", "-n", "production")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(Equal("No Ghosts found\n"))
+}
+
+func TestStatus(t *testing.T) {
+	g := NewWithT(t)
+	ghost := ghostIn(contextNamespace, "blog")
+	ghost.Status = marketingv1.GhostStatus{
+		Phase: marketingv1.GhostPhaseReady,
+		URL:   "https://blog.example.com",
+		Conditions: []metav1.Condition{{
+			Type: "GhostReady", Status: metav1.ConditionTrue, Reason: "Ready", Message: "Every replica\nis serving",
+			LastTransitionTime: metav1.Now(),
+		}},
+	}
+	cluster := newFakeCluster([]client.Object{ghost})
+
+	out, err := cluster.run(t, "https://127.0.0.1:6443", "status", "blog")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(MatchRegexp(`URL:\s+https://blog.example.com`))
+	g.Expect(out).To(ContainSubstring("GhostReady"))
+	g.Expect(out).To(ContainSubstring("Every replica is serving"))
+}
+
+func TestChildren(t *testing.T) {
+	g := NewWithT(t)
+	ghost := ghostIn(contextNamespace, "blog")
+	cluster := newFakeCluster([]client.Object{ghost})
+	out, err := cluster.run(t, "https://127.0.0.1:6443", "children", "blog")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(Equal("No children recorded\n"))
+
+	ghost.Status.Children = []marketingv1.ChildStatus{{Kind: "Deployment", Name: "ghost-deployment-blog", Ready: true, LastAppliedHash: "abc"}}
+	cluster = newFakeCluster([]client.Object{ghost})
+	out, err = cluster.run(t, "https://127.0.0.1:6443", "children", "blog")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(MatchRegexp(`Deployment\s+ghost-deployment-blog\s+true\s+abc`))
+}
+
+func TestBackup(t *testing.T) {
+	g := NewWithT(t)
+	cluster := newFakeCluster([]client.Object{ghostIn(contextNamespace, "blog")})
+	_, err := cluster.run(t, "https://127.0.0.1:6443", "backup", "blog")
+	g.Expect(err).To(MatchError(ContainSubstring("no spec.backup.destination")))
+
+	ghost := ghostIn(contextNamespace, "blog")
+	ghost.Spec.Backup = &marketingv1.BackupScheduleSpec{Destination: &marketingv1.BackupDestination{URL: "s3://backups/blog"}}
+	cluster = newFakeCluster([]client.Object{ghost})
+	out, err := cluster.run(t, "https://127.0.0.1:6443", "backup", "blog", "--method", "Export")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(HavePrefix("GhostBackup " + contextNamespace + "/blog-"))
+	backups := &marketingv1.GhostBackupList{}
+	g.Expect(cluster.client.List(context.Background(), backups, client.InNamespace(contextNamespace))).To(Succeed())
+	g.Expect(backups.Items).To(HaveLen(1))
+	g.Expect(backups.Items[0].Spec.Method).To(Equal(marketingv1.BackupMethodExport))
+}
+
+func TestOpen(t *testing.T) {
+	g := NewWithT(t)
+	cluster := newFakeCluster([]client.Object{ghostIn(contextNamespace, "blog")})
+	_, err := cluster.run(t, "https://127.0.0.1:6443", "open", "blog")
+	g.Expect(err).To(MatchError(ContainSubstring("has no URL yet")))
+
+	ghost := ghostIn(contextNamespace, "blog")
+	ghost.Status.URL = "https://blog.example.com"
+	cluster = newFakeCluster([]client.Object{ghost})
+	out, err := cluster.run(t, "https://127.0.0.1:6443", "open", "blog")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(Equal("https://blog.example.com\n"))
+}
+
+func TestLogs(t *testing.T) {
+	g := NewWithT(t)
+	ghost := ghostIn(contextNamespace, "blog")
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: contextNamespace, Name: controller.ServiceName(ghost)},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "ghost-blog"}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: contextNamespace, Name: "ghost-blog-0", Labels: map[string]string{"app": "ghost-blog"}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+
+	cluster := newFakeCluster([]client.Object{ghost}, service)
+	_, err := cluster.run(t, "https://127.0.0.1:6443", "logs", "blog")
+	g.Expect(err).To(MatchError(ContainSubstring("no pod behind Service")))
+
+	cluster = newFakeCluster([]client.Object{ghost}, service, pod)
+	out, err := cluster.run(t, "https://127.0.0.1:6443", "logs", "blog", "--tail", "10", "-f")
+	g.Expect(err).NotTo(HaveOccurred())
+	// The fake clientset streams a fixed body
+	g.Expect(out).To(Equal("fake logs"))
+}
+
+func TestExport(t *testing.T) {
+	g := NewWithT(t)
+	// A cluster serving neither the OpenShift Route nor the Prometheus Operator API
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	cluster := newFakeCluster([]client.Object{ghostIn(contextNamespace, "blog")})
+
+	out, err := cluster.run(t, server.URL, "export", "blog")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(ContainSubstring("kind: Deployment"))
+	g.Expect(out).To(ContainSubstring("name: ghost-deployment-blog"))
+	g.Expect(out).NotTo(ContainSubstring("kind: Route"))
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/controller"
+)
+
+// openOptions are the flags of kubectl ghost open
+type openOptions struct {
+	localPort int
+	tokenTTL  time.Duration
+	browser   bool
+}
+
+func (c *cli) openCommand() *cobra.Command {
+	options := openOptions{}
+	command := &cobra.Command{
+		Use:   "open <ghost>",
+		Short: "Print the URL of a Ghost, port-forwarding to it in PortForwardOnly exposure mode",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			return c.runOpen(command.Context(), command.OutOrStdout(), args[0], options)
+		},
+	}
+	command.Flags().IntVar(&options.localPort, "port", 0, "Local port to listen on, a free one when 0")
+	command.Flags().DurationVar(&options.tokenTTL, "token-ttl", time.Hour, "Lifetime of the port-forward token, at least 10m")
+	command.Flags().BoolVar(&options.browser, "browser", false, "Open the URL in the default browser as well")
+	return command
+}
+
+// runOpen prints the URL the operator resolved for a Ghost. A Ghost in PortForwardOnly exposure
+// mode is port-forwarded to with a token of the ServiceAccount the operator manages for it, until
+// interrupted.
+func (c *cli) runOpen(ctx context.Context, out io.Writer, name string, options openOptions) error {
+	cfg, kubeClient, _, ghost, err := c.getGhost(ctx, name)
+	if err != nil {
+		return err
+	}
+	if ghost.Spec.Exposure == nil || ghost.Spec.Exposure.Mode != marketingv1.ExposureModePortForwardOnly {
+		if ghost.Status.URL == "" {
+			return fmt.Errorf("ghost %s/%s has no URL yet, see kubectl ghost status", ghost.Namespace, ghost.Name)
+		}
+		fmt.Fprintln(out, ghost.Status.URL)
+		if options.browser {
+			return openBrowser(ghost.Status.URL)
+		}
+		return nil
+	}
+
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Namespace: ghost.Namespace,
+		Name:      controller.PortForwardServiceAccountName(ghost),
+	}}
+	tokenRequest := &authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{
+		ExpirationSeconds: ptr.To(int64(options.tokenTTL.Seconds())),
+	}}
+	if err := kubeClient.SubResource("token").Create(ctx, serviceAccount, tokenRequest); err != nil {
+		return fmt.Errorf("requesting a port-forward token: %w", err)
+	}
+	// Everything from here on runs with the port-forward token only
+	tokenCfg := rest.AnonymousClientConfig(cfg)
+	tokenCfg.BearerToken = tokenRequest.Status.Token
+	clientset, err := kubernetes.NewForConfig(tokenCfg)
+	if err != nil {
+		return err
+	}
+
+	service, err := clientset.CoreV1().Services(ghost.Namespace).Get(ctx, controller.ServiceName(ghost), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	pods, err := clientset.CoreV1().Pods(ghost.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String(),
+	})
+	if err != nil {
+		return err
+	}
+	pod := readyPod(pods.Items)
+	if pod == nil {
+		return fmt.Errorf("no ready pod behind Service %s/%s", service.Namespace, service.Name)
+	}
+	remotePort, err := containerPort(pod, service.Spec.Ports[0].TargetPort)
+	if err != nil {
+		return err
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(tokenCfg)
+	if err != nil {
+		return err
+	}
+	url := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(stopCh)
+	}()
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"localhost"},
+		[]string{fmt.Sprintf("%d:%d", options.localPort, remotePort)}, stopCh, readyCh, io.Discard, os.Stderr)
+	if err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- forwarder.ForwardPorts() }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-readyCh:
+	}
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		return err
+	}
+	localURL := fmt.Sprintf("http://localhost:%d", ports[0].Local)
+	fmt.Fprintf(out, "Ghost %s/%s is served at %s, press Ctrl+C to stop\n", ghost.Namespace, ghost.Name, localURL)
+	if options.browser {
+		if err := openBrowser(localURL); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+	}
+	return <-errCh
+}
+
+// openBrowser opens the URL with the default browser of the desktop
+func openBrowser(url string) error {
+	command := "xdg-open"
+	switch runtime.GOOS {
+	case "darwin":
+		command = "open"
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	}
+	return exec.Command(command, url).Start()
+}
+
+// readyPod returns a running pod passing its readiness probe, nil when there is none
+func readyPod(pods []corev1.Pod) *corev1.Pod {
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodRunning || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				return pod
+			}
+		}
+	}
+	return nil
+}
+
+// containerPort resolves the target port of a Service on one of its pods
+func containerPort(pod *corev1.Pod, target intstr.IntOrString) (int32, error) {
+	if target.Type == intstr.Int {
+		return target.IntVal, nil
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == target.StrVal {
+				return port.ContainerPort, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("pod %s/%s has no port named %s", pod.Namespace, pod.Name, target.StrVal)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+func (c *cli) statusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status <ghost>",
+		Short: "Print the status and conditions of a Ghost",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			return c.runStatus(command.Context(), command.OutOrStdout(), args[0])
+		},
+	}
+}
+
+// runStatus prints the status the operator reports for a Ghost, followed by its conditions
+func (c *cli) runStatus(ctx context.Context, out io.Writer, name string) error {
+	_, _, _, ghost, err := c.getGhost(ctx, name)
+	if err != nil {
+		return err
+	}
+	status := ghost.Status
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s/%s\n", ghost.Namespace, ghost.Name)
+	fmt.Fprintf(w, "Phase:\t%s\n", status.Phase)
+	fmt.Fprintf(w, "URL:\t%s\n", status.URL)
+	for _, address := range status.Addresses {
+		fmt.Fprintf(w, "Address:\t%s %s\n", address.Type, address.Value)
+	}
+	image := status.Image
+	if status.GhostVersion != "" {
+		image += " (Ghost " + status.GhostVersion + ")"
+	}
+	fmt.Fprintf(w, "Image:\t%s\n", image)
+	fmt.Fprintf(w, "Replicas:\t%d/%d ready\n", status.ReadyReplicas, status.Replicas)
+	if status.ObservedGeneration != ghost.Generation {
+		fmt.Fprintf(w, "Generation:\t%d, the operator observed %d\n", ghost.Generation, status.ObservedGeneration)
+	}
+	for _, change := range status.PendingChanges {
+		fmt.Fprintf(w, "Pending:\t%s\n", change)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(status.Conditions) == 0 {
+		fmt.Fprintln(out, "\nNo conditions reported")
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tSTATUS\tREASON\tAGE\tMESSAGE")
+	for _, condition := range status.Conditions {
+		age := duration.HumanDuration(time.Since(condition.LastTransitionTime.Time))
+		// A multi line message would break the table
+		message := strings.ReplaceAll(condition.Message, "\n", " ")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason, age, message)
+	}
+	return w.Flush()
+}
@@ -19,16 +19,25 @@ package main
 import (
 	"crypto/tls"
 	"flag"
+	"fmt"
+	"net"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -36,6 +45,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	marketingv2 "github.com/jiaqi-yin/ghost-controller/api/v2"
 	"github.com/jiaqi-yin/ghost-controller/internal/controller"
 	// +kubebuilder:scaffold:imports
 )
@@ -49,6 +59,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(marketingv1.AddToScheme(scheme))
+	utilruntime.Must(marketingv2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -58,6 +69,25 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var manifestTemplateDir string
+	var migrationNamespace string
+	var readOnly bool
+	var proxy marketingv1.ProxySpec
+	var wildcardTLSSecret, wildcardTLSDomain string
+	var reflectionNamespace string
+	var releasesURL string
+	var releasesInterval time.Duration
+	var imageTagInterval time.Duration
+	var channel string
+	var maxConcurrentReconciles int
+	var baseBackoff, maxBackoff time.Duration
+	var errorBudget int
+	var defaultThemeBundles, defaultThemeAdminAPIKey string
+	var loadSheddingCooldown time.Duration
+	var resyncPeriod time.Duration
+	var watchNamespaces, namespaceLabelSelector string
+	var shards, shard int
+	var eventReceiverAddr, eventReceiverURL string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -69,6 +99,77 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&manifestTemplateDir, "manifest-template-dir", "",
+		"Directory of site-specific manifest templates overriding the embedded defaults by file name. Only "+
+			"pvc.yaml, deployment.yaml, service.yaml and ingress.yaml can be overridden, any other manifest is rejected.")
+	flag.StringVar(&migrationNamespace, "migration-namespace", envOrDefault("POD_NAMESPACE", "ghost-controller-system"),
+		"Namespace of the ConfigMap tracking which operator upgrade migrations already ran.")
+	flag.BoolVar(&readOnly, "read-only", false,
+		"If set, the controller only reports drift in the Ghost status and never changes child resources.")
+	flag.StringVar(&proxy.HTTPProxy, "http-proxy", "",
+		"Egress proxy for plain HTTP injected into Ghost pods and backup jobs, spec.proxy overrides it per Ghost.")
+	flag.StringVar(&proxy.HTTPSProxy, "https-proxy", "",
+		"Egress proxy for HTTPS injected into Ghost pods and backup jobs, spec.proxy overrides it per Ghost.")
+	flag.StringVar(&proxy.NoProxy, "no-proxy", "",
+		"Comma separated hosts reached without the egress proxy, cluster internal names are always added.")
+	flag.StringVar(&wildcardTLSSecret, "wildcard-tls-secret", "",
+		"Namespace/name of a wildcard TLS Secret used by Ghost Ingresses without spec.ingress.tls, copied into their namespaces.")
+	flag.StringVar(&wildcardTLSDomain, "wildcard-tls-domain", "",
+		"Domain the wildcard TLS Secret covers, e.g. blogs.example.com for *.blogs.example.com.")
+	flag.StringVar(&reflectionNamespace, "reflection-namespace", "",
+		"Namespace of the Secrets copied into Ghost namespaces allowed by their "+
+			"marketing.kb.dev/reflect-to annotation, reflection is off when empty.")
+	flag.StringVar(&releasesURL, "ghost-releases-url", "",
+		"URL of the latest Ghost release as JSON with a tag_name, e.g. "+
+			"https://api.github.com/repos/TryGhost/Ghost/releases/latest. Update notifications are off when empty.")
+	flag.DurationVar(&releasesInterval, "ghost-releases-interval", 6*time.Hour,
+		"How often the latest Ghost release is looked up.")
+	flag.DurationVar(&imageTagInterval, "image-tag-interval", time.Hour,
+		"How often the registry is asked for the releases of the Ghosts tracking their image tag and for the architectures of the Ghost images.")
+	flag.StringVar(&marketingv1.DefaultImageTag, "default-ghost-version", marketingv1.DefaultImageTag,
+		"Ghost image tag the defaulting webhook records for Ghosts created without one, a minor version such as 5.96.")
+	flag.StringVar(&marketingv1.SharedStorageClassName, "shared-storage-class", "",
+		"ReadWriteMany StorageClass the defaulting webhook provisions the content volume of new Ghosts running more than one "+
+			"pod with, when they set neither a class nor access modes. Without it such Ghosts are denied.")
+	flag.StringVar(&channel, "controller-channel", controller.StableChannel,
+		"Release channel of this operator deployment, stable or canary. The canary deployment manages only the Ghosts "+
+			"annotated marketing.kb.dev/controller-channel: canary, the stable one every other Ghost and the fleet wide "+
+			"backups, restores, themes, secret reflection, migrations and webhooks.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"How many Ghosts are reconciled in parallel.")
+	flag.DurationVar(&baseBackoff, "reconcile-base-backoff", 5*time.Millisecond,
+		"Delay before retrying a failed Ghost reconcile, doubled on every further failure.")
+	flag.DurationVar(&maxBackoff, "reconcile-max-backoff", 1000*time.Second,
+		"Longest delay between two retries of a failing Ghost reconcile.")
+	flag.DurationVar(&resyncPeriod, "resync-period", 0,
+		"How often every Ghost is reconciled in full even when nothing changed, to correct drift outside the watched "+
+			"resources such as Admin API settings or DNS records. Ghosts override it with spec.resyncPeriod, 0 disables it.")
+	flag.IntVar(&errorBudget, "reconcile-error-budget", 20,
+		"Failed reconciles per hour a single Ghost may have before it is reported by a Warning event and the "+
+			"ghost_reconcile_error_budget_exceeded metric, 0 disables the budget.")
+	flag.StringVar(&defaultThemeBundles, "default-theme-bundles", "",
+		"Comma separated locale=url theme zips installed and activated on new Ghosts by their marketing.kb.dev/locale "+
+			"annotation, e.g. de=https://cdn.example.com/brand-de.zip,https://cdn.example.com/brand.zip where the "+
+			"url without a locale is the fallback. New Ghosts keep Casper when empty.")
+	flag.StringVar(&defaultThemeAdminAPIKey, "default-theme-admin-api-key", "",
+		"secret:key of the Admin API key the default theme is installed with, looked up in the namespace of each Ghost.")
+	flag.DurationVar(&loadSheddingCooldown, "load-shedding-cooldown", 5*time.Minute,
+		"How long the operator stretches its polls and defers volume stats and drift scans after the API server "+
+			"throttled it, reported by the ghost_operator_degraded_observation metric. 0 disables load shedding.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma separated namespaces the operator caches and manages, all namespaces when empty.")
+	flag.StringVar(&namespaceLabelSelector, "namespace-label-selector", "",
+		"Label selector of the namespaces whose Ghosts the operator manages, e.g. team=marketing, all when empty.")
+	flag.IntVar(&shards, "shards", 1,
+		"Number of replicas the Ghosts are sharded over. Every replica reconciles the Ghosts of its own shard "+
+			"and the leader labels the Ghosts with their shard, so run the replicas with --leader-elect.")
+	flag.IntVar(&shard, "shard", 0,
+		"Shard of this replica from 0 to --shards minus 1, e.g. the apps.kubernetes.io/pod-index label of a StatefulSet pod.")
+	flag.StringVar(&eventReceiverAddr, "event-receiver-bind-address", "0",
+		"The address the receiver of the Ghost webhooks of spec.events binds to, e.g. :8082. 0 disables the receiver.")
+	flag.StringVar(&eventReceiverURL, "event-receiver-url", "",
+		"URL Ghost reaches the event receiver at, e.g. http://ghost-controller-event-receiver.ghost-controller-system.svc:8082. "+
+			"spec.events is not registered when empty.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -77,6 +178,22 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if channel != controller.StableChannel && channel != controller.CanaryChannel {
+		setupLog.Error(fmt.Errorf("unknown channel %q", channel), "invalid controller channel")
+		os.Exit(1)
+	}
+	if maxConcurrentReconciles < 1 || baseBackoff <= 0 || maxBackoff < baseBackoff {
+		setupLog.Error(fmt.Errorf("max-concurrent-reconciles must be at least 1 and "+
+			"0 < reconcile-base-backoff <= reconcile-max-backoff"), "invalid reconcile concurrency")
+		os.Exit(1)
+	}
+	stable := channel == controller.StableChannel
+	// The deployments of both channels run side by side, each elects its own leader
+	leaderElectionID := "cd4c70cc.kb.dev"
+	if !stable {
+		leaderElectionID = channel + "." + leaderElectionID
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -120,13 +237,47 @@ func main() {
 		metricsServerOptions.FilterProvider = filters.WithAuthenticationAndAuthorization
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	// Only Warning events are summarised into the Ghost status, the rest are never cached
+	cacheOptions := cache.Options{ByObject: map[client.Object]cache.ByObject{
+		&corev1.Event{}: {Field: fields.OneTermEqualSelector("type", corev1.EventTypeWarning)},
+	}}
+	// Only the Secrets of the reflection namespace are cached with their data, every other Secret
+	// is cached without it and read on demand, with or without a reflection namespace. The Ghosts
+	// watch the metadata of the Secrets they reference everywhere.
+	secrets := map[string]cache.Config{cache.AllNamespaces: {Transform: dropSecretData}}
+	// A restricted install caches its team namespaces only, so no Ghost outside them is reconciled.
+	// The migration state is read through the API reader and the reflection namespace only adds its
+	// Secrets below.
+	if watchNamespaces != "" {
+		cacheOptions.DefaultNamespaces = map[string]cache.Config{}
+		secrets = map[string]cache.Config{}
+		for _, namespace := range strings.Split(watchNamespaces, ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				cacheOptions.DefaultNamespaces[namespace] = cache.Config{}
+				secrets[namespace] = cache.Config{Transform: dropSecretData}
+			}
+		}
+	}
+	if reflectionNamespace != "" {
+		secrets[reflectionNamespace] = cache.Config{}
+	}
+	cacheOptions.ByObject[&corev1.Secret{}] = cache.ByObject{Namespaces: secrets}
+
+	restConfig := ctrl.GetConfigOrDie()
+	var loadShedder *controller.LoadShedder
+	if loadSheddingCooldown > 0 {
+		loadShedder = &controller.LoadShedder{Cooldown: loadSheddingCooldown}
+		loadShedder.WrapConfig(restConfig)
+	}
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  cacheOptions,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "cd4c70cc.kb.dev",
+		LeaderElectionID:       leaderElectionID,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -144,19 +295,333 @@ func main() {
 		os.Exit(1)
 	}
 
+	scope, err := controller.ParseNamespaceScope(namespaceLabelSelector, mgr.GetClient())
+	if err != nil {
+		setupLog.Error(err, "invalid namespace label selector")
+		os.Exit(1)
+	}
+	sharding, err := controller.ParseSharding(shards, shard)
+	if err != nil {
+		setupLog.Error(err, "invalid sharding")
+		os.Exit(1)
+	}
+
+	if err = controller.LoadManifestTemplates(manifestTemplateDir); err != nil {
+		setupLog.Error(err, "unable to load manifest templates")
+		os.Exit(1)
+	}
+
+	routeAPIAvailable, err := controller.RouteAPIAvailable(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to detect the OpenShift Route API")
+		os.Exit(1)
+	}
+	setupLog.Info("detected OpenShift Route API", "available", routeAPIAvailable)
+	monitoringAPIAvailable, err := controller.MonitoringAPIAvailable(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to detect the prometheus-operator API")
+		os.Exit(1)
+	}
+	setupLog.Info("detected prometheus-operator API", "available", monitoringAPIAvailable)
+
+	// The kubelet stats summary is only reachable through the node proxy of the core API
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create the Kubernetes clientset")
+		os.Exit(1)
+	}
+
+	var operatorProxy *marketingv1.ProxySpec
+	if proxy.HTTPProxy != "" || proxy.HTTPSProxy != "" {
+		operatorProxy = &proxy
+	}
+
+	wildcardCertificate, err := controller.ParseWildcardCertificate(wildcardTLSSecret, wildcardTLSDomain)
+	if err != nil {
+		setupLog.Error(err, "invalid wildcard TLS configuration")
+		os.Exit(1)
+	}
+
+	defaultTheme, err := controller.ParseDefaultTheme(defaultThemeBundles, defaultThemeAdminAPIKey)
+	if err != nil {
+		setupLog.Error(err, "invalid default theme configuration")
+		os.Exit(1)
+	}
+	// One client checks the health of every Ghost and completes the setup of new ones
+	adminAPI := &controller.AdminAPISite{}
+	// Lifecycle notifications of the Ghosts and their backups are posted by one client
+	notifier := &controller.WebhookNotifier{}
+	// The releases and the architectures of the Ghost images are looked up in the same registries
+	registry := &controller.RegistryTags{Interval: imageTagInterval}
+
+	var ghostErrorBudget *controller.ErrorBudget
+	if errorBudget > 0 {
+		ghostErrorBudget = &controller.ErrorBudget{FailuresPerHour: errorBudget}
+	}
 	if err = (&controller.GhostReconciler{
-		Client:  mgr.GetClient(),
-		Scheme:  mgr.GetScheme(),
-		Recoder: mgr.GetEventRecorderFor("ghost-controller"),
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		Recoder:           mgr.GetEventRecorderFor("ghost-controller"),
+		RouteAPIAvailable: routeAPIAvailable,
+		// Without prometheus-operator the Ghosts asking for monitors report MonitoringUnavailable
+		MonitoringAPIAvailable: monitoringAPIAvailable,
+		ReadOnly:               readOnly,
+		Proxy:                  operatorProxy,
+		WildcardCertificate:    wildcardCertificate,
+		APIReader:              mgr.GetAPIReader(),
+		Channel:                channel,
+		// Ghosts are independent of each other, so they are safe to reconcile in parallel
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		BaseBackoff:             baseBackoff,
+		MaxBackoff:              maxBackoff,
+		ErrorBudget:             ghostErrorBudget,
+		VolumeStats:             &controller.KubeletVolumeStats{RESTClient: clientset.CoreV1().RESTClient()},
+		DefaultTheme:            defaultTheme,
+		Sites:                   adminAPI,
+		Setup:                   adminAPI,
+		Smoke:                   adminAPI,
+		HTTPChecks:              adminAPI,
+		Tags:                    registry,
+		Platforms:               registry,
+		Redis:                   &controller.RedisDialer{},
+		DNS:                     net.DefaultResolver,
+		EventReceiverURL:        eventReceiverURL,
+		Notifier:                notifier,
+		LoadShedder:             loadShedder,
+		Scope:                   scope,
+		Sharding:                sharding,
+		ResyncPeriod:            resyncPeriod,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Ghost")
 		os.Exit(1)
 	}
-	// if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-	if err = (&marketingv1.Ghost{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Ghost")
+	if sharding != nil {
+		if err = (&controller.GhostShardReconciler{
+			Client:   mgr.GetClient(),
+			Sharding: sharding,
+			Channel:  channel,
+			ReadOnly: readOnly,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GhostShard")
+			os.Exit(1)
+		}
+	}
+	// Backups, restores, previews and themes are fleet wide, the stable channel runs them
+	if stable {
+		if err = (&controller.GhostThemeReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Recorder:  mgr.GetEventRecorderFor("ghosttheme-controller"),
+			APIReader: mgr.GetAPIReader(),
+			Scope:     scope,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GhostTheme")
+			os.Exit(1)
+		}
+		if err = (&controller.GhostMemberReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Recorder:  mgr.GetEventRecorderFor("ghostmember-controller"),
+			APIReader: mgr.GetAPIReader(),
+			Scope:     scope,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GhostMember")
+			os.Exit(1)
+		}
+		if err = (&controller.GhostIntegrationReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Recorder:  mgr.GetEventRecorderFor("ghostintegration-controller"),
+			APIReader: mgr.GetAPIReader(),
+			Scope:     scope,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GhostIntegration")
+			os.Exit(1)
+		}
+		if err = (&controller.GhostStaticBuildReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Recorder:  mgr.GetEventRecorderFor("ghoststaticbuild-controller"),
+			APIReader: mgr.GetAPIReader(),
+			Proxy:     operatorProxy,
+			Scope:     scope,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GhostStaticBuild")
+			os.Exit(1)
+		}
+		// Backups run Jobs on new volumes, which read-only mode leaves alone
+		if !readOnly {
+			if err = (&controller.GhostBackupReconciler{
+				Client:    mgr.GetClient(),
+				Scheme:    mgr.GetScheme(),
+				Recorder:  mgr.GetEventRecorderFor("ghostbackup-controller"),
+				APIReader: mgr.GetAPIReader(),
+				Proxy:     operatorProxy,
+				Notifier:  notifier,
+				Scope:     scope,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "GhostBackup")
+				os.Exit(1)
+			}
+		}
+		// Restores scale the Ghost down and overwrite its content, which read-only mode leaves alone
+		if !readOnly {
+			if err = (&controller.GhostRestoreReconciler{
+				Client:   mgr.GetClient(),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("ghostrestore-controller"),
+				Proxy:    operatorProxy,
+				Scope:    scope,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "GhostRestore")
+				os.Exit(1)
+			}
+		}
+		// Content syncs overwrite the content of the target Ghost, which read-only mode leaves alone
+		if !readOnly {
+			if err = (&controller.GhostContentSyncReconciler{
+				Client:   mgr.GetClient(),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("ghostcontentsync-controller"),
+				Scope:    scope,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "GhostContentSync")
+				os.Exit(1)
+			}
+		}
+		// Clones snapshot a Ghost into new volumes and Ghosts, which read-only mode leaves alone
+		if !readOnly {
+			if err = (&controller.GhostCloneReconciler{
+				Client:   mgr.GetClient(),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("ghostclone-controller"),
+				Scope:    scope,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "GhostClone")
+				os.Exit(1)
+			}
+		}
+		// Previews create Ghosts and delete the expired ones with their content, which read-only mode leaves alone
+		if !readOnly {
+			if err = (&controller.GhostPreviewReconciler{
+				Client:   mgr.GetClient(),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("ghostpreview-controller"),
+				Scope:    scope,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "GhostPreview")
+				os.Exit(1)
+			}
+		}
+	}
+	if err = (&controller.EventSummaryReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Channel:   channel,
+		Scope:     scope,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GhostEventSummary")
+		os.Exit(1)
+	}
+	// Scheduled backups are created and pruned, which read-only mode leaves alone
+	if !readOnly {
+		if err = (&controller.BackupScheduleReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("ghost-backup-schedule"),
+			Channel:  channel,
+			Scope:    scope,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GhostBackupSchedule")
+			os.Exit(1)
+		}
+	}
+	if err = (&controller.OwnerReviewReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("ghost-owner-review"),
+		Channel:  channel,
+		Scope:    scope,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GhostOwnerReview")
 		os.Exit(1)
 	}
+	// Staging creates, promotes and tears down whole Ghosts, which read-only mode leaves alone
+	if !readOnly {
+		if err = (&controller.StagingReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("ghost-staging"),
+			Channel:  channel,
+			Scope:    scope,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GhostStaging")
+			os.Exit(1)
+		}
+	}
+	if reflectionNamespace != "" && !readOnly && stable {
+		if err = (&controller.SecretReflectorReconciler{
+			Client:    mgr.GetClient(),
+			Recorder:  mgr.GetEventRecorderFor("secret-reflector"),
+			APIReader: mgr.GetAPIReader(),
+			Namespace: reflectionNamespace,
+			Scope:     scope,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "SecretReflector")
+			os.Exit(1)
+		}
+	}
+	if eventReceiverAddr != "0" {
+		if err = mgr.Add(&controller.EventReceiver{
+			Client:      mgr.GetClient(),
+			APIReader:   mgr.GetAPIReader(),
+			Recorder:    mgr.GetEventRecorderFor("ghost-event-receiver"),
+			BindAddress: eventReceiverAddr,
+			ReadOnly:    readOnly,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up the event receiver")
+			os.Exit(1)
+		}
+	}
+	if releasesURL != "" {
+		releases := &controller.ReleaseWatcher{URL: releasesURL, Interval: releasesInterval}
+		if err = mgr.Add(releases); err != nil {
+			setupLog.Error(err, "unable to set up the release watcher")
+			os.Exit(1)
+		}
+		if err = (&controller.UpdateNotifierReconciler{
+			Client:   mgr.GetClient(),
+			Recorder: mgr.GetEventRecorderFor("ghost-update-notifier"),
+			Releases: releases,
+			Channel:  channel,
+			Scope:    scope,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GhostUpdateNotifier")
+			os.Exit(1)
+		}
+	}
+	switch {
+	case readOnly:
+		setupLog.Info("read-only mode, child resources will not be changed and migrations are deferred")
+	case !stable:
+		setupLog.Info("canary channel, only the Ghosts annotated for it are managed and migrations are left to the stable channel")
+	default:
+		if err = mgr.Add(&controller.MigrationRunner{
+			Client:    mgr.GetClient(),
+			Reader:    mgr.GetAPIReader(),
+			Namespace: migrationNamespace,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up migrations")
+			os.Exit(1)
+		}
+	}
+	// if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+	if stable {
+		// Also serves the conversion webhook between the Ghost versions registered in the scheme
+		if err = (&marketingv1.Ghost{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Ghost")
+			os.Exit(1)
+		}
+	}
 	// }
 	// +kubebuilder:scaffold:builder
 
@@ -175,3 +640,20 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// envOrDefault returns the environment variable or the fallback when it is unset
+func envOrDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// dropSecretData keeps the data of Secrets outside the reflection namespace out of the cache
+func dropSecretData(obj interface{}) (interface{}, error) {
+	if secret, ok := obj.(*corev1.Secret); ok {
+		secret.Data = nil
+		secret.StringData = nil
+	}
+	return obj, nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+// GhostSetup completes the initial setup of a Ghost through its Admin API
+type GhostSetup interface {
+	SetupCompleted(ctx context.Context, ghost *marketingv1.Ghost) (bool, error)
+	CompleteSetup(ctx context.Context, ghost *marketingv1.Ghost, setup ghostapi.Setup) error
+}
+
+func (s *AdminAPISite) SetupCompleted(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	return ghostapi.SetupCompleted(ctx, s.HTTPClient, ghostAdminURL(ghost))
+}
+
+func (s *AdminAPISite) CompleteSetup(ctx context.Context, ghost *marketingv1.Ghost, setup ghostapi.Setup) error {
+	return ghostapi.CompleteSetup(ctx, s.HTTPClient, ghostAdminURL(ghost), setup)
+}
+
+// adminBootstrappedCondition reports whether the initial setup of spec.adminBootstrap completed
+const adminBootstrappedCondition = "AdminBootstrapped"
+
+// bootstrapAdmin completes the initial setup of spec.adminBootstrap once Ghost answers and reports
+// it in the AdminBootstrapped condition. A failed setup leaves the rest of the Ghost reconciled.
+func (r *GhostReconciler) bootstrapAdmin(ctx context.Context, ghost *marketingv1.Ghost) {
+	if ghost.Spec.AdminBootstrap == nil || r.Setup == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, adminBootstrappedCondition)
+		return
+	}
+	if r.readOnly(ghost) {
+		return
+	}
+	if ghost.Status.AdminBootstrap == nil {
+		// The setup endpoint fails like every other one until Ghost answers, the next pass tries again
+		if ghost.Status.ReadyReplicas == 0 || applicationUnhealthy(ghost) != "" {
+			setCondition(ghost, adminBootstrappedCondition, metav1.ConditionUnknown, "WaitingForGhost",
+				"The setup runs once Ghost answers")
+			return
+		}
+		if err := r.completeSetup(ctx, ghost); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to bootstrap the Ghost admin")
+			setCondition(ghost, adminBootstrappedCondition, metav1.ConditionFalse, "SetupFailed", err.Error())
+			r.Recoder.Event(ghost, corev1.EventTypeWarning, "AdminSetupFailed", err.Error())
+			return
+		}
+	}
+	setCondition(ghost, adminBootstrappedCondition, metav1.ConditionTrue, "SetupCompleted", "Ghost is set up")
+}
+
+// completeSetup creates the owner account from the Secret of spec.adminBootstrap, a setup someone
+// already completed by hand is only recorded. The Secret is not read again afterwards.
+func (r *GhostReconciler) completeSetup(ctx context.Context, ghost *marketingv1.Ghost) error {
+	spec := ghost.Spec.AdminBootstrap
+	completed, err := r.Setup.SetupCompleted(ctx, ghost)
+	if err != nil {
+		return fmt.Errorf("checking the Ghost setup: %w", err)
+	}
+	if completed {
+		ghost.Status.AdminBootstrap = &marketingv1.AdminBootstrapStatus{CompletedAt: metav1.Now()}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "AdminSetupSkipped", "Ghost was already set up, spec.adminBootstrap is ignored")
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.secretReader().Get(ctx, client.ObjectKey{Namespace: ghost.ObjectMeta.Namespace, Name: spec.SecretRef.Name}, secret); err != nil {
+		return fmt.Errorf("admin bootstrap secret %s: %w", spec.SecretRef.Name, err)
+	}
+	setup := ghostapi.Setup{
+		Name:      string(secret.Data["name"]),
+		Email:     string(secret.Data["email"]),
+		Password:  string(secret.Data["password"]),
+		BlogTitle: spec.BlogTitle,
+	}
+	if setup.Email == "" || setup.Password == "" {
+		return fmt.Errorf("admin bootstrap secret %s needs the email and password keys", spec.SecretRef.Name)
+	}
+	if setup.Name == "" {
+		setup.Name = setup.Email
+	}
+	if setup.BlogTitle == "" {
+		setup.BlogTitle = ghost.Name
+	}
+	if err := r.Setup.CompleteSetup(ctx, ghost, setup); err != nil {
+		return fmt.Errorf("completing the Ghost setup: %w", err)
+	}
+	ghost.Status.AdminBootstrap = &marketingv1.AdminBootstrapStatus{Email: setup.Email, CompletedAt: metav1.Now()}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "AdminSetupCompleted", "Ghost was set up with the owner account "+setup.Email)
+	return nil
+}
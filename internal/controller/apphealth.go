@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+// applicationHealthyCondition reports whether Ghost itself answers, beyond its pods being ready
+const applicationHealthyCondition = "ApplicationHealthy"
+
+// applicationHealthPollInterval is how often the Admin API of a running Ghost is checked
+const applicationHealthPollInterval = time.Minute
+
+// SiteReader reads the site information a Ghost serves through its Admin API
+type SiteReader interface {
+	Site(ctx context.Context, ghost *marketingv1.Ghost) (*ghostapi.Site, error)
+}
+
+// AdminAPISite reads the site information through the Ghost Service
+type AdminAPISite struct {
+	// HTTPClient sends the requests, one with a short timeout when unset
+	HTTPClient *http.Client
+}
+
+func (s *AdminAPISite) Site(ctx context.Context, ghost *marketingv1.Ghost) (*ghostapi.Site, error) {
+	return ghostapi.GetSite(ctx, s.HTTPClient, ghostAdminURL(ghost))
+}
+
+// applicationPollInterval is how long until the application health is checked again, zero when it is not
+func (r *GhostReconciler) applicationPollInterval() time.Duration {
+	if r.Sites == nil {
+		return 0
+	}
+	return applicationHealthPollInterval
+}
+
+// observeApplication asks a Ghost with ready pods for its site information and reports in the
+// ApplicationHealthy condition whether it answered, and the version it runs in the status. Ready
+// pods only tell that Ghost serves HTTP, it still fails every request while its database is down.
+func (r *GhostReconciler) observeApplication(ctx context.Context, ghost *marketingv1.Ghost) {
+	if r.Sites == nil {
+		return
+	}
+	if stopped(ghost) {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, applicationHealthyCondition)
+		return
+	}
+	if ghost.Status.ReadyReplicas == 0 {
+		setCondition(ghost, applicationHealthyCondition, metav1.ConditionUnknown, "NoReadyReplicas",
+			"Ghost is checked once a pod is ready")
+		return
+	}
+	site, err := r.Sites.Site(ctx, ghost)
+	if err != nil {
+		setCondition(ghost, applicationHealthyCondition, metav1.ConditionFalse, "AdminAPIFailed",
+			"The Ghost Admin API site endpoint failed: "+err.Error())
+		return
+	}
+	ghost.Status.GhostVersion = site.Version
+	setCondition(ghost, applicationHealthyCondition, metav1.ConditionTrue, "AdminAPIResponding",
+		"Ghost "+site.Version+" answers on its Admin API")
+}
+
+// applicationUnhealthy returns why Ghost does not answer although its pods are ready, empty when it does
+func applicationUnhealthy(ghost *marketingv1.Ghost) string {
+	if condition := meta.FindStatusCondition(ghost.Status.Conditions, applicationHealthyCondition); condition != nil && condition.Status == metav1.ConditionFalse {
+		return condition.Message
+	}
+	return ""
+}
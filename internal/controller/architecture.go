@@ -0,0 +1,199 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// PlatformLister lists the architectures an image is published for
+type PlatformLister interface {
+	Architectures(ctx context.Context, image string) ([]string, error)
+}
+
+var _ PlatformLister = &RegistryTags{}
+
+// manifestMediaTypes are the manifests a registry may answer with, the indexes list a manifest per platform
+var manifestMediaTypes = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}, ", ")
+
+type platformList struct {
+	architectures []string
+	fetchedAt     time.Time
+}
+
+// Architectures returns the Linux architectures the index of the image lists, none for an image
+// published as a single manifest. They are looked up again once older than Interval.
+func (t *RegistryTags) Architectures(ctx context.Context, image string) ([]string, error) {
+	now := time.Now
+	if t.Now != nil {
+		now = t.Now
+	}
+	t.mu.Lock()
+	list, ok := t.platforms[image]
+	t.mu.Unlock()
+	if ok && now().Sub(list.fetchedAt) < t.Interval {
+		return list.architectures, nil
+	}
+	architectures, err := t.fetchArchitectures(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if t.platforms == nil {
+		t.platforms = map[string]platformList{}
+	}
+	t.platforms[image] = platformList{architectures: architectures, fetchedAt: now()}
+	t.mu.Unlock()
+	return architectures, nil
+}
+
+func (t *RegistryTags) fetchArchitectures(ctx context.Context, image string) ([]string, error) {
+	repository, reference := splitImage(image)
+	host, path := registryRepository(repository)
+	target := "https://" + host + "/v2/" + path + "/manifests/" + reference
+	response, err := t.get(ctx, target, "", manifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusUnauthorized {
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+		token, err := t.token(ctx, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if response, err = t.get(ctx, target, token, manifestMediaTypes); err != nil {
+			return nil, err
+		}
+	}
+	var manifest struct {
+		Manifests []struct {
+			Platform struct {
+				Architecture string `json:"architecture"`
+				OS           string `json:"os"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := decodeRegistryResponse(response, &manifest); err != nil {
+		return nil, fmt.Errorf("reading the manifest of %s: %w", image, err)
+	}
+	var architectures []string
+	for _, entry := range manifest.Manifests {
+		// Attestations are listed with an unknown platform
+		platform := entry.Platform
+		if platform.OS == "linux" && platform.Architecture != "unknown" && !slices.Contains(architectures, platform.Architecture) {
+			architectures = append(architectures, platform.Architecture)
+		}
+	}
+	return architectures, nil
+}
+
+// splitImage splits an image reference into its repository and its tag or digest
+func splitImage(image string) (string, string) {
+	if repository, digest, found := strings.Cut(image, "@"); found {
+		return repository, digest
+	}
+	tag := imageTag(image)
+	return strings.TrimSuffix(image, ":"+tag), tag
+}
+
+// imageArchitectures are the architectures the Ghost pods are scheduled onto, none when any will do
+func imageArchitectures(ghost *marketingv1.Ghost) []string {
+	if image := ghost.Spec.Image; image != nil && len(image.Architectures) > 0 {
+		if image.ArchitectureTags {
+			return []string{string(image.Architectures[0])}
+		}
+		architectures := make([]string, 0, len(image.Architectures))
+		for _, architecture := range image.Architectures {
+			architectures = append(architectures, string(architecture))
+		}
+		return architectures
+	}
+	return ghost.Status.ImageArchitectures
+}
+
+// architectureTag is the suffix spec.image.architectureTags adds to the tag of the image
+func architectureTag(ghost *marketingv1.Ghost) string {
+	if image := ghost.Spec.Image; image != nil && image.ArchitectureTags && len(image.Architectures) > 0 {
+		return "-" + string(image.Architectures[0])
+	}
+	return ""
+}
+
+// detectArchitectures records the architectures the registry publishes the Ghost image for in
+// status.imageArchitectures. A failed lookup keeps the last ones, releases rarely drop one.
+func (r *GhostReconciler) detectArchitectures(ctx context.Context, ghost *marketingv1.Ghost) {
+	if r.Platforms == nil || (ghost.Spec.Image != nil && len(ghost.Spec.Image.Architectures) > 0) {
+		ghost.Status.ImageArchitectures = nil
+		return
+	}
+	image := ghostImage(ghost)
+	architectures, err := r.Platforms.Architectures(ctx, image)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to look up the image architectures", "image", image)
+		return
+	}
+	ghost.Status.ImageArchitectures = architectures
+}
+
+// pinToArchitectures requires nodes of an architecture the image runs on, so a mixed cluster
+// never schedules Ghost where it crash-loops with an exec format error
+func pinToArchitectures(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec) {
+	architectures := imageArchitectures(ghost)
+	if len(architectures) == 0 {
+		return
+	}
+	requirement := corev1.NodeSelectorRequirement{
+		Key:      corev1.LabelArchStable,
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   architectures,
+	}
+	if podSpec.Affinity == nil {
+		podSpec.Affinity = &corev1.Affinity{}
+	}
+	if podSpec.Affinity.NodeAffinity == nil {
+		podSpec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := podSpec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+	// The terms are alternatives, the architecture is required in each of them
+	for i := range required.NodeSelectorTerms {
+		term := &required.NodeSelectorTerms[i]
+		term.MatchExpressions = append(term.MatchExpressions, requirement)
+	}
+}
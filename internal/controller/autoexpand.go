@@ -0,0 +1,67 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// autoExpandVolume grows the content claim by a step once its usage crosses the threshold, the
+// child pipeline keeps the larger size since a claim can only ever grow
+func (r *GhostReconciler) autoExpandVolume(ctx context.Context, ghost *marketingv1.Ghost, pvc *corev1.PersistentVolumeClaim, usage *VolumeUsage) error {
+	if r.readOnly(ghost) || usage == nil || ghost.Spec.Persistence == nil || ghost.Spec.Persistence.AutoExpand == nil {
+		return nil
+	}
+	autoExpand := ghost.Spec.Persistence.AutoExpand
+	requested, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok {
+		return nil
+	}
+	// The filesystem only reports the new size once the previous expansion finished
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; !ok || capacity.Cmp(requested) < 0 {
+		return nil
+	}
+	percent := usage.Used * 100 / usage.Capacity
+	if percent < int64(autoExpand.ThresholdPercent) {
+		return nil
+	}
+
+	size := requested.DeepCopy()
+	size.Add(autoExpand.Step)
+	if size.Cmp(autoExpand.MaxSize) > 0 {
+		size = autoExpand.MaxSize.DeepCopy()
+	}
+	if size.Cmp(requested) <= 0 {
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "VolumeExpansionLimitReached",
+			fmt.Sprintf("Content volume is %d%% full and already at spec.persistence.autoExpand.maxSize %s", percent, autoExpand.MaxSize.String()))
+		return nil
+	}
+	original := pvc.DeepCopy()
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	if err := r.Patch(ctx, pvc, client.MergeFrom(original)); err != nil {
+		return err
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "VolumeExpanded",
+		fmt.Sprintf("Expanded the content volume from %s to %s at %d%% usage", requested.String(), size.String(), percent))
+	return nil
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	backupJobNamePrefix = "ghost-backup-"
+	backupDir           = "/backup"
+	backupArtifact      = backupDir + "/artifact"
+	// archiveImage only needs tar and gzip
+	archiveImage = "busybox:1.36"
+	awsCLIImage  = "amazon/aws-cli:2.17.0"
+	gcloudImage  = "gcr.io/google.com/cloudsdktool/google-cloud-cli:489.0.0-alpine"
+	// gcsCredentialsPath is where the GCS service account key is mounted
+	gcsCredentialsPath = "/var/run/secrets/gcs"
+	// uploadContainer reports the artifact size in its termination message
+	uploadContainer = "upload"
+)
+
+// exportScript saves Ghost's JSON export to the artifact path
+const exportScript = adminTokenScript + `
+async function main() {
+  const res = await fetch(process.env.GHOST_URL + '/ghost/api/admin/db/', {
+    headers: {'X-Forwarded-Proto': 'https', Authorization: 'Ghost ' + adminToken(process.env.GHOST_ADMIN_API_KEY)},
+  });
+  if (!res.ok) {
+    console.error('Ghost export failed: HTTP ' + res.status);
+    process.exit(1);
+  }
+  require('fs').writeFileSync(process.env.ARTIFACT, Buffer.from(await res.arrayBuffer()));
+}
+main().catch((err) => {
+  console.error(err);
+  process.exit(1);
+});
+`
+
+// archiveScript packs the content volume into the artifact path
+const archiveScript = `tar -czf "$ARTIFACT" -C "$CONTENT_PATH" .`
+
+// backupLocation is the object URL the backup is uploaded to
+func backupLocation(backup *marketingv1.GhostBackup) string {
+	extension := ".tar.gz"
+	if backup.Spec.Method == marketingv1.BackupMethodExport {
+		extension = ".json"
+	}
+	return strings.TrimSuffix(backup.Spec.Destination.URL, "/") + "/" + backup.Namespace + "/" + backup.Name + extension
+}
+
+// generateBackupJob renders the Job taking the backup. The artifact is produced by an
+// init container into a scratch volume and uploaded by the main container.
+func generateBackupJob(backup *marketingv1.GhostBackup, ghost *marketingv1.Ghost, proxy *marketingv1.ProxySpec) *batchv1.Job {
+	scratch := corev1.VolumeMount{Name: "backup", MountPath: backupDir}
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Volumes:       []corev1.Volume{scratchVolume(ghost, "backup")},
+	}
+
+	if backup.Spec.Method == marketingv1.BackupMethodExport {
+		if ghost.Spec.Image != nil {
+			podSpec.ImagePullSecrets = ghost.Spec.Image.PullSecrets
+		}
+		podSpec.InitContainers = []corev1.Container{{
+			Name:    "export",
+			Image:   ghostImage(ghost),
+			Command: []string{"node", "-e", exportScript},
+			Env: []corev1.EnvVar{
+				{Name: "GHOST_URL", Value: fmt.Sprintf("http://%s:%d%s", childName(ghost, svcNamePrefix), servicePort(ghost), ghostPath(ghost))},
+				{Name: "GHOST_ADMIN_API_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: backup.Spec.AdminAPIKeySecretRef}},
+				{Name: "ARTIFACT", Value: backupArtifact},
+			},
+			VolumeMounts: []corev1.VolumeMount{scratch},
+		}}
+	} else {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "ghost-data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: contentClaimName(ghost),
+				ReadOnly:  true,
+			}},
+		})
+		podSpec.InitContainers = []corev1.Container{{
+			Name:    "archive",
+			Image:   archiveImage,
+			Command: []string{"sh", "-c", archiveScript},
+			Env: []corev1.EnvVar{
+				{Name: "CONTENT_PATH", Value: ghostContentPath},
+				{Name: "ARTIFACT", Value: backupArtifact},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				scratch,
+				{Name: "ghost-data", MountPath: ghostContentPath, ReadOnly: true},
+			},
+		}}
+		podSpec.Affinity = contentVolumeAffinity(ghost)
+	}
+	podSpec.Containers = []corev1.Container{generateUploadContainer(backup, scratch)}
+	podSpec.Volumes = append(podSpec.Volumes, storageVolumes(backup.Spec.Destination)...)
+	setPodProxyEnv(&podSpec, ghost, proxy)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupJobNamePrefix + backup.Name,
+			Namespace: backup.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](2),
+			Template: corev1.PodTemplateSpec{
+				Spec: podSpec,
+			},
+		},
+	}
+}
+
+// contentVolumeAffinity runs a pod mounting the content volume next to Ghost while it runs,
+// a ReadWriteOnce volume only attaches to one node
+func contentVolumeAffinity(ghost *marketingv1.Ghost) *corev1.Affinity {
+	if ghost.Spec.Replicas == 0 {
+		return nil
+	}
+	return &corev1.Affinity{PodAffinity: &corev1.PodAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": appLabel(ghost)}},
+			TopologyKey:   corev1.LabelHostname,
+		}},
+	}}
+}
+
+// generateUploadContainer copies the artifact to object storage and writes its size
+// to the termination message, where the controller picks it up for the status
+func generateUploadContainer(backup *marketingv1.GhostBackup, scratch corev1.VolumeMount) corev1.Container {
+	container := storageCopyContainer(uploadContainer, backup.Spec.Destination, backupArtifact, backupLocation(backup), scratch)
+	container.Command[len(container.Command)-1] += ` && wc -c < "$SOURCE" > /dev/termination-log`
+	container.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	return container
+}
+
+// storageCopyContainer copies source to target with the CLI of the destination's object
+// store, one of them being a local path on the scratch volume
+func storageCopyContainer(name string, destination marketingv1.BackupDestination, source, target string, scratch corev1.VolumeMount) corev1.Container {
+	container := corev1.Container{
+		Name: name,
+		Env: []corev1.EnvVar{
+			{Name: "SOURCE", Value: source},
+			{Name: "TARGET", Value: target},
+		},
+		VolumeMounts: []corev1.VolumeMount{scratch},
+	}
+
+	if isGCS(destination) {
+		container.Image = gcloudImage
+		container.Command = []string{"sh", "-c", `gcloud storage cp "$SOURCE" "$TARGET"`}
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE",
+			Value: gcsCredentialsPath + "/credentials.json",
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "gcs-credentials",
+			MountPath: gcsCredentialsPath,
+			ReadOnly:  true,
+		})
+		return container
+	}
+
+	container.Image = awsCLIImage
+	container.Command = []string{"sh", "-c", `aws s3 cp "$SOURCE" "$TARGET"`}
+	container.EnvFrom = []corev1.EnvFromSource{{
+		SecretRef: &corev1.SecretEnvSource{LocalObjectReference: destination.CredentialsSecretRef},
+	}}
+	if destination.Endpoint != "" {
+		container.Env = append(container.Env, corev1.EnvVar{Name: "AWS_ENDPOINT_URL", Value: destination.Endpoint})
+	}
+	if destination.Region != "" {
+		container.Env = append(container.Env, corev1.EnvVar{Name: "AWS_REGION", Value: destination.Region})
+	}
+	return container
+}
+
+// storageVolumes returns the volumes storageCopyContainer mounts besides the scratch volume
+func storageVolumes(destination marketingv1.BackupDestination) []corev1.Volume {
+	if !isGCS(destination) {
+		return nil
+	}
+	return []corev1.Volume{{
+		Name: "gcs-credentials",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: destination.CredentialsSecretRef.Name,
+		}},
+	}}
+}
+
+func isGCS(destination marketingv1.BackupDestination) bool {
+	return strings.HasPrefix(destination.URL, "gs://")
+}
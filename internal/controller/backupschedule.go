@@ -0,0 +1,208 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// scheduledByLabel marks the GhostBackups created by spec.backup with the Ghost name
+const scheduledByLabel = "marketing.kb.dev/scheduled-by"
+
+// BackupScheduleReconciler creates a GhostBackup on every tick of spec.backup.schedule
+// and prunes the finished ones beyond the retention count
+type BackupScheduleReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Now is the clock used for the schedule, time.Now when unset
+	Now func() time.Time
+	// Channel is the release channel of this operator deployment, stable when empty
+	Channel string
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostbackups,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile takes the backup that is due, if any, and requeues until the next run
+func (r *BackupScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, req.NamespacedName, ghost); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// Backup events reach every channel, the Ghost is left to the deployment of its own
+	if ghost.Spec.Backup == nil || ghost.Spec.Backup.Schedule == "" || !ghost.DeletionTimestamp.IsZero() || !inChannel(ghost, r.Channel) {
+		return ctrl.Result{}, nil
+	}
+
+	schedule, err := cron.ParseStandard(ghost.Spec.Backup.Schedule)
+	if err != nil {
+		r.Recorder.Event(ghost, corev1.EventTypeWarning, "InvalidBackupSchedule", err.Error())
+		return resultForError(invalidSpecError(fmt.Errorf("spec.backup.schedule: %w", err)))
+	}
+
+	now := r.now()
+	if due := lastScheduledTime(schedule, scheduleStart(ghost), now); !due.IsZero() {
+		if err := r.takeBackup(ctx, ghost, due); err != nil {
+			log.Error(err, "Failed to create scheduled GhostBackup")
+			r.Recorder.Event(ghost, corev1.EventTypeWarning, "ScheduledBackupFailed", err.Error())
+			return resultForError(err)
+		}
+	}
+	if err := r.prune(ctx, ghost); err != nil {
+		log.Error(err, "Failed to prune scheduled GhostBackups")
+		return resultForError(err)
+	}
+	return ctrl.Result{RequeueAfter: schedule.Next(now).Sub(now)}, nil
+}
+
+func (r *BackupScheduleReconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// scheduleStart is the time runs are counted from, the last scheduled backup or the Ghost creation
+func scheduleStart(ghost *marketingv1.Ghost) time.Time {
+	if ghost.Status.LastBackupTime != nil {
+		return ghost.Status.LastBackupTime.Time
+	}
+	return ghost.CreationTimestamp.Time
+}
+
+// lastScheduledTime returns the latest run after start and not after now, zero when none is due.
+// Missed runs collapse into the latest one so an outage does not start a burst of backups.
+func lastScheduledTime(schedule cron.Schedule, start, now time.Time) time.Time {
+	var last time.Time
+	for next := schedule.Next(start); !next.After(now); next = schedule.Next(next) {
+		last = next
+	}
+	return last
+}
+
+// takeBackup creates the GhostBackup for the run at the given time and records it in the Ghost status
+func (r *BackupScheduleReconciler) takeBackup(ctx context.Context, ghost *marketingv1.Ghost, at time.Time) error {
+	backup := backupFor(ghost)
+	backup.Name = fmt.Sprintf("%s-%d", ghost.Name, at.Unix())
+	backup.Labels = map[string]string{scheduledByLabel: ghost.Name}
+	if err := controllerutil.SetControllerReference(ghost, backup, r.Scheme); err != nil {
+		return err
+	}
+	// A create that succeeded before a failed status patch is retried with the same name
+	if err := r.Create(ctx, backup); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	r.Recorder.Event(ghost, corev1.EventTypeNormal, "ScheduledBackup", "Created GhostBackup "+backup.Name)
+
+	original := ghost.DeepCopy()
+	scheduled := metav1.NewTime(at)
+	ghost.Status.LastBackupTime = &scheduled
+	ghost.Status.LastBackupName = backup.Name
+	return r.Status().Patch(ctx, ghost, client.MergeFrom(original))
+}
+
+// backupFor is an unnamed GhostBackup of the Ghost to the destination of spec.backup
+func backupFor(ghost *marketingv1.Ghost) *marketingv1.GhostBackup {
+	spec := ghost.Spec.Backup
+	return &marketingv1.GhostBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ghost.Namespace},
+		Spec: marketingv1.GhostBackupSpec{
+			GhostRef:             corev1.LocalObjectReference{Name: ghost.Name},
+			Method:               spec.Method,
+			AdminAPIKeySecretRef: spec.AdminAPIKeySecretRef,
+			Destination:          *spec.Destination,
+		},
+	}
+}
+
+// OnDemandBackup is a GhostBackup taken outside the schedule of the Ghost, to the same
+// destination. It is named after the Ghost by the API server and never pruned.
+func OnDemandBackup(ghost *marketingv1.Ghost) (*marketingv1.GhostBackup, error) {
+	if ghost.Spec.Backup == nil || ghost.Spec.Backup.Destination == nil {
+		return nil, fmt.Errorf("ghost %s/%s has no spec.backup.destination to back up to", ghost.Namespace, ghost.Name)
+	}
+	backup := backupFor(ghost)
+	backup.GenerateName = ghost.Name + "-"
+	return backup, nil
+}
+
+// prune deletes finished scheduled backups beyond the retention count, newest are kept.
+// Running backups are never deleted and do not count against the retention.
+func (r *BackupScheduleReconciler) prune(ctx context.Context, ghost *marketingv1.Ghost) error {
+	backups := &marketingv1.GhostBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(ghost.Namespace), client.MatchingLabels{scheduledByLabel: ghost.Name}); err != nil {
+		return err
+	}
+	var finished []marketingv1.GhostBackup
+	for _, backup := range backups.Items {
+		if backup.Status.Phase == marketingv1.BackupPhaseSucceeded || backup.Status.Phase == marketingv1.BackupPhaseFailed {
+			finished = append(finished, backup)
+		}
+	}
+	// Names end in the schedule time, they break ties between backups created in the same second
+	sort.Slice(finished, func(i, j int) bool {
+		if !finished[i].CreationTimestamp.Equal(&finished[j].CreationTimestamp) {
+			return finished[j].CreationTimestamp.Before(&finished[i].CreationTimestamp)
+		}
+		return finished[i].Name > finished[j].Name
+	})
+	retention := int(ghost.Spec.Backup.Retention)
+	if retention < 1 {
+		retention = 1
+	}
+	for i := retention; i < len(finished); i++ {
+		if err := r.Delete(ctx, &finished[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		r.Recorder.Event(ghost, corev1.EventTypeNormal, "BackupPruned", "Deleted GhostBackup "+finished[i].Name)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.Ghost{}, builder.WithPredicates(channelPredicate(r.Channel))).
+		Owns(&marketingv1.GhostBackup{}).
+		Named("ghost-backup-schedule").
+		Complete(r)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Backup schedule", func() {
+	const namespace = "scheduled-backups"
+
+	It("should create one GhostBackup per run and prune beyond the retention", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec: marketingv1.GhostSpec{
+				ImageTag: "latest",
+				Replicas: 1,
+				Backup: &marketingv1.BackupScheduleSpec{
+					Schedule:  "0 * * * *",
+					Retention: 1,
+					Destination: &marketingv1.BackupDestination{
+						URL:                  "s3://backups/ghost",
+						CredentialsSecretRef: corev1.LocalObjectReference{Name: "s3-credentials"},
+					},
+				},
+			},
+		})).To(Succeed())
+
+		key := types.NamespacedName{Namespace: namespace, Name: "blog"}
+		ghost := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		now := ghost.CreationTimestamp.Add(3 * time.Hour)
+		reconciler := &BackupScheduleReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+			Now:      func() time.Time { return now },
+		}
+
+		By("collapsing the missed runs into a single backup")
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(result.RequeueAfter).To(BeNumerically("<=", time.Hour))
+
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		due := now.Truncate(time.Hour)
+		Expect(ghost.Status.LastBackupTime.Time).To(BeTemporally("==", due))
+		Expect(ghost.Status.LastBackupName).To(Equal(fmt.Sprintf("blog-%d", due.Unix())))
+
+		backups := &marketingv1.GhostBackupList{}
+		Expect(k8sClient.List(ctx, backups, client.InNamespace(namespace))).To(Succeed())
+		Expect(backups.Items).To(HaveLen(1))
+		first := backups.Items[0]
+		Expect(first.Spec.GhostRef.Name).To(Equal("blog"))
+		Expect(first.Spec.Destination.URL).To(Equal("s3://backups/ghost"))
+		Expect(metav1.IsControlledBy(&first, ghost)).To(BeTrue())
+
+		By("taking nothing until the next run is due")
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.List(ctx, backups, client.InNamespace(namespace))).To(Succeed())
+		Expect(backups.Items).To(HaveLen(1))
+
+		By("pruning the older finished backup once the next one finished")
+		first.Status.Phase = marketingv1.BackupPhaseSucceeded
+		Expect(k8sClient.Status().Update(ctx, &first)).To(Succeed())
+		now = now.Add(time.Hour)
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.List(ctx, backups, client.InNamespace(namespace))).To(Succeed())
+		Expect(backups.Items).To(HaveLen(2))
+
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		second := &marketingv1.GhostBackup{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ghost.Status.LastBackupName}, second)).To(Succeed())
+		second.Status.Phase = marketingv1.BackupPhaseSucceeded
+		Expect(k8sClient.Status().Update(ctx, second)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.List(ctx, backups, client.InNamespace(namespace))).To(Succeed())
+		Expect(backups.Items).To(ConsistOf(HaveField("Name", second.Name)))
+	})
+})
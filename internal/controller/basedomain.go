@@ -0,0 +1,67 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// baseDomainAnnotation on a namespace replaces the default domain the hosts of its Ghosts are
+// derived from, e.g. team-a.example.com. The controller mirrors it onto every Ghost of the
+// namespace, so the host index and kubectl ghost export see the same hosts as the controller.
+const baseDomainAnnotation = marketingv1.BaseDomainAnnotation
+
+// defaultBaseDomain is the domain of the hosts of Ghosts without ingress.host
+const defaultBaseDomain = marketingv1.DefaultBaseDomain
+
+// syncBaseDomain mirrors the base domain of the namespace onto the Ghost
+func (r *GhostReconciler) syncBaseDomain(ctx context.Context, ghost *marketingv1.Ghost) error {
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: ghost.Namespace}, namespace); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	domain := namespace.Annotations[baseDomainAnnotation]
+	if domain != "" {
+		if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+			r.Recoder.Event(ghost, corev1.EventTypeWarning, "InvalidBaseDomain", "Base domain "+domain+" of namespace "+ghost.Namespace+" is ignored: "+errs[0])
+			domain = ""
+		}
+	}
+	if ghost.Annotations[baseDomainAnnotation] == domain {
+		return nil
+	}
+	original := ghost.DeepCopy()
+	if domain == "" {
+		delete(ghost.Annotations, baseDomainAnnotation)
+	} else {
+		if ghost.Annotations == nil {
+			ghost.Annotations = map[string]string{}
+		}
+		ghost.Annotations[baseDomainAnnotation] = domain
+	}
+	if r.readOnly(ghost) {
+		// Observe the hosts of the namespace's domain without writing to the Ghost
+		return nil
+	}
+	return r.Patch(ctx, ghost, client.MergeFrom(original))
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// A BlueGreen Ghost runs its pods in one of two slots. Every slot has its own Deployment and
+// content PVC, the blue slot keeps the names the Ghost had before. A new image is rolled out to
+// the idle slot on a clone of the active content volume and the Service switches to the idle
+// slot once its pods are ready. The claim of the slot switched away from keeps the content the
+// previous image left until the next upgrade clones over it.
+const (
+	// activeSlotAnnotation names the slot the Service selects, blue when unset
+	activeSlotAnnotation = "marketing.kb.dev/active-slot"
+	// slotLabel tells the pods of the two slots apart
+	slotLabel = "marketing.kb.dev/slot"
+	blueSlot  = "blue"
+	greenSlot = "green"
+	// clonedForAnnotation records the image a slot's content PVC was cloned for
+	clonedForAnnotation = "marketing.kb.dev/cloned-for"
+	// blueGreenCondition reports the progress of a blue/green upgrade
+	blueGreenCondition = "BlueGreenUpgrade"
+)
+
+// SmokeTester requests a URL of a Ghost pod before a blue/green upgrade switches to it
+type SmokeTester interface {
+	Smoke(ctx context.Context, url string) error
+}
+
+// Smoke fails unless the URL answers with a 2xx status
+func (s *AdminAPISite) Smoke(ctx context.Context, url string) error {
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("GET %s answered %s", url, resp.Status)
+	}
+	return nil
+}
+
+// blueGreen reports whether a new image is verified in the idle slot before the Service switches to it
+func blueGreen(ghost *marketingv1.Ghost) bool {
+	policy := ghost.Spec.UpgradePolicy
+	return policy != nil && policy.Strategy == marketingv1.UpgradeStrategyBlueGreen
+}
+
+// activeSlot is the slot the Service selects
+func activeSlot(ghost *marketingv1.Ghost) string {
+	if ghost.Annotations[activeSlotAnnotation] == greenSlot {
+		return greenSlot
+	}
+	return blueSlot
+}
+
+func idleSlot(ghost *marketingv1.Ghost) string {
+	if activeSlot(ghost) == greenSlot {
+		return blueSlot
+	}
+	return greenSlot
+}
+
+// slotName names the child of a slot after the child of the blue one
+func slotName(name, slot string) string {
+	if slot == greenSlot {
+		return name + "-" + greenSlot
+	}
+	return name
+}
+
+// deploymentName is the Deployment of the active slot
+func deploymentName(ghost *marketingv1.Ghost) string {
+	return slotName(childName(ghost, deploymentNamePrefix), activeSlot(ghost))
+}
+
+// inSlot returns a copy of the Ghost rendering the children of the given slot. The content
+// volume of the slot is a clone the pods are not pinned to the node of yet.
+func inSlot(ghost *marketingv1.Ghost, slot string) *marketingv1.Ghost {
+	copied := ghost.DeepCopy()
+	metav1.SetMetaDataAnnotation(&copied.ObjectMeta, activeSlotAnnotation, slot)
+	copied.Status.ContentVolumeNodeAffinity = nil
+	return copied
+}
+
+// upgradeBlueGreen rolls a new image out to the idle slot and switches the Ghost to it once it
+// is verified, reporting whether the rest of the children have to wait meanwhile. The active
+// slot keeps serving the previous image while the upgrade is held or fails.
+func (r *GhostReconciler) upgradeBlueGreen(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	if !blueGreen(ghost) {
+		if ghost.Status.BlueGreen != nil {
+			if err := r.deleteIdleSlot(ctx, ghost); err != nil {
+				return false, err
+			}
+		}
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, blueGreenCondition)
+		ghost.Status.BlueGreen = nil
+		return false, nil
+	}
+	observed, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, deploymentName(ghost), &appsv1.Deployment{})
+	if err != nil || observed == nil {
+		// A fresh install has nothing to keep serving
+		return false, err
+	}
+	active := observed.(*appsv1.Deployment)
+	if active.Spec.Template.Labels[slotLabel] != activeSlot(ghost) || !deploymentRolledOut(active) {
+		// Pods running from before the strategy was set cannot be told apart from the idle slot
+		// by the Service, the first image they run after being labelled is rolled in place
+		return false, nil
+	}
+	if ghost.Status.BlueGreen == nil {
+		ghost.Status.BlueGreen = &marketingv1.BlueGreenStatus{}
+	}
+	image := ghostImage(ghost)
+	containers := active.Spec.Template.Spec.Containers
+	if len(containers) == 0 || containers[0].Image == image {
+		ghost.Status.BlueGreen.TargetImage = ""
+		if err := r.deleteIdleSlot(ctx, ghost); err != nil {
+			return false, err
+		}
+		setCondition(ghost, blueGreenCondition, metav1.ConditionTrue, "Active",
+			"The "+activeSlot(ghost)+" slot serves "+image)
+		return false, nil
+	}
+
+	idle := idleSlot(ghost)
+	ghost.Status.BlueGreen.TargetImage = image
+	if r.readOnly(ghost) {
+		setCondition(ghost, blueGreenCondition, metav1.ConditionFalse, "DriftDetected",
+			"The "+idle+" slot would be upgraded to "+image+", skipped in read-only mode")
+		return true, nil
+	}
+	// The Service pins the active slot before the idle one has any pods
+	service, err := desire(serviceChild{}, ghost)
+	if err != nil {
+		return true, err
+	}
+	if err := r.applyChild(ctx, ghost, service); err != nil {
+		return true, err
+	}
+	target := inSlot(ghost, idle)
+	cloned, err := r.cloneContent(ctx, ghost, target, image)
+	if err != nil || !cloned {
+		return true, err
+	}
+
+	desired, err := desire(deploymentChild{proxy: r.Proxy}, target)
+	if err != nil {
+		return true, err
+	}
+	if err := r.applyChild(ctx, ghost, desired); err != nil {
+		return true, err
+	}
+	rollout := deploymentChild{}.Status(desired)
+	if rollout.Status != metav1.ConditionTrue {
+		if rollout.Reason == "ProgressDeadlineExceeded" {
+			setCondition(ghost, blueGreenCondition, metav1.ConditionFalse, "UpgradeFailed",
+				"The "+idle+" slot failed to roll out "+image+", the "+activeSlot(ghost)+" slot keeps serving: "+rollout.Message)
+			r.Recoder.Event(ghost, corev1.EventTypeWarning, "BlueGreenFailed", "The "+idle+" slot failed to roll out "+image)
+			return true, nil
+		}
+		setCondition(ghost, blueGreenCondition, metav1.ConditionFalse, "RollingOut",
+			"Rolling "+image+" out to the "+idle+" slot: "+rollout.Message)
+		return true, nil
+	}
+	if err := r.smokeTest(ctx, ghost, target); err != nil {
+		setCondition(ghost, blueGreenCondition, metav1.ConditionFalse, "SmokeTestFailed",
+			"The "+idle+" slot runs "+image+" but "+err.Error())
+		return true, nil
+	}
+
+	original := ghost.DeepCopy()
+	metav1.SetMetaDataAnnotation(&ghost.ObjectMeta, activeSlotAnnotation, idle)
+	if err := r.Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+		return true, err
+	}
+	now := metav1.Now()
+	ghost.Status.BlueGreen.TargetImage = ""
+	ghost.Status.BlueGreen.LastSwitchTime = &now
+	// The node affinity is observed again from the volume of the new slot
+	ghost.Status.ContentVolumeNodeAffinity = nil
+	setCondition(ghost, blueGreenCondition, metav1.ConditionTrue, "Switched",
+		"The "+idle+" slot serves "+image)
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "BlueGreenSwitched", "The Service switched to "+image+" in the "+idle+" slot")
+	return false, nil
+}
+
+// cloneContent clones the active content PVC into the claim of the target slot and reports
+// whether the clone was requested. A claim left from an earlier upgrade is deleted first.
+func (r *GhostReconciler) cloneContent(ctx context.Context, ghost, target *marketingv1.Ghost, image string) (bool, error) {
+	name := contentClaimName(target)
+	observed, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, name, &corev1.PersistentVolumeClaim{})
+	if err != nil {
+		return false, err
+	}
+	if observed != nil {
+		claim := observed.(*corev1.PersistentVolumeClaim)
+		if claim.Annotations[clonedForAnnotation] == image && claim.DeletionTimestamp.IsZero() {
+			return true, nil
+		}
+		if !metav1.IsControlledBy(claim, ghost) {
+			return false, invalidSpecError(fmt.Errorf("PVC %s of the %s slot is not controlled by the Ghost", name, activeSlot(target)))
+		}
+		if claim.DeletionTimestamp.IsZero() {
+			if err := r.Delete(ctx, claim); client.IgnoreNotFound(err) != nil {
+				return false, err
+			}
+		}
+		setCondition(ghost, blueGreenCondition, metav1.ConditionFalse, "CloningContent",
+			"Waiting for PVC "+name+" of an earlier upgrade to be deleted")
+		return false, nil
+	}
+
+	source, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, contentClaimName(ghost), &corev1.PersistentVolumeClaim{})
+	if err != nil || source == nil {
+		return false, err
+	}
+	desired, err := desire(pvcChild{}, target)
+	if err != nil {
+		return false, err
+	}
+	// A clone is at least as large as its source
+	pvcChild{}.Retain(desired, source)
+	clone := desired.(*corev1.PersistentVolumeClaim)
+	clone.Annotations = withCommon(map[string]string{clonedForAnnotation: image}, clone.Annotations)
+	clone.Spec.DataSource = &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: source.GetName()}
+	if err := controllerutil.SetControllerReference(ghost, clone, r.Scheme); err != nil {
+		return false, err
+	}
+	if err := r.Create(ctx, clone, client.FieldOwner(fieldManager)); err != nil {
+		return false, err
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "BlueGreenCloneCreated", "PVC "+name+" cloned from "+source.GetName()+" for "+image)
+	return true, nil
+}
+
+// smokeTest requests spec.upgradePolicy.smokePath and spec.verification.httpCheck from a ready
+// pod of the target slot
+func (r *GhostReconciler) smokeTest(ctx context.Context, ghost, target *marketingv1.Ghost) error {
+	path := ghost.Spec.UpgradePolicy.SmokePath
+	if path == "" || r.Smoke == nil {
+		path = ""
+	}
+	check := httpCheck(ghost)
+	if r.HTTPChecks == nil {
+		check = nil
+	}
+	if path == "" && check == nil {
+		return nil
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(ghost.ObjectMeta.Namespace),
+		client.MatchingLabels{"app": appLabel(ghost), slotLabel: activeSlot(target)}); err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP == "" || !podReady(&pod) {
+			continue
+		}
+		origin := "http://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(containerPort(ghost))))
+		if path != "" {
+			if err := r.Smoke.Smoke(ctx, origin+subPath(ghost, path)); err != nil {
+				return err
+			}
+		}
+		if check != nil {
+			return r.HTTPChecks.CheckHTTP(ctx, origin+subPath(ghost, httpCheckPath(check)), *check)
+		}
+		return nil
+	}
+	return fmt.Errorf("no pod is ready for the smoke test")
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// deploymentRolledOut reports whether the Deployment runs its current pod template
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	return deploymentChild{}.Status(deployment).Status == metav1.ConditionTrue
+}
+
+// deleteIdleSlot removes the Deployment of the slot the Service does not select, its content
+// PVC is kept for rolling back
+func (r *GhostReconciler) deleteIdleSlot(ctx context.Context, ghost *marketingv1.Ghost) error {
+	if r.readOnly(ghost) {
+		return nil
+	}
+	name := slotName(childName(ghost, deploymentNamePrefix), idleSlot(ghost))
+	observed, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, name, &appsv1.Deployment{})
+	if err != nil || observed == nil || !metav1.IsControlledBy(observed, ghost) {
+		return err
+	}
+	if err := r.Delete(ctx, observed); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "BlueGreenIdleDeleted", "Deployment "+name+" of the idle slot deleted")
+	return nil
+}
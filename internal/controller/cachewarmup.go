@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	cacheWarmupNamePrefix      = "ghost-cache-warmup-"
+	defaultCacheWarmupSchedule = "*/15 * * * *"
+)
+
+// cacheWarmupScript runs on node from the Ghost image. It requests every page and the images it
+// embeds, Ghost resizes an image on its first request and serves the stored copy afterwards.
+// Unreachable pages are logged without failing the job, the next run tries again.
+const cacheWarmupScript = `
+// The pages are relative to the sub-path Ghost is served under
+const base = process.env.GHOST_URL + '/';
+const imagesPath = new URL('content/images/', base).pathname;
+const headers = {'X-Forwarded-Proto': 'https'};
+async function warm(url) {
+  const started = Date.now();
+  const res = await fetch(url, {headers});
+  const body = await res.text();
+  console.log(res.status + ' ' + url + ' in ' + (Date.now() - started) + 'ms');
+  return body;
+}
+async function main() {
+  for (const page of process.env.WARMUP_URLS.split('\n')) {
+    const url = new URL(page.replace(/^\//, ''), base);
+    try {
+      const html = await warm(url.href);
+      const images = new Set();
+      for (const [, src] of html.matchAll(/(?:src|srcset)="([^"]+)"/g)) {
+        for (const candidate of src.split(',')) {
+          const image = new URL(candidate.trim().split(' ')[0], url);
+          if (image.origin === url.origin && image.pathname.startsWith(imagesPath)) {
+            images.add(image.href);
+          }
+        }
+      }
+      for (const image of images) {
+        await warm(image);
+      }
+    } catch (err) {
+      console.error('Warming ' + url.href + ' failed: ' + err.message);
+    }
+  }
+}
+main();
+`
+
+// cacheWarmupChild manages the CronJob requesting the key pages of the Ghost
+type cacheWarmupChild struct{}
+
+func (cacheWarmupChild) Kind() string {
+	return "CacheWarmup"
+}
+
+func (cacheWarmupChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if ghost.Spec.CacheWarmup == nil {
+		return nil, nil
+	}
+	return generateDesiredCacheWarmup(ghost), nil
+}
+
+func (cacheWarmupChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, cacheWarmupNamePrefix), &batchv1.CronJob{})
+}
+
+func (cacheWarmupChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+func generateDesiredCacheWarmup(ghost *marketingv1.Ghost) *batchv1.CronJob {
+	spec := ghost.Spec.CacheWarmup
+	schedule := defaultCacheWarmupSchedule
+	if spec.Schedule != "" {
+		schedule = spec.Schedule
+	}
+	var pullSecrets []corev1.LocalObjectReference
+	if ghost.Spec.Image != nil {
+		pullSecrets = ghost.Spec.Image.PullSecrets
+	}
+
+	return &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, cacheWarmupNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   schedule,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: ptr.To[int32](1),
+			FailedJobsHistoryLimit:     ptr.To[int32](1),
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					BackoffLimit:          ptr.To[int32](0),
+					ActiveDeadlineSeconds: ptr.To[int64](600),
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							RestartPolicy:    corev1.RestartPolicyNever,
+							ImagePullSecrets: pullSecrets,
+							Containers: []corev1.Container{
+								{
+									Name:    "cache-warmup",
+									Image:   ghostImage(ghost),
+									Command: []string{"node", "-e", cacheWarmupScript},
+									Env: []corev1.EnvVar{
+										{
+											Name:  "GHOST_URL",
+											Value: fmt.Sprintf("http://%s:%d%s", childName(ghost, svcNamePrefix), servicePort(ghost), ghostPath(ghost)),
+										},
+										{
+											Name:  "WARMUP_URLS",
+											Value: strings.Join(spec.URLs, "\n"),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
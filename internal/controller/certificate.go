@@ -0,0 +1,120 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const certificateNamePrefix = "ghost-certificate-"
+const tlsSecretNamePrefix = "ghost-tls-"
+
+// cert-manager is an optional dependency, so Certificates are handled as unstructured objects
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+
+// tlsEnabled reports whether the Ghost asks for TLS termination on its Ingress
+func tlsEnabled(ghost *marketingv1.Ghost) bool {
+	return ingressEnabled(ghost) && ghost.Spec.Ingress != nil && ghost.Spec.Ingress.TLS != nil && ghost.Spec.Ingress.TLS.Enabled
+}
+
+// certificateManaged reports whether the controller should request the certificate from cert-manager
+func certificateManaged(ghost *marketingv1.Ghost) bool {
+	return tlsEnabled(ghost) && ghost.Spec.Ingress.TLS.IssuerRef != nil
+}
+
+func tlsSecretName(ghost *marketingv1.Ghost) string {
+	if ghost.Spec.Ingress.TLS.SecretName != "" {
+		return ghost.Spec.Ingress.TLS.SecretName
+	}
+	return childName(ghost, tlsSecretNamePrefix)
+}
+
+// certificateChild manages the cert-manager Certificate for the Ingress hosts
+type certificateChild struct{}
+
+func (certificateChild) Kind() string {
+	return "Certificate"
+}
+
+func (certificateChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !certificateManaged(ghost) {
+		return nil, nil
+	}
+	return generateDesiredCertificate(ghost), nil
+}
+
+func (certificateChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, certificateNamePrefix), newUnstructured(certificateGVK))
+}
+
+// Status mirrors the Certificate's Ready condition into the Ghost status
+func (certificateChild) Status(observed client.Object) *metav1.Condition {
+	certificate := observed.(*unstructured.Unstructured)
+	conditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+		if reason == "" {
+			reason = "Unknown"
+		}
+		status := metav1.ConditionFalse
+		if condition["status"] == string(metav1.ConditionTrue) {
+			status = metav1.ConditionTrue
+		}
+		return &metav1.Condition{Type: "CertificateReady", Status: status, Reason: reason, Message: message}
+	}
+	return &metav1.Condition{Type: "CertificateReady", Status: metav1.ConditionFalse, Reason: "Issuing", Message: "Waiting for cert-manager to issue the certificate"}
+}
+
+func generateDesiredCertificate(ghost *marketingv1.Ghost) *unstructured.Unstructured {
+	issuerRef := ghost.Spec.Ingress.TLS.IssuerRef
+	dnsNames := []interface{}{}
+	for _, host := range append(ingressHosts(ghost), redirectHosts(ghost)...) {
+		dnsNames = append(dnsNames, host)
+	}
+
+	certificate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"secretName": tlsSecretName(ghost),
+				"dnsNames":   dnsNames,
+				"issuerRef": map[string]interface{}{
+					"name":  issuerRef.Name,
+					"kind":  issuerRef.Kind,
+					"group": issuerRef.Group,
+				},
+			},
+		},
+	}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetName(childName(ghost, certificateNamePrefix))
+	certificate.SetNamespace(ghost.ObjectMeta.Namespace)
+	return certificate
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// controllerChannelAnnotation assigns a Ghost to the operator deployment of a release channel,
+// so a new operator version can be trialed on a few instances before the whole fleet gets it
+const controllerChannelAnnotation = "marketing.kb.dev/controller-channel"
+
+const (
+	// StableChannel manages every Ghost without a channel annotation
+	StableChannel = "stable"
+	// CanaryChannel manages the Ghosts opted into trialing the next operator version
+	CanaryChannel = "canary"
+)
+
+// ghostChannel is the channel of the operator deployment managing the object
+func ghostChannel(obj client.Object) string {
+	if channel := obj.GetAnnotations()[controllerChannelAnnotation]; channel != "" {
+		return channel
+	}
+	return StableChannel
+}
+
+// inChannel reports whether the operator deployment of channel manages the object, an empty
+// channel is the stable one
+func inChannel(obj client.Object, channel string) bool {
+	if channel == "" {
+		channel = StableChannel
+	}
+	return ghostChannel(obj) == channel
+}
+
+// channelPredicate keeps the Ghost events of the other channels away from the controller
+func channelPredicate(channel string) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return inChannel(obj, channel)
+	})
+}
@@ -0,0 +1,587 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// childReconciler manages one kind of child resource owned by a Ghost. New child
+// types plug into the pipeline by implementing it and registering in childStages.
+type childReconciler interface {
+	// Kind names the child in logs, events and conditions
+	Kind() string
+	// Desire renders the wanted object, or nil when the Ghost does not want this child
+	Desire(ghost *marketingv1.Ghost) (client.Object, error)
+	// Observe fetches the live object, or nil when it does not exist
+	Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error)
+	// Status reports the readiness of the live object, nil when the child has nothing to report
+	Status(observed client.Object) *metav1.Condition
+}
+
+// retainingChild is implemented by children whose desired state depends on the live object
+type retainingChild interface {
+	// Retain copies what the live object must keep into the desired one before it is applied
+	Retain(desired, observed client.Object)
+}
+
+// fieldManager owns every field the controller applies to a child
+const fieldManager = "ghost-controller"
+
+// appliedHashAnnotation on a child holds a hash of the desired state last applied to it, so the
+// drift check can tell from the cache that the desired state did not change since
+const appliedHashAnnotation = "marketing.kb.dev/applied-hash"
+
+// resourceConflictCondition is only present while an object the Ghost does not control holds the
+// name of one of its children
+const resourceConflictCondition = "ResourceConflict"
+
+// legacyFieldManager wrote the children before they were server-side applied. Without an
+// explicit field manager the API server names the manager after the client binary.
+var legacyFieldManager = filepath.Base(os.Args[0])
+
+// childResult is the outcome of reconciling a single child
+type childResult struct {
+	err       error
+	condition *metav1.Condition
+	// drift is only reported in read-only mode, where changes are never applied
+	drift *metav1.Condition
+	// object is the live child for the inventory, nil when the Ghost has none
+	object client.Object
+	// hash identifies what was applied, empty when nothing was
+	hash string
+	// conflict reports that err is an object of another owner holding the child's name
+	conflict bool
+	// action is what failed with err, Create, Update or Delete, empty before the change was attempted
+	action string
+	// change describes what a read-only pass held back, empty when the child is in sync
+	change string
+}
+
+// childStages groups children into stages that run in order, the children of a
+// stage are reconciled concurrently. The Deployment mounts the PVC so it waits for it,
+// and the export snapshots everything else so it runs last.
+func (r *GhostReconciler) childStages() [][]childReconciler {
+	return [][]childReconciler{
+		{
+			pvcChild{},
+			serviceChild{},
+			ingressChild{wildcard: r.WildcardCertificate},
+			httpRouteChild{},
+			routeChild{apiAvailable: r.RouteAPIAvailable},
+			certificateChild{},
+			pdbChild{},
+			hpaChild{},
+			networkPolicyChild{},
+			viewerRoleChild{},
+			viewerRoleBindingChild{},
+			portForwardServiceAccountChild{},
+			portForwardRoleChild{},
+			portForwardRoleBindingChild{},
+			dashboardChild{},
+			maintenancePageChild{},
+		},
+		{
+			deploymentChild{proxy: r.Proxy},
+			statefulSetChild{proxy: r.Proxy},
+			schedulerCheckChild{},
+			cacheWarmupChild{},
+			contentExportChild{proxy: r.Proxy},
+			maintenanceDeploymentChild{},
+			// Monitors follow the Service and the pods they select
+			monitorChild{gvk: serviceMonitorGVK, apiAvailable: r.MonitoringAPIAvailable},
+			monitorChild{gvk: podMonitorGVK, apiAvailable: r.MonitoringAPIAvailable},
+			prometheusRuleChild{apiAvailable: r.MonitoringAPIAvailable},
+		},
+		{
+			exportChild{reconciler: r},
+		},
+	}
+}
+
+// reconcileChildren runs every stage of the pipeline, records each child's outcome
+// in the Ghost status and prunes the children left under previous names. It returns the first error and whether any child
+// is still waiting to become ready.
+func (r *GhostReconciler) reconcileChildren(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	log := log.FromContext(ctx)
+	pending := false
+	previous := append([]marketingv1.ChildStatus(nil), ghost.Status.Children...)
+	var conflicts, quota, changes []string
+	var quotaErr error
+	for _, stage := range r.childStages() {
+		results := make([]childResult, len(stage))
+		var children errgroup.Group
+		for i, child := range stage {
+			children.Go(func() error {
+				start := time.Now()
+				results[i] = r.reconcileChild(ctx, ghost, child)
+				childReconcileDuration.WithLabelValues(child.Kind()).Observe(time.Since(start).Seconds())
+				return results[i].err
+			})
+		}
+		stageErr := children.Wait()
+
+		// Conditions are only written here so children never touch the status concurrently
+		for i, child := range stage {
+			reconciled := child.Kind() + "Reconciled"
+			// Replaced by the Reconciled conditions, which also record the recovery
+			meta.RemoveStatusCondition(&ghost.Status.Conditions, child.Kind()+"NotReady")
+			if err := results[i].err; err != nil {
+				if results[i].conflict {
+					conflicts = append(conflicts, err.Error())
+				}
+				if classifyError(err) == ErrorClassQuotaExceeded {
+					quota = append(quota, child.Kind()+": "+err.Error())
+					quotaErr = err
+				}
+				log.Error(err, "Failed to reconcile child for Ghost", "kind", child.Kind())
+				if !results[i].conflict {
+					r.Recoder.Event(ghost, corev1.EventTypeWarning, childFailureReason(child, results[i].action), err.Error())
+				}
+				childReconcileErrors.WithLabelValues(child.Kind(), string(classifyError(err))).Inc()
+				setCondition(ghost, reconciled, metav1.ConditionFalse, "ReconcileFailed", "Failed to reconcile "+child.Kind()+" for Ghost: "+err.Error())
+				recordChildError(ghost, child.Kind(), "ReconcileFailed", err.Error(), metav1.Now())
+				continue
+			}
+			if results[i].change != "" {
+				changes = append(changes, results[i].change)
+			}
+			condition := results[i].condition
+			recordInventory(ghost, child.Kind(), results[i].object, r.Scheme, condition == nil || condition.Status == metav1.ConditionTrue, results[i].hash)
+			if meta.FindStatusCondition(ghost.Status.Conditions, reconciled) != nil {
+				setCondition(ghost, reconciled, metav1.ConditionTrue, "Reconciled", child.Kind()+" reconciled")
+			}
+			for _, condition := range []*metav1.Condition{results[i].condition, results[i].drift} {
+				if condition != nil {
+					setCondition(ghost, condition.Type, condition.Status, condition.Reason, condition.Message)
+					pending = pending || condition.Status != metav1.ConditionTrue
+				}
+				if condition != nil && condition.Reason == quotaExceededCondition {
+					quota = append(quota, child.Kind()+": "+condition.Message)
+				}
+			}
+		}
+		if len(conflicts) > 0 {
+			message := strings.Join(conflicts, ", ")
+			if !meta.IsStatusConditionTrue(ghost.Status.Conditions, resourceConflictCondition) {
+				r.Recoder.Event(ghost, corev1.EventTypeWarning, "ResourceConflict", message)
+			}
+			setCondition(ghost, resourceConflictCondition, metav1.ConditionTrue, "NotControlled", message)
+		}
+		if stageErr != nil {
+			r.reportQuota(ghost, quota)
+			if quotaErr != nil {
+				// Waiting on the quota rather than backing off keeps the child from being retried hot
+				return pending, quotaErr
+			}
+			return pending, stageErr
+		}
+	}
+	r.reportQuota(ghost, quota)
+	r.reportPendingChanges(ghost, changes)
+	meta.RemoveStatusCondition(&ghost.Status.Conditions, resourceConflictCondition)
+	if r.readOnly(ghost) {
+		return pending, nil
+	}
+	return pending, r.pruneChildren(ctx, ghost, previous)
+}
+
+// reconcileChild applies or deletes one child so that it matches its desired state
+func (r *GhostReconciler) reconcileChild(ctx context.Context, ghost *marketingv1.Ghost, child childReconciler) childResult {
+	log := log.FromContext(ctx).WithValues("kind", child.Kind())
+
+	desired, err := desire(child, ghost)
+	if err != nil {
+		return childResult{err: err}
+	}
+	observed, err := child.Observe(ctx, r.Client, ghost)
+	if err != nil {
+		return childResult{err: err}
+	}
+	// An object of the same name the Ghost does not control is never changed or deleted
+	if observed != nil && !controlsChild(ghost, observed) {
+		if desired == nil {
+			return childResult{}
+		}
+		err := fmt.Errorf("%s %s already exists and is not controlled by the Ghost", child.Kind(), observed.GetName())
+		return childResult{err: externalError(err), conflict: true}
+	}
+
+	switch {
+	case desired == nil && observed == nil:
+		return childResult{}
+	case desired == nil:
+		if r.readOnly(ghost) {
+			return childResult{drift: driftCondition(child, "would be deleted"), object: observed,
+				change: child.Kind() + " " + observed.GetName() + " would be deleted"}
+		}
+		// Child is no longer wanted, remove it
+		if err := r.Delete(ctx, observed); client.IgnoreNotFound(err) != nil {
+			return childResult{err: err, action: "Delete"}
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, child.Kind()+"Deleted", child.Kind()+" deleted successfully")
+		log.Info(child.Kind()+" deleted", "name", observed.GetName())
+		return childResult{}
+	}
+
+	if retainer, ok := child.(retainingChild); ok && observed != nil {
+		retainer.Retain(desired, observed)
+	}
+	if r.readOnly(ghost) {
+		if observed == nil {
+			return childResult{drift: driftCondition(child, "is missing and would be created"),
+				change: child.Kind() + " " + desired.GetName() + " would be created"}
+		}
+		fields, err := r.childDrifted(ctx, ghost, desired, observed)
+		if err != nil {
+			return childResult{err: err}
+		}
+		if len(fields) > 0 {
+			return childResult{condition: child.Status(observed), drift: driftCondition(child, "has drifted and would be updated"), object: observed,
+				change: child.Kind() + " " + desired.GetName() + " would change " + strings.Join(fields, ", ")}
+		}
+		return childResult{condition: child.Status(observed), object: observed, drift: &metav1.Condition{
+			Type:    child.Kind() + "InSync",
+			Status:  metav1.ConditionTrue,
+			Reason:  "InSync",
+			Message: child.Kind() + " matches the desired state",
+		}}
+	}
+
+	action := "Update"
+	if observed == nil {
+		action = "Create"
+	}
+	if observed != nil {
+		if err := r.upgradeFieldManager(ctx, observed); err != nil {
+			return childResult{err: err, action: action}
+		}
+	}
+	if charged, ok := child.(quotaChargedChild); ok && observed == nil {
+		if err := checkQuota(ctx, r.Client, ghost.Namespace, charged.Charge(desired)); err != nil {
+			return childResult{err: err, action: action}
+		}
+	}
+	hash, err := childHash(desired)
+	if err != nil {
+		return childResult{err: err, action: action}
+	}
+	if err := r.applyChild(ctx, ghost, desired); err != nil {
+		return childResult{err: err, action: action}
+	}
+	switch {
+	case observed == nil:
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, child.Kind()+"Created", child.Kind()+" created successfully")
+		log.Info(child.Kind()+" created", "name", desired.GetName())
+	case desired.GetResourceVersion() != observed.GetResourceVersion():
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, child.Kind()+"Updated", child.Kind()+" updated successfully")
+		log.Info(child.Kind()+" updated", "name", desired.GetName())
+	default:
+		log.Info(child.Kind()+" is up to date, no action required", "name", desired.GetName())
+	}
+	return childResult{condition: child.Status(desired), object: desired, hash: hash}
+}
+
+// childFailureReason names the Warning event of a failed child, such as PVCCreateFailed
+func childFailureReason(child childReconciler, action string) string {
+	if action == "" {
+		action = "Reconcile"
+	}
+	return child.Kind() + action + "Failed"
+}
+
+// controlsChild reports whether the Ghost may change the live child: it controls it, or it is
+// taking the child over from the Ghost it was renamed from
+func controlsChild(ghost *marketingv1.Ghost, observed client.Object) bool {
+	if metav1.IsControlledBy(observed, ghost) {
+		return true
+	}
+	owner := metav1.GetControllerOf(observed)
+	from := ghost.Annotations[renamedFromAnnotation]
+	return owner != nil && from != "" && owner.Kind == "Ghost" && owner.Name == from &&
+		strings.HasPrefix(owner.APIVersion, marketingv1.GroupVersion.Group+"/")
+}
+
+// applyChild server-side applies the desired child and replaces it with the object the API server
+// returned. Fields the controller stops setting are removed, fields other managers set, such as an
+// autoscaler's replica count or labels added by other tools, are left alone.
+func (r *GhostReconciler) applyChild(ctx context.Context, ghost *marketingv1.Ghost, desired client.Object, opts ...client.PatchOption) error {
+	if err := controllerutil.SetControllerReference(ghost, desired, r.Scheme); err != nil {
+		return err
+	}
+	if err := stampAppliedHash(desired); err != nil {
+		return err
+	}
+	opts = append(opts, client.FieldOwner(fieldManager), client.ForceOwnership)
+	return r.Patch(ctx, desired, client.Apply, opts...)
+}
+
+// stampAppliedHash annotates the desired child with a hash of everything else it sets
+func stampAppliedHash(desired client.Object) error {
+	annotations := desired.GetAnnotations()
+	delete(annotations, appliedHashAnnotation)
+	hash, err := childHash(desired)
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[appliedHashAnnotation] = hash
+	desired.SetAnnotations(annotations)
+	return nil
+}
+
+// childMatches compares the desired child with the cached one without calling the API server: the
+// live child carries the hash of the desired state and every field the controller sets still has
+// its value. A mismatch is not necessarily drift, the server may have normalised a field, so it
+// only means the apply has to be dry run.
+func (r *GhostReconciler) childMatches(ghost *marketingv1.Ghost, desired, observed client.Object) (bool, error) {
+	candidate := desired.DeepCopyObject().(client.Object)
+	if err := controllerutil.SetControllerReference(ghost, candidate, r.Scheme); err != nil {
+		return false, err
+	}
+	if err := stampAppliedHash(candidate); err != nil {
+		return false, err
+	}
+	want, err := comparable(candidate)
+	if err != nil {
+		return false, err
+	}
+	have, err := comparable(observed)
+	if err != nil {
+		return false, err
+	}
+	return containsFields(want, have), nil
+}
+
+// containsFields reports whether every field set in want has the same value in have, lists have
+// to match item by item
+func containsFields(want, have interface{}) bool {
+	switch want := want.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		have, ok := have.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range want {
+			if !containsFields(value, have[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		have, ok := have.([]interface{})
+		if !ok || len(have) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !containsFields(want[i], have[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return equality.Semantic.DeepEqual(want, have)
+	}
+}
+
+// childDrifted reports whether applying the desired child would change the live one, it only dry runs the apply
+func (r *GhostReconciler) childDrifted(ctx context.Context, ghost *marketingv1.Ghost, desired, observed client.Object) ([]string, error) {
+	if err := r.applyChild(ctx, ghost, desired, client.DryRunAll); err != nil {
+		return nil, err
+	}
+	applied, err := comparable(desired)
+	if err != nil {
+		return nil, err
+	}
+	live, err := comparable(observed)
+	if err != nil {
+		return nil, err
+	}
+	if equality.Semantic.DeepEqual(applied, live) {
+		return nil, nil
+	}
+	return changedFields(applied, live, ""), nil
+}
+
+// comparable returns the object content without the type and field ownership, which
+// differ between a cached object and one returned by an apply that changed nothing
+func comparable(obj client.Object) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	unstructured.RemoveNestedField(content, "metadata", "managedFields")
+	return content, nil
+}
+
+// upgradeFieldManager hands the fields the controller wrote with updates over to its apply
+// manager, otherwise fields it stops setting would never be removed from older children
+func (r *GhostReconciler) upgradeFieldManager(ctx context.Context, observed client.Object) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(observed, sets.New(legacyFieldManager), fieldManager)
+	if err != nil || patch == nil {
+		return err
+	}
+	return r.Patch(ctx, observed, client.RawPatch(types.JSONPatchType, patch))
+}
+
+// desire renders the child and stamps the Ghost's common labels and annotations and its tags on it
+func desire(child childReconciler, ghost *marketingv1.Ghost) (client.Object, error) {
+	desired, err := child.Desire(ghost)
+	if err != nil || desired == nil {
+		return desired, err
+	}
+	labels := withCommon(desired.GetLabels(), ghost.Spec.CommonLabels)
+	desired.SetLabels(withCommon(labels, marketingv1.TagLabels(ghost.Spec.Tags)))
+	desired.SetAnnotations(withCommon(desired.GetAnnotations(), ghost.Spec.CommonAnnotations))
+	return desired, nil
+}
+
+// withCommon adds the common entries to the map without overriding the ones the child sets itself
+func withCommon(own, common map[string]string) map[string]string {
+	if len(common) == 0 {
+		return own
+	}
+	merged := make(map[string]string, len(own)+len(common))
+	for key, value := range common {
+		merged[key] = value
+	}
+	for key, value := range own {
+		merged[key] = value
+	}
+	return merged
+}
+
+// driftCondition records a change that read-only mode detected but did not apply
+func driftCondition(child childReconciler, drift string) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    child.Kind() + "InSync",
+		Status:  metav1.ConditionFalse,
+		Reason:  "DriftDetected",
+		Message: child.Kind() + " " + drift + ", skipped in read-only mode",
+	}
+}
+
+// observeChild fetches a child by name, treating a missing object or a missing API as absent
+func observeChild(ctx context.Context, c client.Reader, namespace, name string, obj client.Object) (client.Object, error) {
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, obj); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+// newUnstructured returns an empty object for child kinds served by optional APIs
+func newUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	return obj
+}
+
+// hashDesiredChildren returns a stable hash of every child resource rendered for the Ghost
+func (r *GhostReconciler) hashDesiredChildren(ghost *marketingv1.Ghost) (string, error) {
+	hasher := sha256.New()
+	for _, stage := range r.childStages() {
+		for _, child := range stage {
+			desired, err := desire(child, ghost)
+			if err != nil || desired == nil {
+				// Invalid children are reported by the full reconcile
+				continue
+			}
+			data, err := json.Marshal(desired)
+			if err != nil {
+				return "", err
+			}
+			hasher.Write(data)
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil))[:16], nil
+}
+
+// shortHash turns an arbitrary value such as an image or a token into a name safe suffix
+func shortHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// childrenInSync checks that every desired child still exists and was not edited by hand. It
+// compares against the cached children and only dry runs the apply of a child that does not
+// match, so a Ghost in sync costs no API call.
+func (r *GhostReconciler) childrenInSync(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	for _, stage := range r.childStages() {
+		for _, child := range stage {
+			desired, err := desire(child, ghost)
+			if err != nil || desired == nil {
+				continue
+			}
+			observed, err := child.Observe(ctx, r.Client, ghost)
+			if err != nil {
+				return false, err
+			}
+			if observed == nil {
+				return false, nil
+			}
+			if retainer, ok := child.(retainingChild); ok {
+				retainer.Retain(desired, observed)
+			}
+			matches, err := r.childMatches(ghost, desired, observed)
+			if err != nil {
+				return false, err
+			}
+			if matches {
+				continue
+			}
+			if fields, err := r.childDrifted(ctx, ghost, desired, observed); err != nil || len(fields) > 0 {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// configChecksumAnnotation on the pod template rolls the pods when a Secret or ConfigMap they
+// read at startup changes
+const configChecksumAnnotation = "marketing.kb.dev/config-checksum"
+
+// Field indexes listing Ghosts by the Secrets and ConfigMaps their pods read
+const (
+	ghostSecretIndex    = "ghost.spec.secretRefs"
+	ghostConfigMapIndex = "ghost.spec.configMapRefs"
+)
+
+// indexConfigReferences registers the indexes mapping a changed Secret or ConfigMap to its Ghosts
+func indexConfigReferences(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &marketingv1.Ghost{}, ghostSecretIndex, func(obj client.Object) []string {
+		return referencedSecrets(obj.(*marketingv1.Ghost))
+	}); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &marketingv1.Ghost{}, ghostConfigMapIndex, func(obj client.Object) []string {
+		return referencedConfigMaps(obj.(*marketingv1.Ghost))
+	})
+}
+
+// referencedSecrets are the Secrets the Ghost pods read into their environment or mount. The
+// Secret a Redis URL is split into follows the Secret of the URL.
+func referencedSecrets(ghost *marketingv1.Ghost) []string {
+	var names []string
+	if mail := ghost.Spec.Mail; mail != nil && mail.PasswordSecretRef != nil {
+		names = append(names, mail.PasswordSecretRef.Name)
+	}
+	if redis := redisCache(ghost); redis != nil {
+		if redis.PasswordSecretRef != nil {
+			names = append(names, redis.PasswordSecretRef.Name)
+		}
+		if redis.URLSecretRef != nil {
+			names = append(names, redis.URLSecretRef.Name)
+		}
+	}
+	if storage := ghost.Spec.MediaStorage; storage != nil && storage.S3 != nil && storage.S3.CredentialsSecretRef != nil {
+		names = append(names, storage.S3.CredentialsSecretRef.Name)
+	}
+	if init := ghost.Spec.ContentInit; init != nil && init.Git != nil && init.Git.CredentialsSecretRef != nil {
+		names = append(names, init.Git.CredentialsSecretRef.Name)
+	}
+	if shipper := logShipper(ghost); shipper != nil {
+		names = append(names, shipper.ConfigSecretRef.Name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// referencedConfigMaps are the ConfigMaps the Ghost pods read at startup
+func referencedConfigMaps(ghost *marketingv1.Ghost) []string {
+	names := mountedSiteConfigMaps(ghost)
+	if bundle := ghost.Spec.TrustedCABundle; bundle != nil {
+		names = append(names, bundle.Name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// observeConfigChecksum hashes the data of the referenced Secrets and ConfigMaps into
+// status.configChecksum, the pod template carries it so a change rolls the pods. A missing
+// object hashes as empty, the pods then fail on it the same way before and after.
+func (r *GhostReconciler) observeConfigChecksum(ctx context.Context, ghost *marketingv1.Ghost) error {
+	secrets, configMaps := referencedSecrets(ghost), referencedConfigMaps(ghost)
+	if len(secrets) == 0 && len(configMaps) == 0 {
+		ghost.Status.ConfigChecksum = ""
+		return nil
+	}
+	sum := sha256.New()
+	for _, name := range secrets {
+		secret := &corev1.Secret{}
+		observed, err := observeChild(ctx, r.secretReader(), ghost.ObjectMeta.Namespace, name, secret)
+		if err != nil {
+			return err
+		}
+		sum.Write([]byte("Secret/" + name + "\n"))
+		if observed != nil {
+			hashData(sum, secret.Data)
+		}
+	}
+	for _, name := range configMaps {
+		// Read past the cache like the Secrets, only the metadata of ConfigMaps is watched
+		configMap := &corev1.ConfigMap{}
+		observed, err := observeChild(ctx, r.secretReader(), ghost.ObjectMeta.Namespace, name, configMap)
+		if err != nil {
+			return err
+		}
+		sum.Write([]byte("ConfigMap/" + name + "\n"))
+		if observed != nil {
+			data := map[string][]byte{}
+			for key, value := range configMap.Data {
+				data[key] = []byte(value)
+			}
+			for key, value := range configMap.BinaryData {
+				data[key] = value
+			}
+			hashData(sum, data)
+		}
+	}
+	ghost.Status.ConfigChecksum = hex.EncodeToString(sum.Sum(nil))
+	return nil
+}
+
+// hashData writes the keys and values in key order, so the checksum only changes with the data
+func hashData(sum hash.Hash, data map[string][]byte) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		sum.Write([]byte(key + "=" + hex.EncodeToString(data[key]) + "\n"))
+	}
+}
+
+// ghostsReferencing maps a changed Secret or ConfigMap to the Ghosts whose pods read it
+func (r *GhostReconciler) ghostsReferencing(index string) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		ghosts := &marketingv1.GhostList{}
+		if err := r.List(ctx, ghosts, client.InNamespace(obj.GetNamespace()), client.MatchingFields{index: obj.GetName()}); err != nil {
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, ghost := range ghosts.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ghost)})
+		}
+		return requests
+	}
+}
+
+// setConfigChecksum annotates the pod template with the checksum of the referenced configuration
+func setConfigChecksum(ghost *marketingv1.Ghost, template *metav1.ObjectMeta) {
+	if ghost.Status.ConfigChecksum == "" {
+		return
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[configChecksumAnnotation] = ghost.Status.ConfigChecksum
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	contentExportNamePrefix       = "ghost-content-export-"
+	defaultContentExportRetention = 7
+	contentExportDir              = "/export"
+	// contentExportClaimPath is where a PVC destination is mounted
+	contentExportClaimPath = "/destination"
+	// exportStamp names the directory of a run, exports sort by the time they were taken
+	exportStamp = `$(date -u +%Y%m%dT%H%M%SZ)`
+)
+
+// imagesArchiveScript packs the images of the content volume into the artifact path
+const imagesArchiveScript = `tar -czf "$ARTIFACT" -C "$CONTENT_PATH/images" .`
+
+// claimStoreScript copies the run's files into a new directory of the claim and deletes
+// the oldest directories beyond the retention
+const claimStoreScript = `target="$TARGET/` + exportStamp + `" && mkdir -p "$target" && cp "$SOURCE"/* "$target"/ && ` +
+	`ls -1d "$TARGET"/*/ | sort -r | tail -n +$((RETENTION + 1)) | xargs -r rm -rf`
+
+// contentExportChild manages the CronJob saving the content of the Ghost
+type contentExportChild struct {
+	proxy *marketingv1.ProxySpec
+}
+
+func (contentExportChild) Kind() string {
+	return "ContentExport"
+}
+
+func (c contentExportChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if ghost.Spec.Export == nil {
+		return nil, nil
+	}
+	return generateDesiredContentExport(ghost, c.proxy), nil
+}
+
+func (contentExportChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, contentExportNamePrefix), &batchv1.CronJob{})
+}
+
+func (contentExportChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// contentExportLocation is the directory or object prefix the runs of the Ghost are stored under
+func contentExportLocation(ghost *marketingv1.Ghost) string {
+	destination := ghost.Spec.Export.Destination
+	if destination.ObjectStore == nil {
+		return path.Join(contentExportClaimPath, ghost.Name)
+	}
+	return strings.TrimSuffix(destination.ObjectStore.URL, "/") + "/" + ghost.Namespace + "/" + ghost.Name
+}
+
+// generateDesiredContentExport renders the CronJob. The init containers write content.json
+// and images.tar.gz to a scratch volume, the main container stores them under a directory
+// named after the time of the run.
+func generateDesiredContentExport(ghost *marketingv1.Ghost, proxy *marketingv1.ProxySpec) *batchv1.CronJob {
+	spec := ghost.Spec.Export
+	scratch := corev1.VolumeMount{Name: "export", MountPath: contentExportDir}
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Volumes:       []corev1.Volume{scratchVolume(ghost, "export")},
+		InitContainers: []corev1.Container{{
+			Name:    "export",
+			Image:   ghostImage(ghost),
+			Command: []string{"node", "-e", exportScript},
+			Env: []corev1.EnvVar{
+				{Name: "GHOST_URL", Value: fmt.Sprintf("http://%s:%d%s", childName(ghost, svcNamePrefix), servicePort(ghost), ghostPath(ghost))},
+				{Name: "GHOST_ADMIN_API_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &spec.AdminAPIKeySecretRef}},
+				{Name: "ARTIFACT", Value: contentExportDir + "/content.json"},
+			},
+			VolumeMounts: []corev1.VolumeMount{scratch},
+		}},
+	}
+	if ghost.Spec.Image != nil {
+		podSpec.ImagePullSecrets = ghost.Spec.Image.PullSecrets
+	}
+
+	if spec.IncludeImages {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "ghost-data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: contentClaimName(ghost),
+				ReadOnly:  true,
+			}},
+		})
+		podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+			Name:    "images",
+			Image:   archiveImage,
+			Command: []string{"sh", "-c", imagesArchiveScript},
+			Env: []corev1.EnvVar{
+				{Name: "CONTENT_PATH", Value: ghostContentPath},
+				{Name: "ARTIFACT", Value: contentExportDir + "/images.tar.gz"},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				scratch,
+				{Name: "ghost-data", MountPath: ghostContentPath, ReadOnly: true},
+			},
+		})
+		podSpec.Affinity = contentVolumeAffinity(ghost)
+	}
+
+	if store := spec.Destination.ObjectStore; store != nil {
+		container := storageCopyContainer("store", *store, contentExportDir, contentExportLocation(ghost), scratch)
+		if isGCS(*store) {
+			container.Command[len(container.Command)-1] = `gcloud storage cp -r "$SOURCE/*" "$TARGET/` + exportStamp + `/"`
+		} else {
+			container.Command[len(container.Command)-1] = `aws s3 cp --recursive "$SOURCE" "$TARGET/` + exportStamp + `/"`
+		}
+		podSpec.Containers = []corev1.Container{container}
+		podSpec.Volumes = append(podSpec.Volumes, storageVolumes(*store)...)
+	} else {
+		retention := spec.Retention
+		if retention == 0 {
+			retention = defaultContentExportRetention
+		}
+		podSpec.Containers = []corev1.Container{{
+			Name:    "store",
+			Image:   archiveImage,
+			Command: []string{"sh", "-c", claimStoreScript},
+			Env: []corev1.EnvVar{
+				{Name: "SOURCE", Value: contentExportDir},
+				{Name: "TARGET", Value: contentExportLocation(ghost)},
+				{Name: "RETENTION", Value: strconv.Itoa(int(retention))},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				scratch,
+				{Name: "destination", MountPath: contentExportClaimPath},
+			},
+		}}
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "destination",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: spec.Destination.ClaimName,
+			}},
+		})
+	}
+	setPodProxyEnv(&podSpec, ghost, proxy)
+
+	return &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, contentExportNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   spec.Schedule,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: ptr.To[int32](1),
+			FailedJobsHistoryLimit:     ptr.To[int32](1),
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					BackoffLimit: ptr.To[int32](2),
+					Template: corev1.PodTemplateSpec{
+						Spec: podSpec,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	ghostContentPath = "/var/lib/ghost/content"
+	// contentInitContainerName fetches spec.contentInit into the content volume
+	contentInitContainerName = "content-init"
+	// contentInitImage ships git, wget and tar
+	contentInitImage = "alpine/git:2.45.2"
+	// fixPermissionsImage only needs chown and chmod
+	fixPermissionsImage = "busybox:1.36"
+	// ghostUID is the node user the official Ghost image runs as
+	ghostUID = 1000
+)
+
+// contentInitScript fetches the source into /tmp/src and copies SUBPATH into the content
+// directory. Ghost's entrypoint chowns the copied files to the node user on start.
+const contentInitScript = `set -eu
+mkdir -p /tmp/src
+if [ -n "${TRUSTED_CA_FILE:-}" ]; then
+  cat /etc/ssl/certs/ca-certificates.crt "$TRUSTED_CA_FILE" > /tmp/ca-certificates.crt
+  export GIT_SSL_CAINFO=/tmp/ca-certificates.crt SSL_CERT_FILE=/tmp/ca-certificates.crt
+fi
+if [ -n "${GIT_REPOSITORY:-}" ]; then
+  # The credentials stay in the environment, out of the clone's config and the process list
+  git -c credential.helper='!f() { [ -n "${GIT_PASSWORD:-}" ] || exit 0; echo "username=${GIT_USERNAME:-git}"; echo "password=$GIT_PASSWORD"; }; f' \
+    clone --depth 1 ${GIT_REF:+--branch "$GIT_REF"} "$GIT_REPOSITORY" /tmp/src
+else
+  wget -qO- "$ARCHIVE_URL" | tar -xz -C /tmp/src
+fi
+if [ "$OVERWRITE" = "true" ]; then
+  cp -R "/tmp/src/$SUBPATH/." "$CONTENT_PATH/"
+else
+  cp -Rn "/tmp/src/$SUBPATH/." "$CONTENT_PATH/"
+fi
+`
+
+// fixPermissionsScript hands the content directory to Ghost's user and keeps it writable
+var fixPermissionsScript = fmt.Sprintf(`chown -R %[1]d:%[1]d "$CONTENT_PATH" && chmod -R u+rwX "$CONTENT_PATH"`, ghostUID)
+
+// generateInitContainers returns the content seeding and permission repair containers
+// followed by the user's init containers. Permissions are repaired after seeding so
+// freshly copied files are covered too.
+func generateInitContainers(ghost *marketingv1.Ghost) []corev1.Container {
+	var containers []corev1.Container
+	if init := ghost.Spec.ContentInit; init != nil {
+		env := []corev1.EnvVar{
+			{Name: "CONTENT_PATH", Value: ghostContentPath},
+			{Name: "SUBPATH", Value: init.SubPath},
+			{Name: "OVERWRITE", Value: strconv.FormatBool(init.Overwrite)},
+		}
+		if init.Git != nil {
+			env = append(env,
+				corev1.EnvVar{Name: "GIT_REPOSITORY", Value: init.Git.Repository},
+				corev1.EnvVar{Name: "GIT_REF", Value: init.Git.Ref},
+			)
+			if secret := init.Git.CredentialsSecretRef; secret != nil {
+				for _, key := range []struct {
+					name, key string
+					optional  bool
+				}{{"GIT_USERNAME", "username", true}, {"GIT_PASSWORD", "password", false}} {
+					env = append(env, corev1.EnvVar{
+						Name: key.name,
+						ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: *secret,
+							Key:                  key.key,
+							Optional:             ptr.To(key.optional),
+						}},
+					})
+				}
+			}
+		} else {
+			env = append(env, corev1.EnvVar{Name: "ARCHIVE_URL", Value: init.ArchiveURL})
+		}
+		containers = append(containers, corev1.Container{
+			Name:    contentInitContainerName,
+			Image:   contentInitImage,
+			Command: []string{"sh", "-c", contentInitScript},
+			Env:     env,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "ghost-data",
+					MountPath: ghostContentPath,
+				},
+			},
+		})
+	}
+	if ghost.Spec.Persistence != nil && ghost.Spec.Persistence.FixPermissions {
+		containers = append(containers, corev1.Container{
+			Name:    "fix-permissions",
+			Image:   fixPermissionsImage,
+			Command: []string{"sh", "-c", fixPermissionsScript},
+			Env:     []corev1.EnvVar{{Name: "CONTENT_PATH", Value: ghostContentPath}},
+			SecurityContext: &corev1.SecurityContext{
+				RunAsUser:    ptr.To[int64](0),
+				RunAsNonRoot: ptr.To(false),
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "ghost-data",
+					MountPath: ghostContentPath,
+				},
+			},
+		})
+	}
+	return append(containers, ghost.Spec.InitContainers...)
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"slices"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	contentSyncJobNamePrefix = "ghost-content-sync-"
+	contentSyncDir           = "/sync"
+	contentSyncArtifact      = contentSyncDir + "/export.json"
+)
+
+// excludeMembersScript drops the tables holding members and their data from a JSON export
+const excludeMembersScript = `
+const fs = require('fs');
+const exported = JSON.parse(fs.readFileSync(process.env.ARTIFACT));
+for (const db of exported.db) {
+  for (const table of Object.keys(db.data)) {
+    if (table.startsWith('members') || table === 'labels' || table === 'email_recipients') {
+      delete db.data[table];
+    }
+  }
+}
+fs.writeFileSync(process.env.ARTIFACT, JSON.stringify(exported));
+`
+
+// deleteContentScript deletes the posts and tags of Ghost through the Admin API
+const deleteContentScript = adminTokenScript + `
+async function main() {
+  const res = await fetch(process.env.GHOST_URL + '/ghost/api/admin/db/', {
+    method: 'DELETE',
+    headers: {'X-Forwarded-Proto': 'https', Authorization: 'Ghost ' + adminToken(process.env.GHOST_ADMIN_API_KEY)},
+  });
+  if (!res.ok) {
+    console.error('Ghost content deletion failed: HTTP ' + res.status + ' ' + await res.text());
+    process.exit(1);
+  }
+}
+main().catch((err) => {
+  console.error(err);
+  process.exit(1);
+});
+`
+
+// generateContentSyncJob renders the Job syncing the content. The export of the source is
+// saved by an init container into a scratch volume, filtered and imported into the target.
+func generateContentSyncJob(sync *marketingv1.GhostContentSync, source, target *marketingv1.Ghost) *batchv1.Job {
+	scratch := corev1.VolumeMount{Name: "sync", MountPath: contentSyncDir}
+	adminEnv := func(ghost *marketingv1.Ghost, endpoint marketingv1.ContentSyncEndpoint) []corev1.EnvVar {
+		return []corev1.EnvVar{
+			{Name: "GHOST_URL", Value: ghostAdminURL(ghost)},
+			{Name: "GHOST_ADMIN_API_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: endpoint.AdminAPIKeySecretRef.DeepCopy()}},
+			{Name: "ARTIFACT", Value: contentSyncArtifact},
+		}
+	}
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Volumes:       []corev1.Volume{scratchVolume(target, "sync")},
+		InitContainers: []corev1.Container{{
+			Name:         "export",
+			Image:        ghostImage(source),
+			Command:      []string{"node", "-e", exportScript},
+			Env:          adminEnv(source, sync.Spec.Source),
+			VolumeMounts: []corev1.VolumeMount{scratch},
+		}},
+	}
+	if sync.Spec.ExcludeMembers {
+		podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+			Name:         "exclude-members",
+			Image:        ghostImage(target),
+			Command:      []string{"node", "-e", excludeMembersScript},
+			Env:          []corev1.EnvVar{{Name: "ARTIFACT", Value: contentSyncArtifact}},
+			VolumeMounts: []corev1.VolumeMount{scratch},
+		})
+	}
+	if sync.Spec.ReplaceContent {
+		podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+			Name:    "delete-content",
+			Image:   ghostImage(target),
+			Command: []string{"node", "-e", deleteContentScript},
+			Env:     adminEnv(target, sync.Spec.Target),
+		})
+	}
+	podSpec.Containers = []corev1.Container{{
+		Name:         "import",
+		Image:        ghostImage(target),
+		Command:      []string{"node", "-e", importScript},
+		Env:          adminEnv(target, sync.Spec.Target),
+		VolumeMounts: []corev1.VolumeMount{scratch},
+	}}
+	// The pull secrets of a Ghost in another namespace do not exist next to the Job
+	for _, ghost := range []*marketingv1.Ghost{source, target} {
+		if ghost.Spec.Image == nil || ghost.Namespace != sync.Namespace {
+			continue
+		}
+		for _, secret := range ghost.Spec.Image.PullSecrets {
+			if !slices.Contains(podSpec.ImagePullSecrets, secret) {
+				podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, secret)
+			}
+		}
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      contentSyncJobNamePrefix + sync.Name,
+			Namespace: sync.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](2),
+			Template: corev1.PodTemplateSpec{
+				Spec: podSpec,
+			},
+		},
+	}
+}
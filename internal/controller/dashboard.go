@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	dashboardNamePrefix = "ghost-dashboard-"
+	// grafanaDashboardLabel marks the ConfigMaps the Grafana sidecar loads dashboards from
+	grafanaDashboardLabel = "grafana_dashboard"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+
+// dashboardEnabled reports whether the Ghost asks for a Grafana dashboard
+func dashboardEnabled(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.Monitoring != nil && ghost.Spec.Monitoring.Dashboard
+}
+
+// dashboardChild manages the ConfigMap holding the Grafana dashboard of the Ghost
+type dashboardChild struct{}
+
+func (dashboardChild) Kind() string {
+	return "Dashboard"
+}
+
+func (dashboardChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !dashboardEnabled(ghost) {
+		return nil, nil
+	}
+	dashboard, err := json.MarshalIndent(generateDashboard(ghost), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, dashboardNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+			Labels:    map[string]string{grafanaDashboardLabel: "1"},
+		},
+		Data: map[string]string{
+			childName(ghost, dashboardNamePrefix) + ".json": string(dashboard),
+		},
+	}, nil
+}
+
+func (dashboardChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, dashboardNamePrefix), &corev1.ConfigMap{})
+}
+
+func (dashboardChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// generateDashboard graphs the ready replicas, the request rate through the Ingress and the content
+// volume usage of the Ghost from the same series its PrometheusRule alerts on
+func generateDashboard(ghost *marketingv1.Ghost) map[string]interface{} {
+	namespace := ghost.ObjectMeta.Namespace
+	claim := contentClaimName(ghost)
+	ingress := childName(ghost, ingressNamePrefix)
+	panels := []interface{}{
+		dashboardPanel(1, "Ready replicas", "short", 0,
+			readyReplicasSeries(ghost), "ready"),
+		dashboardPanel(2, "Requests", "reqps", 8,
+			fmt.Sprintf(`sum by (status) (rate(nginx_ingress_controller_requests{exported_namespace=%q,ingress=%q}[5m]))`, namespace, ingress),
+			"{{status}}"),
+	}
+	if !ephemeral(ghost) {
+		panels = append(panels, dashboardPanel(3, "Content volume usage", "percentunit", 16,
+			fmt.Sprintf(`kubelet_volume_stats_used_bytes{namespace=%q,persistentvolumeclaim=%q} / `+
+				`kubelet_volume_stats_capacity_bytes{namespace=%q,persistentvolumeclaim=%q}`, namespace, claim, namespace, claim),
+			"used"))
+	}
+	return map[string]interface{}{
+		"uid":           childName(ghost, dashboardNamePrefix) + "-" + namespace,
+		"title":         fmt.Sprintf("Ghost %s/%s", namespace, ghost.Name),
+		"tags":          []string{"ghost", namespace},
+		"schemaVersion": 39,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []interface{}{map[string]interface{}{
+				"name":  "datasource",
+				"type":  "datasource",
+				"query": "prometheus",
+			}},
+		},
+		"panels": panels,
+	}
+}
+
+// dashboardPanel is a time series panel of a single query, the panels are stacked vertically
+func dashboardPanel(id int, title, unit string, y int, expr, legend string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"type":       "timeseries",
+		"title":      title,
+		"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+		"gridPos":    map[string]int{"h": 8, "w": 24, "x": 0, "y": y},
+		"fieldConfig": map[string]interface{}{
+			"defaults": map[string]string{"unit": unit},
+		},
+		"targets": []interface{}{map[string]string{
+			"refId":        "A",
+			"expr":         expr,
+			"legendFormat": legend,
+		}},
+	}
+}
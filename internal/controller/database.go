@@ -0,0 +1,329 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	sqliteMigratedCondition      = "SQLiteMigrated"
+	sqliteMigrationJobNamePrefix = "ghost-sqlite-migrate-"
+	sqliteCleanupJobNamePrefix   = "ghost-sqlite-cleanup-"
+	// sqliteFileAnnotation records on the migration Job the SQLite file it copied, the file the
+	// cleanup Job removes
+	sqliteFileAnnotation = "marketing.kb.dev/sqlite-file"
+	// defaultSQLiteFilename is the database__connection__filename of the Deployment template
+	defaultSQLiteFilename = "/var/lib/ghost/content/data/ghost.db"
+)
+
+// sqliteMigrateScript creates the schema of the running image on MySQL and replaces its rows with
+// the ones of every table of the SQLite file, in a single transaction so a failed copy leaves
+// MySQL untouched
+const sqliteMigrateScript = `set -eu
+cd current
+node_modules/.bin/knex-migrator-init --mgpath .
+exec node - <<'EOF'
+const knex = require('knex');
+const source = knex({client: 'sqlite3', connection: {filename: process.env.SQLITE_FILENAME}, useNullAsDefault: true});
+const target = knex({client: 'mysql2', connection: {
+  host: process.env.database__connection__host,
+  port: Number(process.env.database__connection__port),
+  user: process.env.database__connection__user,
+  password: process.env.database__connection__password,
+  database: process.env.database__connection__database,
+  charset: 'utf8mb4',
+}});
+(async () => {
+  const tables = await source('sqlite_master').where('type', 'table').whereNot('name', 'like', 'sqlite_%').pluck('name');
+  await target.transaction(async (trx) => {
+    await trx.raw('SET FOREIGN_KEY_CHECKS=0');
+    for (const table of tables) {
+      if (!(await trx.schema.hasTable(table))) {
+        console.log('skipping ' + table + ', the image has no such table');
+        continue;
+      }
+      await trx(table).del();
+      const rows = await source(table).select();
+      for (let i = 0; i < rows.length; i += 500) {
+        await trx(table).insert(rows.slice(i, i + 500));
+      }
+      console.log('copied ' + rows.length + ' rows of ' + table);
+    }
+    await trx.raw('SET FOREIGN_KEY_CHECKS=1');
+  });
+  await source.destroy();
+  await target.destroy();
+})().catch((err) => {
+  console.error(err);
+  process.exit(1);
+});
+EOF
+`
+
+// sqliteCleanupScript removes the SQLite file the migration copied along with its journals
+const sqliteCleanupScript = `set -eu
+rm -f "$SQLITE_FILENAME" "$SQLITE_FILENAME-journal" "$SQLITE_FILENAME-wal" "$SQLITE_FILENAME-shm"
+`
+
+// databaseMySQL reports whether Ghost runs on the MySQL server of spec.database
+func databaseMySQL(ghost *marketingv1.Ghost) bool {
+	database := ghost.Spec.Database
+	return database != nil && database.Client == marketingv1.DatabaseClientMySQL && database.MySQL != nil
+}
+
+// applyDatabaseEnv replaces the SQLite file of the Deployment template with the MySQL connection
+// of spec.database, sourcing the password from a Secret
+func applyDatabaseEnv(ghost *marketingv1.Ghost, env []corev1.EnvVar) []corev1.EnvVar {
+	if !databaseMySQL(ghost) {
+		return env
+	}
+	mysql := ghost.Spec.Database.MySQL
+	env = slices.DeleteFunc(env, func(variable corev1.EnvVar) bool {
+		return variable.Name == "database__connection__filename"
+	})
+	env = setEnv(env, "database__client", string(marketingv1.DatabaseClientMySQL))
+	port := mysql.Port
+	if port == 0 {
+		port = 3306
+	}
+	return append(env,
+		corev1.EnvVar{Name: "database__connection__host", Value: mysql.Host},
+		corev1.EnvVar{Name: "database__connection__port", Value: strconv.Itoa(int(port))},
+		corev1.EnvVar{Name: "database__connection__user", Value: mysql.User},
+		corev1.EnvVar{
+			Name: "database__connection__password",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: mysql.PasswordSecretRef.DeepCopy(),
+			},
+		},
+		corev1.EnvVar{Name: "database__connection__database", Value: mysql.Database},
+	)
+}
+
+// sqliteMigration reports whether the content of the SQLite file is copied to MySQL once the
+// client changes
+func sqliteMigration(ghost *marketingv1.Ghost) bool {
+	return databaseMySQL(ghost) && ghost.Spec.Database.Migration != nil && ghost.Spec.Database.Migration.Enabled
+}
+
+// ghostContainer returns the Ghost container of a pod template, nil when there is none
+func ghostContainer(containers []corev1.Container) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == GhostContainerName {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// containerEnv returns the value of an environment variable of the Ghost container
+func containerEnv(containers []corev1.Container, name string) string {
+	if container := ghostContainer(containers); container != nil {
+		for _, env := range container.Env {
+			if env.Name == name {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+// sqliteClient reports whether a database__client is SQLite, the image default when unset
+func sqliteClient(value string) bool {
+	return value == "" || value == "sqlite3" || value == "sqlite"
+}
+
+// migrateDatabase copies the content of the SQLite file to MySQL in a Job while Ghost is scaled
+// down, once spec.database switches the client of a running Ghost, and reports whether the
+// rollout has to wait for the Job. The SQLite file is kept until
+// spec.database.migration.removeSQLite asks for its removal.
+func (r *GhostReconciler) migrateDatabase(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	if !sqliteMigration(ghost) {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, sqliteMigratedCondition)
+		return false, nil
+	}
+	observed, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, deploymentName(ghost), &appsv1.Deployment{})
+	if err != nil || observed == nil {
+		// A fresh install creates its database on MySQL on startup
+		return false, err
+	}
+	live := observed.(*appsv1.Deployment)
+	containers := live.Spec.Template.Spec.Containers
+	container := ghostContainer(containers)
+	if container == nil {
+		return false, nil
+	}
+	// Deployments rendered before the template set database__client run on the SQLite default
+	if !sqliteClient(containerEnv(containers, "database__client")) {
+		return false, r.removeSQLite(ctx, ghost)
+	}
+	filename := containerEnv(containers, "database__connection__filename")
+	if filename == "" {
+		filename = defaultSQLiteFilename
+	}
+
+	name := childName(ghost, sqliteMigrationJobNamePrefix)
+	if r.readOnly(ghost) {
+		setCondition(ghost, sqliteMigratedCondition, metav1.ConditionFalse, "DriftDetected", "Job "+name+" would copy "+filename+" to MySQL, skipped in read-only mode")
+		return true, nil
+	}
+	// Ghost must not write to the SQLite file while it is copied. The Deployment is applied
+	// with its replica count and the MySQL connection once the Job succeeded.
+	if ptr.Deref(live.Spec.Replicas, 1) != 0 {
+		patch := client.MergeFrom(live.DeepCopy())
+		live.Spec.Replicas = ptr.To[int32](0)
+		if err := r.Patch(ctx, live, patch); err != nil {
+			return true, err
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "MigrationScaledDown", "Deployment "+live.Name+" scaled down to copy "+filename+" to MySQL")
+	}
+	if live.Status.Replicas != 0 {
+		setCondition(ghost, sqliteMigratedCondition, metav1.ConditionFalse, "MigrationPending", "Waiting for the pods on "+filename+" to stop before copying it to MySQL")
+		return true, nil
+	}
+
+	observed, err = observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, name, &batchv1.Job{})
+	if err != nil {
+		return true, err
+	}
+	if observed == nil {
+		desired, err := desire(deploymentChild{proxy: r.Proxy}, ghost)
+		if err != nil {
+			return true, err
+		}
+		// The schema is created for the image the SQLite file was written by, a new image is
+		// migrated on MySQL afterwards
+		job := generateSQLiteJob(ghost, desired.(*appsv1.Deployment), name, container.Image, filename, sqliteMigrateScript)
+		if err := controllerutil.SetControllerReference(ghost, job, r.Scheme); err != nil {
+			return true, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return true, err
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "MigrationStarted", "Job "+name+" copies "+filename+" to MySQL")
+		setCondition(ghost, sqliteMigratedCondition, metav1.ConditionFalse, "Migrating", "Job "+name+" copies "+filename+" to MySQL")
+		return true, nil
+	}
+
+	for _, condition := range observed.(*batchv1.Job).Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			if previous := meta.FindStatusCondition(ghost.Status.Conditions, sqliteMigratedCondition); previous == nil || previous.Reason != "Migrated" {
+				r.Recoder.Event(ghost, corev1.EventTypeNormal, "Migrated", "Job "+name+" copied "+filename+" to MySQL")
+			}
+			setCondition(ghost, sqliteMigratedCondition, metav1.ConditionTrue, "Migrated",
+				"Job "+name+" copied "+filename+" to MySQL, the file is kept until spec.database.migration.removeSQLite is set")
+			return false, nil
+		case batchv1.JobFailed:
+			if previous := meta.FindStatusCondition(ghost.Status.Conditions, sqliteMigratedCondition); previous == nil || previous.Reason != "MigrationFailed" {
+				r.Recoder.Event(ghost, corev1.EventTypeWarning, "MigrationFailed", "Job "+name+" failed to copy "+filename+" to MySQL, see its logs")
+			}
+			setCondition(ghost, sqliteMigratedCondition, metav1.ConditionFalse, "MigrationFailed",
+				"Job "+name+" failed to copy "+filename+" to MySQL, Ghost stays scaled down on SQLite until the Job is deleted to retry or spec.database is reverted: "+condition.Message)
+			return true, nil
+		}
+	}
+	setCondition(ghost, sqliteMigratedCondition, metav1.ConditionFalse, "Migrating", "Job "+name+" copies "+filename+" to MySQL")
+	return true, nil
+}
+
+// removeSQLite deletes the SQLite file the migration Job copied in a Job of its own, once
+// spec.database.migration.removeSQLite asks for it
+func (r *GhostReconciler) removeSQLite(ctx context.Context, ghost *marketingv1.Ghost) error {
+	if !ghost.Spec.Database.Migration.RemoveSQLite || r.readOnly(ghost) {
+		return nil
+	}
+	observed, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, childName(ghost, sqliteMigrationJobNamePrefix), &batchv1.Job{})
+	if err != nil || observed == nil {
+		// Without the migration Job the operator did not copy the file, it is left alone
+		return err
+	}
+	migration := observed.(*batchv1.Job)
+	if !jobSucceeded(migration) {
+		return nil
+	}
+	filename := migration.Annotations[sqliteFileAnnotation]
+	if filename == "" {
+		filename = defaultSQLiteFilename
+	}
+
+	name := childName(ghost, sqliteCleanupJobNamePrefix)
+	observed, err = observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, name, &batchv1.Job{})
+	if err != nil {
+		return err
+	}
+	if observed == nil {
+		desired, err := desire(deploymentChild{proxy: r.Proxy}, ghost)
+		if err != nil {
+			return err
+		}
+		deployment := desired.(*appsv1.Deployment)
+		job := generateSQLiteJob(ghost, deployment, name, ghostImage(ghost), filename, sqliteCleanupScript)
+		if err := controllerutil.SetControllerReference(ghost, job, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return err
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "RemovingSQLite", "Job "+name+" removes "+filename)
+		setCondition(ghost, sqliteMigratedCondition, metav1.ConditionTrue, "RemovingSQLite", "Job "+name+" removes "+filename+" copied to MySQL")
+		return nil
+	}
+	if jobSucceeded(observed.(*batchv1.Job)) {
+		setCondition(ghost, sqliteMigratedCondition, metav1.ConditionTrue, "SQLiteRemoved", "Job "+name+" removed "+filename+" copied to MySQL")
+	}
+	return nil
+}
+
+// jobSucceeded reports whether the Job completed
+func jobSucceeded(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobComplete && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSQLiteJob renders a Job running script on the content volume with the MySQL
+// connection of the desired Deployment and the SQLite file in SQLITE_FILENAME
+func generateSQLiteJob(ghost *marketingv1.Ghost, deployment *appsv1.Deployment, name, image, filename, script string) *batchv1.Job {
+	job := generateMigrationJob(ghost, deployment, name, image)
+	container := &job.Spec.Template.Spec.Containers[0]
+	container.Image = image
+	container.Command = []string{"sh", "-c", script}
+	container.Env = setEnv(container.Env, "SQLITE_FILENAME", filename)
+	job.Annotations[sqliteFileAnnotation] = filename
+	return job
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// The GhostTheme installing the default theme, Ghost registers the theme under this name
+const defaultThemeNamePrefix = "default-theme-"
+
+// localeAnnotation is the locale of the Ghost's publication, e.g. de or pt-BR, of Ghosts
+// created before spec.locale
+const localeAnnotation = "marketing.kb.dev/locale"
+
+// DefaultTheme is the operator wide theme bundle installed and activated on every new Ghost
+type DefaultTheme struct {
+	// Bundles maps locales to the URL of their theme zip, the empty locale is the fallback
+	Bundles map[string]string
+	// AdminAPIKeySecretRef is the Secret in the namespace of each Ghost holding its Admin API key
+	AdminAPIKeySecretRef corev1.SecretKeySelector
+}
+
+// ParseDefaultTheme parses comma separated locale=url bundles, a url without a locale is the
+// fallback, and the secret:key of the Admin API key. It returns nil when bundles is empty.
+func ParseDefaultTheme(bundles, adminAPIKey string) (*DefaultTheme, error) {
+	if bundles == "" {
+		return nil, nil
+	}
+	theme := &DefaultTheme{Bundles: map[string]string{}}
+	for _, bundle := range strings.Split(bundles, ",") {
+		locale, url, ok := strings.Cut(strings.TrimSpace(bundle), "=")
+		if !ok {
+			locale, url = "", locale
+		}
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return nil, fmt.Errorf("default theme bundle %q is not an HTTP(S) URL", bundle)
+		}
+		locale = normalizeLocale(locale)
+		if _, ok := theme.Bundles[locale]; ok {
+			return nil, fmt.Errorf("default theme bundle for locale %q is set twice", locale)
+		}
+		theme.Bundles[locale] = url
+	}
+	name, key, ok := strings.Cut(adminAPIKey, ":")
+	if !ok || name == "" || key == "" {
+		return nil, fmt.Errorf("default theme admin API key %q is not of the form secret:key", adminAPIKey)
+	}
+	theme.AdminAPIKeySecretRef = corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Key: key}
+	return theme, nil
+}
+
+// normalizeLocale lowercases a locale and spells it with dashes, pt_BR becomes pt-br
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// bundleFor picks the bundle of the locale, of its language or the fallback, empty when none applies
+func (t *DefaultTheme) bundleFor(locale string) string {
+	locale = normalizeLocale(locale)
+	if url, ok := t.Bundles[locale]; ok {
+		return url
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	if url, ok := t.Bundles[language]; ok {
+		return url
+	}
+	return t.Bundles[""]
+}
+
+// locale is spec.locale, or the locale annotation
+func locale(ghost *marketingv1.Ghost) string {
+	if ghost.Spec.Locale != "" {
+		return ghost.Spec.Locale
+	}
+	return ghost.Annotations[localeAnnotation]
+}
+
+// installDefaultTheme hands the default theme of the Ghost's locale to the theme controller. Only
+// new Ghosts get it, so a theme the team activated later is never replaced, and a deleted
+// GhostTheme is not recreated.
+func (r *GhostReconciler) installDefaultTheme(ctx context.Context, ghost *marketingv1.Ghost) error {
+	if r.DefaultTheme == nil || r.readOnly(ghost) || ghost.Status.ObservedGeneration != 0 {
+		return nil
+	}
+	url := r.DefaultTheme.bundleFor(locale(ghost))
+	if url == "" {
+		return nil
+	}
+	theme := &marketingv1.GhostTheme{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, defaultThemeNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+			Labels:    ghost.Spec.CommonLabels,
+		},
+		Spec: marketingv1.GhostThemeSpec{
+			GhostRef:             corev1.LocalObjectReference{Name: ghost.Name},
+			Source:               marketingv1.ThemeSource{URL: url},
+			Activate:             true,
+			AdminAPIKeySecretRef: r.DefaultTheme.AdminAPIKeySecretRef,
+		},
+	}
+	if err := controllerutil.SetControllerReference(ghost, theme, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, theme); err != nil {
+		return client.IgnoreAlreadyExists(err)
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "DefaultThemeRequested",
+		fmt.Sprintf("GhostTheme %s installs the default theme from %s", theme.Name, url))
+	return nil
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	deploymentNamePrefix = "ghost-deployment-"
+	// deploymentRolledOutCondition gates GhostReady on the pods actually running the current spec
+	deploymentRolledOutCondition = "DeploymentRolledOut"
+	defaultImageRepository       = "ghost"
+	defaultImageTag              = "latest"
+)
+
+// deploymentChild manages the Deployment running the Ghost pods
+type deploymentChild struct {
+	// proxy is the operator wide egress proxy
+	proxy *marketingv1.ProxySpec
+}
+
+func (deploymentChild) Kind() string {
+	return "Deployment"
+}
+
+func (d deploymentChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if statefulSet(ghost) {
+		return nil, nil
+	}
+	deployment, err := generateDesiredDeployment(ghost)
+	if err != nil {
+		return nil, err
+	}
+	proxy := effectiveProxy(ghost, d.proxy)
+	setProxyEnv(&deployment.Spec.Template.Spec.Containers[0], ghost, proxy)
+	setInitProxyEnv(&deployment.Spec.Template.Spec, ghost, proxy)
+	return deployment, nil
+}
+
+func (deploymentChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, deploymentName(ghost), &appsv1.Deployment{})
+}
+
+// Retain keeps the replica count the HorizontalPodAutoscaler chose, only scaling to or from zero for a restore overrides it
+func (deploymentChild) Retain(desired, observed client.Object) {
+	desiredDeployment := desired.(*appsv1.Deployment)
+	replicas := observed.(*appsv1.Deployment).Spec.Replicas
+	if desiredDeployment.Annotations[autoscaledAnnotation] == "true" && replicas != nil && *replicas > 0 && *desiredDeployment.Spec.Replicas > 0 {
+		desiredDeployment.Spec.Replicas = replicas
+	}
+}
+
+// Charge counts the Deployment, its pods and what all of them request
+func (deploymentChild) Charge(desired client.Object) corev1.ResourceList {
+	deployment := desired.(*appsv1.Deployment)
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	charge := podCharge(&deployment.Spec.Template.Spec, replicas)
+	charge[corev1.ResourceName("count/deployments.apps")] = resource.MustParse("1")
+	return charge
+}
+
+// Status reports whether the Deployment finished rolling out its current pod template, the way
+// kubectl rollout status does
+func (deploymentChild) Status(observed client.Object) *metav1.Condition {
+	deployment := observed.(*appsv1.Deployment)
+	rollingOut := func(reason, message string) *metav1.Condition {
+		return &metav1.Condition{Type: deploymentRolledOutCondition, Status: metav1.ConditionFalse, Reason: reason, Message: message}
+	}
+	status := deployment.Status
+	if deployment.Generation > status.ObservedGeneration {
+		return rollingOut("RolloutPending", "Waiting for the Deployment controller to observe the new generation")
+	}
+	// Pods refused by a ResourceQuota never show up, the ReplicaSet only reports the failure
+	if failure := deploymentCondition(deployment, appsv1.DeploymentReplicaFailure); failure != nil && failure.Status == corev1.ConditionTrue && strings.Contains(failure.Message, "exceeded quota") {
+		return rollingOut(quotaExceededCondition, failure.Message)
+	}
+	if progressing := deploymentCondition(deployment, appsv1.DeploymentProgressing); progressing != nil && progressing.Reason == "ProgressDeadlineExceeded" {
+		return rollingOut("ProgressDeadlineExceeded", progressing.Message)
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	switch {
+	case status.UpdatedReplicas < replicas:
+		return rollingOut("RollingOut", fmt.Sprintf("%d of %d replicas updated", status.UpdatedReplicas, replicas))
+	case status.Replicas > status.UpdatedReplicas:
+		return rollingOut("RollingOut", fmt.Sprintf("%d old replicas pending termination", status.Replicas-status.UpdatedReplicas))
+	case status.AvailableReplicas < status.UpdatedReplicas:
+		return rollingOut("RollingOut", fmt.Sprintf("%d of %d updated replicas available", status.AvailableReplicas, status.UpdatedReplicas))
+	}
+	if available := deploymentCondition(deployment, appsv1.DeploymentAvailable); replicas > 0 && (available == nil || available.Status != corev1.ConditionTrue) {
+		return rollingOut("Unavailable", "Deployment does not have minimum availability")
+	}
+	return &metav1.Condition{Type: deploymentRolledOutCondition, Status: metav1.ConditionTrue, Reason: "RolledOut", Message: "Deployment rolled out"}
+}
+
+// deploymentCondition returns the condition of the given type, nil when the Deployment has none
+func deploymentCondition(deployment *appsv1.Deployment, conditionType appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range deployment.Status.Conditions {
+		if deployment.Status.Conditions[i].Type == conditionType {
+			return &deployment.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func generateDesiredDeployment(ghost *marketingv1.Ghost) (*appsv1.Deployment, error) {
+	deployment := &appsv1.Deployment{}
+	err := renderTemplate(deploymentTemplate, templateParams{
+		Name:          deploymentName(ghost),
+		Namespace:     ghost.ObjectMeta.Namespace,
+		AppLabel:      appLabel(ghost),
+		Image:         ghostImage(ghost),
+		Replicas:      desiredReplicas(ghost),
+		ClaimName:     contentClaimName(ghost),
+		ContainerPort: containerPort(ghost),
+	}, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	if autoscaled(ghost) {
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+		deployment.Annotations[autoscaledAnnotation] = "true"
+	}
+	podSpec := &deployment.Spec.Template.Spec
+	container := &podSpec.Containers[0]
+	container.Env = setEnv(container.Env, "NODE_ENV", nodeEnv(ghost))
+	container.Env = applyDatabaseEnv(ghost, container.Env)
+	container.Env = append(container.Env, generateDesiredEnv(ghost)...)
+	container.Resources = containerResources(ghost)
+	container.VolumeMounts = append(container.VolumeMounts, ghost.Spec.ExtraVolumeMounts...)
+	generateProbes(ghost, container)
+	applyShutdown(ghost, podSpec, container)
+	// Ghost logs its fatal errors rather than writing a termination message, status.lastTermination
+	// then carries the tail of the log
+	container.TerminationMessagePolicy = corev1.TerminationMessageFallbackToLogsOnError
+	if ghost.Spec.Image != nil {
+		container.ImagePullPolicy = ghost.Spec.Image.PullPolicy
+		podSpec.ImagePullSecrets = ghost.Spec.Image.PullSecrets
+	}
+
+	podSpec.InitContainers = generateInitContainers(ghost)
+	useEmptyDir(ghost, podSpec)
+	podSpec.Volumes = append(podSpec.Volumes, ghost.Spec.ExtraVolumes...)
+	mountStorageAdapter(ghost, podSpec, container)
+	mountTrustedCABundle(ghost, podSpec, container)
+	mountSiteConfig(ghost, podSpec, container)
+	mountLogVolume(ghost, podSpec, container)
+	applySpreadPolicy(ghost, podSpec, deployment.Spec.Template.Labels)
+	applyPodClasses(ghost, podSpec)
+	pinToContentVolume(ghost, podSpec)
+	pinToArchitectures(ghost, podSpec)
+	template := &deployment.Spec.Template
+	template.Labels = withCommon(template.Labels, ghost.Spec.CommonLabels)
+	template.Annotations = withCommon(template.Annotations, ghost.Spec.CommonAnnotations)
+	if blueGreen(ghost) {
+		template.Labels[slotLabel] = activeSlot(ghost)
+	}
+	if evictionProtected(ghost) {
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[safeToEvictAnnotation] = "false"
+	}
+	if scrape := scrapeAnnotations(ghost); scrape != nil {
+		template.Annotations = withCommon(scrape, template.Annotations)
+	}
+	setConfigChecksum(ghost, &template.ObjectMeta)
+	applyVeleroHooks(ghost, template)
+	// Sidecars go last, appending may move the Ghost container that container points at
+	if exporter := metricsExporterContainer(ghost); exporter != nil {
+		podSpec.Containers = append(podSpec.Containers, *exporter)
+	}
+	if shipper := logShipperContainer(ghost); shipper != nil {
+		podSpec.Containers = append(podSpec.Containers, *shipper)
+	}
+	podSpec.Containers = append(podSpec.Containers, ghost.Spec.Sidecars...)
+	declareExtraPorts(ghost, podSpec)
+	applySecurityContext(ghost, podSpec)
+	applySecurityProfiles(ghost, template)
+	if err := applyPodTemplateOverlay(ghost, template); err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// ghostImage returns the image reference to run, the release a tracked tag resolved to if any,
+// in the architecture spec.image.architectureTags selects
+func ghostImage(ghost *marketingv1.Ghost) string {
+	if trackingTag(ghost) && ghost.Status.ResolvedImage != "" {
+		return ghost.Status.ResolvedImage + architectureTag(ghost)
+	}
+	return specImage(ghost) + architectureTag(ghost)
+}
+
+// specImage returns the image reference the spec asks for, preferring spec.image over
+// spec.imageTag and a pinned digest over any tag
+func specImage(ghost *marketingv1.Ghost) string {
+	repository, tag := defaultImageRepository, ghost.Spec.ImageTag
+	if image := ghost.Spec.Image; image != nil {
+		if image.Repository != "" {
+			repository = image.Repository
+		}
+		if image.Digest != "" {
+			return repository + "@sha256:" + strings.TrimPrefix(image.Digest, "sha256:")
+		}
+		if image.Tag != "" {
+			tag = image.Tag
+		}
+	}
+	if tag == "" {
+		tag = defaultImageTag
+	}
+	return repository + ":" + tag
+}
+
+// applyShutdown sets how long a stopping pod may drain. The preStop sleep keeps Ghost serving
+// until the ingress controller and the kube-proxies have dropped the endpoint, only then it is
+// sent SIGTERM.
+func applyShutdown(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec, container *corev1.Container) {
+	podSpec.TerminationGracePeriodSeconds = ghost.Spec.TerminationGracePeriodSeconds
+	if sleep := ghost.Spec.PreStopSleepSeconds; sleep != nil {
+		// An exec sleep rather than the sleep action, which older clusters reject
+		container.Lifecycle = &corev1.Lifecycle{PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{Command: []string{"sleep", strconv.Itoa(int(*sleep))}},
+		}}
+	}
+}
+
+// generateDesiredEnv renders the spec driven environment appended to the template's defaults
+func generateDesiredEnv(ghost *marketingv1.Ghost) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	if url := ghostURL(ghost); url != "" {
+		// Ghost builds absolute links, emails and newsletters with it, and redirects to it
+		env = append(env, corev1.EnvVar{Name: "url", Value: url})
+	}
+	env = append(env, generateMailEnv(ghost.Spec.Mail)...)
+	env = append(env, generateRedisCacheEnv(ghost)...)
+	if ghost.Spec.Timezone != "" {
+		env = append(env, corev1.EnvVar{Name: "TZ", Value: ghost.Spec.Timezone})
+	}
+	if ghost.Spec.ContainerPort != 0 {
+		// Ghost itself honours the port, wrappers listening elsewhere simply ignore it
+		env = append(env, corev1.EnvVar{Name: "server__port", Value: strconv.Itoa(int(ghost.Spec.ContainerPort))})
+	}
+	if ghost.Spec.AccessLogs != nil && loggingLevel(ghost) == "" {
+		// Ghost logs every request at info level
+		level := "warn"
+		if ghost.Spec.AccessLogs.Enabled {
+			level = "info"
+		}
+		env = append(env, corev1.EnvVar{Name: "logging__level", Value: level})
+	}
+	env = append(env, generateLoggingEnv(ghost)...)
+	return env
+}
+
+// generateMailEnv renders Ghost's mail__* settings, sourcing the password from a Secret
+func generateMailEnv(mail *marketingv1.MailSpec) []corev1.EnvVar {
+	if mail == nil {
+		return nil
+	}
+	env := []corev1.EnvVar{
+		{
+			Name:  "mail__transport",
+			Value: mail.Transport,
+		},
+		{
+			Name:  "mail__options__host",
+			Value: mail.Host,
+		},
+		{
+			Name:  "mail__options__port",
+			Value: strconv.Itoa(int(mail.Port)),
+		},
+	}
+	if mail.User != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  "mail__options__auth__user",
+			Value: mail.User,
+		})
+	}
+	if mail.PasswordSecretRef != nil {
+		env = append(env, corev1.EnvVar{
+			Name: "mail__options__auth__pass",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: mail.PasswordSecretRef,
+			},
+		})
+	}
+	if mail.From != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  "mail__from",
+			Value: mail.From,
+		})
+	}
+	return env
+}
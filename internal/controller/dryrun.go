@@ -0,0 +1,83 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// dryRun reports whether spec.reconcileMode holds the changes to the children of the Ghost back
+func dryRun(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.ReconcileMode == marketingv1.ReconcileModeDryRun
+}
+
+// readOnly reports whether nothing may be changed for the Ghost, operator wide or for this Ghost alone
+func (r *GhostReconciler) readOnly(ghost *marketingv1.Ghost) bool {
+	return r.ReadOnly || dryRun(ghost)
+}
+
+// reportPendingChanges records the changes a read-only pass held back in status.pendingChanges,
+// and emits them as an event whenever they change so kubectl describe shows what applying would do
+func (r *GhostReconciler) reportPendingChanges(ghost *marketingv1.Ghost, changes []string) {
+	if !r.readOnly(ghost) {
+		ghost.Status.PendingChanges = nil
+		return
+	}
+	slices.Sort(changes)
+	if len(changes) > 0 && !slices.Equal(changes, ghost.Status.PendingChanges) {
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "PendingChanges", strings.Join(changes, "; "))
+	}
+	ghost.Status.PendingChanges = changes
+}
+
+// changedFields lists the paths at which the applied content differs from the live one. Lists
+// are compared whole, and the bookkeeping the API server does on every change is left out.
+func changedFields(applied, live map[string]interface{}, prefix string) []string {
+	var fields []string
+	keys := make([]string, 0, len(applied)+len(live))
+	for key := range applied {
+		keys = append(keys, key)
+	}
+	for key := range live {
+		if _, ok := applied[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		path := prefix + key
+		if path == "metadata.generation" || path == "metadata.resourceVersion" {
+			continue
+		}
+		a, b := applied[key], live[key]
+		nestedA, okA := a.(map[string]interface{})
+		nestedB, okB := b.(map[string]interface{})
+		switch {
+		case okA && okB:
+			fields = append(fields, changedFields(nestedA, nestedB, path+".")...)
+		case !equality.Semantic.DeepEqual(a, b):
+			fields = append(fields, path)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// errorBudgetWindow is the sliding window the failed reconciles of a Ghost are counted in
+const errorBudgetWindow = time.Hour
+
+// ErrorBudget flags the Ghosts whose reconciles fail more often than allowed, so a flapping
+// instance stands out from the failures the controller error counters add up for the fleet
+type ErrorBudget struct {
+	// FailuresPerHour a single Ghost may have before it is flagged
+	FailuresPerHour int
+	// Now is the clock the failures are recorded with, time.Now when unset
+	Now func() time.Time
+
+	mu       sync.Mutex
+	failures map[types.NamespacedName][]time.Time
+	exceeded map[types.NamespacedName]bool
+}
+
+// record notes the outcome of a reconcile and returns the failures of the Ghost within the
+// window, whether they exceed the budget and whether they just started to
+func (b *ErrorBudget) record(key types.NamespacedName, failed bool) (failures int, exceeded, crossed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures == nil {
+		b.failures = map[types.NamespacedName][]time.Time{}
+		b.exceeded = map[types.NamespacedName]bool{}
+	}
+	now := b.now()
+	recent := b.failures[key]
+	for len(recent) > 0 && now.Sub(recent[0]) >= errorBudgetWindow {
+		recent = recent[1:]
+	}
+	if failed {
+		recent = append(recent, now)
+	}
+	if len(recent) == 0 {
+		delete(b.failures, key)
+	} else {
+		b.failures[key] = recent
+	}
+
+	exceeded = len(recent) > b.FailuresPerHour
+	crossed = exceeded && !b.exceeded[key]
+	if exceeded {
+		b.exceeded[key] = true
+	} else {
+		delete(b.exceeded, key)
+	}
+	return len(recent), exceeded, crossed
+}
+
+// forget drops the failures of a deleted Ghost
+func (b *ErrorBudget) forget(key types.NamespacedName) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, key)
+	delete(b.exceeded, key)
+	reconcileFailures.DeleteLabelValues(key.Namespace, key.Name)
+	reconcileErrorBudgetExceeded.DeleteLabelValues(key.Namespace, key.Name)
+}
+
+func (b *ErrorBudget) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+// trackErrorBudget counts a failed reconcile of the Ghost and warns once it fails more often
+// than the budget allows
+func (r *GhostReconciler) trackErrorBudget(ctx context.Context, req ctrl.Request, err error) {
+	if r.ErrorBudget == nil {
+		return
+	}
+	failures, exceeded, crossed := r.ErrorBudget.record(req.NamespacedName, err != nil)
+	if failures == 0 {
+		reconcileFailures.DeleteLabelValues(req.Namespace, req.Name)
+		reconcileErrorBudgetExceeded.DeleteLabelValues(req.Namespace, req.Name)
+		return
+	}
+	reconcileFailures.WithLabelValues(req.Namespace, req.Name).Set(float64(failures))
+	value := 0.0
+	if exceeded {
+		value = 1
+	}
+	reconcileErrorBudgetExceeded.WithLabelValues(req.Namespace, req.Name).Set(value)
+	if !crossed {
+		return
+	}
+
+	message := fmt.Sprintf("%d reconciles failed within the last hour, more than the budget of %d: %v",
+		failures, r.ErrorBudget.FailuresPerHour, err)
+	log.FromContext(ctx).Info("Ghost exceeded its reconcile error budget", "failures", failures)
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, req.NamespacedName, ghost); err != nil {
+		return
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeWarning, "ReconcileErrorBudgetExceeded", message)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ErrorClass tells the main loop how a failed child reconcile should be retried
+type ErrorClass string
+
+const (
+	// ErrorClassTransient errors are retried with the controller's exponential backoff
+	ErrorClassTransient ErrorClass = "Transient"
+	// ErrorClassInvalidSpec errors cannot succeed until the Ghost spec changes
+	ErrorClassInvalidSpec ErrorClass = "InvalidSpec"
+	// ErrorClassConflict errors come from stale objects and are retried immediately
+	ErrorClassConflict ErrorClass = "Conflict"
+	// ErrorClassExternal errors depend on something outside the cluster or a missing API
+	ErrorClassExternal ErrorClass = "External"
+	// ErrorClassQuotaExceeded errors wait for a ResourceQuota of the namespace to have room
+	ErrorClassQuotaExceeded ErrorClass = "QuotaExceeded"
+	// ErrorClassThrottled errors come from an API server shedding load and are retried after
+	// the delay it asks for
+	ErrorClassThrottled ErrorClass = "Throttled"
+)
+
+const (
+	externalRetryInterval = time.Minute
+	// throttledRetryInterval applies when a throttled request carries no Retry-After
+	throttledRetryInterval = 10 * time.Second
+)
+
+// reconcileError attaches an ErrorClass to an error returned by a child reconciler
+type reconcileError struct {
+	class ErrorClass
+	err   error
+}
+
+func (e *reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reconcileError) Unwrap() error {
+	return e.err
+}
+
+// invalidSpecError marks err as caused by the Ghost spec itself
+func invalidSpecError(err error) error {
+	return &reconcileError{class: ErrorClassInvalidSpec, err: err}
+}
+
+// externalError marks err as caused by a dependency outside the controller's control
+func externalError(err error) error {
+	return &reconcileError{class: ErrorClassExternal, err: err}
+}
+
+// classifyError returns the explicit class of err, falling back to the API status it carries
+func classifyError(err error) ErrorClass {
+	var classified *reconcileError
+	switch {
+	case errors.As(err, &classified):
+		return classified.class
+	case isQuotaExceeded(err):
+		return ErrorClassQuotaExceeded
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return ErrorClassConflict
+	case apierrors.IsTooManyRequests(err), apierrors.IsServerTimeout(err):
+		return ErrorClassThrottled
+	// Changes of immutable fields are refused as invalid too
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return ErrorClassInvalidSpec
+	case meta.IsNoMatchError(err):
+		return ErrorClassExternal
+	default:
+		return ErrorClassTransient
+	}
+}
+
+// resultForError chooses between immediate retry, backoff, a fixed delay or giving up
+func resultForError(err error) (ctrl.Result, error) {
+	switch classifyError(err) {
+	case ErrorClassConflict:
+		return ctrl.Result{Requeue: true}, nil
+	case ErrorClassInvalidSpec:
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	case ErrorClassExternal:
+		return ctrl.Result{RequeueAfter: externalRetryInterval}, nil
+	case ErrorClassQuotaExceeded:
+		return ctrl.Result{RequeueAfter: quotaRetryInterval}, nil
+	case ErrorClassThrottled:
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && seconds > 0 {
+			return ctrl.Result{RequeueAfter: time.Duration(seconds) * time.Second}, nil
+		}
+		return ctrl.Result{RequeueAfter: throttledRetryInterval}, nil
+	default:
+		return ctrl.Result{}, err
+	}
+}
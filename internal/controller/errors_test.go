@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Error classification", func() {
+	It("should classify errors by explicit class before API status", func() {
+		gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+		Expect(classifyError(invalidSpecError(errors.New("bad")))).To(Equal(ErrorClassInvalidSpec))
+		Expect(classifyError(externalError(errors.New("down")))).To(Equal(ErrorClassExternal))
+		Expect(classifyError(apierrors.NewConflict(gr, "ghost", errors.New("stale")))).To(Equal(ErrorClassConflict))
+		Expect(classifyError(apierrors.NewInvalid(schema.GroupKind{Kind: "Ghost"}, "ghost", nil))).To(Equal(ErrorClassInvalidSpec))
+		Expect(classifyError(errors.New("timeout"))).To(Equal(ErrorClassTransient))
+		exceeded := apierrors.NewForbidden(schema.GroupResource{Resource: "persistentvolumeclaims"}, "content",
+			errors.New("exceeded quota: storage, requested: requests.storage=1Gi, used: requests.storage=2Gi, limited: requests.storage=2Gi"))
+		Expect(classifyError(exceeded)).To(Equal(ErrorClassQuotaExceeded))
+		Expect(classifyError(apierrors.NewForbidden(gr, "ghost", errors.New("denied")))).To(Equal(ErrorClassTransient))
+		Expect(classifyError(apierrors.NewTooManyRequests("slow down", 3))).To(Equal(ErrorClassThrottled))
+		Expect(classifyError(apierrors.NewServerTimeout(gr, "get", 0))).To(Equal(ErrorClassThrottled))
+	})
+
+	It("should map each class to a retry decision", func() {
+		result, err := resultForError(apierrors.NewConflict(schema.GroupResource{}, "ghost", errors.New("stale")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Requeue).To(BeTrue())
+
+		result, err = resultForError(externalError(errors.New("down")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(externalRetryInterval))
+
+		result, err = resultForError(quotaExceededError(errors.New("full")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(quotaRetryInterval))
+
+		result, err = resultForError(apierrors.NewTooManyRequests("slow down", 3))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(3 * time.Second))
+
+		result, err = resultForError(apierrors.NewServerTimeout(schema.GroupResource{}, "get", 0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(throttledRetryInterval))
+
+		_, err = resultForError(invalidSpecError(errors.New("bad")))
+		Expect(err).To(HaveOccurred())
+
+		result, err = resultForError(errors.New("timeout"))
+		Expect(err).To(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+})
@@ -0,0 +1,260 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// maxEventSize bounds the payloads read, Ghost sends the full post with post events
+	maxEventSize = 4 << 20
+	// eventSignatureTolerance is how far the timestamp of a signature may be from now, older
+	// events are replays
+	eventSignatureTolerance = 5 * time.Minute
+	// ghostSignatureHeader carries sha256=<hmac of the payload and timestamp>, t=<timestamp in ms>
+	ghostSignatureHeader = "X-Ghost-Signature"
+)
+
+// EventReceiver serves the webhooks Ghost posts for spec.events at
+// /ghost/<namespace>/<name>/<event>. Every event is recorded as a Kubernetes Event on the
+// Ghost, a publication sets status.lastPublishedAt and every event starts the
+// GhostStaticBuilds of the Ghost that build on publish.
+type EventReceiver struct {
+	Client client.Client
+	// APIReader reads the token Secrets, whose data is not cached
+	APIReader client.Reader
+	Recorder  record.EventRecorder
+	// BindAddress is the address the receiver listens on
+	BindAddress string
+	// Now is the clock signatures are checked against, time.Now when unset
+	Now func() time.Time
+	// ReadOnly only records the events, the Ghosts and their static builds are left alone
+	ReadOnly bool
+}
+
+// NeedLeaderElection lets every operator replica receive events
+func (e *EventReceiver) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the receiver until the manager stops
+func (e *EventReceiver) Start(ctx context.Context) error {
+	server := &http.Server{Addr: e.BindAddress, Handler: e, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	log.FromContext(ctx).WithName("event-receiver").Info("Receiving Ghost events", "address", e.BindAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (e *EventReceiver) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+// ServeHTTP checks the signature of the event against the token of the Ghost and acts on it.
+// Events of unknown Ghosts and of events not in spec.events are answered 404.
+func (e *EventReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	log := log.FromContext(ctx).WithName("event-receiver")
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, eventReceiverPathPrefix), "/")
+	if !strings.HasPrefix(req.URL.Path, eventReceiverPathPrefix) || len(parts) != 3 {
+		http.NotFound(w, req)
+		return
+	}
+	namespace, name, event := parts[0], parts[1], marketingv1.GhostEvent(parts[2])
+
+	ghost := &marketingv1.Ghost{}
+	if err := e.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, ghost); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, req)
+			return
+		}
+		log.Error(err, "Failed to read the Ghost", "namespace", namespace, "name", name)
+		http.Error(w, "the Ghost cannot be read", http.StatusInternalServerError)
+		return
+	}
+	if ghost.Spec.Events == nil || !slices.Contains(ghostEvents(ghost), event) {
+		http.NotFound(w, req)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxEventSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxEventSize {
+		http.Error(w, "the event is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	secret := &corev1.Secret{}
+	if err := e.APIReader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: childName(ghost, eventTokenNamePrefix)}, secret); err != nil {
+		log.Error(err, "Failed to read the event token", "namespace", namespace, "name", name)
+		http.Error(w, "the event token cannot be read", http.StatusInternalServerError)
+		return
+	}
+	if !validEventSignature(req.Header.Get(ghostSignatureHeader), body, secret.Data[eventTokenKey], e.now()) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if err := e.handle(ctx, ghost, event, body); err != nil {
+		log.Error(err, "Failed to handle the Ghost event", "namespace", namespace, "name", name, "event", event)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validEventSignature checks the X-Ghost-Signature of the payload, Ghost signs the payload
+// followed by the timestamp
+func validEventSignature(header string, body, token []byte, now time.Time) bool {
+	var signature, timestamp string
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if value, ok := strings.CutPrefix(part, "sha256="); ok {
+			signature = value
+		} else if value, ok := strings.CutPrefix(part, "t="); ok {
+			timestamp = value
+		}
+	}
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(token) == 0 {
+		return false
+	}
+	if age := now.Sub(time.UnixMilli(millis)); age > eventSignatureTolerance || age < -eventSignatureTolerance {
+		return false
+	}
+	sent, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, token)
+	mac.Write(body)
+	mac.Write([]byte(timestamp))
+	return hmac.Equal(sent, mac.Sum(nil))
+}
+
+// ghostEventPayload is the part of the post and page events the receiver reads
+type ghostEventPayload struct {
+	Post *ghostEventResource `json:"post"`
+	Page *ghostEventResource `json:"page"`
+}
+
+type ghostEventResource struct {
+	Current struct {
+		Title       string     `json:"title"`
+		PublishedAt *time.Time `json:"published_at"`
+	} `json:"current"`
+}
+
+// handle records the event on the Ghost, tracks the publications and starts the static builds
+func (e *EventReceiver) handle(ctx context.Context, ghost *marketingv1.Ghost, event marketingv1.GhostEvent, body []byte) error {
+	payload := ghostEventPayload{}
+	// site.changed has no body worth reading, the other events are still acted on without one
+	_ = json.Unmarshal(body, &payload)
+	resource := payload.Post
+	if resource == nil {
+		resource = payload.Page
+	}
+	message := "Ghost posted " + string(event)
+	if resource != nil && resource.Current.Title != "" {
+		message = fmt.Sprintf("Ghost posted %s for %q", event, resource.Current.Title)
+	}
+	e.Recorder.Event(ghost, corev1.EventTypeNormal, ghostEventReason(event), message)
+	if e.ReadOnly {
+		return nil
+	}
+
+	if event == marketingv1.GhostEventPostPublished || event == marketingv1.GhostEventPagePublished {
+		published := metav1.NewTime(e.now())
+		if resource != nil && resource.Current.PublishedAt != nil {
+			published = metav1.NewTime(*resource.Current.PublishedAt)
+		}
+		original := ghost.DeepCopy()
+		ghost.Status.LastPublishedAt = &published
+		if err := e.Client.Status().Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+			return err
+		}
+	}
+
+	builds := &marketingv1.GhostStaticBuildList{}
+	if err := e.Client.List(ctx, builds, client.InNamespace(ghost.Namespace)); err != nil {
+		return err
+	}
+	token := string(event) + "@" + e.now().UTC().Format(time.RFC3339Nano)
+	for i := range builds.Items {
+		build := &builds.Items[i]
+		if build.Spec.GhostRef.Name != ghost.Name || build.Spec.OnPublish == nil {
+			continue
+		}
+		original := build.DeepCopy()
+		if build.Annotations == nil {
+			build.Annotations = map[string]string{}
+		}
+		build.Annotations[staticBuildAnnotation] = token
+		if err := e.Client.Patch(ctx, build, client.MergeFrom(original)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ghostEventReason turns an event such as post.published into the reason PostPublished
+func ghostEventReason(event marketingv1.GhostEvent) string {
+	var reason strings.Builder
+	for _, word := range strings.Split(string(event), ".") {
+		if word != "" {
+			reason.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return reason.String()
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Event receiver", func() {
+	const namespace = "ghost-events"
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+
+	sign := func(body, token string, at time.Time) string {
+		timestamp := strconv.FormatInt(at.UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(token))
+		mac.Write([]byte(body + timestamp))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil)) + ", t=" + timestamp
+	}
+
+	BeforeEach(func() {
+		for _, obj := range []client.Object{
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+			&marketingv1.Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+				Spec: marketingv1.GhostSpec{
+					ImageTag: "latest",
+					Replicas: 1,
+					Events: &marketingv1.EventsSpec{
+						AdminAPIKeySecretRef: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "admin-key"}, Key: "key"},
+					},
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: eventTokenNamePrefix + "blog", Namespace: namespace},
+				StringData: map[string]string{eventTokenKey: "s3cr3t"},
+			},
+			&marketingv1.GhostStaticBuild{
+				ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: namespace},
+				Spec: marketingv1.GhostStaticBuildSpec{
+					GhostRef: corev1.LocalObjectReference{Name: "blog"},
+					OnPublish: &marketingv1.StaticBuildOnPublish{
+						AdminAPIKeySecretRef: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "admin-key"}, Key: "key"},
+					},
+					Destination: marketingv1.StaticBuildDestination{Nginx: &marketingv1.StaticBuildNginx{}},
+				},
+			},
+		} {
+			if err := k8sClient.Create(ctx, obj); !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+	})
+
+	It("should act on the signed events of the Ghost only", func() {
+		recorder := record.NewFakeRecorder(100)
+		receiver := &EventReceiver{
+			Client:    k8sClient,
+			APIReader: k8sClient,
+			Recorder:  recorder,
+			Now:       func() time.Time { return now },
+		}
+		post := func(path, body, signature string) int {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set(ghostSignatureHeader, signature)
+			res := httptest.NewRecorder()
+			receiver.ServeHTTP(res, req)
+			return res.Code
+		}
+		body := `{"post":{"current":{"title":"Hello","published_at":"2026-10-17T08:59:00.000Z"}}}`
+
+		By("rejecting forged, replayed and unknown events")
+		Expect(post("/ghost/"+namespace+"/blog/post.published", body, sign(body, "guess", now))).To(Equal(http.StatusUnauthorized))
+		Expect(post("/ghost/"+namespace+"/blog/post.published", body, sign(body, "s3cr3t", now.Add(-time.Hour)))).To(Equal(http.StatusUnauthorized))
+		Expect(post("/ghost/"+namespace+"/blog/member.added", body, sign(body, "s3cr3t", now))).To(Equal(http.StatusNotFound))
+		Expect(post("/ghost/"+namespace+"/other/post.published", body, sign(body, "s3cr3t", now))).To(Equal(http.StatusNotFound))
+		Expect(recorder.Events).To(BeEmpty())
+
+		By("recording a publication")
+		Expect(post("/ghost/"+namespace+"/blog/post.published", body, sign(body, "s3cr3t", now))).To(Equal(http.StatusNoContent))
+		Eventually(recorder.Events).Should(Receive(Equal(`Normal PostPublished Ghost posted post.published for "Hello"`)))
+		ghost := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "blog"}, ghost)).To(Succeed())
+		Expect(ghost.Status.LastPublishedAt.UTC()).To(Equal(time.Date(2026, 10, 17, 8, 59, 0, 0, time.UTC)))
+		build := &marketingv1.GhostStaticBuild{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "site"}, build)).To(Succeed())
+		Expect(build.Annotations).To(HaveKeyWithValue(staticBuildAnnotation, "post.published@2026-10-17T09:00:00Z"))
+
+		By("starting the static builds on a site change")
+		receiver.Now = func() time.Time { return now.Add(time.Minute) }
+		Expect(post("/ghost/"+namespace+"/blog/site.changed", "{}", sign("{}", "s3cr3t", now))).To(Equal(http.StatusNoContent))
+		Eventually(recorder.Events).Should(Receive(Equal("Normal SiteChanged Ghost posted site.changed")))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "site"}, build)).To(Succeed())
+		Expect(build.Annotations).To(HaveKeyWithValue(staticBuildAnnotation, "site.changed@2026-10-17T09:01:00Z"))
+
+		By("only recording the events in read-only mode")
+		receiver.ReadOnly = true
+		receiver.Now = func() time.Time { return now.Add(2 * time.Minute) }
+		body = `{"post":{"current":{"title":"Later","published_at":"2026-10-17T09:01:30.000Z"}}}`
+		Expect(post("/ghost/"+namespace+"/blog/post.published", body, sign(body, "s3cr3t", now))).To(Equal(http.StatusNoContent))
+		Eventually(recorder.Events).Should(Receive(Equal(`Normal PostPublished Ghost posted post.published for "Later"`)))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "blog"}, ghost)).To(Succeed())
+		Expect(ghost.Status.LastPublishedAt.UTC()).To(Equal(time.Date(2026, 10, 17, 8, 59, 0, 0, time.UTC)))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "site"}, build)).To(Succeed())
+		Expect(build.Annotations).To(HaveKeyWithValue(staticBuildAnnotation, "site.changed@2026-10-17T09:01:00Z"))
+	})
+})
@@ -0,0 +1,155 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// maxOwnerDepth bounds the owner chain walked from an event to its Ghost, a Pod is three
+// levels below it through its ReplicaSet and Deployment
+const maxOwnerDepth = 3
+
+// childKindAliases maps API kinds to the child kinds of the pipeline that name them differently
+var childKindAliases = map[string]string{"PersistentVolumeClaim": "PVC"}
+
+// EventSummaryReconciler records the latest Warning event about the children of a Ghost in
+// status.children, so a failing child shows up without correlating events by hand
+type EventSummaryReconciler struct {
+	client.Client
+	// APIReader follows owner references without caching every kind an event may involve
+	APIReader client.Reader
+	// Channel is the release channel of this operator deployment, stable when empty
+	Channel string
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts/status,verbs=get;update;patch
+
+// Reconcile summarises a Warning event into the status of the Ghost controlling the object it
+// is about
+func (r *EventSummaryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	event := &corev1.Event{}
+	if err := r.Get(ctx, req.NamespacedName, event); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if event.Type != corev1.EventTypeWarning {
+		return ctrl.Result{}, nil
+	}
+	kind, key, err := r.owningGhost(ctx, event.InvolvedObject)
+	if err != nil || kind == "" {
+		return ctrl.Result{}, err
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, key, ghost); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !inChannel(ghost, r.Channel) {
+		return ctrl.Result{}, nil
+	}
+
+	original := ghost.DeepCopy()
+	message := fmt.Sprintf("%s %s: %s", event.InvolvedObject.Kind, event.InvolvedObject.Name, event.Message)
+	if !recordChildError(ghost, kind, event.Reason, message, metav1.NewTime(eventTime(event))) {
+		return ctrl.Result{}, nil
+	}
+	// The inventory is a list, replacing it must not drop what the Ghost controller wrote meanwhile
+	if err := r.Status().Patch(ctx, ghost, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil {
+		log.Error(err, "Failed to update Ghost status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// owningGhost follows the controller references of the involved object up to a Ghost. It
+// returns the kind of the child the Ghost controls and the Ghost, an empty kind when the
+// object does not belong to a Ghost.
+func (r *EventSummaryReconciler) owningGhost(ctx context.Context, ref corev1.ObjectReference) (string, types.NamespacedName, error) {
+	gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+	name := ref.Name
+	for range maxOwnerDepth {
+		object := &metav1.PartialObjectMetadata{}
+		object.SetGroupVersionKind(gvk)
+		err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: name}, object)
+		if client.IgnoreNotFound(err) != nil && !meta.IsNoMatchError(err) {
+			return "", types.NamespacedName{}, err
+		}
+		owner := metav1.GetControllerOf(object)
+		if err != nil || owner == nil {
+			return "", types.NamespacedName{}, nil
+		}
+		ownerGVK := schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind)
+		if ownerGVK.GroupKind() == marketingv1.GroupVersion.WithKind("Ghost").GroupKind() {
+			kind := gvk.Kind
+			if alias, ok := childKindAliases[kind]; ok {
+				kind = alias
+			}
+			return kind, types.NamespacedName{Namespace: ref.Namespace, Name: owner.Name}, nil
+		}
+		gvk, name = ownerGVK, owner.Name
+	}
+	return "", types.NamespacedName{}, nil
+}
+
+// eventTime is when the event was last seen, whichever API wrote it
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *EventSummaryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Event{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			event, ok := obj.(*corev1.Event)
+			return ok && event.Type == corev1.EventTypeWarning
+		}))).
+		Named("ghost-event-summary").
+		Complete(r)
+}
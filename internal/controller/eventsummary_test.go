@@ -0,0 +1,124 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Event summary", func() {
+	const namespace = "event-summary"
+
+	It("should record the latest Warning event of each child in the Ghost status", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		ghost := &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1},
+		}
+		Expect(k8sClient.Create(ctx, ghost)).To(Succeed())
+		create := func(object, owner client.Object) {
+			Expect(controllerutil.SetControllerReference(owner, object, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, object)).To(Succeed())
+		}
+		claim := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "ghost-data-pvc-blog", Namespace: namespace},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources:   corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}},
+			},
+		}
+		create(claim, ghost)
+		labels := map[string]string{"app": "blog"}
+		template := corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "ghost", Image: "ghost:latest"}}},
+		}
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "ghost-deployment-blog", Namespace: namespace},
+			Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}, Template: template},
+		}
+		create(deployment, ghost)
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "ghost-deployment-blog-5d8f", Namespace: namespace},
+			Spec:       appsv1.ReplicaSetSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}, Template: template},
+		}
+		create(replicaSet, deployment)
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "ghost-deployment-blog-5d8f-x2k9q", Namespace: namespace, Labels: labels},
+			Spec:       template.Spec,
+		}
+		create(pod, replicaSet)
+
+		reconciler := &EventSummaryReconciler{Client: k8sClient, APIReader: k8sClient}
+		seen := time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+		summarise := func(name, eventType string, involved client.Object, kind, reason, message string, at time.Time) {
+			event := &corev1.Event{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				InvolvedObject: corev1.ObjectReference{
+					APIVersion: "v1",
+					Kind:       kind,
+					Namespace:  namespace,
+					Name:       involved.GetName(),
+					UID:        involved.GetUID(),
+				},
+				Type:          eventType,
+				Reason:        reason,
+				Message:       message,
+				LastTimestamp: metav1.NewTime(at),
+			}
+			Expect(k8sClient.Create(ctx, event)).To(Succeed())
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(event)})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		children := func() []marketingv1.ChildStatus {
+			reconciled := &marketingv1.Ghost{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "blog"}, reconciled)).To(Succeed())
+			return reconciled.Status.Children
+		}
+
+		By("following a Pod up to the Deployment the Ghost controls")
+		summarise("pull", corev1.EventTypeWarning, pod, "Pod", "Failed", "Back-off pulling image", seen)
+		Expect(children()).To(ContainElement(And(
+			HaveField("Kind", "Deployment"),
+			HaveField("Reason", "Failed"),
+			HaveField("LastError", "Pod "+pod.Name+": Back-off pulling image"),
+			HaveField("LastErrorTime.Time", BeTemporally("==", seen)),
+		)))
+
+		By("keying the claim by its pipeline kind and ignoring Normal events")
+		summarise("provisioning", corev1.EventTypeWarning, claim, "PersistentVolumeClaim", "ProvisioningFailed", "storageclass not found", seen)
+		summarise("provisioned", corev1.EventTypeNormal, claim, "PersistentVolumeClaim", "Provisioned", "volume bound", seen.Add(time.Minute))
+		Expect(children()).To(ContainElement(And(HaveField("Kind", "PVC"), HaveField("Reason", "ProvisioningFailed"))))
+
+		By("keeping the newer error over an older event")
+		summarise("stale", corev1.EventTypeWarning, pod, "Pod", "FailedMount", "volume not attached", seen.Add(-time.Hour))
+		Expect(children()).To(ContainElement(And(HaveField("Kind", "Deployment"), HaveField("Reason", "Failed"))))
+	})
+})
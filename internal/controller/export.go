@@ -0,0 +1,122 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// exportAnnotation set to "true" on a Ghost publishes its rendered children in a ConfigMap
+	exportAnnotation    = "marketing.kb.dev/export"
+	exportNamePrefix    = "ghost-export-"
+	exportManifestsFile = "manifests.yaml"
+)
+
+// RenderManifests returns every child the operator would create for the Ghost as a
+// multi-document YAML stream, without owner references, ready for kubectl apply.
+func RenderManifests(ghost *marketingv1.Ghost, routeAPIAvailable, monitoringAPIAvailable bool) ([]byte, error) {
+	r := &GhostReconciler{RouteAPIAvailable: routeAPIAvailable, MonitoringAPIAvailable: monitoringAPIAvailable}
+	return r.renderManifests(ghost)
+}
+
+func (r *GhostReconciler) renderManifests(ghost *marketingv1.Ghost) ([]byte, error) {
+	var out bytes.Buffer
+	for _, stage := range r.childStages() {
+		for _, child := range stage {
+			if _, ok := child.(exportChild); ok {
+				continue
+			}
+			desired, err := desire(child, ghost)
+			if err != nil {
+				return nil, err
+			}
+			if desired == nil {
+				continue
+			}
+			if err := writeManifest(&out, desired); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, redirect := range redirects(ghost) {
+		ingress, err := generateRedirectIngress(ghost, redirect, r.WildcardCertificate)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeManifest(&out, ingress); err != nil {
+			return nil, err
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// writeManifest appends an object to a multi-document YAML stream
+func writeManifest(out *bytes.Buffer, obj client.Object) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	out.WriteString("---\n")
+	out.Write(data)
+	return nil
+}
+
+// exportChild publishes the rendered manifests in a ConfigMap while the export annotation is set
+type exportChild struct {
+	reconciler *GhostReconciler
+}
+
+func (exportChild) Kind() string {
+	return "Export"
+}
+
+func (e exportChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if ghost.Annotations[exportAnnotation] != "true" {
+		return nil, nil
+	}
+	manifests, err := e.reconciler.renderManifests(ghost)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, exportNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Data: map[string]string{
+			exportManifestsFile: string(manifests),
+		},
+	}, nil
+}
+
+func (exportChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, exportNamePrefix), &corev1.ConfigMap{})
+}
+
+func (exportChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// A Ghost in PortForwardOnly mode is only reached through a port-forward. The operator manages a
+// ServiceAccount allowed to port-forward to the Ghost pods, kubectl ghost open requests a short lived
+// token for it, so users only need to be allowed to request that token rather than port-forward
+// to everything in the namespace.
+const portForwardNamePrefix = "ghost-portforward-"
+
+// The operator can only grant what it holds itself
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
+// +kubebuilder:rbac:groups="",resources=pods/portforward,verbs=create
+
+// portForwardOnly reports whether the Ghost is kept off NodePorts, load balancers and routes
+func portForwardOnly(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.Exposure != nil && ghost.Spec.Exposure.Mode == marketingv1.ExposureModePortForwardOnly
+}
+
+// routed reports whether the Ghost asks for a route, whichever routing API serves it
+func routed(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.EnableIngress && !portForwardOnly(ghost)
+}
+
+// PortForwardServiceAccountName is the ServiceAccount allowed to port-forward to the Ghost pods
+func PortForwardServiceAccountName(ghost *marketingv1.Ghost) string {
+	return childName(ghost, portForwardNamePrefix)
+}
+
+// ServiceName is the Service in front of the Ghost pods
+func ServiceName(ghost *marketingv1.Ghost) string {
+	return childName(ghost, svcNamePrefix)
+}
+
+// portForwardTokenRule lets the owning team request port-forward tokens for the Ghost
+func portForwardTokenRule(ghost *marketingv1.Ghost) rbacv1.PolicyRule {
+	return rbacv1.PolicyRule{
+		APIGroups:     []string{""},
+		Resources:     []string{"serviceaccounts/token"},
+		ResourceNames: []string{PortForwardServiceAccountName(ghost)},
+		Verbs:         []string{"create"},
+	}
+}
+
+// portForwardServiceAccountChild manages the ServiceAccount the port-forward tokens are issued for
+type portForwardServiceAccountChild struct{}
+
+func (portForwardServiceAccountChild) Kind() string {
+	return "ServiceAccount"
+}
+
+func (portForwardServiceAccountChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !portForwardOnly(ghost) {
+		return nil, nil
+	}
+	return &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      PortForwardServiceAccountName(ghost),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		// The tokens are only presented to the API server, never mounted
+		AutomountServiceAccountToken: ptr.To(false),
+	}, nil
+}
+
+func (portForwardServiceAccountChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, PortForwardServiceAccountName(ghost), &corev1.ServiceAccount{})
+}
+
+func (portForwardServiceAccountChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// portForwardRoleChild manages the Role allowing the port-forward to the Ghost pods
+type portForwardRoleChild struct{}
+
+func (portForwardRoleChild) Kind() string {
+	return "PortForwardRole"
+}
+
+func (portForwardRoleChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !portForwardOnly(ghost) {
+		return nil, nil
+	}
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      PortForwardServiceAccountName(ghost),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		// Pod names are not known up front, so the pods of the namespace cannot be narrowed down further
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{""},
+				Resources:     []string{"services"},
+				ResourceNames: []string{ServiceName(ghost)},
+				Verbs:         []string{"get"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"pods"},
+				Verbs:     []string{"get", "list"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"pods/portforward"},
+				Verbs:     []string{"create"},
+			},
+		},
+	}, nil
+}
+
+func (portForwardRoleChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, PortForwardServiceAccountName(ghost), &rbacv1.Role{})
+}
+
+func (portForwardRoleChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// portForwardRoleBindingChild binds the port-forward Role to the ServiceAccount
+type portForwardRoleBindingChild struct{}
+
+func (portForwardRoleBindingChild) Kind() string {
+	return "PortForwardRoleBinding"
+}
+
+func (portForwardRoleBindingChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !portForwardOnly(ghost) {
+		return nil, nil
+	}
+	name := PortForwardServiceAccountName(ghost)
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      name,
+			Namespace: ghost.ObjectMeta.Namespace,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		},
+	}, nil
+}
+
+func (portForwardRoleBindingChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, PortForwardServiceAccountName(ghost), &rbacv1.RoleBinding{})
+}
+
+func (portForwardRoleBindingChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
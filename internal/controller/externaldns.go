@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// Annotations external-dns publishes the records of an Ingress or Service from
+const (
+	externalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+	externalDNSTTLAnnotation      = "external-dns.alpha.kubernetes.io/ttl"
+)
+
+// dnsReadyCondition reports whether the hosts external-dns publishes resolve yet
+const dnsReadyCondition = "DNSReady"
+
+// HostResolver looks the addresses of a host up, net.Resolver implements it
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// externalDNS returns the external-dns settings of the Ghost, nil unless they are enabled
+func externalDNS(ghost *marketingv1.Ghost) *marketingv1.ExternalDNSSpec {
+	if ghost.Spec.Ingress == nil || ghost.Spec.Ingress.ExternalDNS == nil || !ghost.Spec.Ingress.ExternalDNS.Enabled {
+		return nil
+	}
+	return ghost.Spec.Ingress.ExternalDNS
+}
+
+// externalDNSAnnotations publishes the given hosts of the Ghost, nil unless external-dns is enabled
+// and an Ingress or a LoadBalancer Service carries them. The hosts are derived from the spec, the
+// further annotations win.
+func externalDNSAnnotations(ghost *marketingv1.Ghost, hosts []string) map[string]string {
+	dns := externalDNS(ghost)
+	if dns == nil || !(ingressEnabled(ghost) || externalDNSOnService(ghost)) {
+		return nil
+	}
+	annotations := map[string]string{externalDNSHostnameAnnotation: strings.Join(hosts, ",")}
+	if dns.TTL != nil {
+		annotations[externalDNSTTLAnnotation] = strconv.Itoa(int(*dns.TTL))
+	}
+	return withCommon(dns.Annotations, annotations)
+}
+
+// externalDNSOnService reports whether the Service carries the external-dns annotations, which
+// it only does for a Ghost exposed through a LoadBalancer Service instead of an Ingress
+func externalDNSOnService(ghost *marketingv1.Ghost) bool {
+	return !ingressEnabled(ghost) && ghost.Spec.Service != nil && ghost.Spec.Service.Type == corev1.ServiceTypeLoadBalancer
+}
+
+// observeDNS reports in the DNSReady condition whether every host external-dns publishes resolves.
+// A record that is not there yet leaves the Ghost ready, Ghost serves whoever reaches it.
+func (r *GhostReconciler) observeDNS(ctx context.Context, ghost *marketingv1.Ghost) {
+	hosts := append(ingressHosts(ghost), redirectHosts(ghost)...)
+	if externalDNSAnnotations(ghost, hosts) == nil || r.DNS == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, dnsReadyCondition)
+		return
+	}
+	var missing []string
+	for _, host := range hosts {
+		// A wildcard record only answers for the names below it
+		if strings.HasPrefix(host, "*.") {
+			continue
+		}
+		if addresses, err := r.DNS.LookupHost(ctx, host); err != nil || len(addresses) == 0 {
+			missing = append(missing, host)
+		}
+	}
+	if len(missing) > 0 {
+		setCondition(ghost, dnsReadyCondition, metav1.ConditionFalse, "RecordMissing",
+			"Waiting for external-dns to publish "+strings.Join(missing, ", "))
+		return
+	}
+	setCondition(ghost, dnsReadyCondition, metav1.ConditionTrue, "RecordsResolved", "Every host resolves")
+}
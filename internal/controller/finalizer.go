@@ -0,0 +1,87 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// cleanupFinalizer holds a deleted Ghost until the artifacts its owner references do not reach are removed
+const cleanupFinalizer = "marketing.kb.dev/cleanup"
+
+// certificateNameAnnotation is set by cert-manager on the Secrets it issues
+const certificateNameAnnotation = "cert-manager.io/certificate-name"
+
+// cleanupStep removes one kind of artifact left behind by a deleted Ghost
+type cleanupStep func(ctx context.Context, ghost *marketingv1.Ghost) error
+
+// cleanupSteps run in order before the cleanup finalizer is released
+func (r *GhostReconciler) cleanupSteps() []cleanupStep {
+	return []cleanupStep{
+		r.deleteIssuedCertificateSecret,
+	}
+}
+
+// finalize runs the cleanup of a deleted Ghost and releases it. Read-only operators leave the
+// finalizer to the instance allowed to delete.
+func (r *GhostReconciler) finalize(ctx context.Context, ghost *marketingv1.Ghost) error {
+	if r.readOnly(ghost) || !controllerutil.ContainsFinalizer(ghost, cleanupFinalizer) {
+		return nil
+	}
+	for _, step := range r.cleanupSteps() {
+		if err := step(ctx, ghost); err != nil {
+			return err
+		}
+	}
+	controllerutil.RemoveFinalizer(ghost, cleanupFinalizer)
+	return r.Update(ctx, ghost)
+}
+
+// ensureFinalizer adds the cleanup finalizer to a live Ghost
+func (r *GhostReconciler) ensureFinalizer(ctx context.Context, ghost *marketingv1.Ghost) error {
+	if r.readOnly(ghost) || !controllerutil.AddFinalizer(ghost, cleanupFinalizer) {
+		return nil
+	}
+	return r.Update(ctx, ghost)
+}
+
+// deleteIssuedCertificateSecret removes the TLS Secret cert-manager issued for the Ghost's
+// Certificate, cert-manager does not set an owner reference on it by default
+func (r *GhostReconciler) deleteIssuedCertificateSecret(ctx context.Context, ghost *marketingv1.Ghost) error {
+	name := childName(ghost, tlsSecretNamePrefix)
+	if tlsEnabled(ghost) {
+		name = tlsSecretName(ghost)
+	}
+	secret, err := observeChild(ctx, r.secretReader(), ghost.ObjectMeta.Namespace, name, &corev1.Secret{})
+	if err != nil || secret == nil {
+		return err
+	}
+	if secret.GetAnnotations()[certificateNameAnnotation] != childName(ghost, certificateNamePrefix) {
+		return nil
+	}
+	if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "CertificateSecretDeleted", "Secret "+name+" issued by cert-manager deleted")
+	return nil
+}
@@ -18,33 +18,108 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"golang.org/x/time/rate"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
 )
 
-const pvcNamePrefix = "ghost-data-pvc-"
-const deploymentNamePrefix = "ghost-deployment-"
-const svcNamePrefix = "ghost-service-"
-const ingressNamePrefix = "ghost-ingress-"
+const childPollInterval = 30 * time.Second
 
 // GhostReconciler reconciles a Ghost object
 type GhostReconciler struct {
 	client.Client
 	Scheme  *runtime.Scheme
 	Recoder record.EventRecorder
+	// RouteAPIAvailable is detected at startup and gates the OpenShift Route routing mode
+	RouteAPIAvailable bool
+	// MonitoringAPIAvailable is detected at startup and gates the monitors of spec.monitoring
+	MonitoringAPIAvailable bool
+	// ReadOnly reports drift in the Ghost status instead of creating, updating or deleting children
+	ReadOnly bool
+	// Proxy is the operator wide egress proxy for Ghosts without spec.proxy
+	Proxy *marketingv1.ProxySpec
+	// WildcardCertificate serves the Ingress of Ghosts under its domain without TLS of their own
+	WildcardCertificate *WildcardCertificate
+	// APIReader reads Secrets without caching them cluster wide
+	APIReader client.Reader
+	// Channel is the release channel of this operator deployment, stable when empty
+	Channel string
+	// MaxConcurrentReconciles is how many Ghosts are reconciled in parallel, one when unset
+	MaxConcurrentReconciles int
+	// BaseBackoff and MaxBackoff bound the per Ghost retry delay after failed reconciles, which
+	// doubles from the base up to the max. Unset keeps the controller-runtime defaults.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// ErrorBudget flags the Ghosts failing to reconcile too often, off when unset
+	ErrorBudget *ErrorBudget
+	// VolumeStats measures the content volume for status.storage and spec.persistence.autoExpand,
+	// which are both off when unset
+	VolumeStats VolumeStatsReader
+	// DefaultTheme is installed and activated on new Ghosts, which keep Casper when unset
+	DefaultTheme *DefaultTheme
+	// Sites checks the Admin API of running Ghosts for the ApplicationHealthy condition and
+	// status.ghostVersion, which are both off when unset
+	Sites SiteReader
+	// Setup completes the initial setup of Ghosts with spec.adminBootstrap, which is off when unset
+	Setup GhostSetup
+	// Smoke requests spec.upgradePolicy.smokePath before a blue/green upgrade switches, which
+	// switches on readiness alone when unset
+	Smoke SmokeTester
+	// HTTPChecks runs spec.verification.httpCheck, which is not checked when nil
+	HTTPChecks HTTPChecker
+	// Redis checks the Redis cache of Ghosts with spec.cache.redis for the CacheReachable
+	// condition, which is off when unset
+	Redis RedisPinger
+	// Tags looks up the releases Ghosts with spec.imageTagPolicy track, which run their tag as
+	// it is when unset
+	Tags TagLister
+	// Platforms looks up the architectures of the Ghost image, the pods are only pinned to
+	// the spec.image.architectures when unset
+	Platforms PlatformLister
+	// AdminURL overrides the address the Admin API of a Ghost is reached at, the Ghost Service when unset
+	AdminURL func(*marketingv1.Ghost) string
+	// LoadShedder defers the volume stats and drift scans and stretches the polls while the API
+	// server throttles the operator, the operator always observes fully when unset
+	LoadShedder *LoadShedder
+	// DNS looks up the hosts published through external-dns, the DNSReady condition is not reported when nil
+	DNS HostResolver
+	// EventReceiverURL is the address of the event receiver Ghost posts the events of spec.events
+	// to, spec.events is not registered when empty
+	EventReceiverURL string
+	// Notifier posts the lifecycle events of Ghosts with spec.notifications, which are not posted when nil
+	Notifier Notifier
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+	// Sharding restricts the controller to the Ghosts of the shard of this replica, all when nil
+	Sharding *Sharding
+	// ResyncPeriod reconciles every Ghost in full at this interval even when nothing changed,
+	// Ghosts without spec.resyncPeriod are only reconciled on changes and polls when zero
+	ResyncPeriod time.Duration
+	// ownerIndexed is set once the owner index is registered, the orphans are only looked for through it
+	ownerIndexed bool
 }
 
 // +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts,verbs=get;list;watch;create;update;patch;delete
@@ -57,391 +132,598 @@ type GhostReconciler struct {
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Ghost object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile runs one pass over a Ghost. The pass finalizes a deleted Ghost, stops at the pause,
+// fills in the namespace and operator defaults, observes what changes without any event (routing,
+// the Deployment, storage, the application itself) and then skips the children when their desired
+// hash and the cluster still agree, or otherwise applies them and reports their readiness in the
+// status conditions.
 //
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.0/pkg/reconcile
+// Once the pass is over its duration is recorded for the shard, its error is charged to the
+// error budget of the Ghost and its outcome is written to the Reconciling and Stalled conditions.
+// Errors are retried the way their class asks for, a Ghost whose state could not be reported is
+// requeued.
 func (r *GhostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+	result, err := r.reconcileGhost(ctx, req)
+	shardReconcileDuration.WithLabelValues(r.Sharding.name()).Observe(time.Since(start).Seconds())
+	r.trackErrorBudget(ctx, req, err)
+	stateErr := r.reportReconcileState(ctx, req, err)
+	if err != nil && !errors.Is(err, reconcile.TerminalError(nil)) {
+		// An error passed up as it came is retried the way its class asks for as well
+		return resultForError(err)
+	}
+	if apierrors.IsConflict(stateErr) {
+		// The state was reported against a stale Ghost, the next pass reports it again
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return result, err
+}
+
+// reconcileGhost runs a single pass over the Ghost and its children
+func (r *GhostReconciler) reconcileGhost(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
+	// The Ghosts of the namespaces out of scope are left to another operator install
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
 	ghost := &marketingv1.Ghost{}
 	if err := r.Get(ctx, req.NamespacedName, ghost); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.forgetGhost(req.NamespacedName)
+		}
 		log.Error(err, "Failed to get Ghost")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-	// Initialize completion status flags
-	// Add or update the namespace first
-	pvcReady := false
-	deploymentReady := false
-	serviceReady := false
-	ingressReady := false
-
-	log.Info("Reconciling Ghost", "imageTag", ghost.Spec.ImageTag, "team", ghost.ObjectMeta.Namespace)
-	// Add PVC if not exists
-	if err := r.addPvcIfNotExists(ctx, ghost); err != nil {
-		log.Error(err, "Failed to add PVC for Ghost")
-		addCondition(&ghost.Status, "PVCNotReady", metav1.ConditionFalse, "PVCNotReady", "Failed to add PVC for Ghost")
-		return ctrl.Result{}, err
-	} else {
-		pvcReady = true
+	// Child events reach every channel, the Ghost is left to the deployment of its own
+	if !inChannel(ghost, r.Channel) {
+		return ctrl.Result{}, nil
 	}
-	// Add or update Deployment
-	if err := r.addOrUpdateDeployment(ctx, ghost); err != nil {
-		log.Error(err, "Failed to add or update Deployment for Ghost")
-		addCondition(&ghost.Status, "DeploymentNotReady", metav1.ConditionFalse, "DeploymentNotReady", "Failed to add or update Deployment for Ghost")
-		return ctrl.Result{}, err
-	} else {
-		deploymentReady = true
+	// and to the replica of its shard, which another replica stops reporting on when it moves
+	if !r.Sharding.Owns(ghost) {
+		r.forgetGhost(req.NamespacedName)
+		return ctrl.Result{}, nil
 	}
-	// Add or update Service
-	if err := r.addServiceIfNotExists(ctx, ghost); err != nil {
-		log.Error(err, "Failed to add Service for Ghost")
-		addCondition(&ghost.Status, "ServiceNotReady", metav1.ConditionFalse, "ServiceNotReady", "Failed to add Service for Ghost")
-		return ctrl.Result{}, err
-	} else {
-		serviceReady = true
+	// Owner references cover the children, anything else is removed before the Ghost goes away,
+	// even while it is paused so a paused Ghost can still be deleted
+	if !ghost.DeletionTimestamp.IsZero() {
+		if err := r.updateStatus(ctx, ghost.DeepCopy(), ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		if err := r.finalize(ctx, ghost); err != nil {
+			log.Error(err, "Failed to clean up after the Ghost")
+			return ctrl.Result{}, r.stepFailed(ctx, nil, ghost, "CleanupFailed", err)
+		}
+		return ctrl.Result{}, nil
 	}
-	// Add or update Ingress
-	if err := r.addIngressIfNotExists(ctx, ghost); err != nil {
-		log.Error(err, "Failed to add Ingress for Ghost")
-		addCondition(&ghost.Status, "IngressNotReady", metav1.ConditionFalse, "IngressNotReady", "Failed to add Ingress for Ghost")
-		return ctrl.Result{}, err
-	} else {
-		ingressReady = true
+	paused, err := r.reconcilePaused(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to look up the pause annotation")
+		return ctrl.Result{}, r.stepFailed(ctx, nil, ghost, "PauseLookupFailed", err)
 	}
-	// Check if all subresources are ready
-	if pvcReady && deploymentReady && serviceReady && ingressReady {
-		// Add your desired condition when all subresources are ready
-		addCondition(&ghost.Status, "GhostReady", metav1.ConditionTrue, "AllSubresourcesReady", "All subresources are ready")
+	if paused != nil {
+		log.Info("Reconciliation paused", "reason", paused.Reason)
+		// Only the status is written, so manual maintenance on the children is not reverted
+		original := ghost.DeepCopy()
+		meta.SetStatusCondition(&ghost.Status.Conditions, *paused)
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
 	}
-	log.Info("Reconciliation complete")
-	if err := r.updateStatus(ctx, ghost); err != nil {
-		log.Error(err, "Failed to update Ghost status")
-		return ctrl.Result{}, err
+	// A Ghost replaced by a renamed one is left alone until the new Ghost deletes it
+	if to := ghost.Annotations[renamedToAnnotation]; to != "" {
+		log.Info("Ghost is being renamed, skipping reconcile", "renamedTo", to)
+		return ctrl.Result{}, nil
 	}
-
-	return ctrl.Result{}, nil
-}
-
-func (r *GhostReconciler) addPvcIfNotExists(ctx context.Context, ghost *marketingv1.Ghost) error {
-	log := log.FromContext(ctx)
-
-	pvc := &corev1.PersistentVolumeClaim{}
-	team := ghost.ObjectMeta.Namespace
-	pvcName := pvcNamePrefix + team
-
-	err := r.Get(ctx, client.ObjectKey{Namespace: ghost.ObjectMeta.Namespace, Name: pvcName}, pvc)
-
-	if err == nil {
-		log.Info("PVC already exists", "pvc", pvcName)
-		return nil
+	if err := r.ensureFinalizer(ctx, ghost); err != nil {
+		log.Error(err, "Failed to add the cleanup finalizer")
+		return ctrl.Result{}, r.stepFailed(ctx, nil, ghost, "FinalizerUpdateFailed", err)
 	}
-
-	// PVC does not exist, create it
-	desiredPVC := generateDesiredPVC(ghost, pvcName)
-	if err := controllerutil.SetControllerReference(ghost, desiredPVC, r.Scheme); err != nil {
-		return err
+	if err := r.pinChildNaming(ctx, ghost); err != nil {
+		log.Error(err, "Failed to pin the child names")
+		return ctrl.Result{}, r.stepFailed(ctx, nil, ghost, "ChildNamingFailed", err)
 	}
-
-	if err := r.Create(ctx, desiredPVC); err != nil {
-		return err
+	if err := r.syncBaseDomain(ctx, ghost); err != nil {
+		log.Error(err, "Failed to look up the base domain")
+		return ctrl.Result{}, r.stepFailed(ctx, nil, ghost, "BaseDomainLookupFailed", err)
 	}
-	r.Recoder.Event(ghost, corev1.EventTypeNormal, "PVCReady", "PVC created successfully")
-	log.Info("PVC created", "pvc", pvcName)
-	return nil
-}
-
-func generateDesiredPVC(ghost *marketingv1.Ghost, pvcName string) *corev1.PersistentVolumeClaim {
-	return &corev1.PersistentVolumeClaim{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      pvcName,
-			Namespace: ghost.ObjectMeta.Namespace,
-		},
-		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-			Resources: corev1.VolumeResourceRequirements{
-				Requests: corev1.ResourceList{
-					corev1.ResourceStorage: resource.MustParse("1Gi"),
-				},
-			},
-		},
+	if err := r.startRename(ctx, ghost); err != nil {
+		log.Error(err, "Failed to take over from the renamed Ghost")
+		return ctrl.Result{}, r.stepFailed(ctx, nil, ghost, "RenameFailed", err)
 	}
-}
-
-func (r *GhostReconciler) addOrUpdateDeployment(ctx context.Context, ghost *marketingv1.Ghost) error {
-	log := log.FromContext(ctx)
-
-	desiredDeployment := generateDesiredDeployment(ghost)
-	existingDeployment := &appsv1.Deployment{}
-	err := r.Get(ctx, client.ObjectKey{Namespace: ghost.ObjectMeta.Namespace, Name: deploymentNamePrefix + ghost.ObjectMeta.Namespace}, existingDeployment)
-	if err != nil && client.IgnoreNotFound(err) != nil {
-		return err
+	// The namespace and then the operator defaults fill in what the spec leaves unset before
+	// anything is derived from it
+	warnings, err := applyNamespaceDefaults(ctx, r.Client, ghost)
+	if err != nil {
+		log.Error(err, "Failed to read the namespace defaults")
+		return ctrl.Result{}, r.stepFailed(ctx, nil, ghost, "NamespaceDefaultsFailed", err)
 	}
-
-	if err == nil {
-		log.Info("Deployment already exists", "deployment", deploymentNamePrefix+existingDeployment.ObjectMeta.Namespace)
-
-		// Compare relevant fields to determine if an update is needed
-		canUpdateDeployment := *existingDeployment.Spec.Replicas != ghost.Spec.Replicas || existingDeployment.Spec.Template.Spec.Containers[0].Image != "ghost:"+ghost.Spec.ImageTag
-		if canUpdateDeployment {
-			// Fields have changed, update the deployment
-			existingDeployment.Spec = desiredDeployment.Spec
-			if err := r.Update(ctx, existingDeployment); err != nil {
-				return err
+	for _, warning := range warnings {
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "InvalidNamespaceDefault", warning)
+	}
+	config, err := operatorConfig(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "Failed to read the operator configuration")
+		return ctrl.Result{}, r.stepFailed(ctx, nil, ghost, "OperatorConfigFailed", err)
+	}
+	applyOperatorDefaults(ghost, config)
+	original := ghost.DeepCopy()
+	meta.RemoveStatusCondition(&ghost.Status.Conditions, reconciliationPausedCondition)
+	// The volume topology feeds the Deployment's node affinity, so it is part of the desired state
+	volumeAffinity, err := contentVolumeNodeAffinity(ctx, r.Client, ghost)
+	if err != nil {
+		log.Error(err, "Failed to look up the content volume topology")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "VolumeTopologyLookupFailed", err)
+	}
+	ghost.Status.ContentVolumeNodeAffinity = volumeAffinity
+	// So is the release a tracked image tag resolves to
+	r.resolveImageTag(ctx, ghost)
+	// And the architectures the nodes running it need
+	r.detectArchitectures(ctx, ghost)
+	// And the address Ghost builds its links with
+	ghost.Status.URL = publicURL(ghost, r.WildcardCertificate)
+	// Load balancers assign their addresses without any change to the Ghost
+	if err := r.observeRouting(ctx, ghost); err != nil {
+		log.Error(err, "Failed to look up the Ghost addresses")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "RoutingLookupFailed", err)
+	}
+	if err := r.observeDeployment(ctx, ghost); err != nil {
+		log.Error(err, "Failed to look up the Ghost Deployment")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "DeploymentLookupFailed", err)
+	}
+	// Renewals of the wildcard certificate are copied even when nothing else changed
+	if err := r.reflectWildcardSecret(ctx, ghost); err != nil {
+		log.Error(err, "Failed to copy the wildcard TLS secret")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "WildcardSecretCopyFailed", err)
+	}
+	// A cache going away leaves the pods ready, so Redis is asked on every pass like Ghost itself
+	if err := r.observeRedisCache(ctx, ghost); err != nil {
+		log.Error(err, "Failed to configure the Redis cache")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "RedisCacheFailed", err)
+	}
+	// Records are published without any change to the Ghost, so they are looked up on every pass too
+	r.observeDNS(ctx, ghost)
+	// The pods read their Secrets and ConfigMaps once, a change rolls them through the pod template
+	if err := r.observeConfigChecksum(ctx, ghost); err != nil {
+		log.Error(err, "Failed to hash the referenced configuration")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "ConfigChecksumFailed", err)
+	}
+	// Usage grows without any change to the Ghost, so it is polled on every pass the API server
+	// is not under pressure
+	shedding := r.LoadShedder.Shedding()
+	if !shedding {
+		pvc, usage, err := r.observeStorage(ctx, ghost)
+		if err != nil {
+			log.Error(err, "Failed to measure the content volume")
+			return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "VolumeStatsFailed", err)
+		}
+		if err := r.autoExpandVolume(ctx, ghost, pvc, usage); err != nil {
+			log.Error(err, "Failed to expand the content volume")
+			return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "VolumeExpandFailed", err)
+		}
+	}
+	// A broken database leaves the pods ready, so Ghost itself is asked on every pass
+	r.observeApplication(ctx, ghost)
+	r.bootstrapAdmin(ctx, ghost)
+	r.registerBuildHook(ctx, ghost)
+	r.registerEventWebhooks(ctx, ghost)
+	// Routes and redirects can be changed in Ghost's admin, so they are compared on every pass
+	r.syncSiteConfig(ctx, ghost)
+	// Skip the full pass when nothing has changed since the last successful reconcile
+	desiredHash, err := r.hashDesiredChildren(ghost)
+	if err != nil {
+		log.Error(err, "Failed to hash desired children")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "DesiredHashFailed", err)
+	}
+	// Children can all exist while nothing runs because an image cannot be pulled or the pods crash
+	failure, err := r.podFailure(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to inspect Ghost pods")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "PodInspectionFailed", err)
+	}
+	// Drift outside the watched resources only shows on a full pass, which the resync period forces
+	resync := r.resyncDue(ghost)
+	if resync {
+		log.Info("Resync period elapsed, reconciling in full")
+	}
+	if !resync && ghost.Status.ObservedGeneration == ghost.Generation && ghost.Status.DesiredHash == desiredHash && failure == nil && allConditionsTrue(&ghost.Status) {
+		// The drift scan reads every child, under pressure the unchanged hash is trusted instead
+		inSync := shedding
+		if !shedding {
+			inSync, err = r.childrenInSync(ctx, ghost)
+			if err != nil {
+				log.Error(err, "Failed to compare the children with their desired state")
+				return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "DriftCheckFailed", err)
 			}
-			log.Info("Deployment updated", "deployment", existingDeployment.Name)
-			r.Recoder.Event(ghost, corev1.EventTypeNormal, "DeploymentUpdated", "Deployment updated successfully")
-		} else {
-			log.Info("Deployment is up to date, no action required", "deployment", existingDeployment.Name)
 		}
-		return nil
+		if inSync {
+			log.Info("Desired state unchanged, skipping reconcile", "hash", desiredHash)
+			// Replica counts move without any change to the children
+			if err := r.updateStatus(ctx, original, ghost); err != nil {
+				log.Error(err, "Failed to update Ghost status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: r.pollInterval(ghost)}, nil
+		}
 	}
 
-	// Deployment does not exist, create it
-	if err := controllerutil.SetControllerReference(ghost, desiredDeployment, r.Scheme); err != nil {
-		return err
+	log.Info("Reconciling Ghost", "image", ghostImage(ghost), "team", ghost.ObjectMeta.Namespace)
+	// A Ghost claiming a host another instance already routes is held so it cannot take its traffic
+	conflict, err := r.hostConflict(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to check the Ghost hosts")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "HostCheckFailed", err)
 	}
-	if err := r.Create(ctx, desiredDeployment); err != nil {
-		return err
+	if conflict != "" {
+		setCondition(ghost, hostConflictCondition, metav1.ConditionTrue, "HostClaimed", conflict)
+		setCondition(ghost, "GhostReady", metav1.ConditionFalse, "HostConflict", conflict)
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "HostConflict", conflict)
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, externalError(errors.New(conflict))
 	}
-
-	r.Recoder.Event(ghost, corev1.EventTypeNormal, "DeploymentCreated", "Deployment created successfully")
-	log.Info("Deployment created", "team", ghost.ObjectMeta.Namespace)
-	return nil
-}
-
-func generateDesiredDeployment(ghost *marketingv1.Ghost) *appsv1.Deployment {
-	return &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      deploymentNamePrefix + ghost.ObjectMeta.Namespace,
-			Namespace: ghost.ObjectMeta.Namespace,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &ghost.Spec.Replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": "ghost-" + ghost.ObjectMeta.Namespace,
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "ghost-" + ghost.ObjectMeta.Namespace,
-					},
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "ghost",
-							Image: "ghost:" + ghost.Spec.ImageTag,
-							Env: []corev1.EnvVar{
-								{
-									Name:  "NODE_ENV",
-									Value: "development",
-								},
-								{
-									Name:  "database__connection__filename",
-									Value: "/var/lib/ghost/content/data/ghost.db",
-								},
-							},
-							Ports: []corev1.ContainerPort{
-								{
-									ContainerPort: 2368,
-								},
-							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "ghost-data",
-									MountPath: "/var/lib/ghost/content",
-								},
-							},
-						},
-					},
-					Volumes: []corev1.Volume{
-						{
-							Name: "ghost-data",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: "ghost-data-pvc-" + ghost.ObjectMeta.Namespace,
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+	meta.RemoveStatusCondition(&ghost.Status.Conditions, hostConflictCondition)
+	r.reportMonitoring(ghost)
+	// An image skipping a major version is not rolled out, Ghost cannot migrate the database across it
+	skip, err := r.majorVersionSkip(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to check the Ghost upgrade")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "UpgradeCheckFailed", err)
 	}
-}
-
-func (r *GhostReconciler) addServiceIfNotExists(ctx context.Context, ghost *marketingv1.Ghost) error {
-	log := log.FromContext(ctx)
-	service := &corev1.Service{}
-	err := r.Get(ctx, client.ObjectKey{Namespace: ghost.ObjectMeta.Namespace, Name: svcNamePrefix + ghost.ObjectMeta.Namespace}, service)
-	if err != nil && client.IgnoreNotFound(err) != nil {
-		return err
+	if skip != "" {
+		if !meta.IsStatusConditionTrue(ghost.Status.Conditions, upgradeBlockedCondition) {
+			r.Recoder.Event(ghost, corev1.EventTypeWarning, "UpgradeBlocked", skip)
+		}
+		setCondition(ghost, upgradeBlockedCondition, metav1.ConditionTrue, "MajorVersionSkipped", skip)
+		setCondition(ghost, "GhostReady", metav1.ConditionFalse, "UpgradeBlocked", skip)
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		// Only a change of the spec can unblock the upgrade
+		return ctrl.Result{}, nil
 	}
-
-	if err == nil {
-		log.Info("Service already exists", "service", svcNamePrefix+ghost.ObjectMeta.Namespace)
-		return nil
+	meta.RemoveStatusCondition(&ghost.Status.Conditions, upgradeBlockedCondition)
+	// Neither is an image the operator configuration does not allow
+	if notAllowed := imageNotAllowed(ghost, config); notAllowed != "" {
+		if !meta.IsStatusConditionTrue(ghost.Status.Conditions, imageNotAllowedCondition) {
+			r.Recoder.Event(ghost, corev1.EventTypeWarning, "ImageNotAllowed", notAllowed)
+		}
+		setCondition(ghost, imageNotAllowedCondition, metav1.ConditionTrue, "TagNotAllowed", notAllowed)
+		setCondition(ghost, "GhostReady", metav1.ConditionFalse, "ImageNotAllowed", notAllowed)
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		// A change of the spec or of the operator configuration releases the image
+		return ctrl.Result{}, nil
 	}
-
-	desiredService := generateDesiredService(ghost)
-	if err := controllerutil.SetControllerReference(ghost, desiredService, r.Scheme); err != nil {
-		return err
+	meta.RemoveStatusCondition(&ghost.Status.Conditions, imageNotAllowedCondition)
+	// A new image whose signature has to be verified is not deployed before it is
+	held, err := r.verifySignature(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to verify the image signature")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "SignatureVerificationFailed", err)
 	}
-
-	// Service does not exist, create it
-	if err := r.Create(ctx, desiredService); err != nil {
-		return err
+	if held {
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: childPollInterval}, nil
 	}
-	r.Recoder.Event(ghost, corev1.EventTypeNormal, "ServiceCreated", "Service created successfully")
-	log.Info("Service created", "service", desiredService.Name)
-	return nil
-}
-
-func generateDesiredService(ghost *marketingv1.Ghost) *corev1.Service {
-	return &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      svcNamePrefix + ghost.ObjectMeta.Namespace,
-			Namespace: ghost.ObjectMeta.Namespace,
-		},
-		Spec: corev1.ServiceSpec{
-			Type: corev1.ServiceTypeNodePort,
-			Ports: []corev1.ServicePort{
-				{
-					Port:       80,
-					TargetPort: intstr.FromInt(2368),
-				},
-			},
-			Selector: map[string]string{
-				"app": "ghost-" + ghost.ObjectMeta.Namespace,
-			},
-		},
+	// A new image is held back until the content volume it is about to migrate is snapshotted
+	held, err = r.snapshotBeforeUpgrade(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to snapshot the content volume before upgrading")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "UpgradeSnapshotFailed", err)
 	}
-}
-
-func (r *GhostReconciler) addIngressIfNotExists(ctx context.Context, ghost *marketingv1.Ghost) error {
-	log := log.FromContext(ctx)
-	ingress := &netv1.Ingress{}
-	err := r.Get(ctx, client.ObjectKey{Namespace: ghost.ObjectMeta.Namespace, Name: ingressNamePrefix + ghost.ObjectMeta.Namespace}, ingress)
-	if err != nil && client.IgnoreNotFound(err) != nil {
-		return err
+	if held {
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: childPollInterval}, nil
 	}
-
-	if err == nil {
-		log.Info("Ingress already exists", "ingress", ingressNamePrefix+ghost.ObjectMeta.Namespace)
-		if !ghost.Spec.EnableIngress {
-			log.Info("Disable ingress", "ingress", ingressNamePrefix+ghost.ObjectMeta.Namespace)
-			if err := r.Delete(ctx, ingress); err != nil {
-				return err
-			}
+	// Switching a running Ghost from SQLite to MySQL copies its content before it rolls out
+	held, err = r.migrateDatabase(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to copy the SQLite database to MySQL")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "SQLiteMigrationFailed", err)
+	}
+	if held {
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: childPollInterval}, nil
+	}
+	// With a migration Job the database is migrated to the new image before it rolls out
+	held, err = r.migrateBeforeUpgrade(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to migrate the database before upgrading")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "MigrationFailed", err)
+	}
+	if held {
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: childPollInterval}, nil
+	}
+	// With the BlueGreen strategy the new image is verified next to the running one first
+	held, err = r.upgradeBlueGreen(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to upgrade the idle slot")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "BlueGreenUpgradeFailed", err)
+	}
+	if held {
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: childPollInterval}, nil
+	}
+	r.observeReplicaSafety(ghost)
+	pending, err := r.reconcileChildren(ctx, ghost)
+	if err != nil {
+		// The failed child is recorded in its Reconciled condition
+		reason := "ChildReconcileFailed"
+		if classifyError(err) == ErrorClassQuotaExceeded {
+			reason = quotaExceededCondition
+		}
+		setCondition(ghost, "GhostReady", metav1.ConditionFalse, reason, err.Error())
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+		}
+		return ctrl.Result{}, err
+	}
+	// Pods of a Ghost whose claim is unbound stay in ContainerCreating, so poll until it binds
+	storagePending, err := r.observeClaimBinding(ctx, ghost)
+	if err != nil {
+		log.Error(err, "Failed to observe the content volume binding")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "StorageLookupFailed", err)
+	}
+	pending = pending || storagePending
+	if err := r.reconcileRedirects(ctx, ghost); err != nil {
+		log.Error(err, "Failed to reconcile the redirect Ingresses")
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "RedirectReconcileFailed", err.Error())
+		setCondition(ghost, "GhostReady", metav1.ConditionFalse, "RedirectReconcileFailed", err.Error())
+		if err := r.updateStatus(ctx, original, ghost); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+		}
+		return ctrl.Result{}, err
+	}
+	if err := r.finishRename(ctx, ghost); err != nil {
+		log.Error(err, "Failed to delete the renamed Ghost")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "RenameFailed", err)
+	}
+	if err := r.installDefaultTheme(ctx, ghost); err != nil {
+		log.Error(err, "Failed to request the default theme")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "DefaultThemeFailed", err)
+	}
+	if failure != nil {
+		if failure.imagePull() {
+			setCondition(ghost, imagePullFailedCondition, metav1.ConditionTrue, "ImagePullBackOff", failure.message)
 		} else {
-			log.Info("Ignoring update", "ingress", ingressNamePrefix+ghost.ObjectMeta.Namespace)
+			meta.RemoveStatusCondition(&ghost.Status.Conditions, imagePullFailedCondition)
+		}
+		setCondition(ghost, degradedCondition, metav1.ConditionTrue, failure.reason, failure.message)
+		setCondition(ghost, "GhostReady", metav1.ConditionFalse, failure.reason, failure.message)
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, failure.eventReason(), failure.message)
+		pending = true
+	} else {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, imagePullFailedCondition)
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, degradedCondition)
+		if stopped(ghost) {
+			// Nothing is expected to answer, so a stopped Ghost is neither rolling out nor unhealthy
+			setCondition(ghost, "GhostReady", metav1.ConditionFalse, stoppedReason, "spec.replicas is zero, the Ghost is stopped")
+		} else if storage := meta.FindStatusCondition(ghost.Status.Conditions, storagePendingCondition); storage != nil && storage.Status == metav1.ConditionTrue {
+			setCondition(ghost, "GhostReady", metav1.ConditionFalse, storagePendingCondition, storage.Message)
+		} else if rollout := meta.FindStatusCondition(ghost.Status.Conditions, deploymentRolledOutCondition); rollout != nil && rollout.Status != metav1.ConditionTrue {
+			// Requeued through pending until the pods run the current spec
+			setCondition(ghost, "GhostReady", metav1.ConditionFalse, "RolloutInProgress", rollout.Message)
+		} else if unhealthy := applicationUnhealthy(ghost); unhealthy != "" {
+			setCondition(ghost, "GhostReady", metav1.ConditionFalse, "ApplicationUnhealthy", unhealthy)
+		} else if failed := r.verifyHTTP(ctx, ghost, desiredHash); failed != "" {
+			// Requeued through pending until Ghost answers the check
+			setCondition(ghost, "GhostReady", metav1.ConditionFalse, "HTTPCheckFailed", failed)
+			pending = true
+		} else {
+			// All subresources are ready once every child reconciled and the Deployment rolled out
+			setCondition(ghost, "GhostReady", metav1.ConditionTrue, "AllSubresourcesReady", "All subresources are ready")
 		}
-		return nil
 	}
-
-	// Ignore ingress creation if disabled
-	if !ghost.Spec.EnableIngress {
-		return nil
+	if err := r.trackRollout(ctx, ghost, failure); err != nil {
+		log.Error(err, "Failed to track the Deployment rollout")
+		return ctrl.Result{}, r.stepFailed(ctx, original, ghost, "RolloutTrackingFailed", err)
 	}
-
-	// Ingress does not exist and enabled, create it
-	desiredIngress := generateDesiredIngress(ghost)
-	if err := controllerutil.SetControllerReference(ghost, desiredIngress, r.Scheme); err != nil {
-		return err
+	log.Info("Reconciliation complete")
+	ghost.Status.ObservedGeneration = ghost.Generation
+	ghost.Status.DesiredHash = desiredHash
+	ghost.Status.LastFullReconcileTime = nil
+	if r.resyncPeriod(ghost) > 0 {
+		now := metav1.Now()
+		ghost.Status.LastFullReconcileTime = &now
+	}
+	if err := r.updateStatus(ctx, original, ghost); err != nil {
+		log.Error(err, "Failed to update Ghost status")
+		return ctrl.Result{}, err
 	}
 
-	if err := r.Create(ctx, desiredIngress); err != nil {
-		return err
+	if pending {
+		// Poll until children such as Certificates or HTTPRoutes report ready
+		return ctrl.Result{RequeueAfter: r.stretch(childPollInterval)}, nil
 	}
-	r.Recoder.Event(ghost, corev1.EventTypeNormal, "IngressCreated", "Ingress created successfully")
-	log.Info("Ingress created", "ingress", desiredIngress.Name)
-	return nil
+	return ctrl.Result{RequeueAfter: r.pollInterval(ghost)}, nil
 }
 
-func generateDesiredIngress(ghost *marketingv1.Ghost) *netv1.Ingress {
-	ingressClassName := "nginx"
-	pathType := netv1.PathTypePrefix
+// pollInterval is how long until the next pass measures what changes without any event, the
+// shortest of the enabled polls and the resync of the Ghost, stretched under API server
+// pressure, and zero when none is
+func (r *GhostReconciler) pollInterval(ghost *marketingv1.Ghost) time.Duration {
+	interval := r.storagePollInterval()
+	for _, poll := range []time.Duration{r.applicationPollInterval(), r.untilResync(ghost)} {
+		if poll != 0 && (interval == 0 || poll < interval) {
+			interval = poll
+		}
+	}
+	return r.stretch(interval)
+}
 
-	return &netv1.Ingress{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      ingressNamePrefix + ghost.ObjectMeta.Namespace,
-			Namespace: ghost.ObjectMeta.Namespace,
-		},
-		Spec: netv1.IngressSpec{
-			IngressClassName: &ingressClassName,
-			Rules: []netv1.IngressRule{
-				{
-					Host: ghost.ObjectMeta.Name + ".kb.dev",
-					IngressRuleValue: netv1.IngressRuleValue{
-						HTTP: &netv1.HTTPIngressRuleValue{
-							Paths: []netv1.HTTPIngressPath{
-								{
-									Path:     "/",
-									PathType: &pathType,
-									Backend: netv1.IngressBackend{
-										Service: &netv1.IngressServiceBackend{
-											Name: svcNamePrefix + ghost.ObjectMeta.Namespace,
-											Port: netv1.ServiceBackendPort{
-												Number: 80,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+// stretch lengthens a poll interval while the API server throttles the operator
+func (r *GhostReconciler) stretch(interval time.Duration) time.Duration {
+	if r.LoadShedder.Shedding() {
+		return interval * loadSheddingStretch
 	}
+	return interval
 }
 
-// Function to add a condition to the GhostStatus
-func addCondition(status *marketingv1.GhostStatus, condType string, statusType metav1.ConditionStatus, reason, message string) {
-	for i, existingCondition := range status.Conditions {
-		if existingCondition.Type == condType {
-			// Condition already exists, update it
-			status.Conditions[i].Status = statusType
-			status.Conditions[i].Reason = reason
-			status.Conditions[i].Message = message
-			status.Conditions[i].LastTransitionTime = metav1.Now()
-			return
+// allConditionsTrue reports whether no condition is waiting on something to settle, a stopped
+// Ghost has settled once it is not ready
+func allConditionsTrue(status *marketingv1.GhostStatus) bool {
+	for _, condition := range status.Conditions {
+		if condition.Type == "GhostReady" && condition.Reason == stoppedReason {
+			continue
+		}
+		if condition.Status != metav1.ConditionTrue {
+			return false
 		}
 	}
+	return true
+}
 
-	// Condition does not exist, add it
-	condition := metav1.Condition{
+// setCondition records a condition for the Ghost's current generation, its transition time
+// only moves when the status flips
+func setCondition(ghost *marketingv1.Ghost, condType string, statusType metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&ghost.Status.Conditions, metav1.Condition{
 		Type:               condType,
 		Status:             statusType,
 		Reason:             reason,
 		Message:            message,
-		LastTransitionTime: metav1.Now(),
-	}
-	status.Conditions = append(status.Conditions, condition)
+		ObservedGeneration: ghost.Generation,
+	})
 }
 
 // Function to update the status of the Ghost object
-func (r *GhostReconciler) updateStatus(ctx context.Context, ghost *marketingv1.Ghost) error {
-	// Update the status of the Ghost object
-	if err := r.Status().Update(ctx, ghost); err != nil {
+func (r *GhostReconciler) updateStatus(ctx context.Context, original, ghost *marketingv1.Ghost) error {
+	ghost.Status.Phase = ghostPhase(ghost)
+	ready := 0.0
+	if meta.IsStatusConditionTrue(ghost.Status.Conditions, "GhostReady") {
+		ready = 1
+	}
+	ghostInstanceReady.WithLabelValues(ghost.Namespace, ghost.Name).Set(ready)
+	// Skip the write entirely when nothing changed during this reconcile
+	if equality.Semantic.DeepEqual(original.Status, ghost.Status) {
+		return nil
+	}
+
+	// Patch the accumulated status changes in a single write
+	if err := r.Status().Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+		reason := "StatusUpdateFailed"
+		if apierrors.IsConflict(err) {
+			reason = "StatusUpdateConflict"
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, reason, err.Error())
 		return err
 	}
+	r.notifyLifecycle(ctx, original, ghost)
 
 	return nil
 }
 
+// stepFailed reports a failed reconcile step as a Warning event carrying the error, and
+// persists the status gathered before it unless original is nil, so the observations of
+// the steps that did succeed are not lost with the early return
+func (r *GhostReconciler) stepFailed(ctx context.Context, original, ghost *marketingv1.Ghost, reason string, err error) error {
+	r.Recoder.Event(ghost, corev1.EventTypeWarning, reason, err.Error())
+	if original == nil {
+		return err
+	}
+	if statusErr := r.updateStatus(ctx, original, ghost); statusErr != nil {
+		log.FromContext(ctx).Error(statusErr, "Failed to update Ghost status")
+	}
+	return err
+}
+
+// forgetGhost drops the per Ghost metrics and failure history of a Ghost this replica no longer reconciles
+func (r *GhostReconciler) forgetGhost(key types.NamespacedName) {
+	ghostPaused.DeleteLabelValues(key.Namespace, key.Name)
+	deleteStorageMetrics(key.Namespace, key.Name)
+	ghostInstanceReady.DeleteLabelValues(key.Namespace, key.Name)
+	r.ErrorBudget.forget(key)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *GhostReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Recoder = mgr.GetEventRecorderFor("ghost-controller")
+	if err := indexHosts(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	if err := indexConfigReferences(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	if err := indexChildren(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	r.ownerIndexed = true
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&marketingv1.Ghost{}).
+		For(&marketingv1.Ghost{}, builder.WithPredicates(channelPredicate(r.Channel), shardPredicate(r.Sharding), ghostChangedPredicate())).
+		// A deleted or hand edited child is restored right away. Of the Deployment status only
+		// the ready replicas are mirrored, the rest of the rollout progress would only add reconciles.
+		Owns(&appsv1.Deployment{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{},
+			predicate.Funcs{UpdateFunc: readyReplicasChanged}))).
+		Owns(&appsv1.StatefulSet{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{},
+			predicate.Funcs{UpdateFunc: readyReplicasChanged}))).
+		Owns(&corev1.Service{}).
+		Owns(&netv1.Ingress{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		// Pods are owned through ReplicaSets, so map them back to the Ghosts of their namespace
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.ghostsForPod)).
+		// A namespace relabelled into the scope of the install has its Ghosts picked up
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.ghostsInNamespace),
+			builder.WithPredicates(predicate.Or(predicate.AnnotationChangedPredicate{}, predicate.LabelChangedPredicate{}))).
+		// Only the metadata of the referenced configuration is cached, a new resource version
+		// is all it takes to hash it again
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.ghostsReferencing(ghostSecretIndex)),
+			builder.OnlyMetadata, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.ghostsReferencing(ghostConfigMapIndex)),
+			builder.OnlyMetadata, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&marketingv1.GhostOperatorConfig{}, handler.EnqueueRequestsFromMapFunc(r.allGhosts)).
+		// A raised or freed up quota lets the children it held back be created
+		Watches(&corev1.ResourceQuota{}, handler.EnqueueRequestsFromMapFunc(r.ghostsForQuota)).
+		WithOptions(r.controllerOptions()).
 		Complete(r)
 }
+
+// ghostChangedPredicate drops the Ghost updates that only touch the status, such as the status
+// writes of this and the other Ghost controllers, so they do not cause another full reconcile.
+// Annotations like the pause, rename or channel ones change the behaviour without a new generation.
+func ghostChangedPredicate() predicate.Predicate {
+	return predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{})
+}
+
+// controllerOptions sets the parallelism and retry backoff, so a restarted operator converges
+// on hundreds of Ghosts without serialising them behind slow or failing ones
+func (r *GhostReconciler) controllerOptions() controller.Options {
+	options := controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}
+	if r.Sharding != nil {
+		// Every replica reconciles its own shard, the leader only assigns them
+		options.NeedLeaderElection = ptr.To(false)
+	}
+	if r.BaseBackoff > 0 && r.MaxBackoff > 0 {
+		// The overall bucket of the default limiter still caps bursts of requeues
+		options.RateLimiter = workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](r.BaseBackoff, r.MaxBackoff),
+			&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+		)
+	}
+	return options
+}
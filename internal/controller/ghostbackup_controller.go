@@ -0,0 +1,228 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// backupCompleteCondition is True once the artifact is uploaded
+const backupCompleteCondition = "Complete"
+
+// GhostBackupReconciler runs a Job per GhostBackup and mirrors its outcome in the status
+type GhostBackupReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader lists the backup pods without caching every pod in the cluster
+	APIReader client.Reader
+	// Proxy is the operator wide egress proxy for Ghosts without spec.proxy
+	Proxy *marketingv1.ProxySpec
+	// Notifier posts the failed backups of Ghosts with spec.notifications, which are not posted when nil
+	Notifier Notifier
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostbackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostbackups/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Reconcile starts the backup Job once and follows it until it finishes
+func (r *GhostBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	backup := &marketingv1.GhostBackup{}
+	if err := r.Get(ctx, req.NamespacedName, backup); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if backup.Status.Phase == marketingv1.BackupPhaseSucceeded || backup.Status.Phase == marketingv1.BackupPhaseFailed {
+		return ctrl.Result{}, nil
+	}
+	original := backup.DeepCopy()
+
+	reconcileErr := r.reconcileBackup(ctx, backup)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostBackup")
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed", reconcileErr.Error())
+	}
+	if !equality.Semantic.DeepEqual(original.Status, backup.Status) {
+		if err := r.Status().Patch(ctx, backup, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostBackup status")
+			return ctrl.Result{}, err
+		}
+	}
+	if backup.Status.Phase == marketingv1.BackupPhaseFailed {
+		r.notifyBackupFailed(ctx, backup)
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *GhostBackupReconciler) reconcileBackup(ctx context.Context, backup *marketingv1.GhostBackup) error {
+	observed, err := observeChild(ctx, r.Client, backup.Namespace, backupJobNamePrefix+backup.Name, &batchv1.Job{})
+	if err != nil {
+		return err
+	}
+	if observed == nil {
+		return r.startBackup(ctx, backup)
+	}
+
+	job := observed.(*batchv1.Job)
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			size, err := r.artifactSize(ctx, job)
+			if err != nil {
+				return err
+			}
+			backup.Status.Phase = marketingv1.BackupPhaseSucceeded
+			backup.Status.SizeBytes = size
+			backup.Status.CompletionTime = job.Status.CompletionTime
+			meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+				Type:    backupCompleteCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  "BackupSucceeded",
+				Message: "Backup uploaded to " + backup.Status.Location,
+			})
+			r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupSucceeded", "Backup uploaded to "+backup.Status.Location)
+			return nil
+		case batchv1.JobFailed:
+			backup.Status.Phase = marketingv1.BackupPhaseFailed
+			meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+				Type:    backupCompleteCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  "JobFailed",
+				Message: "Backup job " + job.Name + " failed: " + condition.Message,
+			})
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed", "Backup job "+job.Name+" failed, see its logs")
+			return nil
+		}
+	}
+	if job.Status.Active > 0 {
+		backup.Status.Phase = marketingv1.BackupPhaseRunning
+	}
+	return nil
+}
+
+// startBackup creates the backup Job owned by the GhostBackup
+func (r *GhostBackupReconciler) startBackup(ctx context.Context, backup *marketingv1.GhostBackup) error {
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: backup.Namespace, Name: backup.Spec.GhostRef.Name}, ghost); err != nil {
+		meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+			Type:    backupCompleteCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "GhostNotFound",
+			Message: err.Error(),
+		})
+		return externalError(err)
+	}
+	if _, err := applyNamespaceDefaults(ctx, r.Client, ghost); err != nil {
+		return err
+	}
+	config, err := operatorConfig(ctx, r.Client)
+	if err != nil {
+		return err
+	}
+	applyOperatorDefaults(ghost, config)
+	if ephemeral(ghost) && backup.Spec.Method != marketingv1.BackupMethodExport {
+		backup.Status.Phase = marketingv1.BackupPhaseFailed
+		meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+			Type:    backupCompleteCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoContentVolume",
+			Message: "Ghost " + ghost.Name + " keeps its content in an emptyDir, only the Export method backs it up",
+		})
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed", "Ghost "+ghost.Name+" has no content volume to archive")
+		return nil
+	}
+
+	job := generateBackupJob(backup, ghost, effectiveProxy(ghost, r.Proxy))
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return err
+	}
+	now := metav1.Now()
+	backup.Status.Phase = marketingv1.BackupPhasePending
+	backup.Status.JobName = job.Name
+	backup.Status.Location = backupLocation(backup)
+	backup.Status.StartTime = &now
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:    backupCompleteCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "JobCreated",
+		Message: "Backup job " + job.Name + " created",
+	})
+	r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupStarted", "Backup job "+job.Name+" created")
+	return nil
+}
+
+// artifactSize reads the size the upload container reported, zero when no pod reported it
+func (r *GhostBackupReconciler) artifactSize(ctx context.Context, job *batchv1.Job) (int64, error) {
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{batchv1.JobNameLabel: job.Name}); err != nil {
+		return 0, err
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if status.Name != uploadContainer || terminated == nil || terminated.ExitCode != 0 {
+				continue
+			}
+			if size, err := strconv.ParseInt(strings.TrimSpace(terminated.Message), 10, 64); err == nil {
+				return size, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("GhostBackup Controller", func() {
+	const namespace = "backups"
+
+	It("should run a backup Job and report the uploaded artifact", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: namespace},
+			Spec: marketingv1.GhostBackupSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "blog"},
+				Destination: marketingv1.BackupDestination{
+					URL:                  "s3://backups/ghost/",
+					CredentialsSecretRef: corev1.LocalObjectReference{Name: "s3-credentials"},
+				},
+			},
+		})).To(Succeed())
+
+		reconciler := &GhostBackupReconciler{
+			Client:    k8sClient,
+			Scheme:    k8sClient.Scheme(),
+			Recorder:  record.NewFakeRecorder(100),
+			APIReader: k8sClient,
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "nightly"}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		backup := &marketingv1.GhostBackup{}
+		Expect(k8sClient.Get(ctx, key, backup)).To(Succeed())
+		Expect(backup.Status.Phase).To(Equal(marketingv1.BackupPhasePending))
+		Expect(backup.Status.Location).To(Equal("s3://backups/ghost/backups/nightly.tar.gz"))
+
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: backup.Status.JobName}, job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.InitContainers[0].Name).To(Equal("archive"))
+		Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal(awsCLIImage))
+
+		By("reading the artifact size from the finished upload container")
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      job.Name + "-x1",
+				Namespace: namespace,
+				Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: uploadContainer, Image: awsCLIImage}}},
+		}
+		Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  uploadContainer,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0, Message: "4096\n"}},
+		}}
+		Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+		started := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+		finished := metav1.NewTime(time.Now().Truncate(time.Second))
+		job.Status.StartTime = &started
+		job.Status.CompletionTime = &finished
+		job.Status.Succeeded = 1
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue},
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+		}
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, backup)).To(Succeed())
+		Expect(backup.Status.Phase).To(Equal(marketingv1.BackupPhaseSucceeded))
+		Expect(backup.Status.SizeBytes).To(Equal(int64(4096)))
+		Expect(backup.Status.CompletionTime.Time).To(BeTemporally("==", finished.Time))
+	})
+
+	It("should export through the Admin API and upload to GCS", func() {
+		backup := &marketingv1.GhostBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "export", Namespace: namespace},
+			Spec: marketingv1.GhostBackupSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "blog"},
+				Method:   marketingv1.BackupMethodExport,
+				AdminAPIKeySecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "admin-key"},
+					Key:                  "key",
+				},
+				Destination: marketingv1.BackupDestination{
+					URL:                  "gs://backups",
+					CredentialsSecretRef: corev1.LocalObjectReference{Name: "gcs-credentials"},
+				},
+			},
+		}
+		ghost := &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec: marketingv1.GhostSpec{
+				ImageTag:         "5.80.0",
+				Replicas:         1,
+				EphemeralStorage: &marketingv1.EphemeralStorageSpec{ScratchSizeLimit: ptr.To(resource.MustParse("10Gi"))},
+			},
+		}
+
+		job := generateBackupJob(backup, ghost, nil)
+		Expect(backupLocation(backup)).To(Equal("gs://backups/backups/export.json"))
+		Expect(job.Spec.Template.Spec.InitContainers[0].Image).To(Equal("ghost:5.80.0"))
+		Expect(job.Spec.Template.Spec.Affinity).To(BeNil())
+		Expect(job.Spec.Template.Spec.Volumes[0].EmptyDir.SizeLimit.String()).To(Equal("10Gi"))
+		upload := job.Spec.Template.Spec.Containers[0]
+		Expect(upload.Image).To(Equal(gcloudImage))
+		Expect(upload.VolumeMounts).To(ContainElement(HaveField("MountPath", gcsCredentialsPath)))
+	})
+
+	It("should post a failed backup to the notifications of its Ghost", func() {
+		if err := k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}); !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "notifications", Namespace: namespace},
+			StringData: map[string]string{"url": "https://hooks.example/backups"},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "scratch", Namespace: namespace},
+			Spec: marketingv1.GhostSpec{
+				ImageTag:    "latest",
+				Replicas:    1,
+				Persistence: &marketingv1.PersistenceSpec{Enabled: ptr.To(false)},
+				Notifications: &marketingv1.NotificationsSpec{
+					WebhookURLSecretRef: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "notifications"}, Key: "url"},
+				},
+			},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "scratch-archive", Namespace: namespace},
+			Spec: marketingv1.GhostBackupSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "scratch"},
+				Destination: marketingv1.BackupDestination{
+					URL:                  "s3://backups/ghost/",
+					CredentialsSecretRef: corev1.LocalObjectReference{Name: "s3-credentials"},
+				},
+			},
+		})).To(Succeed())
+
+		notifier := &recordingNotifier{}
+		reconciler := &GhostBackupReconciler{
+			Client:    k8sClient,
+			Scheme:    k8sClient.Scheme(),
+			Recorder:  record.NewFakeRecorder(100),
+			APIReader: k8sClient,
+			Notifier:  notifier,
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "scratch-archive"}
+		for range 2 {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(notifier.URLs).To(Equal([]string{"https://hooks.example/backups"}))
+		Expect(notifier.Notifications).To(ConsistOf(Notification{
+			Text:      "Backup scratch-archive of Ghost backups/scratch failed: Ghost scratch keeps its content in an emptyDir, only the Export method backs it up",
+			Event:     marketingv1.NotificationEventBackupFailed,
+			Namespace: namespace,
+			Ghost:     "scratch",
+		}))
+	})
+})
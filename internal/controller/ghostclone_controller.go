@@ -0,0 +1,317 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// clonedCondition reports the progress of a GhostClone
+	clonedCondition = "Cloned"
+	// clonedByAnnotation records the UID of the GhostClone that created a Ghost, which the
+	// clone does not own so the Ghost outlives it
+	clonedByAnnotation = "marketing.kb.dev/cloned-by"
+	// cloneSnapshotNamePrefix names the VolumeSnapshot taken of the content for a GhostClone
+	cloneSnapshotNamePrefix = "ghost-clone-"
+	// clonePollInterval paces the checks on the snapshot and the new Ghost, neither of which is watched
+	clonePollInterval = 10 * time.Second
+)
+
+// GhostCloneReconciler creates a new Ghost from the spec of an existing one, with its content
+// restored from a VolumeSnapshot of the content volume of the existing Ghost
+type GhostCloneReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostclones,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostclones/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostclones/finalizers,verbs=update
+
+// Reconcile snapshots the content, creates the new Ghost on a restore of it once and follows
+// the Ghost until it is ready
+func (r *GhostCloneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	clone := &marketingv1.GhostClone{}
+	if err := r.Get(ctx, req.NamespacedName, clone); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if clone.Status.Phase == marketingv1.ClonePhaseSucceeded || clone.Status.Phase == marketingv1.ClonePhaseFailed {
+		return ctrl.Result{}, nil
+	}
+	original := clone.DeepCopy()
+
+	reconcileErr := r.reconcileClone(ctx, clone)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostClone")
+		r.Recorder.Event(clone, corev1.EventTypeWarning, "CloneFailed", reconcileErr.Error())
+	}
+	if !equality.Semantic.DeepEqual(original.Status, clone.Status) {
+		if err := r.Status().Patch(ctx, clone, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostClone status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	if clone.Status.Phase == marketingv1.ClonePhaseSucceeded || clone.Status.Phase == marketingv1.ClonePhaseFailed {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: clonePollInterval}, nil
+}
+
+func (r *GhostCloneReconciler) reconcileClone(ctx context.Context, clone *marketingv1.GhostClone) error {
+	if clone.Status.Phase == "" {
+		clone.Status.Phase = marketingv1.ClonePhasePending
+	}
+	created, err := r.clonedGhost(ctx, clone)
+	if err != nil {
+		return err
+	}
+	if created == nil {
+		ghost := &marketingv1.Ghost{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: clone.Namespace, Name: clone.Spec.GhostRef.Name}, ghost); err != nil {
+			r.setCondition(clone, metav1.ConditionFalse, "GhostNotFound", err.Error())
+			return externalError(err)
+		}
+		if ephemeral(ghost) {
+			clone.Status.Phase = marketingv1.ClonePhaseFailed
+			r.setCondition(clone, metav1.ConditionFalse, "Ephemeral", "Ghost "+ghost.Name+" keeps no content volume to clone")
+			return nil
+		}
+		snapshot, err := r.ensureSnapshot(ctx, clone, ghost)
+		if err != nil || snapshot == nil {
+			return err
+		}
+		desired := &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        clone.Name,
+				Namespace:   clone.Namespace,
+				Annotations: map[string]string{clonedByAnnotation: string(clone.UID)},
+			},
+			Spec: cloneSpec(clone, ghost),
+		}
+		// The restored claim has to exist before the new Ghost would create an empty one of that name
+		if err := r.restoreContent(ctx, clone, ghost, desired, snapshot); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, desired); err != nil {
+			return err
+		}
+		r.Recorder.Event(clone, corev1.EventTypeNormal, "GhostCloned",
+			"Ghost "+desired.Name+" created from VolumeSnapshot "+snapshot.GetName()+" of Ghost "+ghost.Name)
+		created = desired
+	}
+
+	clone.Status.GhostName = created.Name
+	clone.Status.URL = created.Status.URL
+	if !meta.IsStatusConditionTrue(created.Status.Conditions, "GhostReady") {
+		clone.Status.Phase = marketingv1.ClonePhaseProvisioning
+		r.setCondition(clone, metav1.ConditionFalse, "GhostPending", "Waiting for Ghost "+created.Name+" to become ready")
+		return nil
+	}
+	now := metav1.Now()
+	clone.Status.Phase = marketingv1.ClonePhaseSucceeded
+	clone.Status.CompletionTime = &now
+	r.setCondition(clone, metav1.ConditionTrue, "GhostReady", "Ghost "+created.Name+" cloned from Ghost "+clone.Spec.GhostRef.Name)
+	r.Recorder.Event(clone, corev1.EventTypeNormal, "CloneSucceeded", "Ghost "+created.Name+" is ready")
+	return nil
+}
+
+// clonedGhost returns the Ghost the clone created, nil while there is none yet. It refuses to
+// take over a Ghost of the same name the clone did not create.
+func (r *GhostCloneReconciler) clonedGhost(ctx context.Context, clone *marketingv1.GhostClone) (*marketingv1.Ghost, error) {
+	ghost := &marketingv1.Ghost{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: clone.Namespace, Name: clone.Name}, ghost)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ghost.Annotations[clonedByAnnotation] != string(clone.UID) {
+		return nil, invalidSpecError(fmt.Errorf("ghost %s/%s already exists and was not created by this clone", ghost.Namespace, ghost.Name))
+	}
+	return ghost, nil
+}
+
+// ensureSnapshot returns the ready VolumeSnapshot the clone is restored from, taking it first
+// unless spec.snapshotRef names one. It returns nil while the snapshot is not ready to use.
+func (r *GhostCloneReconciler) ensureSnapshot(ctx context.Context, clone *marketingv1.GhostClone, ghost *marketingv1.Ghost) (*unstructured.Unstructured, error) {
+	name := cloneSnapshotNamePrefix + clone.Name
+	if clone.Spec.SnapshotRef != nil {
+		name = clone.Spec.SnapshotRef.Name
+	}
+	clone.Status.SnapshotName = name
+	live, err := observeChild(ctx, r.Client, clone.Namespace, name, newUnstructured(volumeSnapshotGVK))
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		if clone.Spec.SnapshotRef != nil {
+			r.setCondition(clone, metav1.ConditionFalse, "SnapshotNotFound", "VolumeSnapshot "+name+" does not exist")
+			return nil, externalError(fmt.Errorf("VolumeSnapshot %s not found", name))
+		}
+		snapshot := generateUpgradeSnapshot(ghost, name, ghostImage(ghost), clone.Spec.VolumeSnapshotClassName)
+		if err := controllerutil.SetControllerReference(clone, snapshot, r.Scheme); err != nil {
+			return nil, err
+		}
+		if err := r.Create(ctx, snapshot); err != nil {
+			return nil, err
+		}
+		clone.Status.Phase = marketingv1.ClonePhaseSnapshotting
+		r.Recorder.Event(clone, corev1.EventTypeNormal, "SnapshotCreated", "VolumeSnapshot "+name+" of the content of Ghost "+ghost.Name+" created")
+		r.setCondition(clone, metav1.ConditionFalse, "SnapshotPending", "Waiting for VolumeSnapshot "+name)
+		return nil, nil
+	}
+
+	snapshot := live.(*unstructured.Unstructured)
+	if claim, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName"); claim != contentClaimName(ghost) {
+		return nil, invalidSpecError(fmt.Errorf("VolumeSnapshot %s is not a snapshot of the content of Ghost %s", name, ghost.Name))
+	}
+	if message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); found {
+		clone.Status.Phase = marketingv1.ClonePhaseFailed
+		r.setCondition(clone, metav1.ConditionFalse, "SnapshotFailed", "VolumeSnapshot "+name+" failed: "+message)
+		return nil, nil
+	}
+	if ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse"); !ready {
+		clone.Status.Phase = marketingv1.ClonePhaseSnapshotting
+		r.setCondition(clone, metav1.ConditionFalse, "SnapshotPending", "Waiting for VolumeSnapshot "+name)
+		return nil, nil
+	}
+	return snapshot, nil
+}
+
+// restoreContent creates the content PVC of the new Ghost from the snapshot. The clone owns the
+// claim until its Ghost takes it over.
+func (r *GhostCloneReconciler) restoreContent(ctx context.Context, clone *marketingv1.GhostClone, ghost, created *marketingv1.Ghost, snapshot *unstructured.Unstructured) error {
+	name := contentClaimName(created)
+	observed, err := observeChild(ctx, r.Client, clone.Namespace, name, &corev1.PersistentVolumeClaim{})
+	if err != nil {
+		return err
+	}
+	if observed != nil {
+		if !isOwnedBy(observed, clone) {
+			return invalidSpecError(fmt.Errorf("PVC %s already exists and was not restored for the clone", name))
+		}
+		return nil
+	}
+	desired, err := desire(pvcChild{}, created)
+	if err != nil {
+		return err
+	}
+	source, err := observeChild(ctx, r.Client, ghost.Namespace, contentClaimName(ghost), &corev1.PersistentVolumeClaim{})
+	if err != nil {
+		return err
+	}
+	if source != nil {
+		// A restore is at least as large as the claim it was taken of
+		pvcChild{}.Retain(desired, source)
+	}
+	claim := desired.(*corev1.PersistentVolumeClaim)
+	if size, found, _ := unstructured.NestedString(snapshot.Object, "status", "restoreSize"); found {
+		if restoreSize, err := resource.ParseQuantity(size); err == nil && restoreSize.Cmp(claim.Spec.Resources.Requests[corev1.ResourceStorage]) > 0 {
+			claim.Spec.Resources.Requests[corev1.ResourceStorage] = restoreSize
+		}
+	}
+	claim.Spec.DataSource = &corev1.TypedLocalObjectReference{
+		APIGroup: ptr.To(volumeSnapshotGVK.Group),
+		Kind:     volumeSnapshotGVK.Kind,
+		Name:     snapshot.GetName(),
+	}
+	if err := controllerutil.SetOwnerReference(clone, claim, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, claim, client.FieldOwner(fieldManager)); err != nil {
+		return err
+	}
+	clone.Status.Phase = marketingv1.ClonePhaseProvisioning
+	r.Recorder.Event(clone, corev1.EventTypeNormal, "ContentRestored", "PVC "+name+" restored from VolumeSnapshot "+snapshot.GetName())
+	return nil
+}
+
+// cloneSpec derives the new Ghost from the one it is cloned from. Unlike a preview the clone is
+// a site of its own, it keeps the production settings but not the addresses of the original.
+func cloneSpec(clone *marketingv1.GhostClone, ghost *marketingv1.Ghost) marketingv1.GhostSpec {
+	spec := *ghost.Spec.DeepCopy()
+	spec.URL = ""
+	spec.Staging = nil
+	// The restored content already has its owner
+	spec.AdminBootstrap = nil
+	if clone.Spec.Image != nil {
+		spec.Image = clone.Spec.Image.DeepCopy()
+	}
+	if clone.Spec.Host == "" {
+		spec.EnableIngress = false
+		spec.Ingress = nil
+		return spec
+	}
+	spec.EnableIngress = true
+	if spec.Ingress == nil {
+		spec.Ingress = &marketingv1.IngressSpec{}
+	}
+	spec.Ingress.Host = clone.Spec.Host
+	spec.Ingress.ExtraHosts = nil
+	if spec.Ingress.TLS != nil {
+		// A Secret named by the original holds the certificate of its host
+		spec.Ingress.TLS.SecretName = ""
+	}
+	return spec
+}
+
+func (r *GhostCloneReconciler) setCondition(clone *marketingv1.GhostClone, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&clone.Status.Conditions, metav1.Condition{
+		Type:    clonedCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostCloneReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostClone{}).
+		Complete(r)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("GhostClone Controller", func() {
+	const namespace = "clones"
+
+	It("should create a new Ghost on a restore of a snapshot of the content", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec: marketingv1.GhostSpec{
+				ImageTag:      "alpine",
+				Replicas:      2,
+				EnableIngress: true,
+				Ingress:       &marketingv1.IngressSpec{Host: "blog.example.com", ExtraHosts: []string{"www.example.com"}},
+			},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: pvcNamePrefix + "blog", Namespace: namespace},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("5Gi"),
+				}},
+			},
+		})).To(Succeed())
+		clone := &marketingv1.GhostClone{
+			ObjectMeta: metav1.ObjectMeta{Name: "spring", Namespace: namespace},
+			Spec: marketingv1.GhostCloneSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "blog"},
+				Host:     "spring.example.com",
+			},
+		}
+		Expect(k8sClient.Create(ctx, clone)).To(Succeed())
+
+		recorder := record.NewFakeRecorder(100)
+		reconciler := &GhostCloneReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: recorder}
+		key := types.NamespacedName{Namespace: namespace, Name: "spring"}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("snapshotting the content of the Ghost")
+		snapshot := newUnstructured(volumeSnapshotGVK)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: cloneSnapshotNamePrefix + "spring"}, snapshot)).To(Succeed())
+		Expect(snapshot.Object["spec"]).To(HaveKeyWithValue("source",
+			map[string]interface{}{"persistentVolumeClaimName": pvcNamePrefix + "blog"}))
+		Expect(k8sClient.Get(ctx, key, clone)).To(Succeed())
+		Expect(clone.Status.Phase).To(Equal(marketingv1.ClonePhaseSnapshotting))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "spring"}, &marketingv1.Ghost{})).NotTo(Succeed())
+
+		By("restoring the snapshot into the claim of a new Ghost once it is ready to use")
+		snapshot.Object["status"] = map[string]interface{}{"readyToUse": true, "restoreSize": "6Gi"}
+		Expect(k8sClient.Status().Update(ctx, snapshot)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		claim := &corev1.PersistentVolumeClaim{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: pvcNamePrefix + "spring"}, claim)).To(Succeed())
+		Expect(claim.Spec.DataSource).To(Equal(&corev1.TypedLocalObjectReference{
+			APIGroup: ptr.To("snapshot.storage.k8s.io"), Kind: "VolumeSnapshot", Name: cloneSnapshotNamePrefix + "spring",
+		}))
+		Expect(claim.Spec.Resources.Requests.Storage().String()).To(Equal("6Gi"))
+		Expect(isOwnedBy(claim, clone)).To(BeTrue())
+
+		created := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "spring"}, created)).To(Succeed())
+		Expect(created.OwnerReferences).To(BeEmpty())
+		Expect(created.Annotations).To(HaveKeyWithValue(clonedByAnnotation, string(clone.UID)))
+		Expect(created.Spec.Replicas).To(Equal(int32(2)))
+		Expect(created.Spec.Ingress.Host).To(Equal("spring.example.com"))
+		Expect(created.Spec.Ingress.ExtraHosts).To(BeEmpty())
+		Expect(k8sClient.Get(ctx, key, clone)).To(Succeed())
+		Expect(clone.Status.Phase).To(Equal(marketingv1.ClonePhaseProvisioning))
+		Expect(clone.Status.GhostName).To(Equal("spring"))
+		Eventually(recorder.Events).Should(Receive(ContainSubstring("GhostCloned")))
+
+		By("succeeding once the new Ghost is ready")
+		meta.SetStatusCondition(&created.Status.Conditions, metav1.Condition{Type: "GhostReady", Status: metav1.ConditionTrue, Reason: "Ready"})
+		created.Status.URL = "https://spring.example.com"
+		Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(k8sClient.Get(ctx, key, clone)).To(Succeed())
+		Expect(clone.Status.Phase).To(Equal(marketingv1.ClonePhaseSucceeded))
+		Expect(clone.Status.URL).To(Equal("https://spring.example.com"))
+		Expect(clone.Status.CompletionTime).NotTo(BeNil())
+	})
+
+	It("should fail to clone a Ghost without a content volume", func() {
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: namespace},
+			Spec: marketingv1.GhostSpec{
+				ImageTag:    "alpine",
+				Replicas:    1,
+				Persistence: &marketingv1.PersistenceSpec{Enabled: ptr.To(false)},
+			},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostClone{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo-copy", Namespace: namespace},
+			Spec:       marketingv1.GhostCloneSpec{GhostRef: corev1.LocalObjectReference{Name: "demo"}},
+		})).To(Succeed())
+
+		reconciler := &GhostCloneReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: record.NewFakeRecorder(100)}
+		key := types.NamespacedName{Namespace: namespace, Name: "demo-copy"}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		clone := &marketingv1.GhostClone{}
+		Expect(k8sClient.Get(ctx, key, clone)).To(Succeed())
+		Expect(clone.Status.Phase).To(Equal(marketingv1.ClonePhaseFailed))
+		Expect(meta.FindStatusCondition(clone.Status.Conditions, clonedCondition).Reason).To(Equal("Ephemeral"))
+	})
+})
@@ -0,0 +1,204 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// contentSyncedCondition reports the outcome of a GhostContentSync
+const contentSyncedCondition = "Synced"
+
+// GhostContentSyncReconciler exports the content of one Ghost and imports it into another
+// in a Job per GhostContentSync, and mirrors its outcome in the status
+type GhostContentSyncReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostcontentsyncs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostcontentsyncs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostcontentsyncs/finalizers,verbs=update
+
+// Reconcile starts the sync Job once and follows it until it finishes
+func (r *GhostContentSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	sync := &marketingv1.GhostContentSync{}
+	if err := r.Get(ctx, req.NamespacedName, sync); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if sync.Status.Phase == marketingv1.ContentSyncPhaseSucceeded || sync.Status.Phase == marketingv1.ContentSyncPhaseFailed {
+		return ctrl.Result{}, nil
+	}
+	original := sync.DeepCopy()
+
+	reconcileErr := r.reconcileSync(ctx, sync)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostContentSync")
+		r.Recorder.Event(sync, corev1.EventTypeWarning, "SyncFailed", reconcileErr.Error())
+	}
+	if !equality.Semantic.DeepEqual(original.Status, sync.Status) {
+		if err := r.Status().Patch(ctx, sync, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostContentSync status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *GhostContentSyncReconciler) reconcileSync(ctx context.Context, sync *marketingv1.GhostContentSync) error {
+	if sync.Status.StartTime == nil {
+		now := metav1.Now()
+		sync.Status.StartTime = &now
+		sync.Status.Phase = marketingv1.ContentSyncPhasePending
+	}
+	source, err := r.endpointGhost(ctx, sync, sync.Spec.Source)
+	if err != nil {
+		r.setCondition(sync, metav1.ConditionFalse, "SourceUnavailable", err.Error())
+		return err
+	}
+	target, err := r.endpointGhost(ctx, sync, sync.Spec.Target)
+	if err != nil {
+		r.setCondition(sync, metav1.ConditionFalse, "TargetUnavailable", err.Error())
+		return err
+	}
+	sync.Status.Source = source.Namespace + "/" + source.Name
+	sync.Status.Target = target.Namespace + "/" + target.Name
+	if source.UID == target.UID {
+		sync.Status.Phase = marketingv1.ContentSyncPhaseFailed
+		r.setCondition(sync, metav1.ConditionFalse, "SameGhost", "The source and target are both Ghost "+sync.Status.Source)
+		return nil
+	}
+
+	observed, err := observeChild(ctx, r.Client, sync.Namespace, contentSyncJobNamePrefix+sync.Name, &batchv1.Job{})
+	if err != nil {
+		return err
+	}
+	if observed == nil {
+		job := generateContentSyncJob(sync, source, target)
+		if err := controllerutil.SetControllerReference(sync, job, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return err
+		}
+		sync.Status.Phase = marketingv1.ContentSyncPhaseRunning
+		sync.Status.JobName = job.Name
+		r.setCondition(sync, metav1.ConditionFalse, "JobCreated", "Sync job "+job.Name+" created")
+		r.Recorder.Event(sync, corev1.EventTypeNormal, "SyncStarted",
+			"Sync job "+job.Name+" exports Ghost "+sync.Status.Source+" into Ghost "+sync.Status.Target)
+		return nil
+	}
+
+	job := observed.(*batchv1.Job)
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			message := "Content of Ghost " + sync.Status.Source + " imported into Ghost " + sync.Status.Target
+			sync.Status.Phase = marketingv1.ContentSyncPhaseSucceeded
+			sync.Status.CompletionTime = job.Status.CompletionTime
+			r.setCondition(sync, metav1.ConditionTrue, "SyncSucceeded", message)
+			r.Recorder.Event(sync, corev1.EventTypeNormal, "SyncSucceeded", message)
+			// The target keeps a record of what replaced its content next to its own events
+			r.Recorder.Event(target, corev1.EventTypeNormal, "ContentSynced",
+				"Content of Ghost "+sync.Status.Source+" imported by GhostContentSync "+sync.Namespace+"/"+sync.Name)
+			return nil
+		case batchv1.JobFailed:
+			sync.Status.Phase = marketingv1.ContentSyncPhaseFailed
+			r.setCondition(sync, metav1.ConditionFalse, "JobFailed", "Sync job "+job.Name+" failed: "+condition.Message)
+			r.Recorder.Event(sync, corev1.EventTypeWarning, "SyncFailed", "Sync job "+job.Name+" failed, see its logs")
+			return nil
+		}
+	}
+	// The Job watch brings the sync back once it finishes
+	return nil
+}
+
+// endpointGhost reads the Ghost of a source or target, which may live in another namespace of
+// the operator install
+func (r *GhostContentSyncReconciler) endpointGhost(ctx context.Context, sync *marketingv1.GhostContentSync, endpoint marketingv1.ContentSyncEndpoint) (*marketingv1.Ghost, error) {
+	namespace := endpoint.Namespace
+	if namespace == "" {
+		namespace = sync.Namespace
+	}
+	if namespace != sync.Namespace {
+		included, err := r.Scope.Includes(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !included {
+			return nil, invalidSpecError(fmt.Errorf("namespace %s is not managed by this operator", namespace))
+		}
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: endpoint.GhostRef.Name}, ghost); err != nil {
+		return nil, externalError(err)
+	}
+	if _, err := applyNamespaceDefaults(ctx, r.Client, ghost); err != nil {
+		return nil, err
+	}
+	config, err := operatorConfig(ctx, r.Client)
+	if err != nil {
+		return nil, err
+	}
+	applyOperatorDefaults(ghost, config)
+	return ghost, nil
+}
+
+func (r *GhostContentSyncReconciler) setCondition(sync *marketingv1.GhostContentSync, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&sync.Status.Conditions, metav1.Condition{
+		Type:    contentSyncedCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostContentSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostContentSync{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("GhostContentSync Controller", func() {
+	const namespace = "content-sync"
+
+	endpoint := func(ghost, namespace, secret string) marketingv1.ContentSyncEndpoint {
+		return marketingv1.ContentSyncEndpoint{
+			GhostRef:  corev1.LocalObjectReference{Name: ghost},
+			Namespace: namespace,
+			AdminAPIKeySecretRef: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+				Key:                  "key",
+			},
+		}
+	}
+
+	It("should export the source and import it into the target in a Job", func() {
+		for _, name := range []string{namespace, namespace + "-staging"} {
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})).To(Succeed())
+		}
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace + "-staging"},
+			Spec:       marketingv1.GhostSpec{ImageTag: "5-alpine", Replicas: 1},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec:       marketingv1.GhostSpec{ImageTag: "alpine", Replicas: 1},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostContentSync{
+			ObjectMeta: metav1.ObjectMeta{Name: "promote", Namespace: namespace},
+			Spec: marketingv1.GhostContentSyncSpec{
+				Source:         endpoint("blog", namespace+"-staging", "staging-admin-key"),
+				Target:         endpoint("blog", "", "admin-key"),
+				ExcludeMembers: true,
+				ReplaceContent: true,
+			},
+		})).To(Succeed())
+
+		recorder := record.NewFakeRecorder(100)
+		reconciler := &GhostContentSyncReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: recorder,
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "promote"}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		sync := &marketingv1.GhostContentSync{}
+		Expect(k8sClient.Get(ctx, key, sync)).To(Succeed())
+		Expect(sync.Status.Phase).To(Equal(marketingv1.ContentSyncPhaseRunning))
+		Expect(sync.Status.Source).To(Equal(namespace + "-staging/blog"))
+		Expect(sync.Status.Target).To(Equal(namespace + "/blog"))
+		Eventually(recorder.Events).Should(Receive(HavePrefix("Normal SyncStarted")))
+
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: sync.Status.JobName}, job)).To(Succeed())
+		pod := job.Spec.Template.Spec
+		Expect(pod.InitContainers).To(HaveLen(3))
+		export := pod.InitContainers[0]
+		Expect(export.Image).To(Equal("ghost:5-alpine"))
+		Expect(export.Env).To(ContainElement(corev1.EnvVar{Name: "GHOST_URL", Value: "http://ghost-service-blog." + namespace + "-staging.svc:80"}))
+		Expect(export.Env[1].ValueFrom.SecretKeyRef.Name).To(Equal("staging-admin-key"))
+		Expect(pod.InitContainers[1].Name).To(Equal("exclude-members"))
+		Expect(pod.InitContainers[2].Name).To(Equal("delete-content"))
+		Expect(pod.InitContainers[2].Env[1].ValueFrom.SecretKeyRef.Name).To(Equal("admin-key"))
+		Expect(pod.Containers[0].Name).To(Equal("import"))
+		Expect(pod.Containers[0].Image).To(Equal("ghost:alpine"))
+		Expect(pod.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "GHOST_URL", Value: "http://ghost-service-blog." + namespace + ".svc:80"}))
+
+		By("recording the import once the Job completed")
+		finished := metav1.NewTime(time.Now().Truncate(time.Second))
+		job.Status.StartTime = &finished
+		job.Status.CompletionTime = &finished
+		job.Status.Succeeded = 1
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue},
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+		}
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, sync)).To(Succeed())
+		Expect(sync.Status.Phase).To(Equal(marketingv1.ContentSyncPhaseSucceeded))
+		Expect(sync.Status.CompletionTime.Time).To(BeTemporally("==", finished.Time))
+		Eventually(recorder.Events).Should(Receive(HavePrefix("Normal SyncSucceeded")))
+		Eventually(recorder.Events).Should(Receive(Equal("Normal ContentSynced Content of Ghost " + namespace +
+			"-staging/blog imported by GhostContentSync " + namespace + "/promote")))
+	})
+
+	It("should reject a sync of a Ghost into itself", func() {
+		err := k8sClient.Create(ctx, &marketingv1.GhostContentSync{
+			ObjectMeta: metav1.ObjectMeta{Name: "loop", Namespace: "default"},
+			Spec: marketingv1.GhostContentSyncSpec{
+				Source: endpoint("blog", "", "admin-key"),
+				Target: endpoint("blog", "", "admin-key"),
+			},
+		})
+		Expect(err).To(MatchError(ContainSubstring("source and target must be different Ghosts")))
+	})
+})
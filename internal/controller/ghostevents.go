@@ -0,0 +1,189 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+const (
+	// eventsRegisteredCondition reports whether Ghost posts the events of spec.events to the operator
+	eventsRegisteredCondition = "EventsRegistered"
+	// eventTokenNamePrefix names the Secret holding the token Ghost signs the events with
+	eventTokenNamePrefix = "ghost-events-"
+	eventTokenKey        = "token"
+	// eventReceiverPathPrefix precedes the namespace, name and event in the receiver paths
+	eventReceiverPathPrefix = "/ghost/"
+)
+
+// defaultGhostEvents are received when spec.events lists none
+var defaultGhostEvents = []marketingv1.GhostEvent{marketingv1.GhostEventPostPublished, marketingv1.GhostEventSiteChanged}
+
+// ghostEvents are the events of spec.events, the defaults when none are listed
+func ghostEvents(ghost *marketingv1.Ghost) []marketingv1.GhostEvent {
+	if len(ghost.Spec.Events.Events) == 0 {
+		return defaultGhostEvents
+	}
+	return ghost.Spec.Events.Events
+}
+
+// eventReceiverTarget is the URL Ghost posts an event of the Ghost to. Ghost sends no event
+// name with the payload, so each event has a path of its own.
+func eventReceiverTarget(receiverURL string, ghost *marketingv1.Ghost, event marketingv1.GhostEvent) string {
+	return strings.TrimSuffix(receiverURL, "/") + eventReceiverPathPrefix + ghost.Namespace + "/" + ghost.Name + "/" + string(event)
+}
+
+// registerEventWebhooks registers a Ghost webhook per event of spec.events once Ghost answers
+// and reports them in the EventsRegistered condition. Removing spec.events leaves the webhooks
+// in Ghost, they go with the custom integration and the receiver ignores their events.
+func (r *GhostReconciler) registerEventWebhooks(ctx context.Context, ghost *marketingv1.Ghost) {
+	if ghost.Spec.Events == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, eventsRegisteredCondition)
+		ghost.Status.Events = nil
+		return
+	}
+	if r.EventReceiverURL == "" {
+		setCondition(ghost, eventsRegisteredCondition, metav1.ConditionFalse, "ReceiverDisabled",
+			"The operator runs without --event-receiver-url, Ghost has nowhere to post the events")
+		return
+	}
+	if r.readOnly(ghost) {
+		return
+	}
+	if ghost.Status.ReadyReplicas == 0 || applicationUnhealthy(ghost) != "" {
+		setCondition(ghost, eventsRegisteredCondition, metav1.ConditionUnknown, "WaitingForGhost",
+			"The webhooks are registered once Ghost answers")
+		return
+	}
+	if err := r.ensureEventWebhooks(ctx, ghost); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to register the event webhooks")
+		setCondition(ghost, eventsRegisteredCondition, metav1.ConditionFalse, "RegistrationFailed", err.Error())
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "EventsRegistrationFailed", err.Error())
+		return
+	}
+	setCondition(ghost, eventsRegisteredCondition, metav1.ConditionTrue, "Registered",
+		"Ghost posts its events to the operator")
+}
+
+// ensureEventWebhooks creates or updates the webhooks of the events whenever the receiver URL,
+// the token or the events change, and deletes those of events no longer received
+func (r *GhostReconciler) ensureEventWebhooks(ctx context.Context, ghost *marketingv1.Ghost) error {
+	token, err := r.eventToken(ctx, ghost)
+	if err != nil {
+		return err
+	}
+	events := ghostEvents(ghost)
+	sum := sha256.Sum256([]byte(r.EventReceiverURL + "\n" + token))
+	digest := hex.EncodeToString(sum[:8])
+	status := ghost.Status.Events
+	if status == nil {
+		status = &marketingv1.EventsStatus{}
+	}
+	if status.TargetDigest == digest && len(status.Webhooks) == len(events) {
+		current := true
+		for _, event := range events {
+			current = current && status.Webhooks[event] != ""
+		}
+		if current {
+			return nil
+		}
+	}
+
+	api, err := newAdminClient(ctx, r.secretReader(), r.AdminURL, ghost, ghost.ObjectMeta.Namespace, ghost.Spec.Events.AdminAPIKeySecretRef)
+	if err != nil {
+		return err
+	}
+	registered := map[marketingv1.GhostEvent]string{}
+	for _, event := range events {
+		webhook := ghostapi.Webhook{
+			ID:        status.Webhooks[event],
+			Event:     string(event),
+			TargetURL: eventReceiverTarget(r.EventReceiverURL, ghost, event),
+			Name:      "Operator " + string(event),
+			Secret:    token,
+		}
+		var hook *ghostapi.Webhook
+		if webhook.ID != "" {
+			hook, err = api.UpdateWebhook(ctx, webhook)
+		}
+		if webhook.ID == "" || ghostapi.IsNotFound(err) {
+			hook, err = api.CreateWebhook(ctx, webhook)
+		}
+		if err != nil {
+			return fmt.Errorf("registering the %s webhook: %w", event, err)
+		}
+		registered[event] = hook.ID
+	}
+	for event, id := range status.Webhooks {
+		if slices.Contains(events, event) {
+			continue
+		}
+		if err := api.DeleteWebhook(ctx, id); err != nil && !ghostapi.IsNotFound(err) {
+			return fmt.Errorf("deleting the %s webhook: %w", event, err)
+		}
+	}
+	ghost.Status.Events = &marketingv1.EventsStatus{Webhooks: registered, TargetDigest: digest}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "EventsRegistered", fmt.Sprintf("Ghost posts %d events to the operator", len(registered)))
+	return nil
+}
+
+// eventToken reads the token Ghost signs the events with, generating its Secret on first use.
+// The Secret is owned by the Ghost, deleting it rotates the token.
+func (r *GhostReconciler) eventToken(ctx context.Context, ghost *marketingv1.Ghost) (string, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: ghost.ObjectMeta.Namespace, Name: childName(ghost, eventTokenNamePrefix)}
+	err := r.secretReader().Get(ctx, key, secret)
+	if err == nil {
+		return string(secret.Data[eventTokenKey]), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Data:       map[string][]byte{eventTokenKey: []byte(token)},
+	}
+	if err := controllerutil.SetControllerReference(ghost, secret, r.Scheme); err != nil {
+		return "", err
+	}
+	if err := r.Create(ctx, secret); err != nil {
+		return "", err
+	}
+	return token, nil
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+// integrationFinalizer holds a deleted GhostIntegration until the integration is removed from Ghost
+const integrationFinalizer = "marketing.kb.dev/integration"
+
+// integrationReadyCondition reports whether the integration exists and its keys are published
+const integrationReadyCondition = "Ready"
+
+// Keys of the Secret the keys of an integration are published in
+const (
+	integrationContentKey = "content-api-key"
+	integrationAdminKey   = "admin-api-key"
+	integrationURLKey     = "url"
+)
+
+// GhostIntegrationReconciler creates the custom integrations declared by GhostIntegrations in
+// their Ghost and publishes the keys Ghost generates into a Secret
+type GhostIntegrationReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads Secrets without caching them cluster wide
+	APIReader client.Reader
+	// AdminURL returns the base URL of a Ghost's Admin API, the in-cluster Service by default
+	AdminURL func(ghost *marketingv1.Ghost) string
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostintegrations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostintegrations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostintegrations/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile creates or updates the integration and publishes its keys, and removes it on delete
+func (r *GhostIntegrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	integration := &marketingv1.GhostIntegration{}
+	if err := r.Get(ctx, req.NamespacedName, integration); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !integration.DeletionTimestamp.IsZero() {
+		if err := r.finalizeIntegration(ctx, integration); err != nil {
+			log.Error(err, "Failed to remove the integration")
+			r.Recorder.Event(integration, corev1.EventTypeWarning, "RemovalFailed", err.Error())
+			return resultForError(err)
+		}
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.AddFinalizer(integration, integrationFinalizer) {
+		if err := r.Update(ctx, integration); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	original := integration.DeepCopy()
+
+	reconcileErr := r.reconcileIntegration(ctx, integration)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostIntegration")
+		r.Recorder.Event(integration, corev1.EventTypeWarning, "SyncFailed", reconcileErr.Error())
+	} else {
+		integration.Status.ObservedGeneration = integration.Generation
+	}
+
+	if !equality.Semantic.DeepEqual(original.Status, integration.Status) {
+		if err := r.Status().Patch(ctx, integration, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostIntegration status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileIntegration records the outcome in the Ready condition of the GhostIntegration. An
+// integration deleted in Ghost is created again, with new keys.
+func (r *GhostIntegrationReconciler) reconcileIntegration(ctx context.Context, integration *marketingv1.GhostIntegration) error {
+	fail := func(reason string, err error) error {
+		meta.SetStatusCondition(&integration.Status.Conditions, metav1.Condition{
+			Type:    integrationReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Error(),
+		})
+		return err
+	}
+
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: integration.Namespace, Name: integration.Spec.GhostRef.Name}, ghost); err != nil {
+		return fail("GhostNotFound", externalError(err))
+	}
+	api, err := newAdminClient(ctx, r.APIReader, r.AdminURL, ghost, integration.Namespace, integration.Spec.AdminAPIKeySecretRef)
+	if err != nil {
+		return fail("AdminAPIKeyUnavailable", err)
+	}
+
+	desired := ghostapi.Integration{Name: integrationName(integration), Description: integration.Spec.Description}
+	var live *ghostapi.Integration
+	if id := integration.Status.IntegrationID; id != "" {
+		live, err = api.GetIntegration(ctx, id)
+		if ghostapi.IsNotFound(err) {
+			live, err = nil, nil
+			r.Recorder.Event(integration, corev1.EventTypeWarning, "IntegrationMissing",
+				"Integration "+id+" was deleted in Ghost, it is created again with new keys")
+		}
+		if err != nil {
+			return fail("AdminAPIFailed", externalError(err))
+		}
+	}
+	switch {
+	case live == nil:
+		if live, err = api.CreateIntegration(ctx, desired); err != nil {
+			return fail("AdminAPIFailed", externalError(err))
+		}
+		integration.Status.IntegrationID = live.ID
+		r.Recorder.Event(integration, corev1.EventTypeNormal, "IntegrationCreated", "Integration "+desired.Name+" created in Ghost "+ghost.Name)
+	case live.Name != desired.Name || live.Description != desired.Description:
+		desired.ID = live.ID
+		updated, err := api.UpdateIntegration(ctx, desired)
+		if err != nil {
+			return fail("AdminAPIFailed", externalError(err))
+		}
+		// The keys do not change with the name
+		if len(updated.APIKeys) == 0 {
+			updated.APIKeys = live.APIKeys
+		}
+		live = updated
+	}
+
+	if err := r.publishKeys(ctx, integration, ghost, live); err != nil {
+		return fail("SecretFailed", err)
+	}
+	integration.Status.SecretName = integrationSecretName(integration)
+	meta.SetStatusCondition(&integration.Status.Conditions, metav1.Condition{
+		Type:    integrationReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Published",
+		Message: fmt.Sprintf("The keys of integration %s are in Secret %s", desired.Name, integration.Status.SecretName),
+	})
+	return nil
+}
+
+// publishKeys creates or updates the Secret holding the keys, refusing to take over a Secret the
+// GhostIntegration did not create. A renamed Secret leaves the previous one to garbage collection
+// only once the GhostIntegration is deleted.
+func (r *GhostIntegrationReconciler) publishKeys(ctx context.Context, integration *marketingv1.GhostIntegration, ghost *marketingv1.Ghost, live *ghostapi.Integration) error {
+	data := map[string][]byte{
+		integrationContentKey: []byte(live.Key("content")),
+		integrationAdminKey:   []byte(live.Key("admin")),
+	}
+	if url := ghostURL(ghost); url != "" {
+		data[integrationURLKey] = []byte(url)
+	}
+	name := integrationSecretName(integration)
+	secret := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: integration.Namespace, Name: name}, secret)
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: integration.Namespace},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       data,
+		}
+		if err := controllerutil.SetControllerReference(integration, secret, r.Scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, secret)
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(secret, integration) {
+		return invalidSpecError(fmt.Errorf("secret %s already exists and is not the Secret of this integration", name))
+	}
+	if equality.Semantic.DeepEqual(secret.Data, data) {
+		return nil
+	}
+	secret.Data = data
+	return r.Update(ctx, secret)
+}
+
+// finalizeIntegration removes the integration from Ghost and releases the GhostIntegration, its
+// Secret goes with it. A Ghost that is gone takes its integrations with it, as does a Ghost whose
+// Admin API key is gone.
+func (r *GhostIntegrationReconciler) finalizeIntegration(ctx context.Context, integration *marketingv1.GhostIntegration) error {
+	if !controllerutil.ContainsFinalizer(integration, integrationFinalizer) {
+		return nil
+	}
+	ghost := &marketingv1.Ghost{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: integration.Namespace, Name: integration.Spec.GhostRef.Name}, ghost)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if id := integration.Status.IntegrationID; err == nil && ghost.DeletionTimestamp.IsZero() && id != "" {
+		api, err := newAdminClient(ctx, r.APIReader, r.AdminURL, ghost, integration.Namespace, integration.Spec.AdminAPIKeySecretRef)
+		switch {
+		case apierrors.IsNotFound(err):
+			r.Recorder.Event(integration, corev1.EventTypeWarning, "IntegrationKept",
+				"The Admin API key is gone, integration "+id+" has to be removed from Ghost "+ghost.Name+" by hand")
+		case err != nil:
+			return err
+		default:
+			if err := api.DeleteIntegration(ctx, id); err != nil && !ghostapi.IsNotFound(err) {
+				return externalError(err)
+			}
+			r.Recorder.Event(integration, corev1.EventTypeNormal, "IntegrationRemoved", "Integration "+id+" removed from Ghost "+ghost.Name)
+		}
+	}
+	controllerutil.RemoveFinalizer(integration, integrationFinalizer)
+	return r.Update(ctx, integration)
+}
+
+// integrationName is the name of the integration in Ghost
+func integrationName(integration *marketingv1.GhostIntegration) string {
+	if integration.Spec.DisplayName != "" {
+		return integration.Spec.DisplayName
+	}
+	return integration.Name
+}
+
+// integrationSecretName is the Secret the keys of the integration are published in
+func integrationSecretName(integration *marketingv1.GhostIntegration) string {
+	if integration.Spec.SecretName != "" {
+		return integration.Spec.SecretName
+	}
+	return integration.Name
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostIntegrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostIntegration{}).
+		Owns(&corev1.Secret{}).
+		Complete(r)
+}
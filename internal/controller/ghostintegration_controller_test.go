@@ -0,0 +1,219 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+var _ = Describe("GhostIntegration Controller", func() {
+	const namespace = "integrations"
+
+	var (
+		mu           sync.Mutex
+		integrations map[string]ghostapi.Integration
+		created      int
+		server       *httptest.Server
+	)
+
+	BeforeEach(func() {
+		integrations = map[string]ghostapi.Integration{}
+		created = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/ghost/api/admin/integrations"), "/")
+			reply := func(integration ghostapi.Integration) {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"integrations": []ghostapi.Integration{integration}})
+			}
+			var body struct{ Integrations []ghostapi.Integration }
+			if r.Body != nil {
+				_ = json.NewDecoder(r.Body).Decode(&body)
+			}
+			integration, found := integrations[id]
+			switch {
+			case r.Method == http.MethodPost && id == "":
+				created++
+				integration = body.Integrations[0]
+				integration.ID = "int" + string(rune('0'+created))
+				integration.APIKeys = []ghostapi.APIKey{
+					{ID: "c" + integration.ID, Type: "content", Secret: "content" + integration.ID},
+					{ID: "a" + integration.ID, Type: "admin", Secret: "admin" + integration.ID},
+				}
+				integrations[integration.ID] = integration
+				reply(integration)
+			case !found:
+				w.WriteHeader(http.StatusNotFound)
+			case r.Method == http.MethodGet:
+				reply(integration)
+			case r.Method == http.MethodPut:
+				integration.Name = body.Integrations[0].Name
+				integration.Description = body.Integrations[0].Description
+				integrations[id] = integration
+				reply(ghostapi.Integration{ID: id, Name: integration.Name, Description: integration.Description})
+			case r.Method == http.MethodDelete:
+				delete(integrations, id)
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	BeforeEach(func() {
+		for _, obj := range []client.Object{
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+			&marketingv1.Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+				Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1, URL: "https://blog.example.com"},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "admin-key", Namespace: namespace},
+				StringData: map[string]string{"key": "64f0c1:a1b2c3d4"},
+			},
+		} {
+			if err := k8sClient.Create(ctx, obj); !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+	})
+
+	It("should create the integration, publish its keys and remove it on delete", func() {
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostIntegration{
+			ObjectMeta: metav1.ObjectMeta{Name: "zapier", Namespace: namespace},
+			Spec: marketingv1.GhostIntegrationSpec{
+				GhostRef:    corev1.LocalObjectReference{Name: "blog"},
+				DisplayName: "Zapier",
+				SecretName:  "zapier-keys",
+				AdminAPIKeySecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "admin-key"},
+					Key:                  "key",
+				},
+			},
+		})).To(Succeed())
+
+		recorder := record.NewFakeRecorder(100)
+		reconciler := &GhostIntegrationReconciler{
+			Client:    k8sClient,
+			Scheme:    k8sClient.Scheme(),
+			Recorder:  recorder,
+			APIReader: k8sClient,
+			AdminURL:  func(*marketingv1.Ghost) string { return server.URL },
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "zapier"}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		integration := &marketingv1.GhostIntegration{}
+		Expect(k8sClient.Get(ctx, key, integration)).To(Succeed())
+		Expect(integration.Finalizers).To(ContainElement(integrationFinalizer))
+		Expect(integration.Status.IntegrationID).To(Equal("int1"))
+		Expect(integration.Status.SecretName).To(Equal("zapier-keys"))
+		Expect(meta.IsStatusConditionTrue(integration.Status.Conditions, integrationReadyCondition)).To(BeTrue())
+		secret := &corev1.Secret{}
+		secretKey := types.NamespacedName{Namespace: namespace, Name: "zapier-keys"}
+		Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+		Expect(metav1.IsControlledBy(secret, integration)).To(BeTrue())
+		Expect(secret.Data).To(Equal(map[string][]byte{
+			integrationContentKey: []byte("contentint1"),
+			integrationAdminKey:   []byte("aint1:adminint1"),
+			integrationURLKey:     []byte("https://blog.example.com"),
+		}))
+
+		By("renaming the integration in Ghost and keeping its keys")
+		integration.Spec.DisplayName = "Zapier EU"
+		Expect(k8sClient.Update(ctx, integration)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(integrations).To(HaveKeyWithValue("int1", HaveField("Name", "Zapier EU")))
+		Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue(integrationContentKey, []byte("contentint1")))
+
+		By("creating the integration again once it is deleted in Ghost")
+		mu.Lock()
+		delete(integrations, "int1")
+		mu.Unlock()
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(recorder.Events).Should(Receive(HavePrefix("Warning IntegrationMissing")))
+		Expect(k8sClient.Get(ctx, key, integration)).To(Succeed())
+		Expect(integration.Status.IntegrationID).To(Equal("int2"))
+		Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue(integrationAdminKey, []byte("aint2:adminint2")))
+
+		By("removing the integration from Ghost once the GhostIntegration is deleted")
+		Expect(k8sClient.Delete(ctx, integration)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(integrations).To(BeEmpty())
+		Expect(errors.IsNotFound(k8sClient.Get(ctx, key, integration))).To(BeTrue())
+	})
+
+	It("should not take over a Secret it did not create", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "taken", Namespace: namespace},
+			StringData: map[string]string{"token": "keep"},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostIntegration{
+			ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: namespace},
+			Spec: marketingv1.GhostIntegrationSpec{
+				GhostRef:   corev1.LocalObjectReference{Name: "blog"},
+				SecretName: "taken",
+				AdminAPIKeySecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "admin-key"},
+					Key:                  "key",
+				},
+			},
+		})).To(Succeed())
+		reconciler := &GhostIntegrationReconciler{
+			Client:    k8sClient,
+			Scheme:    k8sClient.Scheme(),
+			Recorder:  record.NewFakeRecorder(100),
+			APIReader: k8sClient,
+			AdminURL:  func(*marketingv1.Ghost) string { return server.URL },
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "slack"}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).To(MatchError(ContainSubstring("secret taken already exists")))
+		integration := &marketingv1.GhostIntegration{}
+		Expect(k8sClient.Get(ctx, key, integration)).To(Succeed())
+		Expect(integration.Status.Conditions).To(ContainElement(And(
+			HaveField("Type", integrationReadyCondition),
+			HaveField("Status", metav1.ConditionFalse),
+			HaveField("Reason", "SecretFailed"),
+		)))
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "taken"}, secret)).To(Succeed())
+		Expect(secret.Data).To(Equal(map[string][]byte{"token": []byte("keep")}))
+	})
+})
@@ -0,0 +1,326 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+// memberFinalizer holds a deleted GhostMember until the staff user is removed from the Ghost
+const memberFinalizer = "marketing.kb.dev/staff-user"
+
+// memberSyncedCondition reports whether the staff user matches the GhostMember
+const memberSyncedCondition = "Synced"
+
+// ownerRole is the role of the staff user created by Ghost's setup, which cannot be reassigned
+const ownerRole = "Owner"
+
+// memberInvitePollInterval is how often a pending invitation is checked for having been accepted
+const memberInvitePollInterval = 10 * time.Minute
+
+// GhostMemberReconciler invites the staff users declared by GhostMembers to their Ghost, keeps
+// their role and removes them once the GhostMember is deleted
+type GhostMemberReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads Secrets without caching them cluster wide
+	APIReader client.Reader
+	// AdminURL returns the base URL of a Ghost's Admin API, the in-cluster Service by default
+	AdminURL func(ghost *marketingv1.Ghost) string
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostmembers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostmembers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostmembers/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Reconcile invites the staff user or updates their role, and removes them on delete
+func (r *GhostMemberReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	member := &marketingv1.GhostMember{}
+	if err := r.Get(ctx, req.NamespacedName, member); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !member.DeletionTimestamp.IsZero() {
+		if err := r.finalizeMember(ctx, member); err != nil {
+			log.Error(err, "Failed to remove the staff user")
+			r.Recorder.Event(member, corev1.EventTypeWarning, "RemovalFailed", err.Error())
+			return resultForError(err)
+		}
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.AddFinalizer(member, memberFinalizer) {
+		if err := r.Update(ctx, member); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	original := member.DeepCopy()
+
+	reconcileErr := r.reconcileMember(ctx, member)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostMember")
+		r.Recorder.Event(member, corev1.EventTypeWarning, "SyncFailed", reconcileErr.Error())
+	} else {
+		member.Status.ObservedGeneration = member.Generation
+	}
+
+	if !equality.Semantic.DeepEqual(original.Status, member.Status) {
+		if err := r.Status().Patch(ctx, member, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostMember status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	// Ghost does not tell when an invitation is accepted
+	if member.Status.State == marketingv1.GhostMemberInvited {
+		return ctrl.Result{RequeueAfter: memberInvitePollInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileMember records the outcome in the Synced condition of the GhostMember
+func (r *GhostMemberReconciler) reconcileMember(ctx context.Context, member *marketingv1.GhostMember) error {
+	fail := func(reason string, err error) error {
+		meta.SetStatusCondition(&member.Status.Conditions, metav1.Condition{
+			Type:    memberSyncedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Error(),
+		})
+		return err
+	}
+
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: member.Namespace, Name: member.Spec.GhostRef.Name}, ghost); err != nil {
+		return fail("GhostNotFound", externalError(err))
+	}
+	api, err := r.adminClient(ctx, ghost, member.Namespace, member.Spec.AdminAPIKeySecretRef)
+	if err != nil {
+		return fail("AdminAPIKeyUnavailable", err)
+	}
+	roleID, err := memberRoleID(ctx, api, member)
+	if err != nil {
+		return fail("RoleUnavailable", err)
+	}
+
+	user, err := api.FindUser(ctx, member.Spec.Email)
+	if err != nil {
+		return fail("AdminAPIFailed", externalError(err))
+	}
+	if user != nil {
+		if hasRole(user, ownerRole) {
+			return fail("Owner", invalidSpecError(fmt.Errorf("%s is the Owner of Ghost %s, whose role cannot be changed", member.Spec.Email, ghost.Name)))
+		}
+		if !hasRoleID(user, roleID) {
+			if _, err := api.UpdateUserRole(ctx, user.ID, roleID); err != nil {
+				return fail("AdminAPIFailed", externalError(err))
+			}
+			r.Recorder.Event(member, corev1.EventTypeNormal, "RoleUpdated", fmt.Sprintf("%s is now %s", member.Spec.Email, memberRole(member)))
+		}
+		member.Status.State = marketingv1.GhostMemberActive
+		member.Status.UserID = user.ID
+		member.Status.InviteID = ""
+		meta.SetStatusCondition(&member.Status.Conditions, metav1.Condition{
+			Type:    memberSyncedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Active",
+			Message: fmt.Sprintf("%s is %s of Ghost %s", member.Spec.Email, memberRole(member), ghost.Name),
+		})
+		return nil
+	}
+
+	invite, err := findInvite(ctx, api, member.Spec.Email)
+	if err != nil {
+		return fail("AdminAPIFailed", externalError(err))
+	}
+	// Ghost cannot change the role of an invitation, so it is sent again
+	if invite != nil && invite.RoleID != roleID {
+		if err := api.DeleteInvite(ctx, invite.ID); err != nil && !ghostapi.IsNotFound(err) {
+			return fail("AdminAPIFailed", externalError(err))
+		}
+		invite = nil
+	}
+	if invite == nil {
+		if invite, err = api.CreateInvite(ctx, member.Spec.Email, roleID); err != nil {
+			return fail("AdminAPIFailed", externalError(err))
+		}
+		r.Recorder.Event(member, corev1.EventTypeNormal, "Invited", fmt.Sprintf("%s invited as %s", member.Spec.Email, memberRole(member)))
+	}
+	member.Status.State = marketingv1.GhostMemberInvited
+	member.Status.UserID = ""
+	member.Status.InviteID = invite.ID
+	meta.SetStatusCondition(&member.Status.Conditions, metav1.Condition{
+		Type:    memberSyncedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Invited",
+		Message: fmt.Sprintf("%s is invited as %s of Ghost %s", member.Spec.Email, memberRole(member), ghost.Name),
+	})
+	return nil
+}
+
+// finalizeMember removes the staff user or revokes their invitation and releases the GhostMember.
+// A Ghost that is gone takes its staff with it, as does a Ghost whose Admin API key is gone.
+func (r *GhostMemberReconciler) finalizeMember(ctx context.Context, member *marketingv1.GhostMember) error {
+	if !controllerutil.ContainsFinalizer(member, memberFinalizer) {
+		return nil
+	}
+	ghost := &marketingv1.Ghost{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: member.Namespace, Name: member.Spec.GhostRef.Name}, ghost)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && ghost.DeletionTimestamp.IsZero() {
+		if err := r.removeMember(ctx, ghost, member); err != nil {
+			return err
+		}
+	}
+	controllerutil.RemoveFinalizer(member, memberFinalizer)
+	return r.Update(ctx, member)
+}
+
+// removeMember deletes the staff user of the GhostMember from the Ghost, their posts are handed
+// over to the Owner
+func (r *GhostMemberReconciler) removeMember(ctx context.Context, ghost *marketingv1.Ghost, member *marketingv1.GhostMember) error {
+	api, err := r.adminClient(ctx, ghost, member.Namespace, member.Spec.AdminAPIKeySecretRef)
+	if apierrors.IsNotFound(err) {
+		r.Recorder.Event(member, corev1.EventTypeWarning, "StaffUserKept",
+			"The Admin API key is gone, "+member.Spec.Email+" has to be removed from Ghost "+ghost.Name+" by hand")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	user, err := api.FindUser(ctx, member.Spec.Email)
+	if err != nil {
+		return externalError(err)
+	}
+	if user != nil {
+		if hasRole(user, ownerRole) {
+			return nil
+		}
+		if err := api.DeleteUser(ctx, user.ID); err != nil && !ghostapi.IsNotFound(err) {
+			return externalError(err)
+		}
+		r.Recorder.Event(member, corev1.EventTypeNormal, "StaffUserRemoved", member.Spec.Email+" removed from Ghost "+ghost.Name)
+		return nil
+	}
+	invite, err := findInvite(ctx, api, member.Spec.Email)
+	if err != nil {
+		return externalError(err)
+	}
+	if invite != nil {
+		if err := api.DeleteInvite(ctx, invite.ID); err != nil && !ghostapi.IsNotFound(err) {
+			return externalError(err)
+		}
+		r.Recorder.Event(member, corev1.EventTypeNormal, "InviteRevoked", "Invitation of "+member.Spec.Email+" revoked")
+	}
+	return nil
+}
+
+// memberRole is the role the GhostMember declares, Contributor by default
+func memberRole(member *marketingv1.GhostMember) marketingv1.GhostMemberRole {
+	if member.Spec.Role == "" {
+		return marketingv1.GhostMemberRoleContributor
+	}
+	return member.Spec.Role
+}
+
+// memberRoleID looks the id of the declared role up, Ghost assigns them per site
+func memberRoleID(ctx context.Context, api *ghostapi.Client, member *marketingv1.GhostMember) (string, error) {
+	roles, err := api.ListRoles(ctx)
+	if err != nil {
+		return "", externalError(err)
+	}
+	for _, role := range roles {
+		if role.Name == string(memberRole(member)) {
+			return role.ID, nil
+		}
+	}
+	return "", externalError(fmt.Errorf("ghost has no %s role", memberRole(member)))
+}
+
+// findInvite returns the pending invitation of the email, nil when there is none
+func findInvite(ctx context.Context, api *ghostapi.Client, email string) (*ghostapi.Invite, error) {
+	invites, err := api.ListInvites(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range invites {
+		if strings.EqualFold(invites[i].Email, email) {
+			return &invites[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// hasRole reports whether the staff user has the role with the name
+func hasRole(user *ghostapi.User, name string) bool {
+	for _, role := range user.Roles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRoleID reports whether the staff user has the role with the id
+func hasRoleID(user *ghostapi.User, id string) bool {
+	for _, role := range user.Roles {
+		if role.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// adminClient builds an Admin API client for the Ghost from the referenced key
+func (r *GhostMemberReconciler) adminClient(ctx context.Context, ghost *marketingv1.Ghost, namespace string, ref corev1.SecretKeySelector) (*ghostapi.Client, error) {
+	return newAdminClient(ctx, r.APIReader, r.AdminURL, ghost, namespace, ref)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostMemberReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostMember{}).
+		Complete(r)
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+var _ = Describe("GhostMember Controller", func() {
+	const namespace = "staff"
+
+	var (
+		mu       sync.Mutex
+		requests []string
+		users    map[string]ghostapi.User
+		invites  map[string]ghostapi.Invite
+		server   *httptest.Server
+	)
+	roles := []ghostapi.Role{{ID: "r-editor", Name: "Editor"}, {ID: "r-author", Name: "Author"}, {ID: "r-contributor", Name: "Contributor"}}
+
+	BeforeEach(func() {
+		requests = nil
+		users = map[string]ghostapi.User{}
+		invites = map[string]ghostapi.Invite{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			path := strings.TrimPrefix(r.URL.Path, "/ghost/api/admin")
+			requests = append(requests, r.Method+" "+path)
+			w.Header().Set("Content-Type", "application/json")
+			reply := func(v interface{}) { _ = json.NewEncoder(w).Encode(v) }
+			switch {
+			case r.Method == http.MethodGet && path == "/roles/":
+				reply(map[string]interface{}{"roles": roles})
+			case r.Method == http.MethodGet && strings.HasPrefix(path, "/users/email/"):
+				user, ok := users[strings.Trim(strings.TrimPrefix(path, "/users/email/"), "/")]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				reply(map[string]interface{}{"users": []ghostapi.User{user}})
+			case r.Method == http.MethodPut && strings.HasPrefix(path, "/users/"):
+				var body struct{ Users []ghostapi.User }
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+				for email, user := range users {
+					if "/users/"+user.ID+"/" == path {
+						user.Roles = body.Users[0].Roles
+						users[email] = user
+						reply(map[string]interface{}{"users": []ghostapi.User{user}})
+					}
+				}
+			case r.Method == http.MethodDelete && strings.HasPrefix(path, "/users/"):
+				for email, user := range users {
+					if "/users/"+user.ID+"/" == path {
+						delete(users, email)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+			case r.Method == http.MethodGet && path == "/invites/":
+				list := []ghostapi.Invite{}
+				for _, invite := range invites {
+					list = append(list, invite)
+				}
+				reply(map[string]interface{}{"invites": list})
+			case r.Method == http.MethodPost && path == "/invites/":
+				var body struct{ Invites []ghostapi.Invite }
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+				invite := body.Invites[0]
+				invite.ID = "i-" + invite.RoleID
+				invites[invite.Email] = invite
+				w.WriteHeader(http.StatusCreated)
+				reply(map[string]interface{}{"invites": []ghostapi.Invite{invite}})
+			case r.Method == http.MethodDelete && strings.HasPrefix(path, "/invites/"):
+				for email, invite := range invites {
+					if "/invites/"+invite.ID+"/" == path {
+						delete(invites, email)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("should invite the staff user, keep their role and remove them on delete", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin-key", Namespace: namespace},
+			StringData: map[string]string{"key": "64f0c1:a1b2c3d4"},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostMember{
+			ObjectMeta: metav1.ObjectMeta{Name: "ada", Namespace: namespace},
+			Spec: marketingv1.GhostMemberSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "blog"},
+				Email:    "ada@example.com",
+				Role:     marketingv1.GhostMemberRoleEditor,
+				AdminAPIKeySecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "admin-key"},
+					Key:                  "key",
+				},
+			},
+		})).To(Succeed())
+
+		reconciler := &GhostMemberReconciler{
+			Client:    k8sClient,
+			Scheme:    k8sClient.Scheme(),
+			Recorder:  record.NewFakeRecorder(100),
+			APIReader: k8sClient,
+			AdminURL:  func(*marketingv1.Ghost) string { return server.URL },
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "ada"}
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(memberInvitePollInterval))
+		Expect(invites).To(HaveKeyWithValue("ada@example.com", HaveField("RoleID", "r-editor")))
+		member := &marketingv1.GhostMember{}
+		Expect(k8sClient.Get(ctx, key, member)).To(Succeed())
+		Expect(member.Finalizers).To(ContainElement(memberFinalizer))
+		Expect(member.Status.State).To(Equal(marketingv1.GhostMemberInvited))
+		Expect(member.Status.InviteID).To(Equal("i-r-editor"))
+		Expect(meta.IsStatusConditionTrue(member.Status.Conditions, memberSyncedCondition)).To(BeTrue())
+
+		By("inviting again with the new role when it changes before the invitation is accepted")
+		member.Spec.Role = marketingv1.GhostMemberRoleAuthor
+		Expect(k8sClient.Update(ctx, member)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(ContainElement("DELETE /invites/i-r-editor/"))
+		Expect(invites).To(HaveKeyWithValue("ada@example.com", HaveField("RoleID", "r-author")))
+
+		By("following the staff user once they accepted the invitation")
+		mu.Lock()
+		delete(invites, "ada@example.com")
+		users["ada@example.com"] = ghostapi.User{ID: "u1", Email: "ada@example.com", Roles: []ghostapi.Role{roles[1]}}
+		mu.Unlock()
+		result, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(k8sClient.Get(ctx, key, member)).To(Succeed())
+		Expect(member.Status.State).To(Equal(marketingv1.GhostMemberActive))
+		Expect(member.Status.UserID).To(Equal("u1"))
+		Expect(member.Status.InviteID).To(BeEmpty())
+
+		By("changing the role of the staff user")
+		member.Spec.Role = marketingv1.GhostMemberRoleEditor
+		Expect(k8sClient.Update(ctx, member)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(users["ada@example.com"].Roles).To(ConsistOf(HaveField("ID", "r-editor")))
+
+		By("removing the staff user once the GhostMember is deleted")
+		Expect(k8sClient.Delete(ctx, member)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(users).To(BeEmpty())
+		Expect(errors.IsNotFound(k8sClient.Get(ctx, key, member))).To(BeTrue())
+	})
+})
@@ -0,0 +1,324 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	previewContentCondition = "ContentReady"
+	previewReadyCondition   = "Ready"
+	// defaultPreviewTTL applies to previews created without the API server defaulting spec.ttl
+	defaultPreviewTTL = 72 * time.Hour
+)
+
+// GhostPreviewReconciler runs a temporary copy of a Ghost on its own host, with the content of
+// the Ghost and optionally another image, and deletes it once its TTL passes
+type GhostPreviewReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Now is the clock the TTL is checked against, time.Now when unset
+	Now func() time.Time
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostpreviews,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostpreviews/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostpreviews/finalizers,verbs=update
+
+// Reconcile provisions the preview Ghost and its content, and deletes the preview once it expired
+func (r *GhostPreviewReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	preview := &marketingv1.GhostPreview{}
+	if err := r.Get(ctx, req.NamespacedName, preview); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !preview.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	ttl := preview.Spec.TTL.Duration
+	if ttl <= 0 {
+		ttl = defaultPreviewTTL
+	}
+	expiresAt := preview.CreationTimestamp.Add(ttl)
+	if !r.now().Before(expiresAt) {
+		// Garbage collection removes the preview Ghost and its content with it
+		if err := r.Delete(ctx, preview, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(preview, corev1.EventTypeNormal, "PreviewExpired", "Preview deleted after its TTL of "+ttl.String())
+		return ctrl.Result{}, nil
+	}
+	original := preview.DeepCopy()
+	preview.Status.ExpiresAt = &metav1.Time{Time: expiresAt}
+
+	reconcileErr := r.reconcilePreview(ctx, preview)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostPreview")
+		r.Recorder.Event(preview, corev1.EventTypeWarning, "PreviewFailed", reconcileErr.Error())
+	}
+	if !equality.Semantic.DeepEqual(original.Status, preview.Status) {
+		if err := r.Status().Patch(ctx, preview, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostPreview status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	// The Ghost and restore watches bring the preview back as it is provisioned
+	return ctrl.Result{RequeueAfter: expiresAt.Sub(r.now())}, nil
+}
+
+// reconcilePreview provisions the content and the Ghost of the preview and reports their progress
+func (r *GhostPreviewReconciler) reconcilePreview(ctx context.Context, preview *marketingv1.GhostPreview) error {
+	if preview.Status.Phase == "" {
+		preview.Status.Phase = marketingv1.PreviewPhaseProvisioning
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: preview.Namespace, Name: preview.Spec.GhostRef.Name}, ghost); err != nil {
+		r.setCondition(preview, previewReadyCondition, metav1.ConditionFalse, "GhostNotFound", err.Error())
+		return externalError(err)
+	}
+	desired := &marketingv1.Ghost{
+		ObjectMeta: metav1.ObjectMeta{Name: preview.Name, Namespace: preview.Namespace},
+		Spec:       previewSpec(preview, ghost),
+	}
+	if preview.Spec.BackupRef == nil {
+		// The clone has to exist before the preview Ghost would create an empty claim of that name
+		if err := r.cloneContent(ctx, preview, ghost, desired); err != nil {
+			r.setCondition(preview, previewContentCondition, metav1.ConditionFalse, "CloneFailed", err.Error())
+			return err
+		}
+	}
+	previewGhost, err := r.ensurePreviewGhost(ctx, preview, desired)
+	if err != nil {
+		return err
+	}
+	preview.Status.GhostName = previewGhost.Name
+	preview.Status.URL = previewGhost.Status.URL
+	if preview.Spec.BackupRef != nil {
+		if err := r.importBackup(ctx, preview, previewGhost); err != nil {
+			return err
+		}
+	}
+
+	content := meta.FindStatusCondition(preview.Status.Conditions, previewContentCondition)
+	if content == nil || content.Status != metav1.ConditionTrue {
+		r.setCondition(preview, previewReadyCondition, metav1.ConditionFalse, "ContentPending", "Waiting for the content of the preview")
+		return nil
+	}
+	if !meta.IsStatusConditionTrue(previewGhost.Status.Conditions, "GhostReady") {
+		r.setCondition(preview, previewReadyCondition, metav1.ConditionFalse, "GhostPending", "Waiting for Ghost "+previewGhost.Name+" to become ready")
+		return nil
+	}
+	if preview.Status.Phase != marketingv1.PreviewPhaseReady {
+		r.Recorder.Event(preview, corev1.EventTypeNormal, "PreviewReady", "Preview served at "+previewGhost.Status.URL)
+	}
+	preview.Status.Phase = marketingv1.PreviewPhaseReady
+	r.setCondition(preview, previewReadyCondition, metav1.ConditionTrue, "GhostReady", "Preview served by Ghost "+previewGhost.Name)
+	return nil
+}
+
+// previewHost returns the hostname serving the preview
+func previewHost(preview *marketingv1.GhostPreview, ghost *marketingv1.Ghost) string {
+	if preview.Spec.Host != "" {
+		return preview.Spec.Host
+	}
+	return preview.Name + ".preview." + ingressHosts(ghost)[0]
+}
+
+// previewSpec derives the preview Ghost from the one it previews. It leaves the hooks reaching
+// outside the cluster to production and skips the setup the cloned content already went through.
+func previewSpec(preview *marketingv1.GhostPreview, ghost *marketingv1.Ghost) marketingv1.GhostSpec {
+	spec := derivedSpec(ghost, previewHost(preview, ghost), preview.Spec.Image)
+	spec.UpgradePolicy = nil
+	spec.AdminBootstrap = nil
+	if spec.Headless != nil {
+		spec.Headless.BuildHook = nil
+	}
+	return spec
+}
+
+// cloneContent creates the content PVC of the preview Ghost as a clone of the Ghost's one. The
+// preview owns the clone until its Ghost takes it over.
+func (r *GhostPreviewReconciler) cloneContent(ctx context.Context, preview *marketingv1.GhostPreview, ghost, previewGhost *marketingv1.Ghost) error {
+	if ephemeral(ghost) {
+		r.setCondition(preview, previewContentCondition, metav1.ConditionTrue, "Ephemeral", "Ghost "+ghost.Name+" keeps no content volume, the preview starts empty")
+		return nil
+	}
+	name := contentClaimName(previewGhost)
+	observed, err := observeChild(ctx, r.Client, preview.Namespace, name, &corev1.PersistentVolumeClaim{})
+	if err != nil {
+		return err
+	}
+	if observed != nil {
+		if !metav1.IsControlledBy(observed, previewGhost) && !isOwnedBy(observed, preview) {
+			return invalidSpecError(fmt.Errorf("PVC %s already exists and was not cloned for the preview", name))
+		}
+		r.setCondition(preview, previewContentCondition, metav1.ConditionTrue, "Cloned", "Content cloned from "+contentClaimName(ghost))
+		return nil
+	}
+	source, err := observeChild(ctx, r.Client, ghost.Namespace, contentClaimName(ghost), &corev1.PersistentVolumeClaim{})
+	if err != nil {
+		return err
+	}
+	if source == nil {
+		return externalError(fmt.Errorf("ghost %s has no content PVC to clone yet", ghost.Name))
+	}
+	desired, err := desire(pvcChild{}, previewGhost)
+	if err != nil {
+		return err
+	}
+	// A clone is at least as large as its source
+	pvcChild{}.Retain(desired, source)
+	clone := desired.(*corev1.PersistentVolumeClaim)
+	clone.Spec.DataSource = &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: source.GetName()}
+	if err := controllerutil.SetOwnerReference(preview, clone, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, clone, client.FieldOwner(fieldManager)); err != nil {
+		return err
+	}
+	r.Recorder.Event(preview, corev1.EventTypeNormal, "ContentCloned", "PVC "+name+" cloned from "+source.GetName())
+	r.setCondition(preview, previewContentCondition, metav1.ConditionTrue, "Cloned", "Content cloned from "+source.GetName())
+	return nil
+}
+
+// isOwnedBy reports whether the object has an owner reference to the owner, controller or not
+func isOwnedBy(obj, owner metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
+// ensurePreviewGhost creates or updates the preview Ghost, refusing to take over one it did not create
+func (r *GhostPreviewReconciler) ensurePreviewGhost(ctx context.Context, preview *marketingv1.GhostPreview, desired *marketingv1.Ghost) (*marketingv1.Ghost, error) {
+	previewGhost := &marketingv1.Ghost{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), previewGhost)
+	if apierrors.IsNotFound(err) {
+		if err := controllerutil.SetControllerReference(preview, desired, r.Scheme); err != nil {
+			return nil, err
+		}
+		if err := r.Create(ctx, desired); err != nil {
+			return nil, err
+		}
+		r.Recorder.Event(preview, corev1.EventTypeNormal, "PreviewCreated", "Ghost "+desired.Name+" created for the preview")
+		return desired, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !metav1.IsControlledBy(previewGhost, preview) {
+		return nil, invalidSpecError(fmt.Errorf("ghost %s/%s already exists and is not the Ghost of this preview", desired.Namespace, desired.Name))
+	}
+	if !equality.Semantic.DeepEqual(previewGhost.Spec, desired.Spec) {
+		previewGhost.Spec = desired.Spec
+		if err := r.Update(ctx, previewGhost); err != nil {
+			return nil, err
+		}
+	}
+	return previewGhost, nil
+}
+
+// importBackup restores spec.backupRef into the preview Ghost and reports the restore progress
+func (r *GhostPreviewReconciler) importBackup(ctx context.Context, preview *marketingv1.GhostPreview, previewGhost *marketingv1.Ghost) error {
+	restore := &marketingv1.GhostRestore{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: preview.Namespace, Name: preview.Name}, restore)
+	if apierrors.IsNotFound(err) {
+		restore = &marketingv1.GhostRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: preview.Name, Namespace: preview.Namespace},
+			Spec: marketingv1.GhostRestoreSpec{
+				GhostRef:  corev1.LocalObjectReference{Name: previewGhost.Name},
+				BackupRef: preview.Spec.BackupRef.DeepCopy(),
+			},
+		}
+		if err := controllerutil.SetControllerReference(preview, restore, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, restore); err != nil {
+			return err
+		}
+		r.setCondition(preview, previewContentCondition, metav1.ConditionFalse, "Importing", "Restoring backup "+preview.Spec.BackupRef.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(restore, preview) {
+		return invalidSpecError(fmt.Errorf("GhostRestore %s/%s already exists and is not the import of this preview", restore.Namespace, restore.Name))
+	}
+	switch restore.Status.Phase {
+	case marketingv1.RestorePhaseSucceeded:
+		r.setCondition(preview, previewContentCondition, metav1.ConditionTrue, "Imported", "Backup "+preview.Spec.BackupRef.Name+" restored")
+	case marketingv1.RestorePhaseFailed:
+		r.setCondition(preview, previewContentCondition, metav1.ConditionFalse, "ImportFailed", "GhostRestore "+restore.Name+" failed, delete the preview to retry")
+	default:
+		r.setCondition(preview, previewContentCondition, metav1.ConditionFalse, "Importing", "Restoring backup "+preview.Spec.BackupRef.Name)
+	}
+	return nil
+}
+
+func (r *GhostPreviewReconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+func (r *GhostPreviewReconciler) setCondition(preview *marketingv1.GhostPreview, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&preview.Status.Conditions, metav1.Condition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostPreviewReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostPreview{}).
+		Owns(&marketingv1.Ghost{}).
+		Owns(&marketingv1.GhostRestore{}).
+		Complete(r)
+}
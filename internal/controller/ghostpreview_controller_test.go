@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("GhostPreview Controller", func() {
+	const namespace = "previews"
+
+	It("should run a clone of the Ghost on a preview host until the TTL passes", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec: marketingv1.GhostSpec{
+				ImageTag: "alpine",
+				Replicas: 3,
+				Ingress:  &marketingv1.IngressSpec{Host: "blog.example.com"},
+			},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: pvcNamePrefix + "blog", Namespace: namespace},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("5Gi"),
+				}},
+			},
+		})).To(Succeed())
+		preview := &marketingv1.GhostPreview{
+			ObjectMeta: metav1.ObjectMeta{Name: "next", Namespace: namespace},
+			Spec: marketingv1.GhostPreviewSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "blog"},
+				Image:    &marketingv1.ImageSpec{Tag: "5-alpine"},
+				TTL:      metav1.Duration{Duration: time.Hour},
+			},
+		}
+		Expect(k8sClient.Create(ctx, preview)).To(Succeed())
+
+		now := preview.CreationTimestamp.Add(time.Minute)
+		reconciler := &GhostPreviewReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+			Now:      func() time.Time { return now },
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "next"}
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(59 * time.Minute))
+
+		By("cloning the content volume of the Ghost")
+		clone := &corev1.PersistentVolumeClaim{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: pvcNamePrefix + "next"}, clone)).To(Succeed())
+		Expect(clone.Spec.DataSource).To(Equal(&corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: pvcNamePrefix + "blog"}))
+		Expect(clone.Spec.Resources.Requests.Storage().String()).To(Equal("5Gi"))
+		Expect(clone.OwnerReferences).To(ContainElement(HaveField("Name", "next")))
+
+		By("running a single replica of the previewed image on the preview host")
+		previewGhost := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, key, previewGhost)).To(Succeed())
+		Expect(metav1.IsControlledBy(previewGhost, preview)).To(BeTrue())
+		Expect(previewGhost.Spec.Replicas).To(Equal(int32(1)))
+		Expect(previewGhost.Spec.Ingress.Host).To(Equal("next.preview.blog.example.com"))
+		Expect(ghostImage(previewGhost)).To(Equal("ghost:5-alpine"))
+		Expect(k8sClient.Get(ctx, key, preview)).To(Succeed())
+		Expect(preview.Status.Phase).To(Equal(marketingv1.PreviewPhaseProvisioning))
+		Expect(preview.Status.ExpiresAt.Time).To(BeTemporally("==", preview.CreationTimestamp.Add(time.Hour)))
+
+		By("turning ready with the preview Ghost")
+		previewGhost.Status.URL = "https://next.preview.blog.example.com"
+		meta.SetStatusCondition(&previewGhost.Status.Conditions, metav1.Condition{Type: "GhostReady", Status: metav1.ConditionTrue, Reason: "AllChildrenReady"})
+		Expect(k8sClient.Status().Update(ctx, previewGhost)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, preview)).To(Succeed())
+		Expect(preview.Status.Phase).To(Equal(marketingv1.PreviewPhaseReady))
+		Expect(preview.Status.URL).To(Equal("https://next.preview.blog.example.com"))
+		Expect(meta.IsStatusConditionTrue(preview.Status.Conditions, previewReadyCondition)).To(BeTrue())
+
+		By("deleting the preview once its TTL passed")
+		now = preview.CreationTimestamp.Add(time.Hour)
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(errors.IsNotFound(k8sClient.Get(ctx, key, preview))).To(BeTrue())
+	})
+})
@@ -0,0 +1,265 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// restorePollInterval paces the checks while the Deployment scales down
+const restorePollInterval = 5 * time.Second
+
+const (
+	restoreScaledDownCondition = "ScaledDown"
+	restoreRestoredCondition   = "Restored"
+	restoreScaledUpCondition   = "ScaledUp"
+)
+
+// GhostRestoreReconciler restores a backup into a Ghost, scaling it down while the
+// content volume is rewritten
+type GhostRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Proxy is the operator wide egress proxy for Ghosts without spec.proxy
+	Proxy *marketingv1.ProxySpec
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostrestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostrestores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostrestores/finalizers,verbs=update
+
+// Reconcile drives the restore through scale down, restore and scale up
+func (r *GhostRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	restore := &marketingv1.GhostRestore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if restore.Status.Phase == marketingv1.RestorePhaseSucceeded || restore.Status.Phase == marketingv1.RestorePhaseFailed {
+		return ctrl.Result{}, nil
+	}
+	original := restore.DeepCopy()
+
+	waiting, reconcileErr := r.reconcileRestore(ctx, restore)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostRestore")
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", reconcileErr.Error())
+	}
+	if !equality.Semantic.DeepEqual(original.Status, restore.Status) {
+		if err := r.Status().Patch(ctx, restore, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostRestore status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	if waiting {
+		return ctrl.Result{RequeueAfter: restorePollInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileRestore advances the restore by one step and reports whether it is waiting on the Deployment
+func (r *GhostRestoreReconciler) reconcileRestore(ctx context.Context, restore *marketingv1.GhostRestore) (bool, error) {
+	if restore.Status.StartTime == nil {
+		now := metav1.Now()
+		restore.Status.StartTime = &now
+		restore.Status.Phase = marketingv1.RestorePhasePending
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: restore.Namespace, Name: restore.Spec.GhostRef.Name}, ghost); err != nil {
+		r.setCondition(restore, restoreRestoredCondition, metav1.ConditionFalse, "GhostNotFound", err.Error())
+		return false, externalError(err)
+	}
+	if _, err := applyNamespaceDefaults(ctx, r.Client, ghost); err != nil {
+		return false, err
+	}
+	config, err := operatorConfig(ctx, r.Client)
+	if err != nil {
+		return false, err
+	}
+	applyOperatorDefaults(ghost, config)
+	source, adminKey, err := r.resolveSource(ctx, restore)
+	if err != nil {
+		r.setCondition(restore, restoreRestoredCondition, metav1.ConditionFalse, "SourceUnavailable", err.Error())
+		return false, err
+	}
+	restore.Status.Location = source.URL
+	scaleDown := source.Method != marketingv1.BackupMethodExport
+	if scaleDown && ephemeral(ghost) {
+		restore.Status.Phase = marketingv1.RestorePhaseFailed
+		r.setCondition(restore, restoreRestoredCondition, metav1.ConditionFalse, "NoContentVolume",
+			"Ghost "+ghost.Name+" keeps its content in an emptyDir, only Export backups restore into it")
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", "Ghost "+ghost.Name+" has no content volume to restore into")
+		return false, nil
+	}
+
+	if scaleDown {
+		if held := ghost.Annotations[restoreAnnotation]; held != restore.Name {
+			if held != "" {
+				return false, externalError(fmt.Errorf("ghost %s is held by restore %s", ghost.Name, held))
+			}
+			if err := r.setHold(ctx, ghost, restore.Name); err != nil {
+				return false, err
+			}
+			restore.Status.Phase = marketingv1.RestorePhaseScalingDown
+			r.setCondition(restore, restoreScaledDownCondition, metav1.ConditionFalse, "ScalingDown", "Waiting for the Ghost pods to stop")
+			return true, nil
+		}
+		workload, err := observeWorkload(ctx, r.Client, ghost)
+		if err != nil {
+			return false, err
+		}
+		if workloadReplicas(workload) > 0 {
+			return true, nil
+		}
+		r.setCondition(restore, restoreScaledDownCondition, metav1.ConditionTrue, "ScaledDown", "The Ghost pods are stopped")
+	}
+
+	observed, err := observeChild(ctx, r.Client, restore.Namespace, restoreJobNamePrefix+restore.Name, &batchv1.Job{})
+	if err != nil {
+		return false, err
+	}
+	if observed == nil {
+		job := generateRestoreJob(restore, ghost, source, adminKey, effectiveProxy(ghost, r.Proxy))
+		if err := controllerutil.SetControllerReference(restore, job, r.Scheme); err != nil {
+			return false, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return false, err
+		}
+		restore.Status.Phase = marketingv1.RestorePhaseRestoring
+		restore.Status.JobName = job.Name
+		r.setCondition(restore, restoreRestoredCondition, metav1.ConditionFalse, "JobCreated", "Restore job "+job.Name+" created")
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreStarted", "Restore job "+job.Name+" created")
+		return false, nil
+	}
+
+	job := observed.(*batchv1.Job)
+	phase := marketingv1.RestorePhase("")
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			phase = marketingv1.RestorePhaseSucceeded
+			r.setCondition(restore, restoreRestoredCondition, metav1.ConditionTrue, "RestoreSucceeded", "Restored "+source.URL)
+		case batchv1.JobFailed:
+			phase = marketingv1.RestorePhaseFailed
+			r.setCondition(restore, restoreRestoredCondition, metav1.ConditionFalse, "JobFailed", "Restore job "+job.Name+" failed: "+condition.Message)
+		}
+	}
+	if phase == "" {
+		// The Job watch brings the restore back once it finishes
+		return false, nil
+	}
+
+	// Scale back up whatever the outcome, a failed restore should not keep the site down
+	if scaleDown {
+		if err := r.setHold(ctx, ghost, ""); err != nil {
+			return false, err
+		}
+		r.setCondition(restore, restoreScaledUpCondition, metav1.ConditionTrue, "ScaledUp", "The Ghost is scaled back to its replicas")
+	}
+	restore.Status.Phase = phase
+	restore.Status.CompletionTime = job.Status.CompletionTime
+	if phase == marketingv1.RestorePhaseFailed {
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", "Restore job "+job.Name+" failed, see its logs")
+	} else {
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreSucceeded", "Restored "+source.URL)
+	}
+	return false, nil
+}
+
+// resolveSource returns the artifact to restore and the Admin API key used to import it
+func (r *GhostRestoreReconciler) resolveSource(ctx context.Context, restore *marketingv1.GhostRestore) (*marketingv1.RestoreSource, *corev1.SecretKeySelector, error) {
+	source := restore.Spec.Source
+	adminKey := restore.Spec.AdminAPIKeySecretRef
+	if ref := restore.Spec.BackupRef; ref != nil {
+		backup := &marketingv1.GhostBackup{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: restore.Namespace, Name: ref.Name}, backup); err != nil {
+			return nil, nil, externalError(err)
+		}
+		if backup.Status.Phase != marketingv1.BackupPhaseSucceeded {
+			return nil, nil, externalError(fmt.Errorf("backup %s has not succeeded", backup.Name))
+		}
+		source = &marketingv1.RestoreSource{Method: backup.Spec.Method, BackupDestination: backup.Spec.Destination}
+		source.URL = backup.Status.Location
+		if adminKey == nil {
+			adminKey = backup.Spec.AdminAPIKeySecretRef
+		}
+	}
+	if source.Method == marketingv1.BackupMethodExport && adminKey == nil {
+		return nil, nil, invalidSpecError(fmt.Errorf("importing an Export backup requires adminAPIKeySecretRef"))
+	}
+	return source, adminKey, nil
+}
+
+// setHold sets or clears the restore annotation that scales the Ghost down
+func (r *GhostRestoreReconciler) setHold(ctx context.Context, ghost *marketingv1.Ghost, restoreName string) error {
+	original := ghost.DeepCopy()
+	if restoreName == "" {
+		delete(ghost.Annotations, restoreAnnotation)
+	} else {
+		if ghost.Annotations == nil {
+			ghost.Annotations = map[string]string{}
+		}
+		ghost.Annotations[restoreAnnotation] = restoreName
+	}
+	return r.Patch(ctx, ghost, client.MergeFrom(original))
+}
+
+func (r *GhostRestoreReconciler) setCondition(restore *marketingv1.GhostRestore, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostRestore{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("GhostRestore Controller", func() {
+	const namespace = "restores"
+
+	It("should scale Ghost down around a volume restore", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		ghostKey := types.NamespacedName{Namespace: namespace, Name: "blog"}
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: ghostKey.Name, Namespace: namespace},
+			Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 2},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "rollback", Namespace: namespace},
+			Spec: marketingv1.GhostRestoreSpec{
+				GhostRef: corev1.LocalObjectReference{Name: ghostKey.Name},
+				Source: &marketingv1.RestoreSource{
+					Method: marketingv1.BackupMethodVolume,
+					BackupDestination: marketingv1.BackupDestination{
+						URL:                  "s3://backups/restores/nightly.tar.gz",
+						CredentialsSecretRef: corev1.LocalObjectReference{Name: "s3-credentials"},
+					},
+				},
+			},
+		})).To(Succeed())
+
+		reconciler := &GhostRestoreReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "rollback"}
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(restorePollInterval))
+
+		ghost := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, ghostKey, ghost)).To(Succeed())
+		Expect(ghost.Annotations).To(HaveKeyWithValue(restoreAnnotation, "rollback"))
+		deployment, err := generateDesiredDeployment(ghost)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*deployment.Spec.Replicas).To(BeZero())
+
+		By("starting the restore Job once no Ghost pod runs")
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		restore := &marketingv1.GhostRestore{}
+		Expect(k8sClient.Get(ctx, key, restore)).To(Succeed())
+		Expect(restore.Status.Phase).To(Equal(marketingv1.RestorePhaseRestoring))
+		Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, restoreScaledDownCondition)).To(BeTrue())
+
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: restore.Status.JobName}, job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.InitContainers[0].Env).To(ContainElement(corev1.EnvVar{Name: "SOURCE", Value: "s3://backups/restores/nightly.tar.gz"}))
+
+		By("scaling back up once the Job completes")
+		started := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+		finished := metav1.NewTime(time.Now().Truncate(time.Second))
+		job.Status.StartTime = &started
+		job.Status.CompletionTime = &finished
+		job.Status.Succeeded = 1
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue},
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+		}
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, restore)).To(Succeed())
+		Expect(restore.Status.Phase).To(Equal(marketingv1.RestorePhaseSucceeded))
+		Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, restoreScaledUpCondition)).To(BeTrue())
+		Expect(k8sClient.Get(ctx, ghostKey, ghost)).To(Succeed())
+		Expect(ghost.Annotations).NotTo(HaveKey(restoreAnnotation))
+	})
+})
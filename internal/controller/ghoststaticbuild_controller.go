@@ -0,0 +1,301 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// staticBuildCondition reports the outcome of the most recent build
+	staticBuildCondition = "Built"
+	// staticBuildAnnotation starts a build whenever its value changes
+	staticBuildAnnotation = "marketing.kb.dev/build"
+	// defaultStaticBuildPollInterval paces the checks for published content
+	defaultStaticBuildPollInterval = 5 * time.Minute
+	// staticBuildWaitInterval paces the checks on a Ghost that does not answer yet
+	staticBuildWaitInterval = 30 * time.Second
+)
+
+// GhostStaticBuildReconciler crawls Ghosts into static sites with a Job per build
+type GhostStaticBuildReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads Secrets without caching them cluster wide
+	APIReader client.Reader
+	// AdminURL returns the base URL of a Ghost's Admin API, the in-cluster Service by default
+	AdminURL func(ghost *marketingv1.Ghost) string
+	// Proxy is the operator wide egress proxy for Ghosts without spec.proxy
+	Proxy *marketingv1.ProxySpec
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghoststaticbuilds,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghoststaticbuilds/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghoststaticbuilds/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps;serviceaccounts;services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile follows the running build and starts the next one when it is requested, on
+// creation, through the build annotation or by a change of the published content
+func (r *GhostStaticBuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	build := &marketingv1.GhostStaticBuild{}
+	if err := r.Get(ctx, req.NamespacedName, build); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	original := build.DeepCopy()
+
+	requeueAfter, reconcileErr := r.reconcileStaticBuild(ctx, build)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostStaticBuild")
+		r.Recorder.Event(build, corev1.EventTypeWarning, "BuildFailed", reconcileErr.Error())
+	}
+	if !equality.Semantic.DeepEqual(original.Status, build.Status) {
+		if err := r.Status().Patch(ctx, build, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostStaticBuild status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcileStaticBuild returns when the published content is to be checked next, zero when
+// it is not watched
+func (r *GhostStaticBuildReconciler) reconcileStaticBuild(ctx context.Context, build *marketingv1.GhostStaticBuild) (time.Duration, error) {
+	fail := func(reason string, err error) error {
+		meta.SetStatusCondition(&build.Status.Conditions, metav1.Condition{
+			Type:    staticBuildCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Error(),
+		})
+		return err
+	}
+
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: build.Namespace, Name: build.Spec.GhostRef.Name}, ghost); err != nil {
+		return 0, fail("GhostNotFound", externalError(err))
+	}
+	if build.Spec.Destination.Nginx != nil {
+		if err := r.applyStaticSite(ctx, build); err != nil {
+			return 0, err
+		}
+		build.Status.ServiceName = staticSiteName(build)
+	} else {
+		build.Status.ServiceName = ""
+	}
+
+	pollInterval := time.Duration(0)
+	if onPublish := build.Spec.OnPublish; onPublish != nil {
+		pollInterval = defaultStaticBuildPollInterval
+		if onPublish.PollInterval != nil && onPublish.PollInterval.Duration > 0 {
+			pollInterval = onPublish.PollInterval.Duration
+		}
+	}
+
+	if build.Status.Phase == marketingv1.StaticBuildPhaseRunning {
+		if running, err := r.followBuild(ctx, build); err != nil || running {
+			return pollInterval, err
+		}
+	}
+
+	reason := ""
+	token := build.Annotations[staticBuildAnnotation]
+	switch {
+	case build.Status.Builds == 0:
+		reason = "the GhostStaticBuild was created"
+	case token != "" && token != build.Status.BuildToken:
+		reason = "it was requested through the " + staticBuildAnnotation + " annotation"
+	}
+	// The crawl fails like the Admin API until Ghost answers, the next pass tries again
+	if ghost.Status.ReadyReplicas == 0 || applicationUnhealthy(ghost) != "" {
+		if build.Status.Builds == 0 {
+			meta.SetStatusCondition(&build.Status.Conditions, metav1.Condition{
+				Type:    staticBuildCondition,
+				Status:  metav1.ConditionUnknown,
+				Reason:  "WaitingForGhost",
+				Message: "The site is built once Ghost answers",
+			})
+		}
+		return staticBuildWaitInterval, nil
+	}
+
+	var changed *metav1.Time
+	if onPublish := build.Spec.OnPublish; onPublish != nil {
+		api, err := newAdminClient(ctx, r.APIReader, r.AdminURL, ghost, build.Namespace, onPublish.AdminAPIKeySecretRef)
+		if err != nil {
+			return 0, fail("AdminAPIUnavailable", err)
+		}
+		last, err := api.LastPublishedChange(ctx)
+		if err != nil {
+			return 0, fail("AdminAPIUnavailable", externalError(err))
+		}
+		changed = &metav1.Time{Time: last}
+		if reason == "" && (build.Status.ContentUpdatedAt == nil || last.After(build.Status.ContentUpdatedAt.Time)) {
+			reason = "content was published"
+		}
+	}
+	if reason == "" {
+		return pollInterval, nil
+	}
+
+	if _, err := applyNamespaceDefaults(ctx, r.Client, ghost); err != nil {
+		return 0, err
+	}
+	config, err := operatorConfig(ctx, r.Client)
+	if err != nil {
+		return 0, err
+	}
+	applyOperatorDefaults(ghost, config)
+	n := build.Status.Builds + 1
+	job := generateStaticBuildJob(build, ghost, n, effectiveProxy(ghost, r.Proxy))
+	if err := controllerutil.SetControllerReference(build, job, r.Scheme); err != nil {
+		return 0, err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return 0, fail("JobNotCreated", err)
+	}
+	build.Status.Builds = n
+	build.Status.JobName = job.Name
+	build.Status.Phase = marketingv1.StaticBuildPhaseRunning
+	build.Status.BuildToken = token
+	if changed != nil {
+		build.Status.ContentUpdatedAt = changed
+	}
+	if meta.FindStatusCondition(build.Status.Conditions, staticBuildCondition) == nil || n == 1 {
+		meta.SetStatusCondition(&build.Status.Conditions, metav1.Condition{
+			Type:    staticBuildCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Building",
+			Message: "Build job " + job.Name + " created",
+		})
+	}
+	r.Recorder.Event(build, corev1.EventTypeNormal, "BuildStarted", "Build job "+job.Name+" created as "+reason)
+	return pollInterval, nil
+}
+
+// followBuild records the outcome of the running build Job and reports whether it still runs
+func (r *GhostStaticBuildReconciler) followBuild(ctx context.Context, build *marketingv1.GhostStaticBuild) (bool, error) {
+	observed, err := observeChild(ctx, r.Client, build.Namespace, build.Status.JobName, &batchv1.Job{})
+	if err != nil {
+		return false, err
+	}
+	if observed == nil {
+		build.Status.Phase = marketingv1.StaticBuildPhaseFailed
+		meta.SetStatusCondition(&build.Status.Conditions, metav1.Condition{
+			Type:    staticBuildCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "JobMissing",
+			Message: "Build job " + build.Status.JobName + " was deleted before it finished",
+		})
+		return false, nil
+	}
+	job := observed.(*batchv1.Job)
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			build.Status.Phase = marketingv1.StaticBuildPhaseSucceeded
+			build.Status.LastBuildTime = job.Status.CompletionTime
+			meta.SetStatusCondition(&build.Status.Conditions, metav1.Condition{
+				Type:    staticBuildCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  "BuildSucceeded",
+				Message: "Build job " + job.Name + " published the site",
+			})
+			r.Recorder.Event(build, corev1.EventTypeNormal, "BuildSucceeded", "Build job "+job.Name+" published the site")
+			return false, nil
+		case batchv1.JobFailed:
+			build.Status.Phase = marketingv1.StaticBuildPhaseFailed
+			meta.SetStatusCondition(&build.Status.Conditions, metav1.Condition{
+				Type:    staticBuildCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  "JobFailed",
+				Message: "Build job " + job.Name + " failed: " + condition.Message,
+			})
+			r.Recorder.Event(build, corev1.EventTypeWarning, "BuildFailed", "Build job "+job.Name+" failed, see its logs")
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// applyStaticSite applies the ConfigMap, the identity of the build Job and the nginx
+// Deployment and Service serving the site
+func (r *GhostStaticBuildReconciler) applyStaticSite(ctx context.Context, build *marketingv1.GhostStaticBuild) error {
+	site := generateStaticSiteConfigMap(build)
+	serviceAccount, role, binding := generateStaticBuildRBAC(build)
+	for _, object := range []client.Object{site, serviceAccount, role, binding, generateStaticSiteService(build)} {
+		if err := r.apply(ctx, build, object); err != nil {
+			return err
+		}
+	}
+	// The Job writes the files, the Deployment mounts those found
+	if err := r.Get(ctx, client.ObjectKeyFromObject(site), site); err != nil {
+		return err
+	}
+	return r.apply(ctx, build, generateStaticSiteDeployment(build, site))
+}
+
+func (r *GhostStaticBuildReconciler) apply(ctx context.Context, build *marketingv1.GhostStaticBuild, object client.Object) error {
+	if err := controllerutil.SetControllerReference(build, object, r.Scheme); err != nil {
+		return err
+	}
+	return r.Patch(ctx, object, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostStaticBuildReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostStaticBuild{}).
+		Owns(&batchv1.Job{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&rbacv1.Role{}).
+		Complete(r)
+}
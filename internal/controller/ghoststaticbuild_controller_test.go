@@ -0,0 +1,234 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("GhostStaticBuild Controller", func() {
+	const namespace = "static-builds"
+
+	var (
+		mu        sync.Mutex
+		published string
+		server    *httptest.Server
+	)
+
+	BeforeEach(func() {
+		published = "2026-10-01T08:00:00.000Z"
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch r.URL.Path {
+			case "/ghost/api/admin/posts/":
+				_, _ = io.WriteString(w, `{"posts":[{"updated_at":"`+published+`"}]}`)
+			case "/ghost/api/admin/pages/":
+				_, _ = io.WriteString(w, `{"pages":[]}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		DeferCleanup(server.Close)
+
+		for _, obj := range []client.Object{
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+			&marketingv1.Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+				Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1, URL: "https://blog.example.com"},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "admin-key", Namespace: namespace},
+				StringData: map[string]string{"key": "64f0c1:a1b2c3d4"},
+			},
+		} {
+			if err := k8sClient.Create(ctx, obj); !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+	})
+
+	completeJob := func(name string) {
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, job)).To(Succeed())
+		started := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+		finished := metav1.NewTime(time.Now().Truncate(time.Second))
+		job.Status.StartTime = &started
+		job.Status.CompletionTime = &finished
+		job.Status.Succeeded = 1
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue},
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+		}
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+	}
+
+	It("should serve the site from nginx and build it again on request and on publish", func() {
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostStaticBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: namespace},
+			Spec: marketingv1.GhostStaticBuildSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "blog"},
+				OnPublish: &marketingv1.StaticBuildOnPublish{
+					AdminAPIKeySecretRef: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "admin-key"},
+						Key:                  "key",
+					},
+				},
+				Destination: marketingv1.StaticBuildDestination{Nginx: &marketingv1.StaticBuildNginx{}},
+			},
+		})).To(Succeed())
+
+		recorder := record.NewFakeRecorder(100)
+		reconciler := &GhostStaticBuildReconciler{
+			Client:    k8sClient,
+			Scheme:    k8sClient.Scheme(),
+			Recorder:  recorder,
+			APIReader: k8sClient,
+			AdminURL:  func(*marketingv1.Ghost) string { return server.URL },
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "site"}
+		build := &marketingv1.GhostStaticBuild{}
+
+		By("waiting for Ghost to answer")
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(staticBuildWaitInterval))
+		Expect(k8sClient.Get(ctx, key, build)).To(Succeed())
+		Expect(build.Status.Builds).To(BeZero())
+		Expect(build.Status.ServiceName).To(Equal("ghost-static-site"))
+		Expect(meta.FindStatusCondition(build.Status.Conditions, staticBuildCondition).Reason).To(Equal("WaitingForGhost"))
+
+		site := types.NamespacedName{Namespace: namespace, Name: "ghost-static-site"}
+		Expect(k8sClient.Get(ctx, site, &corev1.Service{})).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "ghost-static-build-site"}, &corev1.ServiceAccount{})).To(Succeed())
+		role := &rbacv1.Role{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "ghost-static-build-site"}, role)).To(Succeed())
+		Expect(role.Rules[0].ResourceNames).To(ConsistOf("ghost-static-site"))
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, site, deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Volumes[0].EmptyDir).NotTo(BeNil())
+
+		By("building once Ghost answers")
+		ghost := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "blog"}, ghost)).To(Succeed())
+		ghost.Status.ReadyReplicas = 1
+		Expect(k8sClient.Status().Update(ctx, ghost)).To(Succeed())
+		result, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(defaultStaticBuildPollInterval))
+		Expect(k8sClient.Get(ctx, key, build)).To(Succeed())
+		Expect(build.Status.Builds).To(Equal(int64(1)))
+		Expect(build.Status.Phase).To(Equal(marketingv1.StaticBuildPhaseRunning))
+		Expect(build.Status.ContentUpdatedAt.UTC()).To(Equal(time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)))
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "ghost-static-build-site-1"}, job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.ServiceAccountName).To(Equal("ghost-static-build-site"))
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElements(
+			corev1.EnvVar{Name: "SITE_URL", Value: "https://blog.example.com"},
+			corev1.EnvVar{Name: "CONFIGMAP", Value: "ghost-static-site"},
+		))
+		Eventually(recorder.Events).Should(Receive(HavePrefix("Normal BuildStarted Build job ghost-static-build-site-1 created as the GhostStaticBuild was created")))
+
+		By("mounting the files the build wrote")
+		files := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, site, files)).To(Succeed())
+		files.Data = map[string]string{"index.html": "<h1>Blog</h1>", "about..index.html": "<h1>About</h1>"}
+		Expect(k8sClient.Update(ctx, files)).To(Succeed())
+		completeJob("ghost-static-build-site-1")
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, build)).To(Succeed())
+		Expect(build.Status.Phase).To(Equal(marketingv1.StaticBuildPhaseSucceeded))
+		Expect(build.Status.LastBuildTime).NotTo(BeNil())
+		Expect(meta.IsStatusConditionTrue(build.Status.Conditions, staticBuildCondition)).To(BeTrue())
+		Expect(k8sClient.Get(ctx, site, deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Volumes[0].ConfigMap.Items).To(Equal([]corev1.KeyToPath{
+			{Key: "about..index.html", Path: "about/index.html"},
+			{Key: "index.html", Path: "index.html"},
+		}))
+		Expect(k8sClient.Get(ctx, key, build)).To(Succeed())
+		Expect(build.Status.Builds).To(Equal(int64(1)))
+
+		By("building again on request")
+		build.Annotations = map[string]string{staticBuildAnnotation: "2026-10-17"}
+		Expect(k8sClient.Update(ctx, build)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, build)).To(Succeed())
+		Expect(build.Status.JobName).To(Equal("ghost-static-build-site-2"))
+		Expect(build.Status.BuildToken).To(Equal("2026-10-17"))
+
+		By("building again once content is published")
+		completeJob("ghost-static-build-site-2")
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, build)).To(Succeed())
+		Expect(build.Status.Builds).To(Equal(int64(2)))
+		mu.Lock()
+		published = "2026-10-17T10:00:00.000Z"
+		mu.Unlock()
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, build)).To(Succeed())
+		Expect(build.Status.JobName).To(Equal("ghost-static-build-site-3"))
+		Eventually(recorder.Events).Should(Receive(HavePrefix("Normal BuildStarted Build job ghost-static-build-site-3 created as content was published")))
+	})
+
+	It("should synchronise the site to an object store", func() {
+		build := &marketingv1.GhostStaticBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: namespace},
+			Spec: marketingv1.GhostStaticBuildSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "blog"},
+				Destination: marketingv1.StaticBuildDestination{ObjectStore: &marketingv1.BackupDestination{
+					URL:                  "s3://blog-static-site",
+					CredentialsSecretRef: corev1.LocalObjectReference{Name: "bucket"},
+				}},
+			},
+		}
+		ghost := &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1, URL: "https://blog.example.com"},
+		}
+		job := generateStaticBuildJob(build, ghost, 4, nil)
+		Expect(job.Name).To(Equal("ghost-static-build-site-4"))
+		Expect(job.Spec.Template.Spec.ServiceAccountName).To(BeEmpty())
+		Expect(job.Spec.Template.Spec.InitContainers[0].Env).To(ContainElement(corev1.EnvVar{Name: "OUTPUT", Value: staticSiteDir}))
+		publish := job.Spec.Template.Spec.Containers[0]
+		Expect(publish.Command).To(Equal([]string{"sh", "-c", `aws s3 sync --delete "$SOURCE" "$TARGET"`}))
+		Expect(publish.Env).To(ContainElement(corev1.EnvVar{Name: "TARGET", Value: "s3://blog-static-site"}))
+	})
+})
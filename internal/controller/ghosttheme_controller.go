@@ -0,0 +1,261 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+// maxThemeSize bounds theme downloads, Ghost itself rejects larger uploads
+const maxThemeSize = 50 << 20
+
+const (
+	themeUploadedCondition  = "Uploaded"
+	themeActivatedCondition = "Activated"
+)
+
+// GhostThemeReconciler uploads GhostTheme zips to their Ghost and activates them
+type GhostThemeReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads Secrets and ConfigMaps without caching them cluster wide
+	APIReader client.Reader
+	// AdminURL returns the base URL of a Ghost's Admin API, the in-cluster Service by default
+	AdminURL func(ghost *marketingv1.Ghost) string
+	// HTTPClient downloads themes from URL sources
+	HTTPClient *http.Client
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostthemes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostthemes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostthemes/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Reconcile uploads the theme whenever its zip changes and activates it when requested
+func (r *GhostThemeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	theme := &marketingv1.GhostTheme{}
+	if err := r.Get(ctx, req.NamespacedName, theme); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	original := theme.DeepCopy()
+
+	reconcileErr := r.reconcileTheme(ctx, theme)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile GhostTheme")
+		r.Recorder.Event(theme, corev1.EventTypeWarning, "ThemeFailed", reconcileErr.Error())
+	} else {
+		theme.Status.ObservedGeneration = theme.Generation
+	}
+
+	if !equality.Semantic.DeepEqual(original.Status, theme.Status) {
+		if err := r.Status().Patch(ctx, theme, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update GhostTheme status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileTheme records the outcome of each step in the theme's conditions
+func (r *GhostThemeReconciler) reconcileTheme(ctx context.Context, theme *marketingv1.GhostTheme) error {
+	fail := func(reason string, err error) error {
+		meta.SetStatusCondition(&theme.Status.Conditions, metav1.Condition{
+			Type:    themeUploadedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Error(),
+		})
+		return err
+	}
+
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: theme.Namespace, Name: theme.Spec.GhostRef.Name}, ghost); err != nil {
+		return fail("GhostNotFound", externalError(err))
+	}
+	zip, err := r.fetchTheme(ctx, theme)
+	if err != nil {
+		return fail("SourceUnavailable", err)
+	}
+	api, err := r.adminClient(ctx, ghost, theme.Namespace, theme.Spec.AdminAPIKeySecretRef)
+	if err != nil {
+		return fail("AdminAPIKeyUnavailable", err)
+	}
+
+	sum := sha256.Sum256(zip)
+	checksum := hex.EncodeToString(sum[:])
+	uploaded := false
+	if theme.Status.Checksum != checksum || !meta.IsStatusConditionTrue(theme.Status.Conditions, themeUploadedCondition) {
+		// Ghost names the theme after the zip file, so the theme is named after the resource
+		installed, err := api.UploadTheme(ctx, theme.Name+".zip", zip)
+		if err != nil {
+			return fail("UploadFailed", externalError(err))
+		}
+		theme.Status.ThemeName = installed.Name
+		theme.Status.Checksum = checksum
+		uploaded = true
+		meta.SetStatusCondition(&theme.Status.Conditions, metav1.Condition{
+			Type:    themeUploadedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Uploaded",
+			Message: fmt.Sprintf("Theme %s uploaded to Ghost %s", installed.Name, ghost.Name),
+		})
+		r.Recorder.Event(theme, corev1.EventTypeNormal, "ThemeUploaded", "Theme "+installed.Name+" uploaded")
+	}
+
+	if !theme.Spec.Activate {
+		meta.SetStatusCondition(&theme.Status.Conditions, metav1.Condition{
+			Type:    themeActivatedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NotRequested",
+			Message: "spec.activate is false",
+		})
+		return nil
+	}
+	if !uploaded && meta.IsStatusConditionTrue(theme.Status.Conditions, themeActivatedCondition) && theme.Status.ObservedGeneration == theme.Generation {
+		return nil
+	}
+	if _, err := api.ActivateTheme(ctx, theme.Status.ThemeName); err != nil {
+		meta.SetStatusCondition(&theme.Status.Conditions, metav1.Condition{
+			Type:    themeActivatedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ActivationFailed",
+			Message: err.Error(),
+		})
+		return externalError(err)
+	}
+	meta.SetStatusCondition(&theme.Status.Conditions, metav1.Condition{
+		Type:    themeActivatedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Activated",
+		Message: "Theme " + theme.Status.ThemeName + " is the active theme",
+	})
+	r.Recorder.Event(theme, corev1.EventTypeNormal, "ThemeActivated", "Theme "+theme.Status.ThemeName+" activated")
+	return nil
+}
+
+// fetchTheme reads the theme zip from its ConfigMap or downloads it
+func (r *GhostThemeReconciler) fetchTheme(ctx context.Context, theme *marketingv1.GhostTheme) ([]byte, error) {
+	source := theme.Spec.Source
+	if ref := source.ConfigMapRef; ref != nil {
+		configMap := &corev1.ConfigMap{}
+		if err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: theme.Namespace, Name: ref.Name}, configMap); err != nil {
+			return nil, externalError(err)
+		}
+		zip, ok := configMap.BinaryData[ref.Key]
+		if !ok {
+			return nil, invalidSpecError(fmt.Errorf("ConfigMap %s has no binaryData key %s", ref.Name, ref.Key))
+		}
+		return zip, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, invalidSpecError(err)
+	}
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 2 * time.Minute}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, externalError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, externalError(fmt.Errorf("downloading %s: HTTP %d", source.URL, resp.StatusCode))
+	}
+	zip, err := io.ReadAll(io.LimitReader(resp.Body, maxThemeSize+1))
+	if err != nil {
+		return nil, externalError(err)
+	}
+	if len(zip) > maxThemeSize {
+		return nil, invalidSpecError(fmt.Errorf("theme at %s exceeds %d bytes", source.URL, maxThemeSize))
+	}
+	return zip, nil
+}
+
+// adminClient builds an Admin API client for the Ghost from the referenced key
+func (r *GhostThemeReconciler) adminClient(ctx context.Context, ghost *marketingv1.Ghost, namespace string, ref corev1.SecretKeySelector) (*ghostapi.Client, error) {
+	return newAdminClient(ctx, r.APIReader, r.AdminURL, ghost, namespace, ref)
+}
+
+// newAdminClient builds an Admin API client for the Ghost from the referenced key, reaching it at
+// the address adminURL returns or the in-cluster Service when adminURL is nil
+func newAdminClient(ctx context.Context, reader client.Reader, adminURL func(*marketingv1.Ghost) string, ghost *marketingv1.Ghost, namespace string, ref corev1.SecretKeySelector) (*ghostapi.Client, error) {
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		return nil, externalError(err)
+	}
+	key, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, invalidSpecError(fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key))
+	}
+	if adminURL == nil {
+		adminURL = ghostAdminURL
+	}
+	api, err := ghostapi.New(adminURL(ghost), string(key))
+	if err != nil {
+		return nil, invalidSpecError(err)
+	}
+	return api, nil
+}
+
+// ghostAdminURL is the in-cluster address of the Ghost Service, under the sub-path Ghost serves
+func ghostAdminURL(ghost *marketingv1.Ghost) string {
+	return ghostServiceURL(ghost) + ghostPath(ghost)
+}
+
+// ghostServiceURL is the in-cluster origin of the Ghost Service
+func ghostServiceURL(ghost *marketingv1.Ghost) string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", childName(ghost, svcNamePrefix), ghost.Namespace, servicePort(ghost))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostThemeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.GhostTheme{}).
+		Complete(r)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("GhostTheme Controller", func() {
+	const namespace = "themes"
+
+	var (
+		mu       sync.Mutex
+		requests []string
+		server   *httptest.Server
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"themes": []map[string]interface{}{{"name": "casper", "active": r.Method == http.MethodPut}},
+			})
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("should upload and activate the theme once per zip", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin-key", Namespace: namespace},
+			StringData: map[string]string{"key": "64f0c1:a1b2c3d4"},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "casper-zip", Namespace: namespace},
+			BinaryData: map[string][]byte{"casper.zip": []byte("PK\x03\x04theme")},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.GhostTheme{
+			ObjectMeta: metav1.ObjectMeta{Name: "casper", Namespace: namespace},
+			Spec: marketingv1.GhostThemeSpec{
+				GhostRef: corev1.LocalObjectReference{Name: "blog"},
+				Source: marketingv1.ThemeSource{ConfigMapRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "casper-zip"},
+					Key:                  "casper.zip",
+				}},
+				Activate: true,
+				AdminAPIKeySecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "admin-key"},
+					Key:                  "key",
+				},
+			},
+		})).To(Succeed())
+
+		reconciler := &GhostThemeReconciler{
+			Client:    k8sClient,
+			Scheme:    k8sClient.Scheme(),
+			Recorder:  record.NewFakeRecorder(100),
+			APIReader: k8sClient,
+			AdminURL:  func(*marketingv1.Ghost) string { return server.URL },
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "casper"}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(Equal([]string{
+			"POST /ghost/api/admin/themes/upload/",
+			"PUT /ghost/api/admin/themes/casper/activate/",
+		}))
+
+		theme := &marketingv1.GhostTheme{}
+		Expect(k8sClient.Get(ctx, key, theme)).To(Succeed())
+		Expect(theme.Status.ThemeName).To(Equal("casper"))
+		Expect(theme.Status.Checksum).NotTo(BeEmpty())
+		Expect(meta.IsStatusConditionTrue(theme.Status.Conditions, themeUploadedCondition)).To(BeTrue())
+		Expect(meta.IsStatusConditionTrue(theme.Status.Conditions, themeActivatedCondition)).To(BeTrue())
+
+		By("leaving an unchanged zip alone")
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(HaveLen(2))
+	})
+})
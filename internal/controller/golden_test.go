@@ -0,0 +1,660 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// Run `go test ./internal/controller/ -run TestGoldenManifests -update-golden` to rewrite the snapshots
+// after an intended manifest change, then review the diff like any other code.
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden manifest snapshots")
+
+// goldenCase is one Ghost spec whose rendered children are snapshotted in testdata/golden
+type goldenCase struct {
+	name       string
+	reconciler *GhostReconciler
+	spec       marketingv1.GhostSpec
+}
+
+var goldenCases = []goldenCase{
+	{
+		name: "default",
+		spec: marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1},
+	},
+	{
+		name: "mail",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "5.82.0",
+			Replicas: 1,
+			Mail: &marketingv1.MailSpec{
+				Transport: "SMTP",
+				Host:      "smtp.example.com",
+				Port:      587,
+				User:      "ghost",
+				From:      "blog@example.com",
+				PasswordSecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "ghost-mail"},
+					Key:                  "password",
+				},
+			},
+		},
+	},
+	{
+		name: "ingress-tls",
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			Ingress: &marketingv1.IngressSpec{
+				Host:        "blog.example.com",
+				ExtraHosts:  []string{"www.example.com"},
+				ClassName:   "traefik",
+				Annotations: map[string]string{"example.com/owner": "marketing"},
+				TLS: &marketingv1.IngressTLSSpec{
+					Enabled:   true,
+					IssuerRef: &marketingv1.IssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer", Group: "cert-manager.io"},
+				},
+			},
+		},
+	},
+	{
+		name: "gateway-api",
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			Routing: &marketingv1.RoutingSpec{
+				Mode:    marketingv1.RoutingModeGatewayAPI,
+				Gateway: &marketingv1.GatewayReference{Name: "public", Namespace: "gateways"},
+			},
+		},
+	},
+	{
+		name:       "openshift-route",
+		reconciler: &GhostReconciler{RouteAPIAvailable: true},
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			Routing:       &marketingv1.RoutingSpec{Mode: marketingv1.RoutingModeRoute},
+		},
+	},
+	{
+		name: "load-balancer",
+		spec: marketingv1.GhostSpec{
+			ImageTag:    "latest",
+			Replicas:    2,
+			Persistence: &marketingv1.PersistenceSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+			Service: &marketingv1.ServiceSpec{
+				Type:            corev1.ServiceTypeLoadBalancer,
+				Port:            8080,
+				NodePort:        30080,
+				SessionAffinity: corev1.ServiceAffinityClientIP,
+				Annotations:     map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+			},
+		},
+	},
+	{
+		name: "private-registry",
+		spec: marketingv1.GhostSpec{
+			Replicas: 1,
+			Image: &marketingv1.ImageSpec{
+				Repository:  "registry.example.com/mirror/ghost",
+				Tag:         "5.82.0-alpine",
+				PullSecrets: []corev1.LocalObjectReference{{Name: "registry-credentials"}},
+			},
+		},
+	},
+	{
+		name: "digest-pinned",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Image: &marketingv1.ImageSpec{
+				Tag:        "5.82.0",
+				Digest:     "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+				PullPolicy: corev1.PullIfNotPresent,
+			},
+		},
+	},
+	{
+		name: "staging-profile",
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			Profile:       marketingv1.ProfileStaging,
+		},
+	},
+	{
+		name: "scheduler-check",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			SchedulerCheck: &marketingv1.SchedulerCheckSpec{
+				Enabled: true,
+				AdminAPIKeySecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "ghost-admin-api"},
+					Key:                  "key",
+				},
+			},
+		},
+	},
+	{
+		name: "content-init",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			ContentInit: &marketingv1.ContentInitSpec{
+				Git: &marketingv1.GitContentSource{
+					Repository:           "https://github.com/example/blog-content.git",
+					Ref:                  "main",
+					CredentialsSecretRef: &corev1.LocalObjectReference{Name: "blog-content"},
+				},
+				SubPath: "content",
+			},
+			Persistence: &marketingv1.PersistenceSpec{FixPermissions: true},
+			InitContainers: []corev1.Container{
+				{Name: "warm-cache", Image: "busybox:1.36", Command: []string{"true"}},
+			},
+		},
+	},
+	{
+		name: "sidecars",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Sidecars: []corev1.Container{
+				{
+					Name:         "oauth2-proxy",
+					Image:        "quay.io/oauth2-proxy/oauth2-proxy:v7.6.0",
+					Args:         []string{"--upstream=http://127.0.0.1:2368", "--config=/etc/oauth2-proxy/oauth2-proxy.cfg"},
+					VolumeMounts: []corev1.VolumeMount{{Name: "oauth2-proxy-config", MountPath: "/etc/oauth2-proxy"}},
+				},
+			},
+			ExtraVolumes: []corev1.Volume{
+				{
+					Name: "oauth2-proxy-config",
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "oauth2-proxy"}},
+					},
+				},
+			},
+			ExtraVolumeMounts: []corev1.VolumeMount{{Name: "oauth2-proxy-config", MountPath: "/etc/oauth2-proxy", ReadOnly: true}},
+		},
+	},
+	{
+		name: "eviction-protection",
+		spec: marketingv1.GhostSpec{
+			ImageTag:           "latest",
+			Replicas:           1,
+			EvictionProtection: true,
+		},
+	},
+	{
+		name: "common-metadata",
+		spec: marketingv1.GhostSpec{
+			ImageTag:          "latest",
+			Replicas:          1,
+			EnableIngress:     true,
+			CommonLabels:      map[string]string{"cost-center": "marketing"},
+			CommonAnnotations: map[string]string{"backup.example.com/policy": "daily"},
+		},
+	},
+	{
+		name: "zone-spread",
+		spec: marketingv1.GhostSpec{
+			ImageTag:    "latest",
+			Replicas:    3,
+			Persistence: &marketingv1.PersistenceSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+			Scheduling:  &marketingv1.SchedulingSpec{SpreadPolicy: marketingv1.SpreadPolicyZones},
+		},
+	},
+	{
+		name: "ephemeral-storage",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Profile:  marketingv1.ProfileProduction,
+			EphemeralStorage: &marketingv1.EphemeralStorageSpec{
+				Request: ptr.To(resource.MustParse("1Gi")),
+				Limit:   ptr.To(resource.MustParse("4Gi")),
+			},
+		},
+	},
+	{
+		name: "autoscaling",
+		spec: marketingv1.GhostSpec{
+			ImageTag:    "latest",
+			Replicas:    1,
+			Persistence: &marketingv1.PersistenceSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+			Autoscaling: &marketingv1.AutoscalingSpec{
+				MinReplicas:                    2,
+				MaxReplicas:                    5,
+				TargetCPUUtilizationPercentage: ptr.To[int32](70),
+			},
+		},
+	},
+	{
+		name: "container-port",
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			ContainerPort: 8080,
+			EnableIngress: true,
+		},
+	},
+	{
+		name: "disruption-budget",
+		spec: marketingv1.GhostSpec{
+			ImageTag:            "latest",
+			Replicas:            3,
+			Persistence:         &marketingv1.PersistenceSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+			PodDisruptionBudget: &marketingv1.PodDisruptionBudgetSpec{MinAvailable: ptr.To(intstr.FromString("50%"))},
+		},
+	},
+	{
+		name: "access-logs",
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			AccessLogs:    &marketingv1.AccessLogsSpec{Enabled: false},
+		},
+	},
+	{
+		name: "scrape-annotations",
+		spec: marketingv1.GhostSpec{
+			ImageTag:   "latest",
+			Replicas:   1,
+			Monitoring: &marketingv1.MonitoringSpec{Annotations: true, Port: 9416},
+		},
+	},
+	{
+		name:       "service-monitor",
+		reconciler: &GhostReconciler{MonitoringAPIAvailable: true},
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			CommonLabels:  map[string]string{"app.kubernetes.io/part-of": "marketing"},
+			Tags:          map[string]string{"region": "eu"},
+			Monitoring: &marketingv1.MonitoringSpec{
+				Enabled:   true,
+				Interval:  "30s",
+				Dashboard: true,
+				Exporter:  &marketingv1.MetricsExporterSpec{Image: "registry.example.com/ghost-exporter:1.2", Port: 9101},
+			},
+		},
+	},
+	{
+		name: "cache-warmup",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			CacheWarmup: &marketingv1.CacheWarmupSpec{
+				Schedule: "0 * * * *",
+				URLs:     []string{"/", "/features/", "https://blog.example.com/"},
+			},
+		},
+	},
+	{
+		name: "content-export-claim",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Export: &marketingv1.ContentExportSpec{
+				Schedule: "0 4 * * *",
+				AdminAPIKeySecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "ghost-admin-api"},
+					Key:                  "key",
+				},
+				IncludeImages: true,
+				Retention:     14,
+				Destination:   marketingv1.ContentExportDestination{ClaimName: "ghost-exports"},
+			},
+		},
+	},
+	{
+		name: "content-export-object-store",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Export: &marketingv1.ContentExportSpec{
+				Schedule: "0 4 * * *",
+				AdminAPIKeySecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "ghost-admin-api"},
+					Key:                  "key",
+				},
+				Destination: marketingv1.ContentExportDestination{ObjectStore: &marketingv1.BackupDestination{
+					URL:                  "s3://ghost-exports/marketing",
+					Region:               "eu-west-1",
+					CredentialsSecretRef: corev1.LocalObjectReference{Name: "export-bucket"},
+				}},
+			},
+		},
+	},
+	{
+		name: "headless",
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			Headless:      &marketingv1.HeadlessSpec{},
+		},
+	},
+	{
+		name: "stopped",
+		spec: marketingv1.GhostSpec{
+			ImageTag:        "latest",
+			Replicas:        0,
+			EnableIngress:   true,
+			StoppedBehavior: marketingv1.StoppedBehaviorMaintenancePage,
+		},
+	},
+	{
+		name: "network-policy",
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			NetworkPolicy: &marketingv1.NetworkPolicySpec{
+				Enabled: true,
+				AllowedPeers: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: "monitoring"}},
+				}},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.20.0.0/16"}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Port: ptr.To(intstr.FromInt32(3306))}},
+				}},
+			},
+		},
+	},
+	{
+		name: "owner-group",
+		spec: marketingv1.GhostSpec{
+			ImageTag:   "latest",
+			Replicas:   1,
+			OwnerGroup: "team-marketing",
+		},
+	},
+	{
+		name: "security-context",
+		spec: marketingv1.GhostSpec{
+			ImageTag:           "latest",
+			Replicas:           1,
+			PodSecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: ptr.To(true), RunAsUser: ptr.To[int64](2000), FSGroup: ptr.To[int64](2000)},
+			SecurityContext:    &corev1.SecurityContext{ReadOnlyRootFilesystem: ptr.To(true), AllowPrivilegeEscalation: ptr.To(false)},
+			Sidecars:           []corev1.Container{{Name: "log-shipper", Image: "fluent/fluent-bit:3.0"}},
+		},
+	},
+	{
+		name: "security-profiles",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			SecurityProfiles: &marketingv1.SecurityProfilesSpec{
+				Seccomp:  &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: ptr.To("profiles/ghost.json")},
+				AppArmor: &corev1.AppArmorProfile{Type: corev1.AppArmorProfileTypeLocalhost, LocalhostProfile: ptr.To("ghost")},
+			},
+		},
+	},
+	{
+		name: "statefulset",
+		spec: marketingv1.GhostSpec{
+			ImageTag:    "latest",
+			Replicas:    1,
+			Persistence: &marketingv1.PersistenceSpec{Size: ptr.To(resource.MustParse("5Gi"))},
+			Workload:    &marketingv1.WorkloadSpec{Kind: marketingv1.WorkloadKindStatefulSet},
+		},
+	},
+	{
+		name: "s3-media-storage",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			MediaStorage: &marketingv1.MediaStorageSpec{S3: &marketingv1.S3MediaStorageSpec{
+				Bucket:               "blog-media",
+				Region:               "us-east-1",
+				Endpoint:             "https://minio.storage.svc:9000",
+				ForcePathStyle:       true,
+				AssetHost:            "https://media.example.com",
+				CredentialsSecretRef: &corev1.LocalObjectReference{Name: "blog-media"},
+			}},
+		},
+	},
+	{
+		name: "environment-url",
+		spec: marketingv1.GhostSpec{
+			ImageTag:    "latest",
+			Replicas:    1,
+			Environment: marketingv1.NodeEnvironmentProduction,
+			URL:         "https://www.example.com/blog/",
+			Timezone:    "Europe/Berlin",
+			Ingress:     &marketingv1.IngressSpec{Host: "blog.example.com"},
+		},
+	},
+	{
+		name: "service-extra-ports",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Service: &marketingv1.ServiceSpec{
+				Type: corev1.ServiceTypeClusterIP,
+				Port: 80,
+				ExtraPorts: []marketingv1.ServicePortSpec{
+					{Name: "metrics", Port: 9100, ContainerName: "exporter"},
+					{Name: "debug", Port: 9229, TargetPort: 9230},
+				},
+			},
+			Sidecars:      []corev1.Container{{Name: "exporter", Image: "prom/node-exporter"}},
+			NetworkPolicy: &marketingv1.NetworkPolicySpec{Enabled: true},
+		},
+	},
+	{
+		name: "proxy-trusted-ca",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Proxy:    &marketingv1.ProxySpec{HTTPSProxy: "http://proxy.corp:3128", NoProxy: ".corp"},
+			TrustedCABundle: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "corp-ca"},
+				Key:                  "ca.crt",
+			},
+			ContentInit: &marketingv1.ContentInitSpec{
+				Git: &marketingv1.GitContentSource{Repository: "https://github.com/example/blog-content.git"},
+			},
+			MediaStorage: &marketingv1.MediaStorageSpec{S3: &marketingv1.S3MediaStorageSpec{Bucket: "blog-media", Region: "eu-west-1"}},
+		},
+	},
+	{
+		name: "ingress-path",
+		spec: marketingv1.GhostSpec{
+			ImageTag:      "latest",
+			Replicas:      1,
+			EnableIngress: true,
+			Ingress: &marketingv1.IngressSpec{
+				Host: "www.example.com",
+				Path: "/blog",
+				TLS:  &marketingv1.IngressTLSSpec{Enabled: true},
+			},
+		},
+	},
+	{
+		name: "external-dns-load-balancer",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Service:  &marketingv1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Ingress: &marketingv1.IngressSpec{
+				Host:        "blog.example.com",
+				ExternalDNS: &marketingv1.ExternalDNSSpec{Enabled: true, TTL: ptr.To[int32](60)},
+			},
+		},
+	},
+	{
+		name: "log-shipper",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Logging: &marketingv1.LoggingSpec{
+				Level:  "info",
+				Format: marketingv1.LogFormatJSON,
+				Shipper: &marketingv1.LogShipperSpec{
+					Type:            marketingv1.LogShipperFluentBit,
+					ConfigSecretRef: corev1.LocalObjectReference{Name: "fluent-bit"},
+				},
+			},
+		},
+	},
+	{
+		name: "velero-hooks",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Backup: &marketingv1.BackupScheduleSpec{Velero: &marketingv1.VeleroBackupSpec{
+				Enabled:           true,
+				PostBackupCommand: []string{"/bin/sh", "-c", "echo done"},
+				HookTimeout:       &metav1.Duration{Duration: time.Minute},
+				FileSystemBackup:  true,
+			}},
+		},
+	},
+	{
+		name: "pod-template-overlay",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			PodTemplateOverlay: &runtime.RawExtension{Raw: []byte(`{"spec": {
+				"hostAliases": [{"ip": "10.0.0.1", "hostnames": ["db.internal"]}],
+				"dnsConfig": {"options": [{"name": "ndots", "value": "2"}]},
+				"containers": [{"name": "ghost", "env": [{"name": "NODE_OPTIONS", "value": "--max-old-space-size=512"}]}]
+			}}`)},
+		},
+	},
+	{
+		name: "site-config-mounted",
+		spec: marketingv1.GhostSpec{
+			ImageTag: "latest",
+			Replicas: 1,
+			Routes: &marketingv1.SiteConfigSource{ConfigMapRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "site-config"},
+				Key:                  "routes.yaml",
+			}},
+			Redirects: &marketingv1.SiteConfigSource{ConfigMapRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "site-config"},
+				Key:                  "redirects.json",
+			}},
+		},
+	},
+	{
+		name: "graceful-shutdown",
+		spec: marketingv1.GhostSpec{
+			ImageTag:                      "latest",
+			Replicas:                      2,
+			Persistence:                   &marketingv1.PersistenceSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+			TerminationGracePeriodSeconds: ptr.To[int64](60),
+			PreStopSleepSeconds:           ptr.To[int32](15),
+		},
+	},
+	{
+		name: "ephemeral",
+		spec: marketingv1.GhostSpec{
+			ImageTag:    "latest",
+			Replicas:    1,
+			Persistence: &marketingv1.PersistenceSpec{Enabled: ptr.To(false), Size: ptr.To(resource.MustParse("2Gi"))},
+		},
+	},
+	{
+		name: "pod-classes",
+		spec: marketingv1.GhostSpec{
+			ImageTag:          "latest",
+			Replicas:          1,
+			PriorityClassName: "marketing-critical",
+			RuntimeClassName:  "gvisor",
+		},
+	},
+	{
+		name: "mysql-database",
+		spec: marketingv1.GhostSpec{
+			ImageTag:    "latest",
+			Replicas:    3,
+			Persistence: &marketingv1.PersistenceSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+			Database: &marketingv1.DatabaseSpec{
+				Client: marketingv1.DatabaseClientMySQL,
+				MySQL: &marketingv1.MySQLSpec{
+					Host:     "mysql.databases.svc",
+					Port:     3306,
+					Database: "ghost",
+					User:     "ghost",
+					PasswordSecretRef: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "ghost-mysql"},
+						Key:                  "password",
+					},
+				},
+			},
+		},
+	},
+}
+
+// TestGoldenManifests renders the children of every golden case without an API server, so
+// the snapshots are checked by a plain go test without the envtest binaries
+func TestGoldenManifests(t *testing.T) {
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			r := tc.reconciler
+			if r == nil {
+				r = &GhostReconciler{}
+			}
+			ghost := &marketingv1.Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: "marketing"},
+				Spec:       tc.spec,
+			}
+			// Observed ahead of the children by the reconcile
+			ghost.Status.URL = publicURL(ghost, r.WildcardCertificate)
+
+			rendered, err := r.renderManifests(ghost)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			path := filepath.Join("testdata", "golden", tc.name+".yaml")
+			if *updateGolden {
+				g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+				g.Expect(os.WriteFile(path, rendered, 0o644)).To(Succeed())
+			}
+			golden, err := os.ReadFile(path)
+			g.Expect(err).NotTo(HaveOccurred(), "missing snapshot, run with -update-golden")
+			g.Expect(string(rendered)).To(Equal(string(golden)), "rendered manifests drifted from %s, run with -update-golden if intended", path)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+const (
+	// buildHookRegisteredCondition reports whether content changes reach the build hook of spec.headless
+	buildHookRegisteredCondition = "BuildHookRegistered"
+	// buildHookEvent is the Ghost webhook event fired on any change of the published content
+	buildHookEvent = "site.changed"
+)
+
+// headlessPaths are routed for a headless Ghost, its admin and APIs and its uploaded content
+var headlessPaths = []string{"/ghost/", "/content/"}
+
+// headless reports whether the public site of the Ghost is left to a separate front-end
+func headless(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.Headless != nil
+}
+
+// restrictIngressPaths replaces the paths of every Ingress rule with the headless ones, keeping
+// the backend of the rendered template
+func restrictIngressPaths(ingress *netv1.Ingress) {
+	for i := range ingress.Spec.Rules {
+		rule := &ingress.Spec.Rules[i]
+		if rule.HTTP == nil || len(rule.HTTP.Paths) == 0 {
+			continue
+		}
+		backend := rule.HTTP.Paths[0].Backend
+		paths := make([]netv1.HTTPIngressPath, 0, len(headlessPaths))
+		for _, path := range headlessPaths {
+			paths = append(paths, netv1.HTTPIngressPath{
+				Path:     path,
+				PathType: rule.HTTP.Paths[0].PathType,
+				Backend:  backend,
+			})
+		}
+		rule.HTTP.Paths = paths
+	}
+}
+
+// registerBuildHook registers the build hook of spec.headless as a Ghost webhook once Ghost
+// answers and reports it in the BuildHookRegistered condition. Removing the build hook leaves
+// the webhook in Ghost, it goes with the custom integration.
+func (r *GhostReconciler) registerBuildHook(ctx context.Context, ghost *marketingv1.Ghost) {
+	if !headless(ghost) || ghost.Spec.Headless.BuildHook == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, buildHookRegisteredCondition)
+		ghost.Status.Headless = nil
+		return
+	}
+	if r.readOnly(ghost) {
+		return
+	}
+	// The Admin API fails like every other endpoint until Ghost answers, the next pass tries again
+	if ghost.Status.ReadyReplicas == 0 || applicationUnhealthy(ghost) != "" {
+		setCondition(ghost, buildHookRegisteredCondition, metav1.ConditionUnknown, "WaitingForGhost",
+			"The build hook is registered once Ghost answers")
+		return
+	}
+	if err := r.ensureBuildHook(ctx, ghost); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to register the build hook")
+		setCondition(ghost, buildHookRegisteredCondition, metav1.ConditionFalse, "RegistrationFailed", err.Error())
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "BuildHookFailed", err.Error())
+		return
+	}
+	setCondition(ghost, buildHookRegisteredCondition, metav1.ConditionTrue, "Registered",
+		"Content changes are posted to the build hook")
+}
+
+// ensureBuildHook creates the webhook posting to the build hook, or points the registered one
+// at a changed URL. A webhook deleted in Ghost is only created again when the URL changes.
+func (r *GhostReconciler) ensureBuildHook(ctx context.Context, ghost *marketingv1.Ghost) error {
+	spec := ghost.Spec.Headless
+	target, err := r.secretKey(ctx, ghost, *spec.BuildHook)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(target))
+	digest := hex.EncodeToString(sum[:8])
+	status := ghost.Status.Headless
+	if status != nil && status.TargetDigest == digest {
+		return nil
+	}
+	if spec.AdminAPIKeySecretRef == nil {
+		return fmt.Errorf("spec.headless.adminAPIKeySecretRef is required to register the build hook")
+	}
+	key, err := r.secretKey(ctx, ghost, *spec.AdminAPIKeySecretRef)
+	if err != nil {
+		return err
+	}
+	adminURL := ghostAdminURL
+	if r.AdminURL != nil {
+		adminURL = r.AdminURL
+	}
+	api, err := ghostapi.New(adminURL(ghost), key)
+	if err != nil {
+		return err
+	}
+
+	webhook := ghostapi.Webhook{Event: buildHookEvent, TargetURL: target, Name: "Front-end build"}
+	var registered *ghostapi.Webhook
+	var apiErr *ghostapi.Error
+	if status != nil {
+		webhook.ID = status.WebhookID
+		registered, err = api.UpdateWebhook(ctx, webhook)
+	}
+	if status == nil || errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		registered, err = api.CreateWebhook(ctx, webhook)
+	}
+	if err != nil {
+		return fmt.Errorf("registering the build hook: %w", err)
+	}
+	ghost.Status.Headless = &marketingv1.HeadlessStatus{WebhookID: registered.ID, TargetDigest: digest}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "BuildHookRegistered", "Content changes are posted to the build hook by webhook "+registered.ID)
+	return nil
+}
+
+// secretKey reads one key of a Secret in the namespace of the Ghost
+func (r *GhostReconciler) secretKey(ctx context.Context, ghost *marketingv1.Ghost, ref corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.secretReader().Get(ctx, client.ObjectKey{Namespace: ghost.ObjectMeta.Namespace, Name: ref.Name}, secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// hostConflictCondition is only present while another instance holds one of the Ghost's hosts
+const hostConflictCondition = "HostConflict"
+
+// Field indexes listing Ghosts and Ingresses by the hosts they route, across all namespaces
+const (
+	ghostHostIndex   = "ghost.spec.hosts"
+	ingressHostIndex = "ingress.spec.rules.host"
+)
+
+// indexHosts registers the host indexes the conflict check lists through
+func indexHosts(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &marketingv1.Ghost{}, ghostHostIndex, ghostHosts); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &netv1.Ingress{}, ingressHostIndex, ingressRuleHosts)
+}
+
+// ghostHosts returns the hosts a Ghost routes or redirects, none unless it is exposed. They are
+// the hosts the admission webhook checks for uniqueness too.
+func ghostHosts(obj client.Object) []string {
+	return obj.(*marketingv1.Ghost).Hosts()
+}
+
+// ingressRuleHosts returns the hosts an Ingress routes
+func ingressRuleHosts(obj client.Object) []string {
+	var hosts []string
+	for _, rule := range obj.(*netv1.Ingress).Spec.Rules {
+		if rule.Host != "" {
+			hosts = append(hosts, rule.Host)
+		}
+	}
+	return hosts
+}
+
+// hostConflict describes the first host of the Ghost that an older Ghost or a foreign Ingress
+// already routes, empty when the Ghost may route all of them. The oldest claim wins, so a
+// serving Ghost is never taken over by one created later in another team's namespace.
+func (r *GhostReconciler) hostConflict(ctx context.Context, ghost *marketingv1.Ghost) (string, error) {
+	for _, host := range ghostHosts(ghost) {
+		ghosts := &marketingv1.GhostList{}
+		if err := r.List(ctx, ghosts, client.MatchingFields{ghostHostIndex: host}); err != nil {
+			return "", err
+		}
+		for i := range ghosts.Items {
+			other := &ghosts.Items[i]
+			// The Ghost a renamed one replaces hands its hosts over
+			if other.UID == ghost.UID || other.Annotations[renamedToAnnotation] == ghost.Name {
+				continue
+			}
+			if other.DeletionTimestamp.IsZero() && claimedFirst(other, ghost) {
+				return fmt.Sprintf("Host %s is already claimed by Ghost %s/%s", host, other.Namespace, other.Name), nil
+			}
+		}
+
+		ingresses := &netv1.IngressList{}
+		if err := r.List(ctx, ingresses, client.MatchingFields{ingressHostIndex: host}); err != nil {
+			return "", err
+		}
+		for i := range ingresses.Items {
+			ingress := &ingresses.Items[i]
+			// Ingresses of Ghosts are settled through their Ghost above
+			if owner := metav1.GetControllerOf(ingress); owner != nil && owner.Kind == "Ghost" {
+				continue
+			}
+			if claimedFirst(ingress, ghost) {
+				return fmt.Sprintf("Host %s is already served by Ingress %s/%s", host, ingress.Namespace, ingress.Name), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// claimedFirst reports whether a was created before b, ties go to the lower namespace and name
+func claimedFirst(a, b client.Object) bool {
+	aCreated, bCreated := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !aCreated.Equal(&bCreated) {
+		return aCreated.Before(&bCreated)
+	}
+	return client.ObjectKeyFromObject(a).String() < client.ObjectKeyFromObject(b).String()
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const hpaNamePrefix = "ghost-hpa-"
+
+// autoscaledAnnotation marks a Deployment whose replica count belongs to the HorizontalPodAutoscaler
+const autoscaledAnnotation = "marketing.kb.dev/autoscaled"
+
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+
+// autoscaled reports whether a HorizontalPodAutoscaler owns the replica count, a stopped
+// Ghost stays at zero
+func autoscaled(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.Autoscaling != nil && !stopped(ghost)
+}
+
+// hpaChild manages the HorizontalPodAutoscaler scaling the Ghost Deployment
+type hpaChild struct{}
+
+func (hpaChild) Kind() string {
+	return "HorizontalPodAutoscaler"
+}
+
+func (hpaChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !autoscaled(ghost) {
+		return nil, nil
+	}
+	return generateDesiredHPA(ghost), nil
+}
+
+func (hpaChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, hpaNamePrefix), &autoscalingv2.HorizontalPodAutoscaler{})
+}
+
+func (hpaChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+func generateDesiredHPA(ghost *marketingv1.Ghost) *autoscalingv2.HorizontalPodAutoscaler {
+	spec := ghost.Spec.Autoscaling
+	minReplicas := min(max(spec.MinReplicas, 1), maxReplicas(ghost))
+	var metrics []autoscalingv2.MetricSpec
+	for _, target := range []struct {
+		name        corev1.ResourceName
+		utilization *int32
+	}{
+		{corev1.ResourceCPU, spec.TargetCPUUtilizationPercentage},
+		{corev1.ResourceMemory, spec.TargetMemoryUtilizationPercentage},
+	} {
+		if target.utilization == nil {
+			continue
+		}
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name: target.name,
+				Target: autoscalingv2.MetricTarget{
+					Type:               autoscalingv2.UtilizationMetricType,
+					AverageUtilization: target.utilization,
+				},
+			},
+		})
+	}
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, hpaNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       deploymentName(ghost),
+			},
+			MinReplicas: ptr.To(minReplicas),
+			MaxReplicas: maxReplicas(ghost),
+			Metrics:     metrics,
+		},
+	}
+}
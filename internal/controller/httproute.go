@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const httpRouteNamePrefix = "ghost-httproute-"
+
+// Gateway API is an optional dependency, so HTTPRoutes are handled as unstructured objects
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+
+func routingMode(ghost *marketingv1.Ghost) marketingv1.RoutingMode {
+	if ghost.Spec.Routing == nil || ghost.Spec.Routing.Mode == "" {
+		return marketingv1.RoutingModeIngress
+	}
+	return ghost.Spec.Routing.Mode
+}
+
+// httpRouteEnabled reports whether the Ghost is exposed through a Gateway API HTTPRoute
+func httpRouteEnabled(ghost *marketingv1.Ghost) bool {
+	return servesHosts(ghost) && routingMode(ghost) == marketingv1.RoutingModeGatewayAPI
+}
+
+// httpRouteChild manages the Gateway API HTTPRoute exposing the Ghost Service
+type httpRouteChild struct{}
+
+func (httpRouteChild) Kind() string {
+	return "HTTPRoute"
+}
+
+func (httpRouteChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !httpRouteEnabled(ghost) {
+		return nil, nil
+	}
+	if ghost.Spec.Routing.Gateway == nil {
+		return nil, invalidSpecError(errors.New("spec.routing.gateway is required in GatewayAPI mode"))
+	}
+	return generateDesiredHTTPRoute(ghost), nil
+}
+
+func (httpRouteChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, httpRouteNamePrefix), newUnstructured(httpRouteGVK))
+}
+
+// Status propagates the Accepted and ResolvedRefs conditions reported by the Gateway
+func (httpRouteChild) Status(observed client.Object) *metav1.Condition {
+	httpRoute := observed.(*unstructured.Unstructured)
+	parents, _, _ := unstructured.NestedSlice(httpRoute.Object, "status", "parents")
+	if len(parents) == 0 {
+		return &metav1.Condition{Type: "RouteReady", Status: metav1.ConditionFalse, Reason: "Pending", Message: "Waiting for the Gateway to accept the HTTPRoute"}
+	}
+	for _, p := range parents {
+		parent, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(parent, "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || (condition["type"] != "Accepted" && condition["type"] != "ResolvedRefs") {
+				continue
+			}
+			if condition["status"] != string(metav1.ConditionTrue) {
+				reason, _ := condition["reason"].(string)
+				message, _ := condition["message"].(string)
+				return &metav1.Condition{Type: "RouteReady", Status: metav1.ConditionFalse, Reason: reason, Message: message}
+			}
+		}
+	}
+	return &metav1.Condition{Type: "RouteReady", Status: metav1.ConditionTrue, Reason: "Accepted", Message: "HTTPRoute accepted by the Gateway"}
+}
+
+func generateDesiredHTTPRoute(ghost *marketingv1.Ghost) *unstructured.Unstructured {
+	parentRefs := []interface{}{}
+	if gateway := ghost.Spec.Routing.Gateway; gateway != nil {
+		parentRef := map[string]interface{}{
+			"name": gateway.Name,
+		}
+		if gateway.Namespace != "" {
+			parentRef["namespace"] = gateway.Namespace
+		}
+		if gateway.SectionName != "" {
+			parentRef["sectionName"] = gateway.SectionName
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+	hostnames := []interface{}{}
+	for _, host := range ingressHosts(ghost) {
+		hostnames = append(hostnames, host)
+	}
+
+	rule := map[string]interface{}{
+		"backendRefs": []interface{}{
+			map[string]interface{}{
+				"name": childName(ghost, svcNamePrefix),
+				"port": int64(servicePort(ghost)),
+			},
+		},
+	}
+	if headless(ghost) {
+		matches := []interface{}{}
+		for _, path := range headlessPaths {
+			matches = append(matches, map[string]interface{}{
+				"path": map[string]interface{}{"type": "PathPrefix", "value": subPath(ghost, path)},
+			})
+		}
+		rule["matches"] = matches
+	} else if prefix := ghostPath(ghost); prefix != "" {
+		rule["matches"] = []interface{}{map[string]interface{}{
+			"path": map[string]interface{}{"type": "PathPrefix", "value": prefix},
+		}}
+	}
+
+	httpRoute := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"parentRefs": parentRefs,
+				"hostnames":  hostnames,
+				"rules":      []interface{}{rule},
+			},
+		},
+	}
+	httpRoute.SetGroupVersionKind(httpRouteGVK)
+	httpRoute.SetName(childName(ghost, httpRouteNamePrefix))
+	httpRoute.SetNamespace(ghost.ObjectMeta.Namespace)
+	return httpRoute
+}
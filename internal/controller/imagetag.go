@@ -0,0 +1,269 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// TagLister lists the tags of an image repository
+type TagLister interface {
+	Tags(ctx context.Context, repository string) ([]string, error)
+}
+
+// RegistryTags lists tags anonymously through the registry API of the OCI distribution spec. A
+// list is kept for Interval, so the Ghosts tracking the same repository share a lookup.
+type RegistryTags struct {
+	// Interval between two lookups of the same repository
+	Interval time.Duration
+	// HTTPClient performs the lookups, http.DefaultClient when unset
+	HTTPClient *http.Client
+	// Now returns the current time, time.Now when unset
+	Now func() time.Time
+
+	mu        sync.Mutex
+	lists     map[string]tagList
+	platforms map[string]platformList
+}
+
+type tagList struct {
+	tags      []string
+	fetchedAt time.Time
+}
+
+var _ TagLister = &RegistryTags{}
+
+// challengeParam matches the parameters of a WWW-Authenticate Bearer challenge
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Tags returns the tags of the repository, looked up again once the last list is older than Interval
+func (t *RegistryTags) Tags(ctx context.Context, repository string) ([]string, error) {
+	now := time.Now
+	if t.Now != nil {
+		now = t.Now
+	}
+	t.mu.Lock()
+	list, ok := t.lists[repository]
+	t.mu.Unlock()
+	if ok && now().Sub(list.fetchedAt) < t.Interval {
+		return list.tags, nil
+	}
+	tags, err := t.fetch(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if t.lists == nil {
+		t.lists = map[string]tagList{}
+	}
+	t.lists[repository] = tagList{tags: tags, fetchedAt: now()}
+	t.mu.Unlock()
+	return tags, nil
+}
+
+func (t *RegistryTags) fetch(ctx context.Context, repository string) ([]string, error) {
+	host, path := registryRepository(repository)
+	next, err := url.Parse("https://" + host + "/v2/" + path + "/tags/list?n=1000")
+	if err != nil {
+		return nil, err
+	}
+	var token string
+	var tags []string
+	for next != nil {
+		response, err := t.get(ctx, next.String(), token, "application/json")
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode == http.StatusUnauthorized && token == "" {
+			challenge := response.Header.Get("WWW-Authenticate")
+			response.Body.Close()
+			if token, err = t.token(ctx, challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = decodeRegistryResponse(response, &page)
+		if err != nil {
+			return nil, fmt.Errorf("listing the tags of %s: %w", repository, err)
+		}
+		tags = append(tags, page.Tags...)
+		next = nextPage(next, response.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+// token asks the authorization server of a Bearer challenge for an anonymous pull token
+func (t *RegistryTags) token(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry asks for %q authentication, only anonymous Bearer tokens are supported", scheme)
+	}
+	values := url.Values{}
+	var realm string
+	for _, match := range challengeParam.FindAllStringSubmatch(params, -1) {
+		if match[1] == "realm" {
+			realm = match[2]
+		} else {
+			values.Set(match[1], match[2])
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("registry challenge %q has no realm", challenge)
+	}
+	response, err := t.get(ctx, realm+"?"+values.Encode(), "", "application/json")
+	if err != nil {
+		return "", err
+	}
+	var grant struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := decodeRegistryResponse(response, &grant); err != nil {
+		return "", fmt.Errorf("requesting a registry token: %w", err)
+	}
+	if grant.Token != "" {
+		return grant.Token, nil
+	}
+	return grant.AccessToken, nil
+}
+
+func (t *RegistryTags) get(ctx context.Context, target, token, accept string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", accept)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	httpClient := t.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(request)
+}
+
+func decodeRegistryResponse(response *http.Response, into interface{}) error {
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry answered %s", response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(into)
+}
+
+// nextPage follows the Link header a registry paginates the tags with, nil on the last page
+func nextPage(current *url.URL, link string) *url.URL {
+	target, rel, found := strings.Cut(link, ";")
+	if !found || !strings.Contains(rel, `rel="next"`) {
+		return nil
+	}
+	next, err := current.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+	if err != nil {
+		return nil
+	}
+	return next
+}
+
+// registryRepository splits a repository the way docker resolves it into the registry host and
+// the repository path, images without a registry come from Docker Hub
+func registryRepository(repository string) (string, string) {
+	host, path, found := strings.Cut(repository, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		host, path = "docker.io", repository
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = "registry-1.docker.io"
+		if !strings.Contains(path, "/") {
+			path = "library/" + path
+		}
+	}
+	return host, path
+}
+
+// trackingTag reports whether spec.imageTagPolicy moves the Ghost to newer releases
+func trackingTag(ghost *marketingv1.Ghost) bool {
+	policy := ghost.Spec.ImageTagPolicy
+	return policy == marketingv1.ImageTagPolicyTrackPatch || policy == marketingv1.ImageTagPolicyTrackMinor
+}
+
+// trackedTag returns the newest release among the candidates the policy moves the tag to, the
+// tag itself when none is newer. Only full releases of the same variant, such as 5.96.1-alpine
+// for 5.96.0-alpine, are candidates, floating tags such as 5.96-alpine are not.
+func trackedTag(policy marketingv1.ImageTagPolicy, tag string, candidates []string) string {
+	number, variant, _ := strings.Cut(tag, "-")
+	base, err := utilversion.ParseGeneric(number)
+	if err != nil {
+		return tag
+	}
+	best, bestVersion := tag, base
+	for _, candidate := range candidates {
+		number, candidateVariant, _ := strings.Cut(candidate, "-")
+		if candidateVariant != variant {
+			continue
+		}
+		version, err := utilversion.ParseSemantic(number)
+		if err != nil || version.Major() != base.Major() {
+			continue
+		}
+		if policy == marketingv1.ImageTagPolicyTrackPatch && version.Minor() != base.Minor() {
+			continue
+		}
+		if bestVersion.LessThan(version) {
+			best, bestVersion = candidate, version
+		}
+	}
+	return best
+}
+
+// resolveImageTag records the newest release matching the tag of a Ghost tracking releases in
+// status.resolvedImage. A failed lookup keeps the last resolved image while it still matches.
+func (r *GhostReconciler) resolveImageTag(ctx context.Context, ghost *marketingv1.Ghost) {
+	image := specImage(ghost)
+	tag := imageTag(image)
+	if !trackingTag(ghost) || r.Tags == nil || tag == "" {
+		ghost.Status.ResolvedImage = ""
+		return
+	}
+	repository := strings.TrimSuffix(image, ":"+tag)
+	tags, err := r.Tags.Tags(ctx, repository)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to look up the image tags", "repository", repository)
+		resolved := ghost.Status.ResolvedImage
+		if resolved == "" || strings.TrimSuffix(resolved, ":"+imageTag(resolved)) != repository {
+			ghost.Status.ResolvedImage = ""
+			return
+		}
+		tags = []string{imageTag(resolved)}
+	}
+	ghost.Status.ResolvedImage = repository + ":" + trackedTag(ghost.Spec.ImageTagPolicy, tag, tags)
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// staticTags lists the same tags for every repository, or fails with Err
+type staticTags struct {
+	List []string
+	Err  error
+}
+
+func (s *staticTags) Tags(context.Context, string) ([]string, error) {
+	return s.List, s.Err
+}
+
+// staticPlatforms lists the same architectures for every image
+type staticPlatforms []string
+
+func (s staticPlatforms) Architectures(context.Context, string) ([]string, error) {
+	return s, nil
+}
+
+var _ = Describe("Image tag tracking", func() {
+	It("should list the tags of a registry asking for a token, page by page", func() {
+		lookups := 0
+		var registry *httptest.Server
+		registry = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/token":
+				Expect(r.URL.Query().Get("scope")).To(Equal("repository:library/ghost:pull"))
+				fmt.Fprint(w, `{"token": "anonymous"}`)
+			case r.Header.Get("Authorization") != "Bearer anonymous":
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="registry",scope="repository:library/ghost:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			case r.URL.Query().Get("last") == "":
+				lookups++
+				w.Header().Set("Link", `</v2/library/ghost/tags/list?last=5.96.0&n=1000>; rel="next"`)
+				fmt.Fprint(w, `{"name": "library/ghost", "tags": ["5.96.0"]}`)
+			default:
+				fmt.Fprint(w, `{"name": "library/ghost", "tags": ["5.96.1", "latest"]}`)
+			}
+		}))
+		defer registry.Close()
+		repository := strings.TrimPrefix(registry.URL, "https://") + "/library/ghost"
+		now := time.Now()
+		tags := &RegistryTags{Interval: time.Hour, HTTPClient: registry.Client(), Now: func() time.Time { return now }}
+
+		Expect(tags.Tags(ctx, repository)).To(Equal([]string{"5.96.0", "5.96.1", "latest"}))
+		Expect(tags.Tags(ctx, repository)).To(HaveLen(3))
+		Expect(lookups).To(Equal(1))
+		now = now.Add(time.Hour)
+		Expect(tags.Tags(ctx, repository)).To(HaveLen(3))
+		Expect(lookups).To(Equal(2))
+	})
+
+	It("should read the architectures of an image from its index", func() {
+		registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/v2/library/ghost/manifests/5.96.0"))
+			Expect(r.Header.Get("Accept")).To(ContainSubstring("application/vnd.oci.image.index.v1+json"))
+			fmt.Fprint(w, `{"mediaType": "application/vnd.oci.image.index.v1+json", "manifests": [
+				{"platform": {"architecture": "amd64", "os": "linux"}},
+				{"platform": {"architecture": "arm64", "os": "linux"}},
+				{"platform": {"architecture": "unknown", "os": "unknown"}}
+			]}`)
+		}))
+		defer registry.Close()
+		image := strings.TrimPrefix(registry.URL, "https://") + "/library/ghost:5.96.0"
+		platforms := &RegistryTags{Interval: time.Hour, HTTPClient: registry.Client()}
+		Expect(platforms.Architectures(ctx, image)).To(Equal([]string{"amd64", "arm64"}))
+	})
+
+	It("should schedule the pods onto the architectures the image runs on", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "architectures"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		ghost := &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace.Name},
+			Spec:       marketingv1.GhostSpec{Image: &marketingv1.ImageSpec{Tag: "5.96.0"}, Replicas: 1},
+		}
+		Expect(k8sClient.Create(ctx, ghost)).To(Succeed())
+		reconciler := &GhostReconciler{
+			Client:    k8sClient,
+			Scheme:    k8sClient.Scheme(),
+			Recoder:   record.NewFakeRecorder(100),
+			Platforms: staticPlatforms{"amd64", "arm64"},
+		}
+		key := types.NamespacedName{Name: ghost.Name, Namespace: namespace.Name}
+		deploymentKey := types.NamespacedName{Name: deploymentNamePrefix + ghost.Name, Namespace: namespace.Name}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+		required := deployment.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		Expect(required.NodeSelectorTerms).To(ConsistOf(HaveField("MatchExpressions", ConsistOf(corev1.NodeSelectorRequirement{
+			Key:      corev1.LabelArchStable,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{"amd64", "arm64"},
+		}))))
+
+		By("running the tag of the architecture a registry publishes single architecture images for")
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		ghost.Spec.Image.Architectures = []marketingv1.Architecture{marketingv1.ArchitectureARM64}
+		ghost.Spec.Image.ArchitectureTags = true
+		Expect(k8sClient.Update(ctx, ghost)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("ghost:5.96.0-arm64"))
+		required = deployment.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		Expect(required.NodeSelectorTerms[0].MatchExpressions[0].Values).To(Equal([]string{"arm64"}))
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Status.ImageArchitectures).To(BeEmpty())
+	})
+
+	It("should resolve images without a registry to Docker Hub", func() {
+		for repository, expected := range map[string][]string{
+			"ghost":                  {"registry-1.docker.io", "library/ghost"},
+			"bitnami/ghost":          {"registry-1.docker.io", "bitnami/ghost"},
+			"docker.io/ghost":        {"registry-1.docker.io", "library/ghost"},
+			"ghcr.io/acme/ghost":     {"ghcr.io", "acme/ghost"},
+			"localhost:5000/ghost":   {"localhost:5000", "ghost"},
+			"registry.local/a/ghost": {"registry.local", "a/ghost"},
+		} {
+			host, path := registryRepository(repository)
+			Expect([]string{host, path}).To(Equal(expected), repository)
+		}
+	})
+
+	It("should only move to newer releases of the same variant the policy tracks", func() {
+		candidates := []string{"5.95.9-alpine", "5.96.0-alpine", "5.96.3-alpine", "5.96.4", "5.97-alpine", "5.98.1-alpine", "6.0.0-alpine", "latest"}
+		Expect(trackedTag(marketingv1.ImageTagPolicyTrackPatch, "5.96.0-alpine", candidates)).To(Equal("5.96.3-alpine"))
+		Expect(trackedTag(marketingv1.ImageTagPolicyTrackMinor, "5.96.0-alpine", candidates)).To(Equal("5.98.1-alpine"))
+		Expect(trackedTag(marketingv1.ImageTagPolicyTrackPatch, "5.96.0", candidates)).To(Equal("5.96.4"))
+		Expect(trackedTag(marketingv1.ImageTagPolicyTrackPatch, "5.99.0-alpine", candidates)).To(Equal("5.99.0-alpine"))
+	})
+
+	It("should roll the Deployment out to the newest release and keep it while the registry fails", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "image-tag-tracking"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		ghost := &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace.Name},
+			Spec: marketingv1.GhostSpec{
+				Image:          &marketingv1.ImageSpec{Tag: "5.96.0-alpine"},
+				ImageTagPolicy: marketingv1.ImageTagPolicyTrackPatch,
+				Replicas:       1,
+			},
+		}
+		Expect(k8sClient.Create(ctx, ghost)).To(Succeed())
+		tags := &staticTags{List: []string{"5.96.0-alpine", "5.96.2-alpine", "5.97.0-alpine"}}
+		reconciler := &GhostReconciler{
+			Client:  k8sClient,
+			Scheme:  k8sClient.Scheme(),
+			Recoder: record.NewFakeRecorder(100),
+			Tags:    tags,
+		}
+		key := types.NamespacedName{Name: ghost.Name, Namespace: namespace.Name}
+		deploymentKey := types.NamespacedName{Name: deploymentNamePrefix + ghost.Name, Namespace: namespace.Name}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("ghost:5.96.2-alpine"))
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Status.ResolvedImage).To(Equal("ghost:5.96.2-alpine"))
+
+		By("keeping the resolved release while the registry cannot be reached")
+		tags.Err = errors.New("registry unavailable")
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("ghost:5.96.2-alpine"))
+
+		By("running the tag as it is once the Ghost is pinned again")
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		ghost.Spec.ImageTagPolicy = marketingv1.ImageTagPolicyPinned
+		Expect(k8sClient.Update(ctx, ghost)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("ghost:5.96.0-alpine"))
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Status.ResolvedImage).To(BeEmpty())
+	})
+})
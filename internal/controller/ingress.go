@@ -0,0 +1,181 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const ingressNamePrefix = "ghost-ingress-"
+const defaultIngressClassName = "nginx"
+
+// Non-indexable sites behind ingress-nginx get an X-Robots-Tag header asking crawlers to stay away
+const noIndexAnnotation = "nginx.ingress.kubernetes.io/configuration-snippet"
+const noIndexSnippet = `more_set_headers "X-Robots-Tag: noindex, nofollow";`
+
+// accessLogAnnotation is owned by the operator while spec.accessLogs is set and removed once it is unset
+const accessLogAnnotation = "nginx.ingress.kubernetes.io/enable-access-log"
+
+// ingressChild manages the Ingress exposing the Ghost Service
+type ingressChild struct {
+	wildcard *WildcardCertificate
+}
+
+func (ingressChild) Kind() string {
+	return "Ingress"
+}
+
+func (i ingressChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	// Ignore ingress creation if disabled
+	if !ingressEnabled(ghost) {
+		return nil, nil
+	}
+	return generateDesiredIngress(ghost, i.wildcard)
+}
+
+func (ingressChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, ingressNamePrefix), &netv1.Ingress{})
+}
+
+func (ingressChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+func generateDesiredIngress(ghost *marketingv1.Ghost, wildcard *WildcardCertificate) (*netv1.Ingress, error) {
+	ingressClassName := defaultIngressClassName
+	var annotations map[string]string
+	if ghost.Spec.Ingress != nil {
+		if ghost.Spec.Ingress.ClassName != "" {
+			ingressClassName = ghost.Spec.Ingress.ClassName
+		}
+		annotations = ghost.Spec.Ingress.Annotations
+	}
+
+	ingress := &netv1.Ingress{}
+	err := renderTemplate(ingressTemplate, templateParams{
+		Name:             childName(ghost, ingressNamePrefix),
+		Namespace:        ghost.ObjectMeta.Namespace,
+		ServiceName:      childName(ghost, svcNamePrefix),
+		ServicePort:      servicePort(ghost),
+		IngressClassName: ingressClassName,
+		Hosts:            ingressHosts(ghost),
+	}, ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	if headless(ghost) {
+		restrictIngressPaths(ingress)
+	}
+	if prefix := ghostPath(ghost); prefix != "" {
+		prefixIngressPaths(ingress, prefix)
+	}
+	ingress.Annotations = annotations
+	if ingressClassName == defaultIngressClassName {
+		// Keep the user's map intact, it is shared with the Ghost spec
+		managed := map[string]string{}
+		if !indexable(ghost) {
+			managed[noIndexAnnotation] = noIndexSnippet
+		}
+		if ghost.Spec.AccessLogs != nil {
+			managed[accessLogAnnotation] = strconv.FormatBool(ghost.Spec.AccessLogs.Enabled)
+		}
+		if len(managed) > 0 {
+			ingress.Annotations = withCommon(annotations, managed)
+		}
+	}
+	if dns := externalDNSAnnotations(ghost, ingressHosts(ghost)); dns != nil {
+		ingress.Annotations = withCommon(ingress.Annotations, dns)
+	}
+	if tlsEnabled(ghost) {
+		ingress.Spec.TLS = []netv1.IngressTLS{
+			{
+				Hosts:      ingressHosts(ghost),
+				SecretName: tlsSecretName(ghost),
+			},
+		}
+	} else if hosts := wildcardHosts(ghost, wildcard); len(hosts) > 0 {
+		// Without TLS of its own the Ghost is served with the operator's wildcard certificate
+		ingress.Spec.TLS = []netv1.IngressTLS{
+			{
+				Hosts:      hosts,
+				SecretName: wildcardSecretName(ghost, wildcard),
+			},
+		}
+	}
+	return ingress, nil
+}
+
+// ingressEnabled reports whether the Ghost is exposed through an Ingress
+func ingressEnabled(ghost *marketingv1.Ghost) bool {
+	return servesHosts(ghost) && routingMode(ghost) == marketingv1.RoutingModeIngress
+}
+
+// ghostPath is the sub-path Ghost is served under, the path of spec.ingress.path or spec.url,
+// and empty at the root of its hosts
+func ghostPath(ghost *marketingv1.Ghost) string {
+	if ghost.Spec.Ingress != nil && ghost.Spec.Ingress.Path != "" {
+		return ghost.Spec.Ingress.Path
+	}
+	if ghost.Spec.URL != "" {
+		if parsed, err := url.Parse(ghost.Spec.URL); err == nil {
+			return strings.TrimSuffix(parsed.Path, "/")
+		}
+	}
+	return ""
+}
+
+// subPath prefixes a path of the site with the sub-path Ghost is served under
+func subPath(ghost *marketingv1.Ghost, path string) string {
+	prefix := ghostPath(ghost)
+	if prefix != "" && path == "/" {
+		return prefix
+	}
+	return prefix + path
+}
+
+// prefixIngressPaths moves the paths of every Ingress rule under the sub-path. Ghost serves the
+// sub-path itself, so the requests keep it and nothing is rewritten.
+func prefixIngressPaths(ingress *netv1.Ingress, prefix string) {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			path := &rule.HTTP.Paths[i]
+			if path.Path == "/" {
+				path.Path = prefix
+			} else {
+				path.Path = prefix + path.Path
+			}
+		}
+	}
+}
+
+// ingressHosts returns the primary host followed by any extra hosts
+func ingressHosts(ghost *marketingv1.Ghost) []string {
+	return ghost.ServedHosts()
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// childEntry returns the inventory entry of the child kind, added when missing
+func childEntry(ghost *marketingv1.Ghost, kind string) *marketingv1.ChildStatus {
+	for i := range ghost.Status.Children {
+		if ghost.Status.Children[i].Kind == kind {
+			return &ghost.Status.Children[i]
+		}
+	}
+	ghost.Status.Children = append(ghost.Status.Children, marketingv1.ChildStatus{Kind: kind})
+	return &ghost.Status.Children[len(ghost.Status.Children)-1]
+}
+
+// findChildEntry returns the inventory entry of the child kind, nil when the Ghost has none
+func findChildEntry(ghost *marketingv1.Ghost, kind string) *marketingv1.ChildStatus {
+	for i := range ghost.Status.Children {
+		if ghost.Status.Children[i].Kind == kind {
+			return &ghost.Status.Children[i]
+		}
+	}
+	return nil
+}
+
+// removeChildEntry drops the child kind from the inventory once the Ghost no longer has one
+func removeChildEntry(ghost *marketingv1.Ghost, kind string) {
+	for i := range ghost.Status.Children {
+		if ghost.Status.Children[i].Kind == kind {
+			ghost.Status.Children = append(ghost.Status.Children[:i], ghost.Status.Children[i+1:]...)
+			return
+		}
+	}
+}
+
+// recordInventory records the live child object of the kind, the hash is kept when nothing was applied
+func recordInventory(ghost *marketingv1.Ghost, kind string, object client.Object, scheme *runtime.Scheme, ready bool, hash string) {
+	if object == nil {
+		removeChildEntry(ghost, kind)
+		return
+	}
+	entry := childEntry(ghost, kind)
+	entry.Name = object.GetName()
+	if gvk, err := apiutil.GVKForObject(object, scheme); err == nil {
+		entry.APIVersion, entry.ResourceKind = gvk.ToAPIVersionAndKind()
+	}
+	entry.Ready = ready
+	if hash != "" {
+		entry.LastAppliedHash = hash
+	}
+}
+
+// recordChildError keeps the error in the inventory unless a newer one is already recorded
+// for the kind, and reports whether the status changed
+func recordChildError(ghost *marketingv1.Ghost, kind, reason, message string, at metav1.Time) bool {
+	entry := childEntry(ghost, kind)
+	if entry.LastErrorTime != nil && at.Before(entry.LastErrorTime) {
+		return false
+	}
+	if entry.LastError == message && entry.Reason == reason && entry.LastErrorTime.Equal(&at) {
+		return false
+	}
+	entry.LastError = message
+	entry.Reason = reason
+	entry.LastErrorTime = &at
+	return true
+}
+
+// childHash identifies the content of the object applied for a child
+func childHash(object client.Object) (string, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
+// pruneChildren deletes the children the previous inventory recorded under a name the Ghost no
+// longer uses, such as the ones left behind when the child naming changed, and the orphans found
+// through the owner index. Children the Ghost stops wanting under their current name are already
+// deleted by their reconciler. A stale PVC is kept, it may hold the only copy of the content.
+func (r *GhostReconciler) pruneChildren(ctx context.Context, ghost *marketingv1.Ghost, previous []marketingv1.ChildStatus) error {
+	log := log.FromContext(ctx)
+	for _, entry := range previous {
+		if entry.Name == "" || entry.APIVersion == "" || entry.ResourceKind == "" || entry.Kind == (pvcChild{}).Kind() {
+			continue
+		}
+		if current := findChildEntry(ghost, entry.Kind); current != nil && current.Name == entry.Name {
+			continue
+		}
+		gvk := schema.FromAPIVersionAndKind(entry.APIVersion, entry.ResourceKind)
+		var obj client.Object = newUnstructured(gvk)
+		if typed, err := r.Scheme.New(gvk); err == nil {
+			obj = typed.(client.Object)
+		}
+		observed, err := observeChild(ctx, r.Client, ghost.Namespace, entry.Name, obj)
+		if err != nil {
+			return err
+		}
+		if observed == nil || !metav1.IsControlledBy(observed, ghost) {
+			continue
+		}
+		if err := r.pruneChild(ctx, ghost, entry.Kind, observed); err != nil {
+			return err
+		}
+	}
+	if !r.ownerIndexed {
+		return nil
+	}
+	orphans, err := r.orphanedChildren(ctx, ghost)
+	if err != nil {
+		return err
+	}
+	for _, orphan := range orphans {
+		gvk, err := apiutil.GVKForObject(orphan, r.Scheme)
+		if err != nil {
+			return err
+		}
+		if gvk.Kind == "PersistentVolumeClaim" {
+			log.Info("Orphaned PVC kept", "name", orphan.GetName())
+			continue
+		}
+		if err := r.pruneChild(ctx, ghost, gvk.Kind, orphan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneChild deletes a child the Ghost no longer uses
+func (r *GhostReconciler) pruneChild(ctx context.Context, ghost *marketingv1.Ghost, kind string, child client.Object) error {
+	if err := r.Delete(ctx, child); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, kind+"Pruned", kind+" "+child.GetName()+" is no longer used and was deleted")
+	log.FromContext(ctx).Info(kind+" pruned", "name", child.GetName())
+	return nil
+}
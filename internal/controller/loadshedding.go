@@ -0,0 +1,122 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// clientThrottleThreshold is how long the client-side rate limiter may hold a request back
+	// before it counts as throttling, client-go logs throttling from the same delay on
+	clientThrottleThreshold = time.Second
+	// loadSheddingStretch multiplies the poll intervals while the API server is under pressure
+	loadSheddingStretch = 4
+)
+
+// LoadShedder tells when the API server pushes back on the operator, which then observes less:
+// polls are stretched and the volume stats and drift scans are deferred until the pressure is gone
+type LoadShedder struct {
+	// Cooldown is how long the operator observes less after the last throttled request
+	Cooldown time.Duration
+	// Now is the clock throttling is recorded with, time.Now when unset
+	Now func() time.Time
+
+	mu          sync.Mutex
+	throttledAt time.Time
+}
+
+// WrapConfig records the throttling of every client built from cfg, the HTTP 429 answers of the
+// API server and the requests the client-side rate limiters held back. The rate limiter of cfg is
+// left alone so that client-go keeps building one for each client, the waits are observed through
+// its rate limiter latency metric instead.
+func (s *LoadShedder) WrapConfig(cfg *rest.Config) {
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &throttleRecorder{next: rt, shedder: s}
+	})
+	clientmetrics.RateLimiterLatency = &waitRecorder{next: clientmetrics.RateLimiterLatency, shedder: s}
+}
+
+// Shedding reports whether the operator was throttled within the cooldown
+func (s *LoadShedder) Shedding() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shedding := !s.throttledAt.IsZero() && s.now().Sub(s.throttledAt) < s.Cooldown
+	value := 0.0
+	if shedding {
+		value = 1
+	}
+	degradedObservation.Set(value)
+	return shedding
+}
+
+// throttled notes a throttled request and logs when it starts the degraded observation
+func (s *LoadShedder) throttled(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.throttledAt.IsZero() || now.Sub(s.throttledAt) >= s.Cooldown {
+		ctrl.Log.WithName("load-shedding").Info("API server is throttling the operator, observing less", "reason", reason, "cooldown", s.Cooldown)
+	}
+	s.throttledAt = now
+	degradedObservation.Set(1)
+}
+
+func (s *LoadShedder) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// throttleRecorder notes the requests the API server rejected as too many
+type throttleRecorder struct {
+	next    http.RoundTripper
+	shedder *LoadShedder
+}
+
+func (t *throttleRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		t.shedder.throttled("TooManyRequests")
+	}
+	return resp, err
+}
+
+// waitRecorder notes the requests a client-side rate limiter held back for long
+type waitRecorder struct {
+	next    clientmetrics.LatencyMetric
+	shedder *LoadShedder
+}
+
+func (w *waitRecorder) Observe(ctx context.Context, verb string, u url.URL, latency time.Duration) {
+	if latency >= clientThrottleThreshold {
+		w.shedder.throttled("ClientRateLimited")
+	}
+	w.next.Observe(ctx, verb, u, latency)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/client-go/rest"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Load shedding", func() {
+	It("should observe less for the cooldown after the API server answered too many requests", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+		now := time.Now()
+		shedder := &LoadShedder{Cooldown: 5 * time.Minute, Now: func() time.Time { return now }}
+		cfg := &rest.Config{Host: server.URL}
+		shedder.WrapConfig(cfg)
+		Expect(cfg.RateLimiter).To(BeNil())
+		Expect(shedder.Shedding()).To(BeFalse())
+
+		httpClient, err := rest.HTTPClientFor(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		resp, err := httpClient.Get(server.URL + "/api/v1/namespaces")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(shedder.Shedding()).To(BeTrue())
+		Expect(testutil.ToFloat64(degradedObservation)).To(Equal(1.0))
+
+		By("stretching the polls while shedding")
+		reconciler := &GhostReconciler{VolumeStats: &staticVolumeStats{}, LoadShedder: shedder}
+		Expect(reconciler.pollInterval(&marketingv1.Ghost{})).To(Equal(loadSheddingStretch * volumeUsagePollInterval))
+
+		By("observing fully again after the cooldown")
+		now = now.Add(5 * time.Minute)
+		Expect(shedder.Shedding()).To(BeFalse())
+		Expect(testutil.ToFloat64(degradedObservation)).To(Equal(0.0))
+		Expect(reconciler.pollInterval(&marketingv1.Ghost{})).To(Equal(volumeUsagePollInterval))
+
+		By("observing less again once a client-side rate limiter held a request back")
+		clientmetrics.RateLimiterLatency.Observe(context.Background(), http.MethodGet, url.URL{}, 100*time.Millisecond)
+		Expect(shedder.Shedding()).To(BeFalse())
+		clientmetrics.RateLimiterLatency.Observe(context.Background(), http.MethodGet, url.URL{}, clientThrottleThreshold)
+		Expect(shedder.Shedding()).To(BeTrue())
+	})
+})
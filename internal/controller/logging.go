@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// logShipperContainerName is the sidecar spec.logging.shipper runs
+	logShipperContainerName = "log-shipper"
+	// logVolumeName is shared between Ghost writing its log files and the shipper reading them
+	logVolumeName = "ghost-logs"
+	logPath       = "/var/log/ghost"
+	// logShipperConfigVolumeName mounts the Secret configuring the shipper
+	logShipperConfigVolumeName = "log-shipper-config"
+
+	defaultFluentBitImage = "cr.fluentbit.io/fluent/fluent-bit:3.1"
+	defaultVectorImage    = "timberio/vector:0.41.1-debian"
+)
+
+// loggingLevel is the level spec.logging sets, empty when Ghost keeps its own
+func loggingLevel(ghost *marketingv1.Ghost) string {
+	if ghost.Spec.Logging == nil {
+		return ""
+	}
+	return ghost.Spec.Logging.Level
+}
+
+// logShipper returns spec.logging.shipper, nil when no logs are shipped
+func logShipper(ghost *marketingv1.Ghost) *marketingv1.LogShipperSpec {
+	if ghost.Spec.Logging == nil {
+		return nil
+	}
+	return ghost.Spec.Logging.Shipper
+}
+
+// generateLoggingEnv renders the logging__* settings of spec.logging. Ghost logs JSON records to
+// stdout through its rawstdout transport, and to files in logPath for the shipper.
+func generateLoggingEnv(ghost *marketingv1.Ghost) []corev1.EnvVar {
+	spec := ghost.Spec.Logging
+	if spec == nil {
+		return nil
+	}
+	var env []corev1.EnvVar
+	if spec.Level != "" {
+		env = append(env, corev1.EnvVar{Name: "logging__level", Value: spec.Level})
+	}
+	if spec.Format == "" && spec.Shipper == nil {
+		return env
+	}
+	transports := []string{"stdout"}
+	if spec.Format == marketingv1.LogFormatJSON {
+		transports = []string{"rawstdout"}
+	}
+	if spec.Shipper != nil {
+		transports = append(transports, "file")
+		env = append(env, corev1.EnvVar{Name: "logging__path", Value: logPath + "/"})
+	}
+	// Arrays are read from the environment as JSON
+	value, _ := json.Marshal(transports)
+	return append(env, corev1.EnvVar{Name: "logging__transports", Value: string(value)})
+}
+
+// mountLogVolume shares the log files of Ghost with the shipper of spec.logging.shipper
+func mountLogVolume(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec, container *corev1.Container) {
+	shipper := logShipper(ghost)
+	if shipper == nil {
+		return
+	}
+	podSpec.Volumes = append(podSpec.Volumes,
+		corev1.Volume{Name: logVolumeName, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		corev1.Volume{Name: logShipperConfigVolumeName, VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: shipper.ConfigSecretRef.Name},
+		}},
+	)
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: logVolumeName, MountPath: logPath})
+}
+
+// logShipperContainer returns the shipper sidecar, nil when spec.logging.shipper is unset. The
+// configuration is mounted next to the image's own, so parsers it ships with stay available.
+func logShipperContainer(ghost *marketingv1.Ghost) *corev1.Container {
+	shipper := logShipper(ghost)
+	if shipper == nil {
+		return nil
+	}
+	image, configPath, args := defaultVectorImage, "/etc/vector/ghost", []string{"--config", "/etc/vector/ghost/vector.yaml"}
+	if shipper.Type == marketingv1.LogShipperFluentBit {
+		image, configPath, args = defaultFluentBitImage, "/fluent-bit/etc/ghost", []string{"--config", "/fluent-bit/etc/ghost/fluent-bit.conf"}
+	}
+	if shipper.Image != "" {
+		image = shipper.Image
+	}
+	return &corev1.Container{
+		Name:      logShipperContainerName,
+		Image:     image,
+		Args:      args,
+		Env:       []corev1.EnvVar{{Name: "GHOST_LOG_PATH", Value: logPath}},
+		Resources: shipper.Resources,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: logVolumeName, MountPath: logPath, ReadOnly: true},
+			{Name: logShipperConfigVolumeName, MountPath: configPath, ReadOnly: true},
+		},
+	}
+}
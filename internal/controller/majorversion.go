@@ -0,0 +1,54 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const upgradeBlockedCondition = "UpgradeBlocked"
+
+// imageTag returns the tag of an image reference, empty when it is pinned by digest
+func imageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	return image[strings.LastIndex(image, ":")+1:]
+}
+
+// majorVersionSkip describes the upgrade of the running image when it skips a Ghost major
+// version, which the webhook denies but a Ghost admitted without it can still ask for
+func (r *GhostReconciler) majorVersionSkip(ctx context.Context, ghost *marketingv1.Ghost) (string, error) {
+	if policy := ghost.Spec.UpgradePolicy; policy != nil && policy.AllowMajorSkips {
+		return "", nil
+	}
+	running, err := liveImage(ctx, r.Client, ghost)
+	if err != nil || running == "" {
+		return "", err
+	}
+	image := ghostImage(ghost)
+	from, fromVersion := marketingv1.MajorVersion(imageTag(running))
+	to, toVersion := marketingv1.MajorVersion(imageTag(image))
+	if !fromVersion || !toVersion || to <= from+1 {
+		return "", nil
+	}
+	return fmt.Sprintf("Upgrading from %s to %s skips a Ghost major version, upgrade to Ghost %d first or set spec.upgradePolicy.allowMajorSkips", running, image, from+1), nil
+}
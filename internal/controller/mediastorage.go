@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	storageAdapterContainerName = "storage-adapter"
+	storageAdapterVolume        = "storage-adapter"
+	storageAdapterDir           = "/adapter"
+	// s3AdapterPackage is the pinned npm release of the S3 storage adapter
+	s3AdapterPackage = "ghost-storage-adapter-s3@2.8.0"
+	// s3AdapterPath is where Ghost looks up the storage adapter named s3
+	s3AdapterPath = ghostContentPath + "/adapters/storage/s3"
+)
+
+// installAdapterScript installs the adapter package with its dependencies into the adapter
+// volume, using the npm of the Ghost image so the adapter is built for the same Node release
+const installAdapterScript = `set -eu
+cd "$ADAPTER_DIR"
+npm install --no-save --omit=dev --no-audit --no-fund --cache "$ADAPTER_DIR/.npm" "$ADAPTER_PACKAGE"
+cp -R "node_modules/${ADAPTER_PACKAGE%@*}/." .
+rm -rf .npm
+`
+
+// mountStorageAdapter installs the storage adapter of spec.mediaStorage ahead of the other init
+// containers and mounts it where Ghost looks adapters up, over the content volume, so every
+// rollout runs the pinned release
+func mountStorageAdapter(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec, container *corev1.Container) {
+	storage := ghost.Spec.MediaStorage
+	if storage == nil || storage.S3 == nil {
+		return
+	}
+	podSpec.Volumes = append(podSpec.Volumes, scratchVolume(ghost, storageAdapterVolume))
+	install := corev1.Container{
+		Name:            storageAdapterContainerName,
+		Image:           container.Image,
+		ImagePullPolicy: container.ImagePullPolicy,
+		Command:         []string{"sh", "-c", installAdapterScript},
+		Env: []corev1.EnvVar{
+			{Name: "ADAPTER_DIR", Value: storageAdapterDir},
+			{Name: "ADAPTER_PACKAGE", Value: s3AdapterPackage},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: storageAdapterVolume, MountPath: storageAdapterDir}},
+	}
+	podSpec.InitContainers = append([]corev1.Container{install}, podSpec.InitContainers...)
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      storageAdapterVolume,
+		MountPath: s3AdapterPath,
+		ReadOnly:  true,
+	})
+	container.Env = append(container.Env, generateS3StorageEnv(storage.S3)...)
+}
+
+// generateS3StorageEnv renders Ghost's storage__* settings activating the S3 adapter, sourcing
+// the access keys from a Secret
+func generateS3StorageEnv(s3 *marketingv1.S3MediaStorageSpec) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{Name: "storage__active", Value: "s3"},
+		{Name: "storage__s3__bucket", Value: s3.Bucket},
+		{Name: "storage__s3__region", Value: s3.Region},
+	}
+	if s3.Endpoint != "" {
+		env = append(env, corev1.EnvVar{Name: "storage__s3__endpoint", Value: s3.Endpoint})
+	}
+	if s3.ForcePathStyle {
+		env = append(env, corev1.EnvVar{Name: "storage__s3__forcePathStyle", Value: strconv.FormatBool(s3.ForcePathStyle)})
+	}
+	if s3.AssetHost != "" {
+		env = append(env, corev1.EnvVar{Name: "storage__s3__assetHost", Value: s3.AssetHost})
+	}
+	if s3.PathPrefix != "" {
+		env = append(env, corev1.EnvVar{Name: "storage__s3__pathPrefix", Value: s3.PathPrefix})
+	}
+	if secret := s3.CredentialsSecretRef; secret != nil {
+		for _, key := range []struct{ name, key string }{
+			{"storage__s3__accessKeyId", "AWS_ACCESS_KEY_ID"},
+			{"storage__s3__secretAccessKey", "AWS_SECRET_ACCESS_KEY"},
+		} {
+			env = append(env, corev1.EnvVar{
+				Name: key.name,
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: *secret,
+					Key:                  key.key,
+				}},
+			})
+		}
+	}
+	return env
+}
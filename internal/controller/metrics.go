@@ -0,0 +1,89 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Per Ghost series are removed once the Ghost is deleted so they do not outlive it
+var (
+	ownerReviewTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_owner_review_timestamp_seconds",
+		Help: "Expiry review date of the Ghost as a Unix timestamp, labelled with the owning team",
+	}, []string{"namespace", "name", "team"})
+	ownerReviewOverdue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_owner_review_overdue",
+		Help: "Whether the expiry review of the Ghost has passed, labelled with the owning team",
+	}, []string{"namespace", "name", "team"})
+	ghostPaused = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_paused",
+		Help: "Whether reconciliation of the Ghost is paused, summed it counts the paused instances",
+	}, []string{"namespace", "name"})
+	ghostUpdateAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_update_available",
+		Help: "Whether a newer upstream Ghost release than the one the Ghost runs is available",
+	}, []string{"namespace", "name"})
+	ghostInstanceReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_instance_ready",
+		Help: "Whether the GhostReady condition of the Ghost is true",
+	}, []string{"namespace", "name"})
+	childReconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ghost_child_reconcile_errors_total",
+		Help: "Failed reconciles of a kind of Ghost child resource, by error class",
+	}, []string{"kind", "class"})
+	childReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ghost_child_reconcile_duration_seconds",
+		Help:    "Time spent reconciling a kind of Ghost child resource",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"kind"})
+	reconcileFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_reconcile_failures_last_hour",
+		Help: "Failed reconciles of the Ghost within the last hour, only for Ghosts that failed in it",
+	}, []string{"namespace", "name"})
+	reconcileErrorBudgetExceeded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_reconcile_error_budget_exceeded",
+		Help: "Whether the Ghost failed to reconcile more often within the last hour than the operator allows",
+	}, []string{"namespace", "name"})
+	contentVolumeUsedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_content_volume_used_bytes",
+		Help: "Space used on the content volume of the Ghost",
+	}, []string{"namespace", "name"})
+	contentVolumeCapacityBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_content_volume_capacity_bytes",
+		Help: "Capacity of the content volume of the Ghost",
+	}, []string{"namespace", "name"})
+	contentVolumeInodesUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_content_volume_inodes_used",
+		Help: "Inodes used on the content volume of the Ghost",
+	}, []string{"namespace", "name"})
+	contentVolumeInodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_content_volume_inodes",
+		Help: "Inodes of the content volume of the Ghost",
+	}, []string{"namespace", "name"})
+	degradedObservation = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ghost_operator_degraded_observation",
+		Help: "Whether the operator observes less because the API server throttled it recently",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(ownerReviewTimestamp, ownerReviewOverdue, ghostPaused, ghostUpdateAvailable,
+		ghostInstanceReady, childReconcileErrors, childReconcileDuration, reconcileFailures, reconcileErrorBudgetExceeded,
+		contentVolumeUsedBytes, contentVolumeCapacityBytes, contentVolumeInodesUsed, contentVolumeInodes, degradedObservation)
+}
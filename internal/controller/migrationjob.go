@@ -0,0 +1,169 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	databaseMigratedCondition = "DatabaseMigrated"
+	migrationJobNamePrefix    = "ghost-migrate-"
+)
+
+// migrateScript runs the knex-migrator Ghost ships with against the database its environment configures
+const migrateScript = `set -eu
+cd current
+exec node_modules/.bin/knex-migrator-migrate --mgpath .
+`
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+// migrateBeforeUpgrade migrates the database to a new Ghost image in a Job while the running
+// image is scaled down, and reports whether the rollout has to wait for the Job
+func (r *GhostReconciler) migrateBeforeUpgrade(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	policy := ghost.Spec.UpgradePolicy
+	if policy == nil || !policy.MigrationJob {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, databaseMigratedCondition)
+		return false, nil
+	}
+	observed, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, deploymentName(ghost), &appsv1.Deployment{})
+	if err != nil || observed == nil {
+		// A fresh install creates its database on startup
+		return false, err
+	}
+	live := observed.(*appsv1.Deployment)
+	image := ghostImage(ghost)
+	containers := live.Spec.Template.Spec.Containers
+	if len(containers) == 0 || containers[0].Image == image {
+		return false, nil
+	}
+
+	name := childName(ghost, migrationJobNamePrefix)
+	if r.readOnly(ghost) {
+		setCondition(ghost, databaseMigratedCondition, metav1.ConditionFalse, "DriftDetected", "Job "+name+" would migrate the database to "+image+", skipped in read-only mode")
+		return true, nil
+	}
+	// The previous image must not write to the database while it is migrated under it. The
+	// Deployment is applied with its replica count again once the Job succeeded.
+	if ptr.Deref(live.Spec.Replicas, 1) != 0 {
+		patch := client.MergeFrom(live.DeepCopy())
+		live.Spec.Replicas = ptr.To[int32](0)
+		if err := r.Patch(ctx, live, patch); err != nil {
+			return true, err
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "MigrationScaledDown", "Deployment "+live.Name+" scaled down to migrate the database to "+image)
+	}
+	if live.Status.Replicas != 0 {
+		setCondition(ghost, databaseMigratedCondition, metav1.ConditionFalse, "MigrationPending", "Waiting for the pods of "+containers[0].Image+" to stop before migrating the database to "+image)
+		return true, nil
+	}
+
+	observed, err = observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, name, &batchv1.Job{})
+	if err != nil {
+		return true, err
+	}
+	if observed != nil && observed.GetAnnotations()[snapshotImageAnnotation] != image {
+		// The Job of an earlier target image, e.g. one that failed and was reverted, is replaced
+		err := r.Delete(ctx, observed, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		return true, client.IgnoreNotFound(err)
+	}
+	if observed == nil {
+		desired, err := desire(deploymentChild{proxy: r.Proxy}, ghost)
+		if err != nil {
+			return true, err
+		}
+		job := generateMigrationJob(ghost, desired.(*appsv1.Deployment), name, image)
+		if err := controllerutil.SetControllerReference(ghost, job, r.Scheme); err != nil {
+			return true, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return true, err
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "MigrationStarted", "Job "+name+" migrates the database to "+image)
+		setCondition(ghost, databaseMigratedCondition, metav1.ConditionFalse, "Migrating", "Job "+name+" migrates the database to "+image)
+		return true, nil
+	}
+
+	for _, condition := range observed.(*batchv1.Job).Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			setCondition(ghost, databaseMigratedCondition, metav1.ConditionTrue, "Migrated", "Job "+name+" migrated the database to "+image)
+			return false, nil
+		case batchv1.JobFailed:
+			if previous := meta.FindStatusCondition(ghost.Status.Conditions, databaseMigratedCondition); previous == nil || previous.Reason != "MigrationFailed" {
+				r.Recoder.Event(ghost, corev1.EventTypeWarning, "MigrationFailed", "Job "+name+" failed to migrate the database to "+image+", see its logs")
+			}
+			setCondition(ghost, databaseMigratedCondition, metav1.ConditionFalse, "MigrationFailed",
+				"Job "+name+" failed to migrate the database to "+image+", Ghost stays scaled down until the Job is deleted to retry or the image is reverted: "+condition.Message)
+			return true, nil
+		}
+	}
+	setCondition(ghost, databaseMigratedCondition, metav1.ConditionFalse, "Migrating", "Job "+name+" migrates the database to "+image)
+	return true, nil
+}
+
+// generateMigrationJob renders the Job migrating the database, it runs the Ghost container of
+// the desired Deployment with its configuration and volumes. The pods are left out of the
+// Service, they do not serve.
+func generateMigrationJob(ghost *marketingv1.Ghost, deployment *appsv1.Deployment, name, image string) *batchv1.Job {
+	podSpec := deployment.Spec.Template.Spec.DeepCopy()
+	container := podSpec.Containers[0]
+	container.Command = []string{"sh", "-c", migrateScript}
+	container.Args = nil
+	container.Ports = nil
+	container.LivenessProbe = nil
+	container.ReadinessProbe = nil
+	container.StartupProbe = nil
+	container.Lifecycle = nil
+	// Sidecars would keep the Job from completing
+	podSpec.Containers = []corev1.Container{container}
+	podSpec.RestartPolicy = corev1.RestartPolicyNever
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   ghost.ObjectMeta.Namespace,
+			Labels:      withCommon(map[string]string{"app": appLabel(ghost)}, ghost.Spec.CommonLabels),
+			Annotations: withCommon(map[string]string{snapshotImageAnnotation: image}, ghost.Spec.CommonAnnotations),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](2),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      withCommon(nil, ghost.Spec.CommonLabels),
+					Annotations: deployment.Spec.Template.Annotations,
+				},
+				Spec: *podSpec,
+			},
+		},
+	}
+}
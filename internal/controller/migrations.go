@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// migrationStateName is the ConfigMap recording which migrations already ran in the cluster
+const migrationStateName = "ghost-controller-migrations"
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
+
+// migration is a one-time fleet change shipped with an operator upgrade. Run must be
+// idempotent, it is retried when the operator stops before the migration is recorded.
+type migration struct {
+	// ID is recorded once the migration succeeded, it must never change
+	ID string
+	// Description is logged when the migration runs
+	Description string
+	Run         func(ctx context.Context, c client.Client) error
+}
+
+// migrations is the ordered list of fleet migrations. Append new ones at the end and
+// never reorder or remove entries, their IDs are the only record of what already ran.
+var migrations = []migration{}
+
+// MigrationRunner executes pending migrations once per cluster when the operator starts
+type MigrationRunner struct {
+	Client client.Client
+	// Reader reads the migration state without starting a cluster wide ConfigMap informer
+	Reader client.Reader
+	// Namespace holds the migration state, usually the operator's own namespace
+	Namespace  string
+	migrations []migration
+}
+
+// NeedLeaderElection makes only the elected operator replica run migrations
+func (m *MigrationRunner) NeedLeaderElection() bool {
+	return true
+}
+
+// Start runs every migration not yet recorded, in order, and records each one on success
+func (m *MigrationRunner) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("migrations")
+	pending := m.migrations
+	if pending == nil {
+		pending = migrations
+	}
+
+	state := &corev1.ConfigMap{}
+	err := m.Reader.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: migrationStateName}, state)
+	if apierrors.IsNotFound(err) {
+		state = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: m.Namespace, Name: migrationStateName},
+		}
+		if err := m.Client.Create(ctx, state); err != nil {
+			return fmt.Errorf("creating migration state: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("reading migration state: %w", err)
+	}
+
+	for _, mig := range pending {
+		if _, done := state.Data[mig.ID]; done {
+			continue
+		}
+		log.Info("Running migration", "id", mig.ID, "description", mig.Description)
+		if err := mig.Run(ctx, m.Client); err != nil {
+			return fmt.Errorf("migration %s: %w", mig.ID, err)
+		}
+		if state.Data == nil {
+			state.Data = map[string]string{}
+		}
+		state.Data[mig.ID] = time.Now().UTC().Format(time.RFC3339)
+		// The resourceVersion guards against a second operator recording concurrently
+		if err := m.Client.Update(ctx, state); err != nil {
+			return fmt.Errorf("recording migration %s: %w", mig.ID, err)
+		}
+		log.Info("Migration completed", "id", mig.ID)
+	}
+	return nil
+}
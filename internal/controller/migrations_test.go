@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Migration runner", func() {
+	AfterEach(func() {
+		state := &corev1.ConfigMap{}
+		state.Namespace, state.Name = "default", migrationStateName
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, state))).To(Succeed())
+	})
+
+	It("should run each migration exactly once and record it", func() {
+		runs := 0
+		runner := &MigrationRunner{
+			Client:    k8sClient,
+			Reader:    k8sClient,
+			Namespace: "default",
+			migrations: []migration{{
+				ID:          "0001-example",
+				Description: "count runs",
+				Run: func(context.Context, client.Client) error {
+					runs++
+					return nil
+				},
+			}},
+		}
+
+		Expect(runner.Start(ctx)).To(Succeed())
+		Expect(runner.Start(ctx)).To(Succeed())
+		Expect(runs).To(Equal(1))
+
+		state := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: migrationStateName}, state)).To(Succeed())
+		Expect(state.Data).To(HaveKey("0001-example"))
+	})
+
+	It("should stop at a failed migration without recording it", func() {
+		later := false
+		runner := &MigrationRunner{
+			Client:    k8sClient,
+			Reader:    k8sClient,
+			Namespace: "default",
+			migrations: []migration{
+				{ID: "0001-broken", Run: func(context.Context, client.Client) error { return errors.New("boom") }},
+				{ID: "0002-later", Run: func(context.Context, client.Client) error { later = true; return nil }},
+			},
+		}
+
+		Expect(runner.Start(ctx)).To(MatchError(ContainSubstring("0001-broken")))
+		Expect(later).To(BeFalse())
+
+		state := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: migrationStateName}, state)).To(Succeed())
+		Expect(state.Data).NotTo(HaveKey("0001-broken"))
+	})
+})
@@ -0,0 +1,203 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// The prometheus.io annotations are a convention of the community Prometheus scrape configs,
+// the operator owns them while spec.monitoring.annotations is set
+const (
+	prometheusScrapeAnnotation = "prometheus.io/scrape"
+	prometheusPortAnnotation   = "prometheus.io/port"
+	prometheusPathAnnotation   = "prometheus.io/path"
+	defaultMetricsPath         = "/metrics"
+)
+
+const monitorNamePrefix = "ghost-monitor-"
+
+// metricsExporterContainerName is the sidecar serving metrics for spec.monitoring.exporter
+const (
+	metricsExporterContainerName = "metrics-exporter"
+	defaultMetricsExporterPort   = 9100
+)
+
+// monitoringUnavailableCondition is only present while a monitor is requested on a cluster
+// without prometheus-operator
+const monitoringUnavailableCondition = "MonitoringUnavailable"
+
+// prometheus-operator is an optional dependency, so monitors are handled as unstructured objects
+var (
+	serviceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+	podMonitorGVK     = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PodMonitor"}
+)
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors;podmonitors,verbs=get;list;watch;create;update;patch;delete
+
+// MonitoringAPIAvailable reports whether the cluster serves monitoring.coreos.com/v1
+func MonitoringAPIAvailable(cfg *rest.Config) (bool, error) {
+	return groupVersionServed(cfg, serviceMonitorGVK.GroupVersion())
+}
+
+// monitorKind is the kind of monitor the Ghost asks for, empty when it asks for none
+func monitorKind(ghost *marketingv1.Ghost) marketingv1.MonitorKind {
+	spec := ghost.Spec.Monitoring
+	if spec == nil || !spec.Enabled {
+		return ""
+	}
+	if spec.Kind == "" {
+		return marketingv1.MonitorKindServiceMonitor
+	}
+	return spec.Kind
+}
+
+// metricsPort is the pod port serving the metrics, the exporter's when there is one
+func metricsPort(ghost *marketingv1.Ghost) int32 {
+	spec := ghost.Spec.Monitoring
+	switch {
+	case spec != nil && spec.Exporter != nil:
+		if spec.Exporter.Port != 0 {
+			return spec.Exporter.Port
+		}
+		return defaultMetricsExporterPort
+	case spec != nil && spec.Port != 0:
+		return spec.Port
+	default:
+		return containerPort(ghost)
+	}
+}
+
+// metricsPath is the path of the metrics endpoint
+func metricsPath(ghost *marketingv1.Ghost) string {
+	if spec := ghost.Spec.Monitoring; spec != nil && spec.Path != "" {
+		return spec.Path
+	}
+	return defaultMetricsPath
+}
+
+// scrapeAnnotations returns the annotations pointing Prometheus at the Ghost metrics,
+// nil unless spec.monitoring.annotations is set. The port is the pod port on the Service too,
+// the endpoints discovery scrapes the pods behind it.
+func scrapeAnnotations(ghost *marketingv1.Ghost) map[string]string {
+	spec := ghost.Spec.Monitoring
+	if spec == nil || !spec.Annotations {
+		return nil
+	}
+	return map[string]string{
+		prometheusScrapeAnnotation: "true",
+		prometheusPortAnnotation:   strconv.Itoa(int(metricsPort(ghost))),
+		prometheusPathAnnotation:   metricsPath(ghost),
+	}
+}
+
+// metricsExporterContainer returns the exporter sidecar, nil when spec.monitoring.exporter is unset
+func metricsExporterContainer(ghost *marketingv1.Ghost) *corev1.Container {
+	spec := ghost.Spec.Monitoring
+	if spec == nil || spec.Exporter == nil {
+		return nil
+	}
+	return &corev1.Container{
+		Name:      metricsExporterContainerName,
+		Image:     spec.Exporter.Image,
+		Args:      spec.Exporter.Args,
+		Env:       spec.Exporter.Env,
+		Resources: spec.Exporter.Resources,
+		Ports: []corev1.ContainerPort{{
+			Name:          "metrics",
+			ContainerPort: metricsPort(ghost),
+			Protocol:      corev1.ProtocolTCP,
+		}},
+	}
+}
+
+// reportMonitoring flags a monitor the cluster cannot create, the Ghost is served regardless
+func (r *GhostReconciler) reportMonitoring(ghost *marketingv1.Ghost) {
+	if kind := monitorKind(ghost); kind != "" && !r.MonitoringAPIAvailable {
+		setCondition(ghost, monitoringUnavailableCondition, metav1.ConditionTrue, "PrometheusOperatorMissing",
+			"spec.monitoring asks for a "+string(kind)+" but monitoring.coreos.com/v1 is not served by this cluster")
+		return
+	}
+	meta.RemoveStatusCondition(&ghost.Status.Conditions, monitoringUnavailableCondition)
+}
+
+// monitorChild manages the ServiceMonitor or PodMonitor scraping the Ghost, one child per kind
+// so switching kinds removes the previous monitor
+type monitorChild struct {
+	gvk schema.GroupVersionKind
+	// apiAvailable is detected at startup, see MonitoringAPIAvailable
+	apiAvailable bool
+}
+
+func (c monitorChild) Kind() string {
+	return c.gvk.Kind
+}
+
+func (c monitorChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !c.apiAvailable || string(monitorKind(ghost)) != c.gvk.Kind {
+		return nil, nil
+	}
+	return generateDesiredMonitor(ghost, c.gvk), nil
+}
+
+func (c monitorChild) Observe(ctx context.Context, cl client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, cl, ghost.ObjectMeta.Namespace, childName(ghost, monitorNamePrefix), newUnstructured(c.gvk))
+}
+
+func (monitorChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// generateDesiredMonitor selects the Ghost pods, directly or through the Ghost Service
+func generateDesiredMonitor(ghost *marketingv1.Ghost, gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	endpoint := map[string]interface{}{
+		"targetPort": int64(metricsPort(ghost)),
+		"path":       metricsPath(ghost),
+	}
+	if interval := ghost.Spec.Monitoring.Interval; interval != "" {
+		endpoint["interval"] = interval
+	}
+	endpoints := "endpoints"
+	if gvk == podMonitorGVK {
+		endpoints = "podMetricsEndpoints"
+	}
+	monitor := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"selector": map[string]interface{}{
+					"matchLabels": map[string]interface{}{"app": appLabel(ghost)},
+				},
+				endpoints: []interface{}{endpoint},
+			},
+		},
+	}
+	monitor.SetGroupVersionKind(gvk)
+	monitor.SetName(childName(ghost, monitorNamePrefix))
+	monitor.SetNamespace(ghost.ObjectMeta.Namespace)
+	return monitor
+}
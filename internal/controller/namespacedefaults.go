@@ -0,0 +1,68 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// Annotations on a namespace set the defaults of its Ghosts, so a team does not repeat them in
+// every Ghost. They rank below the spec and above the operator defaults. The default domain of
+// the hosts is the base domain annotation, which is mirrored onto the Ghosts instead.
+const (
+	// defaultStorageClassAnnotation is the storage class of the content volumes
+	defaultStorageClassAnnotation = "marketing.kb.dev/default-storage-class"
+	// defaultProfileAnnotation is the profile, and with it the resource tier, of the Ghosts
+	defaultProfileAnnotation = "marketing.kb.dev/default-profile"
+)
+
+// applyNamespaceDefaults fills the fields the Ghost leaves unset with the defaults of its
+// namespace, before the operator defaults are applied. Like those only the Ghost being reconciled
+// is changed. A malformed default is ignored and described in the returned warnings.
+func applyNamespaceDefaults(ctx context.Context, c client.Reader, ghost *marketingv1.Ghost) ([]string, error) {
+	namespace := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: ghost.Namespace}, namespace); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	var warnings []string
+	if class := namespace.Annotations[defaultStorageClassAnnotation]; class != "" {
+		if errs := validation.IsDNS1123Subdomain(class); len(errs) > 0 {
+			warnings = append(warnings, "Default storage class "+class+" of namespace "+ghost.Namespace+" is ignored: "+errs[0])
+		} else {
+			if ghost.Spec.Persistence == nil {
+				ghost.Spec.Persistence = &marketingv1.PersistenceSpec{}
+			}
+			if ghost.Spec.Persistence.StorageClassName == nil {
+				ghost.Spec.Persistence.StorageClassName = &class
+			}
+		}
+	}
+	if profile := marketingv1.Profile(namespace.Annotations[defaultProfileAnnotation]); profile != "" {
+		if _, ok := profiles[profile]; !ok {
+			warnings = append(warnings, "Default profile "+string(profile)+" of namespace "+ghost.Namespace+" is ignored: not a profile")
+		} else if ghost.Spec.Profile == "" {
+			ghost.Spec.Profile = profile
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NamespaceScope restricts an operator install to the namespaces whose labels match the
+// selector, so the team namespaces of a cluster can be split between several installs
+type NamespaceScope struct {
+	Selector labels.Selector
+	// Reader looks the namespaces up, the cache the Ghost controller fills by watching them
+	Reader client.Reader
+}
+
+// ParseNamespaceScope returns the scope of a label selector, nil for every namespace
+func ParseNamespaceScope(selector string, reader client.Reader) (*NamespaceScope, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	return &NamespaceScope{Selector: parsed, Reader: reader}, nil
+}
+
+// Includes reports whether the install manages the objects of the namespace. A nil scope
+// manages every namespace, a namespace that is gone none.
+func (s *NamespaceScope) Includes(ctx context.Context, name string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	namespace := &corev1.Namespace{}
+	if err := s.Reader.Get(ctx, client.ObjectKey{Name: name}, namespace); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return s.Selector.Matches(labels.Set(namespace.Labels)), nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// childNamingAnnotation set to namespaceChildNaming keeps a Ghost on the names its children were
+// created with before several Ghosts could share a namespace. The Deployment selector and the PVC
+// cannot be renamed in place, so such a Ghost only moves to per-instance names by backing it up,
+// removing the annotation and restoring into the new children.
+const (
+	childNamingAnnotation = "marketing.kb.dev/child-naming"
+	namespaceChildNaming  = "namespace"
+)
+
+// instanceName identifies the Ghost among the children of its namespace: the Ghost name,
+// or the namespace for a Ghost pinned to the legacy names
+func instanceName(ghost *marketingv1.Ghost) string {
+	if ghost.Annotations[childNamingAnnotation] == namespaceChildNaming {
+		return ghost.Namespace
+	}
+	return ghost.Name
+}
+
+// childName returns the name of the Ghost's child with the given prefix
+func childName(ghost *marketingv1.Ghost, prefix string) string {
+	return prefix + instanceName(ghost)
+}
+
+// appLabel is the app label value selecting the Ghost pods
+func appLabel(ghost *marketingv1.Ghost) string {
+	return "ghost-" + instanceName(ghost)
+}
+
+// pinChildNaming annotates a Ghost that still controls children named after its namespace so it keeps
+// using them. This runs on every reconcile rather than as a fleet migration, the Ghost controller can
+// reconcile an existing Ghost before the migration runner gets to it and would fork its content volume.
+func (r *GhostReconciler) pinChildNaming(ctx context.Context, ghost *marketingv1.Ghost) error {
+	if _, ok := ghost.Annotations[childNamingAnnotation]; ok || ghost.Name == ghost.Namespace {
+		return nil
+	}
+	legacy := false
+	for _, child := range []struct {
+		name string
+		obj  client.Object
+	}{
+		{pvcNamePrefix + ghost.Namespace, &corev1.PersistentVolumeClaim{}},
+		{deploymentNamePrefix + ghost.Namespace, &appsv1.Deployment{}},
+	} {
+		observed, err := observeChild(ctx, r.Client, ghost.Namespace, child.name, child.obj)
+		if err != nil {
+			return err
+		}
+		if observed != nil && metav1.IsControlledBy(observed, ghost) {
+			legacy = true
+			break
+		}
+	}
+	if !legacy {
+		return nil
+	}
+	if r.readOnly(ghost) {
+		// Observe the legacy children without writing to the Ghost
+		metav1.SetMetaDataAnnotation(&ghost.ObjectMeta, childNamingAnnotation, namespaceChildNaming)
+		return nil
+	}
+	original := ghost.DeepCopy()
+	metav1.SetMetaDataAnnotation(&ghost.ObjectMeta, childNamingAnnotation, namespaceChildNaming)
+	if err := r.Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+		return err
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "ChildNamingPinned", "Children named after namespace "+ghost.Namespace+" are kept")
+	return nil
+}
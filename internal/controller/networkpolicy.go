@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	networkPolicyNamePrefix           = "ghost-netpol-"
+	defaultIngressControllerNamespace = "ingress-nginx"
+)
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// networkPolicyChild manages the NetworkPolicy isolating the Ghost pods
+type networkPolicyChild struct{}
+
+func (networkPolicyChild) Kind() string {
+	return "NetworkPolicy"
+}
+
+func (networkPolicyChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if ghost.Spec.NetworkPolicy == nil || !ghost.Spec.NetworkPolicy.Enabled {
+		return nil, nil
+	}
+	return generateDesiredNetworkPolicy(ghost), nil
+}
+
+func (networkPolicyChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, networkPolicyNamePrefix), &networkingv1.NetworkPolicy{})
+}
+
+func (networkPolicyChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// ingressPorts are the Ghost port and the extra ports of the Service
+func ingressPorts(ghost *marketingv1.Ghost) []networkingv1.NetworkPolicyPort {
+	ports := []networkingv1.NetworkPolicyPort{{
+		Protocol: ptr.To(corev1.ProtocolTCP),
+		Port:     ptr.To(intstr.FromInt32(containerPort(ghost))),
+	}}
+	for _, port := range extraPorts(ghost) {
+		ports = append(ports, networkingv1.NetworkPolicyPort{
+			Protocol: ptr.To(extraPortProtocol(port)),
+			Port:     ptr.To(intstr.FromInt32(extraPortTarget(port))),
+		})
+	}
+	return ports
+}
+
+// generateDesiredNetworkPolicy admits the ingress controller, the Ghost's own namespace and the
+// allowed peers on the Ghost port and the extra ports. Egress is only restricted when rules are given, DNS stays open.
+func generateDesiredNetworkPolicy(ghost *marketingv1.Ghost) *networkingv1.NetworkPolicy {
+	spec := ghost.Spec.NetworkPolicy
+	controllerNamespace := spec.IngressControllerNamespace
+	if controllerNamespace == "" {
+		controllerNamespace = defaultIngressControllerNamespace
+	}
+	peers := []networkingv1.NetworkPolicyPeer{
+		{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: controllerNamespace}}},
+		{PodSelector: &metav1.LabelSelector{}},
+	}
+	policy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, networkPolicyNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": appLabel(ghost)}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From:  append(peers, spec.AllowedPeers...),
+				Ports: ingressPorts(ghost),
+			}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	if len(spec.Egress) > 0 {
+		dns := networkingv1.NetworkPolicyEgressRule{Ports: []networkingv1.NetworkPolicyPort{
+			{Protocol: ptr.To(corev1.ProtocolUDP), Port: ptr.To(intstr.FromInt32(53))},
+			{Protocol: ptr.To(corev1.ProtocolTCP), Port: ptr.To(intstr.FromInt32(53))},
+		}}
+		policy.Spec.Egress = append([]networkingv1.NetworkPolicyEgressRule{dns}, spec.Egress...)
+		// The API server defaults the port protocol to TCP, do the same so exports match the live policy
+		for i := range policy.Spec.Egress {
+			policy.Spec.Egress[i] = *policy.Spec.Egress[i].DeepCopy()
+			for j := range policy.Spec.Egress[i].Ports {
+				if policy.Spec.Egress[i].Ports[j].Protocol == nil {
+					policy.Spec.Egress[i].Ports[j].Protocol = ptr.To(corev1.ProtocolTCP)
+				}
+			}
+		}
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+	}
+	return policy
+}
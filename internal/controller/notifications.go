@@ -0,0 +1,176 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// defaultNotificationEvents are posted when spec.notifications lists none
+var defaultNotificationEvents = []marketingv1.NotificationEvent{
+	marketingv1.NotificationEventReady,
+	marketingv1.NotificationEventDegraded,
+	marketingv1.NotificationEventUpgraded,
+	marketingv1.NotificationEventBackupFailed,
+}
+
+// Notification is the message posted about a lifecycle event of a Ghost
+type Notification struct {
+	// Text is the message as Slack incoming webhooks display it
+	Text      string                        `json:"text"`
+	Event     marketingv1.NotificationEvent `json:"event"`
+	Namespace string                        `json:"namespace"`
+	Ghost     string                        `json:"ghost"`
+}
+
+// Notifier posts the notifications of spec.notifications to a webhook URL
+type Notifier interface {
+	Notify(ctx context.Context, url string, notification Notification) error
+}
+
+// WebhookNotifier posts notifications as JSON
+type WebhookNotifier struct {
+	HTTPClient *http.Client
+}
+
+// Notify fails unless the webhook answers with a 2xx status
+func (n *WebhookNotifier) Notify(ctx context.Context, url string, notification Notification) error {
+	httpClient := n.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		// The URL is a credential, only its answer is reported
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// notificationEvents are the events of spec.notifications, the defaults when none are listed
+func notificationEvents(ghost *marketingv1.Ghost) []marketingv1.NotificationEvent {
+	if len(ghost.Spec.Notifications.Events) == 0 {
+		return defaultNotificationEvents
+	}
+	return ghost.Spec.Notifications.Events
+}
+
+// notify posts a lifecycle event of the Ghost when spec.notifications asks for it. Notifications
+// are best effort, a failed post is logged and recorded as a Warning event but not retried.
+func notify(ctx context.Context, reader client.Reader, notifier Notifier, recorder record.EventRecorder,
+	ghost *marketingv1.Ghost, event marketingv1.NotificationEvent, text string) {
+	if notifier == nil || ghost.Spec.Notifications == nil || !slices.Contains(notificationEvents(ghost), event) {
+		return
+	}
+	err := func() error {
+		ref := ghost.Spec.Notifications.WebhookURLSecretRef
+		secret := &corev1.Secret{}
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: ghost.Namespace, Name: ref.Name}, secret); err != nil {
+			return err
+		}
+		url, ok := secret.Data[ref.Key]
+		if !ok {
+			return fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key)
+		}
+		return notifier.Notify(ctx, string(url), Notification{Text: text, Event: event, Namespace: ghost.Namespace, Ghost: ghost.Name})
+	}()
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to post notification", "event", event)
+		recorder.Event(ghost, corev1.EventTypeWarning, "NotificationFailed", fmt.Sprintf("Posting the %s notification: %v", event, err))
+	}
+}
+
+// notifyLifecycle posts the phase and image changes of a Ghost once its status recording them
+// is written, so a failed write does not post them twice
+func (r *GhostReconciler) notifyLifecycle(ctx context.Context, original, ghost *marketingv1.Ghost) {
+	if r.Notifier == nil || ghost.Spec.Notifications == nil {
+		return
+	}
+	name := ghost.Namespace + "/" + ghost.Name
+	if phase := ghost.Status.Phase; phase != original.Status.Phase {
+		switch phase {
+		case marketingv1.GhostPhaseReady:
+			text := "Ghost " + name + " is Ready"
+			if ghost.Status.URL != "" {
+				text += " at " + ghost.Status.URL
+			}
+			notify(ctx, r.secretReader(), r.Notifier, r.Recoder, ghost, marketingv1.NotificationEventReady, text)
+		case marketingv1.GhostPhaseDegraded:
+			notify(ctx, r.secretReader(), r.Notifier, r.Recoder, ghost, marketingv1.NotificationEventDegraded,
+				"Ghost "+name+" is Degraded: "+degradedReason(ghost))
+		}
+	}
+	if from, to := original.Status.Image, ghost.Status.Image; from != "" && to != "" && from != to {
+		notify(ctx, r.secretReader(), r.Notifier, r.Recoder, ghost, marketingv1.NotificationEventUpgraded,
+			"Ghost "+name+" is upgraded from "+from+" to "+to)
+	}
+}
+
+// degradedReason explains the Degraded phase by the condition causing it, or the missing replicas
+func degradedReason(ghost *marketingv1.Ghost) string {
+	for _, condType := range []string{hostConflictCondition, imagePullFailedCondition, degradedCondition} {
+		if condition := meta.FindStatusCondition(ghost.Status.Conditions, condType); condition != nil && condition.Status == metav1.ConditionTrue {
+			return condition.Message
+		}
+	}
+	return fmt.Sprintf("%d of %d replicas are ready", ghost.Status.ReadyReplicas, ghost.Status.Replicas)
+}
+
+// notifyBackupFailed posts the failure of a backup to the notifications of its Ghost
+func (r *GhostBackupReconciler) notifyBackupFailed(ctx context.Context, backup *marketingv1.GhostBackup) {
+	if r.Notifier == nil {
+		return
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: backup.Namespace, Name: backup.Spec.GhostRef.Name}, ghost); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to get the Ghost of the failed backup")
+		return
+	}
+	text := "Backup " + backup.Name + " of Ghost " + ghost.Namespace + "/" + ghost.Name + " failed"
+	if condition := meta.FindStatusCondition(backup.Status.Conditions, backupCompleteCondition); condition != nil {
+		text += ": " + condition.Message
+	}
+	notify(ctx, r.APIReader, r.Notifier, r.Recorder, ghost, marketingv1.NotificationEventBackupFailed, text)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// imageNotAllowedCondition holds back an image whose tag the operator configuration does not allow
+const imageNotAllowedCondition = "ImageNotAllowed"
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostoperatorconfigs,verbs=get;list;watch
+
+// operatorConfig reads the cluster wide defaults, nil when there is no GhostOperatorConfig
+func operatorConfig(ctx context.Context, c client.Client) (*marketingv1.GhostOperatorConfigSpec, error) {
+	observed, err := observeChild(ctx, c, "", marketingv1.OperatorConfigName, &marketingv1.GhostOperatorConfig{})
+	if err != nil || observed == nil {
+		return nil, err
+	}
+	return &observed.(*marketingv1.GhostOperatorConfig).Spec, nil
+}
+
+// applyOperatorDefaults fills the fields the Ghost leaves unset with the operator defaults. Only
+// the Ghost being reconciled is changed, the stored spec keeps what its author wrote, so a changed
+// default reaches every Ghost on its next reconcile.
+func applyOperatorDefaults(ghost *marketingv1.Ghost, config *marketingv1.GhostOperatorConfigSpec) {
+	if config == nil {
+		return
+	}
+	if config.StorageClassName != "" {
+		if ghost.Spec.Persistence == nil {
+			ghost.Spec.Persistence = &marketingv1.PersistenceSpec{}
+		}
+		if ghost.Spec.Persistence.StorageClassName == nil {
+			class := config.StorageClassName
+			ghost.Spec.Persistence.StorageClassName = &class
+		}
+	}
+	if config.IngressClassName != "" {
+		if ghost.Spec.Ingress == nil {
+			ghost.Spec.Ingress = &marketingv1.IngressSpec{}
+		}
+		if ghost.Spec.Ingress.ClassName == "" {
+			ghost.Spec.Ingress.ClassName = config.IngressClassName
+		}
+	}
+	if config.ImageRegistry != "" {
+		if ghost.Spec.Image == nil {
+			ghost.Spec.Image = &marketingv1.ImageSpec{}
+		}
+		repository := ghost.Spec.Image.Repository
+		if repository == "" {
+			repository = defaultImageRepository
+		}
+		ghost.Spec.Image.Repository = withRegistry(repository, config.ImageRegistry)
+	}
+	if config.Resources != nil && ghost.Spec.Resources == nil && ghost.Spec.Profile == "" {
+		ghost.Spec.Resources = config.Resources.DeepCopy()
+	}
+}
+
+// withRegistry prefixes a repository naming no registry host the way docker tells them apart
+func withRegistry(repository, registry string) string {
+	host, _, found := strings.Cut(repository, "/")
+	if found && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		return repository
+	}
+	return strings.TrimSuffix(registry, "/") + "/" + repository
+}
+
+// imageNotAllowed describes the image of the Ghost when its tag matches none of the allowed
+// patterns. Images pinned by digest are not checked.
+func imageNotAllowed(ghost *marketingv1.Ghost, config *marketingv1.GhostOperatorConfigSpec) string {
+	if config == nil || len(config.AllowedImageTags) == 0 {
+		return ""
+	}
+	image := ghostImage(ghost)
+	tag := imageTag(image)
+	if tag == "" {
+		return ""
+	}
+	for _, pattern := range config.AllowedImageTags {
+		// A malformed pattern matches nothing
+		if matched, _ := path.Match(pattern, tag); matched {
+			return ""
+		}
+	}
+	return fmt.Sprintf("Image %s is not allowed by the operator configuration, its tag has to match one of %s",
+		image, strings.Join(config.AllowedImageTags, ", "))
+}
+
+// allGhosts maps a changed operator configuration to every Ghost
+func (r *GhostReconciler) allGhosts(ctx context.Context, _ client.Object) []reconcile.Request {
+	ghosts := &marketingv1.GhostList{}
+	if err := r.List(ctx, ghosts); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(ghosts.Items))
+	for _, ghost := range ghosts.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ghost)})
+	}
+	return requests
+}
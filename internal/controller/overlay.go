@@ -0,0 +1,51 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// applyPodTemplateOverlay patches the generated pod template with spec.podTemplateOverlay. It
+// runs last, so the overlay wins over every field the operator sets, and being part of the
+// desired template it is re-applied wherever the live pods drift from it.
+func applyPodTemplateOverlay(ghost *marketingv1.Ghost, template *corev1.PodTemplateSpec) error {
+	overlay := ghost.Spec.PodTemplateOverlay
+	if overlay == nil || len(overlay.Raw) == 0 {
+		return nil
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, overlay.Raw, corev1.PodTemplateSpec{})
+	if err != nil {
+		return invalidSpecError(fmt.Errorf("spec.podTemplateOverlay: %w", err))
+	}
+	overlaid := corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(patched, &overlaid); err != nil {
+		return invalidSpecError(fmt.Errorf("spec.podTemplateOverlay: %w", err))
+	}
+	*template = overlaid
+	return nil
+}
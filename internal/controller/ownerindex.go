@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// Field indexes listing the children of a Ghost whatever their names, and the Ghosts by the app label of their pods
+const (
+	ownerIndex    = "metadata.controller.uid"
+	ghostAppIndex = "ghost.app"
+)
+
+// indexChildren registers the indexes the Ghost children and pods are looked up through
+func indexChildren(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}, &corev1.PersistentVolumeClaim{}} {
+		if err := indexer.IndexField(ctx, obj, ownerIndex, controllerUID); err != nil {
+			return err
+		}
+	}
+	return indexer.IndexField(ctx, &marketingv1.Ghost{}, ghostAppIndex, func(obj client.Object) []string {
+		return []string{appLabel(obj.(*marketingv1.Ghost))}
+	})
+}
+
+// controllerUID returns the UID of the object's controller, none when nothing controls it
+func controllerUID(obj client.Object) []string {
+	if owner := metav1.GetControllerOf(obj); owner != nil {
+		return []string{string(owner.UID)}
+	}
+	return nil
+}
+
+// orphanedChildren lists the Deployments, Services and PVCs the Ghost controls under names it no
+// longer uses, such as the ones named after the namespace before the Ghost moved to its own names
+func (r *GhostReconciler) orphanedChildren(ctx context.Context, ghost *marketingv1.Ghost) ([]client.Object, error) {
+	inUse := sets.New(
+		slotName(childName(ghost, deploymentNamePrefix), blueSlot),
+		slotName(childName(ghost, deploymentNamePrefix), greenSlot),
+		childName(ghost, maintenanceNamePrefix),
+		childName(ghost, svcNamePrefix),
+		contentClaimName(inSlot(ghost, blueSlot)),
+		contentClaimName(inSlot(ghost, greenSlot)),
+	)
+	var orphans []client.Object
+	for _, list := range []client.ObjectList{&appsv1.DeploymentList{}, &corev1.ServiceList{}, &corev1.PersistentVolumeClaimList{}} {
+		if err := r.List(ctx, list, client.InNamespace(ghost.Namespace), client.MatchingFields{ownerIndex: string(ghost.UID)}); err != nil {
+			return nil, err
+		}
+		if err := meta.EachListItem(list, func(item runtime.Object) error {
+			child := item.(client.Object)
+			if !inUse.Has(child.GetName()) && child.GetDeletionTimestamp().IsZero() {
+				orphans = append(orphans, child)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return orphans, nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// ownerReminderInterval paces the reminders once an expiry review is overdue
+const ownerReminderInterval = 24 * time.Hour
+
+// OwnerReviewReconciler tracks the expiry review of spec.owner and reminds the owners
+// once it is overdue, so blogs nobody needs anymore do not linger unowned
+type OwnerReviewReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Now is the clock the review date is compared against, time.Now when unset
+	Now func() time.Time
+	// Channel is the release channel of this operator deployment, stable when empty
+	Channel string
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts/status,verbs=get;update;patch
+
+// Reconcile reports whether the review is overdue, sends a reminder at most once per
+// interval while it is and requeues until the review date or the next reminder
+func (r *OwnerReviewReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, req.NamespacedName, ghost); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	series := prometheus.Labels{"namespace": req.Namespace, "name": req.Name}
+	ownerReviewTimestamp.DeletePartialMatch(series)
+	ownerReviewOverdue.DeletePartialMatch(series)
+	if ghost.UID == "" || !ghost.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	original := ghost.DeepCopy()
+	owner := ghost.Spec.Owner
+	var requeueAfter time.Duration
+	if owner == nil || owner.ExpiryReview == "" {
+		ghost.Status.Owner = nil
+	} else {
+		review, err := time.Parse(time.DateOnly, owner.ExpiryReview)
+		if err != nil {
+			r.Recorder.Event(ghost, corev1.EventTypeWarning, "InvalidExpiryReview", err.Error())
+			return resultForError(invalidSpecError(fmt.Errorf("spec.owner.expiryReview: %w", err)))
+		}
+		now := r.now()
+		status := &marketingv1.OwnerStatus{ReviewOverdue: !now.Before(review)}
+		if ghost.Status.Owner != nil && status.ReviewOverdue {
+			status.LastReminderTime = ghost.Status.Owner.LastReminderTime
+		}
+		switch {
+		case !status.ReviewOverdue:
+			requeueAfter = review.Sub(now)
+		case status.LastReminderTime == nil || now.Sub(status.LastReminderTime.Time) >= ownerReminderInterval:
+			r.Recorder.Event(ghost, corev1.EventTypeWarning, "OwnerReviewOverdue", reviewReminder(owner))
+			reminded := metav1.NewTime(now)
+			status.LastReminderTime = &reminded
+			requeueAfter = ownerReminderInterval
+		default:
+			requeueAfter = status.LastReminderTime.Add(ownerReminderInterval).Sub(now)
+		}
+		ghost.Status.Owner = status
+
+		series["team"] = owner.Team
+		ownerReviewTimestamp.With(series).Set(float64(review.Unix()))
+		overdue := 0.0
+		if status.ReviewOverdue {
+			overdue = 1
+		}
+		ownerReviewOverdue.With(series).Set(overdue)
+	}
+
+	if !equality.Semantic.DeepEqual(original.Status, ghost.Status) {
+		if err := r.Status().Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (r *OwnerReviewReconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// reviewReminder asks the owners to confirm the blog is still needed
+func reviewReminder(owner *marketingv1.OwnerSpec) string {
+	message := "Expiry review was due on " + owner.ExpiryReview + ", team " + owner.Team + " should confirm the blog is still needed or move spec.owner.expiryReview"
+	if owner.Contact != "" {
+		message += ", contact " + owner.Contact
+	}
+	return message
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OwnerReviewReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.Ghost{}, builder.WithPredicates(channelPredicate(r.Channel))).
+		Named("ghost-owner-review").
+		Complete(r)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Owner review", func() {
+	const namespace = "owner-review"
+
+	It("should remind the owners once a day after the expiry review date", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec: marketingv1.GhostSpec{
+				ImageTag: "latest",
+				Replicas: 1,
+				Owner:    &marketingv1.OwnerSpec{Team: "growth", Contact: "growth@example.com", ExpiryReview: "2026-03-01"},
+			},
+		})).To(Succeed())
+
+		now := time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC)
+		recorder := record.NewFakeRecorder(100)
+		reconciler := &OwnerReviewReconciler{
+			Client:   k8sClient,
+			Recorder: recorder,
+			Now:      func() time.Time { return now },
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "blog"}
+		series := []string{namespace, "blog", "growth"}
+
+		By("waiting for the review date")
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(12 * time.Hour))
+		ghost := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Status.Owner.ReviewOverdue).To(BeFalse())
+		Expect(testutil.ToFloat64(ownerReviewOverdue.WithLabelValues(series...))).To(BeZero())
+		Expect(recorder.Events).To(BeEmpty())
+
+		By("reminding once the date has passed")
+		now = now.Add(13 * time.Hour)
+		result, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(ownerReminderInterval))
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Status.Owner.ReviewOverdue).To(BeTrue())
+		Expect(ghost.Status.Owner.LastReminderTime.Time).To(BeTemporally("==", now))
+		Expect(testutil.ToFloat64(ownerReviewOverdue.WithLabelValues(series...))).To(Equal(1.0))
+		Expect(recorder.Events).To(Receive(ContainSubstring("OwnerReviewOverdue")))
+
+		By("not repeating the reminder within the interval")
+		now = now.Add(time.Hour)
+		result, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(ownerReminderInterval - time.Hour))
+		Expect(recorder.Events).To(BeEmpty())
+
+		By("clearing the review once the date is moved")
+		ghost.Spec.Owner.ExpiryReview = "2027-03-01"
+		Expect(k8sClient.Update(ctx, ghost)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Status.Owner.ReviewOverdue).To(BeFalse())
+		Expect(ghost.Status.Owner.LastReminderTime).To(BeNil())
+		Expect(testutil.ToFloat64(ownerReviewOverdue.WithLabelValues(series...))).To(BeZero())
+	})
+})
@@ -0,0 +1,81 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// pausedAnnotation set to "true" on a namespace stops the reconciliation of every Ghost in it,
+// e.g. for a maintenance window, set on a Ghost it pauses that instance alone like spec.paused
+const pausedAnnotation = "marketing.kb.dev/paused"
+
+// reconciliationPausedCondition is True while the Ghost is paused, the controller then leaves
+// its children alone
+const reconciliationPausedCondition = "ReconciliationPaused"
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// reconcilePaused returns the condition of a paused Ghost, nil when it is not paused, and records
+// it in the paused metric
+func (r *GhostReconciler) reconcilePaused(ctx context.Context, ghost *marketingv1.Ghost) (*metav1.Condition, error) {
+	var paused *metav1.Condition
+	switch {
+	case ghost.Spec.Paused:
+		paused = &metav1.Condition{Reason: "SpecPaused", Message: "Reconciliation is paused by spec.paused"}
+	case ghost.Annotations[pausedAnnotation] == "true":
+		paused = &metav1.Condition{Reason: "AnnotationPaused", Message: "Reconciliation is paused by the " + pausedAnnotation + " annotation"}
+	default:
+		namespace := &corev1.Namespace{}
+		if err := r.Get(ctx, client.ObjectKey{Name: ghost.Namespace}, namespace); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		if namespace.Annotations[pausedAnnotation] == "true" {
+			paused = &metav1.Condition{Reason: "NamespacePaused", Message: "Reconciliation is paused by the " + pausedAnnotation + " annotation of namespace " + ghost.Namespace}
+		}
+	}
+	value := 0.0
+	if paused != nil {
+		value = 1
+		paused.Type = reconciliationPausedCondition
+		paused.Status = metav1.ConditionTrue
+		paused.ObservedGeneration = ghost.Generation
+	}
+	ghostPaused.WithLabelValues(ghost.Namespace, ghost.Name).Set(value)
+	return paused, nil
+}
+
+// ghostsInNamespace requeues the Ghosts of a namespace once its annotations change, e.g. to resume
+// them or move them to another base domain
+func (r *GhostReconciler) ghostsInNamespace(ctx context.Context, namespace client.Object) []reconcile.Request {
+	ghosts := &marketingv1.GhostList{}
+	if err := r.List(ctx, ghosts, client.InNamespace(namespace.GetName())); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(ghosts.Items))
+	for _, ghost := range ghosts.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ghost)})
+	}
+	return requests
+}
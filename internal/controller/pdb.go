@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const pdbNamePrefix = "ghost-pdb-"
+
+// safeToEvictAnnotation tells the cluster autoscaler whether it may evict a pod to scale down a node
+const safeToEvictAnnotation = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+
+// evictionProtected reports whether the only replica of the Ghost must not be evicted
+func evictionProtected(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.EvictionProtection && ghost.Spec.Replicas == 1
+}
+
+// maxReplicas is the most pods the Ghost runs, the autoscaler's ceiling when it owns the count
+func maxReplicas(ghost *marketingv1.Ghost) int32 {
+	if _, _, capped := unsafeReplicas(ghost); capped {
+		return 1
+	}
+	if autoscaled(ghost) {
+		return ghost.Spec.Autoscaling.MaxReplicas
+	}
+	return ghost.Spec.Replicas
+}
+
+// pdbWanted reports whether the Ghost pods need a PodDisruptionBudget, a single replica
+// only gets one when eviction protection asks for it
+func pdbWanted(ghost *marketingv1.Ghost) bool {
+	return evictionProtected(ghost) || maxReplicas(ghost) > 1
+}
+
+// pdbChild manages the PodDisruptionBudget guarding the Ghost pods
+type pdbChild struct{}
+
+func (pdbChild) Kind() string {
+	return "PodDisruptionBudget"
+}
+
+func (pdbChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !pdbWanted(ghost) {
+		return nil, nil
+	}
+	return generateDesiredPDB(ghost), nil
+}
+
+func (pdbChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, pdbNamePrefix), &policyv1.PodDisruptionBudget{})
+}
+
+func (pdbChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// generateDesiredPDB pins the only replica of an eviction protected Ghost, and otherwise
+// applies spec.podDisruptionBudget, defaulting to one unavailable pod at a time
+func generateDesiredPDB(ghost *marketingv1.Ghost) *policyv1.PodDisruptionBudget {
+	var minAvailable, maxUnavailable *intstr.IntOrString
+	switch spec := ghost.Spec.PodDisruptionBudget; {
+	case evictionProtected(ghost):
+		minAvailable = ptr.To(intstr.FromInt32(1))
+	case spec != nil && (spec.MinAvailable != nil || spec.MaxUnavailable != nil):
+		minAvailable, maxUnavailable = spec.MinAvailable, spec.MaxUnavailable
+	default:
+		maxUnavailable = ptr.To(intstr.FromInt32(1))
+	}
+	return &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{APIVersion: "policy/v1", Kind: "PodDisruptionBudget"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, pdbNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable:   minAvailable,
+			MaxUnavailable: maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": appLabel(ghost),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// GhostContainerName is the Ghost container of the Deployment template, the one kubectl ghost logs reads
+const GhostContainerName = "ghost"
+
+// imagePullFailedCondition is only present while a Ghost pod cannot pull an image
+const imagePullFailedCondition = "ImagePullFailed"
+
+// degradedCondition is only present while a Ghost pod is failing, whatever the cause
+const degradedCondition = "Degraded"
+
+// imagePullReasons are the kubelet waiting reasons of a container whose image cannot be pulled
+var imagePullReasons = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+	"InvalidImageName": true,
+}
+
+// containerFailureReasons are the kubelet waiting reasons of a container that cannot start or keeps exiting
+var containerFailureReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// podFailure is the first failing container found among the Ghost pods
+type podFailure struct {
+	// reason is the kubelet waiting reason of the container
+	reason  string
+	message string
+}
+
+// imagePull reports whether the container fails because its image cannot be pulled
+func (f *podFailure) imagePull() bool {
+	return imagePullReasons[f.reason]
+}
+
+// eventReason names the Warning event recorded on the Ghost
+func (f *podFailure) eventReason() string {
+	if f.imagePull() {
+		return "ImagePullFailed"
+	}
+	return f.reason
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// podFailure describes the first Ghost pod container that cannot pull its image, start or keep
+// running, nil when every container is healthy or still starting. The latest termination of the
+// Ghost container is recorded in the status on the way.
+func (r *GhostReconciler) podFailure(ctx context.Context, ghost *marketingv1.Ghost) (*podFailure, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(ghost.ObjectMeta.Namespace), client.MatchingLabels{"app": appLabel(ghost)}); err != nil {
+		return nil, err
+	}
+	recordLastTermination(ghost, pods.Items)
+	for _, pod := range pods.Items {
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			waiting := status.State.Waiting
+			switch {
+			case waiting == nil:
+				continue
+			case imagePullReasons[waiting.Reason]:
+				return &podFailure{
+					reason:  waiting.Reason,
+					message: fmt.Sprintf("Pod %s cannot pull image %s (%s): %s", pod.Name, status.Image, waiting.Reason, waiting.Message),
+				}, nil
+			case containerFailureReasons[waiting.Reason]:
+				message := fmt.Sprintf("Pod %s container %s is failing (%s): %s", pod.Name, status.Name, waiting.Reason, waiting.Message)
+				if terminated := status.LastTerminationState.Terminated; terminated != nil {
+					message += fmt.Sprintf(", last exit code %d (%s) after %d restarts", terminated.ExitCode, terminated.Reason, status.RestartCount)
+				}
+				return &podFailure{reason: waiting.Reason, message: message}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// recordLastTermination keeps the most recent termination of the Ghost container across the pods,
+// an older one seen later, e.g. in a pod that is still terminating, does not replace it
+func recordLastTermination(ghost *marketingv1.Ghost, pods []corev1.Pod) {
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.LastTerminationState.Terminated
+			if status.Name != GhostContainerName || terminated == nil {
+				continue
+			}
+			if last := ghost.Status.LastTermination; last != nil && !last.FinishedAt.Before(&terminated.FinishedAt) {
+				continue
+			}
+			ghost.Status.LastTermination = &marketingv1.TerminationStatus{
+				Pod:          pod.Name,
+				Reason:       terminated.Reason,
+				ExitCode:     terminated.ExitCode,
+				Message:      terminated.Message,
+				FinishedAt:   terminated.FinishedAt,
+				RestartCount: status.RestartCount,
+			}
+		}
+	}
+}
+
+// ghostsForPod enqueues the Ghost whose app label the pod carries when the pod changes
+func (r *GhostReconciler) ghostsForPod(ctx context.Context, pod client.Object) []reconcile.Request {
+	app := pod.GetLabels()["app"]
+	if !strings.HasPrefix(app, "ghost-") {
+		return nil
+	}
+	ghosts := &marketingv1.GhostList{}
+	if err := r.List(ctx, ghosts, client.InNamespace(pod.GetNamespace()), client.MatchingFields{ghostAppIndex: app}); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, ghost := range ghosts.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ghost)})
+	}
+	return requests
+}
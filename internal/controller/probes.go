@@ -0,0 +1,100 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	defaultContainerPort = 2368
+	// ghostHealthPath answers without authentication once Ghost has booted
+	ghostHealthPath = "/ghost/api/admin/site/"
+)
+
+// containerPort returns the port Ghost listens on
+func containerPort(ghost *marketingv1.Ghost) int32 {
+	if ghost.Spec.ContainerPort != 0 {
+		return ghost.Spec.ContainerPort
+	}
+	return defaultContainerPort
+}
+
+// probeDefaults are the operator's thresholds for one probe
+type probeDefaults struct {
+	InitialDelaySeconds int32
+	PeriodSeconds       int32
+	TimeoutSeconds      int32
+	FailureThreshold    int32
+}
+
+// startupProbeDefaults give Ghost five minutes to boot and run migrations
+var startupProbeDefaults = probeDefaults{PeriodSeconds: 10, TimeoutSeconds: 5, FailureThreshold: 30}
+
+// generateProbes sets the liveness, readiness and startup probes of the Ghost container
+func generateProbes(ghost *marketingv1.Ghost, container *corev1.Container) {
+	var overrides marketingv1.ProbesSpec
+	if ghost.Spec.Probes != nil {
+		overrides = *ghost.Spec.Probes
+	}
+	profile := profileFor(ghost)
+	port := containerPort(ghost)
+	path := subPath(ghost, ghostHealthPath)
+	container.LivenessProbe = generateProbe(port, path, profile.Probes, overrides.Liveness)
+	container.ReadinessProbe = generateProbe(port, path, profile.Probes, overrides.Readiness)
+	container.StartupProbe = generateProbe(port, path, startupProbeDefaults, overrides.Startup)
+}
+
+// generateProbe renders an HTTP probe with every field spelled out so server defaulting never shows up as drift
+func generateProbe(port int32, path string, defaults probeDefaults, override *marketingv1.ProbeSpec) *corev1.Probe {
+	if override != nil {
+		if override.Disabled {
+			return nil
+		}
+		if override.InitialDelaySeconds != 0 {
+			defaults.InitialDelaySeconds = override.InitialDelaySeconds
+		}
+		if override.PeriodSeconds != 0 {
+			defaults.PeriodSeconds = override.PeriodSeconds
+		}
+		if override.TimeoutSeconds != 0 {
+			defaults.TimeoutSeconds = override.TimeoutSeconds
+		}
+		if override.FailureThreshold != 0 {
+			defaults.FailureThreshold = override.FailureThreshold
+		}
+	}
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   path,
+				Port:   intstr.FromInt32(port),
+				Scheme: corev1.URISchemeHTTP,
+				// Ghost redirects plain HTTP requests when its url is https
+				HTTPHeaders: []corev1.HTTPHeader{{Name: "X-Forwarded-Proto", Value: "https"}},
+			},
+		},
+		InitialDelaySeconds: defaults.InitialDelaySeconds,
+		PeriodSeconds:       defaults.PeriodSeconds,
+		TimeoutSeconds:      defaults.TimeoutSeconds,
+		FailureThreshold:    defaults.FailureThreshold,
+		SuccessThreshold:    1,
+	}
+}
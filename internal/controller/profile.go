@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// profileDefaults is the bundle of defaults a profile selects
+type profileDefaults struct {
+	NodeEnv   string
+	Resources corev1.ResourceRequirements
+	Indexable bool
+	// Probes are the liveness and readiness thresholds
+	Probes probeDefaults
+}
+
+// standardProbes restart a hung Ghost after about 30s, development tolerates longer pauses
+var (
+	standardProbes = probeDefaults{PeriodSeconds: 10, TimeoutSeconds: 5, FailureThreshold: 3}
+	relaxedProbes  = probeDefaults{PeriodSeconds: 20, TimeoutSeconds: 10, FailureThreshold: 6}
+)
+
+// profiles maps each profile to its defaults. Instances without a profile keep the
+// template's development NODE_ENV, run without resource presets and stay indexable.
+var profiles = map[marketingv1.Profile]profileDefaults{
+	"": {
+		NodeEnv:   "development",
+		Indexable: true,
+		Probes:    standardProbes,
+	},
+	marketingv1.ProfileDevelopment: {
+		NodeEnv:   "development",
+		Resources: resourcePreset("100m", "256Mi", "512Mi"),
+		Probes:    relaxedProbes,
+	},
+	marketingv1.ProfileStaging: {
+		NodeEnv:   "production",
+		Resources: resourcePreset("250m", "512Mi", "1Gi"),
+		Probes:    standardProbes,
+	},
+	marketingv1.ProfileProduction: {
+		NodeEnv:   "production",
+		Resources: resourcePreset("500m", "1Gi", "2Gi"),
+		Indexable: true,
+		Probes:    standardProbes,
+	},
+}
+
+// resourcePreset requests cpu and memory and caps memory, cpu is left unlimited to avoid throttling
+func resourcePreset(cpu, memory, memoryLimit string) corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse(memoryLimit),
+		},
+	}
+}
+
+// profileFor returns the defaults of the Ghost's profile
+func profileFor(ghost *marketingv1.Ghost) profileDefaults {
+	return profiles[ghost.Spec.Profile]
+}
+
+// containerResources returns the explicit resources, or the profile's preset, with the
+// ephemeral storage bounds layered on top
+func containerResources(ghost *marketingv1.Ghost) corev1.ResourceRequirements {
+	resources := profileFor(ghost).Resources
+	if ghost.Spec.Resources != nil {
+		resources = *ghost.Spec.Resources
+	}
+	storage := ghost.Spec.EphemeralStorage
+	if storage == nil || (storage.Request == nil && storage.Limit == nil) {
+		return resources
+	}
+	// Copy before setting so neither the spec nor the shared preset is modified
+	resources = *resources.DeepCopy()
+	if storage.Request != nil {
+		resources.Requests = withQuantity(resources.Requests, corev1.ResourceEphemeralStorage, *storage.Request)
+	}
+	if storage.Limit != nil {
+		resources.Limits = withQuantity(resources.Limits, corev1.ResourceEphemeralStorage, *storage.Limit)
+	}
+	return resources
+}
+
+func withQuantity(list corev1.ResourceList, name corev1.ResourceName, quantity resource.Quantity) corev1.ResourceList {
+	if list == nil {
+		list = corev1.ResourceList{}
+	}
+	list[name] = quantity
+	return list
+}
+
+// scratchVolume is an emptyDir bounded by spec.ephemeralStorage.scratchSizeLimit
+func scratchVolume(ghost *marketingv1.Ghost, name string) corev1.Volume {
+	emptyDir := &corev1.EmptyDirVolumeSource{}
+	if storage := ghost.Spec.EphemeralStorage; storage != nil {
+		emptyDir.SizeLimit = storage.ScratchSizeLimit
+	}
+	return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{EmptyDir: emptyDir}}
+}
+
+// nodeEnv is the NODE_ENV of spec.environment, or the one of the profile
+func nodeEnv(ghost *marketingv1.Ghost) string {
+	if ghost.Spec.Environment != "" {
+		return string(ghost.Spec.Environment)
+	}
+	return profileFor(ghost).NodeEnv
+}
+
+// indexable reports whether search engines may index the site
+func indexable(ghost *marketingv1.Ghost) bool {
+	if ghost.Spec.Indexable != nil {
+		return *ghost.Spec.Indexable
+	}
+	return profileFor(ghost).Indexable
+}
+
+// setEnv overrides the value of an environment variable, appending it when missing
+func setEnv(env []corev1.EnvVar, name, value string) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == name {
+			env[i].Value = value
+			env[i].ValueFrom = nil
+			return env
+		}
+	}
+	return append(env, corev1.EnvVar{Name: name, Value: value})
+}
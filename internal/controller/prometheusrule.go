@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const alertRulesNamePrefix = "ghost-alerts-"
+
+// Thresholds of the per Ghost availability alerts
+const (
+	volumeFullRatio    = 0.9
+	ingressErrorsRatio = 0.05
+)
+
+var prometheusRuleGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"}
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+
+// prometheusRuleChild manages the availability alerts of a monitored Ghost
+type prometheusRuleChild struct {
+	// apiAvailable is detected at startup, see MonitoringAPIAvailable
+	apiAvailable bool
+}
+
+func (prometheusRuleChild) Kind() string {
+	return prometheusRuleGVK.Kind
+}
+
+func (c prometheusRuleChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !c.apiAvailable || monitorKind(ghost) == "" {
+		return nil, nil
+	}
+	return generateDesiredPrometheusRule(ghost), nil
+}
+
+func (prometheusRuleChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, alertRulesNamePrefix), newUnstructured(prometheusRuleGVK))
+}
+
+func (prometheusRuleChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// generateDesiredPrometheusRule alerts on the Ghost's Deployment, content volume and Ingress through
+// the kube-state-metrics, kubelet and ingress-nginx series. The alerts carry the common labels of
+// the Ghost so they route to the owning team.
+func generateDesiredPrometheusRule(ghost *marketingv1.Ghost) *unstructured.Unstructured {
+	namespace := ghost.ObjectMeta.Namespace
+	claim := contentClaimName(ghost)
+	rules := []interface{}{
+		alertRule(ghost, "GhostNoReadyReplicas", "5m", "critical",
+			readyReplicasSeries(ghost)+" == 0",
+			"Ghost "+namespace+"/"+ghost.Name+" has no ready replicas"),
+	}
+	if !ephemeral(ghost) {
+		rules = append(rules, alertRule(ghost, "GhostVolumeAlmostFull", "15m", "warning",
+			fmt.Sprintf(`kubelet_volume_stats_used_bytes{namespace=%q,persistentvolumeclaim=%q} / `+
+				`kubelet_volume_stats_capacity_bytes{namespace=%q,persistentvolumeclaim=%q} > %g`,
+				namespace, claim, namespace, claim, volumeFullRatio),
+			"The content volume of Ghost "+namespace+"/"+ghost.Name+" is {{ $value | humanizePercentage }} full"))
+	}
+	if ingressEnabled(ghost) {
+		ingress := childName(ghost, ingressNamePrefix)
+		rules = append(rules, alertRule(ghost, "GhostIngressErrorRateHigh", "10m", "warning",
+			fmt.Sprintf(`sum(rate(nginx_ingress_controller_requests{exported_namespace=%q,ingress=%q,status=~"5.."}[5m])) / `+
+				`sum(rate(nginx_ingress_controller_requests{exported_namespace=%q,ingress=%q}[5m])) > %g`,
+				namespace, ingress, namespace, ingress, ingressErrorsRatio),
+			"{{ $value | humanizePercentage }} of the requests to Ghost "+namespace+"/"+ghost.Name+" fail with a 5xx"))
+	}
+
+	rule := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"groups": []interface{}{
+					map[string]interface{}{
+						"name":  "ghost-" + namespace + "-" + ghost.Name,
+						"rules": rules,
+					},
+				},
+			},
+		},
+	}
+	rule.SetGroupVersionKind(prometheusRuleGVK)
+	rule.SetName(childName(ghost, alertRulesNamePrefix))
+	rule.SetNamespace(namespace)
+	return rule
+}
+
+// alertRule is a single alert labelled with the Ghost and its common labels
+func alertRule(ghost *marketingv1.Ghost, name, duration, severity, expr, summary string) map[string]interface{} {
+	labels := map[string]interface{}{}
+	for key, value := range ghost.Spec.CommonLabels {
+		labels[prometheusLabelName(key)] = value
+	}
+	labels["severity"] = severity
+	labels["ghost"] = ghost.Name
+	return map[string]interface{}{
+		"alert":       name,
+		"expr":        expr,
+		"for":         duration,
+		"labels":      labels,
+		"annotations": map[string]interface{}{"summary": summary},
+	}
+}
+
+// prometheusLabelName spells a Kubernetes label key as a Prometheus label name, the way
+// kube-state-metrics does, e.g. app.kubernetes.io/part-of becomes app_kubernetes_io_part_of
+func prometheusLabelName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			name[i] = '_'
+		}
+	}
+	return string(name)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// clusterNoProxy keeps in-cluster traffic, e.g. backup jobs calling the Ghost Service, off the proxy
+const clusterNoProxy = "localhost,127.0.0.1,.svc,.cluster.local"
+
+// effectiveProxy returns the Ghost's proxy, falling back to the operator wide one
+func effectiveProxy(ghost *marketingv1.Ghost, operatorProxy *marketingv1.ProxySpec) *marketingv1.ProxySpec {
+	if ghost.Spec.Proxy != nil {
+		return ghost.Spec.Proxy
+	}
+	return operatorProxy
+}
+
+// setProxyEnv sets the proxy variables on the container in both cases, tools disagree on which they read
+func setProxyEnv(container *corev1.Container, ghost *marketingv1.Ghost, proxy *marketingv1.ProxySpec) {
+	if proxy == nil || (proxy.HTTPProxy == "" && proxy.HTTPSProxy == "") {
+		return
+	}
+	noProxy := []string{clusterNoProxy, childName(ghost, svcNamePrefix)}
+	if proxy.NoProxy != "" {
+		noProxy = append(noProxy, proxy.NoProxy)
+	}
+	for _, variable := range []struct{ name, value string }{
+		{"HTTP_PROXY", proxy.HTTPProxy},
+		{"HTTPS_PROXY", proxy.HTTPSProxy},
+		{"NO_PROXY", strings.Join(noProxy, ",")},
+	} {
+		if variable.value == "" {
+			continue
+		}
+		container.Env = setEnv(container.Env, variable.name, variable.value)
+		container.Env = setEnv(container.Env, strings.ToLower(variable.name), variable.value)
+	}
+}
+
+// setInitProxyEnv lets the init containers fetching content or the storage adapter reach the
+// internet through the egress proxy, the user's own init containers are left alone
+func setInitProxyEnv(podSpec *corev1.PodSpec, ghost *marketingv1.Ghost, proxy *marketingv1.ProxySpec) {
+	for i := range podSpec.InitContainers {
+		if name := podSpec.InitContainers[i].Name; name == storageAdapterContainerName || name == contentInitContainerName {
+			setProxyEnv(&podSpec.InitContainers[i], ghost, proxy)
+		}
+	}
+}
+
+// setPodProxyEnv sets the proxy variables on every container of a job pod
+func setPodProxyEnv(podSpec *corev1.PodSpec, ghost *marketingv1.Ghost, proxy *marketingv1.ProxySpec) {
+	for i := range podSpec.InitContainers {
+		setProxyEnv(&podSpec.InitContainers[i], ghost, proxy)
+	}
+	for i := range podSpec.Containers {
+		setProxyEnv(&podSpec.Containers[i], ghost, proxy)
+	}
+}
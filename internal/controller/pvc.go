@@ -0,0 +1,158 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const pvcNamePrefix = "ghost-data-pvc-"
+
+// +kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch
+
+// pvcChild manages the PersistentVolumeClaim holding the Ghost content
+type pvcChild struct{}
+
+func (pvcChild) Kind() string {
+	return "PVC"
+}
+
+func (pvcChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if ephemeral(ghost) {
+		return nil, nil
+	}
+	pvc, err := generateDesiredPVC(ghost, contentClaimName(ghost))
+	if err != nil {
+		return nil, err
+	}
+	pvc.Labels = labelForVelero(ghost, pvc.Labels)
+	return pvc, nil
+}
+
+func (pvcChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, contentClaimName(ghost), &corev1.PersistentVolumeClaim{})
+}
+
+// Retain keeps a larger live storage request, a claim can only ever grow. It also keeps the class
+// the claim was provisioned with, a changed operator default only applies to new claims.
+func (pvcChild) Retain(desired, observed client.Object) {
+	pvc := desired.(*corev1.PersistentVolumeClaim)
+	if class := observed.(*corev1.PersistentVolumeClaim).Spec.StorageClassName; class != nil {
+		pvc.Spec.StorageClassName = class
+	}
+	size, ok := observed.(*corev1.PersistentVolumeClaim).Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok || size.Cmp(pvc.Spec.Resources.Requests[corev1.ResourceStorage]) <= 0 {
+		return
+	}
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+}
+
+// Charge counts the claim and its storage request, in total and for its StorageClass
+func (pvcChild) Charge(desired client.Object) corev1.ResourceList {
+	pvc := desired.(*corev1.PersistentVolumeClaim)
+	charge := corev1.ResourceList{corev1.ResourcePersistentVolumeClaims: resource.MustParse("1")}
+	size, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if ok {
+		charge[corev1.ResourceRequestsStorage] = size
+	}
+	if class := pvc.Spec.StorageClassName; class != nil && *class != "" {
+		prefix := *class + ".storageclass.storage.k8s.io/"
+		charge[corev1.ResourceName(prefix+string(corev1.ResourcePersistentVolumeClaims))] = resource.MustParse("1")
+		if ok {
+			charge[corev1.ResourceName(prefix+string(corev1.ResourceRequestsStorage))] = size
+		}
+	}
+	return charge
+}
+
+func (pvcChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+func generateDesiredPVC(ghost *marketingv1.Ghost, pvcName string) (*corev1.PersistentVolumeClaim, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := renderTemplate(pvcTemplate, templateParams{
+		Name:      pvcName,
+		Namespace: ghost.ObjectMeta.Namespace,
+	}, pvc)
+	if err != nil {
+		return nil, err
+	}
+	if persistence := ghost.Spec.Persistence; persistence != nil && persistence.Size != nil {
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = *persistence.Size
+	}
+	if persistence := ghost.Spec.Persistence; persistence != nil && persistence.StorageClassName != nil {
+		pvc.Spec.StorageClassName = persistence.StorageClassName
+	}
+	if persistence := ghost.Spec.Persistence; persistence != nil && len(persistence.AccessModes) > 0 {
+		pvc.Spec.AccessModes = persistence.AccessModes
+	}
+	return pvc, nil
+}
+
+// ephemeral reports whether the content lives in an emptyDir rather than a PVC
+func ephemeral(ghost *marketingv1.Ghost) bool {
+	persistence := ghost.Spec.Persistence
+	return persistence != nil && persistence.Enabled != nil && !*persistence.Enabled
+}
+
+// useEmptyDir replaces the content PVC of the pods with an emptyDir of the persistence size
+func useEmptyDir(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec) {
+	if !ephemeral(ghost) {
+		return
+	}
+	for i := range podSpec.Volumes {
+		if podSpec.Volumes[i].Name == contentVolumeName {
+			podSpec.Volumes[i].VolumeSource = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: ghost.Spec.Persistence.Size}}
+		}
+	}
+}
+
+// contentVolumeNodeAffinity returns the node affinity of the volume bound to the content PVC,
+// nil while the claim is unbound or when the volume can attach to any node
+func contentVolumeNodeAffinity(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (*corev1.NodeSelector, error) {
+	pvc, err := observeChild(ctx, c, ghost.ObjectMeta.Namespace, contentClaimName(ghost), &corev1.PersistentVolumeClaim{})
+	if err != nil || pvc == nil {
+		return nil, err
+	}
+	volumeName := pvc.(*corev1.PersistentVolumeClaim).Spec.VolumeName
+	if volumeName == "" {
+		return nil, nil
+	}
+	pv, err := observeChild(ctx, c, "", volumeName, &corev1.PersistentVolume{})
+	if err != nil || pv == nil {
+		return nil, err
+	}
+	affinity := pv.(*corev1.PersistentVolume).Spec.NodeAffinity
+	if affinity == nil || affinity.Required == nil {
+		return nil, nil
+	}
+	return affinity.Required, nil
+}
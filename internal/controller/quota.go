@@ -0,0 +1,173 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// quotaExceededCondition is only present while a ResourceQuota of the namespace keeps a child
+// of the Ghost from being created or the pods of its Deployment from starting
+const quotaExceededCondition = "QuotaExceeded"
+
+// quotaRetryInterval paces the retries of a child the quota has no room for, the ResourceQuota
+// watch brings the Ghost back sooner once a quota is raised or freed up
+const quotaRetryInterval = 5 * time.Minute
+
+// +kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;watch
+
+// quotaChargedChild is implemented by children whose creation counts against the ResourceQuotas
+// of the namespace, so the headroom is checked before they are created
+type quotaChargedChild interface {
+	// Charge returns what the desired object adds to the usage of the namespace
+	Charge(desired client.Object) corev1.ResourceList
+}
+
+// quotaExceededError marks err as a ResourceQuota without room for a child
+func quotaExceededError(err error) error {
+	return &reconcileError{class: ErrorClassQuotaExceeded, err: err}
+}
+
+// isQuotaExceeded reports whether the API server refused an object for exceeding a ResourceQuota
+func isQuotaExceeded(err error) bool {
+	return apierrors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota")
+}
+
+// checkQuota fails when a ResourceQuota of the namespace has less room left than the charge,
+// naming the quota and the resource that is short
+func checkQuota(ctx context.Context, c client.Client, namespace string, charge corev1.ResourceList) error {
+	if len(charge) == 0 {
+		return nil
+	}
+	quotas := &corev1.ResourceQuotaList{}
+	if err := c.List(ctx, quotas, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(charge))
+	for name := range charge {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, quota := range quotas.Items {
+		// A scoped quota may not apply to the child, the API server still enforces it on create
+		if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+			continue
+		}
+		for _, name := range names {
+			hard, ok := quota.Status.Hard[corev1.ResourceName(name)]
+			if !ok {
+				continue
+			}
+			left := hard.DeepCopy()
+			left.Sub(quota.Status.Used[corev1.ResourceName(name)])
+			if left.Sign() < 0 {
+				left = resource.Quantity{}
+			}
+			requested := charge[corev1.ResourceName(name)]
+			if requested.Cmp(left) > 0 {
+				return quotaExceededError(fmt.Errorf("ResourceQuota %s has %s of %s %s left, %s more is needed",
+					quota.Name, left.String(), hard.String(), name, requested.String()))
+			}
+		}
+	}
+	return nil
+}
+
+// podCharge returns what the given number of pods count against a quota. A pod requests the
+// larger of its containers together and its largest init container, as the scheduler sees it.
+func podCharge(spec *corev1.PodSpec, replicas int32) corev1.ResourceList {
+	pod := corev1.ResourceList{}
+	for _, kind := range []string{"requests", "limits"} {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			quantity := func(container corev1.Container) resource.Quantity {
+				if kind == "requests" {
+					return container.Resources.Requests[name]
+				}
+				return container.Resources.Limits[name]
+			}
+			total := resource.Quantity{}
+			for _, container := range spec.Containers {
+				total.Add(quantity(container))
+			}
+			for _, container := range spec.InitContainers {
+				if init := quantity(container); init.Cmp(total) > 0 {
+					total = init
+				}
+			}
+			if !total.IsZero() {
+				pod[corev1.ResourceName(kind+"."+string(name))] = total
+			}
+		}
+	}
+	charge := corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(int64(replicas), resource.DecimalSI)}
+	for name, quantity := range pod {
+		total := resource.Quantity{}
+		for i := int32(0); i < replicas; i++ {
+			total.Add(quantity)
+		}
+		charge[name] = total
+		// The plain cpu and memory names of a quota are the requests
+		if kind, plain, _ := strings.Cut(string(name), "."); kind == "requests" {
+			charge[corev1.ResourceName(plain)] = total
+		}
+	}
+	return charge
+}
+
+// reportQuota sets the QuotaExceeded condition from the quota failures of the children, and
+// removes it once there are none
+func (r *GhostReconciler) reportQuota(ghost *marketingv1.Ghost, failures []string) {
+	if len(failures) == 0 {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, quotaExceededCondition)
+		return
+	}
+	message := strings.Join(failures, ", ")
+	if !meta.IsStatusConditionTrue(ghost.Status.Conditions, quotaExceededCondition) {
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "QuotaExceeded", message)
+	}
+	setCondition(ghost, quotaExceededCondition, metav1.ConditionTrue, "InsufficientQuota", message)
+}
+
+// ghostsForQuota requeues the Ghosts of the namespace of a ResourceQuota, which may now have
+// room for the children held back by it
+func (r *GhostReconciler) ghostsForQuota(ctx context.Context, quota client.Object) []reconcile.Request {
+	ghosts := &marketingv1.GhostList{}
+	if err := r.List(ctx, ghosts, client.InNamespace(quota.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(ghosts.Items))
+	for _, ghost := range ghosts.Items {
+		if meta.IsStatusConditionTrue(ghost.Status.Conditions, quotaExceededCondition) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ghost)})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// reconcilingCondition is only present while a failed pass over the Ghost is being retried
+	reconcilingCondition = "Reconciling"
+	// stalledCondition is only present while the Ghost cannot be reconciled until its spec changes
+	stalledCondition = "Stalled"
+)
+
+// reportReconcileState records how the last pass over the Ghost ended in the Reconciling and
+// Stalled conditions, so a Ghost only a spec change can fix stands apart from one being retried.
+// It returns the error of the status patch, a conflict when the cached Ghost was stale.
+func (r *GhostReconciler) reportReconcileState(ctx context.Context, req ctrl.Request, err error) error {
+	ghost := &marketingv1.Ghost{}
+	if getErr := r.Get(ctx, req.NamespacedName, ghost); getErr != nil || !ghost.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	if err == nil && meta.FindStatusCondition(ghost.Status.Conditions, reconcilingCondition) == nil &&
+		meta.FindStatusCondition(ghost.Status.Conditions, stalledCondition) == nil {
+		return nil
+	}
+	// The Ghosts of other channels, installs and shards report their own state
+	if !inChannel(ghost, r.Channel) || !r.Sharding.Owns(ghost) {
+		return nil
+	}
+	if included, scopeErr := r.Scope.Includes(ctx, req.Namespace); scopeErr != nil || !included {
+		return nil
+	}
+	original := ghost.DeepCopy()
+	switch class := classifyError(err); {
+	case err == nil:
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, reconcilingCondition)
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, stalledCondition)
+	case class == ErrorClassInvalidSpec:
+		// Retrying cannot help, the Ghost waits for its spec to change
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, reconcilingCondition)
+		setCondition(ghost, stalledCondition, metav1.ConditionTrue, string(class), err.Error())
+	default:
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, stalledCondition)
+		setCondition(ghost, reconcilingCondition, metav1.ConditionTrue, string(class), err.Error())
+	}
+	if equality.Semantic.DeepEqual(original.Status, ghost.Status) {
+		return nil
+	}
+	// The cached Ghost can lag behind the status the pass just wrote, replacing the conditions
+	// of a stale copy would drop them
+	if patchErr := r.Status().Patch(ctx, ghost, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); patchErr != nil {
+		log.FromContext(ctx).Error(patchErr, "Failed to update the reconcile state of the Ghost")
+		return patchErr
+	}
+	return nil
+}
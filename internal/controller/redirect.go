@@ -0,0 +1,180 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const redirectNamePrefix = "ghost-redirect-"
+
+// redirectOfLabel marks the redirect Ingresses of a Ghost so the ones no longer wanted are found
+const redirectOfLabel = "marketing.kb.dev/redirect-of"
+
+// redirectsCondition reports redirect Ingresses the controller could not bring in sync
+const redirectsCondition = "RedirectsInSync"
+
+// permanentRedirectAnnotation makes ingress-nginx answer every request with a 301 to its value
+const permanentRedirectAnnotation = "nginx.ingress.kubernetes.io/permanent-redirect"
+
+// redirects returns the redirects the Ghost serves, with the canonical host defaulted
+func redirects(ghost *marketingv1.Ghost) []marketingv1.IngressRedirect {
+	if !ingressEnabled(ghost) || ghost.Spec.Ingress == nil {
+		return nil
+	}
+	redirects := make([]marketingv1.IngressRedirect, 0, len(ghost.Spec.Ingress.Redirects))
+	for _, redirect := range ghost.Spec.Ingress.Redirects {
+		if redirect.To == "" {
+			redirect.To = ingressHosts(ghost)[0]
+		}
+		redirects = append(redirects, redirect)
+	}
+	return redirects
+}
+
+// redirectHosts returns the hosts the Ghost redirects away from
+func redirectHosts(ghost *marketingv1.Ghost) []string {
+	var hosts []string
+	for _, redirect := range redirects(ghost) {
+		hosts = append(hosts, redirect.From)
+	}
+	return hosts
+}
+
+// redirectName is the redirect Ingress of a host, host names are valid object names
+func redirectName(ghost *marketingv1.Ghost, from string) string {
+	return childName(ghost, redirectNamePrefix) + "." + from
+}
+
+// generateRedirectIngress routes the host of a redirect to a 301 to the canonical host
+func generateRedirectIngress(ghost *marketingv1.Ghost, redirect marketingv1.IngressRedirect, wildcard *WildcardCertificate) (*netv1.Ingress, error) {
+	served := false
+	for _, host := range ingressHosts(ghost) {
+		served = served || host == redirect.To
+	}
+	if !served {
+		return nil, invalidSpecError(fmt.Errorf("spec.ingress.redirects: %s redirects to %s, which the Ghost does not serve", redirect.From, redirect.To))
+	}
+	ingressClassName := defaultIngressClassName
+	if ghost.Spec.Ingress.ClassName != "" {
+		ingressClassName = ghost.Spec.Ingress.ClassName
+	}
+	ingress := &netv1.Ingress{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        redirectName(ghost, redirect.From),
+			Namespace:   ghost.ObjectMeta.Namespace,
+			Labels:      map[string]string{redirectOfLabel: instanceName(ghost)},
+			Annotations: map[string]string{permanentRedirectAnnotation: hostURL(ghost, redirect.To, wildcard) + "$request_uri"},
+		},
+		Spec: netv1.IngressSpec{
+			IngressClassName: ptr.To(ingressClassName),
+			Rules: []netv1.IngressRule{{
+				Host: redirect.From,
+				IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{
+					Paths: []netv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: ptr.To(netv1.PathTypePrefix),
+						Backend: netv1.IngressBackend{Service: &netv1.IngressServiceBackend{
+							Name: childName(ghost, svcNamePrefix),
+							Port: netv1.ServiceBackendPort{Number: servicePort(ghost)},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+	// The redirected hosts need records as much as the ones they redirect to
+	if dns := externalDNSAnnotations(ghost, []string{redirect.From}); dns != nil {
+		ingress.Annotations = withCommon(ingress.Annotations, dns)
+	}
+	// The Certificate of the Ghost covers the redirected hosts, so https requests redirect without a warning
+	if tlsEnabled(ghost) {
+		ingress.Spec.TLS = []netv1.IngressTLS{{Hosts: []string{redirect.From}, SecretName: tlsSecretName(ghost)}}
+	}
+	return ingress, nil
+}
+
+// reconcileRedirects applies a redirect Ingress per spec.ingress.redirects entry and removes the
+// ones of redirects no longer listed
+func (r *GhostReconciler) reconcileRedirects(ctx context.Context, ghost *marketingv1.Ghost) error {
+	desired := map[string]*netv1.Ingress{}
+	for _, redirect := range redirects(ghost) {
+		ingress, err := generateRedirectIngress(ghost, redirect, r.WildcardCertificate)
+		if err != nil {
+			return err
+		}
+		desired[ingress.Name] = ingress
+	}
+	observed := &netv1.IngressList{}
+	if err := r.List(ctx, observed, client.InNamespace(ghost.Namespace), client.MatchingLabels{redirectOfLabel: instanceName(ghost)}); err != nil {
+		return err
+	}
+
+	var drift []string
+	for i := range observed.Items {
+		ingress := &observed.Items[i]
+		if _, ok := desired[ingress.Name]; ok || !metav1.IsControlledBy(ingress, ghost) {
+			continue
+		}
+		if r.readOnly(ghost) {
+			drift = append(drift, "Ingress "+ingress.Name+" would be deleted")
+			continue
+		}
+		if err := r.Delete(ctx, ingress); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	for _, ingress := range desired {
+		if r.readOnly(ghost) {
+			live, err := observeChild(ctx, r.Client, ghost.Namespace, ingress.Name, &netv1.Ingress{})
+			if err != nil {
+				return err
+			}
+			if live == nil {
+				drift = append(drift, "Ingress "+ingress.Name+" is missing and would be created")
+				continue
+			}
+			fields, err := r.childDrifted(ctx, ghost, ingress, live)
+			if err != nil {
+				return err
+			}
+			if len(fields) > 0 {
+				drift = append(drift, "Ingress "+ingress.Name+" has drifted and would be updated")
+			}
+			continue
+		}
+		if err := r.applyChild(ctx, ghost, ingress); err != nil {
+			return err
+		}
+	}
+	if len(drift) > 0 {
+		setCondition(ghost, redirectsCondition, metav1.ConditionFalse, "DriftDetected", drift[0]+", skipped in read-only mode")
+	} else {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, redirectsCondition)
+	}
+	return nil
+}
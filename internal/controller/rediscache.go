@@ -0,0 +1,294 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// cacheReachableCondition reports whether the Redis cache of a Ghost answers
+	cacheReachableCondition = "CacheReachable"
+	redisSecretNamePrefix   = "ghost-redis-"
+	defaultRedisTimeout     = 5 * time.Second
+)
+
+// redisCacheFeatures are the caches Ghost configures per feature, which would keep their own
+// adapter rather than the active one
+var redisCacheFeatures = []string{"imageSizes", "settings", "gscan", "linkRedirectsPublic", "postsPublic", "tagsPublic"}
+
+// RedisEndpoint is where and as whom a Ghost reaches Redis
+type RedisEndpoint struct {
+	Host     string
+	Port     int32
+	Username string
+	Password string
+}
+
+func (e RedisEndpoint) address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(int(e.Port)))
+}
+
+// RedisPinger checks that Redis answers with the given credentials
+type RedisPinger interface {
+	Ping(ctx context.Context, endpoint RedisEndpoint) error
+}
+
+// RedisDialer pings Redis over a plain TCP connection
+type RedisDialer struct {
+	// Timeout bounds a check, five seconds when unset
+	Timeout time.Duration
+}
+
+var _ RedisPinger = &RedisDialer{}
+
+func (d *RedisDialer) Ping(ctx context.Context, endpoint RedisEndpoint) error {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = defaultRedisTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", endpoint.address())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	reader := bufio.NewReader(conn)
+	if endpoint.Password != "" {
+		auth := []string{"AUTH", endpoint.Password}
+		if endpoint.Username != "" {
+			auth = []string{"AUTH", endpoint.Username, endpoint.Password}
+		}
+		if err := redisCommand(conn, reader, auth...); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	return redisCommand(conn, reader, "PING")
+}
+
+// redisCommand sends a command in the RESP protocol and fails on an error reply
+func redisCommand(conn io.Writer, reader *bufio.Reader, args ...string) error {
+	var request strings.Builder
+	fmt.Fprintf(&request, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(conn, request.String()); err != nil {
+		return err
+	}
+	reply, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if message, failed := strings.CutPrefix(strings.TrimSpace(reply), "-"); failed {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// parseRedisURL splits a redis://[user:password@]host[:port] URL into the endpoint it names
+func parseRedisURL(raw string) (RedisEndpoint, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return RedisEndpoint{}, errors.New("the Redis URL cannot be parsed")
+	}
+	if parsed.Scheme != "redis" {
+		return RedisEndpoint{}, fmt.Errorf("the Redis URL has scheme %q, only redis:// URLs are supported", parsed.Scheme)
+	}
+	endpoint := RedisEndpoint{Host: parsed.Hostname(), Port: 6379}
+	if endpoint.Host == "" {
+		return RedisEndpoint{}, errors.New("the Redis URL has no host")
+	}
+	if port := parsed.Port(); port != "" {
+		number, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || number == 0 {
+			return RedisEndpoint{}, fmt.Errorf("the Redis URL has an invalid port %q", port)
+		}
+		endpoint.Port = int32(number)
+	}
+	if user := parsed.User; user != nil {
+		endpoint.Username = user.Username()
+		endpoint.Password, _ = user.Password()
+	}
+	return endpoint, nil
+}
+
+func redisCache(ghost *marketingv1.Ghost) *marketingv1.RedisCacheSpec {
+	if ghost.Spec.Cache == nil {
+		return nil
+	}
+	return ghost.Spec.Cache.Redis
+}
+
+// redisKeyPrefix namespaces the cache keys, so Ghosts sharing a Redis do not read each other's entries
+func redisKeyPrefix(ghost *marketingv1.Ghost) string {
+	if prefix := redisCache(ghost).KeyPrefix; prefix != "" {
+		return prefix
+	}
+	return ghost.ObjectMeta.Namespace + ":" + ghost.Name + ":"
+}
+
+// generateRedisCacheEnv renders Ghost's adapters__cache__* settings activating the Redis adapter.
+// A URL reaches Ghost through the Secret the controller splits it into.
+func generateRedisCacheEnv(ghost *marketingv1.Ghost) []corev1.EnvVar {
+	redis := redisCache(ghost)
+	if redis == nil {
+		return nil
+	}
+	env := []corev1.EnvVar{{Name: "adapters__cache__active", Value: "Redis"}}
+	for _, feature := range redisCacheFeatures {
+		env = append(env, corev1.EnvVar{Name: "adapters__cache__" + feature + "__adapter", Value: "Redis"})
+	}
+	if redis.URLSecretRef != nil {
+		secret := corev1.LocalObjectReference{Name: childName(ghost, redisSecretNamePrefix)}
+		for _, key := range []string{"host", "port", "username", "password"} {
+			env = append(env, corev1.EnvVar{
+				Name: "adapters__cache__Redis__" + key,
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: secret,
+					Key:                  key,
+				}},
+			})
+		}
+	} else {
+		env = append(env,
+			corev1.EnvVar{Name: "adapters__cache__Redis__host", Value: redis.Host},
+			corev1.EnvVar{Name: "adapters__cache__Redis__port", Value: strconv.Itoa(int(redis.Port))},
+		)
+		if redis.PasswordSecretRef != nil {
+			env = append(env, corev1.EnvVar{
+				Name:      "adapters__cache__Redis__password",
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: redis.PasswordSecretRef},
+			})
+		}
+	}
+	env = append(env, corev1.EnvVar{Name: "adapters__cache__Redis__keyPrefix", Value: redisKeyPrefix(ghost)})
+	if redis.TTLSeconds != nil {
+		env = append(env, corev1.EnvVar{Name: "adapters__cache__Redis__ttl", Value: strconv.Itoa(int(*redis.TTLSeconds))})
+	}
+	return env
+}
+
+// redisEndpoint resolves where the Ghost reaches Redis, reading the password or the URL from its Secret
+func (r *GhostReconciler) redisEndpoint(ctx context.Context, ghost *marketingv1.Ghost, redis *marketingv1.RedisCacheSpec) (RedisEndpoint, error) {
+	if redis.URLSecretRef != nil {
+		raw, err := r.secretKey(ctx, ghost, *redis.URLSecretRef)
+		if err != nil {
+			return RedisEndpoint{}, err
+		}
+		return parseRedisURL(raw)
+	}
+	endpoint := RedisEndpoint{Host: redis.Host, Port: redis.Port}
+	if redis.PasswordSecretRef != nil {
+		password, err := r.secretKey(ctx, ghost, *redis.PasswordSecretRef)
+		if err != nil {
+			return RedisEndpoint{}, err
+		}
+		endpoint.Password = password
+	}
+	return endpoint, nil
+}
+
+// observeRedisCache splits the Redis URL of a Ghost into the Secret its pods read and reports in
+// the CacheReachable condition whether Redis answers. An unreachable cache is reported without
+// holding the rollout back, Ghost keeps serving from its database.
+func (r *GhostReconciler) observeRedisCache(ctx context.Context, ghost *marketingv1.Ghost) error {
+	redis := redisCache(ghost)
+	name := childName(ghost, redisSecretNamePrefix)
+	if redis == nil || redis.URLSecretRef == nil {
+		if err := r.deleteRedisSecret(ctx, ghost, name); err != nil {
+			return err
+		}
+	}
+	if redis == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, cacheReachableCondition)
+		return nil
+	}
+
+	endpoint, err := r.redisEndpoint(ctx, ghost, redis)
+	if err != nil {
+		setCondition(ghost, cacheReachableCondition, metav1.ConditionFalse, "InvalidConfiguration", err.Error())
+		return nil
+	}
+	if redis.URLSecretRef != nil && !r.readOnly(ghost) {
+		if err := r.applyChild(ctx, ghost, generateRedisSecret(ghost, name, endpoint)); err != nil {
+			return err
+		}
+	}
+	if r.Redis == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, cacheReachableCondition)
+		return nil
+	}
+	if err := r.Redis.Ping(ctx, endpoint); err != nil {
+		setCondition(ghost, cacheReachableCondition, metav1.ConditionFalse, "Unreachable",
+			"Redis at "+endpoint.address()+" does not answer: "+err.Error())
+		return nil
+	}
+	setCondition(ghost, cacheReachableCondition, metav1.ConditionTrue, "Reachable", "Redis answers at "+endpoint.address())
+	return nil
+}
+
+// deleteRedisSecret removes the Secret a Redis URL was split into once the Ghost no longer uses one
+func (r *GhostReconciler) deleteRedisSecret(ctx context.Context, ghost *marketingv1.Ghost, name string) error {
+	observed, err := observeChild(ctx, r.secretReader(), ghost.ObjectMeta.Namespace, name, &corev1.Secret{})
+	if err != nil || observed == nil || !metav1.IsControlledBy(observed, ghost) || r.readOnly(ghost) {
+		return err
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, observed))
+}
+
+// generateRedisSecret holds the parts of a Redis URL as the keys the pod environment selects
+func generateRedisSecret(ghost *marketingv1.Ghost, name string, endpoint RedisEndpoint) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   ghost.ObjectMeta.Namespace,
+			Labels:      withCommon(map[string]string{"app": appLabel(ghost)}, ghost.Spec.CommonLabels),
+			Annotations: withCommon(nil, ghost.Spec.CommonAnnotations),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"host":     []byte(endpoint.Host),
+			"port":     []byte(strconv.Itoa(int(endpoint.Port))),
+			"username": []byte(endpoint.Username),
+			"password": []byte(endpoint.Password),
+		},
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const updateAvailableCondition = "UpdateAvailable"
+
+// ReleaseWatcher polls the latest upstream Ghost release for the whole operator
+type ReleaseWatcher struct {
+	// URL serves the latest release as JSON with a tag_name, such as the GitHub API
+	// https://api.github.com/repos/TryGhost/Ghost/releases/latest
+	URL string
+	// Interval between two polls
+	Interval time.Duration
+	// HTTPClient performs the polls, http.DefaultClient when unset
+	HTTPClient *http.Client
+
+	mu     sync.RWMutex
+	latest *utilversion.Version
+}
+
+// Start polls the release feed until the manager stops, failed polls keep the last known release
+func (w *ReleaseWatcher) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("release-watcher")
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.poll(ctx); err != nil {
+			log.Error(err, "Failed to look up the latest Ghost release", "url", w.URL)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Latest returns the latest known release, nil until a poll succeeded
+func (w *ReleaseWatcher) Latest() *utilversion.Version {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.latest
+}
+
+func (w *ReleaseWatcher) poll(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, w.URL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	httpClient := w.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("release feed answered %s", response.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&release); err != nil {
+		return err
+	}
+	latest, err := utilversion.ParseGeneric(release.TagName)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.latest = latest
+	w.mu.Unlock()
+	return nil
+}
+
+// UpdateNotifierReconciler compares the Ghost version every instance runs to the latest release
+// and flags the outdated ones with the UpdateAvailable condition and metric
+type UpdateNotifierReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Releases *ReleaseWatcher
+	// Channel is the release channel of this operator deployment, stable when empty
+	Channel string
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts/status,verbs=get;update;patch
+
+// Reconcile sets or clears the UpdateAvailable condition and checks again after the poll interval
+func (r *UpdateNotifierReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, req.NamespacedName, ghost); err != nil {
+		if apierrors.IsNotFound(err) {
+			ghostUpdateAvailable.DeleteLabelValues(req.Namespace, req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	result := ctrl.Result{RequeueAfter: r.Releases.Interval}
+	latest := r.Releases.Latest()
+	if latest == nil {
+		return result, nil
+	}
+
+	original := ghost.DeepCopy()
+	running, current := runningVersion(ghost)
+	if current != nil && olderRelease(current, latest) {
+		ghostUpdateAvailable.WithLabelValues(ghost.Namespace, ghost.Name).Set(1)
+		message := fmt.Sprintf("Ghost %s is available, %s is running", latest, running)
+		if !meta.IsStatusConditionTrue(ghost.Status.Conditions, updateAvailableCondition) {
+			r.Recorder.Event(ghost, corev1.EventTypeNormal, "UpdateAvailable", message)
+		}
+		meta.SetStatusCondition(&ghost.Status.Conditions, metav1.Condition{
+			Type:               updateAvailableCondition,
+			Status:             metav1.ConditionTrue,
+			Reason:             "NewerRelease",
+			Message:            message,
+			ObservedGeneration: ghost.Generation,
+		})
+	} else {
+		// Floating tags such as latest or 5 follow the releases themselves
+		ghostUpdateAvailable.WithLabelValues(ghost.Namespace, ghost.Name).Set(0)
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, updateAvailableCondition)
+	}
+	if !equality.Semantic.DeepEqual(original.Status, ghost.Status) {
+		// The Ghost controller writes the conditions too, replacing them must not drop its writes
+		if err := r.Status().Patch(ctx, ghost, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{Requeue: true}, nil
+			}
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// runningVersion returns the image tag the Ghost runs and its version, nil when the tag is
+// not a version of at least major.minor or the image is pinned by digest
+func runningVersion(ghost *marketingv1.Ghost) (string, *utilversion.Version) {
+	image := ghost.Status.Image
+	if image == "" {
+		image = ghostImage(ghost)
+	}
+	tag := imageTag(image)
+	if tag == "" {
+		return image, nil
+	}
+	// Variants such as 5.96.0-alpine run the release they are named after
+	number, _, _ := strings.Cut(tag, "-")
+	version, err := utilversion.ParseGeneric(number)
+	if err != nil {
+		return tag, nil
+	}
+	return tag, version
+}
+
+// olderRelease reports whether latest is newer than current, compared only as far as current
+// goes so a minor tag such as 5.96 counts as running every 5.96 patch release
+func olderRelease(current, latest *utilversion.Version) bool {
+	latestComponents := latest.Components()
+	for i, component := range current.Components() {
+		if i >= len(latestComponents) {
+			return false
+		}
+		if component != latestComponents[i] {
+			return component < latestComponents[i]
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *UpdateNotifierReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.Ghost{}, builder.WithPredicates(channelPredicate(r.Channel))).
+		Named("ghost-update-notifier").
+		Complete(r)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Update notifier", func() {
+	const namespace = "update-notifier"
+
+	It("should flag the Ghosts running an older release than the latest", func() {
+		feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, `{"tag_name": "v5.97.1", "name": "5.97.1"}`)
+		}))
+		defer feed.Close()
+		releases := &ReleaseWatcher{URL: feed.URL, Interval: time.Hour}
+		Expect(releases.Latest()).To(BeNil())
+		Expect(releases.poll(ctx)).To(Succeed())
+		Expect(releases.Latest().String()).To(Equal("5.97.1"))
+
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		reconciler := &UpdateNotifierReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100), Releases: releases}
+		for tag, available := range map[string]bool{"5.96.0": true, "5.96.0-alpine": true, "5.97": false, "5": false, "latest": false} {
+			ghost := &marketingv1.Ghost{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "blog-", Namespace: namespace},
+				Spec:       marketingv1.GhostSpec{Image: &marketingv1.ImageSpec{Tag: tag}, Replicas: 1},
+			}
+			Expect(k8sClient.Create(ctx, ghost)).To(Succeed())
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ghost)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(time.Hour))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ghost), ghost)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(ghost.Status.Conditions, updateAvailableCondition)).To(Equal(available), tag)
+			expected := 0.0
+			if available {
+				expected = 1
+			}
+			Expect(testutil.ToFloat64(ghostUpdateAvailable.WithLabelValues(namespace, ghost.Name))).To(Equal(expected), tag)
+		}
+	})
+})
@@ -0,0 +1,122 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// A Ghost is renamed by creating the Ghost under its new name with renamedFromAnnotation set to
+// the old name. The new Ghost takes over the content PVC, its other children are created under
+// the new name, and the old Ghost is deleted together with its remaining children.
+const (
+	// renamedFromAnnotation on a new Ghost names the Ghost of its namespace it replaces
+	renamedFromAnnotation = marketingv1.RenamedFromAnnotation
+	// renamedToAnnotation marks the Ghost being replaced, the controller stops managing it
+	renamedToAnnotation = "marketing.kb.dev/renamed-to"
+	// contentClaimAnnotation pins the content PVC a renamed Ghost took over
+	contentClaimAnnotation = marketingv1.ContentClaimAnnotation
+)
+
+// contentClaimName is the content PVC of the active slot of the Ghost, named after the one it
+// took over when it was renamed
+func contentClaimName(ghost *marketingv1.Ghost) string {
+	if statefulSet(ghost) {
+		return statefulSetClaimName(ghost)
+	}
+	claim := childName(ghost, pvcNamePrefix)
+	if pinned := ghost.Annotations[contentClaimAnnotation]; pinned != "" {
+		claim = pinned
+	}
+	return slotName(claim, activeSlot(ghost))
+}
+
+// startRename marks the Ghost being replaced so its controller lets go of it and pins the
+// content PVC of that Ghost on the new one
+func (r *GhostReconciler) startRename(ctx context.Context, ghost *marketingv1.Ghost) error {
+	from := ghost.Annotations[renamedFromAnnotation]
+	if from == "" {
+		return nil
+	}
+	observed, err := observeChild(ctx, r.Client, ghost.Namespace, from, &marketingv1.Ghost{})
+	if err != nil {
+		return err
+	}
+	if observed == nil {
+		if ghost.Annotations[contentClaimAnnotation] == "" {
+			return invalidSpecError(fmt.Errorf("ghost %s/%s to rename from does not exist", ghost.Namespace, from))
+		}
+		// The rename is complete
+		return nil
+	}
+	previous := observed.(*marketingv1.Ghost)
+	if to := previous.Annotations[renamedToAnnotation]; to != "" && to != ghost.Name {
+		return invalidSpecError(fmt.Errorf("ghost %s/%s is already being renamed to %s", ghost.Namespace, from, to))
+	}
+	claim := contentClaimName(previous)
+	if r.readOnly(ghost) {
+		// Observe the content PVC the rename would take over without writing to either Ghost
+		metav1.SetMetaDataAnnotation(&ghost.ObjectMeta, contentClaimAnnotation, claim)
+		return nil
+	}
+
+	if previous.Annotations[renamedToAnnotation] == "" {
+		original := previous.DeepCopy()
+		metav1.SetMetaDataAnnotation(&previous.ObjectMeta, renamedToAnnotation, ghost.Name)
+		if err := r.Patch(ctx, previous, client.MergeFrom(original)); err != nil {
+			return err
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "RenameStarted", "Taking over from Ghost "+from)
+	}
+	if ghost.Annotations[contentClaimAnnotation] == "" {
+		original := ghost.DeepCopy()
+		metav1.SetMetaDataAnnotation(&ghost.ObjectMeta, contentClaimAnnotation, claim)
+		if err := r.Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishRename deletes the replaced Ghost once the content PVC is controlled by the new one,
+// so garbage collection removes the remaining children of the old name but not the content
+func (r *GhostReconciler) finishRename(ctx context.Context, ghost *marketingv1.Ghost) error {
+	from := ghost.Annotations[renamedFromAnnotation]
+	if from == "" || r.readOnly(ghost) {
+		return nil
+	}
+	previous, err := observeChild(ctx, r.Client, ghost.Namespace, from, &marketingv1.Ghost{})
+	if err != nil || previous == nil || !previous.GetDeletionTimestamp().IsZero() {
+		return err
+	}
+	claim, err := observeChild(ctx, r.Client, ghost.Namespace, contentClaimName(ghost), &corev1.PersistentVolumeClaim{})
+	if err != nil || claim == nil || !metav1.IsControlledBy(claim, ghost) {
+		return err
+	}
+	if err := r.Delete(ctx, previous); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	r.Recoder.Event(ghost, corev1.EventTypeNormal, "Renamed", "Ghost "+from+" replaced, its children are removed")
+	return nil
+}
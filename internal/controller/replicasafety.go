@@ -0,0 +1,96 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// unsafeReplicasCondition is set while the Ghost asks for more pods than its database can be
+// written from, and removed once it does not
+const unsafeReplicasCondition = "UnsafeReplicas"
+
+// defaultDatabaseClient is the database__client the Deployment template configures Ghost with
+const defaultDatabaseClient = "sqlite3"
+
+// databaseClient is the database__client Ghost runs with, the SQLite of the Deployment template
+// unless spec.database or spec.podTemplateOverlay sets another
+func databaseClient(ghost *marketingv1.Ghost) string {
+	template := &corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: GhostContainerName,
+		Env:  applyDatabaseEnv(ghost, []corev1.EnvVar{{Name: "database__client", Value: defaultDatabaseClient}}),
+	}}}}
+	// An overlay that does not apply fails the Deployment, which is reported on its own
+	if err := applyPodTemplateOverlay(ghost, template); err != nil {
+		return containerEnv(template.Spec.Containers, "database__client")
+	}
+	for _, container := range template.Spec.Containers {
+		if container.Name != GhostContainerName {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == "database__client" {
+				return env.Value
+			}
+		}
+	}
+	return defaultDatabaseClient
+}
+
+// unsafeReplicas explains why the pods the Ghost asks for would corrupt its content, an empty
+// reason when they do not. Every pod writes the SQLite database of the content volume, so the
+// pods sharing a ReadWriteOnce volume are capped to one. On ReadWriteMany storage the platform
+// opted into several pods, so they are only warned about.
+func unsafeReplicas(ghost *marketingv1.Ghost) (reason, message string, capped bool) {
+	pods := ghost.Spec.Replicas
+	if autoscaled(ghost) {
+		pods = ghost.Spec.Autoscaling.MaxReplicas
+	}
+	if pods <= 1 || ephemeral(ghost) || statefulSet(ghost) {
+		return "", "", false
+	}
+	if client := databaseClient(ghost); client != "sqlite3" && client != "sqlite" {
+		return "", "", false
+	}
+	if ghost.Spec.Persistence == nil || !slices.Contains(ghost.Spec.Persistence.AccessModes, corev1.ReadWriteMany) {
+		return "ReadWriteOnceVolume", fmt.Sprintf("%d pods would write the SQLite database on the ReadWriteOnce content volume "+
+			"and corrupt it, a single pod runs. Set spec.database.client to mysql to run more.", pods), true
+	}
+	return "SQLiteDatabase", fmt.Sprintf("%d pods write the SQLite database on the shared content volume, concurrent writes "+
+		"can corrupt it. Set spec.database.client to mysql or run a single pod.", pods), false
+}
+
+// observeReplicaSafety reports in the UnsafeReplicas condition why the pods the Ghost asks for
+// are capped or risk its database, with a Warning event when it turns up
+func (r *GhostReconciler) observeReplicaSafety(ghost *marketingv1.Ghost) {
+	reason, message, _ := unsafeReplicas(ghost)
+	if reason == "" {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, unsafeReplicasCondition)
+		return
+	}
+	if condition := meta.FindStatusCondition(ghost.Status.Conditions, unsafeReplicasCondition); condition == nil || condition.Reason != reason {
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, unsafeReplicasCondition, message)
+	}
+	setCondition(ghost, unsafeReplicasCondition, metav1.ConditionTrue, reason, message)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// restoreAnnotation names the GhostRestore holding a Ghost scaled down while it rewrites the content volume
+	restoreAnnotation    = "marketing.kb.dev/restore"
+	restoreJobNamePrefix = "ghost-restore-"
+)
+
+// restoreContentScript replaces the content volume with the archive
+const restoreContentScript = `set -eu
+find "$CONTENT_PATH" -mindepth 1 -delete
+tar -xzf "$ARTIFACT" -C "$CONTENT_PATH"
+`
+
+// importScript imports a JSON export through the Admin API
+const importScript = adminTokenScript + `
+async function main() {
+  const form = new FormData();
+  form.append('importfile', new Blob([require('fs').readFileSync(process.env.ARTIFACT)], {type: 'application/json'}), 'import.json');
+  const res = await fetch(process.env.GHOST_URL + '/ghost/api/admin/db/', {
+    method: 'POST',
+    body: form,
+    headers: {'X-Forwarded-Proto': 'https', Authorization: 'Ghost ' + adminToken(process.env.GHOST_ADMIN_API_KEY)},
+  });
+  if (!res.ok) {
+    console.error('Ghost import failed: HTTP ' + res.status + ' ' + await res.text());
+    process.exit(1);
+  }
+}
+main().catch((err) => {
+  console.error(err);
+  process.exit(1);
+});
+`
+
+// desiredReplicas is the Ghost's replica count, zero while a restore holds it. Autoscaled
+// Ghosts start from the autoscaler's minimum, which then takes over the count. A SQLite
+// database on a ReadWriteOnce volume is written from a single pod.
+func desiredReplicas(ghost *marketingv1.Ghost) int32 {
+	if ghost.Annotations[restoreAnnotation] != "" {
+		return 0
+	}
+	if _, _, capped := unsafeReplicas(ghost); capped {
+		return 1
+	}
+	if autoscaled(ghost) {
+		return max(ghost.Spec.Autoscaling.MinReplicas, 1)
+	}
+	return ghost.Spec.Replicas
+}
+
+// generateRestoreJob renders the Job restoring an artifact. It is downloaded by an init
+// container into a scratch volume and applied by the main container.
+func generateRestoreJob(restore *marketingv1.GhostRestore, ghost *marketingv1.Ghost, source *marketingv1.RestoreSource, adminKey *corev1.SecretKeySelector, proxy *marketingv1.ProxySpec) *batchv1.Job {
+	scratch := corev1.VolumeMount{Name: "backup", MountPath: backupDir}
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Volumes:       append([]corev1.Volume{scratchVolume(ghost, "backup")}, storageVolumes(source.BackupDestination)...),
+		InitContainers: []corev1.Container{
+			storageCopyContainer("download", source.BackupDestination, source.URL, backupArtifact, scratch),
+		},
+	}
+
+	if source.Method == marketingv1.BackupMethodExport {
+		if ghost.Spec.Image != nil {
+			podSpec.ImagePullSecrets = ghost.Spec.Image.PullSecrets
+		}
+		podSpec.Containers = []corev1.Container{{
+			Name:    "import",
+			Image:   ghostImage(ghost),
+			Command: []string{"node", "-e", importScript},
+			Env: []corev1.EnvVar{
+				{Name: "GHOST_URL", Value: fmt.Sprintf("http://%s:%d%s", childName(ghost, svcNamePrefix), servicePort(ghost), ghostPath(ghost))},
+				{Name: "GHOST_ADMIN_API_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: adminKey}},
+				{Name: "ARTIFACT", Value: backupArtifact},
+			},
+			VolumeMounts: []corev1.VolumeMount{scratch},
+		}}
+	} else {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "ghost-data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: contentClaimName(ghost),
+			}},
+		})
+		podSpec.Containers = []corev1.Container{{
+			Name:    "restore",
+			Image:   archiveImage,
+			Command: []string{"sh", "-c", restoreContentScript},
+			Env: []corev1.EnvVar{
+				{Name: "CONTENT_PATH", Value: ghostContentPath},
+				{Name: "ARTIFACT", Value: backupArtifact},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				scratch,
+				{Name: "ghost-data", MountPath: ghostContentPath},
+			},
+		}}
+	}
+
+	setPodProxyEnv(&podSpec, ghost, proxy)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      restoreJobNamePrefix + restore.Name,
+			Namespace: restore.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](2),
+			Template: corev1.PodTemplateSpec{
+				Spec: podSpec,
+			},
+		},
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// resyncPeriod is how often the Ghost is reconciled in full without any change, spec.resyncPeriod
+// over the operator wide period, zero when it is not
+func (r *GhostReconciler) resyncPeriod(ghost *marketingv1.Ghost) time.Duration {
+	if ghost.Spec.ResyncPeriod != nil {
+		return ghost.Spec.ResyncPeriod.Duration
+	}
+	return r.ResyncPeriod
+}
+
+// resyncDue reports whether a resync period passed since the last full reconcile of the Ghost
+func (r *GhostReconciler) resyncDue(ghost *marketingv1.Ghost) bool {
+	period := r.resyncPeriod(ghost)
+	last := ghost.Status.LastFullReconcileTime
+	return period > 0 && (last == nil || !time.Now().Before(last.Add(period)))
+}
+
+// untilResync is how long until the next full reconcile of the Ghost is due, zero without a resync period
+func (r *GhostReconciler) untilResync(ghost *marketingv1.Ghost) time.Duration {
+	period := r.resyncPeriod(ghost)
+	last := ghost.Status.LastFullReconcileTime
+	if period <= 0 || last == nil {
+		return period
+	}
+	// A resync that is already due runs on the next pass
+	return max(time.Until(last.Add(period)), time.Second)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// deploymentRevisionAnnotation is bumped by the Deployment controller on every pod template change
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// trackRollout records the rollout of the current Deployment revision in status.lastRollout and
+// gates it on Ghost answering its Admin API once the pods rolled out. A finished rollout keeps its
+// verdict, later failures are reported by the conditions.
+func (r *GhostReconciler) trackRollout(ctx context.Context, ghost *marketingv1.Ghost, failure *podFailure) error {
+	observed, err := observeWorkload(ctx, r.Client, ghost)
+	if err != nil || observed == nil {
+		return err
+	}
+	revision := observed.GetAnnotations()[deploymentRevisionAnnotation]
+	if statefulSet, ok := observed.(*appsv1.StatefulSet); ok {
+		// A StatefulSet names its revisions after the controller revision of the pod template
+		revision = statefulSet.Status.UpdateRevision
+	}
+	if revision == "" {
+		return nil
+	}
+	last := ghost.Status.LastRollout
+	if last == nil || last.Revision != revision {
+		last = &marketingv1.RolloutStatus{Revision: revision, StartedAt: metav1.Now()}
+		for _, container := range workloadTemplate(observed).Spec.Containers {
+			if container.Name == GhostContainerName {
+				last.Image = container.Image
+			}
+		}
+		ghost.Status.LastRollout = last
+	}
+	if last.FinishedAt != nil {
+		return nil
+	}
+
+	var failures []string
+	rollout := meta.FindStatusCondition(ghost.Status.Conditions, deploymentRolledOutCondition)
+	switch {
+	case rollout == nil:
+		return nil
+	case rollout.Status != metav1.ConditionTrue && rollout.Reason == "ProgressDeadlineExceeded":
+		failures = append(failures, rollout.Message)
+	case rollout.Status != metav1.ConditionTrue:
+		return nil
+	case failure != nil:
+		failures = append(failures, failure.message)
+	case meta.IsStatusConditionFalse(ghost.Status.Conditions, httpCheckCondition):
+		failures = append(failures, meta.FindStatusCondition(ghost.Status.Conditions, httpCheckCondition).Message)
+	case r.Sites != nil:
+		health := meta.FindStatusCondition(ghost.Status.Conditions, applicationHealthyCondition)
+		if health == nil || health.Status == metav1.ConditionUnknown {
+			return nil
+		}
+		if health.Status == metav1.ConditionFalse {
+			failures = append(failures, health.Message)
+		}
+	}
+
+	now := metav1.Now()
+	last.FinishedAt = &now
+	last.Failures = failures
+	last.Verified = len(failures) == 0
+	if last.Verified {
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "RolloutVerified", "Revision "+revision+" rolled out and passed its checks")
+	} else {
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "RolloutFailed", "Revision "+revision+" failed: "+strings.Join(failures, "; "))
+	}
+	return nil
+}
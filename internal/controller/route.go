@@ -0,0 +1,129 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const routeNamePrefix = "ghost-route-"
+
+// OpenShift is an optional platform, so Routes are handled as unstructured objects
+var routeGVK = schema.GroupVersionKind{Group: "route.openshift.io", Version: "v1", Kind: "Route"}
+
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes;routes/custom-host,verbs=get;list;watch;create;update;patch;delete
+
+// RouteAPIAvailable reports whether the cluster serves route.openshift.io/v1
+func RouteAPIAvailable(cfg *rest.Config) (bool, error) {
+	return groupVersionServed(cfg, routeGVK.GroupVersion())
+}
+
+// groupVersionServed reports whether the cluster serves an optional API
+func groupVersionServed(cfg *rest.Config, groupVersion schema.GroupVersion) (bool, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return false, err
+	}
+	if _, err := discoveryClient.ServerResourcesForGroupVersion(groupVersion.String()); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// routeEnabled reports whether the Ghost is exposed through an OpenShift Route
+func routeEnabled(ghost *marketingv1.Ghost) bool {
+	return servesHosts(ghost) && routingMode(ghost) == marketingv1.RoutingModeRoute
+}
+
+// routeChild manages the OpenShift Route exposing the Ghost Service
+type routeChild struct {
+	// apiAvailable is detected at startup, see RouteAPIAvailable
+	apiAvailable bool
+}
+
+func (routeChild) Kind() string {
+	return "Route"
+}
+
+func (c routeChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !routeEnabled(ghost) {
+		return nil, nil
+	}
+	if !c.apiAvailable {
+		return nil, invalidSpecError(errors.New("routing mode Route requested but route.openshift.io/v1 is not served by this cluster"))
+	}
+	return generateDesiredRoute(ghost), nil
+}
+
+func (routeChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, routeNamePrefix), newUnstructured(routeGVK))
+}
+
+func (routeChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// generateDesiredRoute renders a Route for the primary host, a Route only carries a single host
+func generateDesiredRoute(ghost *marketingv1.Ghost) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"host": ingressHosts(ghost)[0],
+		"to": map[string]interface{}{
+			"kind":   "Service",
+			"name":   childName(ghost, svcNamePrefix),
+			"weight": int64(100),
+		},
+		"port": map[string]interface{}{
+			"targetPort": int64(containerPort(ghost)),
+		},
+	}
+	if headless(ghost) {
+		// A Route matches a single path prefix, only the admin and APIs are served through it
+		spec["path"] = subPath(ghost, headlessPaths[0])
+	} else if prefix := ghostPath(ghost); prefix != "" {
+		spec["path"] = prefix
+	}
+	if ghost.Spec.Ingress != nil && ghost.Spec.Ingress.TLS != nil && ghost.Spec.Ingress.TLS.Enabled {
+		spec["tls"] = map[string]interface{}{
+			"termination":                   "edge",
+			"insecureEdgeTerminationPolicy": "Redirect",
+		}
+	}
+
+	route := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": spec,
+		},
+	}
+	route.SetGroupVersionKind(routeGVK)
+	route.SetName(childName(ghost, routeNamePrefix))
+	route.SetNamespace(ghost.ObjectMeta.Namespace)
+	return route
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// routingReadyCondition reports whether the Ingress or Service exposing the Ghost has an address yet
+const routingReadyCondition = "RoutingReady"
+
+// observeRouting publishes in status.addresses where the Ghost is reachable from outside the
+// cluster, read from the Ingress, or else from a LoadBalancer or NodePort Service. A Ghost
+// without hosts gets its status.url from the load balancer too, pipelines rely on it to find
+// a freshly created Ghost. The Gateway API and OpenShift routes carry no address to publish.
+func (r *GhostReconciler) observeRouting(ctx context.Context, ghost *marketingv1.Ghost) error {
+	ghost.Status.Addresses = nil
+	var exposer string
+	switch {
+	case ingressEnabled(ghost):
+		exposer = "Ingress"
+		ingress := &netv1.Ingress{}
+		observed, err := observeChild(ctx, r.Client, ghost.Namespace, childName(ghost, ingressNamePrefix), ingress)
+		if err != nil {
+			return err
+		}
+		if observed != nil {
+			for _, lb := range ingress.Status.LoadBalancer.Ingress {
+				ghost.Status.Addresses = appendLoadBalancerAddress(ghost.Status.Addresses, lb.IP, lb.Hostname)
+			}
+		}
+	case externalService(ghost) != "":
+		exposer = string(externalService(ghost)) + " Service"
+		service := &corev1.Service{}
+		observed, err := observeChild(ctx, r.Client, ghost.Namespace, childName(ghost, svcNamePrefix), service)
+		if err != nil {
+			return err
+		}
+		if observed != nil {
+			ghost.Status.Addresses = serviceAddresses(service)
+		}
+		if len(ghost.Status.Addresses) > 0 && ghost.Status.URL == "" && service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			ghost.Status.URL = loadBalancerURL(ghost, ghost.Status.Addresses[0].Value)
+		}
+	default:
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, routingReadyCondition)
+		return nil
+	}
+	if len(ghost.Status.Addresses) == 0 {
+		setCondition(ghost, routingReadyCondition, metav1.ConditionFalse, "AddressPending",
+			"Waiting for the "+exposer+" to be assigned an address")
+		return nil
+	}
+	values := make([]string, 0, len(ghost.Status.Addresses))
+	for _, address := range ghost.Status.Addresses {
+		values = append(values, address.Value)
+	}
+	setCondition(ghost, routingReadyCondition, metav1.ConditionTrue, "AddressAssigned",
+		exposer+" is reachable at "+strings.Join(values, ", "))
+	return nil
+}
+
+// externalService is the type of the Service when it is reachable from outside the cluster
+func externalService(ghost *marketingv1.Ghost) corev1.ServiceType {
+	if portForwardOnly(ghost) {
+		return ""
+	}
+	// The template exposes the Service on a node port unless spec.service says otherwise
+	if ghost.Spec.Service == nil || ghost.Spec.Service.Type == "" {
+		return corev1.ServiceTypeNodePort
+	}
+	if ghost.Spec.Service.Type == corev1.ServiceTypeClusterIP {
+		return ""
+	}
+	return ghost.Spec.Service.Type
+}
+
+// serviceAddresses are the load balancer addresses of a LoadBalancer Service and the node port
+// of a NodePort one
+func serviceAddresses(service *corev1.Service) []marketingv1.GhostAddress {
+	var addresses []marketingv1.GhostAddress
+	switch service.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		for _, lb := range service.Status.LoadBalancer.Ingress {
+			addresses = appendLoadBalancerAddress(addresses, lb.IP, lb.Hostname)
+		}
+	case corev1.ServiceTypeNodePort:
+		if len(service.Spec.Ports) > 0 && service.Spec.Ports[0].NodePort != 0 {
+			addresses = append(addresses, marketingv1.GhostAddress{
+				Type:  marketingv1.GhostAddressTypeNodePort,
+				Value: strconv.Itoa(int(service.Spec.Ports[0].NodePort)),
+			})
+		}
+	}
+	return addresses
+}
+
+// appendLoadBalancerAddress appends the IP a load balancer reports, or its hostname when it has none
+func appendLoadBalancerAddress(addresses []marketingv1.GhostAddress, ip, hostname string) []marketingv1.GhostAddress {
+	switch {
+	case ip != "":
+		return append(addresses, marketingv1.GhostAddress{Type: marketingv1.GhostAddressTypeIPAddress, Value: ip})
+	case hostname != "":
+		return append(addresses, marketingv1.GhostAddress{Type: marketingv1.GhostAddressTypeHostname, Value: hostname})
+	}
+	return addresses
+}
+
+// loadBalancerURL is the address the Service load balancer serves the Ghost at
+func loadBalancerURL(ghost *marketingv1.Ghost, address string) string {
+	host := address
+	if strings.Contains(address, ":") {
+		// An IPv6 address is bracketed in a URL
+		host = "[" + address + "]"
+	}
+	if port := servicePort(ghost); port != 80 {
+		host = fmt.Sprintf("%s:%d", host, port)
+	}
+	return "http://" + host + ghostPath(ghost)
+}
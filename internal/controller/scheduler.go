@@ -0,0 +1,198 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	schedulerCheckNamePrefix      = "ghost-scheduler-check-"
+	defaultSchedulerCheckSchedule = "*/5 * * * *"
+	defaultSchedulerGraceSeconds  = 300
+)
+
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+
+// adminTokenScript defines adminToken(key) for node scripts run from the Ghost image,
+// it signs a short lived Admin API JWT from an "<id>:<secret>" key
+const adminTokenScript = `
+const crypto = require('crypto');
+function adminToken(key) {
+  const [id, secret] = key.split(':');
+  const encode = (o) => Buffer.from(JSON.stringify(o)).toString('base64url');
+  const now = Math.floor(Date.now() / 1000);
+  const unsigned = encode({alg: 'HS256', typ: 'JWT', kid: id}) + '.' + encode({iat: now, exp: now + 300, aud: '/admin/'});
+  return unsigned + '.' + crypto.createHmac('sha256', Buffer.from(secret, 'hex')).update(unsigned).digest('base64url');
+}
+`
+
+// schedulerCheckScript runs on node from the Ghost image. It fails when Ghost is
+// unhealthy and exits 2 when scheduled posts are overdue, listing them in the job log.
+const schedulerCheckScript = adminTokenScript + `
+const base = process.env.GHOST_URL;
+const headers = {'X-Forwarded-Proto': 'https'};
+async function main() {
+  const health = await fetch(base + '/ghost/api/admin/site/', {headers});
+  if (!health.ok) {
+    console.error('Ghost health check failed: HTTP ' + health.status);
+    process.exit(1);
+  }
+  const key = process.env.GHOST_ADMIN_API_KEY;
+  if (!key) {
+    console.log('Ghost is healthy');
+    return;
+  }
+  const token = adminToken(key);
+  const cutoff = new Date(Date.now() - Number(process.env.GRACE_SECONDS) * 1000).toISOString();
+  const filter = encodeURIComponent("status:scheduled+published_at:<'" + cutoff + "'");
+  const res = await fetch(base + '/ghost/api/admin/posts/?limit=all&fields=id,title,published_at&filter=' + filter,
+    {headers: {...headers, Authorization: 'Ghost ' + token}});
+  if (!res.ok) {
+    console.error('Listing scheduled posts failed: HTTP ' + res.status);
+    process.exit(1);
+  }
+  const {posts} = await res.json();
+  for (const post of posts) {
+    console.error('Overdue scheduled post ' + post.id + ' "' + post.title + '" was due at ' + post.published_at);
+  }
+  process.exit(posts.length ? 2 : 0);
+}
+main().catch((err) => {
+  console.error(err);
+  process.exit(1);
+});
+`
+
+// schedulerCheckChild manages the CronJob watching Ghost's post scheduler
+type schedulerCheckChild struct{}
+
+func (schedulerCheckChild) Kind() string {
+	return "SchedulerCheck"
+}
+
+func (schedulerCheckChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if ghost.Spec.SchedulerCheck == nil || !ghost.Spec.SchedulerCheck.Enabled {
+		return nil, nil
+	}
+	return generateDesiredSchedulerCheck(ghost), nil
+}
+
+func (schedulerCheckChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, schedulerCheckNamePrefix), &batchv1.CronJob{})
+}
+
+// Status reports overdue scheduled posts once the most recent check failed
+func (schedulerCheckChild) Status(observed client.Object) *metav1.Condition {
+	cronJob := observed.(*batchv1.CronJob)
+	condition := &metav1.Condition{
+		Type:    "ScheduledPostsOnTime",
+		Status:  metav1.ConditionTrue,
+		Reason:  "CheckPassed",
+		Message: "The last scheduler check passed",
+	}
+	status := cronJob.Status
+	switch {
+	case status.LastScheduleTime == nil:
+		condition.Reason = "AwaitingFirstCheck"
+		condition.Message = "The scheduler check has not run yet"
+	case len(status.Active) == 0 && (status.LastSuccessfulTime == nil || status.LastSuccessfulTime.Before(status.LastScheduleTime)):
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ScheduledPostsOverdue"
+		condition.Message = fmt.Sprintf("The scheduler check scheduled at %s failed, Ghost is down or scheduled posts are overdue, see the job logs",
+			status.LastScheduleTime.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	return condition
+}
+
+func generateDesiredSchedulerCheck(ghost *marketingv1.Ghost) *batchv1.CronJob {
+	spec := ghost.Spec.SchedulerCheck
+	schedule := defaultSchedulerCheckSchedule
+	if spec.Schedule != "" {
+		schedule = spec.Schedule
+	}
+	graceSeconds := int32(defaultSchedulerGraceSeconds)
+	if spec.GraceSeconds != 0 {
+		graceSeconds = spec.GraceSeconds
+	}
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "GHOST_URL",
+			Value: fmt.Sprintf("http://%s:%d%s", childName(ghost, svcNamePrefix), servicePort(ghost), ghostPath(ghost)),
+		},
+		{
+			Name:  "GRACE_SECONDS",
+			Value: strconv.Itoa(int(graceSeconds)),
+		},
+	}
+	if spec.AdminAPIKeySecretRef != nil {
+		env = append(env, corev1.EnvVar{
+			Name:      "GHOST_ADMIN_API_KEY",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: spec.AdminAPIKeySecretRef},
+		})
+	}
+
+	var pullSecrets []corev1.LocalObjectReference
+	if ghost.Spec.Image != nil {
+		pullSecrets = ghost.Spec.Image.PullSecrets
+	}
+
+	return &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, schedulerCheckNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   schedule,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: ptr.To[int32](1),
+			FailedJobsHistoryLimit:     ptr.To[int32](1),
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					BackoffLimit:          ptr.To[int32](0),
+					ActiveDeadlineSeconds: ptr.To[int64](120),
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							RestartPolicy:    corev1.RestartPolicyNever,
+							ImagePullSecrets: pullSecrets,
+							Containers: []corev1.Container{
+								{
+									Name:    "scheduler-check",
+									Image:   ghostImage(ghost),
+									Command: []string{"node", "-e", schedulerCheckScript},
+									Env:     env,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
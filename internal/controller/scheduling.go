@@ -0,0 +1,102 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// defaultSpreadPolicy keeps replicas apart when possible without ever blocking scheduling
+const defaultSpreadPolicy = marketingv1.SpreadPolicyPreferredNodes
+
+// spreadPolicy returns the configured spread policy, falling back to the operator default
+func spreadPolicy(ghost *marketingv1.Ghost) marketingv1.SpreadPolicy {
+	if ghost.Spec.Scheduling != nil && ghost.Spec.Scheduling.SpreadPolicy != "" {
+		return ghost.Spec.Scheduling.SpreadPolicy
+	}
+	return defaultSpreadPolicy
+}
+
+// applyPodClasses sets the priority and runtime classes the Ghost pods run with
+func applyPodClasses(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec) {
+	podSpec.PriorityClassName = ghost.Spec.PriorityClassName
+	if ghost.Spec.RuntimeClassName != "" {
+		podSpec.RuntimeClassName = &ghost.Spec.RuntimeClassName
+	}
+}
+
+// applySpreadPolicy injects anti-affinity or topology spread constraints into the pod spec
+func applySpreadPolicy(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec, podLabels map[string]string) {
+	if ghost.Spec.Replicas <= 1 {
+		return
+	}
+	selector := &metav1.LabelSelector{MatchLabels: podLabels}
+
+	switch spreadPolicy(ghost) {
+	case marketingv1.SpreadPolicyPreferredNodes:
+		podSpec.Affinity = &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+					{
+						Weight: 100,
+						PodAffinityTerm: corev1.PodAffinityTerm{
+							LabelSelector: selector,
+							TopologyKey:   corev1.LabelHostname,
+						},
+					},
+				},
+			},
+		}
+	case marketingv1.SpreadPolicyRequiredNodes:
+		podSpec.Affinity = &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+					{
+						LabelSelector: selector,
+						TopologyKey:   corev1.LabelHostname,
+					},
+				},
+			},
+		}
+	case marketingv1.SpreadPolicyZones:
+		podSpec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+			{
+				MaxSkew:           1,
+				TopologyKey:       corev1.LabelTopologyZone,
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+				LabelSelector:     selector,
+			},
+		}
+	}
+}
+
+// pinToContentVolume requires the nodes the content volume can attach to, so a zonal
+// volume never leaves a rescheduled pod pending with a volume node affinity conflict
+func pinToContentVolume(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec) {
+	if ghost.Status.ContentVolumeNodeAffinity == nil {
+		return
+	}
+	if podSpec.Affinity == nil {
+		podSpec.Affinity = &corev1.Affinity{}
+	}
+	podSpec.Affinity.NodeAffinity = &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: ghost.Status.ContentVolumeNodeAffinity.DeepCopy(),
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// reflectToAnnotation on a Secret of the reflection namespace lists the namespaces it may be
+// copied to, comma separated namespace names or patterns such as team-*
+const reflectToAnnotation = "marketing.kb.dev/reflect-to"
+
+// reflectedSecretLabel marks the copies with the name of their source Secret
+const reflectedSecretLabel = "marketing.kb.dev/reflected-secret"
+
+// SecretReflectorReconciler copies the Secrets of a central namespace that allow it into the
+// namespaces running Ghosts, such as registry pull secrets or SMTP credentials, and keeps the
+// copies in sync with their source
+type SecretReflectorReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// APIReader reads and lists the copies without caching Secrets cluster wide
+	APIReader client.Reader
+	// Namespace holds the Secrets that may be reflected
+	Namespace string
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts,verbs=get;list;watch
+
+// Reconcile copies one source Secret into every allowed namespace with a Ghost and removes
+// the copies from the namespaces it no longer reaches
+func (r *SecretReflectorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	source := &corev1.Secret{}
+	if err := r.Get(ctx, req.NamespacedName, source); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+
+	targets := map[string]bool{}
+	if source.DeletionTimestamp.IsZero() && source.Annotations[reflectToAnnotation] != "" {
+		namespaces, err := r.ghostNamespaces(ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		for _, namespace := range namespaces {
+			if namespace != r.Namespace && reflectionAllowed(source.Annotations[reflectToAnnotation], namespace) {
+				targets[namespace] = true
+			}
+		}
+	}
+
+	for namespace := range targets {
+		if err := r.reflect(ctx, source, namespace); err != nil {
+			log.Error(err, "Failed to copy Secret", "namespace", namespace)
+			r.Recorder.Event(source, corev1.EventTypeWarning, "ReflectionFailed", "Failed to copy into "+namespace+": "+err.Error())
+			return resultForError(err)
+		}
+	}
+
+	// Copies live in other namespaces, so they are found by label rather than owner reference
+	copies := &corev1.SecretList{}
+	if err := r.APIReader.List(ctx, copies, client.MatchingLabels{reflectedSecretLabel: req.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range copies.Items {
+		reflected := &copies.Items[i]
+		if targets[reflected.Namespace] || reflected.Annotations[reflectedFromAnnotation] != req.String() {
+			continue
+		}
+		// The copies in the namespaces of another operator install are left to it
+		included, err := r.Scope.Includes(ctx, reflected.Namespace)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !included {
+			continue
+		}
+		if err := r.Delete(ctx, reflected); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info("Removed Secret copy", "namespace", reflected.Namespace, "name", reflected.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// reflect applies the copy of the source into the namespace, a Secret of the same name
+// the reflector did not create is never overwritten
+func (r *SecretReflectorReconciler) reflect(ctx context.Context, source *corev1.Secret, namespace string) error {
+	existing, err := observeChild(ctx, r.APIReader, namespace, source.Name, &corev1.Secret{})
+	if err != nil {
+		return err
+	}
+	from := source.Namespace + "/" + source.Name
+	if existing != nil && existing.GetAnnotations()[reflectedFromAnnotation] != from {
+		r.Recorder.Event(source, corev1.EventTypeWarning, "ReflectionSkipped", "Secret "+source.Name+" already exists in "+namespace+" and is not a copy")
+		return nil
+	}
+	reflected := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        source.Name,
+			Namespace:   namespace,
+			Labels:      map[string]string{reflectedSecretLabel: source.Name},
+			Annotations: map[string]string{reflectedFromAnnotation: from},
+		},
+		Type: source.Type,
+		Data: source.Data,
+	}
+	if err := r.Patch(ctx, reflected, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return err
+	}
+	if existing == nil {
+		r.Recorder.Event(source, corev1.EventTypeNormal, "Reflected", "Copied into "+namespace)
+	}
+	return nil
+}
+
+// ghostNamespaces returns the namespaces running at least one Ghost
+func (r *SecretReflectorReconciler) ghostNamespaces(ctx context.Context) ([]string, error) {
+	ghosts := &marketingv1.GhostList{}
+	if err := r.List(ctx, ghosts); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, ghost := range ghosts.Items {
+		if seen[ghost.Namespace] {
+			continue
+		}
+		seen[ghost.Namespace] = true
+		included, err := r.Scope.Includes(ctx, ghost.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if included {
+			namespaces = append(namespaces, ghost.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// reflectionAllowed reports whether the allowlist of a source Secret matches the namespace
+func reflectionAllowed(allowlist, namespace string) bool {
+	for _, pattern := range strings.Split(allowlist, ",") {
+		if matched, _ := path.Match(strings.TrimSpace(pattern), namespace); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sourcesForGhost re-reflects every source Secret when a Ghost appears in or leaves a namespace
+func (r *SecretReflectorReconciler) sourcesForGhost(ctx context.Context, _ client.Object) []reconcile.Request {
+	secrets := &corev1.SecretList{}
+	if err := r.List(ctx, secrets, client.InNamespace(r.Namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list the Secrets to reflect")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, secret := range secrets.Items {
+		if _, ok := secret.Annotations[reflectToAnnotation]; ok {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SecretReflectorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	inNamespace := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == r.Namespace
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Secret{}, builder.WithPredicates(inNamespace)).
+		Watches(&marketingv1.Ghost{}, handler.EnqueueRequestsFromMapFunc(r.sourcesForGhost),
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: func(event.UpdateEvent) bool { return false }})).
+		Named("secret-reflector").
+		Complete(r)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Secret reflector", func() {
+	const central = "shared-credentials"
+
+	It("should copy allowed Secrets into Ghost namespaces and keep them in sync", func() {
+		for _, name := range []string{central, "team-news", "team-docs", "finance"} {
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})).To(Succeed())
+		}
+		for _, namespace := range []string{"team-news", "team-docs", "finance"} {
+			Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+				Spec:       marketingv1.GhostSpec{ImageTag: "latest", Replicas: 1},
+			})).To(Succeed())
+		}
+		// The docs team manages its own SMTP credentials
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "smtp", Namespace: "team-docs"},
+			StringData: map[string]string{"password": "docs"},
+		})).To(Succeed())
+		source := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "smtp",
+				Namespace:   central,
+				Annotations: map[string]string{reflectToAnnotation: "team-*"},
+			},
+			Data: map[string][]byte{"password": []byte("central")},
+		}
+		Expect(k8sClient.Create(ctx, source)).To(Succeed())
+
+		reconciler := &SecretReflectorReconciler{
+			Client:    k8sClient,
+			Recorder:  record.NewFakeRecorder(100),
+			APIReader: k8sClient,
+			Namespace: central,
+		}
+		reflect := func() {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(source)})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		password := func(namespace string) string {
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "smtp"}, secret)).To(Succeed())
+			return string(secret.Data["password"])
+		}
+
+		By("copying into the allowed namespaces only, never over a Secret of the tenant")
+		reflect()
+		Expect(password("team-news")).To(Equal("central"))
+		Expect(password("team-docs")).To(Equal("docs"))
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "finance", Name: "smtp"}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		By("following a rotation of the source")
+		source.Data["password"] = []byte("rotated")
+		Expect(k8sClient.Update(ctx, source)).To(Succeed())
+		reflect()
+		Expect(password("team-news")).To(Equal("rotated"))
+
+		By("removing the copies once the allowlist no longer reaches them")
+		source.Annotations[reflectToAnnotation] = "finance"
+		Expect(k8sClient.Update(ctx, source)).To(Succeed())
+		reflect()
+		Expect(password("finance")).To(Equal("rotated"))
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: "team-news", Name: "smtp"}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(password("team-docs")).To(Equal("docs"))
+
+		By("removing every copy with the source")
+		Expect(k8sClient.Delete(ctx, source)).To(Succeed())
+		reflect()
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: "finance", Name: "smtp"}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
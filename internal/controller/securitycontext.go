@@ -0,0 +1,53 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// applySecurityContext layers the spec overrides over the template's security contexts and
+// gives every other container without one the restricted defaults, so namespaces enforcing the
+// restricted Pod Security Standard admit the pod. Containers that set their own context, such as
+// the root fix-permissions init container, are left alone.
+func applySecurityContext(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec) {
+	if ghost.Spec.PodSecurityContext != nil {
+		podSpec.SecurityContext = ghost.Spec.PodSecurityContext.DeepCopy()
+	}
+	if ghost.Spec.SecurityContext != nil {
+		podSpec.Containers[0].SecurityContext = ghost.Spec.SecurityContext.DeepCopy()
+	}
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			if containers[i].SecurityContext == nil {
+				containers[i].SecurityContext = restrictedSecurityContext()
+			}
+		}
+	}
+}
+
+// restrictedSecurityContext is the least a container needs under the restricted profile,
+// the pod security context covers the user and the seccomp profile
+func restrictedSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: ptr.To(false),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+}
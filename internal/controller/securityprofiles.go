@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// applySecurityProfiles confines every container of the pod with the spec's seccomp and AppArmor
+// profiles. Without a spec, profiles already set by a site template are kept and RuntimeDefault
+// fills the rest so hardened admission policies accept the pod.
+func applySecurityProfiles(ghost *marketingv1.Ghost, template *corev1.PodTemplateSpec) {
+	profiles := ghost.Spec.SecurityProfiles
+	if profiles == nil {
+		profiles = &marketingv1.SecurityProfilesSpec{}
+	}
+
+	podSpec := &template.Spec
+	if podSpec.SecurityContext == nil {
+		podSpec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	switch {
+	case profiles.Seccomp != nil:
+		podSpec.SecurityContext.SeccompProfile = profiles.Seccomp
+	case podSpec.SecurityContext.SeccompProfile == nil:
+		podSpec.SecurityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+
+	value := appArmorAnnotationValue(profiles.AppArmor)
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for _, container := range containers {
+			key := corev1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix + container.Name
+			if _, ok := template.Annotations[key]; ok && profiles.AppArmor == nil {
+				continue
+			}
+			template.Annotations[key] = value
+		}
+	}
+}
+
+// appArmorAnnotationValue renders the profile in the annotation syntax, RuntimeDefault when nil
+func appArmorAnnotationValue(profile *corev1.AppArmorProfile) string {
+	if profile == nil {
+		return corev1.DeprecatedAppArmorBetaProfileRuntimeDefault
+	}
+	switch profile.Type {
+	case corev1.AppArmorProfileTypeUnconfined:
+		return corev1.DeprecatedAppArmorBetaProfileNameUnconfined
+	case corev1.AppArmorProfileTypeLocalhost:
+		if profile.LocalhostProfile != nil {
+			return corev1.DeprecatedAppArmorBetaProfileNamePrefix + *profile.LocalhostProfile
+		}
+	}
+	return corev1.DeprecatedAppArmorBetaProfileRuntimeDefault
+}
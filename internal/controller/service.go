@@ -0,0 +1,183 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const svcNamePrefix = "ghost-service-"
+const defaultServicePort = 80
+
+// serviceChild manages the Service in front of the Ghost pods
+type serviceChild struct{}
+
+func (serviceChild) Kind() string {
+	return "Service"
+}
+
+func (serviceChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	return generateDesiredService(ghost)
+}
+
+func (serviceChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, svcNamePrefix), &corev1.Service{})
+}
+
+func (serviceChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+func generateDesiredService(ghost *marketingv1.Ghost) (*corev1.Service, error) {
+	service := &corev1.Service{}
+	err := renderTemplate(serviceTemplate, templateParams{
+		Name:          childName(ghost, svcNamePrefix),
+		Namespace:     ghost.ObjectMeta.Namespace,
+		AppLabel:      appLabel(ghost),
+		ServicePort:   servicePort(ghost),
+		ContainerPort: containerPort(ghost),
+	}, service)
+	if err != nil {
+		return nil, err
+	}
+
+	service.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	if spec := ghost.Spec.Service; spec != nil {
+		if spec.Type != "" {
+			service.Spec.Type = spec.Type
+		}
+		if service.Spec.Type != corev1.ServiceTypeClusterIP {
+			service.Spec.Ports[0].NodePort = spec.NodePort
+		}
+		if spec.SessionAffinity == corev1.ServiceAffinityClientIP {
+			// Spell out the API server's default timeout so it never shows up as drift
+			timeout := corev1.DefaultClientIPServiceAffinitySeconds
+			if spec.SessionAffinityTimeoutSeconds != nil {
+				timeout = *spec.SessionAffinityTimeoutSeconds
+			}
+			service.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+			service.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+				ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeout},
+			}
+		}
+		service.Annotations = spec.Annotations
+	}
+	if portForwardOnly(ghost) {
+		// Nothing outside the cluster reaches the Ghost, not even through a node port
+		service.Spec.Type = corev1.ServiceTypeClusterIP
+		service.Spec.Ports[0].NodePort = 0
+	}
+	if spec := ghost.Spec.Service; spec != nil && len(spec.ExtraPorts) > 0 {
+		// Every port of a Service with several is named
+		service.Spec.Ports[0].Name = "http"
+		for _, port := range spec.ExtraPorts {
+			service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+				Name:       port.Name,
+				Protocol:   extraPortProtocol(port),
+				Port:       port.Port,
+				TargetPort: intstr.FromInt32(extraPortTarget(port)),
+			})
+		}
+	}
+	if maintenancePageEnabled(ghost) {
+		// The maintenance page answers on the hosts of a stopped Ghost
+		service.Spec.Selector = map[string]string{"app": maintenanceAppLabel(ghost)}
+	} else if blueGreen(ghost) && ghost.Status.BlueGreen != nil {
+		// Only once the pods of the active slot are labelled as such
+		service.Spec.Selector[slotLabel] = activeSlot(ghost)
+	}
+	if monitorKind(ghost) == marketingv1.MonitorKindServiceMonitor {
+		// ServiceMonitors find their Service by label
+		service.Labels = map[string]string{"app": appLabel(ghost)}
+	}
+	if scrape := scrapeAnnotations(ghost); scrape != nil {
+		// Annotations set in spec.service win over the generated ones
+		service.Annotations = withCommon(service.Annotations, scrape)
+	}
+	if dns := externalDNSAnnotations(ghost, ingressHosts(ghost)); dns != nil && externalDNSOnService(ghost) {
+		service.Annotations = withCommon(service.Annotations, dns)
+	}
+	return service, nil
+}
+
+// extraPorts are the additional ports of spec.service
+func extraPorts(ghost *marketingv1.Ghost) []marketingv1.ServicePortSpec {
+	if ghost.Spec.Service == nil {
+		return nil
+	}
+	return ghost.Spec.Service.ExtraPorts
+}
+
+// extraPortTarget is the container port an extra port forwards to
+func extraPortTarget(port marketingv1.ServicePortSpec) int32 {
+	if port.TargetPort != 0 {
+		return port.TargetPort
+	}
+	return port.Port
+}
+
+// extraPortProtocol spells out the protocol defaulted by the API server, so exports match
+func extraPortProtocol(port marketingv1.ServicePortSpec) corev1.Protocol {
+	if port.Protocol != "" {
+		return port.Protocol
+	}
+	return corev1.ProtocolTCP
+}
+
+// declareExtraPorts declares the extra ports of the Service on the containers serving them,
+// unless they already do. The sidecars are copied from the spec, their port lists are cloned.
+func declareExtraPorts(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec) {
+	for _, port := range extraPorts(ghost) {
+		name := port.ContainerName
+		if name == "" {
+			name = GhostContainerName
+		}
+		index := slices.IndexFunc(podSpec.Containers, func(c corev1.Container) bool { return c.Name == name })
+		if index < 0 {
+			continue
+		}
+		container := &podSpec.Containers[index]
+		target, protocol := extraPortTarget(port), extraPortProtocol(port)
+		declared := slices.ContainsFunc(container.Ports, func(p corev1.ContainerPort) bool {
+			return p.ContainerPort == target && (p.Protocol == protocol || (p.Protocol == "" && protocol == corev1.ProtocolTCP))
+		})
+		if declared {
+			continue
+		}
+		container.Ports = append(slices.Clip(container.Ports), corev1.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: target,
+			Protocol:      protocol,
+		})
+	}
+}
+
+// servicePort returns the port the Service exposes, which routing backends point at
+func servicePort(ghost *marketingv1.Ghost) int32 {
+	if ghost.Spec.Service != nil && ghost.Spec.Service.Port != 0 {
+		return ghost.Spec.Service.Port
+	}
+	return defaultServicePort
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// shardLabel assigns a Ghost to the operator replica of a shard. The leader labels the Ghosts by
+// a hash of their namespace and name, so every replica reconciles a bounded share of the fleet.
+const shardLabel = "marketing.kb.dev/shard"
+
+var (
+	shardGhosts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ghost_operator_shard_ghosts",
+		Help: "Ghosts the leader assigned to the shard",
+	}, []string{"shard"})
+	shardReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ghost_operator_shard_reconcile_duration_seconds",
+		Help:    "Time the replica of the shard spent reconciling one of its Ghosts",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"shard"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(shardGhosts, shardReconcileDuration)
+}
+
+// Sharding splits the Ghosts between the replicas of an operator deployment, which then all
+// reconcile at once instead of waiting for the leader election
+type Sharding struct {
+	// Shards is the number of replicas the Ghosts are spread over
+	Shards int
+	// Shard is the one of this replica, from 0 to Shards-1
+	Shard int
+}
+
+// ParseSharding returns the sharding of a replica, nil when a single replica reconciles every Ghost
+func ParseSharding(shards, shard int) (*Sharding, error) {
+	if shards < 1 || shard < 0 || shard >= shards {
+		return nil, fmt.Errorf("shard %d is not one of %d shards", shard, shards)
+	}
+	if shards == 1 {
+		return nil, nil
+	}
+	return &Sharding{Shards: shards, Shard: shard}, nil
+}
+
+// ghostShard is the shard the Ghost hashes to
+func ghostShard(key types.NamespacedName, shards int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key.String()))
+	return int(hash.Sum32() % uint32(shards))
+}
+
+// Owns reports whether this replica reconciles the Ghost, a nil sharding every one. A Ghost
+// the leader has not labelled yet goes to the shard it hashes to, which it is labelled with next.
+func (s *Sharding) Owns(obj client.Object) bool {
+	if s == nil {
+		return true
+	}
+	if shard, err := strconv.Atoi(obj.GetLabels()[shardLabel]); err == nil {
+		return shard == s.Shard
+	}
+	return ghostShard(client.ObjectKeyFromObject(obj), s.Shards) == s.Shard
+}
+
+// name labels the metrics of the shard, a single replica is shard 0
+func (s *Sharding) name() string {
+	if s == nil {
+		return "0"
+	}
+	return strconv.Itoa(s.Shard)
+}
+
+// shardPredicate keeps the Ghost events of the other shards away from the controller. A Ghost
+// moved to another shard still reaches the replica it leaves, which then forgets about it.
+func shardPredicate(sharding *Sharding) predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return sharding.Owns(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return sharding.Owns(e.ObjectOld) || sharding.Owns(e.ObjectNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return sharding.Owns(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return sharding.Owns(e.Object) },
+	}
+}
+
+// GhostShardReconciler runs on the leader and labels every Ghost of its channel with the shard
+// it hashes to, so a change of the shard count moves the Ghosts to their new replicas
+type GhostShardReconciler struct {
+	client.Client
+	Sharding *Sharding
+	// Channel is the release channel of this operator deployment, stable when empty
+	Channel string
+	// ReadOnly only logs the Ghosts that would be labelled, they stay with the shard their
+	// current label or hash gives them
+	ReadOnly bool
+
+	mu sync.Mutex
+	// assigned is the shard of every Ghost labelled so far, counted into ghost_operator_shard_ghosts
+	assigned map[types.NamespacedName]int
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts,verbs=get;list;watch;update;patch
+
+// Reconcile labels the Ghost with its shard
+func (r *GhostShardReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, req.NamespacedName, ghost); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.assign(req.NamespacedName, -1)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	shard := ghostShard(req.NamespacedName, r.Sharding.Shards)
+	if ghost.Labels[shardLabel] != strconv.Itoa(shard) && r.ReadOnly {
+		log.FromContext(ctx).Info("Shard label skipped in read-only mode", "label", ghost.Labels[shardLabel], "shard", shard)
+		if current, err := strconv.Atoi(ghost.Labels[shardLabel]); err == nil {
+			shard = current
+		}
+		r.assign(req.NamespacedName, shard)
+		return ctrl.Result{}, nil
+	}
+	if ghost.Labels[shardLabel] != strconv.Itoa(shard) {
+		patch := client.MergeFrom(ghost.DeepCopy())
+		if ghost.Labels == nil {
+			ghost.Labels = map[string]string{}
+		}
+		ghost.Labels[shardLabel] = strconv.Itoa(shard)
+		if err := r.Patch(ctx, ghost, patch); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+	r.assign(req.NamespacedName, shard)
+	return ctrl.Result{}, nil
+}
+
+// assign records the shard of the Ghost, -1 for one that is gone, and updates the counts
+func (r *GhostShardReconciler) assign(key types.NamespacedName, shard int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.assigned == nil {
+		r.assigned = map[types.NamespacedName]int{}
+	}
+	if previous, ok := r.assigned[key]; ok {
+		shardGhosts.WithLabelValues(strconv.Itoa(previous)).Dec()
+		delete(r.assigned, key)
+	}
+	if shard >= 0 {
+		r.assigned[key] = shard
+		shardGhosts.WithLabelValues(strconv.Itoa(shard)).Inc()
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GhostShardReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("ghostshard").
+		For(&marketingv1.Ghost{}, builder.WithPredicates(channelPredicate(r.Channel), predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectOld.GetLabels()[shardLabel] != e.ObjectNew.GetLabels()[shardLabel]
+			},
+		})).
+		Complete(r)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	imageVerificationFailedCondition = "ImageVerificationFailed"
+	verifyJobNamePrefix              = "ghost-verify-"
+	cosignImage                      = "gcr.io/projectsigstore/cosign:v2.4.1"
+	// verificationAnnotation identifies the image and the signer a verification Job checks
+	verificationAnnotation = "marketing.kb.dev/verification"
+	dockerConfigDir        = "/docker"
+)
+
+// verificationHash identifies an image together with the signer it has to be signed by
+func verificationHash(image string, verify *marketingv1.SignatureVerificationSpec) string {
+	signer, _ := json.Marshal(verify)
+	return shortHash(image + "\n" + string(signer))
+}
+
+// verifySignature holds a new image back until a Job verified its cosign signature, and
+// reports whether the Deployment has to wait for it. Read-only mode deploys nothing, so it
+// verifies nothing either.
+func (r *GhostReconciler) verifySignature(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	if ghost.Spec.Image == nil || ghost.Spec.Image.VerifySignature == nil || r.readOnly(ghost) {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, imageVerificationFailedCondition)
+		return false, nil
+	}
+	image := ghostImage(ghost)
+	running, err := liveImage(ctx, r.Client, ghost)
+	if err != nil {
+		return true, err
+	}
+	if running == image {
+		return false, nil
+	}
+
+	name := childName(ghost, verifyJobNamePrefix)
+	hash := verificationHash(image, ghost.Spec.Image.VerifySignature)
+	observed, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, name, &batchv1.Job{})
+	if err != nil {
+		return true, err
+	}
+	if observed != nil && observed.GetAnnotations()[verificationAnnotation] != hash {
+		// The Job of an earlier image or signer is replaced
+		err := r.Delete(ctx, observed, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		return true, client.IgnoreNotFound(err)
+	}
+	if observed == nil {
+		job := generateVerifyJob(ghost, name, image, hash, r.Proxy)
+		if err := controllerutil.SetControllerReference(ghost, job, r.Scheme); err != nil {
+			return true, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return true, err
+		}
+		setCondition(ghost, "GhostReady", metav1.ConditionFalse, "VerifyingImage", "Job "+name+" verifies the signature of "+image)
+		return true, nil
+	}
+
+	for _, condition := range observed.(*batchv1.Job).Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			meta.RemoveStatusCondition(&ghost.Status.Conditions, imageVerificationFailedCondition)
+			return false, nil
+		case batchv1.JobFailed:
+			message := "The signature of " + image + " could not be verified, see the logs of Job " + name + ": " + condition.Message
+			if !meta.IsStatusConditionTrue(ghost.Status.Conditions, imageVerificationFailedCondition) {
+				r.Recoder.Event(ghost, corev1.EventTypeWarning, "ImageVerificationFailed", message)
+			}
+			setCondition(ghost, imageVerificationFailedCondition, metav1.ConditionTrue, "SignatureNotVerified", message)
+			setCondition(ghost, "GhostReady", metav1.ConditionFalse, "ImageVerificationFailed", message)
+			return true, nil
+		}
+	}
+	setCondition(ghost, "GhostReady", metav1.ConditionFalse, "VerifyingImage", "Job "+name+" verifies the signature of "+image)
+	return true, nil
+}
+
+// generateVerifyJob renders the Job running cosign verify against the image. The first pull
+// secret of the Ghost authenticates cosign against the registry.
+func generateVerifyJob(ghost *marketingv1.Ghost, name, image, hash string, proxy *marketingv1.ProxySpec) *batchv1.Job {
+	verify := ghost.Spec.Image.VerifySignature
+	container := corev1.Container{
+		Name:            "cosign",
+		Image:           cosignImage,
+		Args:            []string{"verify"},
+		SecurityContext: restrictedSecurityContext(),
+	}
+	if verify.PublicKeySecretRef != nil {
+		container.Args = append(container.Args, "--key", "env://COSIGN_PUBLIC_KEY")
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:      "COSIGN_PUBLIC_KEY",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: verify.PublicKeySecretRef},
+		})
+	} else {
+		container.Args = append(container.Args,
+			"--certificate-identity", verify.Keyless.Identity,
+			"--certificate-oidc-issuer", verify.Keyless.Issuer)
+	}
+	container.Args = append(container.Args, image)
+	podSpec := corev1.PodSpec{
+		RestartPolicy:   corev1.RestartPolicyNever,
+		SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: ptr.To(true)},
+	}
+	if pullSecrets := ghost.Spec.Image.PullSecrets; len(pullSecrets) > 0 {
+		podSpec.Volumes = []corev1.Volume{{
+			Name: "docker-config",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName: pullSecrets[0].Name,
+				Items:      []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: "config.json"}},
+			}},
+		}}
+		container.Env = append(container.Env, corev1.EnvVar{Name: "DOCKER_CONFIG", Value: dockerConfigDir})
+		container.VolumeMounts = []corev1.VolumeMount{{Name: "docker-config", MountPath: dockerConfigDir, ReadOnly: true}}
+	}
+	podSpec.Containers = []corev1.Container{container}
+	setPodProxyEnv(&podSpec, ghost, proxy)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   ghost.ObjectMeta.Namespace,
+			Labels:      withCommon(map[string]string{"app": appLabel(ghost)}, ghost.Spec.CommonLabels),
+			Annotations: withCommon(map[string]string{verificationAnnotation: hash}, ghost.Spec.CommonAnnotations),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](2),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: withCommon(nil, ghost.Spec.CommonLabels)},
+				Spec:       podSpec,
+			},
+		},
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+	"github.com/jiaqi-yin/ghost-controller/internal/ghostapi"
+)
+
+const (
+	// siteConfigSyncedCondition reports whether the routes and redirects of Ghost match the spec
+	siteConfigSyncedCondition = "SiteConfigSynced"
+	siteConfigVolume          = "site-config"
+	routesFile                = "routes.yaml"
+)
+
+// siteConfigUploaded reports whether spec.routes and spec.redirects are uploaded through the
+// Admin API rather than mounted
+func siteConfigUploaded(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.AdminAPIKeySecretRef != nil
+}
+
+// redirectsFile names the redirects file after its format, Ghost reads redirects.json as JSON
+func redirectsFile(source *marketingv1.SiteConfigSource) string {
+	if source == nil {
+		return "redirects.yaml"
+	}
+	json := strings.HasPrefix(strings.TrimSpace(source.Inline), "[")
+	if source.ConfigMapRef != nil {
+		json = strings.HasSuffix(source.ConfigMapRef.Key, ".json")
+	}
+	if json {
+		return "redirects.json"
+	}
+	return "redirects.yaml"
+}
+
+// mountSiteConfig mounts the ConfigMaps of spec.routes and spec.redirects where Ghost reads them
+// at startup, unless they are uploaded. The pods roll through the config checksum when they change.
+func mountSiteConfig(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec, container *corev1.Container) {
+	if siteConfigUploaded(ghost) {
+		return
+	}
+	var sources []corev1.VolumeProjection
+	mount := func(source *marketingv1.SiteConfigSource, file, dir string) {
+		if source == nil || source.ConfigMapRef == nil {
+			return
+		}
+		sources = append(sources, corev1.VolumeProjection{ConfigMap: &corev1.ConfigMapProjection{
+			LocalObjectReference: source.ConfigMapRef.LocalObjectReference,
+			Items:                []corev1.KeyToPath{{Key: source.ConfigMapRef.Key, Path: file}},
+		}})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      siteConfigVolume,
+			MountPath: ghostContentPath + "/" + dir + "/" + file,
+			SubPath:   file,
+			ReadOnly:  true,
+		})
+	}
+	mount(ghost.Spec.Routes, routesFile, "settings")
+	mount(ghost.Spec.Redirects, redirectsFile(ghost.Spec.Redirects), "data")
+	if len(sources) == 0 {
+		return
+	}
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name:         siteConfigVolume,
+		VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: sources}},
+	})
+}
+
+// mountedSiteConfigMaps are the ConfigMaps of spec.routes and spec.redirects the pods read
+func mountedSiteConfigMaps(ghost *marketingv1.Ghost) []string {
+	if siteConfigUploaded(ghost) {
+		return nil
+	}
+	var names []string
+	for _, source := range []*marketingv1.SiteConfigSource{ghost.Spec.Routes, ghost.Spec.Redirects} {
+		if source != nil && source.ConfigMapRef != nil {
+			names = append(names, source.ConfigMapRef.Name)
+		}
+	}
+	return names
+}
+
+// syncSiteConfig uploads spec.routes and spec.redirects through the Admin API once Ghost answers
+// and reports it in the SiteConfigSynced condition. The live files are compared on every pass, a
+// file changed in Ghost since it was uploaded is drift and is replaced by the declared one.
+// Removing spec.routes or spec.redirects leaves the files in Ghost as they are.
+func (r *GhostReconciler) syncSiteConfig(ctx context.Context, ghost *marketingv1.Ghost) {
+	if !siteConfigUploaded(ghost) || ghost.Spec.Routes == nil && ghost.Spec.Redirects == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, siteConfigSyncedCondition)
+		ghost.Status.SiteConfig = nil
+		return
+	}
+	if r.readOnly(ghost) {
+		return
+	}
+	if ghost.Status.ReadyReplicas == 0 || applicationUnhealthy(ghost) != "" {
+		setCondition(ghost, siteConfigSyncedCondition, metav1.ConditionUnknown, "WaitingForGhost",
+			"The routes and redirects are uploaded once Ghost answers")
+		return
+	}
+	drifted, err := r.uploadSiteConfig(ctx, ghost)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to upload the routes and redirects")
+		setCondition(ghost, siteConfigSyncedCondition, metav1.ConditionFalse, "UploadFailed", err.Error())
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "SiteConfigFailed", err.Error())
+		return
+	}
+	if len(drifted) > 0 {
+		r.Recoder.Event(ghost, corev1.EventTypeWarning, "SiteConfigDrift",
+			strings.Join(drifted, " and ")+" changed in Ghost, the declared configuration was uploaded again")
+	}
+	setCondition(ghost, siteConfigSyncedCondition, metav1.ConditionTrue, "Synced",
+		"The routes and redirects of Ghost match the spec")
+}
+
+// uploadSiteConfig uploads every declared file that differs from the live one and returns the
+// files that were changed in Ghost after they were uploaded
+func (r *GhostReconciler) uploadSiteConfig(ctx context.Context, ghost *marketingv1.Ghost) ([]string, error) {
+	key, err := r.secretKey(ctx, ghost, *ghost.Spec.AdminAPIKeySecretRef)
+	if err != nil {
+		return nil, err
+	}
+	adminURL := ghostAdminURL
+	if r.AdminURL != nil {
+		adminURL = r.AdminURL
+	}
+	api, err := ghostapi.New(adminURL(ghost), key)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &marketingv1.SiteConfigStatus{}
+	if ghost.Status.SiteConfig != nil {
+		status = ghost.Status.SiteConfig.DeepCopy()
+	}
+	redirects := redirectsFile(ghost.Spec.Redirects)
+	files := []struct {
+		source   *marketingv1.SiteConfigSource
+		name     string
+		digest   *string
+		download func(context.Context) ([]byte, error)
+		upload   func(context.Context, []byte) error
+	}{
+		{ghost.Spec.Routes, routesFile, &status.RoutesDigest, api.DownloadRoutes, api.UploadRoutes},
+		{ghost.Spec.Redirects, redirects, &status.RedirectsDigest, api.DownloadRedirects, func(ctx context.Context, data []byte) error {
+			return api.UploadRedirects(ctx, redirects, data)
+		}},
+	}
+	var drifted []string
+	for _, file := range files {
+		if file.source == nil {
+			*file.digest = ""
+			continue
+		}
+		declared, err := r.siteConfigData(ctx, ghost, file.source)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(declared)
+		digest := hex.EncodeToString(sum[:8])
+		live, err := file.download(ctx)
+		if err != nil && !ghostapi.IsNotFound(err) {
+			return nil, fmt.Errorf("downloading %s: %w", file.name, err)
+		}
+		if !bytes.Equal(bytes.TrimSpace(declared), bytes.TrimSpace(live)) {
+			if *file.digest == digest {
+				drifted = append(drifted, file.name)
+			}
+			if err := file.upload(ctx, declared); err != nil {
+				return nil, fmt.Errorf("uploading %s: %w", file.name, err)
+			}
+		}
+		*file.digest = digest
+	}
+	ghost.Status.SiteConfig = status
+	return drifted, nil
+}
+
+// siteConfigData returns the content of an inline or ConfigMap configuration file
+func (r *GhostReconciler) siteConfigData(ctx context.Context, ghost *marketingv1.Ghost, source *marketingv1.SiteConfigSource) ([]byte, error) {
+	ref := source.ConfigMapRef
+	if ref == nil {
+		return []byte(source.Inline), nil
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := r.secretReader().Get(ctx, client.ObjectKey{Namespace: ghost.ObjectMeta.Namespace, Name: ref.Name}, configMap); err != nil {
+		return nil, err
+	}
+	if data, ok := configMap.Data[ref.Key]; ok {
+		return []byte(data), nil
+	}
+	if data, ok := configMap.BinaryData[ref.Key]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("ConfigMap %s has no key %s", ref.Name, ref.Key)
+}
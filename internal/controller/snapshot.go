@@ -0,0 +1,117 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const upgradeSnapshotCondition = "UpgradeSnapshotReady"
+
+// snapshotImageAnnotation records the Ghost image running when the snapshot was taken
+const snapshotImageAnnotation = "marketing.kb.dev/image"
+
+// The CSI snapshot API is an optional dependency, so VolumeSnapshots are handled as unstructured objects
+var volumeSnapshotGVK = schema.GroupVersionKind{Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot"}
+
+// +kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create
+
+// upgradeSnapshotName is unique per target image so every upgrade gets its own snapshot
+func upgradeSnapshotName(ghost *marketingv1.Ghost, image string) string {
+	return contentClaimName(ghost) + "-pre-" + shortHash(image)
+}
+
+// snapshotBeforeUpgrade snapshots the content volume when the Ghost image is about to change
+// and reports whether the rollout has to wait for the snapshot to become ready to use
+func (r *GhostReconciler) snapshotBeforeUpgrade(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	policy := ghost.Spec.UpgradePolicy
+	if policy == nil || !policy.SnapshotBeforeUpgrade {
+		return false, nil
+	}
+	running, err := liveImage(ctx, r.Client, ghost)
+	if err != nil || running == "" {
+		// A fresh install has nothing to snapshot
+		return false, err
+	}
+	image := ghostImage(ghost)
+	if running == image {
+		return false, nil
+	}
+
+	name := upgradeSnapshotName(ghost, image)
+	ghost.Status.PreUpgradeSnapshot = name
+	live, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, name, newUnstructured(volumeSnapshotGVK))
+	if err != nil {
+		return true, err
+	}
+	if live == nil {
+		if r.readOnly(ghost) {
+			setCondition(ghost, upgradeSnapshotCondition, metav1.ConditionFalse, "DriftDetected", "VolumeSnapshot "+name+" would be taken before upgrading to "+image+", skipped in read-only mode")
+			return true, nil
+		}
+		snapshot := generateUpgradeSnapshot(ghost, name, running, policy.VolumeSnapshotClassName)
+		if err := controllerutil.SetControllerReference(ghost, snapshot, r.Scheme); err != nil {
+			return true, err
+		}
+		if err := r.Create(ctx, snapshot); err != nil {
+			return true, err
+		}
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "UpgradeSnapshotCreated", "VolumeSnapshot "+name+" created before upgrading to "+image)
+		setCondition(ghost, upgradeSnapshotCondition, metav1.ConditionFalse, "SnapshotPending", "Waiting for VolumeSnapshot "+name+" before upgrading to "+image)
+		return true, nil
+	}
+
+	snapshot := live.(*unstructured.Unstructured)
+	if message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); found {
+		setCondition(ghost, upgradeSnapshotCondition, metav1.ConditionFalse, "SnapshotFailed", "VolumeSnapshot "+name+" failed, the upgrade is held: "+message)
+		return true, nil
+	}
+	if ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse"); !ready {
+		setCondition(ghost, upgradeSnapshotCondition, metav1.ConditionFalse, "SnapshotPending", "Waiting for VolumeSnapshot "+name+" before upgrading to "+image)
+		return true, nil
+	}
+	setCondition(ghost, upgradeSnapshotCondition, metav1.ConditionTrue, "SnapshotReady", "VolumeSnapshot "+name+" is ready, upgrading to "+image)
+	return false, nil
+}
+
+// generateUpgradeSnapshot renders the snapshot of the content PVC, annotated with the image
+// that wrote the content so a rollback knows which image to pin
+func generateUpgradeSnapshot(ghost *marketingv1.Ghost, name, liveImage, className string) *unstructured.Unstructured {
+	snapshot := newUnstructured(volumeSnapshotGVK)
+	snapshot.SetName(name)
+	snapshot.SetNamespace(ghost.ObjectMeta.Namespace)
+	snapshot.SetLabels(withCommon(map[string]string{"app": appLabel(ghost)}, ghost.Spec.CommonLabels))
+	snapshot.SetAnnotations(withCommon(map[string]string{snapshotImageAnnotation: liveImage}, ghost.Spec.CommonAnnotations))
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": contentClaimName(ghost),
+		},
+	}
+	if className != "" {
+		spec["volumeSnapshotClassName"] = className
+	}
+	snapshot.Object["spec"] = spec
+	return snapshot
+}
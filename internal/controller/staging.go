@@ -0,0 +1,464 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// stagingFinalizer removes the staging instance, which lives in another namespace
+	// and so cannot be garbage collected through an owner reference
+	stagingFinalizer = "marketing.kb.dev/staging"
+	// stagingOfLabel carries the UID of the production Ghost on everything created for its staging instance
+	stagingOfLabel = "marketing.kb.dev/staging-of"
+	// promoteStagingAnnotation starts a promotion whenever its value changes
+	promoteStagingAnnotation = "marketing.kb.dev/promote-staging"
+	// stagingPollInterval paces the checks on running content transfers
+	stagingPollInterval = 10 * time.Second
+	// promotionHistoryLimit is the number of promotions kept in the Ghost status
+	promotionHistoryLimit = 10
+)
+
+// StagingReconciler maintains the staging instance linked to a Ghost and promotes it on request
+type StagingReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Now is the clock used for promotion windows, time.Now when unset
+	Now func() time.Time
+	// Channel is the release channel of this operator deployment, stable when empty
+	Channel string
+	// Scope restricts the controller to the namespaces of this operator install, all when nil
+	Scope *NamespaceScope
+}
+
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghosts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostbackups,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=marketing.kb.dev,resources=ghostrestores,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;delete
+
+// Reconcile keeps the staging Ghost in line with the production spec, clones the production
+// content into it once and runs promotions requested through the promote annotation
+func (r *StagingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if included, err := r.Scope.Includes(ctx, req.Namespace); err != nil || !included {
+		return ctrl.Result{}, err
+	}
+	ghost := &marketingv1.Ghost{}
+	if err := r.Get(ctx, req.NamespacedName, ghost); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	enabled := ghost.Spec.Staging != nil && ghost.Spec.Staging.Enabled && ghost.DeletionTimestamp.IsZero()
+	if !enabled {
+		if !controllerutil.ContainsFinalizer(ghost, stagingFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if err := r.teardown(ctx, ghost); err != nil {
+			log.Error(err, "Failed to remove the staging instance")
+			return resultForError(err)
+		}
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.AddFinalizer(ghost, stagingFinalizer) {
+		if err := r.Update(ctx, ghost); err != nil {
+			return resultForError(err)
+		}
+	}
+
+	status := marketingv1.StagingStatus{}
+	if ghost.Status.Staging != nil {
+		status = *ghost.Status.Staging
+	}
+	pending, promoted, reconcileErr := r.reconcileStaging(ctx, ghost, &status)
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "Failed to reconcile the staging instance")
+		r.Recorder.Event(ghost, corev1.EventTypeWarning, "StagingFailed", reconcileErr.Error())
+	}
+
+	original := ghost.DeepCopy()
+	ghost.Status.Staging = &status
+	if promoted != nil {
+		ghost.Status.Promotions = recordPromotion(ghost.Status.Promotions, *promoted)
+	}
+	if !equality.Semantic.DeepEqual(original.Status, ghost.Status) {
+		if err := r.Status().Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+			log.Error(err, "Failed to update Ghost status")
+			return ctrl.Result{}, err
+		}
+	}
+	if reconcileErr != nil {
+		return resultForError(reconcileErr)
+	}
+	if pending {
+		return ctrl.Result{RequeueAfter: stagingPollInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileStaging converges the staging instance and reports whether a content transfer is still
+// running, along with the record of a promotion finished during this reconcile
+func (r *StagingReconciler) reconcileStaging(ctx context.Context, ghost *marketingv1.Ghost, status *marketingv1.StagingStatus) (bool, *marketingv1.PromotionRecord, error) {
+	namespace := stagingNamespace(ghost)
+	status.Namespace = namespace
+	if err := r.ensureNamespace(ctx, ghost, namespace); err != nil {
+		return false, nil, err
+	}
+	staging, err := r.ensureStagingGhost(ctx, ghost, namespace)
+	if err != nil {
+		return false, nil, err
+	}
+
+	spec := ghost.Spec.Staging
+	if spec.ContentPolicy == marketingv1.ContentPolicyClone && !status.Cloned {
+		done, err := r.transferContent(ctx, ghost.Name+"-staging-clone", ghost, staging, *spec.Transfer, true)
+		if err != nil || !done {
+			return true, nil, err
+		}
+		status.Cloned = true
+		r.Recorder.Event(ghost, corev1.EventTypeNormal, "StagingCloned", "Production content cloned into staging namespace "+namespace)
+	}
+
+	token := ghost.Annotations[promoteStagingAnnotation]
+	if token == "" || token == status.PromotedToken {
+		status.Promotion = ""
+		status.PromotionWindowStart = nil
+		return false, nil, nil
+	}
+	status.Promotion = token
+	open, windowStart, err := promotionWindowOpen(spec.PromotionWindow, r.now())
+	if err != nil {
+		return false, nil, err
+	}
+	status.PromotionWindowStart = nil
+	if !open {
+		status.PromotionWindowStart = &metav1.Time{Time: windowStart}
+	}
+
+	record := marketingv1.PromotionRecord{
+		Token:  token,
+		Source: staging.Namespace + "/" + staging.Name,
+		Target: ghost.Namespace + "/" + ghost.Name,
+	}
+	if spec.Transfer != nil {
+		// A restore that already started is seen through even when the window closed meanwhile
+		record.Backup = ghost.Name + "-promote-" + shortHash(token)
+		done, err := r.transferContent(ctx, record.Backup, staging, ghost, *spec.Transfer, open)
+		if err != nil || !done {
+			return true, nil, err
+		}
+	} else if !open {
+		return true, nil, nil
+	}
+	if spec.Image != nil {
+		// The image tried on staging becomes the production image
+		original := ghost.DeepCopy()
+		ghost.Spec.Image = spec.Image
+		ghost.Spec.Staging.Image = nil
+		if err := r.Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+			return true, nil, err
+		}
+		record.Image = ghostImage(ghost)
+	}
+	record.CompletionTime = metav1.Time{Time: r.now()}
+	if err := r.recordStagingPromotion(ctx, staging, record); err != nil {
+		return true, nil, err
+	}
+	status.Promotion = ""
+	status.PromotionWindowStart = nil
+	status.PromotedToken = token
+	r.Recorder.Event(ghost, corev1.EventTypeNormal, "StagingPromoted", "Staging promoted to production")
+	return false, &record, nil
+}
+
+func (r *StagingReconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// promotionWindowOpen reports whether a promotion may restore into production now,
+// and otherwise when the next window opens. Without a window promotions run right away.
+func promotionWindowOpen(window *marketingv1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	if window == nil {
+		return true, time.Time{}, nil
+	}
+	schedule, err := cron.ParseStandard(window.Schedule)
+	if err != nil {
+		return false, time.Time{}, invalidSpecError(fmt.Errorf("spec.staging.promotionWindow.schedule: %w", err))
+	}
+	duration := window.Duration.Duration
+	if duration <= 0 {
+		duration = time.Hour
+	}
+	if opened := lastScheduledTime(schedule, now.Add(-duration), now); !opened.IsZero() {
+		return true, time.Time{}, nil
+	}
+	return false, schedule.Next(now), nil
+}
+
+// recordPromotion adds the record to the history, newest first, replacing a record of the same
+// promotion left by an interrupted reconcile and dropping the oldest beyond the limit
+func recordPromotion(history []marketingv1.PromotionRecord, record marketingv1.PromotionRecord) []marketingv1.PromotionRecord {
+	records := []marketingv1.PromotionRecord{record}
+	for _, previous := range history {
+		if previous.Token == record.Token && previous.Target == record.Target {
+			continue
+		}
+		records = append(records, previous)
+	}
+	if len(records) > promotionHistoryLimit {
+		records = records[:promotionHistoryLimit]
+	}
+	return records
+}
+
+// recordStagingPromotion adds the promotion to the history of the staging Ghost
+func (r *StagingReconciler) recordStagingPromotion(ctx context.Context, staging *marketingv1.Ghost, record marketingv1.PromotionRecord) error {
+	original := staging.DeepCopy()
+	staging.Status.Promotions = recordPromotion(staging.Status.Promotions, record)
+	return r.Status().Patch(ctx, staging, client.MergeFrom(original))
+}
+
+// stagingNamespace returns the namespace of the staging Ghost
+func stagingNamespace(ghost *marketingv1.Ghost) string {
+	if ghost.Spec.Staging.Namespace != "" {
+		return ghost.Spec.Staging.Namespace
+	}
+	return ghost.Namespace + "-staging"
+}
+
+// stagingHost returns the hostname serving the staging instance
+func stagingHost(ghost *marketingv1.Ghost) string {
+	if ghost.Spec.Staging.Host != "" {
+		return ghost.Spec.Staging.Host
+	}
+	return "staging." + ingressHosts(ghost)[0]
+}
+
+// stagingSpec derives the staging Ghost from production
+func stagingSpec(ghost *marketingv1.Ghost) marketingv1.GhostSpec {
+	return derivedSpec(ghost, stagingHost(ghost), ghost.Spec.Staging.Image)
+}
+
+// derivedSpec derives an instance trying out changes to production: a single replica on the
+// staging profile, served on its own host and without scheduled backups, autoscaling or a
+// staging of its own. A non-nil image replaces the production one.
+func derivedSpec(ghost *marketingv1.Ghost, host string, image *marketingv1.ImageSpec) marketingv1.GhostSpec {
+	spec := *ghost.Spec.DeepCopy()
+	spec.Staging = nil
+	spec.Backup = nil
+	spec.Autoscaling = nil
+	spec.Replicas = 1
+	spec.Profile = marketingv1.ProfileStaging
+	spec.Indexable = nil
+	// The copy is served at its own host rather than the production address
+	spec.URL = ""
+	if image != nil {
+		spec.Image = image.DeepCopy()
+	}
+	if spec.Ingress == nil {
+		spec.Ingress = &marketingv1.IngressSpec{}
+	}
+	spec.Ingress.Host = host
+	spec.Ingress.ExtraHosts = nil
+	if spec.Ingress.TLS != nil {
+		// A Secret named in production holds the certificate of the production host
+		spec.Ingress.TLS.SecretName = ""
+	}
+	return spec
+}
+
+// ensureNamespace creates the staging namespace unless it already exists
+func (r *StagingReconciler) ensureNamespace(ctx context.Context, ghost *marketingv1.Ghost, name string) error {
+	namespace := &corev1.Namespace{}
+	err := r.Get(ctx, client.ObjectKey{Name: name}, namespace)
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{stagingOfLabel: string(ghost.UID)},
+	}}
+	if err := r.Create(ctx, namespace); err != nil {
+		return err
+	}
+	r.Recorder.Event(ghost, corev1.EventTypeNormal, "StagingNamespaceCreated", "Namespace "+name+" created for the staging instance")
+	return nil
+}
+
+// ensureStagingGhost creates or updates the staging Ghost, refusing to take over one it did not create
+func (r *StagingReconciler) ensureStagingGhost(ctx context.Context, ghost *marketingv1.Ghost, namespace string) (*marketingv1.Ghost, error) {
+	staging := &marketingv1.Ghost{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ghost.Name}, staging)
+	if apierrors.IsNotFound(err) {
+		staging = &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ghost.Name,
+				Namespace: namespace,
+				Labels:    map[string]string{stagingOfLabel: string(ghost.UID)},
+			},
+			Spec: stagingSpec(ghost),
+		}
+		if err := r.Create(ctx, staging); err != nil {
+			return nil, err
+		}
+		r.Recorder.Event(ghost, corev1.EventTypeNormal, "StagingCreated", "Staging Ghost created in namespace "+namespace)
+		return staging, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if staging.Labels[stagingOfLabel] != string(ghost.UID) {
+		return nil, invalidSpecError(fmt.Errorf("ghost %s/%s already exists and is not the staging instance of this Ghost", namespace, ghost.Name))
+	}
+	if desired := stagingSpec(ghost); !equality.Semantic.DeepEqual(staging.Spec, desired) {
+		staging.Spec = desired
+		if err := r.Update(ctx, staging); err != nil {
+			return nil, err
+		}
+	}
+	return staging, nil
+}
+
+// transferContent copies the content of one Ghost into another through a volume backup and a
+// restore named after the transfer, and reports whether the restore succeeded. The restore
+// is only started when startRestore is set, the backup is taken either way.
+func (r *StagingReconciler) transferContent(ctx context.Context, name string, from, to *marketingv1.Ghost, destination marketingv1.BackupDestination, startRestore bool) (bool, error) {
+	backup := &marketingv1.GhostBackup{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: from.Namespace, Name: name}, backup)
+	if apierrors.IsNotFound(err) {
+		backup = &marketingv1.GhostBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: from.Namespace},
+			Spec: marketingv1.GhostBackupSpec{
+				GhostRef:    corev1.LocalObjectReference{Name: from.Name},
+				Method:      marketingv1.BackupMethodVolume,
+				Destination: destination,
+			},
+		}
+		if err := controllerutil.SetControllerReference(from, backup, r.Scheme); err != nil {
+			return false, err
+		}
+		return false, r.Create(ctx, backup)
+	}
+	if err != nil {
+		return false, err
+	}
+	switch backup.Status.Phase {
+	case marketingv1.BackupPhaseSucceeded:
+	case marketingv1.BackupPhaseFailed:
+		return false, externalError(fmt.Errorf("backup %s/%s failed, delete it to retry", backup.Namespace, backup.Name))
+	default:
+		return false, nil
+	}
+
+	restore := &marketingv1.GhostRestore{}
+	err = r.Get(ctx, client.ObjectKey{Namespace: to.Namespace, Name: name}, restore)
+	if apierrors.IsNotFound(err) {
+		if !startRestore {
+			return false, nil
+		}
+		source := &marketingv1.RestoreSource{Method: marketingv1.BackupMethodVolume, BackupDestination: destination}
+		source.URL = backup.Status.Location
+		restore = &marketingv1.GhostRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: to.Namespace},
+			Spec: marketingv1.GhostRestoreSpec{
+				GhostRef: corev1.LocalObjectReference{Name: to.Name},
+				Source:   source,
+			},
+		}
+		if err := controllerutil.SetControllerReference(to, restore, r.Scheme); err != nil {
+			return false, err
+		}
+		return false, r.Create(ctx, restore)
+	}
+	if err != nil {
+		return false, err
+	}
+	switch restore.Status.Phase {
+	case marketingv1.RestorePhaseSucceeded:
+		return true, nil
+	case marketingv1.RestorePhaseFailed:
+		return false, externalError(fmt.Errorf("restore %s/%s failed, delete it to retry", restore.Namespace, restore.Name))
+	default:
+		return false, nil
+	}
+}
+
+// teardown deletes the staging Ghost and the namespace created for it, then releases the Ghost
+func (r *StagingReconciler) teardown(ctx context.Context, ghost *marketingv1.Ghost) error {
+	if status := ghost.Status.Staging; status != nil && status.Namespace != "" {
+		staging := &marketingv1.Ghost{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: status.Namespace, Name: ghost.Name}, staging)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err == nil && staging.Labels[stagingOfLabel] == string(ghost.UID) {
+			if err := r.Delete(ctx, staging); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+		namespace := &corev1.Namespace{}
+		err = r.Get(ctx, client.ObjectKey{Name: status.Namespace}, namespace)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err == nil && namespace.Labels[stagingOfLabel] == string(ghost.UID) {
+			if err := r.Delete(ctx, namespace); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+		r.Recorder.Event(ghost, corev1.EventTypeNormal, "StagingDeleted", "Staging instance in namespace "+status.Namespace+" deleted")
+	}
+
+	if ghost.DeletionTimestamp.IsZero() && ghost.Status.Staging != nil {
+		original := ghost.DeepCopy()
+		ghost.Status.Staging = nil
+		if err := r.Status().Patch(ctx, ghost, client.MergeFrom(original)); err != nil {
+			return err
+		}
+	}
+	controllerutil.RemoveFinalizer(ghost, stagingFinalizer)
+	return r.Update(ctx, ghost)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *StagingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&marketingv1.Ghost{}, builder.WithPredicates(channelPredicate(r.Channel))).
+		Named("ghost-staging").
+		Complete(r)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+var _ = Describe("Staging", func() {
+	const namespace = "editorial"
+
+	It("should clone into a linked staging instance and promote it back", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		transfer := marketingv1.BackupDestination{
+			URL:                  "s3://transfer/ghost",
+			CredentialsSecretRef: corev1.LocalObjectReference{Name: "s3-credentials"},
+		}
+		Expect(k8sClient.Create(ctx, &marketingv1.Ghost{
+			ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: namespace},
+			Spec: marketingv1.GhostSpec{
+				ImageTag:      "latest",
+				Replicas:      2,
+				EnableIngress: true,
+				Ingress:       &marketingv1.IngressSpec{Host: "blog.example.com", ExtraHosts: []string{"www.example.com"}},
+				Staging: &marketingv1.StagingSpec{
+					Enabled:       true,
+					ContentPolicy: marketingv1.ContentPolicyClone,
+					Transfer:      &transfer,
+				},
+			},
+		})).To(Succeed())
+
+		// Saturday 1 June 2024, 12:00 UTC
+		now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+		reconciler := &StagingReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+			Now:      func() time.Time { return now },
+		}
+		key := types.NamespacedName{Namespace: namespace, Name: "blog"}
+		stagingKey := types.NamespacedName{Namespace: namespace + "-staging", Name: "blog"}
+		reconcileStaging := func() {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		succeedBackup := func(key types.NamespacedName, location string) {
+			backup := &marketingv1.GhostBackup{}
+			Expect(k8sClient.Get(ctx, key, backup)).To(Succeed())
+			backup.Status.Phase = marketingv1.BackupPhaseSucceeded
+			backup.Status.Location = location
+			Expect(k8sClient.Status().Update(ctx, backup)).To(Succeed())
+		}
+		succeedRestore := func(key types.NamespacedName) *marketingv1.GhostRestore {
+			restore := &marketingv1.GhostRestore{}
+			Expect(k8sClient.Get(ctx, key, restore)).To(Succeed())
+			restore.Status.Phase = marketingv1.RestorePhaseSucceeded
+			Expect(k8sClient.Status().Update(ctx, restore)).To(Succeed())
+			return restore
+		}
+
+		By("creating the staging namespace and Ghost")
+		reconcileStaging()
+		staging := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, stagingKey, staging)).To(Succeed())
+		Expect(staging.Spec.Replicas).To(Equal(int32(1)))
+		Expect(staging.Spec.Profile).To(Equal(marketingv1.ProfileStaging))
+		Expect(staging.Spec.Staging).To(BeNil())
+		Expect(staging.Spec.Ingress.Host).To(Equal("staging.blog.example.com"))
+		Expect(staging.Spec.Ingress.ExtraHosts).To(BeEmpty())
+		ghost := &marketingv1.Ghost{}
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Finalizers).To(ContainElement(stagingFinalizer))
+		Expect(ghost.Status.Staging.Namespace).To(Equal(stagingKey.Namespace))
+
+		By("cloning the production content through the transfer bucket")
+		cloneKey := types.NamespacedName{Namespace: namespace, Name: "blog-staging-clone"}
+		succeedBackup(cloneKey, "s3://transfer/ghost/editorial/blog-staging-clone.tar.gz")
+		reconcileStaging()
+		restore := succeedRestore(types.NamespacedName{Namespace: stagingKey.Namespace, Name: cloneKey.Name})
+		Expect(restore.Spec.Source.URL).To(Equal("s3://transfer/ghost/editorial/blog-staging-clone.tar.gz"))
+		reconcileStaging()
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Status.Staging.Cloned).To(BeTrue())
+
+		By("snapshotting staging right away but restoring only in the promotion window")
+		ghost.Annotations = map[string]string{promoteStagingAnnotation: "release-1"}
+		ghost.Spec.Staging.Image = &marketingv1.ImageSpec{Repository: "ghost", Tag: "5.82.0"}
+		ghost.Spec.Staging.PromotionWindow = &marketingv1.MaintenanceWindow{
+			Schedule: "0 2 * * *",
+			Duration: metav1.Duration{Duration: time.Hour},
+		}
+		Expect(k8sClient.Update(ctx, ghost)).To(Succeed())
+		reconcileStaging()
+		Expect(k8sClient.Get(ctx, stagingKey, staging)).To(Succeed())
+		Expect(staging.Spec.Image.Tag).To(Equal("5.82.0"))
+		promoteName := "blog-promote-" + shortHash("release-1")
+		succeedBackup(types.NamespacedName{Namespace: stagingKey.Namespace, Name: promoteName}, "s3://transfer/ghost/editorial-staging/"+promoteName+".tar.gz")
+		reconcileStaging()
+		promoteKey := types.NamespacedName{Namespace: namespace, Name: promoteName}
+		err := k8sClient.Get(ctx, promoteKey, &marketingv1.GhostRestore{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Status.Staging.PromotionWindowStart.Time).To(BeTemporally("==", time.Date(2024, time.June, 2, 2, 0, 0, 0, time.UTC)))
+
+		By("promoting the staging content and image once the window opened")
+		now = time.Date(2024, time.June, 2, 2, 30, 0, 0, time.UTC)
+		reconcileStaging()
+		succeedRestore(promoteKey)
+		reconcileStaging()
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Spec.Image.Tag).To(Equal("5.82.0"))
+		Expect(ghost.Spec.Staging.Image).To(BeNil())
+		Expect(ghost.Status.Staging.PromotedToken).To(Equal("release-1"))
+		Expect(ghost.Status.Staging.Promotion).To(BeEmpty())
+		Expect(ghost.Status.Staging.PromotionWindowStart).To(BeNil())
+		Expect(ghost.Status.Promotions).To(HaveLen(1))
+		promotion := ghost.Status.Promotions[0]
+		Expect(promotion.Token).To(Equal("release-1"))
+		Expect(promotion.Source).To(Equal(stagingKey.String()))
+		Expect(promotion.Target).To(Equal(key.String()))
+		Expect(promotion.Backup).To(Equal(promoteName))
+		Expect(promotion.Image).To(Equal("ghost:5.82.0"))
+		Expect(k8sClient.Get(ctx, stagingKey, staging)).To(Succeed())
+		Expect(staging.Status.Promotions).To(ConsistOf(HaveField("Token", "release-1")))
+
+		By("removing the staging instance once it is disabled")
+		ghost.Spec.Staging.Enabled = false
+		Expect(k8sClient.Update(ctx, ghost)).To(Succeed())
+		reconcileStaging()
+		err = k8sClient.Get(ctx, stagingKey, staging)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(k8sClient.Get(ctx, key, ghost)).To(Succeed())
+		Expect(ghost.Finalizers).NotTo(ContainElement(stagingFinalizer))
+		Expect(ghost.Status.Staging).To(BeNil())
+	})
+})
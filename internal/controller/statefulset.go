@@ -0,0 +1,195 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	statefulSetNamePrefix = "ghost-statefulset-"
+	// contentVolumeName is the pod volume holding the content, and the claim template of a StatefulSet
+	contentVolumeName = "ghost-data"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+
+// statefulSet reports whether the Ghost pods run in a StatefulSet rather than a Deployment
+func statefulSet(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.Workload != nil && ghost.Spec.Workload.Kind == marketingv1.WorkloadKindStatefulSet
+}
+
+// workloadName is the Deployment or StatefulSet running the Ghost pods
+func workloadName(ghost *marketingv1.Ghost) string {
+	if statefulSet(ghost) {
+		return childName(ghost, statefulSetNamePrefix)
+	}
+	return deploymentName(ghost)
+}
+
+// statefulSetClaimName is the claim the StatefulSet controller gives the pod of the Ghost. The
+// PVC child creates it ahead of the pod, so the StatefulSet adopts a claim the operator can
+// still grow while volume claim templates are immutable.
+func statefulSetClaimName(ghost *marketingv1.Ghost) string {
+	return contentVolumeName + "-" + childName(ghost, statefulSetNamePrefix) + "-0"
+}
+
+// observeWorkload fetches the live Deployment or StatefulSet, nil when it does not exist
+func observeWorkload(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	if statefulSet(ghost) {
+		return observeChild(ctx, c, ghost.ObjectMeta.Namespace, workloadName(ghost), &appsv1.StatefulSet{})
+	}
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, workloadName(ghost), &appsv1.Deployment{})
+}
+
+// workloadTemplate is the pod template of a Deployment or StatefulSet
+func workloadTemplate(workload client.Object) *corev1.PodTemplateSpec {
+	switch workload := workload.(type) {
+	case *appsv1.Deployment:
+		return &workload.Spec.Template
+	case *appsv1.StatefulSet:
+		return &workload.Spec.Template
+	}
+	return nil
+}
+
+// workloadReplicas counts the pods a Deployment or StatefulSet still runs, zero before it exists
+func workloadReplicas(workload client.Object) int32 {
+	switch workload := workload.(type) {
+	case *appsv1.Deployment:
+		return workload.Status.Replicas
+	case *appsv1.StatefulSet:
+		return workload.Status.Replicas
+	}
+	return 0
+}
+
+// liveImage is the Ghost image the live workload runs, empty before it exists
+func liveImage(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (string, error) {
+	observed, err := observeWorkload(ctx, c, ghost)
+	if err != nil || observed == nil {
+		return "", err
+	}
+	containers := workloadTemplate(observed).Spec.Containers
+	if len(containers) == 0 {
+		return "", nil
+	}
+	return containers[0].Image, nil
+}
+
+// readyReplicasSeries is the kube-state-metrics series counting the ready Ghost pods
+func readyReplicasSeries(ghost *marketingv1.Ghost) string {
+	if statefulSet(ghost) {
+		return fmt.Sprintf(`kube_statefulset_status_replicas_ready{namespace=%q,statefulset=%q}`, ghost.ObjectMeta.Namespace, workloadName(ghost))
+	}
+	return fmt.Sprintf(`kube_deployment_status_replicas_ready{namespace=%q,deployment=%q}`, ghost.ObjectMeta.Namespace, workloadName(ghost))
+}
+
+// statefulSetChild manages the StatefulSet running the Ghost pod of spec.workload.kind StatefulSet
+type statefulSetChild struct {
+	// proxy is the operator wide egress proxy
+	proxy *marketingv1.ProxySpec
+}
+
+func (statefulSetChild) Kind() string {
+	return "StatefulSet"
+}
+
+// Desire renders the pod template of the Deployment into a StatefulSet, whose claim template
+// replaces the volume of the content PVC
+func (s statefulSetChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !statefulSet(ghost) {
+		return nil, nil
+	}
+	deployment, err := generateDesiredDeployment(ghost)
+	if err != nil {
+		return nil, err
+	}
+	pvc, err := generateDesiredPVC(ghost, contentVolumeName)
+	if err != nil {
+		return nil, err
+	}
+	template := deployment.Spec.Template
+	proxy := effectiveProxy(ghost, s.proxy)
+	setProxyEnv(&template.Spec.Containers[0], ghost, proxy)
+	setInitProxyEnv(&template.Spec, ghost, proxy)
+	template.Spec.Volumes = slices.DeleteFunc(template.Spec.Volumes, func(volume corev1.Volume) bool {
+		return volume.Name == contentVolumeName
+	})
+	return &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        workloadName(ghost),
+			Namespace:   ghost.ObjectMeta.Namespace,
+			Annotations: deployment.Annotations,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: deployment.Spec.Replicas,
+			Selector: deployment.Spec.Selector,
+			// The pod keeps its name, ordinal and claim, it is not looked up through DNS
+			ServiceName: childName(ghost, svcNamePrefix),
+			Template:    template,
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: contentVolumeName},
+				Spec:       pvc.Spec,
+			}},
+		},
+	}, nil
+}
+
+func (statefulSetChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, statefulSetNamePrefix), &appsv1.StatefulSet{})
+}
+
+// Retain keeps the claim template the StatefulSet was created with, it cannot change and the
+// PVC child grows the claim itself
+func (statefulSetChild) Retain(desired, observed client.Object) {
+	desired.(*appsv1.StatefulSet).Spec.VolumeClaimTemplates = observed.(*appsv1.StatefulSet).Spec.VolumeClaimTemplates
+}
+
+// Status reports whether the StatefulSet finished its ordered rollout of the current pod
+// template, the way kubectl rollout status does
+func (statefulSetChild) Status(observed client.Object) *metav1.Condition {
+	statefulSet := observed.(*appsv1.StatefulSet)
+	rollingOut := func(reason, message string) *metav1.Condition {
+		return &metav1.Condition{Type: deploymentRolledOutCondition, Status: metav1.ConditionFalse, Reason: reason, Message: message}
+	}
+	status := statefulSet.Status
+	if statefulSet.Generation > status.ObservedGeneration {
+		return rollingOut("RolloutPending", "Waiting for the StatefulSet controller to observe the new generation")
+	}
+	replicas := ptr.Deref(statefulSet.Spec.Replicas, 1)
+	switch {
+	case status.UpdatedReplicas < replicas:
+		return rollingOut("RollingOut", fmt.Sprintf("%d of %d replicas updated", status.UpdatedReplicas, replicas))
+	case status.UpdateRevision != status.CurrentRevision:
+		return rollingOut("RollingOut", "Waiting for the pods of revision "+status.CurrentRevision+" to be replaced")
+	case status.AvailableReplicas < replicas:
+		return rollingOut("RollingOut", fmt.Sprintf("%d of %d replicas available", status.AvailableReplicas, replicas))
+	}
+	return &metav1.Condition{Type: deploymentRolledOutCondition, Status: metav1.ConditionTrue, Reason: "RolledOut", Message: "StatefulSet rolled out"}
+}
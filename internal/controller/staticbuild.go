@@ -0,0 +1,352 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	staticBuildJobNamePrefix = "ghost-static-build-"
+	staticSiteNamePrefix     = "ghost-static-"
+	staticSiteDir            = "/site"
+	// staticSiteKeySeparator stands for the slashes of a file path in a ConfigMap key,
+	// which only allows alphanumerics, '-', '_' and '.'
+	staticSiteKeySeparator = ".."
+	nginxHTMLPath          = "/usr/share/nginx/html"
+)
+
+// staticBuildScript runs on node from the Ghost image. It crawls the site from its root, the
+// sitemap and robots.txt, following every link within the site, and stores each page as
+// index.html of its directory with the links made relative to the root of the host. With
+// CONFIGMAP set the files are written into the ConfigMap instead of being left for upload,
+// the images then stay on Ghost.
+const staticBuildScript = `
+const fs = require('fs');
+const path = require('path');
+const ghost = new URL(process.env.GHOST_URL + '/');
+const site = new URL(process.env.SITE_URL.replace(/\/?$/, '/'));
+const imagesPath = site.pathname + 'content/images/';
+const skipImages = process.env.CONFIGMAP !== undefined;
+const textTypes = /^(text\/|application\/(json|xml|javascript|rss\+xml))|\+xml/;
+const escape = (s) => s.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+
+function local(ref, page) {
+  let url;
+  try {
+    url = new URL(ref.trim().split(' ')[0], new URL(page, site.origin));
+  } catch {
+    return null;
+  }
+  if (url.origin !== site.origin && url.origin !== ghost.origin) return null;
+  const p = url.pathname;
+  if (!p.startsWith(site.pathname) || p.startsWith(site.pathname + 'ghost/')) return null;
+  if (skipImages && p.startsWith(imagesPath)) return null;
+  return decodeURI(p);
+}
+
+function rewrite(text) {
+  text = text.split(site.origin).join('');
+  if (skipImages) {
+    text = text.replace(new RegExp('(^|[\\s"\'(,=])' + escape(imagesPath), 'g'), '$1' + site.origin + imagesPath);
+  }
+  return text;
+}
+
+async function main() {
+  const queue = [site.pathname, site.pathname + 'sitemap.xml', site.pathname + 'robots.txt'];
+  const seen = new Set(queue);
+  const files = {};
+  while (queue.length > 0) {
+    const page = queue.shift();
+    const res = await fetch(new URL(encodeURI(page), ghost.origin), {headers: {'X-Forwarded-Proto': site.protocol.slice(0, -1)}});
+    if (!res.ok) {
+      console.error(res.status + ' ' + page);
+      continue;
+    }
+    const type = res.headers.get('content-type') || '';
+    let body = Buffer.from(await res.arrayBuffer());
+    if (textTypes.test(type)) {
+      const text = body.toString();
+      const refs = [
+        ...[...text.matchAll(/(?:href|src)="([^"]+)"/g)].map((m) => m[1]),
+        ...[...text.matchAll(/srcset="([^"]+)"/g)].flatMap((m) => m[1].split(',')),
+        ...[...text.matchAll(/<loc>([^<]+)<\/loc>/g)].map((m) => m[1]),
+        ...[...text.matchAll(/url\(['"]?([^'")]+)['"]?\)/g)].map((m) => m[1]),
+      ];
+      for (const ref of refs) {
+        const p = local(ref, page);
+        if (p && !seen.has(p)) {
+          seen.add(p);
+          queue.push(p);
+        }
+      }
+      body = Buffer.from(rewrite(text));
+    }
+    const file = (page.endsWith('/') ? page + 'index.html' : page).slice(1);
+    files[file] = {body, text: textTypes.test(type)};
+    console.log(res.status + ' ' + page);
+  }
+
+  if (!skipImages) {
+    for (const [file, {body}] of Object.entries(files)) {
+      const target = path.join(process.env.OUTPUT, file);
+      fs.mkdirSync(path.dirname(target), {recursive: true});
+      fs.writeFileSync(target, body);
+    }
+    return;
+  }
+  const data = {};
+  const binaryData = {};
+  for (const [file, {body, text}] of Object.entries(files)) {
+    const key = file.split('/').join(process.env.KEY_SEPARATOR);
+    if (!/^[-._a-zA-Z0-9]+$/.test(key) || key.startsWith('..')) {
+      console.error('Skipping ' + file + ', it cannot be a ConfigMap key');
+      continue;
+    }
+    if (text) {
+      data[key] = body.toString();
+    } else {
+      binaryData[key] = body.toString('base64');
+    }
+  }
+  const token = fs.readFileSync('/var/run/secrets/kubernetes.io/serviceaccount/token', 'utf8');
+  const api = 'https://' + process.env.KUBERNETES_SERVICE_HOST + ':' + process.env.KUBERNETES_SERVICE_PORT;
+  const res = await fetch(api + '/api/v1/namespaces/' + process.env.NAMESPACE + '/configmaps/' + process.env.CONFIGMAP, {
+    method: 'PATCH',
+    headers: {Authorization: 'Bearer ' + token, 'Content-Type': 'application/json-patch+json'},
+    body: JSON.stringify([{op: 'add', path: '/data', value: data}, {op: 'add', path: '/binaryData', value: binaryData}]),
+  });
+  if (!res.ok) {
+    console.error('Writing ConfigMap ' + process.env.CONFIGMAP + ' failed: HTTP ' + res.status + ' ' + await res.text());
+    process.exit(1);
+  }
+}
+main().catch((err) => {
+  console.error(err);
+  process.exit(1);
+});
+`
+
+// staticSiteName names the ConfigMap, Deployment and Service serving the site of the build
+func staticSiteName(build *marketingv1.GhostStaticBuild) string {
+	return staticSiteNamePrefix + build.Name
+}
+
+// staticBuildServiceAccountName is the identity the build Job writes the ConfigMap with
+func staticBuildServiceAccountName(build *marketingv1.GhostStaticBuild) string {
+	return staticBuildJobNamePrefix + build.Name
+}
+
+// staticBuildJobName names the Job of the nth build, finished Jobs are kept for a day
+func staticBuildJobName(build *marketingv1.GhostStaticBuild, n int64) string {
+	return staticBuildJobNamePrefix + build.Name + "-" + strconv.FormatInt(n, 10)
+}
+
+// generateStaticBuildJob renders the Job of the nth build. For an object store the crawl
+// writes to a scratch volume that is synchronised to the bucket, for nginx the crawl writes
+// the ConfigMap itself.
+func generateStaticBuildJob(build *marketingv1.GhostStaticBuild, ghost *marketingv1.Ghost, n int64, proxy *marketingv1.ProxySpec) *batchv1.Job {
+	crawl := corev1.Container{
+		Name:    "crawl",
+		Image:   ghostImage(ghost),
+		Command: []string{"node", "-e", staticBuildScript},
+		Env: []corev1.EnvVar{
+			{Name: "GHOST_URL", Value: fmt.Sprintf("http://%s:%d%s", childName(ghost, svcNamePrefix), servicePort(ghost), ghostPath(ghost))},
+			{Name: "SITE_URL", Value: ghostURL(ghost)},
+		},
+	}
+	podSpec := corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever}
+	if ghost.Spec.Image != nil {
+		podSpec.ImagePullSecrets = ghost.Spec.Image.PullSecrets
+	}
+
+	if store := build.Spec.Destination.ObjectStore; store != nil {
+		scratch := corev1.VolumeMount{Name: "site", MountPath: staticSiteDir}
+		crawl.Env = append(crawl.Env, corev1.EnvVar{Name: "OUTPUT", Value: staticSiteDir})
+		crawl.VolumeMounts = []corev1.VolumeMount{scratch}
+		publish := storageCopyContainer("publish", *store, staticSiteDir, store.URL, scratch)
+		if isGCS(*store) {
+			publish.Command[len(publish.Command)-1] = `gcloud storage rsync -r --delete-unmatched-destination-objects "$SOURCE" "$TARGET"`
+		} else {
+			publish.Command[len(publish.Command)-1] = `aws s3 sync --delete "$SOURCE" "$TARGET"`
+		}
+		podSpec.InitContainers = []corev1.Container{crawl}
+		podSpec.Containers = []corev1.Container{publish}
+		podSpec.Volumes = append([]corev1.Volume{scratchVolume(ghost, "site")}, storageVolumes(*store)...)
+	} else {
+		crawl.Env = append(crawl.Env,
+			corev1.EnvVar{Name: "CONFIGMAP", Value: staticSiteName(build)},
+			corev1.EnvVar{Name: "NAMESPACE", Value: build.Namespace},
+			corev1.EnvVar{Name: "KEY_SEPARATOR", Value: staticSiteKeySeparator},
+			corev1.EnvVar{Name: "NODE_EXTRA_CA_CERTS", Value: "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"},
+		)
+		podSpec.ServiceAccountName = staticBuildServiceAccountName(build)
+		podSpec.Containers = []corev1.Container{crawl}
+	}
+	setPodProxyEnv(&podSpec, ghost, proxy)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      staticBuildJobName(build, n),
+			Namespace: build.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            ptr.To[int32](1),
+			ActiveDeadlineSeconds:   ptr.To[int64](1800),
+			TTLSecondsAfterFinished: ptr.To[int32](86400),
+			Template: corev1.PodTemplateSpec{
+				Spec: podSpec,
+			},
+		},
+	}
+}
+
+// generateStaticSiteConfigMap renders the ConfigMap holding the site, its data is written by
+// the build Job and left out of the applied configuration
+func generateStaticSiteConfigMap(build *marketingv1.GhostStaticBuild) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      staticSiteName(build),
+			Namespace: build.Namespace,
+		},
+	}
+}
+
+// generateStaticBuildRBAC renders the ServiceAccount of the build Job and the Role allowing
+// it to write the ConfigMap of the site
+func generateStaticBuildRBAC(build *marketingv1.GhostStaticBuild) (*corev1.ServiceAccount, *rbacv1.Role, *rbacv1.RoleBinding) {
+	name := staticBuildServiceAccountName(build)
+	meta := metav1.ObjectMeta{Name: name, Namespace: build.Namespace}
+	serviceAccount := &corev1.ServiceAccount{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: meta,
+	}
+	role := &rbacv1.Role{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+		ObjectMeta: meta,
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: []string{staticSiteName(build)},
+			Verbs:         []string{"get", "patch"},
+		}},
+	}
+	binding := &rbacv1.RoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+		ObjectMeta: meta,
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: build.Namespace}},
+	}
+	return serviceAccount, role, binding
+}
+
+// staticSiteItems maps the keys of the site ConfigMap back to the paths of the files
+func staticSiteItems(site *corev1.ConfigMap) []corev1.KeyToPath {
+	var keys []string
+	for key := range site.Data {
+		keys = append(keys, key)
+	}
+	for key := range site.BinaryData {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	items := make([]corev1.KeyToPath, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, corev1.KeyToPath{Key: key, Path: strings.ReplaceAll(key, staticSiteKeySeparator, "/")})
+	}
+	return items
+}
+
+// generateStaticSiteDeployment renders the nginx Deployment serving the files of the site
+// ConfigMap. Until the first build wrote it no file is mounted.
+func generateStaticSiteDeployment(build *marketingv1.GhostStaticBuild, site *corev1.ConfigMap) *appsv1.Deployment {
+	spec := build.Spec.Destination.Nginx
+	replicas := spec.Replicas
+	if replicas == 0 {
+		replicas = 1
+	}
+	image := spec.Image
+	if image == "" {
+		image = "nginx:1.27-alpine"
+	}
+	labels := map[string]string{"app": staticSiteName(build)}
+	volume := corev1.Volume{Name: "site", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+	if items := staticSiteItems(site); len(items) > 0 {
+		volume.VolumeSource = corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: site.Name},
+			Items:                items,
+		}}
+	}
+
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      staticSiteName(build),
+			Namespace: build.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "nginx",
+						Image: image,
+						Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 80}},
+						ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromString("http")},
+						}},
+						VolumeMounts: []corev1.VolumeMount{{Name: "site", MountPath: nginxHTMLPath, ReadOnly: true}},
+					}},
+					Volumes: []corev1.Volume{volume},
+				},
+			},
+		},
+	}
+}
+
+// generateStaticSiteService renders the Service in front of the nginx Deployment
+func generateStaticSiteService(build *marketingv1.GhostStaticBuild) *corev1.Service {
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      staticSiteName(build),
+			Namespace: build.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": staticSiteName(build)},
+			Ports:    []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromString("http")}},
+		},
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// observeDeployment mirrors the replica counts and the image of the live Deployment or
+// StatefulSet into the Ghost status
+func (r *GhostReconciler) observeDeployment(ctx context.Context, ghost *marketingv1.Ghost) error {
+	observed, err := observeWorkload(ctx, r.Client, ghost)
+	if err != nil {
+		return err
+	}
+	ghost.Status.Replicas, ghost.Status.ReadyReplicas, ghost.Status.Image = 0, 0, ""
+	var replicas *int32
+	switch workload := observed.(type) {
+	case *appsv1.Deployment:
+		replicas, ghost.Status.ReadyReplicas = workload.Spec.Replicas, workload.Status.ReadyReplicas
+	case *appsv1.StatefulSet:
+		replicas, ghost.Status.ReadyReplicas = workload.Spec.Replicas, workload.Status.ReadyReplicas
+	default:
+		return nil
+	}
+	ghost.Status.Replicas = 1
+	if replicas != nil {
+		ghost.Status.Replicas = *replicas
+	}
+	if containers := workloadTemplate(observed).Spec.Containers; len(containers) > 0 {
+		ghost.Status.Image = containers[0].Image
+	}
+	return nil
+}
+
+// readyReplicasChanged passes the Deployment and StatefulSet updates that change the mirrored replica counts
+func readyReplicasChanged(e event.UpdateEvent) bool {
+	switch old := e.ObjectOld.(type) {
+	case *appsv1.Deployment:
+		updated, ok := e.ObjectNew.(*appsv1.Deployment)
+		return ok && old.Status.ReadyReplicas != updated.Status.ReadyReplicas
+	case *appsv1.StatefulSet:
+		updated, ok := e.ObjectNew.(*appsv1.StatefulSet)
+		return ok && old.Status.ReadyReplicas != updated.Status.ReadyReplicas
+	}
+	return false
+}
+
+// ghostPhase summarises the status. Missing replicas keep a new Ghost Provisioning, while a
+// Ghost that has been Ready before turns Degraded.
+func ghostPhase(ghost *marketingv1.Ghost) marketingv1.GhostPhase {
+	status := &ghost.Status
+	switch {
+	case !ghost.DeletionTimestamp.IsZero():
+		return marketingv1.GhostPhaseDeleting
+	case meta.IsStatusConditionTrue(status.Conditions, hostConflictCondition),
+		meta.IsStatusConditionTrue(status.Conditions, imagePullFailedCondition),
+		meta.IsStatusConditionTrue(status.Conditions, degradedCondition):
+		return marketingv1.GhostPhaseDegraded
+	case stopped(ghost):
+		return marketingv1.GhostPhaseStopped
+	case status.ReadyReplicas < status.Replicas &&
+		(status.Phase == marketingv1.GhostPhaseReady || status.Phase == marketingv1.GhostPhaseDegraded):
+		return marketingv1.GhostPhaseDegraded
+	case !meta.IsStatusConditionTrue(status.Conditions, "GhostReady") || !allConditionsTrue(status):
+		return marketingv1.GhostPhaseProvisioning
+	case status.ReadyReplicas >= status.Replicas:
+		return marketingv1.GhostPhaseReady
+	default:
+		return marketingv1.GhostPhaseProvisioning
+	}
+}
+
+// publicURL is the address the primary host is served at, or spec.url
+func publicURL(ghost *marketingv1.Ghost, wildcard *WildcardCertificate) string {
+	if ghost.Spec.URL != "" {
+		return ghost.Spec.URL
+	}
+	if !servesHosts(ghost) {
+		return ""
+	}
+	return hostURL(ghost, ingressHosts(ghost)[0], wildcard) + ghostPath(ghost)
+}
+
+// ghostURL is the url Ghost is configured with, status.url is observed ahead of the children
+// as it depends on the wildcard certificate of the operator
+func ghostURL(ghost *marketingv1.Ghost) string {
+	if ghost.Spec.URL != "" {
+		return ghost.Spec.URL
+	}
+	return ghost.Status.URL
+}
+
+// hostURL is the address a host of the Ghost is served at, https when the Ingress or Route
+// terminates TLS for it
+func hostURL(ghost *marketingv1.Ghost, host string, wildcard *WildcardCertificate) string {
+	tls := ghost.Spec.Ingress != nil && ghost.Spec.Ingress.TLS != nil && ghost.Spec.Ingress.TLS.Enabled
+	for _, covered := range wildcardHosts(ghost, wildcard) {
+		tls = tls || covered == host
+	}
+	if tls {
+		return "https://" + host
+	}
+	return "http://" + host
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	maintenanceNamePrefix = "ghost-maintenance-"
+	// maintenanceImage serves the maintenance page, as a non-root user on any port
+	maintenanceImage = "nginxinc/nginx-unprivileged:1.27-alpine"
+	// stoppedReason is the GhostReady reason of a Ghost stopped on purpose
+	stoppedReason = "Stopped"
+	// maintenancePage is answered with a 503 to every request while the Ghost is stopped
+	maintenancePage = `<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>Down for maintenance</title></head>
+<body style="font-family: sans-serif; text-align: center; padding: 4em;">
+<h1>Down for maintenance</h1>
+<p>This site is temporarily unavailable. Please check back soon.</p>
+</body>
+</html>
+`
+)
+
+// stopped reports whether the Ghost is scaled to zero on purpose
+func stopped(ghost *marketingv1.Ghost) bool {
+	return ghost.Spec.Replicas == 0
+}
+
+// maintenancePageEnabled reports whether the hosts of a stopped Ghost serve the maintenance page
+func maintenancePageEnabled(ghost *marketingv1.Ghost) bool {
+	return stopped(ghost) && ghost.Spec.StoppedBehavior != marketingv1.StoppedBehaviorRemoveIngress
+}
+
+// servesHosts reports whether the Ingress, HTTPRoute or Route of the Ghost exists. A Ghost
+// stopped with RemoveIngress keeps claiming its hosts without routing them.
+func servesHosts(ghost *marketingv1.Ghost) bool {
+	return routed(ghost) && !(stopped(ghost) && ghost.Spec.StoppedBehavior == marketingv1.StoppedBehaviorRemoveIngress)
+}
+
+// maintenanceAppLabel is the app label value selecting the maintenance page pods
+func maintenanceAppLabel(ghost *marketingv1.Ghost) string {
+	return appLabel(ghost) + "-maintenance"
+}
+
+// maintenanceConfig serves the page with a 503 on the port the Service targets
+func maintenanceConfig(ghost *marketingv1.Ghost) string {
+	return fmt.Sprintf(`server {
+    listen %d;
+    root /usr/share/nginx/maintenance;
+    error_page 503 /index.html;
+    location = /index.html {
+        internal;
+    }
+    location / {
+        add_header Retry-After 3600 always;
+        return 503;
+    }
+}
+`, containerPort(ghost))
+}
+
+// maintenancePageChild manages the ConfigMap holding the maintenance page and its server config
+type maintenancePageChild struct{}
+
+func (maintenancePageChild) Kind() string {
+	return "MaintenancePage"
+}
+
+func (maintenancePageChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !maintenancePageEnabled(ghost) {
+		return nil, nil
+	}
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, maintenanceNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Data: map[string]string{
+			"default.conf": maintenanceConfig(ghost),
+			"index.html":   maintenancePage,
+		},
+	}, nil
+}
+
+func (maintenancePageChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, maintenanceNamePrefix), &corev1.ConfigMap{})
+}
+
+func (maintenancePageChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// maintenanceDeploymentChild manages the Deployment serving the maintenance page
+type maintenanceDeploymentChild struct{}
+
+func (maintenanceDeploymentChild) Kind() string {
+	return "MaintenanceDeployment"
+}
+
+func (maintenanceDeploymentChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if !maintenancePageEnabled(ghost) {
+		return nil, nil
+	}
+	return generateMaintenanceDeployment(ghost), nil
+}
+
+func (maintenanceDeploymentChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, maintenanceNamePrefix), &appsv1.Deployment{})
+}
+
+func (maintenanceDeploymentChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+func generateMaintenanceDeployment(ghost *marketingv1.Ghost) *appsv1.Deployment {
+	name := childName(ghost, maintenanceNamePrefix)
+	labels := map[string]string{"app": maintenanceAppLabel(ghost)}
+	configVolume := func(volume, key string) corev1.Volume {
+		return corev1.Volume{Name: volume, VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Items:                []corev1.KeyToPath{{Key: key, Path: key}},
+		}}}
+	}
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(int32(1)),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: withCommon(labels, ghost.Spec.CommonLabels),
+					// The server config only changes with the port, which rolls the pods this way
+					Annotations: map[string]string{"marketing.kb.dev/config-hash": shortHash(maintenanceConfig(ghost) + maintenancePage)},
+				},
+				Spec: corev1.PodSpec{
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot:   ptr.To(true),
+						SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+					},
+					Containers: []corev1.Container{{
+						Name:  "maintenance",
+						Image: maintenanceImage,
+						Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: containerPort(ghost)}},
+						ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("http")},
+						}},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("10m"),
+								corev1.ResourceMemory: resource.MustParse("16Mi"),
+							},
+							Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+						},
+						SecurityContext: restrictedSecurityContext(),
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "config", MountPath: "/etc/nginx/conf.d", ReadOnly: true},
+							{Name: "page", MountPath: "/usr/share/nginx/maintenance", ReadOnly: true},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						configVolume("config", "default.conf"),
+						configVolume("page", "index.html"),
+					},
+				},
+			},
+		},
+	}
+}
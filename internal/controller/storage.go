@@ -0,0 +1,168 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// volumeUsagePollInterval is how often the usage of the content volume is measured
+const volumeUsagePollInterval = 5 * time.Minute
+
+// +kubebuilder:rbac:groups="",resources=nodes/proxy,verbs=get
+
+// VolumeUsage is how much of a mounted volume is in use, in bytes and inodes
+type VolumeUsage struct {
+	Used     int64
+	Capacity int64
+	// Inodes and InodesUsed are nil when the filesystem does not report them
+	Inodes     *int64
+	InodesUsed *int64
+}
+
+// VolumeStatsReader reads the usage of a PersistentVolumeClaim mounted on a node
+type VolumeStatsReader interface {
+	// ClaimUsage returns nil when the node does not report the claim
+	ClaimUsage(ctx context.Context, node, namespace, claim string) (*VolumeUsage, error)
+}
+
+// KubeletVolumeStats reads volume usage from the kubelet summary API through the API server proxy
+type KubeletVolumeStats struct {
+	// RESTClient is a client of the core API group
+	RESTClient rest.Interface
+}
+
+// kubeletSummary is the part of the kubelet stats summary holding the pod volumes
+type kubeletSummary struct {
+	Pods []struct {
+		Volumes []struct {
+			UsedBytes     *int64 `json:"usedBytes"`
+			CapacityBytes *int64 `json:"capacityBytes"`
+			Inodes        *int64 `json:"inodes"`
+			InodesUsed    *int64 `json:"inodesUsed"`
+			PVCRef        *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+func (s *KubeletVolumeStats) ClaimUsage(ctx context.Context, node, namespace, claim string) (*VolumeUsage, error) {
+	raw, err := s.RESTClient.Get().Resource("nodes").Name(node).SubResource("proxy", "stats", "summary").DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summary := &kubeletSummary{}
+	if err := json.Unmarshal(raw, summary); err != nil {
+		return nil, err
+	}
+	for _, pod := range summary.Pods {
+		for _, volume := range pod.Volumes {
+			ref := volume.PVCRef
+			if ref == nil || ref.Namespace != namespace || ref.Name != claim || volume.UsedBytes == nil || volume.CapacityBytes == nil {
+				continue
+			}
+			return &VolumeUsage{
+				Used:       *volume.UsedBytes,
+				Capacity:   *volume.CapacityBytes,
+				Inodes:     volume.Inodes,
+				InodesUsed: volume.InodesUsed,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// storagePollInterval is how long until the content volume usage is measured again, zero when it is not
+func (r *GhostReconciler) storagePollInterval() time.Duration {
+	if r.VolumeStats == nil {
+		return 0
+	}
+	return volumeUsagePollInterval
+}
+
+// observeStorage measures the content volume on the node of a running Ghost pod and reports it in
+// status.storage and the volume metrics. It returns the claim with its usage, a nil usage when no
+// running pod mounts it or the kubelet cannot tell.
+func (r *GhostReconciler) observeStorage(ctx context.Context, ghost *marketingv1.Ghost) (*corev1.PersistentVolumeClaim, *VolumeUsage, error) {
+	if r.VolumeStats == nil {
+		return nil, nil, nil
+	}
+	observed, err := observeChild(ctx, r.Client, ghost.ObjectMeta.Namespace, contentClaimName(ghost), &corev1.PersistentVolumeClaim{})
+	if err != nil || observed == nil {
+		return nil, nil, err
+	}
+	pvc := observed.(*corev1.PersistentVolumeClaim)
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(ghost.ObjectMeta.Namespace), client.MatchingLabels{"app": appLabel(ghost)}); err != nil {
+		return nil, nil, err
+	}
+	var node string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.Spec.NodeName != "" {
+			node = pod.Spec.NodeName
+			break
+		}
+	}
+	// The last measurement stays in the status while the Ghost is scaled down or restarting
+	if node == "" {
+		return pvc, nil, nil
+	}
+	usage, err := r.VolumeStats.ClaimUsage(ctx, node, pvc.Namespace, pvc.Name)
+	if err != nil {
+		// The kubelet may not be reachable, the next poll tries again
+		log.FromContext(ctx).Error(err, "Failed to read the content volume usage", "node", node)
+		return pvc, nil, nil
+	}
+	if usage == nil || usage.Capacity <= 0 {
+		return pvc, nil, nil
+	}
+
+	ghost.Status.Storage = &marketingv1.StorageStatus{
+		Capacity:   *resource.NewQuantity(usage.Capacity, resource.BinarySI),
+		Used:       *resource.NewQuantity(usage.Used, resource.BinarySI),
+		Inodes:     usage.Inodes,
+		InodesUsed: usage.InodesUsed,
+	}
+	contentVolumeUsedBytes.WithLabelValues(ghost.Namespace, ghost.Name).Set(float64(usage.Used))
+	contentVolumeCapacityBytes.WithLabelValues(ghost.Namespace, ghost.Name).Set(float64(usage.Capacity))
+	if usage.Inodes != nil && usage.InodesUsed != nil {
+		contentVolumeInodes.WithLabelValues(ghost.Namespace, ghost.Name).Set(float64(*usage.Inodes))
+		contentVolumeInodesUsed.WithLabelValues(ghost.Namespace, ghost.Name).Set(float64(*usage.InodesUsed))
+	}
+	return pvc, usage, nil
+}
+
+// deleteStorageMetrics removes the volume series of a deleted Ghost
+func deleteStorageMetrics(namespace, name string) {
+	contentVolumeUsedBytes.DeleteLabelValues(namespace, name)
+	contentVolumeCapacityBytes.DeleteLabelValues(namespace, name)
+	contentVolumeInodes.DeleteLabelValues(namespace, name)
+	contentVolumeInodesUsed.DeleteLabelValues(namespace, name)
+}
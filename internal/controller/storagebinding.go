@@ -0,0 +1,117 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// storagePendingCondition is set while the content claim is not bound to a volume, with the
+// reason the provisioner or the cluster gives for it, and removed once it is
+const storagePendingCondition = "StoragePending"
+
+// selectedNodeAnnotation is set on a claim waiting for its first consumer once that pod is scheduled
+const selectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+
+// observeClaimBinding records in the StoragePending condition why the content claim of the Ghost
+// is not bound yet, and reports whether it is still waiting. The claim counts as ready in the
+// inventory only once it is bound, an existing but unbound claim is not.
+func (r *GhostReconciler) observeClaimBinding(ctx context.Context, ghost *marketingv1.Ghost) (bool, error) {
+	observed, err := observeChild(ctx, r.Client, ghost.Namespace, contentClaimName(ghost), &corev1.PersistentVolumeClaim{})
+	if err != nil {
+		return false, err
+	}
+	if ephemeral(ghost) || observed == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, storagePendingCondition)
+		return false, nil
+	}
+	pvc := observed.(*corev1.PersistentVolumeClaim)
+	reason, message, err := r.claimPendingReason(ctx, pvc)
+	if err != nil {
+		return false, err
+	}
+	for i := range ghost.Status.Children {
+		if ghost.Status.Children[i].Kind == (pvcChild{}).Kind() {
+			ghost.Status.Children[i].Ready = reason == ""
+		}
+	}
+	if reason == "" {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, storagePendingCondition)
+		return false, nil
+	}
+	setCondition(ghost, storagePendingCondition, metav1.ConditionTrue, reason, message)
+	return true, nil
+}
+
+// claimPendingReason explains why the claim is not bound, an empty reason for a bound claim.
+// The latest Warning event about the claim, such as a failed provisioning, wins over what its
+// StorageClass tells.
+func (r *GhostReconciler) claimPendingReason(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (string, string, error) {
+	prefix := "PVC " + pvc.Name + " "
+	switch pvc.Status.Phase {
+	case corev1.ClaimBound:
+		return "", "", nil
+	case corev1.ClaimLost:
+		return "ClaimLost", prefix + "lost its volume " + pvc.Spec.VolumeName, nil
+	}
+
+	events := &corev1.EventList{}
+	if err := r.List(ctx, events, client.InNamespace(pvc.Namespace)); err != nil {
+		return "", "", err
+	}
+	var latest *corev1.Event
+	for i := range events.Items {
+		event := &events.Items[i]
+		if event.Type != corev1.EventTypeWarning || event.InvolvedObject.Kind != "PersistentVolumeClaim" ||
+			event.InvolvedObject.Name != pvc.Name || event.InvolvedObject.UID != pvc.UID {
+			continue
+		}
+		if latest == nil || eventTime(event).After(eventTime(latest)) {
+			latest = event
+		}
+	}
+	if latest != nil {
+		return latest.Reason, prefix + "is pending: " + latest.Message, nil
+	}
+
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return "ProvisioningPending", prefix + "is waiting for a volume of the default StorageClass", nil
+	}
+	class := *pvc.Spec.StorageClassName
+	observed, err := observeChild(ctx, r.Client, "", class, &storagev1.StorageClass{})
+	if err != nil {
+		return "", "", err
+	}
+	if observed == nil {
+		return "StorageClassNotFound", prefix + "asks for StorageClass " + class + ", which does not exist", nil
+	}
+	mode := observed.(*storagev1.StorageClass).VolumeBindingMode
+	if mode != nil && *mode == storagev1.VolumeBindingWaitForFirstConsumer && pvc.Annotations[selectedNodeAnnotation] == "" {
+		return "WaitForFirstConsumer", prefix + "is provisioned once a Ghost pod using it is scheduled, check the pods for scheduling failures", nil
+	}
+	return "ProvisioningPending", prefix + "is waiting for a volume of StorageClass " + class, nil
+}
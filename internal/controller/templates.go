@@ -0,0 +1,179 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+// The default child manifests ship inside the binary so they can be reviewed and
+// diffed across operator versions. Bump the template-version header on change. Only the
+// PVC, Deployment, Service and Ingress are rendered from a template, every other child
+// is built in code and cannot be overridden.
+//
+//go:embed templates/*.yaml
+var embeddedTemplates embed.FS
+
+const (
+	pvcTemplate        = "pvc.yaml"
+	deploymentTemplate = "deployment.yaml"
+	serviceTemplate    = "service.yaml"
+	ingressTemplate    = "ingress.yaml"
+)
+
+// templateNames are the templates the children are rendered from, the only files an override
+// directory may hold
+var templateNames = []string{pvcTemplate, deploymentTemplate, serviceTemplate, ingressTemplate}
+
+// templateParams are the typed values a manifest template can reference
+type templateParams struct {
+	Name             string
+	Namespace        string
+	AppLabel         string
+	Image            string
+	Replicas         int32
+	ClaimName        string
+	ServiceName      string
+	ServicePort      int32
+	ContainerPort    int32
+	IngressClassName string
+	Hosts            []string
+}
+
+// sampleTemplateParams exercises every field so broken overrides are rejected at startup
+var sampleTemplateParams = templateParams{
+	Name:             "sample",
+	Namespace:        "sample",
+	AppLabel:         "ghost-sample",
+	Image:            "ghost:latest",
+	Replicas:         1,
+	ClaimName:        "sample",
+	ServiceName:      "sample",
+	ServicePort:      80,
+	ContainerPort:    2368,
+	IngressClassName: "nginx",
+	Hosts:            []string{"sample.kb.dev"},
+}
+
+// manifestTemplates holds the parsed templates used to render every child
+var manifestTemplates = mustParseTemplates(embeddedTemplates)
+
+// LoadManifestTemplates replaces the embedded templates with same-named files found
+// in overrideDir, so sites can change the default manifests without rebuilding.
+func LoadManifestTemplates(overrideDir string) error {
+	if overrideDir == "" {
+		return nil
+	}
+	if err := checkOverrideNames(os.DirFS(overrideDir)); err != nil {
+		return fmt.Errorf("loading manifest templates from %s: %w", overrideDir, err)
+	}
+	parsed, err := parseTemplates(overlayFS{base: embeddedTemplates, overrides: os.DirFS(overrideDir)})
+	if err != nil {
+		return fmt.Errorf("loading manifest templates from %s: %w", overrideDir, err)
+	}
+	manifestTemplates = parsed
+	return nil
+}
+
+// checkOverrideNames rejects manifests for children that are not rendered from a template, an
+// override that would silently be ignored. Hidden entries such as the ones of a mounted
+// ConfigMap are skipped.
+func checkOverrideNames(overrides fs.FS) error {
+	entries, err := fs.ReadDir(overrides, ".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || (path.Ext(name) != ".yaml" && path.Ext(name) != ".yml") {
+			continue
+		}
+		if !slices.Contains(templateNames, name) {
+			return fmt.Errorf("%s cannot be overridden, only %s are rendered from templates", name, strings.Join(templateNames, ", "))
+		}
+	}
+	return nil
+}
+
+// overlayFS serves templates/<name> from overrides/<name> when present
+type overlayFS struct {
+	base      fs.FS
+	overrides fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.overrides.Open(path.Base(name)); err == nil {
+		return f, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
+
+func mustParseTemplates(fsys fs.FS) *template.Template {
+	parsed, err := parseTemplates(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseTemplates(fsys fs.FS) (*template.Template, error) {
+	parsed := template.New("manifests").Option("missingkey=error").Funcs(template.FuncMap{
+		"quote": strconv.Quote,
+	})
+	for _, name := range templateNames {
+		data, err := fs.ReadFile(fsys, "templates/"+name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := parsed.New(name).Parse(string(data)); err != nil {
+			return nil, err
+		}
+		// Render once so templates that cannot produce valid YAML fail fast
+		var out bytes.Buffer
+		if err := parsed.ExecuteTemplate(&out, name, sampleTemplateParams); err != nil {
+			return nil, err
+		}
+		var probe map[string]interface{}
+		if err := yaml.Unmarshal(out.Bytes(), &probe); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return parsed, nil
+}
+
+// renderTemplate executes the named template and decodes the YAML into obj
+func renderTemplate(name string, params templateParams, obj interface{}) error {
+	var out bytes.Buffer
+	if err := manifestTemplates.ExecuteTemplate(&out, name, params); err != nil {
+		return err
+	}
+	return yaml.UnmarshalStrict(out.Bytes(), obj)
+}
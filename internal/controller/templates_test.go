@@ -0,0 +1,37 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"testing/fstest"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestManifestTemplateOverrideNames(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(checkOverrideNames(fstest.MapFS{
+		"deployment.yaml": {},
+		"README.md":       {},
+		"..data/hpa.yaml": {},
+	})).To(Succeed())
+	g.Expect(checkOverrideNames(fstest.MapFS{
+		"service.yaml": {},
+		"hpa.yaml":     {},
+	})).To(MatchError(ContainSubstring("hpa.yaml cannot be overridden")))
+}
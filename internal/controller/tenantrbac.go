@@ -0,0 +1,146 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const viewerNamePrefix = "ghost-viewer-"
+
+// The operator can only grant what it holds itself, so it needs read access to everything in viewerRules
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods/log;events,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+
+// viewerRules is the read access the owning team gets to debug its Ghost without cluster-admin help
+var viewerRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods", "pods/log", "services", "persistentvolumeclaims", "configmaps", "events"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"apps"},
+		Resources: []string{"deployments", "replicasets"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"networking.k8s.io"},
+		Resources: []string{"ingresses", "networkpolicies"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"policy"},
+		Resources: []string{"poddisruptionbudgets"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"autoscaling"},
+		Resources: []string{"horizontalpodautoscalers"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"batch"},
+		Resources: []string{"cronjobs", "jobs"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{marketingv1.GroupVersion.Group},
+		Resources: []string{"ghosts", "ghostbackups", "ghostrestores", "ghostpreviews", "ghostmembers", "ghoststaticbuilds", "ghostcontentsyncs", "ghostclones"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+}
+
+// viewerRoleChild manages the Role with the read access of the owning team
+type viewerRoleChild struct{}
+
+func (viewerRoleChild) Kind() string {
+	return "Role"
+}
+
+func (viewerRoleChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if ghost.Spec.OwnerGroup == "" {
+		return nil, nil
+	}
+	rules := viewerRules
+	if portForwardOnly(ghost) {
+		rules = append(slices.Clone(viewerRules), portForwardTokenRule(ghost))
+	}
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(ghost, viewerNamePrefix),
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Rules: rules,
+	}, nil
+}
+
+func (viewerRoleChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, viewerNamePrefix), &rbacv1.Role{})
+}
+
+func (viewerRoleChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
+
+// viewerRoleBindingChild binds the read access Role to spec.ownerGroup
+type viewerRoleBindingChild struct{}
+
+func (viewerRoleBindingChild) Kind() string {
+	return "RoleBinding"
+}
+
+func (viewerRoleBindingChild) Desire(ghost *marketingv1.Ghost) (client.Object, error) {
+	if ghost.Spec.OwnerGroup == "" {
+		return nil, nil
+	}
+	name := childName(ghost, viewerNamePrefix)
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ghost.ObjectMeta.Namespace,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:     rbacv1.GroupKind,
+			APIGroup: rbacv1.GroupName,
+			Name:     ghost.Spec.OwnerGroup,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		},
+	}, nil
+}
+
+func (viewerRoleBindingChild) Observe(ctx context.Context, c client.Client, ghost *marketingv1.Ghost) (client.Object, error) {
+	return observeChild(ctx, c, ghost.ObjectMeta.Namespace, childName(ghost, viewerNamePrefix), &rbacv1.RoleBinding{})
+}
+
+func (viewerRoleBindingChild) Status(observed client.Object) *metav1.Condition {
+	return nil
+}
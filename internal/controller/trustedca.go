@@ -0,0 +1,67 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	trustedCAVolume = "trusted-ca"
+	trustedCADir    = "/etc/ghost/trusted-ca"
+	trustedCAFile   = "ca-bundle.crt"
+)
+
+// mountTrustedCABundle mounts spec.trustedCABundle into the Ghost container and the init
+// containers fetching content or the storage adapter, so they trust a TLS intercepting proxy.
+// Node reads NODE_EXTRA_CA_CERTS, which adds the certificates to the bundled roots instead of
+// replacing them, the content init script appends TRUSTED_CA_FILE to the system roots.
+func mountTrustedCABundle(ghost *marketingv1.Ghost, podSpec *corev1.PodSpec, container *corev1.Container) {
+	bundle := ghost.Spec.TrustedCABundle
+	if bundle == nil {
+		return
+	}
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: trustedCAVolume,
+		VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: bundle.LocalObjectReference,
+			Items:                []corev1.KeyToPath{{Key: bundle.Key, Path: trustedCAFile}},
+			Optional:             bundle.Optional,
+		}},
+	})
+	mountTrustedCA(container, "NODE_EXTRA_CA_CERTS")
+	for i := range podSpec.InitContainers {
+		switch init := &podSpec.InitContainers[i]; init.Name {
+		case storageAdapterContainerName:
+			mountTrustedCA(init, "NODE_EXTRA_CA_CERTS")
+		case contentInitContainerName:
+			mountTrustedCA(init, "TRUSTED_CA_FILE")
+		}
+	}
+}
+
+// mountTrustedCA mounts the bundle into the container and names the file in the variable
+func mountTrustedCA(container *corev1.Container, variable string) {
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      trustedCAVolume,
+		MountPath: trustedCADir,
+		ReadOnly:  true,
+	})
+	container.Env = setEnv(container.Env, variable, trustedCADir+"/"+trustedCAFile)
+}
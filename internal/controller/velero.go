@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+// veleroBackupLabel marks the content PVC and the pods for a Velero Backup to select, e.g.
+// velero backup create --selector marketing.kb.dev/velero-backup=true
+const veleroBackupLabel = "marketing.kb.dev/velero-backup"
+
+// The backup hook annotations Velero reads from the pods, see
+// https://velero.io/docs/main/backup-hooks/
+const (
+	veleroPreHookPrefix           = "pre.hook.backup.velero.io/"
+	veleroPostHookPrefix          = "post.hook.backup.velero.io/"
+	veleroBackupVolumesAnnotation = "backup.velero.io/backup-volumes"
+)
+
+// veleroBackup returns spec.backup.velero when it is enabled
+func veleroBackup(ghost *marketingv1.Ghost) *marketingv1.VeleroBackupSpec {
+	if ghost.Spec.Backup == nil || ghost.Spec.Backup.Velero == nil || !ghost.Spec.Backup.Velero.Enabled {
+		return nil
+	}
+	return ghost.Spec.Backup.Velero
+}
+
+// labelForVelero includes the object in the Velero Backups selecting the Ghost
+func labelForVelero(ghost *marketingv1.Ghost, labels map[string]string) map[string]string {
+	if veleroBackup(ghost) == nil {
+		return labels
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[veleroBackupLabel] = "true"
+	return labels
+}
+
+// applyVeleroHooks stamps the backup hooks on the pod template. The pre hook fails the backup of
+// the pod rather than taking an inconsistent copy of the content.
+func applyVeleroHooks(ghost *marketingv1.Ghost, template *corev1.PodTemplateSpec) {
+	velero := veleroBackup(ghost)
+	if velero == nil {
+		return
+	}
+	template.Labels = labelForVelero(ghost, template.Labels)
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	pre := velero.PreBackupCommand
+	if len(pre) == 0 {
+		pre = []string{"/bin/sh", "-c", "sync"}
+	}
+	setVeleroHook(template.Annotations, veleroPreHookPrefix, pre, velero)
+	template.Annotations[veleroPreHookPrefix+"on-error"] = "Fail"
+	if len(velero.PostBackupCommand) > 0 {
+		setVeleroHook(template.Annotations, veleroPostHookPrefix, velero.PostBackupCommand, velero)
+	}
+	if velero.FileSystemBackup {
+		template.Annotations[veleroBackupVolumesAnnotation] = contentVolumeName
+	}
+}
+
+// setVeleroHook annotates a hook running the command in the Ghost container
+func setVeleroHook(annotations map[string]string, prefix string, command []string, velero *marketingv1.VeleroBackupSpec) {
+	// Velero reads the command as a JSON array
+	value, _ := json.Marshal(command)
+	annotations[prefix+"container"] = GhostContainerName
+	annotations[prefix+"command"] = string(value)
+	if velero.HookTimeout != nil {
+		annotations[prefix+"timeout"] = velero.HookTimeout.Duration.String()
+	}
+}
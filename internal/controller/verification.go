@@ -0,0 +1,118 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const (
+	// httpCheckCondition reports whether Ghost answered spec.verification.httpCheck
+	httpCheckCondition      = "HTTPCheckPassed"
+	defaultHTTPCheckTimeout = 10 * time.Second
+)
+
+// HTTPChecker requests a URL of Ghost for spec.verification.httpCheck
+type HTTPChecker interface {
+	CheckHTTP(ctx context.Context, url string, check marketingv1.HTTPCheckSpec) error
+}
+
+// CheckHTTP fails unless the URL answers with the expected status, redirects are not followed
+func (s *AdminAPISite) CheckHTTP(ctx context.Context, url string, check marketingv1.HTTPCheckSpec) error {
+	timeout := defaultHTTPCheckTimeout
+	if check.Timeout != nil {
+		timeout = check.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	httpClient := http.Client{}
+	if s.HTTPClient != nil {
+		httpClient = *s.HTTPClient
+	}
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	// Ghost redirects plain http requests when its url is https
+	req.Header.Set("X-Forwarded-Proto", "https")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if expected := expectedStatus(check); resp.StatusCode != expected {
+		return fmt.Errorf("GET %s answered %s, expected %d", url, resp.Status, expected)
+	}
+	return nil
+}
+
+// httpCheck is spec.verification.httpCheck, nil when Ghost is not checked
+func httpCheck(ghost *marketingv1.Ghost) *marketingv1.HTTPCheckSpec {
+	if ghost.Spec.Verification == nil {
+		return nil
+	}
+	return ghost.Spec.Verification.HTTPCheck
+}
+
+func httpCheckPath(check *marketingv1.HTTPCheckSpec) string {
+	if check.Path == "" {
+		return "/"
+	}
+	return check.Path
+}
+
+func expectedStatus(check marketingv1.HTTPCheckSpec) int {
+	if check.ExpectedStatus == 0 {
+		return http.StatusOK
+	}
+	return int(check.ExpectedStatus)
+}
+
+// verifyHTTP runs spec.verification.httpCheck against the Ghost Service once the children of
+// desiredHash rolled out, and reports why GhostReady is held back, empty once they passed. A
+// failed check is run again on every pass until it passes.
+func (r *GhostReconciler) verifyHTTP(ctx context.Context, ghost *marketingv1.Ghost, desiredHash string) string {
+	check := httpCheck(ghost)
+	if check == nil || r.HTTPChecks == nil {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, httpCheckCondition)
+		ghost.Status.VerifiedHash = ""
+		return ""
+	}
+	if ghost.Status.VerifiedHash == desiredHash && meta.IsStatusConditionTrue(ghost.Status.Conditions, httpCheckCondition) {
+		return ""
+	}
+	path := httpCheckPath(check)
+	if err := r.HTTPChecks.CheckHTTP(ctx, ghostServiceURL(ghost)+subPath(ghost, path), *check); err != nil {
+		message := "HTTP check failed: " + err.Error()
+		setCondition(ghost, httpCheckCondition, metav1.ConditionFalse, "CheckFailed", message)
+		return message
+	}
+	setCondition(ghost, httpCheckCondition, metav1.ConditionTrue, "Passed",
+		"GET "+path+" answered "+strconv.Itoa(expectedStatus(*check)))
+	ghost.Status.VerifiedHash = desiredHash
+	return ""
+}
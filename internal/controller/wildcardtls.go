@@ -0,0 +1,168 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	marketingv1 "github.com/jiaqi-yin/ghost-controller/api/v1"
+)
+
+const wildcardSecretNamePrefix = "ghost-wildcard-tls-"
+const wildcardTLSCondition = "WildcardTLSInSync"
+
+// reflectedFromAnnotation records the Secret a wildcard certificate copy was taken from
+const reflectedFromAnnotation = "marketing.kb.dev/reflected-from"
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;patch;delete
+
+// WildcardCertificate is the operator wide TLS Secret for *.Domain, used by the Ingress
+// of every Ghost under that domain without a spec.ingress.tls of its own
+type WildcardCertificate struct {
+	// Domain is the parent of the covered hosts, e.g. blogs.example.com for *.blogs.example.com
+	Domain string
+	// Namespace and Name locate the source Secret
+	Namespace string
+	Name      string
+}
+
+// ParseWildcardCertificate parses the namespace/name of the source Secret, nil when secret is empty
+func ParseWildcardCertificate(secret, domain string) (*WildcardCertificate, error) {
+	if secret == "" {
+		return nil, nil
+	}
+	namespace, name, ok := strings.Cut(secret, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("wildcard TLS secret %q is not of the form namespace/name", secret)
+	}
+	domain = strings.TrimPrefix(strings.TrimPrefix(domain, "*"), ".")
+	if domain == "" {
+		return nil, fmt.Errorf("wildcard TLS secret %q needs a domain", secret)
+	}
+	return &WildcardCertificate{Domain: domain, Namespace: namespace, Name: name}, nil
+}
+
+// covers reports whether the certificate is valid for the host, a wildcard only matches a single label
+func (w *WildcardCertificate) covers(host string) bool {
+	label, ok := strings.CutSuffix(host, "."+w.Domain)
+	return ok && label != "" && !strings.Contains(label, ".")
+}
+
+// wildcardHosts returns the Ingress hosts served with the wildcard certificate,
+// none when the Ghost configures TLS itself or no host is under the domain
+func wildcardHosts(ghost *marketingv1.Ghost, wildcard *WildcardCertificate) []string {
+	if wildcard == nil || !ingressEnabled(ghost) || (ghost.Spec.Ingress != nil && ghost.Spec.Ingress.TLS != nil) {
+		return nil
+	}
+	var hosts []string
+	for _, host := range ingressHosts(ghost) {
+		if wildcard.covers(host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// wildcardSecretName is the Secret the Ingress references, Ghosts next to the source use it directly
+func wildcardSecretName(ghost *marketingv1.Ghost, wildcard *WildcardCertificate) string {
+	if ghost.ObjectMeta.Namespace == wildcard.Namespace {
+		return wildcard.Name
+	}
+	return childName(ghost, wildcardSecretNamePrefix)
+}
+
+// reflectWildcardSecret copies the wildcard certificate next to a Ghost whose Ingress uses it and
+// removes the copy once it does not. The copy is refreshed on every reconcile so renewals reach it.
+func (r *GhostReconciler) reflectWildcardSecret(ctx context.Context, ghost *marketingv1.Ghost) error {
+	name := childName(ghost, wildcardSecretNamePrefix)
+	observed, err := observeChild(ctx, r.secretReader(), ghost.ObjectMeta.Namespace, name, &corev1.Secret{})
+	if err != nil {
+		return err
+	}
+	wildcard := r.WildcardCertificate
+	if len(wildcardHosts(ghost, wildcard)) == 0 || ghost.ObjectMeta.Namespace == wildcard.Namespace {
+		meta.RemoveStatusCondition(&ghost.Status.Conditions, wildcardTLSCondition)
+		if observed == nil || !metav1.IsControlledBy(observed, ghost) {
+			return nil
+		}
+		if r.readOnly(ghost) {
+			setCondition(ghost, wildcardTLSCondition, metav1.ConditionFalse, "DriftDetected", "Secret "+name+" would be deleted, skipped in read-only mode")
+			return nil
+		}
+		return client.IgnoreNotFound(r.Delete(ctx, observed))
+	}
+
+	source := &corev1.Secret{}
+	if err := r.secretReader().Get(ctx, client.ObjectKey{Namespace: wildcard.Namespace, Name: wildcard.Name}, source); err != nil {
+		return externalError(fmt.Errorf("wildcard TLS secret %s/%s: %w", wildcard.Namespace, wildcard.Name, err))
+	}
+	desired := generateWildcardSecret(ghost, name, source)
+	if r.readOnly(ghost) {
+		drift := "is missing and would be created"
+		if observed != nil {
+			fields, err := r.childDrifted(ctx, ghost, desired, observed)
+			if err != nil || len(fields) == 0 {
+				return err
+			}
+			drift = "has drifted and would be updated"
+		}
+		setCondition(ghost, wildcardTLSCondition, metav1.ConditionFalse, "DriftDetected", "Secret "+name+" "+drift+", skipped in read-only mode")
+		return nil
+	}
+	if observed != nil {
+		if err := r.upgradeFieldManager(ctx, observed); err != nil {
+			return err
+		}
+	}
+	if err := r.applyChild(ctx, ghost, desired); err != nil {
+		return err
+	}
+	if observed == nil || desired.GetResourceVersion() != observed.GetResourceVersion() {
+		r.Recoder.Event(ghost, corev1.EventTypeNormal, "WildcardTLSReflected", "Secret "+name+" copied from "+wildcard.Namespace+"/"+wildcard.Name)
+	}
+	setCondition(ghost, wildcardTLSCondition, metav1.ConditionTrue, "Reflected", "Secret "+name+" matches "+wildcard.Namespace+"/"+wildcard.Name)
+	return nil
+}
+
+// secretReader reads Secrets past the cache when an API reader is set
+func (r *GhostReconciler) secretReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+func generateWildcardSecret(ghost *marketingv1.Ghost, name string, source *corev1.Secret) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   ghost.ObjectMeta.Namespace,
+			Labels:      withCommon(map[string]string{"app": appLabel(ghost)}, ghost.Spec.CommonLabels),
+			Annotations: withCommon(map[string]string{reflectedFromAnnotation: source.Namespace + "/" + source.Name}, ghost.Spec.CommonAnnotations),
+		},
+		Type: source.Type,
+		Data: source.Data,
+	}
+}
@@ -0,0 +1,349 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghostapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const testKey = "6489b2b6c6a4e2001c0b1a2c:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+var _ = Describe("Admin API client", func() {
+	It("should reject malformed keys", func() {
+		_, err := New("http://ghost", "no-secret")
+		Expect(err).To(HaveOccurred())
+		_, err = New("http://ghost", "id:not-hex")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should sign tokens with the key secret", func() {
+		c, err := New("http://ghost", testKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		parts := strings.Split(c.token(time.Now()), ".")
+		Expect(parts).To(HaveLen(3))
+		header, err := base64.RawURLEncoding.DecodeString(parts[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(header)).To(ContainSubstring(`"kid":"6489b2b6c6a4e2001c0b1a2c"`))
+
+		secret, _ := hex.DecodeString(strings.Split(testKey, ":")[1])
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(parts[0] + "." + parts[1]))
+		Expect(parts[2]).To(Equal(base64.RawURLEncoding.EncodeToString(mac.Sum(nil))))
+	})
+
+	It("should upload and activate a theme", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(HavePrefix("Ghost "))
+			switch {
+			case r.Method == http.MethodPost && r.URL.Path == "/ghost/api/admin/themes/upload/":
+				file, header, err := r.FormFile("file")
+				Expect(err).NotTo(HaveOccurred())
+				data, _ := io.ReadAll(file)
+				Expect(header.Filename).To(Equal("casper.zip"))
+				Expect(string(data)).To(Equal("zip"))
+				_, _ = io.WriteString(w, `{"themes":[{"name":"casper","active":false}]}`)
+			case r.Method == http.MethodPut && r.URL.Path == "/ghost/api/admin/themes/casper/activate/":
+				_, _ = io.WriteString(w, `{"themes":[{"name":"casper","active":true}]}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		c, err := New(server.URL, testKey)
+		Expect(err).NotTo(HaveOccurred())
+		theme, err := c.UploadTheme(context.Background(), "casper.zip", []byte("zip"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(theme.Name).To(Equal("casper"))
+		theme, err = c.ActivateTheme(context.Background(), theme.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(theme.Active).To(BeTrue())
+	})
+
+	It("should surface Ghost error messages", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = io.WriteString(w, `{"errors":[{"message":"Theme is invalid"}]}`)
+		}))
+		defer server.Close()
+
+		c, err := New(server.URL, testKey)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.UploadTheme(context.Background(), "broken.zip", []byte("zip"))
+		var apiErr *Error
+		Expect(err).To(BeAssignableToTypeOf(apiErr))
+		Expect(err.Error()).To(ContainSubstring("Theme is invalid"))
+	})
+
+	It("should read the site without a key", func() {
+		healthy := true
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/ghost/api/admin/site/"))
+			Expect(r.Header.Get("Authorization")).To(BeEmpty())
+			if !healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = io.WriteString(w, `{"errors":[{"message":"Database is unavailable"}]}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"site":{"title":"News","url":"https://news.example.com/","version":"5.96"}}`)
+		}))
+		defer server.Close()
+
+		site, err := GetSite(context.Background(), nil, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(site.Version).To(Equal("5.96"))
+
+		healthy = false
+		_, err = GetSite(context.Background(), nil, server.URL)
+		Expect(err).To(MatchError(ContainSubstring("Database is unavailable")))
+	})
+
+	It("should complete the initial setup once", func() {
+		completed := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/ghost/api/admin/authentication/setup/"))
+			switch r.Method {
+			case http.MethodGet:
+				_, _ = io.WriteString(w, fmt.Sprintf(`{"setup":[{"status":%t}]}`, completed))
+			case http.MethodPost:
+				body, _ := io.ReadAll(r.Body)
+				Expect(string(body)).To(ContainSubstring(`"email":"owner@example.com"`))
+				completed = true
+				w.WriteHeader(http.StatusCreated)
+				_, _ = io.WriteString(w, `{"users":[{"email":"owner@example.com"}]}`)
+			}
+		}))
+		defer server.Close()
+
+		done, err := SetupCompleted(context.Background(), nil, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(done).To(BeFalse())
+		Expect(CompleteSetup(context.Background(), nil, server.URL, Setup{
+			Name: "Owner", Email: "owner@example.com", Password: "correct-horse-battery", BlogTitle: "News",
+		})).To(Succeed())
+		done, err = SetupCompleted(context.Background(), nil, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(done).To(BeTrue())
+	})
+
+	It("should create, update and delete webhooks", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(HavePrefix("Ghost "))
+			if r.Method == http.MethodDelete {
+				Expect(r.URL.Path).To(Equal("/ghost/api/admin/webhooks/hook1/"))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			Expect(string(body)).NotTo(ContainSubstring(`"id"`))
+			Expect(string(body)).To(ContainSubstring(`"event":"site.changed"`))
+			switch {
+			case r.Method == http.MethodPost && r.URL.Path == "/ghost/api/admin/webhooks/":
+				w.WriteHeader(http.StatusCreated)
+				_, _ = io.WriteString(w, `{"webhooks":[{"id":"hook1","event":"site.changed","target_url":"https://ci.example.com/build"}]}`)
+			case r.Method == http.MethodPut && r.URL.Path == "/ghost/api/admin/webhooks/hook1/":
+				_, _ = io.WriteString(w, `{"webhooks":[{"id":"hook1","event":"site.changed","target_url":"https://ci.example.com/rebuild"}]}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		c, err := New(server.URL, testKey)
+		Expect(err).NotTo(HaveOccurred())
+		webhook, err := c.CreateWebhook(context.Background(), Webhook{Event: "site.changed", TargetURL: "https://ci.example.com/build"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(webhook.ID).To(Equal("hook1"))
+		webhook.TargetURL = "https://ci.example.com/rebuild"
+		webhook, err = c.UpdateWebhook(context.Background(), *webhook)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(webhook.TargetURL).To(Equal("https://ci.example.com/rebuild"))
+		Expect(c.DeleteWebhook(context.Background(), "hook1")).To(Succeed())
+	})
+
+	It("should find staff users and change their role", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(HavePrefix("Ghost "))
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/ghost/api/admin/roles/":
+				_, _ = io.WriteString(w, `{"roles":[{"id":"r1","name":"Editor"},{"id":"r2","name":"Author"}]}`)
+			case r.Method == http.MethodGet && r.URL.Path == "/ghost/api/admin/users/email/ada@example.com/":
+				Expect(r.URL.Query().Get("include")).To(Equal("roles"))
+				_, _ = io.WriteString(w, `{"users":[{"id":"u1","email":"ada@example.com","roles":[{"id":"r2","name":"Author"}]}]}`)
+			case r.Method == http.MethodPut && r.URL.Path == "/ghost/api/admin/users/u1/":
+				body, _ := io.ReadAll(r.Body)
+				Expect(string(body)).To(ContainSubstring(`"roles":[{"id":"r1"`))
+				_, _ = io.WriteString(w, `{"users":[{"id":"u1","email":"ada@example.com","roles":[{"id":"r1","name":"Editor"}]}]}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = io.WriteString(w, `{"errors":[{"message":"User not found."}]}`)
+			}
+		}))
+		defer server.Close()
+
+		c, err := New(server.URL, testKey)
+		Expect(err).NotTo(HaveOccurred())
+		roles, err := c.ListRoles(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(roles).To(ContainElement(Role{ID: "r1", Name: "Editor"}))
+		user, err := c.FindUser(context.Background(), "ada@example.com")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.Roles).To(Equal([]Role{{ID: "r2", Name: "Author"}}))
+		user, err = c.UpdateUserRole(context.Background(), user.ID, "r1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.Roles).To(Equal([]Role{{ID: "r1", Name: "Editor"}}))
+		user, err = c.FindUser(context.Background(), "grace@example.com")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user).To(BeNil())
+	})
+
+	It("should invite staff users and revoke the invitations", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodPost && r.URL.Path == "/ghost/api/admin/invites/":
+				body, _ := io.ReadAll(r.Body)
+				Expect(string(body)).To(Equal(`{"invites":[{"email":"ada@example.com","role_id":"r1"}]}`))
+				w.WriteHeader(http.StatusCreated)
+				_, _ = io.WriteString(w, `{"invites":[{"id":"i1","email":"ada@example.com","role_id":"r1","status":"sent"}]}`)
+			case r.Method == http.MethodGet && r.URL.Path == "/ghost/api/admin/invites/":
+				_, _ = io.WriteString(w, `{"invites":[{"id":"i1","email":"ada@example.com","role_id":"r1","status":"sent"}]}`)
+			case r.Method == http.MethodDelete && r.URL.Path == "/ghost/api/admin/invites/i1/":
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		c, err := New(server.URL, testKey)
+		Expect(err).NotTo(HaveOccurred())
+		invite, err := c.CreateInvite(context.Background(), "ada@example.com", "r1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(invite.ID).To(Equal("i1"))
+		invites, err := c.ListInvites(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(invites).To(ConsistOf(HaveField("Email", "ada@example.com")))
+		Expect(c.DeleteInvite(context.Background(), "i1")).To(Succeed())
+		Expect(IsNotFound(c.DeleteInvite(context.Background(), "i2"))).To(BeTrue())
+	})
+
+	It("should download and upload the routes and redirects", func() {
+		uploaded := map[string]string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/ghost/api/admin/settings/routes/yaml/":
+				w.Header().Set("Content-Type", "application/yaml")
+				_, _ = io.WriteString(w, "routes: {}\n")
+			case r.Method == http.MethodGet && r.URL.Path == "/ghost/api/admin/redirects/download/":
+				_, _ = io.WriteString(w, "[]")
+			case r.Method == http.MethodPost:
+				field := map[string]string{"/ghost/api/admin/settings/routes/yaml/": "routes", "/ghost/api/admin/redirects/upload/": "redirects"}[r.URL.Path]
+				file, header, err := r.FormFile(field)
+				Expect(err).NotTo(HaveOccurred())
+				data, _ := io.ReadAll(file)
+				uploaded[header.Filename] = string(data)
+				_, _ = io.WriteString(w, `{}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		c, err := New(server.URL, testKey)
+		Expect(err).NotTo(HaveOccurred())
+		routes, err := c.DownloadRoutes(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(routes)).To(Equal("routes: {}\n"))
+		redirects, err := c.DownloadRedirects(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(redirects)).To(Equal("[]"))
+		Expect(c.UploadRoutes(context.Background(), []byte("routes:\n  /: {}\n"))).To(Succeed())
+		Expect(c.UploadRedirects(context.Background(), "redirects.yaml", []byte("301: {}\n"))).To(Succeed())
+		Expect(uploaded).To(Equal(map[string]string{"routes.yaml": "routes:\n  /: {}\n", "redirects.yaml": "301: {}\n"}))
+	})
+
+	It("should create, update and delete custom integrations", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			switch {
+			case r.Method == http.MethodPost && r.URL.Path == "/ghost/api/admin/integrations/":
+				Expect(r.URL.Query().Get("include")).To(Equal("api_keys"))
+				Expect(string(body)).To(Equal(`{"integrations":[{"name":"Zapier","description":"Posts to Slack"}]}`))
+				w.WriteHeader(http.StatusCreated)
+				_, _ = io.WriteString(w, `{"integrations":[{"id":"int1","name":"Zapier","api_keys":[`+
+					`{"id":"k1","type":"content","secret":"c0ffee"},{"id":"k2","type":"admin","secret":"beef"}]}]}`)
+			case r.Method == http.MethodPut && r.URL.Path == "/ghost/api/admin/integrations/int1/":
+				Expect(string(body)).To(Equal(`{"integrations":[{"name":"Zapier EU"}]}`))
+				_, _ = io.WriteString(w, `{"integrations":[{"id":"int1","name":"Zapier EU"}]}`)
+			case r.Method == http.MethodDelete && r.URL.Path == "/ghost/api/admin/integrations/int1/":
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		c, err := New(server.URL, testKey)
+		Expect(err).NotTo(HaveOccurred())
+		integration, err := c.CreateIntegration(context.Background(), Integration{Name: "Zapier", Description: "Posts to Slack"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(integration.Key("content")).To(Equal("c0ffee"))
+		Expect(integration.Key("admin")).To(Equal("k2:beef"))
+		integration, err = c.UpdateIntegration(context.Background(), Integration{ID: "int1", Name: "Zapier EU"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(integration.Name).To(Equal("Zapier EU"))
+		Expect(c.DeleteIntegration(context.Background(), "int1")).To(Succeed())
+		_, err = c.GetIntegration(context.Background(), "int2")
+		Expect(IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should find the last change of the published content", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("filter")).To(Equal("status:published"))
+			Expect(r.URL.Query().Get("order")).To(Equal("updated_at desc"))
+			switch r.URL.Path {
+			case "/ghost/api/admin/posts/":
+				_, _ = io.WriteString(w, `{"posts":[{"updated_at":"2026-10-01T08:00:00.000Z"}]}`)
+			case "/ghost/api/admin/pages/":
+				_, _ = io.WriteString(w, `{"pages":[{"updated_at":"2026-10-02T09:30:00.000Z"}]}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		c, err := New(server.URL, testKey)
+		Expect(err).NotTo(HaveOccurred())
+		changed, err := c.LastPublishedChange(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(Equal(time.Date(2026, 10, 2, 9, 30, 0, 0, time.UTC)))
+	})
+})